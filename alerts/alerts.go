@@ -0,0 +1,363 @@
+// Package alerts notifies operators when the tunnel stays unhealthy past configured thresholds, through webhooks,
+// Slack or PagerDuty, so that outages page someone without external monitoring.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+const (
+	defaultCooldown           = 15 * time.Minute
+	defaultEvaluationInterval = time.Minute
+	defaultMinRequests        = 20
+	queueSize                 = 64
+
+	responseByCodeMetric = "cloudflared_tunnel_response_by_code"
+	requestErrorsMetric  = "cloudflared_tunnel_request_errors"
+)
+
+// Condition identifies what an alert is about.
+type Condition string
+
+const (
+	// ConnectionsDown fires when no connection to the edge has been registered for Config.ConnectionsDownAfter.
+	ConnectionsDown Condition = "connections_down"
+	// OriginErrorRate fires when the share of 5xx responses and failed requests to origins reaches
+	// Config.OriginErrorRate.
+	OriginErrorRate Condition = "origin_error_rate"
+	// RegistrationFailures fires when Config.RegistrationFailures connections in a row fail to register.
+	RegistrationFailures Condition = "registration_failures"
+)
+
+// State is whether an alert starts or ends.
+type State string
+
+const (
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Alert is sent to every channel when a condition starts or stops being met.
+type Alert struct {
+	Condition   Condition `json:"condition"`
+	State       State     `json:"state"`
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+	ConnectorID uuid.UUID `json:"connectorId"`
+}
+
+// Config sets the thresholds of the conditions, which are disabled when left at zero, and where alerts are sent.
+type Config struct {
+	// ConnectionsDownAfter is how long all the connections to the edge must be down for ConnectionsDown to fire.
+	ConnectionsDownAfter time.Duration
+	// OriginErrorRate is the percentage of failed origin requests, over EvaluationInterval, that fires
+	// OriginErrorRate.
+	OriginErrorRate float64
+	// MinRequests is how many origin requests an evaluation interval needs for its error rate to count. Defaults to 20.
+	MinRequests int
+	// RegistrationFailures is how many registration failures in a row fire RegistrationFailures.
+	RegistrationFailures int
+	// Cooldown is the minimum time between two notifications that a condition fires. Defaults to 15 minutes.
+	Cooldown time.Duration
+	// EvaluationInterval is how often the origin error rate is computed. Defaults to 1 minute.
+	EvaluationInterval time.Duration
+
+	// WebhookURLs receive a POST request with the JSON Alert.
+	WebhookURLs []string
+	// SlackWebhookURLs are Slack incoming webhooks.
+	SlackWebhookURLs []string
+	// PagerDutyRoutingKey is the integration key of a PagerDuty service using the Events API v2.
+	PagerDutyRoutingKey string
+	// PagerDutyURL is the Events API v2 endpoint. Defaults to PagerDuty's.
+	PagerDutyURL string
+
+	// Gatherer provides the metrics the origin error rate is computed from. Defaults to prometheus.DefaultGatherer.
+	Gatherer prometheus.Gatherer
+}
+
+// Enabled tells whether a condition is configured, and there's a channel to send its alerts to.
+func (c *Config) Enabled() bool {
+	hasCondition := c.ConnectionsDownAfter > 0 || c.OriginErrorRate > 0 || c.RegistrationFailures > 0
+	hasChannel := len(c.WebhookURLs) > 0 || len(c.SlackWebhookURLs) > 0 || c.PagerDutyRoutingKey != ""
+	return hasCondition && hasChannel
+}
+
+type conditionState struct {
+	firing bool
+	// notified is whether the alert that the condition fires was sent, so that its resolution is sent too
+	notified     bool
+	lastNotified time.Time
+}
+
+// Alerter is a connection.EventSink that evaluates the conditions and sends alerts when they change.
+type Alerter struct {
+	config      Config
+	connectorID uuid.UUID
+	channels    []channel
+	queue       chan Alert
+	log         *zerolog.Logger
+
+	lock       sync.Mutex
+	conditions map[Condition]*conditionState
+	connected  map[uint8]bool
+	// downSince is when the last connection was lost, zero while a connection is up
+	downSince             time.Time
+	downTimer             *time.Timer
+	registrationErrors    int
+	shuttingDown          bool
+	lastRequests          float64
+	lastFailedRequests    float64
+	originMetricsBaseline bool
+}
+
+// New creates an Alerter. Conditions are evaluated and alerts sent until ctx is done.
+func New(ctx context.Context, config Config, connectorID uuid.UUID, log *zerolog.Logger) *Alerter {
+	if config.Cooldown == 0 {
+		config.Cooldown = defaultCooldown
+	}
+	if config.EvaluationInterval == 0 {
+		config.EvaluationInterval = defaultEvaluationInterval
+	}
+	if config.MinRequests == 0 {
+		config.MinRequests = defaultMinRequests
+	}
+	if config.Gatherer == nil {
+		config.Gatherer = prometheus.DefaultGatherer
+	}
+	a := &Alerter{
+		config:      config,
+		connectorID: connectorID,
+		channels:    newChannels(config, connectorID),
+		queue:       make(chan Alert, queueSize),
+		log:         log,
+		conditions: map[Condition]*conditionState{
+			ConnectionsDown:      {},
+			OriginErrorRate:      {},
+			RegistrationFailures: {},
+		},
+		connected: make(map[uint8]bool),
+	}
+	// The tunnel hasn't connected yet, which counts as being down
+	a.lock.Lock()
+	a.connectionsLost(time.Now())
+	a.lock.Unlock()
+	go a.run(ctx)
+	if config.OriginErrorRate > 0 {
+		go a.evaluateOriginErrors(ctx)
+	}
+	return a
+}
+
+func (a *Alerter) OnTunnelEvent(event connection.Event) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	switch event.EventType {
+	case connection.Connected:
+		a.connected[event.Index] = true
+		a.registrationErrors = 0
+		a.downSince = time.Time{}
+		if a.downTimer != nil {
+			a.downTimer.Stop()
+		}
+		a.resolve(ConnectionsDown, "a connection to the edge is registered again")
+		a.resolve(RegistrationFailures, "a connection to the edge registered successfully")
+	case connection.Unregistering, connection.Disconnected, connection.Reconnecting, connection.RegisteringTunnel:
+		// Connections also unregister when they are lost, only a shutdown isn't an outage
+		if event.EventType == connection.Unregistering && event.ShuttingDown {
+			a.shuttingDown = true
+			if a.downTimer != nil {
+				a.downTimer.Stop()
+			}
+		}
+		wasActive := a.activeConnections() > 0
+		a.connected[event.Index] = false
+		if wasActive && a.activeConnections() == 0 && !a.shuttingDown {
+			a.connectionsLost(time.Now())
+		}
+	case connection.RegistrationError:
+		a.registrationErrors++
+		if a.config.RegistrationFailures > 0 && a.registrationErrors >= a.config.RegistrationFailures {
+			message := fmt.Sprintf("%d connections in a row failed to register", a.registrationErrors)
+			if event.Err != nil {
+				message = fmt.Sprintf("%s, the last one with: %v", message, event.Err)
+			}
+			a.fire(RegistrationFailures, message)
+		}
+	}
+}
+
+func (a *Alerter) activeConnections() int {
+	active := 0
+	for _, connected := range a.connected {
+		if connected {
+			active++
+		}
+	}
+	return active
+}
+
+// connectionsLost fires ConnectionsDown if no connection comes back within the threshold.
+// The caller must hold the lock.
+func (a *Alerter) connectionsLost(now time.Time) {
+	if a.config.ConnectionsDownAfter <= 0 {
+		return
+	}
+	a.downSince = now
+	if a.downTimer != nil {
+		a.downTimer.Stop()
+	}
+	a.downTimer = time.AfterFunc(a.config.ConnectionsDownAfter, func() {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		if !a.downSince.Equal(now) || a.shuttingDown {
+			return
+		}
+		a.fire(ConnectionsDown, fmt.Sprintf("no connection to the edge has been registered for %s", a.config.ConnectionsDownAfter))
+	})
+}
+
+// evaluateOriginErrors computes the origin error rate over every evaluation interval.
+func (a *Alerter) evaluateOriginErrors(ctx context.Context) {
+	ticker := time.NewTicker(a.config.EvaluationInterval)
+	defer ticker.Stop()
+	a.checkOriginErrors()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.checkOriginErrors()
+		}
+	}
+}
+
+func (a *Alerter) checkOriginErrors() {
+	requests, failedRequests, err := originRequests(a.config.Gatherer)
+	if err != nil {
+		a.log.Err(err).Msg("Failed to gather the metrics of origin requests for alerts")
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	intervalRequests := requests - a.lastRequests
+	intervalFailedRequests := failedRequests - a.lastFailedRequests
+	a.lastRequests, a.lastFailedRequests = requests, failedRequests
+	// The counters include the requests proxied before the first evaluation, which aren't part of an interval
+	if !a.originMetricsBaseline {
+		a.originMetricsBaseline = true
+		return
+	}
+	if intervalRequests < float64(a.config.MinRequests) {
+		return
+	}
+	rate := intervalFailedRequests / intervalRequests * 100
+	if rate >= a.config.OriginErrorRate {
+		a.fire(OriginErrorRate, fmt.Sprintf("%.1f%% of the %.0f origin requests of the last %s failed", rate, intervalRequests, a.config.EvaluationInterval))
+	} else {
+		a.resolve(OriginErrorRate, fmt.Sprintf("%.1f%% of the origin requests of the last %s failed", rate, a.config.EvaluationInterval))
+	}
+}
+
+// originRequests returns how many origin requests were made, and how many got a 5xx response or failed, since
+// cloudflared started.
+func originRequests(gatherer prometheus.Gatherer) (requests, failedRequests float64, err error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, family := range families {
+		switch family.GetName() {
+		case responseByCodeMetric:
+			for _, metric := range family.GetMetric() {
+				value := metric.GetCounter().GetValue()
+				requests += value
+				if statusCode := labelValue(metric, "status_code"); len(statusCode) == 3 && statusCode[0] == '5' {
+					failedRequests += value
+				}
+			}
+		case requestErrorsMetric:
+			for _, metric := range family.GetMetric() {
+				requests += metric.GetCounter().GetValue()
+				failedRequests += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return requests, failedRequests, nil
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// fire notifies that the condition is met, unless it already was or it was notified less than a cooldown ago.
+// The caller must hold the lock.
+func (a *Alerter) fire(condition Condition, message string) {
+	state := a.conditions[condition]
+	if state.firing {
+		return
+	}
+	state.firing = true
+	now := time.Now()
+	if !state.lastNotified.IsZero() && now.Sub(state.lastNotified) < a.config.Cooldown {
+		a.log.Debug().Str("condition", string(condition)).Msg("Not sending the alert again during its cooldown")
+		return
+	}
+	state.notified = true
+	state.lastNotified = now
+	a.send(Alert{Condition: condition, State: Firing, Message: message, Time: now.UTC()})
+}
+
+// resolve notifies that the condition isn't met anymore, if its alert was sent.
+// The caller must hold the lock.
+func (a *Alerter) resolve(condition Condition, message string) {
+	state := a.conditions[condition]
+	if !state.firing {
+		return
+	}
+	state.firing = false
+	if !state.notified {
+		return
+	}
+	state.notified = false
+	a.send(Alert{Condition: condition, State: Resolved, Message: message, Time: time.Now().UTC()})
+}
+
+func (a *Alerter) send(alert Alert) {
+	alert.ConnectorID = a.connectorID
+	a.log.Warn().Str("condition", string(alert.Condition)).Str("state", string(alert.State)).Msg(alert.Message)
+	select {
+	case a.queue <- alert:
+	default:
+		a.log.Warn().Str("condition", string(alert.Condition)).Msg("Dropping alert because too many are pending")
+	}
+}
+
+func (a *Alerter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-a.queue:
+			for _, ch := range a.channels {
+				if err := ch.send(ctx, alert); err != nil {
+					a.log.Err(err).Str("channel", ch.name()).Str("condition", string(alert.Condition)).Msg("Failed to send alert")
+				}
+			}
+		}
+	}
+}
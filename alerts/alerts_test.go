@@ -0,0 +1,169 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+var testLogger = zerolog.Nop()
+
+// receiver records the JSON bodies POSTed to it.
+func receiver(t *testing.T) (*httptest.Server, <-chan map[string]any) {
+	bodies := make(chan map[string]any, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies <- body
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+func next(t *testing.T, bodies <-chan map[string]any) map[string]any {
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an alert")
+		return nil
+	}
+}
+
+func assertNothingSent(t *testing.T, bodies <-chan map[string]any) {
+	select {
+	case body := <-bodies:
+		t.Fatalf("Unexpected alert %v", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newTestAlerter(t *testing.T, config Config) *Alerter {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return New(ctx, config, uuid.New(), &testLogger)
+}
+
+func TestConnectionsDown(t *testing.T) {
+	server, bodies := receiver(t)
+	a := newTestAlerter(t, Config{ConnectionsDownAfter: 20 * time.Millisecond, Cooldown: time.Nanosecond, WebhookURLs: []string{server.URL}})
+
+	a.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected})
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected})
+	a.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Reconnecting})
+	assertNothingSent(t, bodies)
+
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	alert := next(t, bodies)
+	assert.Equal(t, string(ConnectionsDown), alert["condition"])
+	assert.Equal(t, string(Firing), alert["state"])
+	assert.Equal(t, a.connectorID.String(), alert["connectorId"])
+
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected})
+	alert = next(t, bodies)
+	assert.Equal(t, string(ConnectionsDown), alert["condition"])
+	assert.Equal(t, string(Resolved), alert["state"])
+
+	// A connection that unregisters because it was lost counts as down
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Unregistering})
+	alert = next(t, bodies)
+	assert.Equal(t, string(ConnectionsDown), alert["condition"])
+	assert.Equal(t, string(Firing), alert["state"])
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected})
+	assert.Equal(t, string(Resolved), next(t, bodies)["state"])
+
+	// A connection that comes back before the threshold doesn't fire, and neither does shutting down
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected})
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Unregistering, ShuttingDown: true})
+	a.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	assertNothingSent(t, bodies)
+}
+
+func TestRegistrationFailuresAndCooldown(t *testing.T) {
+	server, bodies := receiver(t)
+	a := newTestAlerter(t, Config{RegistrationFailures: 2, Cooldown: time.Hour, SlackWebhookURLs: []string{server.URL}})
+
+	registrationError := connection.Event{EventType: connection.RegistrationError, Err: errors.New("unauthorized")}
+	a.OnTunnelEvent(registrationError)
+	assertNothingSent(t, bodies)
+	a.OnTunnelEvent(registrationError)
+	assert.Contains(t, next(t, bodies)["text"], "registration_failures firing: 2 connections in a row failed to register, the last one with: unauthorized")
+	a.OnTunnelEvent(registrationError)
+	assertNothingSent(t, bodies)
+
+	a.OnTunnelEvent(connection.Event{EventType: connection.Connected})
+	assert.Contains(t, next(t, bodies)["text"], "registration_failures resolved")
+
+	// Firing again within the cooldown isn't notified, and so neither is its resolution
+	a.OnTunnelEvent(registrationError)
+	a.OnTunnelEvent(registrationError)
+	a.OnTunnelEvent(connection.Event{EventType: connection.Connected})
+	assertNothingSent(t, bodies)
+}
+
+func TestOriginErrorRate(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	responses := prometheus.NewCounterVec(prometheus.CounterOpts{Name: responseByCodeMetric}, []string{"status_code"})
+	requestErrors := prometheus.NewCounter(prometheus.CounterOpts{Name: requestErrorsMetric})
+	registry.MustRegister(responses, requestErrors)
+	responses.WithLabelValues("200").Add(1000)
+
+	server, bodies := receiver(t)
+	a := newTestAlerter(t, Config{
+		OriginErrorRate:     10,
+		PagerDutyRoutingKey: "routing-key",
+		PagerDutyURL:        server.URL,
+		EvaluationInterval:  time.Hour,
+		Gatherer:            registry,
+	})
+	// The requests made before the first evaluation are its baseline
+	require.Eventually(t, func() bool {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		return a.originMetricsBaseline
+	}, time.Second, time.Millisecond)
+
+	responses.WithLabelValues("200").Add(90)
+	responses.WithLabelValues("503").Add(5)
+	requestErrors.Add(5)
+	a.checkOriginErrors()
+	event := next(t, bodies)
+	assert.Equal(t, "routing-key", event["routing_key"])
+	assert.Equal(t, "trigger", event["event_action"])
+	dedupKey := event["dedup_key"]
+	payload := event["payload"].(map[string]any)
+	assert.Equal(t, "10.0% of the 100 origin requests of the last 1h0m0s failed", payload["summary"])
+	assert.Equal(t, "critical", payload["severity"])
+
+	// Too few requests to tell
+	responses.WithLabelValues("200").Add(10)
+	a.checkOriginErrors()
+	assertNothingSent(t, bodies)
+
+	responses.WithLabelValues("200").Add(99)
+	responses.WithLabelValues("500").Add(1)
+	a.checkOriginErrors()
+	event = next(t, bodies)
+	assert.Equal(t, "resolve", event["event_action"])
+	assert.Equal(t, dedupKey, event["dedup_key"])
+	assert.Nil(t, event["payload"])
+}
+
+func TestConfigEnabled(t *testing.T) {
+	assert.False(t, (&Config{ConnectionsDownAfter: time.Minute}).Enabled())
+	assert.False(t, (&Config{WebhookURLs: []string{"https://example.com"}}).Enabled())
+	assert.True(t, (&Config{ConnectionsDownAfter: time.Minute, WebhookURLs: []string{"https://example.com"}}).Enabled())
+}
@@ -0,0 +1,144 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cloudflare/cloudflared/webhook"
+)
+
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+	maxRetries = 3
+)
+
+// channel delivers alerts to a notification service.
+type channel interface {
+	name() string
+	send(ctx context.Context, alert Alert) error
+}
+
+func newChannels(config Config, connectorID uuid.UUID) []channel {
+	sender := webhook.NewSender(nil, "", maxRetries, 0)
+	var channels []channel
+	for _, url := range config.WebhookURLs {
+		channels = append(channels, &webhookChannel{sender: sender, url: url})
+	}
+	for _, url := range config.SlackWebhookURLs {
+		channels = append(channels, &slackChannel{sender: sender, url: url})
+	}
+	if config.PagerDutyRoutingKey != "" {
+		url := config.PagerDutyURL
+		if url == "" {
+			url = defaultPagerDutyURL
+		}
+		channels = append(channels, &pagerDutyChannel{
+			sender:      sender,
+			url:         url,
+			routingKey:  config.PagerDutyRoutingKey,
+			connectorID: connectorID,
+		})
+	}
+	return channels
+}
+
+// webhookChannel POSTs the JSON Alert.
+type webhookChannel struct {
+	sender *webhook.Sender
+	url    string
+}
+
+func (c *webhookChannel) name() string {
+	return "webhook"
+}
+
+func (c *webhookChannel) send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, c.sender, c.url, alert)
+}
+
+// slackChannel posts a message to a Slack incoming webhook.
+type slackChannel struct {
+	sender *webhook.Sender
+	url    string
+}
+
+func (c *slackChannel) name() string {
+	return "slack"
+}
+
+func (c *slackChannel) send(ctx context.Context, alert Alert) error {
+	icon := ":rotating_light:"
+	if alert.State == Resolved {
+		icon = ":white_check_mark:"
+	}
+	text := fmt.Sprintf("%s cloudflared connector %s: %s %s: %s", icon, alert.ConnectorID, alert.Condition, alert.State, alert.Message)
+	return postJSON(ctx, c.sender, c.url, map[string]string{"text": text})
+}
+
+// pagerDutyChannel triggers and resolves PagerDuty incidents with the Events API v2. Incidents are deduplicated per
+// connector and condition, so a resolution closes the incident its alert opened.
+type pagerDutyChannel struct {
+	sender      *webhook.Sender
+	url         string
+	routingKey  string
+	connectorID uuid.UUID
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary   string `json:"summary"`
+	Source    string `json:"source"`
+	Severity  string `json:"severity"`
+	Timestamp string `json:"timestamp"`
+	Component string `json:"component"`
+	Class     string `json:"class"`
+}
+
+func (c *pagerDutyChannel) name() string {
+	return "pagerduty"
+}
+
+func (c *pagerDutyChannel) send(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  c.routingKey,
+		EventAction: "resolve",
+		DedupKey:    fmt.Sprintf("cloudflared-%s-%s", c.connectorID, alert.Condition),
+	}
+	if alert.State == Firing {
+		source, _ := os.Hostname()
+		if source == "" {
+			source = c.connectorID.String()
+		}
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:   alert.Message,
+			Source:    source,
+			Severity:  "critical",
+			Timestamp: alert.Time.Format(time.RFC3339),
+			Component: "cloudflared",
+			Class:     string(alert.Condition),
+		}
+	}
+	return postJSON(ctx, c.sender, c.url, event)
+}
+
+// postJSON sends body as JSON to url, retrying with backoff while the failure may be temporary.
+func postJSON(ctx context.Context, sender *webhook.Sender, url string, body any) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, url, content)
+}
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -20,17 +21,25 @@ import (
 )
 
 const (
-	LogFieldOriginURL       = "originURL"
-	CFAccessTokenHeader     = "Cf-Access-Token"
-	cfJumpDestinationHeader = "Cf-Access-Jump-Destination"
+	LogFieldOriginURL          = "originURL"
+	CFAccessTokenHeader        = "Cf-Access-Token"
+	CFAccessClientIDHeader     = "Cf-Access-Client-Id"
+	CFAccessClientSecretHeader = "Cf-Access-Client-Secret"
+	cfJumpDestinationHeader    = "Cf-Access-Jump-Destination"
 )
 
+// ErrServiceTokenRejected is returned instead of going through the browser login flow when Access redirected a
+// request authenticated with a service token, since service tokens are meant for machines without a browser.
+var ErrServiceTokenRejected = errors.New("Access rejected the service token, check its ID and secret, and that the application has a Service Auth policy that allows it")
+
 type StartOptions struct {
 	AppInfo         *token.AppInfo
 	OriginURL       string
 	Headers         http.Header
 	Host            string
 	TLSClientConfig *tls.Config
+	// Compression offers the permessage-deflate extension to the edge
+	Compression bool
 }
 
 // Connection wraps up all the needed functions to forward over the tunnel
@@ -161,6 +170,49 @@ func BuildAccessRequest(options *StartOptions, log *zerolog.Logger) (*http.Reque
 	return originRequest, nil
 }
 
+// FetchAccessToken fetches the Access token for the origin of options up front when Access protects it, so that the
+// connections of clients with a short timeout, like RDP clients, don't wait for a browser login. Origins that aren't
+// protected by Access, and requests authenticated with a service token, don't need a token.
+func FetchAccessToken(options *StartOptions, log *zerolog.Logger) error {
+	if options.Headers.Get(CFAccessClientIDHeader) != "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodGet, options.OriginURL, nil)
+	if err != nil {
+		return err
+	}
+	if options.Host != "" {
+		req.Host = options.Host
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: options.TLSClientConfig,
+		},
+		// Access redirects to its login page
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to reach the origin")
+	}
+	resp.Body.Close()
+	if !IsAccessResponse(resp) {
+		return nil
+	}
+
+	appInfo, err := token.GetAppInfo(req.URL)
+	if err != nil {
+		return err
+	}
+	options.AppInfo = appInfo
+	_, err = token.FetchTokenWithRedirect(req.URL, appInfo, log)
+	return err
+}
+
 func SetBastionDest(header http.Header, destination string) {
 	if destination != "" {
 		header.Set(cfJumpDestinationHeader, destination)
@@ -0,0 +1,169 @@
+package carrier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/stream"
+)
+
+// An RDP connection starts with an X.224 Connection Request in a TPKT packet, see
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-rdpbcgr/18a27ef9-6f9a-4501-b000-94b1fe3c2c10
+const (
+	tpktVersion           = 3
+	tpktHeaderLen         = 4
+	x224ConnectionRequest = 0xe0
+	x224DisconnectRequest = 0x80
+	rdpNegRequest         = 0x01
+	rdpNegRequestLen      = 8
+
+	// Security protocols of the RDP Negotiation Request
+	rdpProtocolSSL      = 0x01
+	rdpProtocolHybrid   = 0x02
+	rdpProtocolRDSTLS   = 0x04
+	rdpProtocolHybridEx = 0x08
+
+	// rdpConnectionRequestTimeout is how long an RDP client has to send its Connection Request once connected
+	rdpConnectionRequestTimeout = 10 * time.Second
+)
+
+// x224Disconnect is the X.224 Disconnect Request sent to RDP clients whose connection can't be carried to the origin,
+// so that they give up right away rather than wait for their timeout.
+var x224Disconnect = []byte{tpktVersion, 0, 0, 11, 6, x224DisconnectRequest, 0, 0, 0, 0, 0}
+
+// RDPGateway carries RDP connections to the origin like Websocket does, but first reads the X.224 Connection Request of
+// the client, so that other clients are turned away before they go through Access, and RDP clients are told when the
+// connection can't be carried. The rest of the connection, including TLS and NLA (CredSSP), goes between the client
+// and the RDP server as it is.
+type RDPGateway struct {
+	log *zerolog.Logger
+}
+
+// NewRDPGatewayConnection returns a new connection object for RDP clients
+func NewRDPGatewayConnection(log *zerolog.Logger) Connection {
+	return &RDPGateway{
+		log: log,
+	}
+}
+
+// ServeStream reads the Connection Request of the RDP client from conn, and sends it and the rest of conn to the origin
+// over a websocket.
+func (g *RDPGateway) ServeStream(options *StartOptions, conn io.ReadWriter) error {
+	netConn, isNetConn := conn.(net.Conn)
+	if isNetConn {
+		_ = netConn.SetReadDeadline(time.Now().Add(rdpConnectionRequestTimeout))
+	}
+	req, err := readRDPConnectionRequest(conn)
+	if err != nil {
+		g.log.Debug().Err(err).Msg("Rejected a connection that isn't from an RDP client")
+		return err
+	}
+	if isNetConn {
+		_ = netConn.SetReadDeadline(time.Time{})
+	}
+	g.log.Debug().
+		Str("rdpUser", req.user).
+		Str("rdpProtocols", rdpProtocolNames(req.requestedProtocols)).
+		Msg("RDP client connected")
+
+	wsConn, err := createWebsocketStream(options, g.log)
+	if err != nil {
+		g.log.Err(err).Str(LogFieldOriginURL, options.OriginURL).Msg("failed to connect to origin")
+		_, _ = conn.Write(x224Disconnect)
+		return err
+	}
+	defer wsConn.Close()
+
+	if _, err := wsConn.Write(req.raw); err != nil {
+		return errors.Wrap(err, "failed to send the RDP connection request to the origin")
+	}
+	stream.Pipe(wsConn, conn, g.log)
+	return nil
+}
+
+// rdpConnectionRequest is the X.224 Connection Request that starts an RDP connection.
+type rdpConnectionRequest struct {
+	// raw is the whole TPKT packet of the request
+	raw []byte
+	// user is the user name of the mstshash cookie that mstsc sends, if any
+	user string
+	// requestedProtocols are the security protocols of the RDP Negotiation Request, if any
+	requestedProtocols uint32
+}
+
+func readRDPConnectionRequest(r io.Reader) (*rdpConnectionRequest, error) {
+	header := make([]byte, tpktHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != tpktVersion {
+		return nil, fmt.Errorf("unsupported TPKT version %d", header[0])
+	}
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	// The TPKT header is followed by at least the 7 bytes of the X.224 header
+	if length < tpktHeaderLen+7 {
+		return nil, fmt.Errorf("TPKT packet of %d bytes is too short", length)
+	}
+	raw := make([]byte, length)
+	copy(raw, header)
+	if _, err := io.ReadFull(r, raw[tpktHeaderLen:]); err != nil {
+		return nil, err
+	}
+	return parseRDPConnectionRequest(raw)
+}
+
+func parseRDPConnectionRequest(raw []byte) (*rdpConnectionRequest, error) {
+	x224 := raw[tpktHeaderLen:]
+	// The length indicator doesn't count itself
+	if int(x224[0]) != len(x224)-1 {
+		return nil, fmt.Errorf("X.224 length indicator %d doesn't match the TPKT length", x224[0])
+	}
+	if x224[1]&0xf0 != x224ConnectionRequest {
+		return nil, fmt.Errorf("expected an X.224 Connection Request, got code %#x", x224[1])
+	}
+
+	req := &rdpConnectionRequest{raw: raw}
+	data := x224[7:]
+	// The request may start with a cookie or a routing token, that ends with CR LF
+	if end := bytes.Index(data, []byte("\r\n")); end >= 0 && bytes.HasPrefix(data, []byte("Cookie:")) {
+		cookie := strings.TrimSpace(strings.TrimPrefix(string(data[:end]), "Cookie:"))
+		if strings.HasPrefix(cookie, "mstshash=") {
+			req.user = strings.TrimPrefix(cookie, "mstshash=")
+		}
+		data = data[end+2:]
+	}
+	if len(data) >= rdpNegRequestLen && data[0] == rdpNegRequest {
+		req.requestedProtocols = binary.LittleEndian.Uint32(data[4:8])
+	}
+	return req, nil
+}
+
+// rdpProtocolNames describes the security protocols an RDP client requested
+func rdpProtocolNames(protocols uint32) string {
+	if protocols == 0 {
+		return "rdp"
+	}
+	var names []string
+	for _, p := range []struct {
+		flag uint32
+		name string
+	}{
+		{rdpProtocolSSL, "tls"},
+		{rdpProtocolHybrid, "nla"},
+		{rdpProtocolRDSTLS, "rdstls"},
+		{rdpProtocolHybridEx, "nla-ex"},
+	} {
+		if protocols&p.flag != 0 {
+			names = append(names, p.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
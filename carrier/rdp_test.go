@@ -0,0 +1,105 @@
+package carrier
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gws "github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRDPConnectionRequest builds the Connection Request that mstsc sends
+func newRDPConnectionRequest(cookie string, protocols uint32) []byte {
+	data := []byte(cookie)
+	neg := make([]byte, rdpNegRequestLen)
+	neg[0] = rdpNegRequest
+	binary.LittleEndian.PutUint16(neg[2:], rdpNegRequestLen)
+	binary.LittleEndian.PutUint32(neg[4:], protocols)
+	data = append(data, neg...)
+
+	x224 := append([]byte{byte(6 + len(data)), x224ConnectionRequest, 0, 0, 0, 0, 0}, data...)
+	packet := []byte{tpktVersion, 0, 0, 0}
+	binary.BigEndian.PutUint16(packet[2:], uint16(tpktHeaderLen+len(x224)))
+	return append(packet, x224...)
+}
+
+func TestParseRDPConnectionRequest(t *testing.T) {
+	raw := newRDPConnectionRequest("Cookie: mstshash=dalton\r\n", rdpProtocolSSL|rdpProtocolHybrid)
+	req, err := readRDPConnectionRequest(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, raw, req.raw)
+	assert.Equal(t, "dalton", req.user)
+	assert.Equal(t, "tls,nla", rdpProtocolNames(req.requestedProtocols))
+
+	req, err = readRDPConnectionRequest(bytes.NewReader(newRDPConnectionRequest("", 0)))
+	require.NoError(t, err)
+	assert.Empty(t, req.user)
+	assert.Equal(t, "rdp", rdpProtocolNames(req.requestedProtocols))
+
+	_, err = readRDPConnectionRequest(bytes.NewReader([]byte("SSH-2.0-OpenSSH_9.0\r\n")))
+	assert.Error(t, err)
+}
+
+func TestRDPGateway(t *testing.T) {
+	// The origin echoes what it gets over the websocket
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&gws.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	log := zerolog.Nop()
+	client, gateway := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer gateway.Close()
+		_ = NewRDPGatewayConnection(&log).ServeStream(&StartOptions{OriginURL: server.URL}, gateway)
+	}()
+
+	raw := newRDPConnectionRequest("Cookie: mstshash=dalton\r\n", rdpProtocolHybrid)
+	_, err := client.Write(raw)
+	require.NoError(t, err)
+	echo := make([]byte, len(raw))
+	_, err = io.ReadFull(client, echo)
+	require.NoError(t, err)
+	assert.Equal(t, raw, echo)
+}
+
+func TestRDPGatewayOriginUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	log := zerolog.Nop()
+	client, gateway := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer gateway.Close()
+		_ = NewRDPGatewayConnection(&log).ServeStream(&StartOptions{OriginURL: server.URL}, gateway)
+	}()
+
+	_, err := client.Write(newRDPConnectionRequest("", rdpProtocolSSL))
+	require.NoError(t, err)
+	// The client is told to disconnect rather than left waiting
+	reply, err := io.ReadAll(client)
+	require.NoError(t, err)
+	assert.Equal(t, x224Disconnect, reply)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 
+	"github.com/cloudflare/cloudflared/socks"
 	"github.com/cloudflare/cloudflared/stream"
 	"github.com/cloudflare/cloudflared/token"
 	cfwebsocket "github.com/cloudflare/cloudflared/websocket"
@@ -28,9 +29,22 @@ func NewWSConnection(log *zerolog.Logger) Connection {
 	}
 }
 
+// NewSocksWSConnection returns a new connection object that serves SOCKS5 requests from conn, and sends each of them
+// to the SOCKS5 proxy of the origin over its own websocket connection. The UDP datagrams of UDP ASSOCIATE requests are
+// relayed over the websocket connection too.
+func NewSocksWSConnection(log *zerolog.Logger) Connection {
+	return &Websocket{
+		log:     log,
+		isSocks: true,
+	}
+}
+
 // ServeStream will create a Websocket client stream connection to the edge
 // it blocks and writes the raw data from conn over the tunnel
 func (ws *Websocket) ServeStream(options *StartOptions, conn io.ReadWriter) error {
+	if ws.isSocks {
+		return ws.serveSocks(options, conn)
+	}
 	wsConn, err := createWebsocketStream(options, ws.log)
 	if err != nil {
 		ws.log.Err(err).Str(LogFieldOriginURL, options.OriginURL).Msg("failed to connect to origin")
@@ -42,6 +56,23 @@ func (ws *Websocket) ServeStream(options *StartOptions, conn io.ReadWriter) erro
 	return nil
 }
 
+func (ws *Websocket) serveSocks(options *StartOptions, conn io.ReadWriter) error {
+	dialer := func() (io.ReadWriteCloser, error) {
+		wsConn, err := createWebsocketStream(options, ws.log)
+		if err != nil {
+			ws.log.Err(err).Str(LogFieldOriginURL, options.OriginURL).Msg("failed to connect to origin")
+			return nil, err
+		}
+		return wsConn, nil
+	}
+	socksServer := socks.NewConnectionHandler(socks.NewUpstreamRequestHandler(dialer))
+	if err := socksServer.Serve(conn); err != nil {
+		ws.log.Debug().Err(err).Msg("Socks stream handler error")
+		return err
+	}
+	return nil
+}
+
 // createWebsocketStream will create a WebSocket connection to stream data over
 // It also handles redirects from Access and will present that flow if
 // the token is not present on the request
@@ -62,13 +93,18 @@ func createWebsocketStream(options *StartOptions, log *zerolog.Logger) (*cfwebso
 	log.Debug().Msgf("Websocket request: %s", string(dump))
 
 	dialer := &websocket.Dialer{
-		TLSClientConfig: options.TLSClientConfig,
-		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig:   options.TLSClientConfig,
+		Proxy:             http.ProxyFromEnvironment,
+		EnableCompression: options.Compression,
 	}
 	wsConn, resp, err := clientConnect(req, dialer)
 	defer closeRespBody(resp)
 
 	if err != nil && IsAccessResponse(resp) {
+		if options.Headers.Get(CFAccessClientIDHeader) != "" {
+			return nil, ErrServiceTokenRejected
+		}
+
 		// Only get Access app info if we know the origin is protected by Access
 		originReq, err := http.NewRequest(http.MethodGet, options.OriginURL, nil)
 		if err != nil {
@@ -101,7 +137,7 @@ var stripWebsocketHeaders = []string{
 }
 
 // the gorilla websocket library sets its own Upgrade, Connection, Sec-WebSocket-Key,
-// Sec-WebSocket-Version and Sec-Websocket-Extensions headers.
+// Sec-WebSocket-Version and Sec-Websocket-Extensions headers, the last one when the dialer enables compression.
 // https://github.com/gorilla/websocket/blob/master/client.go#L189-L194.
 func websocketHeaders(req *http.Request) http.Header {
 	wsHeaders := make(http.Header)
@@ -191,7 +227,10 @@ func createAccessWebSocketStream(options *StartOptions, log *zerolog.Logger) (*w
 	}
 	log.Debug().Msgf("Access Websocket request: %s", string(dump))
 
-	conn, resp, err := clientConnect(req, nil)
+	conn, resp, err := clientConnect(req, &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		EnableCompression: options.Compression,
+	})
 
 	if resp != nil {
 		r, err := httputil.DumpResponse(resp, true)
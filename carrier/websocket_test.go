@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 
 	"github.com/cloudflare/cloudflared/hello"
 	"github.com/cloudflare/cloudflared/tlsconfig"
+	"github.com/cloudflare/cloudflared/token"
 	cfwebsocket "github.com/cloudflare/cloudflared/websocket"
 )
 
@@ -121,3 +124,18 @@ func TestWebsocketWrapper(t *testing.T) {
 	require.Equal(t, n, 2)
 	require.Equal(t, "bc", string(buf[:n]))
 }
+
+func TestServiceTokenRejected(t *testing.T) {
+	// Access redirects requests it doesn't authorize to its login page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.cloudflareaccess.com"+token.AccessLoginWorkerPath, http.StatusFound)
+	}))
+	defer server.Close()
+
+	headers := make(http.Header)
+	headers.Set(CFAccessClientIDHeader, "id")
+	headers.Set(CFAccessClientSecretHeader, "secret")
+	log := zerolog.Nop()
+	_, err := createWebsocketStream(&StartOptions{OriginURL: server.URL, Headers: headers}, &log)
+	assert.ErrorIs(t, err, ErrServiceTokenRejected)
+}
@@ -36,10 +36,11 @@ type RESTClient struct {
 }
 
 type baseEndpoints struct {
-	accountLevel  url.URL
-	zoneLevel     url.URL
-	accountRoutes url.URL
-	accountVnets  url.URL
+	accountLevel   url.URL
+	zoneLevel      url.URL
+	zoneDNSRecords url.URL
+	accountRoutes  url.URL
+	accountVnets   url.URL
 }
 
 var _ Client = (*RESTClient)(nil)
@@ -64,6 +65,10 @@ func NewRESTClient(baseURL, accountTag, zoneTag, authToken, userAgent string, lo
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create account level endpoint")
 	}
+	zoneDNSRecordsEndpoint, err := url.Parse(fmt.Sprintf("%s/zones/%s/dns_records", baseURL, zoneTag))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create DNS records zone-level endpoint")
+	}
 	httpTransport := http.Transport{
 		TLSHandshakeTimeout:   defaultTimeout,
 		ResponseHeaderTimeout: defaultTimeout,
@@ -71,10 +76,11 @@ func NewRESTClient(baseURL, accountTag, zoneTag, authToken, userAgent string, lo
 	http2.ConfigureTransport(&httpTransport)
 	return &RESTClient{
 		baseEndpoints: &baseEndpoints{
-			accountLevel:  *accountLevelEndpoint,
-			zoneLevel:     *zoneLevelEndpoint,
-			accountRoutes: *accountRoutesEndpoint,
-			accountVnets:  *accountVnetsEndpoint,
+			accountLevel:   *accountLevelEndpoint,
+			zoneLevel:      *zoneLevelEndpoint,
+			zoneDNSRecords: *zoneDNSRecordsEndpoint,
+			accountRoutes:  *accountRoutesEndpoint,
+			accountVnets:   *accountVnetsEndpoint,
 		},
 		authToken: authToken,
 		userAgent: userAgent,
@@ -9,7 +9,9 @@ type TunnelClient interface {
 	GetTunnel(tunnelID uuid.UUID) (*Tunnel, error)
 	GetTunnelToken(tunnelID uuid.UUID) (string, error)
 	GetManagementToken(tunnelID uuid.UUID) (string, error)
+	GetTunnelConfiguration(tunnelID uuid.UUID) (*TunnelConfiguration, error)
 	DeleteTunnel(tunnelID uuid.UUID) error
+	RotateTunnelSecret(tunnelID uuid.UUID, tunnelSecret []byte) (*TunnelWithToken, error)
 	ListTunnels(filter *TunnelFilter) ([]*Tunnel, error)
 	ListActiveClients(tunnelID uuid.UUID) ([]*ActiveClient, error)
 	CleanupConnections(tunnelID uuid.UUID, params *CleanupParams) error
@@ -17,6 +19,8 @@ type TunnelClient interface {
 
 type HostnameClient interface {
 	RouteTunnel(tunnelID uuid.UUID, route HostnameRoute) (HostnameRouteResult, error)
+	ListDNSRoutes(tunnelID uuid.UUID) ([]*DNSRecord, error)
+	DeleteDNSRoute(hostname string) (*DNSRecord, error)
 }
 
 type IPRouteClient interface {
@@ -0,0 +1,138 @@
+package cfapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TunnelDomain is the domain of the hostnames that DNS routes to tunnels are CNAMEs to, <tunnel ID>.cfargotunnel.com
+	TunnelDomain = "cfargotunnel.com"
+
+	dnsRecordsPerPage = 100
+)
+
+// DNSRecord is a record of the DNS zone of the origin certificate.
+type DNSRecord struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Name       string    `json:"name"`
+	Content    string    `json:"content"`
+	Proxied    bool      `json:"proxied"`
+	CreatedOn  time.Time `json:"created_on"`
+	ModifiedOn time.Time `json:"modified_on"`
+}
+
+// TunnelID returns the ID of the tunnel that the record routes to, or false if it isn't a DNS route to a tunnel.
+func (r *DNSRecord) TunnelID() (uuid.UUID, bool) {
+	if r.Type != "CNAME" {
+		return uuid.Nil, false
+	}
+	content := strings.TrimSuffix(r.Content, ".")
+	if !strings.HasSuffix(content, "."+TunnelDomain) {
+		return uuid.Nil, false
+	}
+	tunnelID, err := uuid.Parse(strings.TrimSuffix(content, "."+TunnelDomain))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return tunnelID, true
+}
+
+// ListDNSRoutes returns the CNAME records of the zone that route to the tunnel, or to any tunnel, including deleted
+// ones, if tunnelID is uuid.Nil.
+func (r *RESTClient) ListDNSRoutes(tunnelID uuid.UUID) ([]*DNSRecord, error) {
+	query := url.Values{"type": {"CNAME"}}
+	if tunnelID != uuid.Nil {
+		query.Set("content", fmt.Sprintf("%s.%s", tunnelID, TunnelDomain))
+	}
+	records, err := r.listDNSRecords(query)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]*DNSRecord, 0, len(records))
+	for _, record := range records {
+		if id, ok := record.TunnelID(); ok && (tunnelID == uuid.Nil || id == tunnelID) {
+			routes = append(routes, record)
+		}
+	}
+	return routes, nil
+}
+
+// DeleteDNSRoute deletes the CNAME record of the hostname, and returns it. It refuses to delete a record that doesn't
+// route to a tunnel.
+func (r *RESTClient) DeleteDNSRoute(hostname string) (*DNSRecord, error) {
+	records, err := r.listDNSRecords(url.Values{"type": {"CNAME"}, "name": {hostname}})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.Wrapf(ErrNotFound, "no CNAME record of %s", hostname)
+	}
+	record := records[0]
+	if _, ok := record.TunnelID(); !ok {
+		return nil, fmt.Errorf("the CNAME record of %s points to %s, not to a tunnel", hostname, record.Content)
+	}
+
+	endpoint := r.baseEndpoints.zoneDNSRecords
+	endpoint.Path = path.Join(endpoint.Path, url.PathEscape(record.ID))
+	resp, err := r.sendRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "REST request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return record, nil
+	}
+
+	return nil, r.statusCodeToError("delete DNS route", resp)
+}
+
+// listDNSRecords returns every DNS record of the zone that matches the query, fetching them page by page.
+func (r *RESTClient) listDNSRecords(query url.Values) ([]*DNSRecord, error) {
+	var records []*DNSRecord
+	query.Set("per_page", strconv.Itoa(dnsRecordsPerPage))
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		endpoint := r.baseEndpoints.zoneDNSRecords
+		endpoint.RawQuery = query.Encode()
+		pageRecords, err := r.getDNSRecords(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, pageRecords...)
+		if len(pageRecords) < dnsRecordsPerPage {
+			return records, nil
+		}
+	}
+}
+
+func (r *RESTClient) getDNSRecords(endpoint url.URL) ([]*DNSRecord, error) {
+	resp, err := r.sendRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "REST request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return parseDNSRecords(resp.Body)
+	}
+
+	return nil, r.statusCodeToError("list DNS records", resp)
+}
+
+func parseDNSRecords(body io.Reader) ([]*DNSRecord, error) {
+	var records []*DNSRecord
+	err := parseResponse(body, &records)
+	return records, err
+}
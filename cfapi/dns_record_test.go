@@ -0,0 +1,87 @@
+package cfapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSRecordTunnelID(t *testing.T) {
+	tunnelID := uuid.New()
+	for _, test := range []struct {
+		record   DNSRecord
+		expected uuid.UUID
+	}{
+		{record: DNSRecord{Type: "CNAME", Content: tunnelID.String() + ".cfargotunnel.com"}, expected: tunnelID},
+		{record: DNSRecord{Type: "CNAME", Content: tunnelID.String() + ".cfargotunnel.com."}, expected: tunnelID},
+		{record: DNSRecord{Type: "CNAME", Content: "example.com"}},
+		{record: DNSRecord{Type: "CNAME", Content: "not-a-tunnel.cfargotunnel.com"}},
+		{record: DNSRecord{Type: "TXT", Content: tunnelID.String() + ".cfargotunnel.com"}},
+	} {
+		id, ok := test.record.TunnelID()
+		assert.Equal(t, test.expected, id, test.record.Content)
+		assert.Equal(t, test.expected != uuid.Nil, ok, test.record.Content)
+	}
+}
+
+func TestListAndDeleteDNSRoutes(t *testing.T) {
+	tunnelID := uuid.New()
+	// A full page, then a partial one
+	var records []DNSRecord
+	for i := 0; i < dnsRecordsPerPage+1; i++ {
+		records = append(records, DNSRecord{ID: strconv.Itoa(i), Type: "CNAME", Name: fmt.Sprintf("%d.example.com", i), Content: tunnelID.String() + ".cfargotunnel.com"})
+	}
+	records = append(records, DNSRecord{ID: "other", Type: "CNAME", Name: "www.example.com", Content: "example.net"})
+	var deleted []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result []DNSRecord
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones/zone/dns_records":
+			assert.Equal(t, "CNAME", r.URL.Query().Get("type"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			for i, record := range records {
+				if name := r.URL.Query().Get("name"); name != "" && name != record.Name {
+					continue
+				}
+				if name := r.URL.Query().Get("name"); name != "" || i/dnsRecordsPerPage == page-1 {
+					result = append(result, record)
+				}
+			}
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		resultJSON, err := json.Marshal(result)
+		assert.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(response{Success: true, Result: resultJSON})
+	}))
+	defer server.Close()
+	log := zerolog.Nop()
+	client, err := NewRESTClient(server.URL, "account", "zone", "token", "test", &log)
+	require.NoError(t, err)
+
+	routes, err := client.ListDNSRoutes(uuid.Nil)
+	require.NoError(t, err)
+	assert.Len(t, routes, dnsRecordsPerPage+1)
+
+	record, err := client.DeleteDNSRoute("3.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "3", record.ID)
+	assert.Equal(t, []string{"/zones/zone/dns_records/3"}, deleted)
+
+	_, err = client.DeleteDNSRoute("www.example.com")
+	assert.Error(t, err)
+	_, err = client.DeleteDNSRoute("missing.example.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Len(t, deleted, 1)
+}
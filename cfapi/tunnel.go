@@ -1,6 +1,7 @@
 package cfapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -21,6 +22,9 @@ type Tunnel struct {
 	CreatedAt   time.Time    `json:"created_at"`
 	DeletedAt   time.Time    `json:"deleted_at"`
 	Connections []Connection `json:"connections"`
+	// ConnsActiveAt is when the tunnel last went from no connections to some, ConnsInactiveAt when it last lost them
+	ConnsActiveAt   *time.Time `json:"conns_active_at,omitempty"`
+	ConnsInactiveAt *time.Time `json:"conns_inactive_at,omitempty"`
 }
 
 type TunnelWithToken struct {
@@ -37,12 +41,20 @@ type Connection struct {
 }
 
 type ActiveClient struct {
-	ID          uuid.UUID    `json:"id"`
-	Features    []string     `json:"features"`
-	Version     string       `json:"version"`
-	Arch        string       `json:"arch"`
-	RunAt       time.Time    `json:"run_at"`
-	Connections []Connection `json:"conns"`
+	ID                uuid.UUID    `json:"id"`
+	Features          []string     `json:"features"`
+	Version           string       `json:"version"`
+	Arch              string       `json:"arch"`
+	ConfigFingerprint string       `json:"config_fingerprint"`
+	RunAt             time.Time    `json:"run_at"`
+	Connections       []Connection `json:"conns"`
+}
+
+// TunnelConfiguration is the configuration stored for a remotely managed tunnel
+type TunnelConfiguration struct {
+	TunnelID uuid.UUID       `json:"tunnel_id"`
+	Version  int32           `json:"version"`
+	Config   json.RawMessage `json:"config"`
 }
 
 type newTunnel struct {
@@ -50,6 +62,10 @@ type newTunnel struct {
 	TunnelSecret []byte `json:"tunnel_secret"`
 }
 
+type rotatedSecret struct {
+	TunnelSecret []byte `json:"tunnel_secret"`
+}
+
 type managementRequest struct {
 	Resources []string `json:"resources"`
 }
@@ -159,6 +175,24 @@ func (r *RESTClient) GetManagementToken(tunnelID uuid.UUID) (token string, err e
 	return "", r.statusCodeToError("get tunnel token", resp)
 }
 
+func (r *RESTClient) GetTunnelConfiguration(tunnelID uuid.UUID) (*TunnelConfiguration, error) {
+	endpoint := r.baseEndpoints.accountLevel
+	endpoint.Path = path.Join(endpoint.Path, fmt.Sprintf("%v/configurations", tunnelID))
+	resp, err := r.sendRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "REST request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var configuration TunnelConfiguration
+		err = parseResponse(resp.Body, &configuration)
+		return &configuration, err
+	}
+
+	return nil, r.statusCodeToError("get tunnel configuration", resp)
+}
+
 func (r *RESTClient) DeleteTunnel(tunnelID uuid.UUID) error {
 	endpoint := r.baseEndpoints.accountLevel
 	endpoint.Path = path.Join(endpoint.Path, fmt.Sprintf("%v", tunnelID))
@@ -171,6 +205,28 @@ func (r *RESTClient) DeleteTunnel(tunnelID uuid.UUID) error {
 	return r.statusCodeToError("delete tunnel", resp)
 }
 
+// RotateTunnelSecret replaces the secret of a tunnel, keeping its ID and routes. Connectors need the new secret to
+// register connections from then on.
+func (r *RESTClient) RotateTunnelSecret(tunnelID uuid.UUID, tunnelSecret []byte) (*TunnelWithToken, error) {
+	endpoint := r.baseEndpoints.accountLevel
+	endpoint.Path = path.Join(endpoint.Path, fmt.Sprintf("%v", tunnelID))
+	resp, err := r.sendRequest("PATCH", endpoint, &rotatedSecret{TunnelSecret: tunnelSecret})
+	if err != nil {
+		return nil, errors.Wrap(err, "REST request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var tunnel TunnelWithToken
+		if err := parseResponse(resp.Body, &tunnel); err != nil {
+			return nil, err
+		}
+		return &tunnel, nil
+	}
+
+	return nil, r.statusCodeToError("rotate tunnel secret", resp)
+}
+
 func (r *RESTClient) ListTunnels(filter *TunnelFilter) ([]*Tunnel, error) {
 	endpoint := r.baseEndpoints.accountLevel
 	endpoint.RawQuery = filter.encode()
@@ -50,6 +50,11 @@ func (f *TunnelFilter) MaxFetchSize(max uint) {
 	f.queryParams.Set("per_page", strconv.Itoa(int(max)))
 }
 
+// Page only returns the given page of the tunnels, starting at 1, when they're split in pages of MaxFetchSize tunnels.
+func (f *TunnelFilter) Page(page uint) {
+	f.queryParams.Set("page", strconv.Itoa(int(page)))
+}
+
 func (f TunnelFilter) encode() string {
 	return f.queryParams.Encode()
 }
@@ -63,6 +63,7 @@ func Test_parseListTunnels(t *testing.T) {
 }
 
 func Test_unmarshalTunnel(t *testing.T) {
+	inactiveAt := time.Date(2021, 07, 29, 13, 47, 22, 548482000, loc)
 	type args struct {
 		body string
 	}
@@ -81,6 +82,8 @@ func Test_unmarshalTunnel(t *testing.T) {
 				CreatedAt:   time.Date(2021, 07, 29, 13, 46, 14, 90955000, loc),
 				DeletedAt:   time.Date(2021, 07, 29, 14, 7, 27, 559047000, loc),
 				Connections: nil,
+				// The tunnel was connected once
+				ConnsInactiveAt: &inactiveAt,
 			},
 		},
 	}
@@ -147,3 +150,11 @@ func TestUnmarshalConnections(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []*ActiveClient{&expected}, actual)
 }
+
+func TestTunnelFilterPage(t *testing.T) {
+	filter := NewTunnelFilter()
+	filter.NoDeleted()
+	filter.Page(3)
+	filter.MaxFetchSize(50)
+	assert.Equal(t, "is_deleted=false&page=3&per_page=50", filter.encode())
+}
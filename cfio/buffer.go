@@ -0,0 +1,244 @@
+package cfio
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DatagramBufferSize fits a packet of the usual internet MTU.
+	DatagramBufferSize = 2 * 1024
+	// StreamBufferSize is used to copy streams.
+	StreamBufferSize = defaultBufferSize
+	// LargeBufferSize is used for bursts of stream data, like h2mux write chunks.
+	LargeBufferSize = 64 * 1024
+
+	// budgetWaitTimeout bounds how long callers without a context wait for buffers to be released.
+	budgetWaitTimeout = 10 * time.Second
+)
+
+// ErrMemoryBudgetExhausted is returned when a buffer can't be handed out without exceeding the memory budget.
+var ErrMemoryBudgetExhausted = errors.New("memory budget for buffers is exhausted")
+
+var (
+	buffersInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "buffers",
+		Name:      "bytes_in_use",
+		Help:      "Bytes of buffers currently handed out to proxy data",
+	})
+	buffersBudget = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "buffers",
+		Name:      "budget_bytes",
+		Help:      "Maximum bytes of buffers that can be handed out at once, 0 if unlimited",
+	})
+	buffersAllocated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cloudflared",
+		Subsystem: "buffers",
+		Name:      "allocations_total",
+		Help:      "Count of buffers allocated because none could be reused, by size class",
+	}, []string{"size_class"})
+	buffersWaits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cloudflared",
+		Subsystem: "buffers",
+		Name:      "budget_waits_total",
+		Help:      "Count of times a buffer had to wait for others to be released to stay within the memory budget",
+	})
+	buffersRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "cloudflared",
+		Subsystem: "buffers",
+		Name:      "budget_rejections_total",
+		Help:      "Count of buffers that weren't handed out because the memory budget stayed exhausted",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(buffersInUse, buffersBudget, buffersAllocated, buffersWaits, buffersRejected)
+}
+
+var defaultManager = NewBufferManager(0, DatagramBufferSize, StreamBufferSize, LargeBufferSize)
+
+// SetMemoryBudget limits the bytes of buffers handed out at once by GetBuffer. 0 removes the limit.
+func SetMemoryBudget(bytes int64) {
+	defaultManager.SetBudget(bytes)
+}
+
+// GetBuffer returns a buffer of length size from the shared buffer manager, waiting until ctx is done for the memory
+// budget. It must be returned with PutBuffer.
+func GetBuffer(ctx context.Context, size int) (*[]byte, error) {
+	return defaultManager.Get(ctx, size)
+}
+
+// TryGetBuffer is like GetBuffer, but fails right away if the memory budget is exhausted.
+func TryGetBuffer(size int) (*[]byte, error) {
+	return defaultManager.TryGet(size)
+}
+
+// PutBuffer returns a buffer obtained from GetBuffer so it can be reused.
+func PutBuffer(buf *[]byte) {
+	defaultManager.Put(buf)
+}
+
+type sizeClass struct {
+	size  int
+	label string
+	pool  sync.Pool
+}
+
+// BufferManager hands out buffers from pools of fixed sizes, so memory is reused across connections, and makes
+// callers wait once the buffers in use reach the memory budget. Waiting applies backpressure to the connections
+// instead of letting bursts of traffic grow memory until cloudflared is killed. Callers never wait longer than their
+// context allows, so a budget that stays exhausted fails them rather than stall them.
+type BufferManager struct {
+	classes []*sizeClass
+
+	lock   sync.Mutex
+	budget int64
+	inUse  int64
+	// released is closed, and replaced, whenever buffers are released or the budget changes while callers wait
+	released chan struct{}
+	waiting  int
+}
+
+// NewBufferManager creates a BufferManager with a pool for each of the given sizes, and the given budget in bytes. A
+// budget of 0 is unlimited.
+func NewBufferManager(budget int64, sizes ...int) *BufferManager {
+	sort.Ints(sizes)
+	m := &BufferManager{budget: budget, released: make(chan struct{})}
+	for _, size := range sizes {
+		class := &sizeClass{size: size, label: formatSize(size)}
+		class.pool.New = func() interface{} {
+			buffersAllocated.WithLabelValues(class.label).Inc()
+			buf := make([]byte, class.size)
+			return &buf
+		}
+		m.classes = append(m.classes, class)
+	}
+	return m
+}
+
+// SetBudget changes the memory budget. Buffers already handed out are kept even if they exceed the new budget.
+func (m *BufferManager) SetBudget(budget int64) {
+	m.lock.Lock()
+	m.budget = budget
+	m.notifyReleased()
+	m.lock.Unlock()
+	buffersBudget.Set(float64(budget))
+}
+
+// Get returns a buffer of length size, waiting until enough buffers are released to stay within the budget, or failing
+// with ErrMemoryBudgetExhausted once ctx is done. A buffer is always handed out when none are in use, so requests
+// larger than the budget don't fail. The buffer is returned as the pointer that's pooled, so that putting it back
+// doesn't allocate.
+func (m *BufferManager) Get(ctx context.Context, size int) (*[]byte, error) {
+	class := m.classFor(size)
+	capacity := size
+	if class != nil {
+		capacity = class.size
+	}
+	if err := m.reserve(ctx, int64(capacity)); err != nil {
+		return nil, err
+	}
+	if class == nil {
+		buffersAllocated.WithLabelValues("oversized").Inc()
+		buf := make([]byte, size)
+		return &buf, nil
+	}
+	buf := class.pool.Get().(*[]byte)
+	*buf = (*buf)[:size]
+	return buf, nil
+}
+
+// TryGet is like Get, but fails right away if the buffer doesn't fit in the budget. It's meant for callers that can't
+// wait, like those holding a lock other goroutines need to release their buffers.
+func (m *BufferManager) TryGet(size int) (*[]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return m.Get(ctx, size)
+}
+
+// Put releases a buffer obtained from Get. The buffer may have been resliced, but not replaced.
+func (m *BufferManager) Put(buf *[]byte) {
+	capacity := cap(*buf)
+	if class := m.classFor(capacity); class != nil && class.size == capacity {
+		*buf = (*buf)[:capacity]
+		class.pool.Put(buf)
+	}
+	m.release(int64(capacity))
+}
+
+// InUse returns how many bytes of buffers are currently handed out.
+func (m *BufferManager) InUse() int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.inUse
+}
+
+func (m *BufferManager) classFor(size int) *sizeClass {
+	for _, class := range m.classes {
+		if size <= class.size {
+			return class
+		}
+	}
+	return nil
+}
+
+func (m *BufferManager) reserve(ctx context.Context, bytes int64) error {
+	m.lock.Lock()
+	waited := false
+	for m.budget > 0 && m.inUse > 0 && m.inUse+bytes > m.budget {
+		released := m.released
+		m.waiting++
+		m.lock.Unlock()
+		if !waited {
+			buffersWaits.Inc()
+			waited = true
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			m.lock.Lock()
+			m.waiting--
+			m.lock.Unlock()
+			buffersRejected.Inc()
+			return ErrMemoryBudgetExhausted
+		}
+		m.lock.Lock()
+		m.waiting--
+	}
+	m.inUse += bytes
+	m.lock.Unlock()
+	buffersInUse.Add(float64(bytes))
+	return nil
+}
+
+func (m *BufferManager) release(bytes int64) {
+	m.lock.Lock()
+	m.inUse -= bytes
+	m.notifyReleased()
+	m.lock.Unlock()
+	buffersInUse.Sub(float64(bytes))
+}
+
+// notifyReleased wakes up the callers waiting for the budget. It must be called with lock held.
+func (m *BufferManager) notifyReleased() {
+	if m.waiting == 0 {
+		return
+	}
+	close(m.released)
+	m.released = make(chan struct{})
+}
+
+func formatSize(size int) string {
+	if size%1024 == 0 {
+		return strconv.Itoa(size/1024) + "KiB"
+	}
+	return strconv.Itoa(size) + "B"
+}
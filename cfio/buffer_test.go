@@ -0,0 +1,106 @@
+package cfio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustGet(t *testing.T, m *BufferManager, size int) *[]byte {
+	buf, err := m.Get(context.Background(), size)
+	require.NoError(t, err)
+	return buf
+}
+
+func TestBufferManagerSizeClasses(t *testing.T) {
+	m := NewBufferManager(0, 1024, 4096)
+
+	buf := mustGet(t, m, 100)
+	assert.Len(t, *buf, 100)
+	assert.Equal(t, 1024, cap(*buf))
+	assert.Equal(t, int64(1024), m.InUse())
+
+	large := mustGet(t, m, 2000)
+	assert.Equal(t, 4096, cap(*large))
+
+	oversized := mustGet(t, m, 5000)
+	assert.Len(t, *oversized, 5000)
+	assert.Equal(t, int64(1024+4096+5000), m.InUse())
+
+	m.Put(buf)
+	m.Put(large)
+	m.Put(oversized)
+	assert.Equal(t, int64(0), m.InUse())
+}
+
+func TestBufferManagerBudget(t *testing.T) {
+	m := NewBufferManager(2048, 1024)
+	first := mustGet(t, m, 1024)
+	second := mustGet(t, m, 1024)
+
+	_, err := m.TryGet(1024)
+	assert.ErrorIs(t, err, ErrMemoryBudgetExhausted)
+
+	got := make(chan *[]byte)
+	go func() {
+		buf, _ := m.Get(context.Background(), 1024)
+		got <- buf
+	}()
+	select {
+	case <-got:
+		t.Fatal("buffer handed out beyond the budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.Put(first)
+	select {
+	case third := <-got:
+		assert.Len(t, *third, 1024)
+		m.Put(third)
+	case <-time.After(time.Second):
+		t.Fatal("buffer wasn't handed out after one was released")
+	}
+	m.Put(second)
+
+	// A buffer larger than the budget is handed out when no others are in use
+	require.Equal(t, int64(0), m.InUse())
+	oversized := mustGet(t, m, 4096)
+	assert.Len(t, *oversized, 4096)
+	m.Put(oversized)
+}
+
+func TestBufferManagerBudgetExhausted(t *testing.T) {
+	m := NewBufferManager(1024, 1024)
+	buf := mustGet(t, m, 1024)
+	defer m.Put(buf)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := m.Get(ctx, 1024)
+	assert.ErrorIs(t, err, ErrMemoryBudgetExhausted)
+	assert.Equal(t, int64(1024), m.InUse())
+}
+
+func TestBufferManagerReusesBuffers(t *testing.T) {
+	m := NewBufferManager(0, 1024)
+	buf := mustGet(t, m, 1024)
+	m.Put(buf)
+
+	// Putting a buffer back in its pool doesn't allocate
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ := m.Get(context.Background(), 100)
+		m.Put(buf)
+	})
+	assert.Zero(t, allocs)
+
+	// A resliced buffer gets its full length back
+	buf = mustGet(t, m, 100)
+	*buf = (*buf)[:10]
+	m.Put(buf)
+	buf = mustGet(t, m, 1024)
+	assert.Len(t, *buf, 1024)
+	m.Put(buf)
+}
@@ -1,27 +1,52 @@
 package cfio
 
 import (
+	"context"
 	"io"
-	"sync"
+	"net"
+	"os"
 )
 
 const defaultBufferSize = 16 * 1024
 
-var bufferPool = sync.Pool{
-	New: func() interface{} {
-		return make([]byte, defaultBufferSize)
-	},
-}
-
+// Copy copies src to dst with a buffer of the shared buffer manager. It fails with ErrMemoryBudgetExhausted if the
+// memory budget stays exhausted for too long.
 func Copy(dst io.Writer, src io.Reader) (written int64, err error) {
 	_, okWriteTo := src.(io.WriterTo)
 	_, okReadFrom := dst.(io.ReaderFrom)
+	if okReadFrom && !canReadFromDirectly(src) {
+		// ReadFrom would allocate its own buffer, e.g. for a TCP connection copying from a stream of the edge
+		dst = writerOnly{dst}
+		okReadFrom = false
+	}
 	var buffer []byte = nil
 
 	if !(okWriteTo || okReadFrom) {
-		buffer = bufferPool.Get().([]byte)
-		defer bufferPool.Put(buffer)
+		ctx, cancel := context.WithTimeout(context.Background(), budgetWaitTimeout)
+		pooled, err := GetBuffer(ctx, StreamBufferSize)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		defer PutBuffer(pooled)
+		buffer = *pooled
 	}
 
 	return io.CopyBuffer(dst, src, buffer)
 }
+
+// canReadFromDirectly returns whether the ReadFrom of network connections can copy from src without a buffer, with
+// splice or sendfile.
+func canReadFromDirectly(src io.Reader) bool {
+	switch src.(type) {
+	case *net.TCPConn, *net.UnixConn, *os.File:
+		return true
+	default:
+		return false
+	}
+}
+
+// writerOnly hides the ReadFrom method of a writer.
+type writerOnly struct {
+	io.Writer
+}
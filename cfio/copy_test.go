@@ -0,0 +1,34 @@
+package cfio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allocatingReaderFrom stands for writers like TCP connections whose ReadFrom allocates a buffer for most sources.
+type allocatingReaderFrom struct {
+	bytes.Buffer
+	readFrom bool
+}
+
+func (w *allocatingReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return w.Buffer.ReadFrom(r)
+}
+
+func TestCopyUsesPooledBuffer(t *testing.T) {
+	dst := &allocatingReaderFrom{}
+	// LimitReader hides the WriteTo method of the strings.Reader
+	src := io.LimitReader(strings.NewReader("hello, world"), 1024)
+	written, err := Copy(dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), written)
+	assert.Equal(t, "hello, world", dst.String())
+	assert.False(t, dst.readFrom)
+	assert.Equal(t, int64(0), defaultManager.InUse())
+}
@@ -0,0 +1,28 @@
+package cfio
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomically replaces the file at filePath with content, writing it to a temporary file of the same directory
+// first, so that readers see either the previous or the new content, and a crash never leaves it half written.
+func WriteFileAtomically(filePath string, content []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
@@ -0,0 +1,29 @@
+package cfio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.json")
+
+	require.NoError(t, WriteFileAtomically(filePath, []byte("first")))
+	require.NoError(t, WriteFileAtomically(filePath, []byte("second")))
+
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.Error(t, WriteFileAtomically(filepath.Join(dir, "missing", "config.json"), []byte("content")))
+}
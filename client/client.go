@@ -0,0 +1,313 @@
+// Package client embeds a Cloudflare Tunnel connector in a Go program, so applications can expose their services
+// through a named tunnel without running the cloudflared binary next to them.
+//
+//	tunnel, err := client.Connect(ctx, creds, []client.IngressRule{
+//		{Hostname: "app.example.com", Service: "http://localhost:8080"},
+//		{Service: "http_status:404"},
+//	}, client.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	defer tunnel.Shutdown()
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
+	"github.com/cloudflare/cloudflared/features"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/orchestration"
+	"github.com/cloudflare/cloudflared/signal"
+	"github.com/cloudflare/cloudflared/supervisor"
+	"github.com/cloudflare/cloudflared/tlsconfig"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+const (
+	DefaultHAConnections = 4
+	DefaultGracePeriod   = 30 * time.Second
+	DefaultRetries       = 5
+	// DefaultVersion is reported to the edge as the connector's version when Options.Version isn't set.
+	DefaultVersion = "embedded"
+
+	defaultMaxEdgeAddrRetries          = 8
+	defaultUDPUnregisterSessionTimeout = 5 * time.Second
+)
+
+// Credentials authenticate the connector as a named tunnel. They're the contents of the tunnel's credentials file.
+type Credentials = connection.Credentials
+
+// IngressRule routes requests for a hostname and path to a service, like the ingress rules of the configuration
+// file. The last rule must match all requests.
+type IngressRule = config.UnvalidatedIngressRule
+
+// Event is something that happened to the connector's connections to the edge.
+type Event = connection.Event
+
+// EventType identifies what an Event is about.
+type EventType = connection.Status
+
+const (
+	// Connected is sent when a connection is registered with the edge. The event has the colo it's registered with.
+	Connected = connection.Connected
+	// Disconnected is sent when a connection is closed. The event has the error that caused it, if any.
+	Disconnected = connection.Disconnected
+	// Reconnecting is sent when a connection is about to be retried.
+	Reconnecting = connection.Reconnecting
+	// RegistrationError is sent when the edge refuses to register a connection.
+	RegistrationError = connection.RegistrationError
+)
+
+// Options tune how the connector connects to the edge. The zero value uses the same defaults as cloudflared.
+type Options struct {
+	// Logger receives the connector's logs. Logs are discarded if it's nil.
+	Logger *zerolog.Logger
+	// Protocol is the transport protocol to the edge: auto, quic or http2. Defaults to auto.
+	Protocol string
+	// HAConnections is how many connections to keep to the edge. Defaults to DefaultHAConnections.
+	HAConnections int
+	// Region is the region of the edge to connect to. Empty for the global region.
+	Region string
+	// GracePeriod bounds how long Shutdown waits for in-flight requests. Defaults to DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Retries is the maximum number of retries for connection errors. Defaults to DefaultRetries.
+	Retries uint
+	// OriginRequest is the default configuration of requests to origins, which rules can override.
+	OriginRequest config.OriginRequestConfig
+	// Tags are reported to the edge to identify the connector.
+	Tags map[string]string
+	// Features are enabled on top of the default ones.
+	Features []string
+	// Version is reported to the edge as the connector's version. Defaults to DefaultVersion.
+	Version string
+}
+
+// Tunnel is a running connector. Its connections are maintained until Shutdown or Close is called, or the context
+// given to Connect is done.
+type Tunnel struct {
+	// ID is the tunnel the connector serves.
+	ID uuid.UUID
+	// ConnectorID uniquely identifies this connector.
+	ConnectorID uuid.UUID
+
+	observer       *connection.Observer
+	connTracker    *tunnelstate.ConnTracker
+	gracePeriod    time.Duration
+	cancel         context.CancelFunc
+	graceShutdownC chan struct{}
+	shutdownOnce   sync.Once
+	done           chan struct{}
+	err            error
+}
+
+// Connect starts a connector for the tunnel identified by creds, serving the given ingress rules. It returns once a
+// connection to the edge is registered, or with an error if the connector stopped before that.
+// Cancelling ctx stops the connector without waiting for in-flight requests.
+func Connect(ctx context.Context, creds Credentials, rules []IngressRule, opts Options) (*Tunnel, error) {
+	opts.setDefaults()
+	log := opts.Logger
+
+	ingressRules, err := ingress.ParseIngress(&config.Configuration{
+		TunnelID:      creds.TunnelID.String(),
+		Ingress:       rules,
+		OriginRequest: opts.OriginRequest,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ingress rules")
+	}
+
+	connectorID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't generate connector UUID")
+	}
+	tags := make([]tunnelpogs.Tag, 0, len(opts.Tags)+1)
+	for name, value := range opts.Tags {
+		tags = append(tags, tunnelpogs.Tag{Name: name, Value: value})
+	}
+	tags = append(tags, tunnelpogs.Tag{Name: "ID", Value: connectorID.String()})
+
+	osArch := fmt.Sprintf("%s_%s", runtime.GOOS, runtime.GOARCH)
+	namedTunnel := &connection.NamedTunnelProperties{
+		Credentials: creds,
+		Client: tunnelpogs.ClientInfo{
+			ClientID: connectorID[:],
+			Features: append(append([]string{}, opts.Features...), features.DefaultFeatures...),
+			Version:  opts.Version,
+			Arch:     osArch,
+		},
+	}
+
+	protocolSelector, err := connection.NewProtocolSelector(opts.Protocol, creds.AccountTag, false, false, edgediscovery.ProtocolPercentage, connection.ResolveTTL, log)
+	if err != nil {
+		return nil, err
+	}
+	edgeTLSConfigs := make(map[connection.Protocol]*tls.Config, len(connection.ProtocolList))
+	for _, p := range connection.ProtocolList {
+		tlsSettings := p.TLSSettings()
+		if tlsSettings == nil {
+			return nil, fmt.Errorf("%s has unknown TLS settings", p)
+		}
+		edgeTLSConfig, err := tlsconfig.NewTunnelConfig(tlsSettings.ServerName, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create TLS config to connect with edge")
+		}
+		if len(tlsSettings.NextProtos) > 0 {
+			edgeTLSConfig.NextProtos = tlsSettings.NextProtos
+		}
+		edgeTLSConfigs[p] = edgeTLSConfig
+	}
+
+	observer := connection.NewObserver(log, log)
+	connTracker := tunnelstate.NewConnTracker(log)
+	observer.RegisterSink(connTracker)
+
+	tunnelConfig := &supervisor.TunnelConfig{
+		GracePeriod:                 opts.GracePeriod,
+		OSArch:                      osArch,
+		ClientID:                    connectorID.String(),
+		Region:                      opts.Region,
+		EdgeIPVersion:               allregions.Auto,
+		HAConnections:               opts.HAConnections,
+		IncidentLookup:              supervisor.NewIncidentLookup(),
+		Tags:                        tags,
+		Log:                         log,
+		LogTransport:                log,
+		Observer:                    observer,
+		ReportedVersion:             opts.Version,
+		Retries:                     opts.Retries,
+		NamedTunnel:                 namedTunnel,
+		ProtocolSelector:            protocolSelector,
+		EdgeTLSConfigs:              edgeTLSConfigs,
+		MaxEdgeAddrRetries:          defaultMaxEdgeAddrRetries,
+		UDPUnregisterSessionTimeout: defaultUDPUnregisterSessionTimeout,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	orchestrator, err := orchestration.NewOrchestrator(ctx, &orchestration.Config{
+		Ingress:           &ingressRules,
+		WarpRouting:       ingress.NewWarpRoutingConfig(&config.WarpRoutingConfig{}),
+		Observer:          observer,
+		TrailersSupported: opts.Protocol == connection.HTTP2.String(),
+	}, tags, nil, log)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	t := &Tunnel{
+		ID:             creds.TunnelID,
+		ConnectorID:    connectorID,
+		observer:       observer,
+		connTracker:    connTracker,
+		gracePeriod:    opts.GracePeriod,
+		cancel:         cancel,
+		graceShutdownC: make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	connectedSignal := signal.New(make(chan struct{}))
+	reconnectCh := make(chan supervisor.ReconnectSignal, opts.HAConnections)
+	go func() {
+		defer close(t.done)
+		t.err = supervisor.StartTunnelDaemon(ctx, tunnelConfig, orchestrator, connectedSignal, reconnectCh, t.graceShutdownC)
+	}()
+
+	select {
+	case <-connectedSignal.Wait():
+		return t, nil
+	case <-t.done:
+		cancel()
+		if t.err == nil {
+			t.err = ctx.Err()
+		}
+		if t.err == nil {
+			t.err = errors.New("connector stopped before connecting to the edge")
+		}
+		return nil, t.err
+	}
+}
+
+func (o *Options) setDefaults() {
+	if o.Logger == nil {
+		nop := zerolog.Nop()
+		o.Logger = &nop
+	}
+	if o.Protocol == "" {
+		o.Protocol = connection.AutoSelectFlag
+	}
+	if o.HAConnections <= 0 {
+		o.HAConnections = DefaultHAConnections
+	}
+	if o.GracePeriod <= 0 {
+		o.GracePeriod = DefaultGracePeriod
+	}
+	if o.GracePeriod > connection.MaxGracePeriod {
+		o.GracePeriod = connection.MaxGracePeriod
+	}
+	if o.Retries == 0 {
+		o.Retries = DefaultRetries
+	}
+	if o.Version == "" {
+		o.Version = DefaultVersion
+	}
+}
+
+// ActiveConnections returns how many connections to the edge are currently registered.
+func (t *Tunnel) ActiveConnections() uint {
+	return t.connTracker.CountActiveConns()
+}
+
+// Subscribe returns a channel that receives the connector's events of the given types, or all of them if no type is
+// given. Events are dropped if the subscriber falls more than bufferSize events behind.
+// The returned function ends the subscription and closes the channel.
+func (t *Tunnel) Subscribe(bufferSize int, types ...EventType) (<-chan Event, func()) {
+	return t.observer.Subscribe(bufferSize, types...)
+}
+
+// Done is closed once the connector has stopped.
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns why the connector stopped. It's nil until Done is closed, and after a requested shutdown.
+func (t *Tunnel) Err() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Shutdown gracefully stops the connector: the edge stops sending new requests to it, and in-flight ones are given
+// up to the grace period to complete. It returns once the connector has stopped.
+func (t *Tunnel) Shutdown() error {
+	t.shutdownOnce.Do(func() {
+		close(t.graceShutdownC)
+	})
+	select {
+	case <-t.done:
+	case <-time.After(t.gracePeriod):
+	}
+	return t.Close()
+}
+
+// Close stops the connector immediately, dropping in-flight requests. It returns once the connector has stopped.
+func (t *Tunnel) Close() error {
+	t.cancel()
+	<-t.done
+	return t.err
+}
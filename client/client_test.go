@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+func TestConnectInvalidIngress(t *testing.T) {
+	creds := Credentials{AccountTag: "account", TunnelSecret: []byte("secret"), TunnelID: uuid.New()}
+
+	_, err := Connect(context.Background(), creds, nil, Options{})
+	assert.Error(t, err)
+
+	// The last rule must match all requests
+	_, err = Connect(context.Background(), creds, []IngressRule{
+		{Hostname: "app.example.com", Service: "http://localhost:8080"},
+	}, Options{})
+	assert.Error(t, err)
+
+	_, err = Connect(context.Background(), creds, []IngressRule{
+		{Service: "http://localhost:8080"},
+	}, Options{Protocol: "carrier-pigeon"})
+	assert.Error(t, err)
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	opts := Options{GracePeriod: time.Hour}
+	opts.setDefaults()
+	require.NotNil(t, opts.Logger)
+	assert.Equal(t, connection.AutoSelectFlag, opts.Protocol)
+	assert.Equal(t, DefaultHAConnections, opts.HAConnections)
+	assert.Equal(t, connection.MaxGracePeriod, opts.GracePeriod)
+	assert.Equal(t, uint(DefaultRetries), opts.Retries)
+	assert.Equal(t, DefaultVersion, opts.Version)
+}
@@ -18,8 +18,10 @@ import (
 
 const (
 	LogFieldHost               = "host"
-	cfAccessClientIDHeader     = "Cf-Access-Client-Id"
-	cfAccessClientSecretHeader = "Cf-Access-Client-Secret"
+	cfAccessClientIDHeader     = carrier.CFAccessClientIDHeader
+	cfAccessClientSecretHeader = carrier.CFAccessClientSecretHeader
+	// socksListenerScheme makes the listener a SOCKS5 server for the socks-proxy origin of the application
+	socksListenerScheme = "socks5"
 )
 
 // StartForwarder starts a client side websocket forward
@@ -31,12 +33,8 @@ func StartForwarder(forwarder config.Forwarder, shutdown <-chan struct{}, log *z
 
 	// get the headers from the config file and add to the request
 	headers := make(http.Header)
-	if forwarder.TokenClientID != "" {
-		headers.Set(cfAccessClientIDHeader, forwarder.TokenClientID)
-	}
-
-	if forwarder.TokenSecret != "" {
-		headers.Set(cfAccessClientSecretHeader, forwarder.TokenSecret)
+	if err := setServiceTokenHeaders(headers, forwarder.TokenClientID, forwarder.TokenSecret); err != nil {
+		return err
 	}
 
 	carrier.SetBastionDest(headers, forwarder.Destination)
@@ -48,6 +46,9 @@ func StartForwarder(forwarder config.Forwarder, shutdown <-chan struct{}, log *z
 
 	// we could add a cmd line variable for this bool if we want the SOCK5 server to be on the client side
 	wsConn := carrier.NewWSConnection(log)
+	if validURL.Scheme == socksListenerScheme {
+		wsConn = carrier.NewSocksWSConnection(log)
+	}
 
 	log.Info().Str(LogFieldHost, validURL.Host).Msg("Start Websocket listener")
 	return carrier.StartForwarder(wsConn, validURL.Host, shutdown, options)
@@ -70,19 +71,17 @@ func ssh(c *cli.Context) error {
 
 	// get the headers from the cmdline and add them
 	headers := buildRequestHeaders(c.StringSlice(sshHeaderFlag))
-	if c.IsSet(sshTokenIDFlag) {
-		headers.Set(cfAccessClientIDHeader, c.String(sshTokenIDFlag))
-	}
-	if c.IsSet(sshTokenSecretFlag) {
-		headers.Set(cfAccessClientSecretHeader, c.String(sshTokenSecretFlag))
+	if err := setServiceTokenHeaders(headers, c.String(sshTokenIDFlag), c.String(sshTokenSecretFlag)); err != nil {
+		return err
 	}
 
 	carrier.SetBastionDest(headers, c.String(sshDestinationFlag))
 
 	options := &carrier.StartOptions{
-		OriginURL: originURL,
-		Headers:   headers,
-		Host:      hostname,
+		OriginURL:   originURL,
+		Headers:     headers,
+		Host:        hostname,
+		Compression: c.Bool(sshCompressionFlag),
 	}
 
 	if connectTo := c.String(sshConnectTo); connectTo != "" {
@@ -113,6 +112,17 @@ func ssh(c *cli.Context) error {
 			log.Err(err).Msg("Error validating origin URL")
 			return errors.Wrap(err, "error validating origin URL")
 		}
+		if forwarder.Scheme == socksListenerScheme {
+			// Rather than forwarding connections as they are, the listener serves SOCKS5 requests itself, so that it
+			// can relay the UDP datagrams of UDP ASSOCIATE requests
+			wsConn = carrier.NewSocksWSConnection(log)
+		} else if c.Bool(rdpGatewayFlag) {
+			wsConn = carrier.NewRDPGatewayConnection(log)
+			// RDP clients give up on connections that wait for a browser login, so it happens before they connect
+			if err := carrier.FetchAccessToken(options, log); err != nil {
+				log.Err(err).Msg("Failed to fetch an Access token, RDP clients will log in when they connect")
+			}
+		}
 		log.Info().Str(LogFieldHost, forwarder.Host).Msg("Start Websocket listener")
 		err = carrier.StartForwarder(wsConn, forwarder.Host, shutdownC, options)
 		if err != nil {
@@ -121,9 +131,26 @@ func ssh(c *cli.Context) error {
 		return err
 	}
 
+	if c.Bool(rdpGatewayFlag) {
+		return fmt.Errorf("--%s needs a local listener, set with --%s", rdpGatewayFlag, sshURLFlag)
+	}
 	return carrier.StartClient(wsConn, &carrier.StdinoutStream{}, options)
 }
 
+// setServiceTokenHeaders authenticates the requests to Access with a service token, if there is one, so that headless
+// machines don't go through the browser login flow. Both the ID and the secret of the token are needed.
+func setServiceTokenHeaders(headers http.Header, id, secret string) error {
+	if id == "" && secret == "" {
+		return nil
+	}
+	if id == "" || secret == "" {
+		return fmt.Errorf("a service token needs both --%s and --%s", sshTokenIDFlag, sshTokenSecretFlag)
+	}
+	headers.Set(cfAccessClientIDHeader, id)
+	headers.Set(cfAccessClientSecretHeader, secret)
+	return nil
+}
+
 func buildRequestHeaders(values []string) http.Header {
 	headers := make(http.Header)
 	for _, valuePair := range values {
@@ -16,3 +16,17 @@ func TestBuildRequestHeaders(t *testing.T) {
 	assert.Equal(t, headers.Get("client"), values.Get("client"))
 	assert.Equal(t, headers.Get("secret"), values.Get("secret"))
 }
+
+func TestSetServiceTokenHeaders(t *testing.T) {
+	headers := make(http.Header)
+	assert.NoError(t, setServiceTokenHeaders(headers, "", ""))
+	assert.Empty(t, headers)
+
+	assert.Error(t, setServiceTokenHeaders(headers, "id", ""))
+	assert.Error(t, setServiceTokenHeaders(headers, "", "secret"))
+	assert.Empty(t, headers)
+
+	assert.NoError(t, setServiceTokenHeaders(headers, "id", "secret"))
+	assert.Equal(t, "id", headers.Get("Cf-Access-Client-Id"))
+	assert.Equal(t, "secret", headers.Get("Cf-Access-Client-Secret"))
+}
@@ -1,8 +1,9 @@
 package access
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/net/idna"
 
 	"github.com/cloudflare/cloudflared/carrier"
@@ -33,15 +35,29 @@ const (
 	sshTokenIDFlag     = "service-token-id"
 	sshTokenSecretFlag = "service-token-secret"
 	sshGenCertFlag     = "short-lived-cert"
+	sshAgentFlag       = "agent"
+	sshRefreshFlag     = "refresh"
 	sshConnectTo       = "connect-to"
+	sshCompressionFlag = "compression"
+	rdpGatewayFlag     = "rdp-gateway"
+	tokenStoreFlag     = "token-store"
 	sshConfigTemplate  = `
 Add to your {{.Home}}/.ssh/config:
 
-{{- if .ShortLivedCerts}}
+{{- if and .ShortLivedCerts .Agent}}
+Match host {{.Hostname}} exec "{{.Cloudflared}} access ssh-gen --hostname %h --agent"
+  ProxyCommand {{.Cloudflared}} access ssh --hostname %h
+
+To keep a certificate in your SSH agent while you're logged in, run:
+  {{.Cloudflared}} access ssh-gen --hostname {{.Hostname}} --agent --refresh
+{{- else if .ShortLivedCerts}}
 Match host {{.Hostname}} exec "{{.Cloudflared}} access ssh-gen --hostname %h"
   ProxyCommand {{.Cloudflared}} access ssh --hostname %h
   IdentityFile ~/.cloudflared/%h-cf_key
   CertificateFile ~/.cloudflared/%h-cf_key-cert.pub
+
+To keep the certificate valid while you're logged in, run:
+  {{.Cloudflared}} access ssh-gen --hostname {{.Hostname}} --refresh
 {{- else}}
 Host {{.Hostname}}
   ProxyCommand {{.Cloudflared}} access ssh --hostname %h
@@ -79,6 +95,15 @@ func Commands() []*cli.Command {
 			per-user and by application. With Cloudflare Access, only authenticated users with the required permissions are
 			able to reach sensitive resources. The commands provided here allow you to interact with Access protected
 			applications from the command line.`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    tokenStoreFlag,
+					Usage:   fmt.Sprintf("where to keep Access tokens: %q for files in ~/.cloudflared, or %q for the keychain of the OS (macOS Keychain, Windows Credential Manager, or libsecret on Linux)", token.FileStoreName, token.KeychainStoreName),
+					Value:   token.FileStoreName,
+					EnvVars: []string{"TUNNEL_ACCESS_TOKEN_STORE"},
+				},
+			},
+			Before: cli.BeforeFunc(cliutil.WithErrorHandler(setTokenStore)),
 			Subcommands: []*cli.Command{
 				{
 					Name:   "login",
@@ -88,14 +113,19 @@ func Commands() []*cli.Command {
 					The subcommand will launch a browser. For headless systems, a url is provided.
 					Once authenticated with your identity provider, the login command will generate a JSON Web Token (JWT)
 					scoped to your identity, the application you intend to reach, and valid for a session duration set by your
-					administrator. cloudflared stores the token in local storage.`,
+					administrator. cloudflared stores the token in local storage, or in the keychain of the OS with
+					--token-store keychain, and renews it before it expires while your session lasts.`,
 				},
 				{
 					Name:   "curl",
 					Action: cliutil.Action(curl),
-					Usage:  "curl [--allow-request, -ar] <url> [<curl args>...]",
+					Usage:  "curl [--allow-request, -ar] [--service-token-id <id> --service-token-secret <secret>] <url> [<curl args>...]",
 					Description: `The curl subcommand wraps curl and automatically injects the JWT into a cf-access-token
-					header when using curl to reach an application behind Access.`,
+					header when using curl to reach an application behind Access. With a service token, from the
+					--service-token-id and --service-token-secret options or the TUNNEL_SERVICE_TOKEN_ID and
+					TUNNEL_SERVICE_TOKEN_SECRET environment variables, its Cf-Access-Client-Id and Cf-Access-Client-Secret
+					headers are injected instead, so that scripts never need to log in. cloudflared exits with the exit
+					code of curl.`,
 					ArgsUsage:       "allow-request will allow the curl request to continue even if the jwt is not present.",
 					SkipFlagParsing: true,
 				},
@@ -112,12 +142,14 @@ func Commands() []*cli.Command {
 					},
 				},
 				{
-					Name:        "tcp",
-					Action:      cliutil.Action(ssh),
-					Aliases:     []string{"rdp", "ssh", "smb"},
-					Usage:       "",
-					ArgsUsage:   "",
-					Description: `The tcp subcommand sends data over a proxy to the Cloudflare edge.`,
+					Name:      "tcp",
+					Action:    cliutil.Action(ssh),
+					Aliases:   []string{"rdp", "ssh", "smb"},
+					Usage:     "",
+					ArgsUsage: "",
+					Description: `The tcp subcommand sends data over a proxy to the Cloudflare edge. Headless machines can
+					authenticate with an Access service token instead of a browser login, with --service-token-id and
+					--service-token-secret, or the TUNNEL_SERVICE_TOKEN_ID and TUNNEL_SERVICE_TOKEN_SECRET environment variables.`,
 					Flags: []cli.Flag{
 						&cli.StringFlag{
 							Name:    sshHostnameFlag,
@@ -131,7 +163,7 @@ func Commands() []*cli.Command {
 						&cli.StringFlag{
 							Name:    sshURLFlag,
 							Aliases: []string{"listener", "L"},
-							Usage:   "specify the host:port to forward data to Cloudflare edge.",
+							Usage:   "specify the host:port to forward data to Cloudflare edge. With a socks5:// scheme, the listener serves the SOCKS5 requests of local clients, including UDP ASSOCIATE, with the socks-proxy origin of the application.",
 						},
 						&cli.StringSliceFlag{
 							Name:    sshHeaderFlag,
@@ -160,6 +192,14 @@ func Commands() []*cli.Command {
 							Aliases: []string{"loglevel"}, //added to match the tunnel side
 							Usage:   "Application logging level {debug, info, warn, error, fatal}. ",
 						},
+						&cli.BoolFlag{
+							Name:  rdpGatewayFlag,
+							Usage: "read the connection request of RDP clients before connecting them, and fetch the Access token when the listener starts rather than on the first connection. TLS and NLA stay between the client and the RDP server.",
+						},
+						&cli.BoolFlag{
+							Name:  sshCompressionFlag,
+							Usage: "offer the permessage-deflate websocket extension to compress the data sent over the proxy.",
+						},
 						&cli.StringFlag{
 							Name:   sshConnectTo,
 							Hidden: true,
@@ -181,6 +221,10 @@ func Commands() []*cli.Command {
 							Name:  sshGenCertFlag,
 							Usage: "specify if you wish to generate short lived certs.",
 						},
+						&cli.BoolFlag{
+							Name:  sshAgentFlag,
+							Usage: "specify if you wish the short lived certs to be added to your SSH agent instead of written to ~/.cloudflared.",
+						},
 					},
 				},
 				{
@@ -193,6 +237,14 @@ func Commands() []*cli.Command {
 							Name:  sshHostnameFlag,
 							Usage: "specify the hostname of your application.",
 						},
+						&cli.BoolFlag{
+							Name:  sshAgentFlag,
+							Usage: "add the certificate and its key to the SSH agent at $SSH_AUTH_SOCK instead of writing them to ~/.cloudflared.",
+						},
+						&cli.BoolFlag{
+							Name:  sshRefreshFlag,
+							Usage: "keep running, and generate a new certificate before the current one expires.",
+						},
 					},
 				},
 			},
@@ -200,6 +252,16 @@ func Commands() []*cli.Command {
 	}
 }
 
+// setTokenStore makes the Access commands keep tokens in the store of --token-store
+func setTokenStore(c *cli.Context) error {
+	store, err := token.NewStore(c.String(tokenStoreFlag))
+	if err != nil {
+		return err
+	}
+	token.SetStore(store)
+	return nil
+}
+
 // login pops up the browser window to do the actual login and JWT generation
 func login(c *cli.Context) error {
 	err := sentry.Init(sentry.ClientOptions{
@@ -270,12 +332,31 @@ func curl(c *cli.Context) error {
 		return errors.New("incorrect args")
 	}
 
-	cmdArgs, allowRequest := parseAllowRequest(args.Slice())
+	cmdArgs, options, err := parseCurlArgs(args.Slice())
+	if err != nil {
+		return err
+	}
 	appURL, err := getAppURL(cmdArgs, log)
 	if err != nil {
 		return err
 	}
 
+	// A service token authenticates the request by itself, there's no user token to fetch
+	if options.serviceTokenID != "" || options.serviceTokenSecret != "" {
+		headers := make(http.Header)
+		if err := setServiceTokenHeaders(headers, options.serviceTokenID, options.serviceTokenSecret); err != nil {
+			return err
+		}
+		// The secret is passed in a file that only the user can read rather than in the arguments of curl, which
+		// any user can list
+		headerFile, err := writeCurlHeaderFile(headers)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(headerFile)
+		return runCurl(append(cmdArgs, "-H", "@"+headerFile))
+	}
+
 	appInfo, err := token.GetAppInfo(appURL)
 	if err != nil {
 		return err
@@ -289,9 +370,9 @@ func curl(c *cli.Context) error {
 
 	tok, err := token.GetAppTokenIfExists(appInfo)
 	if err != nil || tok == "" {
-		if allowRequest {
+		if options.allowRequest {
 			log.Info().Msg("You don't have an Access token set. Please run access token <access application> to fetch one.")
-			return run("curl", cmdArgs...)
+			return runCurl(cmdArgs)
 		}
 		tok, err = token.FetchToken(appURL, appInfo, log)
 		if err != nil {
@@ -302,28 +383,44 @@ func curl(c *cli.Context) error {
 
 	cmdArgs = append(cmdArgs, "-H")
 	cmdArgs = append(cmdArgs, fmt.Sprintf("%s: %s", carrier.CFAccessTokenHeader, tok))
-	return run("curl", cmdArgs...)
+	return runCurl(cmdArgs)
 }
 
-// run kicks off a shell task and pipe the results to the respective std pipes
-func run(cmd string, args ...string) error {
-	c := exec.Command(cmd, args...)
-	c.Stdin = os.Stdin
-	stderr, err := c.StderrPipe()
+// writeCurlHeaderFile writes headers to a temporary file, which os.CreateTemp makes readable by the user only, for
+// curl to read them with -H @file. The caller removes the file.
+func writeCurlHeaderFile(headers http.Header) (string, error) {
+	f, err := os.CreateTemp("", "cloudflared-curl-headers-*")
 	if err != nil {
-		return err
+		return "", errors.Wrap(err, "failed to create the header file of curl")
+	}
+	defer f.Close()
+	for name, values := range headers {
+		for _, value := range values {
+			if _, err := fmt.Fprintf(f, "%s: %s\n", name, value); err != nil {
+				os.Remove(f.Name())
+				return "", errors.Wrap(err, "failed to write the header file of curl")
+			}
+		}
 	}
-	go func() {
-		io.Copy(os.Stderr, stderr)
-	}()
+	return f.Name(), nil
+}
 
-	stdout, err := c.StdoutPipe()
-	if err != nil {
-		return err
+// runCurl runs curl with args, and exits with the exit code of curl so that scripts can tell why it failed
+func runCurl(args []string) error {
+	err := run("curl", args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return cli.Exit("", exitErr.ExitCode())
 	}
-	go func() {
-		io.Copy(os.Stdout, stdout)
-	}()
+	return err
+}
+
+// run kicks off a shell task that reads and writes the respective std pipes
+func run(cmd string, args ...string) error {
+	c := exec.Command(cmd, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
 	return c.Run()
 }
 
@@ -370,12 +467,19 @@ func sshConfig(c *cli.Context) error {
 	type config struct {
 		Home            string
 		ShortLivedCerts bool
+		Agent           bool
 		Hostname        string
 		Cloudflared     string
 	}
 
 	t := template.Must(template.New("sshConfig").Parse(sshConfigTemplate))
-	return t.Execute(os.Stdout, config{Home: os.Getenv("HOME"), ShortLivedCerts: genCertBool, Hostname: hostname, Cloudflared: cloudflaredPath()})
+	return t.Execute(os.Stdout, config{
+		Home:            os.Getenv("HOME"),
+		ShortLivedCerts: genCertBool,
+		Agent:           c.Bool(sshAgentFlag),
+		Hostname:        hostname,
+		Cloudflared:     cloudflaredPath(),
+	})
 }
 
 // sshGen generates a short lived certificate for provided hostname
@@ -394,26 +498,74 @@ func sshGen(c *cli.Context) error {
 		return err
 	}
 
-	// this fetchToken function mutates the appURL param. We should refactor that
-	fetchTokenURL := &url.URL{}
-	*fetchTokenURL = *originURL
+	install := sshgen.InstallCertificateFile
+	if c.Bool(sshAgentFlag) {
+		sshAgent, err := dialSSHAgent()
+		if err != nil {
+			return err
+		}
+		// ssh runs this through the Match exec of `ssh-config --agent` every time it connects, so the cert already in
+		// the agent is used as long as it is valid
+		if !c.Bool(sshRefreshFlag) {
+			hasCert, err := sshgen.HasCertificateInAgent(sshAgent, originURL)
+			if err != nil {
+				return err
+			}
+			if hasCert {
+				return nil
+			}
+		}
+		install = sshgen.InstallCertificateInAgent(sshAgent)
+	}
 
-	appInfo, err := token.GetAppInfo(fetchTokenURL)
+	appInfo, err := token.GetAppInfo(originURL)
 	if err != nil {
 		return err
 	}
-	cfdToken, err := token.FetchTokenWithRedirect(fetchTokenURL, appInfo, log)
+	fetchToken := func() (string, error) {
+		// this fetchToken function mutates the appURL param. We should refactor that
+		fetchTokenURL := &url.URL{}
+		*fetchTokenURL = *originURL
+		return token.FetchTokenWithRedirect(fetchTokenURL, appInfo, log)
+	}
+
+	if c.Bool(sshRefreshFlag) {
+		ctx, cancel := context.WithCancel(c.Context)
+		defer cancel()
+		go func() {
+			select {
+			case <-shutdownC:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		return sshgen.RefreshShortLivedCertificate(ctx, originURL, fetchToken, install, log)
+	}
+
+	cfdToken, err := fetchToken()
 	if err != nil {
 		return err
 	}
-
-	if err := sshgen.GenerateShortLivedCertificate(originURL, cfdToken); err != nil {
+	if _, err := install(originURL, cfdToken); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// dialSSHAgent connects to the SSH agent that listens on $SSH_AUTH_SOCK
+func dialSSHAgent() (agent.Agent, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set, start an SSH agent to add certificates to it")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to the SSH agent")
+	}
+	return agent.NewClient(conn), nil
+}
+
 // getAppURL will pull the request URL needed for fetching a user's Access token
 func getAppURL(cmdArgs []string, log *zerolog.Logger) (*url.URL, error) {
 	if len(cmdArgs) < 1 {
@@ -430,16 +582,50 @@ func getAppURL(cmdArgs []string, log *zerolog.Logger) (*url.URL, error) {
 	return u, err
 }
 
-// parseAllowRequest will parse cmdArgs and return a copy of the args and result
-// of the allow request was present
-func parseAllowRequest(cmdArgs []string) ([]string, bool) {
-	if len(cmdArgs) > 1 {
-		if cmdArgs[0] == "--allow-request" || cmdArgs[0] == "-ar" {
-			return cmdArgs[1:], true
+// curlOptions are the options of `access curl` that come before the arguments of curl
+type curlOptions struct {
+	allowRequest       bool
+	serviceTokenID     string
+	serviceTokenSecret string
+}
+
+// parseCurlArgs will parse the options of `access curl` at the start of cmdArgs, and return the arguments of curl
+// that follow them. The service token defaults to the one of the environment, like for `access ssh`.
+func parseCurlArgs(cmdArgs []string) ([]string, curlOptions, error) {
+	options := curlOptions{
+		serviceTokenID:     os.Getenv("TUNNEL_SERVICE_TOKEN_ID"),
+		serviceTokenSecret: os.Getenv("TUNNEL_SERVICE_TOKEN_SECRET"),
+	}
+	// The last argument is always left to curl, which needs at least a URL
+	for len(cmdArgs) > 1 {
+		name, value, hasValue := strings.Cut(cmdArgs[0], "=")
+		if !strings.HasPrefix(name, "-") {
+			return cmdArgs, options, nil
+		}
+		var dst *string
+		switch strings.TrimLeft(name, "-") {
+		case "allow-request", "ar":
+			options.allowRequest = true
+			cmdArgs = cmdArgs[1:]
+			continue
+		case sshTokenIDFlag:
+			dst = &options.serviceTokenID
+		case sshTokenSecretFlag:
+			dst = &options.serviceTokenSecret
+		default:
+			return cmdArgs, options, nil
 		}
+		if hasValue {
+			cmdArgs = cmdArgs[1:]
+		} else if len(cmdArgs) > 2 {
+			value = cmdArgs[1]
+			cmdArgs = cmdArgs[2:]
+		} else {
+			return nil, options, fmt.Errorf("%s needs a value", name)
+		}
+		*dst = value
 	}
-
-	return cmdArgs, false
+	return cmdArgs, options, nil
 }
 
 // processURL will preprocess the string (parse to a url, convert to punycode, etc).
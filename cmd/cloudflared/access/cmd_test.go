@@ -1,6 +1,14 @@
 package access
 
-import "testing"
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 func Test_ensureURLScheme(t *testing.T) {
 	type args struct {
@@ -23,3 +31,73 @@ func Test_ensureURLScheme(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCurlArgs(t *testing.T) {
+	t.Setenv("TUNNEL_SERVICE_TOKEN_ID", "")
+	t.Setenv("TUNNEL_SERVICE_TOKEN_SECRET", "")
+	tests := []struct {
+		name     string
+		args     []string
+		wantArgs []string
+		want     curlOptions
+	}{
+		{
+			name:     "only curl args",
+			args:     []string{"https://app.example.com", "-v"},
+			wantArgs: []string{"https://app.example.com", "-v"},
+		},
+		{
+			name:     "allow request",
+			args:     []string{"-ar", "https://app.example.com"},
+			wantArgs: []string{"https://app.example.com"},
+			want:     curlOptions{allowRequest: true},
+		},
+		{
+			name:     "service token",
+			args:     []string{"--service-token-id", "id", "--service-token-secret=secret", "--allow-request", "https://app.example.com", "-v"},
+			wantArgs: []string{"https://app.example.com", "-v"},
+			want:     curlOptions{allowRequest: true, serviceTokenID: "id", serviceTokenSecret: "secret"},
+		},
+		{
+			name:     "url is never an option",
+			args:     []string{"--allow-request"},
+			wantArgs: []string{"--allow-request"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, options, err := parseCurlArgs(tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantArgs, args)
+			assert.Equal(t, tt.want, options)
+		})
+	}
+
+	_, _, err := parseCurlArgs([]string{"--service-token-id", "https://app.example.com"})
+	assert.Error(t, err)
+
+	t.Setenv("TUNNEL_SERVICE_TOKEN_ID", "env-id")
+	_, options, err := parseCurlArgs([]string{"https://app.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-id", options.serviceTokenID)
+}
+
+func TestWriteCurlHeaderFile(t *testing.T) {
+	headers := make(http.Header)
+	require.NoError(t, setServiceTokenHeaders(headers, "id", "secret"))
+
+	name, err := writeCurlHeaderFile(headers)
+	require.NoError(t, err)
+	defer os.Remove(name)
+
+	content, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Cf-Access-Client-Id: id\n")
+	assert.Contains(t, string(content), "Cf-Access-Client-Secret: secret\n")
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
@@ -3,26 +3,64 @@ package cliutil
 import (
 	"fmt"
 	"runtime"
+	"runtime/debug"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
 
+// Features reported in BuildInfo. FeatureBoringCrypto comes from the build settings, the others are added by the
+// commands that provide them: FeaturePostQuantum is only reported by tunnels that run with --post-quantum.
+const (
+	FeatureQUIC         = "quic"
+	FeaturePostQuantum  = "pq"
+	FeatureBoringCrypto = "boringcrypto"
+)
+
 type BuildInfo struct {
-	GoOS               string `json:"go_os"`
-	GoVersion          string `json:"go_version"`
-	GoArch             string `json:"go_arch"`
-	BuildType          string `json:"build_type"`
-	CloudflaredVersion string `json:"cloudflared_version"`
+	GoOS               string   `json:"go_os"`
+	GoVersion          string   `json:"go_version"`
+	GoArch             string   `json:"go_arch"`
+	BuildType          string   `json:"build_type"`
+	CloudflaredVersion string   `json:"cloudflared_version"`
+	GitCommit          string   `json:"git_commit,omitempty"`
+	BuildTags          []string `json:"build_tags"`
+	Features           []string `json:"features"`
+	Protocols          []string `json:"protocols"`
 }
 
 func GetBuildInfo(buildType, version string) *BuildInfo {
-	return &BuildInfo{
+	bi := &BuildInfo{
 		GoOS:               runtime.GOOS,
 		GoVersion:          runtime.Version(),
 		GoArch:             runtime.GOARCH,
 		BuildType:          buildType,
 		CloudflaredVersion: version,
+		BuildTags:          []string{},
+		Features:           []string{},
+		Protocols:          []string{},
+	}
+	boringCrypto := false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				bi.GitCommit = setting.Value
+			case "-tags":
+				bi.BuildTags = strings.Split(setting.Value, ",")
+			case "GOEXPERIMENT":
+				boringCrypto = boringCrypto || strings.Contains(setting.Value, "boringcrypto")
+			}
+		}
 	}
+	for _, tag := range bi.BuildTags {
+		// FIPS builds link against BoringCrypto
+		boringCrypto = boringCrypto || tag == "fips"
+	}
+	if boringCrypto {
+		bi.Features = append(bi.Features, FeatureBoringCrypto)
+	}
+	return bi
 }
 
 func (bi *BuildInfo) Log(log *zerolog.Logger) {
@@ -31,6 +69,10 @@ func (bi *BuildInfo) Log(log *zerolog.Logger) {
 		log.Info().Msgf("Built%s", bi.GetBuildTypeMsg())
 	}
 	log.Info().Msgf("GOOS: %s, GOVersion: %s, GoArch: %s", bi.GoOS, bi.GoVersion, bi.GoArch)
+	if bi.GitCommit != "" {
+		log.Debug().Msgf("Git commit: %s", bi.GitCommit)
+	}
+	log.Debug().Msgf("Features: %s, protocols: %s", strings.Join(bi.Features, ","), strings.Join(bi.Protocols, ","))
 }
 
 func (bi *BuildInfo) OSArch() string {
@@ -0,0 +1,166 @@
+package cliutil
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/logger"
+)
+
+var (
+	logLevels = []string{"debug", "info", "warn", "error", "fatal"}
+	// flagValues are the values that shell completion offers for flags, by flag name
+	flagValues = map[string][]string{
+		logger.LogLevelFlag:          logLevels,
+		logger.LogTransportLevelFlag: logLevels,
+	}
+)
+
+// RegisterFlagValues makes shell completion offer values for a flag.
+func RegisterFlagValues(flagName string, values ...string) {
+	flagValues[flagName] = values
+}
+
+// Complete returns a shell completion function that offers the values of the flag being completed, the flags when a
+// flag is being typed, and otherwise the subcommands and the arguments returned by args, which may be nil.
+func Complete(args func(c *cli.Context) []string) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		// The last argument is the completion flag, the one before is the last word typed
+		var lastArg string
+		if len(os.Args) > 2 {
+			lastArg = os.Args[len(os.Args)-2]
+		}
+		if strings.HasPrefix(lastArg, "-") {
+			if values, ok := flagValues[strings.TrimLeft(lastArg, "-")]; ok {
+				for _, value := range values {
+					_, _ = fmt.Fprintln(c.App.Writer, value)
+				}
+				return
+			}
+		} else if args != nil {
+			for _, arg := range args(c) {
+				_, _ = fmt.Fprintln(c.App.Writer, arg)
+			}
+		}
+		// Commands with subcommands complete as an app, whose context has an empty command
+		command := c.Command
+		if command != nil && command.Name == "" {
+			command = nil
+		}
+		cli.DefaultCompleteWithFlags(command)(c)
+	}
+}
+
+// EnableCompletion turns on shell completion for the app, completing with Complete the commands that don't complete
+// their arguments themselves.
+func EnableCompletion(app *cli.App) {
+	app.EnableBashCompletion = true
+	app.BashComplete = Complete(nil)
+	setCompletion(app.Commands)
+}
+
+func setCompletion(commands []*cli.Command) {
+	for _, command := range commands {
+		if command.BashComplete == nil {
+			command.BashComplete = Complete(nil)
+		}
+		setCompletion(command.Subcommands)
+	}
+}
+
+var completionScripts = map[string]string{
+	"bash": `_cloudflared_completion() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" "$cur" --generate-bash-completion 2>/dev/null)
+  else
+    opts=$("${COMP_WORDS[@]:0:$COMP_CWORD}" --generate-bash-completion 2>/dev/null)
+  fi
+  COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+  return 0
+}
+complete -o bashdefault -o default -F _cloudflared_completion cloudflared
+`,
+	"zsh": `#compdef cloudflared
+_cloudflared() {
+  local -a opts
+  local cur="${words[-1]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} "$cur" --generate-bash-completion 2>/dev/null)}")
+  else
+    opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  fi
+  if [[ "${opts[1]}" != "" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+compdef _cloudflared cloudflared
+`,
+	"fish": `function __cloudflared_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    if string match -q -- '-*' $current
+        $tokens $current --generate-bash-completion 2>/dev/null
+    else
+        $tokens --generate-bash-completion 2>/dev/null
+    end
+end
+complete -c cloudflared -f -a '(__cloudflared_complete)'
+`,
+	"powershell": `Register-ArgumentCompleter -Native -CommandName cloudflared -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') { $words = $words[0..($words.Count - 2)] }
+    $arguments = @()
+    if ($words.Count -gt 1) { $arguments += $words[1..($words.Count - 1)] }
+    if ($wordToComplete -like '-*') { $arguments += $wordToComplete }
+    & $words[0] @arguments --generate-bash-completion 2>$null |
+        Where-Object { $_ -like "$wordToComplete*" } |
+        ForEach-Object { [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_) }
+}
+`,
+}
+
+// CompletionCommand prints the scripts that set up shell completion.
+func CompletionCommand() *cli.Command {
+	shells := make([]string, 0, len(completionScripts))
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return &cli.Command{
+		Name:      "completion",
+		Usage:     "Print the script that sets up shell completion",
+		UsageText: fmt.Sprintf("cloudflared completion %s", strings.Join(shells, "|")),
+		Description: `Prints the script that completes commands, flags, flag values and tunnel names in the given shell.
+Tunnel names come from the tunnels that were listed or created on this machine. For example:
+
+  bash:       source <(cloudflared completion bash)
+  zsh:        cloudflared completion zsh > "${fpath[1]}/_cloudflared"
+  fish:       cloudflared completion fish > ~/.config/fish/completions/cloudflared.fish
+  powershell: cloudflared completion powershell | Out-String | Invoke-Expression`,
+		Action: func(c *cli.Context) error {
+			script, ok := completionScripts[c.Args().First()]
+			if c.NArg() != 1 || !ok {
+				return UsageError("\"cloudflared completion\" requires exactly 1 argument, one of %s.", strings.Join(shells, ", "))
+			}
+			_, err := fmt.Fprint(c.App.Writer, script)
+			return err
+		},
+		BashComplete: func(c *cli.Context) {
+			if c.NArg() == 0 {
+				for _, shell := range shells {
+					_, _ = fmt.Fprintln(c.App.Writer, shell)
+				}
+			}
+		},
+	}
+}
@@ -1,9 +1,30 @@
 package cliutil
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+// Exit codes of cloudflared, so that service managers and scripts can tell failures apart without parsing the logs.
+// The updater exits with 10 when an update failed and 11 when cloudflared was updated, and usage errors exit with 255.
+const (
+	// ExitCodeError is for failures that don't have a more specific exit code.
+	ExitCodeError = 1
+	// ExitCodeConfig is for an invalid configuration file, ingress rules or flags.
+	ExitCodeConfig = 2
+	// ExitCodeCredentials is for tunnel credentials or a token that can't be read or are invalid, and for the API
+	// refusing the credentials.
+	ExitCodeCredentials = 3
+	// ExitCodeOriginCertMissing is for commands that need the origin certificate when there is none.
+	ExitCodeOriginCertMissing = 4
+	// ExitCodeEdgeUnreachable is for the edge that can't be discovered or connected to.
+	ExitCodeEdgeUnreachable = 5
+	// ExitCodeRegistrationRejected is for the edge refusing to register the tunnel for good.
+	ExitCodeRegistrationRejected = 6
 )
 
 type usageError string
@@ -21,6 +42,31 @@ func UsageError(format string, args ...interface{}) error {
 	}
 }
 
+type exitCodeError struct {
+	err  error
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *exitCodeError) ExitCode() int {
+	return e.code
+}
+
+// WithExitCode makes cloudflared exit with code if a command returns err, even once err is wrapped.
+func WithExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{err: err, code: code}
+}
+
 // Ensures exit with error code if actionFunc returns an error
 func WithErrorHandler(actionFunc cli.ActionFunc) cli.ActionFunc {
 	return func(ctx *cli.Context) error {
@@ -30,9 +76,21 @@ func WithErrorHandler(actionFunc cli.ActionFunc) cli.ActionFunc {
 				msg := fmt.Sprintf("%s\nSee 'cloudflared %s --help'.", err.Error(), ctx.Command.FullName())
 				err = cli.Exit(msg, -1)
 			} else if _, ok := err.(cli.ExitCoder); !ok {
-				err = cli.Exit(err.Error(), 1)
+				err = cli.Exit(err.Error(), exitCode(err))
 			}
 		}
 		return err
 	}
 }
+
+// exitCode finds the exit code of a wrapped error.
+func exitCode(err error) int {
+	var exitCoder cli.ExitCoder
+	if errors.As(err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+	if errors.Is(err, cfapi.ErrUnauthorized) {
+		return ExitCodeCredentials
+	}
+	return ExitCodeError
+}
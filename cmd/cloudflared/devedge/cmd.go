@@ -0,0 +1,172 @@
+package devedge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/devedge"
+	"github.com/cloudflare/cloudflared/logger"
+)
+
+const (
+	edgeAddressFlag = "edge-address"
+	httpAddressFlag = "http-address"
+	caCertOutFlag   = "cacert-out"
+	tunnelIDFlag    = "tunnel-id"
+	accountTagFlag  = "account-tag"
+)
+
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:   "dev-edge",
+		Action: cliutil.ConfiguredAction(Run),
+		Usage:  "Run a local mock of Cloudflare's edge for offline development of tunnel configurations.",
+		Description: `Starts a local stand-in for Cloudflare's edge that cloudflared can connect a tunnel to, without Internet
+access or a Cloudflare account. Requests sent to --http-address are proxied through the tunnel to its ingress
+rules, exactly as if they came from Cloudflare.
+
+Only the http2 protocol is supported. On startup the command prints the arguments to pass to
+'cloudflared tunnel run' to connect to it.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    edgeAddressFlag,
+				Usage:   "Listen address for tunnel connections from cloudflared.",
+				Value:   "127.0.0.1:7844",
+				EnvVars: []string{"DEV_EDGE_ADDRESS"},
+			},
+			&cli.StringFlag{
+				Name:    httpAddressFlag,
+				Usage:   "Listen address for HTTP requests to proxy through the tunnel.",
+				Value:   "127.0.0.1:8000",
+				EnvVars: []string{"DEV_EDGE_HTTP_ADDRESS"},
+			},
+			&cli.StringFlag{
+				Name:    caCertOutFlag,
+				Usage:   "Path to write the dev edge's CA certificate to, for use with `tunnel run --cacert`.",
+				Value:   "dev-edge-ca.pem",
+				EnvVars: []string{"DEV_EDGE_CACERT_OUT"},
+			},
+			&cli.StringFlag{
+				Name:    tunnelIDFlag,
+				Usage:   "ID of the tunnel allowed to connect. A random one is generated if not specified.",
+				EnvVars: []string{"DEV_EDGE_TUNNEL_ID"},
+			},
+			&cli.StringFlag{
+				Name:    accountTagFlag,
+				Usage:   "Account tag of the tunnel allowed to connect.",
+				Value:   "dev",
+				EnvVars: []string{"DEV_EDGE_ACCOUNT_TAG"},
+			},
+		},
+		ArgsUsage: " ", // can't be the empty string or we get the default output
+	}
+}
+
+// Run starts the dev edge and blocks until it receives SIGINT or SIGTERM.
+func Run(c *cli.Context) error {
+	log := logger.CreateLoggerFromContext(c, logger.EnableTerminalLog)
+
+	credentials, err := devCredentials(c)
+	if err != nil {
+		return err
+	}
+	edge, err := devedge.New(credentials, log)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.String(caCertOutFlag), edge.CACertPEM(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write dev edge CA certificate")
+	}
+
+	edgeListener, err := net.Listen("tcp", c.String(edgeAddressFlag))
+	if err != nil {
+		return errors.Wrap(err, "failed to open tunnel listener")
+	}
+	httpListener, err := net.Listen("tcp", c.String(httpAddressFlag))
+	if err != nil {
+		edgeListener.Close()
+		return errors.Wrap(err, "failed to open HTTP listener")
+	}
+
+	token, err := tunnelToken(credentials)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(`Dev edge is ready. Connect a tunnel to it with:
+
+	cloudflared tunnel --edge %s --cacert %s --protocol http2 run --token %s
+
+Then send requests through the tunnel to http://%s
+`, edgeListener.Addr(), c.String(caCertOutFlag), token, httpListener.Addr())
+
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+
+	errC := make(chan error, 2)
+	go func() {
+		errC <- edge.ServeTunnels(ctx, edgeListener)
+	}()
+	httpServer := &http.Server{Handler: edge}
+	go func() {
+		errC <- httpServer.Serve(httpListener)
+	}()
+
+	signals := make(chan os.Signal, 10)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	select {
+	case <-signals:
+		err = nil
+	case err = <-errC:
+		log.Err(err).Msg("Dev edge stopped")
+	}
+	cancel()
+	httpServer.Close()
+	return err
+}
+
+func devCredentials(c *cli.Context) (connection.Credentials, error) {
+	tunnelID := uuid.New()
+	if id := c.String(tunnelIDFlag); id != "" {
+		var err error
+		if tunnelID, err = uuid.Parse(id); err != nil {
+			return connection.Credentials{}, errors.Wrapf(err, "%s is not a valid tunnel ID", id)
+		}
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return connection.Credentials{}, errors.Wrap(err, "couldn't generate the tunnel secret")
+	}
+	return connection.Credentials{
+		AccountTag:   c.String(accountTagFlag),
+		TunnelSecret: secret,
+		TunnelID:     tunnelID,
+	}, nil
+}
+
+func tunnelToken(credentials connection.Credentials) (string, error) {
+	tokenJSON, err := json.Marshal(connection.TunnelToken{
+		AccountTag:   credentials.AccountTag,
+		TunnelSecret: credentials.TunnelSecret,
+		TunnelID:     credentials.TunnelID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(tokenJSON), nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/access"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/devedge"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/proxydns"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/tail"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/tunnel"
@@ -83,7 +85,8 @@ func main() {
 	See https://developers.cloudflare.com/cloudflare-one/connections/connect-apps for more in-depth documentation.`
 	app.Flags = flags()
 	app.Action = action(graceShutdownC)
-	app.Commands = commands(cli.ShowVersion)
+	app.Commands = commands(cli.ShowVersion, bInfo)
+	cliutil.EnableCompletion(app)
 
 	tunnel.Init(bInfo, graceShutdownC) // we need this to support the tunnel sub command...
 	access.Init(graceShutdownC, Version)
@@ -94,16 +97,21 @@ func main() {
 	runApp(app, graceShutdownC)
 }
 
-func commands(version func(c *cli.Context)) []*cli.Command {
+func commands(version func(c *cli.Context), bInfo *cliutil.BuildInfo) []*cli.Command {
 	cmds := []*cli.Command{
 		{
 			Name:   "update",
 			Action: cliutil.ConfiguredAction(updater.Update),
 			Usage:  "Update the agent if a new version exists",
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "channel",
+					Usage: "specify the release channel to update from, stable or beta",
+					Value: updater.StableChannel,
+				},
 				&cli.BoolFlag{
 					Name:  "beta",
-					Usage: "specify if you wish to update to the latest beta version",
+					Usage: "specify if you wish to update to the latest beta version, same as --channel beta",
 				},
 				&cli.BoolFlag{
 					Name:   "force",
@@ -117,21 +125,52 @@ func commands(version func(c *cli.Context)) []*cli.Command {
 				},
 				&cli.StringFlag{
 					Name:   "version",
-					Usage:  "specify a version you wish to upgrade or downgrade to",
+					Usage:  "specify a version you wish to upgrade or downgrade to, to pin cloudflared to it",
 					Hidden: false,
 				},
+				&cli.BoolFlag{
+					Name:  "rollback",
+					Usage: "restore the version that was installed before the last update, which is kept next to the binary",
+				},
+				&cli.BoolFlag{
+					Name:  "canary",
+					Usage: "run the new version in a validation mode that doesn't take traffic, and only install it if it passes",
+				},
 			},
 			Description: `Looks for a new version on the official download server.
 If a new version exists, updates the agent binary and quits.
 Otherwise, does nothing.
 
+The binary that an update replaces is kept next to the new one, with the .previous suffix, so that
+"cloudflared update --rollback" can restore it.
+
+With --canary, the new version first runs "cloudflared tunnel canary" with the same configuration file: it
+validates the ingress rules, reads the tunnel credentials, probes the origins and connects to the edge, without
+registering a connection that would take traffic. The update is only installed if these checks pass.
+
 To determine if an update happened in a script, check for error code 11.`,
 		},
 		{
 			Name: "version",
 			Action: func(c *cli.Context) (err error) {
-				version(c)
-				return nil
+				switch output := c.String("output"); output {
+				case "":
+					version(c)
+					return nil
+				case "json":
+					encoder := json.NewEncoder(c.App.Writer)
+					encoder.SetIndent("", "  ")
+					return encoder.Encode(bInfo)
+				default:
+					return cliutil.UsageError("unknown output format %q, the only valid option is 'json'", output)
+				}
+			},
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "Render the version, git commit, Go version, features and supported protocols using given `FORMAT`. The only valid option is 'json'",
+				},
 			},
 			Usage:       versionText,
 			Description: versionText,
@@ -141,6 +180,8 @@ To determine if an update happened in a script, check for error code 11.`,
 	cmds = append(cmds, proxydns.Command(false))
 	cmds = append(cmds, access.Commands()...)
 	cmds = append(cmds, tail.Command())
+	cmds = append(cmds, devedge.Command())
+	cmds = append(cmds, cliutil.CompletionCommand())
 	return cmds
 }
 
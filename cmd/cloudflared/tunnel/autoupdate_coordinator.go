@@ -0,0 +1,117 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+// updateSettlePeriod is how long a connector that just started is given to serve traffic before another
+// connector of the same tunnel may update.
+const updateSettlePeriod = 2 * time.Minute
+
+// activeClientsLister lists the connectors of a tunnel.
+type activeClientsLister interface {
+	ListActiveClients(tunnelID uuid.UUID) ([]*cfapi.ActiveClient, error)
+}
+
+// updateCoordinator lets the connectors of a tunnel auto-update one at a time. Each connector looks at the connectors
+// currently serving the tunnel, and only the outdated one with the smallest ID may update, once every other connector
+// has settled. Since a connector gets a new ID every time it starts, an updated connector never blocks the others.
+type updateCoordinator struct {
+	tunnelID    uuid.UUID
+	newClient   func() (activeClientsLister, error)
+	client      activeClientsLister
+	connectorID uuid.UUID
+	// lastSeen are the connectors seen at the previous check
+	lastSeen map[uuid.UUID]bool
+	now      func() time.Time
+	lock     sync.Mutex
+	log      *zerolog.Logger
+}
+
+func newUpdateCoordinator(c *cli.Context, tunnelID uuid.UUID, log *zerolog.Logger) *updateCoordinator {
+	return &updateCoordinator{
+		tunnelID: tunnelID,
+		newClient: func() (activeClientsLister, error) {
+			sc, err := newSubcommandContext(c)
+			if err != nil {
+				return nil, err
+			}
+			sc.log = log
+			return sc.client()
+		},
+		now: time.Now,
+		log: log,
+	}
+}
+
+// setConnectorID sets the ID of this connector, updates are deferred until it is known.
+func (uc *updateCoordinator) setConnectorID(connectorID uuid.UUID) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	uc.connectorID = connectorID
+}
+
+// MayUpdate implements updater.Coordinator.
+func (uc *updateCoordinator) MayUpdate(version string) (bool, error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+
+	if uc.connectorID == uuid.Nil {
+		return false, nil
+	}
+	if uc.client == nil {
+		client, err := uc.newClient()
+		if err != nil {
+			return false, errors.Wrap(err, "failed to coordinate the update with the other connectors")
+		}
+		uc.client = client
+	}
+	connectors, err := uc.client.ListActiveClients(uc.tunnelID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list the connectors of the tunnel")
+	}
+
+	seen := make(map[uuid.UUID]bool, len(connectors))
+	for _, connector := range connectors {
+		seen[connector.ID] = true
+	}
+	lastSeen := uc.lastSeen
+	uc.lastSeen = seen
+
+	if !seen[uc.connectorID] {
+		uc.log.Debug().Msg("Deferring update: this connector isn't registered with the edge yet")
+		return false, nil
+	}
+	for id := range lastSeen {
+		if !seen[id] {
+			uc.log.Debug().Msgf("Deferring update: connector %s just went away and may be updating", id)
+			return false, nil
+		}
+	}
+	var next uuid.UUID
+	for _, connector := range connectors {
+		if connector.ID != uc.connectorID && uc.now().Sub(connector.RunAt) < updateSettlePeriod {
+			uc.log.Debug().Msgf("Deferring update: connector %s just started", connector.ID)
+			return false, nil
+		}
+		if connector.Version == version {
+			continue
+		}
+		if next == uuid.Nil || connector.ID.String() < next.String() {
+			next = connector.ID
+		}
+	}
+	if next != uc.connectorID {
+		uc.log.Debug().Msgf("Deferring update: connector %s updates first", next)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,83 @@
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+type mockActiveClientsLister struct {
+	clients []*cfapi.ActiveClient
+}
+
+func (m *mockActiveClientsLister) ListActiveClients(uuid.UUID) ([]*cfapi.ActiveClient, error) {
+	return m.clients, nil
+}
+
+func TestUpdateCoordinator(t *testing.T) {
+	const newVersion = "2023.3.0"
+	now := time.Date(2023, time.March, 3, 12, 0, 0, 0, time.UTC)
+	settled := now.Add(-time.Hour)
+	first := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	second := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+
+	lister := &mockActiveClientsLister{
+		clients: []*cfapi.ActiveClient{
+			{ID: second, Version: "2023.2.0", RunAt: settled},
+			{ID: first, Version: "2023.2.0", RunAt: settled},
+		},
+	}
+	log := zerolog.Nop()
+	newCoordinator := func(connectorID uuid.UUID) *updateCoordinator {
+		return &updateCoordinator{
+			tunnelID:    uuid.New(),
+			client:      lister,
+			connectorID: connectorID,
+			now:         func() time.Time { return now },
+			log:         &log,
+		}
+	}
+	firstCoordinator := newCoordinator(first)
+	secondCoordinator := newCoordinator(second)
+
+	// Only the outdated connector with the smallest ID updates
+	mayUpdate, err := firstCoordinator.MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.True(t, mayUpdate)
+	mayUpdate, err = secondCoordinator.MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.False(t, mayUpdate)
+
+	// The first connector is restarting
+	lister.clients = lister.clients[:1]
+	mayUpdate, err = secondCoordinator.MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.False(t, mayUpdate)
+
+	// The first connector came back updated, with a new ID, and is settling
+	third := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	lister.clients = append(lister.clients, &cfapi.ActiveClient{ID: third, Version: newVersion, RunAt: now.Add(-time.Minute)})
+	mayUpdate, err = secondCoordinator.MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.False(t, mayUpdate)
+
+	// Once it settled, the second connector may update
+	lister.clients[1].RunAt = settled
+	mayUpdate, err = secondCoordinator.MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.True(t, mayUpdate)
+
+	// A connector that isn't registered yet waits
+	mayUpdate, err = newCoordinator(uuid.New()).MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.False(t, mayUpdate)
+	mayUpdate, err = newCoordinator(uuid.Nil).MayUpdate(newVersion)
+	require.NoError(t, err)
+	assert.False(t, mayUpdate)
+}
@@ -0,0 +1,63 @@
+package tunnel
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// defaultBulkConcurrency bounds how many Tunnelstore API calls an operation on many items makes at once.
+const defaultBulkConcurrency = 8
+
+// bulkError reports every item of a bulk operation that failed, instead of only the first one.
+type bulkError struct {
+	total  int
+	errors []error
+}
+
+func (e *bulkError) Error() string {
+	if len(e.errors) == 1 {
+		return e.errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d operations failed:", len(e.errors), e.total)
+	for _, err := range e.errors {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// runBulk calls op with the indexes 0 to n-1, with at most concurrency calls in flight. Every call runs even if some
+// fail; the errors are returned together, in index order.
+func runBulk(n, concurrency int, op func(i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, n)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = op(i)
+		}(i)
+	}
+	wg.Wait()
+
+	bulkErr := &bulkError{total: n}
+	for _, err := range errs {
+		if err != nil {
+			bulkErr.errors = append(bulkErr.errors, err)
+		}
+	}
+	if len(bulkErr.errors) == 0 {
+		return nil
+	}
+	return bulkErr
+}
@@ -0,0 +1,47 @@
+package tunnel
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBulk(t *testing.T) {
+	var inFlight, maxInFlight int32
+	calls := make([]bool, 20)
+	err := runBulk(len(calls), 3, func(i int) error {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		calls[i] = true
+		if i%7 == 0 {
+			return fmt.Errorf("item %d failed", i)
+		}
+		return nil
+	})
+
+	for i, called := range calls {
+		assert.True(t, called, "item %d wasn't processed", i)
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(3))
+	require.Error(t, err)
+	assert.Equal(t, "3 of 20 operations failed:\n  - item 0 failed\n  - item 7 failed\n  - item 14 failed", err.Error())
+
+	assert.NoError(t, runBulk(5, defaultBulkConcurrency, func(int) error { return nil }))
+	assert.EqualError(t, runBulk(5, 0, func(i int) error {
+		if i == 2 {
+			return fmt.Errorf("item %d failed", i)
+		}
+		return nil
+	}), "item 2 failed")
+}
@@ -0,0 +1,90 @@
+package tunnel
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
+	"github.com/cloudflare/cloudflared/tlsconfig"
+)
+
+func buildCanaryCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "canary",
+		Action:    cliutil.ConfiguredAction(canaryCommand),
+		Usage:     "Check that this binary can run the tunnel, without taking traffic",
+		UsageText: "cloudflared tunnel [tunnel command options] canary",
+		Description: `Runs the checks that "cloudflared update --canary" makes a new version pass before installing it:
+it validates the ingress rules, reads the tunnel credentials, probes the origins and connects to the edge. The
+connection stops after the TLS handshake, because a registered connection would take traffic.`,
+		Hidden: true,
+	}
+}
+
+func canaryCommand(c *cli.Context) error {
+	log := logger.CreateLoggerFromContext(c, logger.EnableTerminalLog)
+	conf := config.GetConfiguration()
+
+	ing, err := ingress.ParseIngressFromConfigAndCLI(conf, c, log)
+	if err != nil {
+		return errors.Wrap(err, "invalid ingress rules")
+	}
+	log.Info().Msg("Canary: ingress rules are valid")
+
+	if tunnelRef := conf.TunnelID; tunnelRef != "" {
+		tunnelID, err := uuid.Parse(tunnelRef)
+		if err != nil {
+			return errors.Wrapf(err, "invalid tunnel ID %s", tunnelRef)
+		}
+		sc, err := newSubcommandContext(c)
+		if err != nil {
+			return err
+		}
+		if _, err := sc.findCredentials(tunnelID); err != nil {
+			return err
+		}
+		log.Info().Msg("Canary: tunnel credentials are readable")
+	}
+
+	shutdownC := make(chan struct{})
+	defer close(shutdownC)
+	if err := ing.StartOrigins(log, shutdownC); err != nil {
+		return errors.Wrap(err, "failed to start origin")
+	}
+	if err := ing.ProbeOrigins(); err != nil {
+		return err
+	}
+	log.Info().Msg("Canary: origins are reachable")
+
+	edgeIPVersion, err := parseConfigIPVersion(c.String("edge-ip-version"))
+	if err != nil {
+		return err
+	}
+	edge, err := edgediscovery.ResolveEdge(log, c.String("region"), edgeIPVersion)
+	if err != nil {
+		return errors.Wrap(err, "failed to discover the edge")
+	}
+	addr, err := edge.GetAddrForRPC()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := tlsconfig.CreateTunnelConfig(c, connection.HTTP2.TLSSettings().ServerName)
+	if err != nil {
+		return errors.Wrap(err, "unable to create TLS config to connect with edge")
+	}
+	edgeConn, err := edgediscovery.DialEdge(context.Background(), c.Duration("dial-edge-timeout"), tlsConfig, addr.TCP, nil, edgeSocketOptions(c))
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the edge")
+	}
+	_ = edgeConn.Close()
+	log.Info().Msgf("Canary: connected to the edge at %s", addr.TCP)
+	return nil
+}
@@ -22,7 +22,9 @@ import (
 	"github.com/urfave/cli/v2"
 	"github.com/urfave/cli/v2/altsrc"
 
+	"github.com/cloudflare/cloudflared/alerts"
 	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cfio"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/proxydns"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/updater"
@@ -31,16 +33,20 @@ import (
 	"github.com/cloudflare/cloudflared/credentials"
 	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/features"
+	"github.com/cloudflare/cloudflared/hooks"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/limits"
 	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/management"
 	"github.com/cloudflare/cloudflared/metrics"
 	"github.com/cloudflare/cloudflared/orchestration"
+	"github.com/cloudflare/cloudflared/recording"
 	"github.com/cloudflare/cloudflared/signal"
 	"github.com/cloudflare/cloudflared/supervisor"
 	"github.com/cloudflare/cloudflared/tlsconfig"
 	"github.com/cloudflare/cloudflared/tunneldns"
 	"github.com/cloudflare/cloudflared/validation"
+	"github.com/cloudflare/cloudflared/webhook"
 )
 
 const (
@@ -85,6 +91,112 @@ const (
 	// uiFlag is to enable launching cloudflared in interactive UI mode
 	uiFlag = "ui"
 
+	// recordTrafficFlag is the file HTTP exchanges proxied by the tunnel are recorded to
+	recordTrafficFlag = "record-traffic"
+
+	// recordTrafficMaxBodySizeFlag is how much of each request and response body is recorded
+	recordTrafficMaxBodySizeFlag = "record-traffic-max-body-size"
+
+	// webhookURLFlag are the endpoints notified about tunnel lifecycle events
+	webhookURLFlag = "webhook-url"
+
+	// webhookHeaderFlag are headers added to webhook notifications
+	webhookHeaderFlag = "webhook-header"
+
+	// webhookEventFlag limits which events webhooks are notified about
+	webhookEventFlag = "webhook-event"
+
+	// alertConnectionsDownFlag and the other alert-* flags set the thresholds of alerts and where they're sent
+	alertConnectionsDownFlag      = "alert-connections-down-after"
+	alertOriginErrorRateFlag      = "alert-origin-error-rate"
+	alertRegistrationFailuresFlag = "alert-registration-failures"
+	alertCooldownFlag             = "alert-cooldown"
+	alertWebhookURLFlag           = "alert-webhook-url"
+
+	// onConnectedFlag is the command run when a connection to the edge is registered
+	onConnectedFlag = "on-connected"
+
+	// onDisconnectedFlag is the command run when a registered connection to the edge is lost
+	onDisconnectedFlag = "on-disconnected"
+
+	// onURLAssignedFlag is the command run when a quick tunnel is given its URL
+	onURLAssignedFlag = "on-url-assigned"
+
+	// memoryBudgetFlag bounds the memory of the buffers used to proxy data, in megabytes
+	memoryBudgetFlag = "memory-budget"
+
+	// memoryLimitFlag is the Go runtime's soft memory limit, in megabytes
+	memoryLimitFlag = "memory-limit"
+
+	// retryInitialDelayFlag, retryMultiplierFlag, retryMaxDelayFlag and retryJitterFlag tune the backoff between
+	// connection retries
+	retryInitialDelayFlag = "retry-initial-delay"
+	retryMultiplierFlag   = "retry-multiplier"
+	retryMaxDelayFlag     = "retry-max-delay"
+	retryJitterFlag       = "retry-jitter"
+
+	// protocolFallbackFlag, protocolFallbackRetriesFlag and protocolFallbackCooldownFlag are the policy connections
+	// follow when they keep failing with their protocol
+	protocolFallbackFlag         = "protocol-fallback"
+	protocolFallbackRetriesFlag  = "protocol-fallback-retries"
+	protocolFallbackCooldownFlag = "protocol-fallback-cooldown"
+
+	// haProtocolMixFlag assigns protocols to the HA connections, e.g. quic:2,http2:2
+	haProtocolMixFlag = "ha-protocol-mix"
+
+	// connectionWatchdogFlag is how long a connection to the edge can stay silent before it's re-established
+	connectionWatchdogFlag = "connection-watchdog-timeout"
+
+	// quicIdleTimeoutFlag and the other quic-* flags tune the transport parameters of QUIC connections to the edge
+	quicIdleTimeoutFlag         = "quic-idle-timeout"
+	quicKeepAlivePeriodFlag     = "quic-keepalive-period"
+	quicInitialStreamWindowFlag = "quic-initial-stream-window"
+	quicMaxStreamWindowFlag     = "quic-max-stream-window"
+	quicMaxIncomingStreamsFlag  = "quic-max-incoming-streams"
+
+	// clockSkewToleranceFlag is how much the system clock may be off for the edge's certificate to be accepted
+	clockSkewToleranceFlag = "clock-skew-tolerance"
+
+	// edgeTLSMinVersionFlag and the other edge-tls flags restrict the TLS connections to the edge
+	edgeTLSMinVersionFlag   = "edge-tls-min-version"
+	edgeTLSCipherSuitesFlag = "edge-tls-cipher-suites"
+	edgeTLSCurvesFlag       = "edge-tls-curves"
+
+	// stateDumpFileFlag is where the state is dumped on SIGUSR1
+	stateDumpFileFlag = "state-dump-file"
+
+	// cleanupStaleConnectionsFlag removes the connections of the previous run when the edge refuses to register
+	// new ones as duplicates
+	cleanupStaleConnectionsFlag = "cleanup-stale-connections"
+
+	// shedLoadLatencyFlag is the scheduler latency above which new requests are rejected
+	shedLoadLatencyFlag = "shed-load-latency"
+
+	// shedLoadMemoryFlag is the memory, in megabytes, above which new requests are rejected
+	shedLoadMemoryFlag = "shed-load-memory"
+
+	// warmupOriginsFlag prepares the connections to origins at startup instead of on their first request
+	warmupOriginsFlag = "warmup-origins"
+
+	// probeOriginsOnUpdateFlag rejects configuration updates whose origins are unreachable
+	probeOriginsOnUpdateFlag = "probe-origins-on-update"
+
+	// dockerLabelsFlag generates ingress rules from the labels of the local Docker containers
+	dockerLabelsFlag = "docker-labels"
+
+	// dockerHostFlag is the address of the Docker daemon followed for dockerLabelsFlag
+	dockerHostFlag = "docker-host"
+
+	// featureFlagsURLFlag is where the feature flags are fetched from in the background at startup
+	featureFlagsURLFlag = "feature-flags-url"
+
+	// edgeTCPNoDelayFlag and the other edge-tcp flags tune the sockets of TCP connections to the edge
+	edgeTCPNoDelayFlag           = "edge-tcp-nodelay"
+	edgeTCPKeepAliveIntervalFlag = "edge-tcp-keepalive-interval"
+	edgeTCPKeepAliveCountFlag    = "edge-tcp-keepalive-count"
+	edgeTCPSendBufferFlag        = "edge-tcp-send-buffer"
+	edgeTCPReceiveBufferFlag     = "edge-tcp-receive-buffer"
+
 	LogFieldCommand             = "command"
 	LogFieldExpandedPath        = "expandedPath"
 	LogFieldPIDPathname         = "pidPathname"
@@ -119,6 +231,7 @@ func Flags() []cli.Flag {
 func Commands() []*cli.Command {
 	subcommands := []*cli.Command{
 		buildLoginSubcommand(false),
+		buildInitCommand(),
 		buildCreateCommand(),
 		buildRouteCommand(),
 		buildVirtualNetworkSubcommand(false),
@@ -126,9 +239,13 @@ func Commands() []*cli.Command {
 		buildListCommand(),
 		buildInfoCommand(),
 		buildIngressSubcommand(),
+		buildConfigCommand(),
 		buildDeleteCommand(),
 		buildCleanupCommand(),
+		buildRotateSecretCommand(),
+		buildCredentialsCommand(),
 		buildTokenCommand(),
+		buildCanaryCommand(),
 		// for compatibility, allow following as tunnel subcommands
 		proxydns.Command(true),
 		cliutil.RemovedCommand("db-connect"),
@@ -174,7 +291,9 @@ For exposing local TCP/UDP services by IP to your privately connected users, che
 
 	$ cloudflared tunnel route ip --help
 
-See https://developers.cloudflare.com/cloudflare-one/connections/connect-apps/install-and-setup/tunnel-guide/ for more info.`,
+See https://developers.cloudflare.com/cloudflare-one/connections/connect-apps/install-and-setup/tunnel-guide/ for more info.
+
+` + exitCodesHelp,
 		Subcommands: subcommands,
 		Flags:       tunnelFlags(false),
 	}
@@ -237,6 +356,13 @@ func TunnelCommand(c *cli.Context) error {
 
 func Init(info *cliutil.BuildInfo, gracefulShutdown chan struct{}) {
 	buildInfo, graceShutdownC = info, gracefulShutdown
+	for _, protocol := range connection.ProtocolList {
+		info.Protocols = append(info.Protocols, protocol.String())
+		if protocol == connection.QUIC {
+			info.Features = append(info.Features, cliutil.FeatureQUIC)
+		}
+	}
+	registerFlagCompletions()
 }
 
 // runAdhocNamedTunnel create, route and run a named tunnel in one command
@@ -335,6 +461,16 @@ func StartServer(
 	info.Log(log)
 	logClientOptions(c, log)
 
+	if budget := c.Int(memoryBudgetFlag); budget > 0 {
+		cfio.SetMemoryBudget(int64(budget) * 1024 * 1024)
+	}
+	limits.ApplyContainerLimits(log)
+	// 0 leaves the memory limit unset, rather than have the garbage collector run all the time
+	if memoryLimitMB := c.Int(memoryLimitFlag); memoryLimitMB > 0 {
+		limits.SetMemoryLimit(int64(memoryLimitMB) * 1024 * 1024)
+		log.Info().Msgf("Set the Go memory limit to %d MB", memoryLimitMB)
+	}
+
 	// this context drives the server, when it's cancelled tunnel and all other components (origins, dns, etc...) should stop
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -358,12 +494,22 @@ func StartServer(
 		go writePidFile(connectedSignal, c.String("pidfile"), log)
 	}
 
+	updatePolicy, err := parseUpdatePolicy(c)
+	if err != nil {
+		return err
+	}
+	var coordinator *updateCoordinator
+	if c.Bool("autoupdate-coordinate") && namedTunnel != nil {
+		coordinator = newUpdateCoordinator(c, namedTunnel.Credentials.TunnelID, log)
+		updatePolicy.Coordinator = coordinator
+	}
+
 	// update needs to be after DNS proxy is up to resolve equinox server address
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		autoupdater := updater.NewAutoUpdater(
-			c.Bool("no-autoupdate"), c.Duration("autoupdate-freq"), &listeners, log,
+			c.Bool("no-autoupdate"), c.Duration("autoupdate-freq"), updatePolicy, &listeners, log,
 		)
 		errC <- autoupdater.Run(ctx)
 	}()
@@ -372,12 +518,19 @@ func StartServer(
 	if dnsProxyStandAlone(c, namedTunnel) {
 		connectedSignal.Notify()
 		// no grace period, handle SIGINT/SIGTERM immediately
-		return waitToShutdown(&wg, cancel, errC, graceShutdownC, 0, log)
+		return waitToShutdown(&wg, cancel, errC, graceShutdownC, 0, nil, log)
 	}
 
 	logTransport := logger.CreateTransportLoggerFromContext(c, logger.EnableTerminalLog)
 
 	observer := connection.NewObserver(log, logTransport)
+	hooksConfig, err := parseHooksConfig(c)
+	if err != nil {
+		return err
+	}
+	if !hooksConfig.IsEmpty() {
+		observer.RegisterSink(hooks.NewRunner(ctx, hooksConfig, log))
+	}
 
 	// Send Quick Tunnel URL to UI if applicable
 	var quickTunnelURL string
@@ -393,6 +546,24 @@ func StartServer(
 		log.Err(err).Msg("Couldn't start tunnel")
 		return err
 	}
+	if recorder := orchestratorConfig.Recorder; recorder != nil {
+		// Deferred to after waitToShutdown, once the requests in flight are done
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Err(err).Msg("Failed to close the traffic recording")
+			}
+		}()
+	}
+	if tunnelConfig.NeedPQ {
+		info.Features = append(info.Features, cliutil.FeaturePostQuantum)
+	}
+	drainer := connection.NewDrainer()
+	tunnelConfig.Drainer = drainer
+	orchestratorConfig.Drainer = drainer
+	if shedder := newLoadShedder(c, log); shedder.Enabled() {
+		go shedder.Run(ctx)
+		orchestratorConfig.LoadShedder = shedder
+	}
 	var clientID uuid.UUID
 	if tunnelConfig.NamedTunnel != nil {
 		clientID, err = uuid.FromBytes(tunnelConfig.NamedTunnel.Client.ClientID)
@@ -401,6 +572,24 @@ func StartServer(
 			clientID = uuid.Nil
 		}
 	}
+	if coordinator != nil {
+		coordinator.setConnectorID(clientID)
+	}
+
+	if (c.Bool(cleanupStaleConnectionsFlag) || c.Bool(cleanupOnStartFlag.Name)) && namedTunnel != nil {
+		cleanup, err := newStaleConnectionsCleanup(c, namedTunnel.Credentials.TunnelID, clientID, log)
+		if err != nil {
+			log.Err(err).Msg("Stale connections won't be cleaned up")
+		}
+		if c.Bool(cleanupOnStartFlag.Name) && cleanup != nil {
+			if err := cleanup(); err != nil {
+				log.Err(err).Msg("Failed to clean up the connections of the previous run")
+			}
+		}
+		if c.Bool(cleanupStaleConnectionsFlag) {
+			tunnelConfig.CleanupStaleConnections = cleanup
+		}
+	}
 
 	internalRules := []ingress.Rule{}
 	if features.Contains(features.FeatureManagementLogs) {
@@ -421,10 +610,60 @@ func StartServer(
 		)
 		internalRules = []ingress.Rule{ingress.NewManagementRule(mgmt)}
 	}
+	if c.IsSet(webhookURLFlag) {
+		webhookConfig, err := parseWebhookConfig(c)
+		if err != nil {
+			return err
+		}
+		observer.RegisterSink(webhook.NewNotifier(ctx, webhookConfig, clientID, log))
+	}
+
+	alertsConfig, err := parseAlertsConfig(c)
+	if err != nil {
+		return err
+	}
+	if alertsConfig.Enabled() {
+		observer.RegisterSink(alerts.New(ctx, alertsConfig, clientID, log))
+	}
+
+	remoteConfig := c.Bool(remoteConfigFlag.Name) && namedTunnel != nil
+	if remoteConfig {
+		cachePath, err := remoteConfigCachePath(c, namedTunnel.Credentials, log)
+		if err != nil {
+			log.Err(err).Msg("The remote configuration won't be cached")
+		}
+		orchestratorConfig.RemoteConfigCache = cachePath
+	}
+
 	orchestrator, err := orchestration.NewOrchestrator(ctx, orchestratorConfig, tunnelConfig.Tags, internalRules, tunnelConfig.Log)
 	if err != nil {
 		return err
 	}
+	if remoteConfig {
+		loadRemoteConfig(c, orchestrator, namedTunnel.Credentials, log)
+	}
+	// Report the fingerprint of the configuration when registering, so that drift can be spotted among the connectors
+	log.Info().Msgf("Configuration fingerprint: %s", orchestrator.ConfigFingerprint())
+	tunnelConfig.ConfigFingerprint = orchestrator.ConfigFingerprint
+
+	// Only the ingress rules of the configuration file can be reloaded, not the ones of the command line
+	if len(config.GetConfiguration().Ingress) > 0 {
+		if err := startConfigReload(ctx, c, orchestrator, log); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool(dockerLabelsFlag) {
+		if err := startDockerLabels(ctx, c, orchestrator, namedTunnel, log); err != nil {
+			return err
+		}
+	}
+
+	stateDump := &metrics.StateDump{
+		Connections: orchestrator.ConnTracker(),
+		Drainer:     drainer,
+	}
+	go dumpStateOnSignal(ctx, stateDump, c.String(stateDumpFileFlag), log)
 
 	metricsListener, err := listeners.Listen("tcp", c.String("metrics"))
 	if err != nil {
@@ -441,6 +680,9 @@ func StartServer(
 			ReadyServer:         readinessServer,
 			QuickTunnelHostname: quickTunnelURL,
 			Orchestrator:        orchestrator,
+			Drainer:             drainer,
+			StateDump:           stateDump,
+			BuildInfo:           info,
 		}
 		errC <- metrics.ServeMetrics(metricsListener, ctx, metricsConfig, log)
 	}()
@@ -451,62 +693,45 @@ func StartServer(
 		go stdinControl(reconnectCh, log)
 	}
 
+	// The connections to the edge are stopped before the other components, so they can unregister while the metrics
+	// server still reports their state
+	tunnelCtx, cancelTunnel := context.WithCancel(ctx)
+	defer cancelTunnel()
+	tunnel := &tunnelShutdown{
+		drainer:     drainer,
+		unregisterC: make(chan struct{}),
+		stoppedC:    make(chan struct{}),
+		cancel:      cancelTunnel,
+	}
 	wg.Add(1)
 	go func() {
 		defer func() {
 			wg.Done()
 			log.Info().Msg("Tunnel server stopped")
 		}()
-		errC <- supervisor.StartTunnelDaemon(ctx, tunnelConfig, orchestrator, connectedSignal, reconnectCh, graceShutdownC)
+		err := supervisor.StartTunnelDaemon(tunnelCtx, tunnelConfig, orchestrator, connectedSignal, reconnectCh, tunnel.unregisterC)
+		close(tunnel.stoppedC)
+		errC <- tunnelExitError(err)
 	}()
 
 	gracePeriod, err := gracePeriod(c)
 	if err != nil {
 		return err
 	}
-	return waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, log)
+	return waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, tunnel, log)
 }
 
-func waitToShutdown(wg *sync.WaitGroup,
-	cancelServerContext func(),
-	errC <-chan error,
-	graceShutdownC <-chan struct{},
-	gracePeriod time.Duration,
-	log *zerolog.Logger,
-) error {
-	var err error
-	select {
-	case err = <-errC:
-		log.Error().Err(err).Msg("Initiating shutdown")
-	case <-graceShutdownC:
-		log.Debug().Msg("Graceful shutdown signalled")
-		if gracePeriod > 0 {
-			// wait for either grace period or service termination
-			select {
-			case <-time.Tick(gracePeriod):
-			case <-errC:
-			}
-		}
+// newLoadShedder creates the LoadShedder configured by the shed-load flags. Unless the memory threshold is set, it's
+// derived from the Go runtime's soft memory limit, which follows the container's memory limit by default.
+func newLoadShedder(c *cli.Context, log *zerolog.Logger) *limits.LoadShedder {
+	maxMemory := int64(c.Int(shedLoadMemoryFlag)) * 1024 * 1024
+	if memoryLimit := limits.MemoryLimit(); !c.IsSet(shedLoadMemoryFlag) && memoryLimit > 0 {
+		maxMemory = limits.ShedMemoryThreshold(memoryLimit)
 	}
-
-	// stop server context
-	cancelServerContext()
-
-	// Wait for clean exit, discarding all errors while we wait
-	stopDiscarding := make(chan struct{})
-	go func() {
-		for {
-			select {
-			case <-errC: // ignore
-			case <-stopDiscarding:
-				return
-			}
-		}
-	}()
-	wg.Wait()
-	close(stopDiscarding)
-
-	return err
+	return limits.NewLoadShedder(limits.ShedderConfig{
+		MaxSchedulerLatency: c.Duration(shedLoadLatencyFlag),
+		MaxMemoryBytes:      maxMemory,
+	}, log)
 }
 
 func notifySystemd(waitForSignal *signal.Signal) {
@@ -592,6 +817,73 @@ func tunnelFlags(shouldHide bool) []cli.Flag {
 			EnvVars: []string{"TUNNEL_EDGE_BIND_ADDRESS"},
 			Hidden:  false,
 		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    shedLoadLatencyFlag,
+			Usage:   "Reject new requests with 503 while goroutines are scheduled later than this on average, which happens when cloudflared is starved of CPU. 0 disables it.",
+			EnvVars: []string{"TUNNEL_SHED_LOAD_LATENCY"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    shedLoadMemoryFlag,
+			Usage:   "Reject new requests with 503 while cloudflared uses more megabytes of memory than this. Defaults to 5% above the Go memory limit, if there is one. 0 disables it.",
+			EnvVars: []string{"TUNNEL_SHED_LOAD_MEMORY"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    warmupOriginsFlag,
+			Usage:   "Prepare the connections to every origin when the ingress rules are loaded, failing if an origin is misconfigured. By default that's deferred to the first request of each origin, so that cloudflared connects to the edge quickly.",
+			EnvVars: []string{"TUNNEL_WARMUP_ORIGINS"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    probeOriginsOnUpdateFlag,
+			Usage:   "Check that the origins of a configuration update accept connections before applying it, and keep the current configuration if they don't.",
+			EnvVars: []string{"TUNNEL_PROBE_ORIGINS_ON_UPDATE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    dockerLabelsFlag,
+			Usage:   "Expose the running Docker containers labelled with cloudflared.hostname and cloudflared.port, and optionally cloudflared.scheme, after the ingress rules, before their catch-all rule. DNS routes are created for their hostnames, and the rules are removed when the containers stop.",
+			EnvVars: []string{"TUNNEL_DOCKER_LABELS"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    dockerHostFlag,
+			Usage:   "Address of the Docker daemon followed for --docker-labels, e.g. unix:///var/run/docker.sock or tcp://127.0.0.1:2375.",
+			EnvVars: []string{"DOCKER_HOST"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    edgeTCPNoDelayFlag,
+			Usage:   "Send small writes to the edge immediately instead of coalescing them. Only applies to the http2 protocol.",
+			Value:   true,
+			EnvVars: []string{"TUNNEL_EDGE_TCP_NODELAY"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    edgeTCPKeepAliveIntervalFlag,
+			Usage:   "Time between TCP keepalive probes of idle connections to the edge. Only applies to the http2 protocol, on Linux, macOS, FreeBSD, NetBSD and DragonFly BSD.",
+			EnvVars: []string{"TUNNEL_EDGE_TCP_KEEPALIVE_INTERVAL"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    edgeTCPKeepAliveCountFlag,
+			Usage:   "Number of unanswered TCP keepalive probes after which a connection to the edge is dropped. Only applies to the http2 protocol, on Linux, macOS, FreeBSD, NetBSD and DragonFly BSD.",
+			EnvVars: []string{"TUNNEL_EDGE_TCP_KEEPALIVE_COUNT"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    edgeTCPSendBufferFlag,
+			Usage:   "Size in bytes of the send buffer of TCP connections to the edge. Only applies to the http2 protocol.",
+			EnvVars: []string{"TUNNEL_EDGE_TCP_SEND_BUFFER"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    edgeTCPReceiveBufferFlag,
+			Usage:   "Size in bytes of the receive buffer of TCP connections to the edge. Only applies to the http2 protocol.",
+			EnvVars: []string{"TUNNEL_EDGE_TCP_RECEIVE_BUFFER"},
+			Hidden:  shouldHide,
+		}),
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:    tlsconfig.CaCertFlag,
 			Usage:   "Certificate Authority authenticating connections with Cloudflare's edge network.",
@@ -681,11 +973,98 @@ func tunnelFlags(shouldHide bool) []cli.Flag {
 			EnvVars: []string{"TUNNEL_RETRIES"},
 			Hidden:  shouldHide,
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    protocolFallbackFlag,
+			Usage:   "Protocols to connect to the edge with, in order: connections start with the first one, and fall back to the next one when they keep failing, e.g. quic,http2. It replaces the fallback that --protocol auto picks.",
+			EnvVars: []string{"TUNNEL_PROTOCOL_FALLBACK"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    protocolFallbackRetriesFlag,
+			Usage:   "Number of retries with a protocol before a connection falls back to the next one. The last protocol is retried up to --retries, which is also the default.",
+			EnvVars: []string{"TUNNEL_PROTOCOL_FALLBACK_RETRIES"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    protocolFallbackCooldownFlag,
+			Usage:   "How long a connection stays on a fallback protocol before it tries the preferred protocol again, the next time it reconnects. 0 means it stays on the fallback protocol.",
+			EnvVars: []string{"TUNNEL_PROTOCOL_FALLBACK_COOLDOWN"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    retryInitialDelayFlag,
+			Value:   time.Second,
+			Usage:   "Initial delay before retrying a connection. It grows by --retry-multiplier with each retry, up to --retries times.",
+			EnvVars: []string{"TUNNEL_RETRY_INITIAL_DELAY"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    retryMultiplierFlag,
+			Value:   2,
+			Usage:   "Factor by which the delay before retrying a connection grows with each retry.",
+			EnvVars: []string{"TUNNEL_RETRY_MULTIPLIER"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    retryMaxDelayFlag,
+			Usage:   "Maximum delay before retrying a connection. 0 means it's only bounded by --retries.",
+			EnvVars: []string{"TUNNEL_RETRY_MAX_DELAY"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    retryJitterFlag,
+			Value:   1,
+			Usage:   "Share of the delay before retrying a connection that is random, from 0 to 1, so that connections that failed at once don't retry at once.",
+			EnvVars: []string{"TUNNEL_RETRY_JITTER"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    connectionWatchdogFlag,
+			Usage:   "Re-establish a connection to the edge when nothing was received on it for this long, so that connections dropped by a NAT or firewall don't linger until requests fail. Over quic, the edge acknowledges keepalive pings every second. Over http2, cloudflared pings the edge once a connection has been quiet for half of it. 0 disables it.",
+			EnvVars: []string{"TUNNEL_CONNECTION_WATCHDOG_TIMEOUT"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    quicIdleTimeoutFlag,
+			Usage:   "Close a QUIC connection to the edge when nothing was received on it for this long. Links with long round trips, like satellite links, may need more than the default of 5s.",
+			EnvVars: []string{"TUNNEL_QUIC_IDLE_TIMEOUT"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    quicKeepAlivePeriodFlag,
+			Usage:   "How often QUIC connections to the edge are pinged to keep them alive. It must be shorter than --quic-idle-timeout. The default is 1s.",
+			EnvVars: []string{"TUNNEL_QUIC_KEEPALIVE_PERIOD"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    quicInitialStreamWindowFlag,
+			Usage:   "Initial flow control window, in bytes, of the streams of QUIC connections to the edge. The default is 512KB.",
+			EnvVars: []string{"TUNNEL_QUIC_INITIAL_STREAM_WINDOW"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    quicMaxStreamWindowFlag,
+			Usage:   "Maximum flow control window, in bytes, that the streams of QUIC connections to the edge grow to. Links with a large bandwidth-delay product need a window of at least bandwidth times round trip time to use their bandwidth. The default is 6MB.",
+			EnvVars: []string{"TUNNEL_QUIC_MAX_STREAM_WINDOW"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    quicMaxIncomingStreamsFlag,
+			Usage:   "Maximum number of streams the edge may open at once on a QUIC connection. It's unlimited by default.",
+			EnvVars: []string{"TUNNEL_QUIC_MAX_INCOMING_STREAMS"},
+			Hidden:  shouldHide,
+		}),
 		altsrc.NewIntFlag(&cli.IntFlag{
 			Name:   haConnectionsFlag,
 			Value:  4,
 			Hidden: true,
 		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    haProtocolMixFlag,
+			Usage:   "Run the HA connections with a mix of protocols, given as protocol:count entries, e.g. quic:2,http2:2, so that the tunnel stays up on networks that block UDP on some paths. Connections keep their protocol, except to fall back when it keeps failing.",
+			EnvVars: []string{"TUNNEL_HA_PROTOCOL_MIX"},
+			Hidden:  shouldHide,
+		}),
 		altsrc.NewDurationFlag(&cli.DurationFlag{
 			Name:   udpUnregisterSessionTimeoutFlag,
 			Value:  5 * time.Second,
@@ -698,7 +1077,7 @@ func tunnelFlags(shouldHide bool) []cli.Flag {
 		}),
 		altsrc.NewDurationFlag(&cli.DurationFlag{
 			Name:    "grace-period",
-			Usage:   "When cloudflared receives SIGINT/SIGTERM it will stop accepting new requests, wait for in-progress requests to terminate, then shutdown. Waiting for in-progress requests will timeout after this grace period, or when a second SIGTERM/SIGINT is received.",
+			Usage:   "When cloudflared receives SIGINT/SIGTERM it will reject new requests and sessions, wait for in-progress ones to terminate, unregister its connections, then shutdown. Waiting for in-progress requests will timeout after this grace period, or when a second SIGTERM/SIGINT is received, which exits immediately. The drain progress is served on the metrics server at /drain.",
 			Value:   time.Second * 30,
 			EnvVars: []string{"TUNNEL_GRACE_PERIOD"},
 			Hidden:  shouldHide,
@@ -764,6 +1143,145 @@ func tunnelFlags(shouldHide bool) []cli.Flag {
 			EnvVars: []string{"TUNNEL_POST_QUANTUM"},
 			Hidden:  FipsEnabled,
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    recordTrafficFlag,
+			Usage:   "Record sanitized HTTP requests and responses proxied by this tunnel to the given file, so they can be replayed with `cloudflared tunnel ingress replay`. The values of sensitive headers and of query parameters are redacted.",
+			EnvVars: []string{"TUNNEL_RECORD_TRAFFIC"},
+			Hidden:  shouldHide,
+		}),
+		// Note TUN-3758 , we use Int because UInt is not supported with altsrc
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    recordTrafficMaxBodySizeFlag,
+			Usage:   "Maximum number of bytes of each request and response body to record with --record-traffic.",
+			Value:   recording.DefaultMaxBodySize,
+			EnvVars: []string{"TUNNEL_RECORD_TRAFFIC_MAX_BODY_SIZE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    memoryLimitFlag,
+			Usage:   "Soft limit, in megabytes, of the memory used by cloudflared. The garbage collector works harder as it's approached, and new requests are shed once it's exceeded. Takes precedence over GOMEMLIMIT. Defaults to 90% of the container's memory limit, if there is one, which 0 keeps.",
+			EnvVars: []string{"TUNNEL_MEMORY_LIMIT"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    clockSkewToleranceFlag,
+			Value:   tlsconfig.DefaultClockSkewTolerance,
+			Usage:   "How much the system clock may be off for the certificate of the edge to still be accepted. Devices without a reliable real-time clock otherwise fail to connect until their clock is synchronized. Clock skew isn't tolerated by default.",
+			EnvVars: []string{"TUNNEL_CLOCK_SKEW_TOLERANCE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    edgeTLSMinVersionFlag,
+			Usage:   "Minimum TLS version of connections to the edge: 1.2 or 1.3. QUIC always uses TLS 1.3.",
+			EnvVars: []string{"TUNNEL_EDGE_TLS_MIN_VERSION"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    edgeTLSCipherSuitesFlag,
+			Usage:   "TLS 1.2 cipher suites allowed for connections to the edge, by their IANA name, e.g. TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384. TLS 1.3 cipher suites can't be restricted.",
+			EnvVars: []string{"TUNNEL_EDGE_TLS_CIPHER_SUITES"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    edgeTLSCurvesFlag,
+			Usage:   "Curves allowed for connections to the edge, in order of preference, among X25519, P256, P384 and P521.",
+			EnvVars: []string{"TUNNEL_EDGE_TLS_CURVES"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    featureFlagsURLFlag,
+			Usage:   "URL of a JSON object of feature flags, fetched in the background at startup, that enable or disable the features sent to the edge, e.g. {\"support_datagram_v2\": {\"enabled\": true, \"percentage\": 10}}. The percentage selects the same accounts on every connector. Connections registered before the flags are fetched use the default features.",
+			EnvVars: []string{"TUNNEL_FEATURE_FLAGS_URL"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    stateDumpFileFlag,
+			Usage:   "File where the state of cloudflared is dumped when it receives SIGUSR1: its connections, requests in flight, metrics and goroutine stacks. It's logged if this isn't set. The state dump is also served by the metrics server at /debug/state.",
+			EnvVars: []string{"TUNNEL_STATE_DUMP_FILE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    cleanupStaleConnectionsFlag,
+			Usage:   "When the edge keeps refusing to register connections because it still has the ones of a previous run of this connector, e.g. after a crash, delete them. It requires the origin certificate, next to which the connector ID of the last run is kept.",
+			EnvVars: []string{"TUNNEL_CLEANUP_STALE_CONNECTIONS"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    memoryBudgetFlag,
+			Usage:   "Maximum megabytes of memory used to buffer proxied data. Once it's reached, connections wait for buffers to be released instead of allocating more. 0 means unlimited.",
+			EnvVars: []string{"TUNNEL_MEMORY_BUDGET"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    webhookURLFlag,
+			Usage:   "Send a POST request to this URL when all connections are lost or re-established, the remote configuration is reloaded, or a connection fails to register. Multiple URLs may be specified.",
+			EnvVars: []string{"TUNNEL_WEBHOOK_URL"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    webhookHeaderFlag,
+			Usage:   "Header to add to webhook notifications, in format `NAME:VALUE`. Multiple headers may be specified.",
+			EnvVars: []string{"TUNNEL_WEBHOOK_HEADER"},
+			Hidden:  shouldHide,
+		}),
+		webhookSecretFlag,
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    alertConnectionsDownFlag,
+			Usage:   "Send an alert when no connection to Cloudflare's edge has been registered for this long. 0 disables it.",
+			EnvVars: []string{"TUNNEL_ALERT_CONNECTIONS_DOWN_AFTER"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewFloat64Flag(&cli.Float64Flag{
+			Name:    alertOriginErrorRateFlag,
+			Usage:   "Send an alert when this percentage of the origin requests of the last minute got a 5xx response or failed. 0 disables it.",
+			EnvVars: []string{"TUNNEL_ALERT_ORIGIN_ERROR_RATE"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:    alertRegistrationFailuresFlag,
+			Usage:   "Send an alert when this many connections in a row fail to register with Cloudflare's edge. 0 disables it.",
+			EnvVars: []string{"TUNNEL_ALERT_REGISTRATION_FAILURES"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    alertCooldownFlag,
+			Usage:   "Minimum time before an alert is sent again after it fired, so that a flapping condition doesn't page repeatedly.",
+			Value:   15 * time.Minute,
+			EnvVars: []string{"TUNNEL_ALERT_COOLDOWN"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    alertWebhookURLFlag,
+			Usage:   "Send alerts as JSON POST requests to this URL. Multiple URLs may be specified.",
+			EnvVars: []string{"TUNNEL_ALERT_WEBHOOK_URL"},
+			Hidden:  shouldHide,
+		}),
+		alertSlackURLFlag,
+		alertPagerDutyKeyFlag,
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    onConnectedFlag,
+			Usage:   "Command to run when a connection to Cloudflare's edge is registered. Arguments are split and quoted like in a shell, but the command isn't run by one. Details are passed in CLOUDFLARED_* environment variables.",
+			EnvVars: []string{"TUNNEL_ON_CONNECTED"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    onDisconnectedFlag,
+			Usage:   "Command to run when a registered connection to Cloudflare's edge is lost. Arguments are split and quoted like in a shell, but the command isn't run by one. Details are passed in CLOUDFLARED_* environment variables.",
+			EnvVars: []string{"TUNNEL_ON_DISCONNECTED"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    onURLAssignedFlag,
+			Usage:   "Command to run when a quick tunnel is assigned its URL. Arguments are split and quoted like in a shell, but the command isn't run by one. The URL is passed in the CLOUDFLARED_URL environment variable.",
+			EnvVars: []string{"TUNNEL_ON_URL_ASSIGNED"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:    webhookEventFlag,
+			Usage:   fmt.Sprintf("Only send webhook notifications for these events. Valid events are %v. Defaults to all events.", webhook.EventTypes),
+			EnvVars: []string{"TUNNEL_WEBHOOK_EVENT"},
+			Hidden:  shouldHide,
+		}),
 		selectProtocolFlag,
 		overwriteDNSFlag,
 	}...)
@@ -780,6 +1298,12 @@ func configureCloudflaredFlags(shouldHide bool) []cli.Flag {
 			Value:  config.FindDefaultConfigPath(),
 			Hidden: shouldHide,
 		},
+		&cli.StringFlag{
+			Name:    config.ConfigDirFlag,
+			Usage:   "Directory of YAML files of ingress rules, e.g. one per team, merged in lexical order into the ones of the config file, before its catch-all rule. A config file can also include such files with `include: [PATTERN...]`.",
+			EnvVars: []string{"TUNNEL_CONFIG_DIR"},
+			Hidden:  shouldHide,
+		},
 		altsrc.NewStringFlag(&cli.StringFlag{
 			Name:    credentials.OriginCertFlag,
 			Usage:   "Path to the certificate generated for your origin when you run cloudflared login.",
@@ -793,6 +1317,24 @@ func configureCloudflaredFlags(shouldHide bool) []cli.Flag {
 			Value:  updater.DefaultCheckUpdateFreq,
 			Hidden: shouldHide,
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:    "autoupdate-window",
+			Usage:   "Only apply updates within this maintenance window, given as a cron expression in local time (minute hour day-of-month month day-of-week), e.g. \"* 2-4 * * 6,0\" for 2 to 5 AM on weekends.",
+			EnvVars: []string{"TUNNEL_AUTOUPDATE_WINDOW"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewDurationFlag(&cli.DurationFlag{
+			Name:    "autoupdate-min-uptime",
+			Usage:   "Don't apply updates until cloudflared has been running for at least this long.",
+			EnvVars: []string{"TUNNEL_AUTOUPDATE_MIN_UPTIME"},
+			Hidden:  shouldHide,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:    "autoupdate-coordinate",
+			Usage:   "Update the connectors of a named tunnel one at a time, so they never all restart at once. Requires the origin certificate to list the connectors of the tunnel.",
+			EnvVars: []string{"TUNNEL_AUTOUPDATE_COORDINATE"},
+			Hidden:  shouldHide,
+		}),
 		altsrc.NewBoolFlag(&cli.BoolFlag{
 			Name:    "no-autoupdate",
 			Usage:   "Disable periodic check for updates, restarting the server with the new version.",
@@ -1,9 +1,16 @@
 package tunnel
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudflare/cloudflared/features"
 )
 
 func TestHostnameFromURI(t *testing.T) {
@@ -15,3 +22,39 @@ func TestHostnameFromURI(t *testing.T) {
 	assert.Equal(t, "", hostnameFromURI("trash"))
 	assert.Equal(t, "", hostnameFromURI("https://awesomesauce.com"))
 }
+
+func TestSecretFlagsAreRedacted(t *testing.T) {
+	for _, name := range []string{"token", "webhook-secret", "alert-slack-webhook-url", "alert-pagerduty-routing-key"} {
+		assert.True(t, isSecretFlag(name), name)
+	}
+	assert.True(t, isSecretEnvVar("TUNNEL_ALERT_SLACK_WEBHOOK_URL"))
+	assert.True(t, isSecretEnvVar("TUNNEL_ALERT_PAGERDUTY_ROUTING_KEY"))
+	assert.False(t, isSecretFlag("alert-webhook-url"))
+}
+
+func TestFetchFeatureFlagsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprintf(w, `{"%s": {"enabled": false}, "new_feature": {"enabled": true}}`, features.FeatureDatagramV2)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	log := zerolog.Nop()
+	clientFeatures := func(defaultFeatures []string) []string {
+		return append([]string{"cli_feature"}, defaultFeatures...)
+	}
+	current := fetchFeatureFlags(server.URL, "account", clientFeatures, &log)
+	assert.Equal(t, clientFeatures(features.DefaultFeatures), current(), "the default features are used until the flags are fetched")
+
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(clientFeatures(features.Apply(features.DefaultFeatures, map[string]bool{
+			features.FeatureDatagramV2: false,
+			"new_feature":              true,
+		}, &log)), current())
+	}, time.Second, 10*time.Millisecond)
+	assert.NotContains(t, current(), features.FeatureDatagramV2)
+	assert.Contains(t, current(), "new_feature")
+}
@@ -0,0 +1,115 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// tunnelNamesCachePath is where the names of the tunnels listed or created on this machine are kept, by tunnel ID, so
+// that shell completion can offer them without calling the API.
+var tunnelNamesCachePath = "~/.cloudflared/tunnel_names.json"
+
+func registerFlagCompletions() {
+	protocols := []string{connection.AutoSelectFlag}
+	for _, p := range connection.ProtocolList {
+		protocols = append(protocols, p.String())
+	}
+	cliutil.RegisterFlagValues(selectProtocolFlag.Name, protocols...)
+	cliutil.RegisterFlagValues("edge-ip-version", "4", "6", "auto")
+	cliutil.RegisterFlagValues(outputFormatFlag.Name, "json", "yaml")
+}
+
+// completeTunnels offers the names and IDs of the tunnels in the cache, and the IDs of the tunnels whose credentials
+// files are in the default directories.
+func completeTunnels(_ *cli.Context) []string {
+	candidates := make(map[string]bool)
+	for id, name := range readTunnelNamesCache() {
+		candidates[id] = true
+		candidates[name] = true
+	}
+	for _, dir := range config.DefaultConfigSearchDirectories() {
+		dir, err := homedir.Expand(dir)
+		if err != nil {
+			continue
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, file := range files {
+			id := strings.TrimSuffix(filepath.Base(file), ".json")
+			if _, err := uuid.Parse(id); err == nil {
+				candidates[id] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(candidates))
+	for candidate := range candidates {
+		sorted = append(sorted, candidate)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func readTunnelNamesCache() map[string]string {
+	names := make(map[string]string)
+	path, err := homedir.Expand(tunnelNamesCachePath)
+	if err != nil {
+		return names
+	}
+	if content, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(content, &names)
+	}
+	return names
+}
+
+// cacheTunnelNames adds tunnels to the cache, and removes the deleted ones. The cache is best effort, so errors are
+// ignored.
+func cacheTunnelNames(tunnels ...*cfapi.Tunnel) {
+	names := readTunnelNamesCache()
+	for _, tunnel := range tunnels {
+		if tunnel == nil {
+			// create doesn't return the tunnel when it prints it in another format
+			continue
+		}
+		if tunnel.DeletedAt.IsZero() {
+			names[tunnel.ID.String()] = tunnel.Name
+		} else {
+			delete(names, tunnel.ID.String())
+		}
+	}
+	writeTunnelNamesCache(names)
+}
+
+// forgetTunnelNames removes deleted tunnels from the cache.
+func forgetTunnelNames(ids []uuid.UUID) {
+	names := readTunnelNamesCache()
+	for _, id := range ids {
+		delete(names, id.String())
+	}
+	writeTunnelNamesCache(names)
+}
+
+func writeTunnelNamesCache(names map[string]string) {
+	path, err := homedir.Expand(tunnelNamesCachePath)
+	if err != nil {
+		return
+	}
+	content, err := json.Marshal(names)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, content, 0600)
+}
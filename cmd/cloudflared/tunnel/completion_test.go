@@ -0,0 +1,34 @@
+package tunnel
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+func TestTunnelNamesCache(t *testing.T) {
+	defer func(path string) { tunnelNamesCachePath = path }(tunnelNamesCachePath)
+	tunnelNamesCachePath = filepath.Join(t.TempDir(), "cloudflared", "tunnel_names.json")
+
+	web := &cfapi.Tunnel{ID: uuid.New(), Name: "web"}
+	ssh := &cfapi.Tunnel{ID: uuid.New(), Name: "ssh"}
+	cacheTunnelNames(web, ssh, nil)
+	require.Equal(t, map[string]string{web.ID.String(): "web", ssh.ID.String(): "ssh"}, readTunnelNamesCache())
+
+	// Deleted tunnels are removed
+	deleted := *ssh
+	deleted.DeletedAt = time.Now()
+	cacheTunnelNames(&deleted)
+	require.Equal(t, map[string]string{web.ID.String(): "web"}, readTunnelNamesCache())
+
+	forgetTunnelNames([]uuid.UUID{web.ID})
+	require.Empty(t, readTunnelNamesCache())
+
+	cacheTunnelNames(web)
+	require.Subset(t, completeTunnels(nil), []string{"web", web.ID.String()})
+}
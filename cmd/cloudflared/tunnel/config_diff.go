@@ -0,0 +1,249 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/orchestration"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
+)
+
+func buildConfigCommand() *cli.Command {
+	return &cli.Command{
+		Name:               "config",
+		Category:           "Tunnel",
+		Usage:              "Inspect the configuration of a tunnel",
+		UsageText:          "cloudflared tunnel [--config FILEPATH] config COMMAND [arguments...]",
+		Subcommands:        []*cli.Command{buildConfigDiffCommand()},
+		CustomHelpTemplate: commandHelpTemplate(),
+	}
+}
+
+func buildConfigDiffCommand() *cli.Command {
+	return &cli.Command{
+		Name:         "diff",
+		Action:       cliutil.ConfiguredAction(configDiffCommand),
+		BashComplete: cliutil.Complete(completeTunnels),
+		Usage:        "Compare the configuration file with the remote configuration of a tunnel",
+		UsageText:    "cloudflared tunnel [--config FILEPATH] config diff [--connector-metrics ADDRESS] [TUNNEL]",
+		ArgsUsage:    "[TUNNEL]",
+		Description: "Compares the ingress rules, origin request settings and WARP routing of the configuration file " +
+			"with the configuration stored remotely for TUNNEL, which requires the origin certificate, or with the " +
+			"configuration that a running connector serves at /config on the metrics server given with " +
+			"--connector-metrics. Values that only the remote configuration has are printed with '-', values that " +
+			"only the configuration file has with '+'. Secrets like the values of added headers and discovery tokens " +
+			"are redacted, so they aren't compared. It fails if the configurations differ.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  connectorMetricsFlagName,
+				Usage: "Address of the metrics server of a running connector to compare with, e.g. localhost:2000",
+			},
+		},
+	}
+}
+
+func configDiffCommand(c *cli.Context) error {
+	connector := c.String(connectorMetricsFlagName)
+	if c.NArg() > 1 || (c.NArg() == 1) == (connector != "") {
+		return cliutil.UsageError(`"cloudflared tunnel config diff" needs either the ID or name of a tunnel, or --%s.`, connectorMetricsFlagName)
+	}
+	conf, err := getConfiguration(c)
+	if err != nil {
+		return err
+	}
+	ing, err := ingress.ParseIngress(conf)
+	if err != nil {
+		return errors.Wrap(err, "Validation failed")
+	}
+	local, err := orchestration.RedactedConfigJSON(&ing, ingress.NewWarpRoutingConfig(&conf.WarpRouting))
+	if err != nil {
+		return err
+	}
+
+	var remote []byte
+	var remoteName string
+	if connector != "" {
+		remoteName = fmt.Sprintf("connector at %s", connector)
+		if remote, err = fetchConnectorConfig(connector); err != nil {
+			return err
+		}
+	} else {
+		sc, err := newSubcommandContext(c)
+		if err != nil {
+			return err
+		}
+		tunnelID, err := sc.findID(c.Args().First())
+		if err != nil {
+			return errors.Wrap(err, "error parsing tunnel ID")
+		}
+		client, err := sc.client()
+		if err != nil {
+			return err
+		}
+		configuration, err := client.GetTunnelConfiguration(tunnelID)
+		if err != nil {
+			return err
+		}
+		remoteName = fmt.Sprintf("remote configuration of tunnel %s, version %d", tunnelID, configuration.Version)
+		if remote, err = normalizeRemoteConfig(configuration.Config); err != nil {
+			return errors.Wrapf(err, "failed to parse the remote configuration of tunnel %s", tunnelID)
+		}
+	}
+
+	changes, err := diffConfigJSON(remote, local)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("The configuration file matches the %s\n", remoteName)
+		return nil
+	}
+	localName := conf.Source()
+	if localName == "" {
+		localName = "local configuration"
+	}
+	color := term.IsTerminal(int(os.Stdout.Fd()))
+	fmt.Printf("--- %s\n+++ %s\n", remoteName, localName)
+	printConfigChanges(os.Stdout, changes, color)
+	return fmt.Errorf("the configuration file differs from the %s in %d place(s)", remoteName, len(changes))
+}
+
+// normalizeRemoteConfig parses a remote configuration and serializes it like the local one, so that the defaults and
+// the formatting of durations and paths don't show up as differences.
+func normalizeRemoteConfig(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, errors.New("the tunnel has no remote configuration, it's managed with a configuration file")
+	}
+	var remoteConfig ingress.RemoteConfig
+	if err := json.Unmarshal(raw, &remoteConfig); err != nil {
+		return nil, err
+	}
+	return orchestration.RedactedConfigJSON(&remoteConfig.Ingress, remoteConfig.WarpRouting)
+}
+
+func fetchConnectorConfig(addr string) ([]byte, error) {
+	client := &http.Client{Timeout: driftStatusTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/config", addr))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the configuration of the connector at %s", addr)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the configuration of the connector at %s: %s", addr, resp.Status)
+	}
+	var versioned struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&versioned); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the configuration of the connector at %s", addr)
+	}
+	return versioned.Config, nil
+}
+
+// configChange is a value that differs between two configurations. A value missing on one side is nil there and
+// has its in flag unset.
+type configChange struct {
+	path              string
+	before, after     any
+	inBefore, inAfter bool
+}
+
+// diffConfigJSON returns the values that differ between two JSON documents, by path, in a stable order.
+func diffConfigJSON(beforeJSON, afterJSON []byte) ([]configChange, error) {
+	var before, after any
+	if err := json.Unmarshal(beforeJSON, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(afterJSON, &after); err != nil {
+		return nil, err
+	}
+	var changes []configChange
+	diffValues("", before, after, &changes)
+	return changes, nil
+}
+
+func diffValues(path string, before, after any, changes *[]configChange) {
+	switch beforeValue := before.(type) {
+	case map[string]any:
+		if afterValue, ok := after.(map[string]any); ok {
+			keys := make([]string, 0, len(beforeValue)+len(afterValue))
+			for key := range beforeValue {
+				keys = append(keys, key)
+			}
+			for key := range afterValue {
+				if _, ok := beforeValue[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				beforeChild, inBefore := beforeValue[key]
+				afterChild, inAfter := afterValue[key]
+				if inBefore && inAfter {
+					diffValues(childPath, beforeChild, afterChild, changes)
+				} else {
+					*changes = append(*changes, configChange{path: childPath, before: beforeChild, after: afterChild, inBefore: inBefore, inAfter: inAfter})
+				}
+			}
+			return
+		}
+	case []any:
+		if afterValue, ok := after.([]any); ok {
+			for i := 0; i < len(beforeValue) || i < len(afterValue); i++ {
+				childPath := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(afterValue):
+					*changes = append(*changes, configChange{path: childPath, before: beforeValue[i], inBefore: true})
+				case i >= len(beforeValue):
+					*changes = append(*changes, configChange{path: childPath, after: afterValue[i], inAfter: true})
+				default:
+					diffValues(childPath, beforeValue[i], afterValue[i], changes)
+				}
+			}
+			return
+		}
+	}
+	beforeSerialized, _ := json.Marshal(before)
+	afterSerialized, _ := json.Marshal(after)
+	if string(beforeSerialized) != string(afterSerialized) {
+		*changes = append(*changes, configChange{path: path, before: before, after: after, inBefore: true, inAfter: true})
+	}
+}
+
+func printConfigChanges(w io.Writer, changes []configChange, color bool) {
+	line := func(sign, colorCode, path string, value any) {
+		serialized, _ := json.Marshal(value)
+		if color {
+			fmt.Fprintf(w, "%s%s %s: %s%s\n", colorCode, sign, path, serialized, colorReset)
+		} else {
+			fmt.Fprintf(w, "%s %s: %s\n", sign, path, serialized)
+		}
+	}
+	for _, change := range changes {
+		if change.inBefore {
+			line("-", colorRed, change.path, change.before)
+		}
+		if change.inAfter {
+			line("+", colorGreen, change.path, change.after)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigJSON(t *testing.T) {
+	remote := []byte(`{"ingress":[{"hostname":"a.example.com","service":"http://localhost:8080"},{"service":"http_status:404"}],"warp-routing":{"enabled":false},"originRequest":{"noTLSVerify":false}}`)
+	local := []byte(`{"ingress":[{"hostname":"a.example.com","service":"http://localhost:9090"},{"hostname":"b.example.com","service":"http://localhost:8080"},{"service":"http_status:404"}],"warp-routing":{"enabled":false},"originRequest":{"noTLSVerify":false,"http2Origin":true}}`)
+
+	changes, err := diffConfigJSON(remote, remote)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	changes, err = diffConfigJSON(remote, local)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	printConfigChanges(&out, changes, false)
+	require.Equal(t, `- ingress[0].service: "http://localhost:8080"
++ ingress[0].service: "http://localhost:9090"
++ ingress[1].hostname: "b.example.com"
+- ingress[1].service: "http_status:404"
++ ingress[1].service: "http://localhost:8080"
++ ingress[2]: {"service":"http_status:404"}
++ originRequest.http2Origin: true
+`, out.String())
+
+	out.Reset()
+	printConfigChanges(&out, changes[:1], true)
+	require.Equal(t, colorRed+`- ingress[0].service: "http://localhost:8080"`+colorReset+"\n"+
+		colorGreen+`+ ingress[0].service: "http://localhost:9090"`+colorReset+"\n", out.String())
+}
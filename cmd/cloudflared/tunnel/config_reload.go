@@ -0,0 +1,90 @@
+package tunnel
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/orchestration"
+)
+
+// configReloadDelay is how long the configuration files have to stay unchanged before they're reloaded, so that an
+// editor or a deployment writing several files only triggers one reload.
+const configReloadDelay = 500 * time.Millisecond
+
+// startConfigReload reloads the ingress rules of the configuration file on SIGHUP, and when the configuration file, the
+// files it includes or the fragments of --config-dir change. The connections to the edge are kept up, and the current
+// rules are kept if the new ones are invalid.
+func startConfigReload(ctx context.Context, c *cli.Context, orchestrator *orchestration.Orchestrator, log *zerolog.Logger) error {
+	conf := config.GetConfiguration()
+	configFile, configDir := conf.Source(), c.String(config.ConfigDirFlag)
+	fileWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "couldn't watch the configuration files")
+	}
+	// Directories are watched rather than files, since editors and Kubernetes replace files instead of writing them
+	watchDirectories := func(conf *config.Configuration) {
+		for _, dir := range conf.SourceDirectories(configDir) {
+			if err := fileWatcher.Add(dir); err != nil {
+				log.Err(err).Msgf("Couldn't watch %s for configuration changes", dir)
+			}
+		}
+	}
+	watchDirectories(conf)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		defer fileWatcher.Close()
+		defer signal.Stop(signals)
+
+		var reloadC <-chan time.Time
+		reload := func(reason string) {
+			changed, err := orchestrator.ReloadLocalConfig(configFile, configDir)
+			if err != nil {
+				log.Err(err).Msgf("Failed to reload the configuration file %s after %s, the current ingress rules are kept", configFile, reason)
+				return
+			}
+			if !changed {
+				log.Debug().Msgf("The ingress rules of the configuration file %s didn't change", configFile)
+				return
+			}
+			log.Info().Msgf("Reloaded the ingress rules of the configuration file %s after %s", configFile, reason)
+			if reloaded, err := config.ReadConfiguration(configFile, configDir); err == nil {
+				watchDirectories(reloaded)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				reload("SIGHUP")
+			case event, ok := <-fileWatcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op != fsnotify.Chmod {
+					reloadC = time.After(configReloadDelay)
+				}
+			case err, ok := <-fileWatcher.Errors:
+				if !ok {
+					return
+				}
+				log.Err(err).Msg("Error watching the configuration files")
+			case <-reloadC:
+				reloadC = nil
+				reload("a file change")
+			}
+		}
+	}()
+	return nil
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	mathRand "math/rand"
 	"net"
+	"net/http"
 	"net/netip"
+	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,17 +20,23 @@ import (
 	"github.com/urfave/cli/v2/altsrc"
 	"golang.org/x/crypto/ssh/terminal"
 
+	"github.com/cloudflare/cloudflared/alerts"
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/updater"
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/edgediscovery/allregions"
 	"github.com/cloudflare/cloudflared/features"
+	"github.com/cloudflare/cloudflared/hooks"
 	"github.com/cloudflare/cloudflared/ingress"
 	"github.com/cloudflare/cloudflared/orchestration"
+	"github.com/cloudflare/cloudflared/recording"
+	"github.com/cloudflare/cloudflared/sockopt"
 	"github.com/cloudflare/cloudflared/supervisor"
 	"github.com/cloudflare/cloudflared/tlsconfig"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/webhook"
 )
 
 const secretValue = "*****"
@@ -37,7 +46,7 @@ var (
 	serviceUrl      = developerPortal + "/reference/service/"
 	argumentsUrl    = developerPortal + "/reference/arguments/"
 
-	secretFlags = [2]*altsrc.StringFlag{credentialsContentsFlag, tunnelTokenFlag}
+	secretFlags = []cli.Flag{credentialsContentsFlag, tunnelTokenFlag, webhookSecretFlag, alertSlackURLFlag, alertPagerDutyKeyFlag, remoteConfigAPITokenFlag}
 
 	configFlags = []string{"autoupdate-freq", "no-autoupdate", "retries", "protocol", "loglevel", "transport-loglevel", "origincert", "metrics", "metrics-update-freq", "edge-ip-version", "edge-bind-address"}
 )
@@ -87,8 +96,10 @@ func logClientOptions(c *cli.Context, log *zerolog.Logger) {
 
 func isSecretFlag(key string) bool {
 	for _, flag := range secretFlags {
-		if flag.Name == key {
-			return true
+		for _, name := range flag.Names() {
+			if name == key {
+				return true
+			}
 		}
 	}
 	return false
@@ -96,7 +107,7 @@ func isSecretFlag(key string) bool {
 
 func isSecretEnvVar(key string) bool {
 	for _, flag := range secretFlags {
-		for _, secretEnvVar := range flag.EnvVars {
+		for _, secretEnvVar := range flagEnvVars(flag) {
 			if secretEnvVar == key {
 				return true
 			}
@@ -105,6 +116,17 @@ func isSecretEnvVar(key string) bool {
 	return false
 }
 
+func flagEnvVars(flag cli.Flag) []string {
+	switch f := flag.(type) {
+	case *altsrc.StringFlag:
+		return f.EnvVars
+	case *altsrc.StringSliceFlag:
+		return f.EnvVars
+	default:
+		return nil
+	}
+}
+
 func dnsProxyStandAlone(c *cli.Context, namedTunnel *connection.NamedTunnelProperties) bool {
 	return c.IsSet("proxy-dns") &&
 		!(c.IsSet("name") || // adhoc-named tunnel
@@ -144,28 +166,64 @@ func prepareTunnelConfig(
 		transportProtocol = connection.QUIC.String()
 	}
 
-	clientFeatures := dedup(append(c.StringSlice("features"), features.DefaultFeatures...))
-	if needPQ {
-		clientFeatures = append(clientFeatures, features.FeaturePostQuantum)
+	clientFeatures := func(defaultFeatures []string) []string {
+		clientFeatures := dedup(append(c.StringSlice("features"), defaultFeatures...))
+		if needPQ {
+			clientFeatures = append(clientFeatures, features.FeaturePostQuantum)
+		}
+		return clientFeatures
 	}
 	namedTunnel.Client = tunnelpogs.ClientInfo{
 		ClientID: clientID[:],
-		Features: clientFeatures,
+		Features: clientFeatures(features.DefaultFeatures),
 		Version:  info.Version(),
 		Arch:     info.OSArch(),
 	}
 	cfg := config.GetConfiguration()
 	ingressRules, err := ingress.ParseIngressFromConfigAndCLI(cfg, c, log)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, cliutil.WithExitCode(cliutil.ExitCodeConfig, err)
 	}
 
-	protocolSelector, err := connection.NewProtocolSelector(transportProtocol, namedTunnel.Credentials.AccountTag, c.IsSet(TunnelTokenFlag), c.Bool("post-quantum"), edgediscovery.ProtocolPercentage, connection.ResolveTTL, log)
+	protocolFallback, err := protocolFallbackPolicy(c, transportProtocol, needPQ, log)
 	if err != nil {
 		return nil, nil, err
 	}
+	var protocolSelector connection.ProtocolSelector
+	if len(protocolFallback.Order) > 0 {
+		protocolSelector = connection.NewOrderedProtocolSelector(protocolFallback.Order)
+	} else {
+		protocolSelector, err = connection.NewProtocolSelector(transportProtocol, namedTunnel.Credentials.AccountTag, c.IsSet(TunnelTokenFlag), c.Bool("post-quantum"), edgediscovery.ProtocolPercentage, connection.ResolveTTL, log)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	log.Info().Msgf("Initial protocol %s", protocolSelector.Current())
+	protocolMix, err := haProtocolMix(c, transportProtocol, needPQ, protocolFallback)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(protocolMix) > 0 {
+		log.Info().Msgf("HA connections protocol mix %s", protocolMix)
+	}
+	// Only http2 connections carry response trailers, so every connection has to use it
+	trailersSupported := transportProtocol == connection.HTTP2.String()
+	for _, p := range protocolFallback.Order {
+		if p != connection.HTTP2 {
+			trailersSupported = false
+		}
+	}
 
+	edgeTLSPolicy, err := tlsconfig.ParsePolicy(c.String(edgeTLSMinVersionFlag), c.StringSlice(edgeTLSCipherSuitesFlag), c.StringSlice(edgeTLSCurvesFlag))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid TLS policy for the edge")
+	}
+	if needPQ && len(edgeTLSPolicy.CurvePreferences) > 0 {
+		return nil, nil, fmt.Errorf("%s can't be used with post-quantum, which only allows post-quantum key agreements", edgeTLSCurvesFlag)
+	}
+	if !edgeTLSPolicy.IsZero() {
+		log.Info().Msgf("TLS policy for the edge: %s", edgeTLSPolicy)
+	}
 	edgeTLSConfigs := make(map[connection.Protocol]*tls.Config, len(connection.ProtocolList))
 	for _, p := range connection.ProtocolList {
 		tlsSettings := p.TLSSettings()
@@ -179,6 +237,8 @@ func prepareTunnelConfig(
 		if len(tlsSettings.NextProtos) > 0 {
 			edgeTLSConfig.NextProtos = tlsSettings.NextProtos
 		}
+		edgeTLSPolicy.Apply(edgeTLSConfig)
+		tlsconfig.TolerateClockSkew(edgeTLSConfig, c.Duration(clockSkewToleranceFlag), warnClockSkew(log))
 		edgeTLSConfigs[p] = edgeTLSConfig
 	}
 
@@ -186,6 +246,17 @@ func prepareTunnelConfig(
 	if err != nil {
 		return nil, nil, err
 	}
+	retryJitter := c.Float64(retryJitterFlag)
+	if retryJitter < 0 || retryJitter > 1 {
+		return nil, nil, fmt.Errorf("%s must be between 0 and 1", retryJitterFlag)
+	}
+	if c.Float64(retryMultiplierFlag) < 1 {
+		return nil, nil, fmt.Errorf("%s must be at least 1", retryMultiplierFlag)
+	}
+	quicTransport, err := quicTransportConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
 	edgeIPVersion, err := parseConfigIPVersion(c.String("edge-ip-version"))
 	if err != nil {
 		return nil, nil, err
@@ -213,25 +284,30 @@ func prepareTunnelConfig(
 	}
 
 	tunnelConfig := &supervisor.TunnelConfig{
-		GracePeriod:     gracePeriod,
-		ReplaceExisting: c.Bool("force"),
-		OSArch:          info.OSArch(),
-		ClientID:        clientID.String(),
-		EdgeAddrs:       c.StringSlice("edge"),
-		Region:          c.String("region"),
-		EdgeIPVersion:   edgeIPVersion,
-		EdgeBindAddr:    edgeBindAddr,
-		HAConnections:   c.Int(haConnectionsFlag),
-		IncidentLookup:  supervisor.NewIncidentLookup(),
-		IsAutoupdated:   c.Bool("is-autoupdated"),
-		LBPool:          c.String("lb-pool"),
-		Tags:            tags,
-		Log:             log,
-		LogTransport:    logTransport,
-		Observer:        observer,
-		ReportedVersion: info.Version(),
+		GracePeriod:       gracePeriod,
+		ReplaceExisting:   c.Bool("force"),
+		OSArch:            info.OSArch(),
+		ClientID:          clientID.String(),
+		EdgeAddrs:         c.StringSlice("edge"),
+		Region:            c.String("region"),
+		EdgeIPVersion:     edgeIPVersion,
+		EdgeBindAddr:      edgeBindAddr,
+		EdgeSocketOptions: edgeSocketOptions(c),
+		HAConnections:     c.Int(haConnectionsFlag),
+		IncidentLookup:    supervisor.NewIncidentLookup(),
+		IsAutoupdated:     c.Bool("is-autoupdated"),
+		LBPool:            c.String("lb-pool"),
+		Tags:              tags,
+		Log:               log,
+		LogTransport:      logTransport,
+		Observer:          observer,
+		ReportedVersion:   info.Version(),
 		// Note TUN-3758 , we use Int because UInt is not supported with altsrc
 		Retries:                     uint(c.Int("retries")),
+		RetryBaseTime:               c.Duration(retryInitialDelayFlag),
+		RetryMultiplier:             c.Float64(retryMultiplierFlag),
+		RetryMaxBackoff:             c.Duration(retryMaxDelayFlag),
+		RetryMinBackoffRatio:        1 - retryJitter,
 		RunFromTerminal:             isRunningFromTerminal(),
 		NamedTunnel:                 namedTunnel,
 		ProtocolSelector:            protocolSelector,
@@ -240,6 +316,10 @@ func prepareTunnelConfig(
 		PQKexIdx:                    pqKexIdx,
 		MaxEdgeAddrRetries:          uint8(c.Int("max-edge-addr-retries")),
 		UDPUnregisterSessionTimeout: c.Duration(udpUnregisterSessionTimeoutFlag),
+		WatchdogTimeout:             c.Duration(connectionWatchdogFlag),
+		QUICTransport:               quicTransport,
+		ProtocolFallback:            protocolFallback,
+		HAProtocolMix:               protocolMix,
 	}
 	packetConfig, err := newPacketConfig(c, log)
 	if err != nil {
@@ -251,10 +331,44 @@ func prepareTunnelConfig(
 		Ingress:            &ingressRules,
 		WarpRouting:        ingress.NewWarpRoutingConfig(&cfg.WarpRouting),
 		ConfigurationFlags: parseConfigFlags(c),
+		Observer:           observer,
+		WarmupOrigins:      c.Bool(warmupOriginsFlag),
+		ProbeOrigins:       c.Bool(probeOriginsOnUpdateFlag),
+		TrailersSupported:  trailersSupported,
+	}
+	if recordPath := c.String(recordTrafficFlag); recordPath != "" {
+		recorder, err := recording.NewFileRecorder(recordPath, c.Int(recordTrafficMaxBodySizeFlag))
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Info().Msgf("Recording HTTP traffic to %s", recordPath)
+		orchestratorConfig.Recorder = recorder
+	}
+	if url := c.String(featureFlagsURLFlag); url != "" {
+		tunnelConfig.ClientFeatures = fetchFeatureFlags(url, namedTunnel.Credentials.AccountTag, clientFeatures, log)
 	}
 	return tunnelConfig, orchestratorConfig, nil
 }
 
+// fetchFeatureFlags fetches the feature flags at url in the background, so they don't delay startup. The returned
+// function tells the features to register connections with: the defaults until the flags have been fetched, then the
+// defaults with the flags applied. clientFeatures adds the features that don't depend on the flags.
+func fetchFeatureFlags(url, accountTag string, clientFeatures func(defaultFeatures []string) []string, log *zerolog.Logger) func() []string {
+	var current atomic.Value
+	current.Store(clientFeatures(features.DefaultFeatures))
+	go func() {
+		flags, err := features.FetchFlags(url)
+		if err != nil {
+			log.Warn().Err(err).Msg("Using the default features")
+			return
+		}
+		current.Store(clientFeatures(features.Apply(features.DefaultFeatures, flags.Select(accountTag), log)))
+	}()
+	return func() []string {
+		return current.Load().([]string)
+	}
+}
+
 func parseConfigFlags(c *cli.Context) map[string]string {
 	result := make(map[string]string)
 
@@ -267,6 +381,87 @@ func parseConfigFlags(c *cli.Context) map[string]string {
 	return result
 }
 
+func parseWebhookConfig(c *cli.Context) (webhook.Config, error) {
+	for _, rawURL := range c.StringSlice(webhookURLFlag) {
+		if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return webhook.Config{}, fmt.Errorf("invalid value for %s: %s is not an http or https URL", webhookURLFlag, rawURL)
+		}
+	}
+	header := make(http.Header)
+	for _, rawHeader := range c.StringSlice(webhookHeaderFlag) {
+		name, value, ok := strings.Cut(rawHeader, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return webhook.Config{}, fmt.Errorf("invalid value for %s: %s is not in format NAME:VALUE", webhookHeaderFlag, rawHeader)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	events, err := webhook.ParseEventTypes(c.StringSlice(webhookEventFlag))
+	if err != nil {
+		return webhook.Config{}, err
+	}
+	return webhook.Config{
+		URLs:   c.StringSlice(webhookURLFlag),
+		Header: header,
+		Secret: c.String(webhookSecretFlag.Name),
+		Events: events,
+	}, nil
+}
+
+func parseAlertsConfig(c *cli.Context) (alerts.Config, error) {
+	for _, flag := range []string{alertWebhookURLFlag, alertSlackURLFlag.Name} {
+		for _, rawURL := range c.StringSlice(flag) {
+			if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return alerts.Config{}, fmt.Errorf("invalid value for %s: %s is not an http or https URL", flag, rawURL)
+			}
+		}
+	}
+	if rate := c.Float64(alertOriginErrorRateFlag); rate < 0 || rate > 100 {
+		return alerts.Config{}, fmt.Errorf("invalid value for %s: %v is not a percentage", alertOriginErrorRateFlag, rate)
+	}
+	if c.Duration(alertConnectionsDownFlag) < 0 || c.Int(alertRegistrationFailuresFlag) < 0 || c.Duration(alertCooldownFlag) < 0 {
+		return alerts.Config{}, fmt.Errorf("alert thresholds can't be negative")
+	}
+	return alerts.Config{
+		ConnectionsDownAfter: c.Duration(alertConnectionsDownFlag),
+		OriginErrorRate:      c.Float64(alertOriginErrorRateFlag),
+		RegistrationFailures: c.Int(alertRegistrationFailuresFlag),
+		Cooldown:             c.Duration(alertCooldownFlag),
+		WebhookURLs:          c.StringSlice(alertWebhookURLFlag),
+		SlackWebhookURLs:     c.StringSlice(alertSlackURLFlag.Name),
+		PagerDutyRoutingKey:  c.String(alertPagerDutyKeyFlag.Name),
+	}, nil
+}
+
+func parseHooksConfig(c *cli.Context) (hooks.Config, error) {
+	var config hooks.Config
+	for flag, command := range map[string]*[]string{
+		onConnectedFlag:    &config.OnConnected,
+		onDisconnectedFlag: &config.OnDisconnected,
+		onURLAssignedFlag:  &config.OnURLAssigned,
+	} {
+		args, err := hooks.SplitCommand(c.String(flag))
+		if err != nil {
+			return hooks.Config{}, errors.Wrapf(err, "invalid %s", flag)
+		}
+		*command = args
+	}
+	return config, nil
+}
+
+func parseUpdatePolicy(c *cli.Context) (updater.UpdatePolicy, error) {
+	policy := updater.UpdatePolicy{
+		MinUptime: c.Duration("autoupdate-min-uptime"),
+	}
+	if spec := c.String("autoupdate-window"); spec != "" {
+		window, err := updater.ParseWindow(spec)
+		if err != nil {
+			return updater.UpdatePolicy{}, errors.Wrap(err, "invalid autoupdate-window")
+		}
+		policy.Window = window
+	}
+	return policy, nil
+}
+
 func gracePeriod(c *cli.Context) (time.Duration, error) {
 	period := c.Duration("grace-period")
 	if period > connection.MaxGracePeriod {
@@ -275,6 +470,93 @@ func gracePeriod(c *cli.Context) (time.Duration, error) {
 	return period, nil
 }
 
+// protocolFallbackPolicy parses the policy of the protocol-fallback flags. Its order has to start with the protocol
+// that --protocol or --post-quantum pick, if any.
+func protocolFallbackPolicy(c *cli.Context, transportProtocol string, needPQ bool, log *zerolog.Logger) (connection.ProtocolFallbackPolicy, error) {
+	retries := c.Int(protocolFallbackRetriesFlag)
+	if retries < 0 {
+		return connection.ProtocolFallbackPolicy{}, fmt.Errorf("%s can't be negative", protocolFallbackRetriesFlag)
+	}
+	cooldown := c.Duration(protocolFallbackCooldownFlag)
+	if cooldown < 0 {
+		return connection.ProtocolFallbackPolicy{}, fmt.Errorf("%s can't be negative", protocolFallbackCooldownFlag)
+	}
+	policy := connection.ProtocolFallbackPolicy{
+		Retries:  uint(retries),
+		Cooldown: cooldown,
+	}
+	if !c.IsSet(protocolFallbackFlag) {
+		return policy, nil
+	}
+
+	order, err := connection.ParseProtocolFallbackOrder(c.StringSlice(protocolFallbackFlag), log)
+	if err != nil {
+		return connection.ProtocolFallbackPolicy{}, err
+	}
+	if needPQ && (len(order) > 1 || order[0] != connection.QUIC) {
+		return connection.ProtocolFallbackPolicy{}, fmt.Errorf("post-quantum is only supported with the quic transport, so it can't fall back to other protocols")
+	}
+	if transportProtocol != connection.AutoSelectFlag && transportProtocol != order[0].String() {
+		return connection.ProtocolFallbackPolicy{}, fmt.Errorf("%s has to start with %s, the protocol that --protocol picks", protocolFallbackFlag, transportProtocol)
+	}
+	policy.Order = order
+	return policy, nil
+}
+
+// haProtocolMix parses the mix of protocols of the HA connections. It can only use the protocols that --protocol,
+// --post-quantum and --protocol-fallback allow.
+func haProtocolMix(c *cli.Context, transportProtocol string, needPQ bool, protocolFallback connection.ProtocolFallbackPolicy) (connection.ProtocolMix, error) {
+	if !c.IsSet(haProtocolMixFlag) {
+		return nil, nil
+	}
+	mix, err := connection.ParseProtocolMix(c.StringSlice(haProtocolMixFlag))
+	if err != nil {
+		return nil, err
+	}
+	if haConnections := c.Int(haConnectionsFlag); len(mix) > haConnections {
+		return nil, fmt.Errorf("%s %s has more than the %d HA connections", haProtocolMixFlag, mix, haConnections)
+	}
+	for _, p := range mix.Protocols() {
+		if needPQ && p != connection.QUIC {
+			return nil, fmt.Errorf("post-quantum is only supported with the quic transport, so %s can't use %s", haProtocolMixFlag, p)
+		}
+		if transportProtocol != connection.AutoSelectFlag && transportProtocol != p.String() {
+			return nil, fmt.Errorf("%s can't use %s, --protocol picks %s", haProtocolMixFlag, p, transportProtocol)
+		}
+		if len(protocolFallback.Order) > 0 && !containsProtocol(protocolFallback.Order, p) {
+			return nil, fmt.Errorf("%s can't use %s, which isn't in %s", haProtocolMixFlag, p, protocolFallbackFlag)
+		}
+	}
+	return mix, nil
+}
+
+func containsProtocol(protocols []connection.Protocol, protocol connection.Protocol) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+func quicTransportConfig(c *cli.Context) (connection.QUICTransportConfig, error) {
+	initialStreamWindow, maxStreamWindow := c.Int(quicInitialStreamWindowFlag), c.Int(quicMaxStreamWindowFlag)
+	if initialStreamWindow < 0 || maxStreamWindow < 0 {
+		return connection.QUICTransportConfig{}, fmt.Errorf("%s and %s can't be negative", quicInitialStreamWindowFlag, quicMaxStreamWindowFlag)
+	}
+	config := connection.QUICTransportConfig{
+		MaxIdleTimeout:             c.Duration(quicIdleTimeoutFlag),
+		KeepAlivePeriod:            c.Duration(quicKeepAlivePeriodFlag),
+		InitialStreamReceiveWindow: uint64(initialStreamWindow),
+		MaxStreamReceiveWindow:     uint64(maxStreamWindow),
+		MaxIncomingStreams:         int64(c.Int(quicMaxIncomingStreamsFlag)),
+	}
+	if err := config.Validate(); err != nil {
+		return connection.QUICTransportConfig{}, errors.Wrap(err, "invalid QUIC transport parameters")
+	}
+	return config, nil
+}
+
 func isRunningFromTerminal() bool {
 	return terminal.IsTerminal(int(os.Stdout.Fd()))
 }
@@ -358,6 +640,20 @@ func adjustIPVersionByBindAddress(ipVersion allregions.ConfigIPVersion, ip net.I
 	}
 }
 
+func edgeSocketOptions(c *cli.Context) sockopt.Options {
+	opts := sockopt.Options{
+		KeepAliveInterval: c.Duration(edgeTCPKeepAliveIntervalFlag),
+		KeepAliveCount:    c.Int(edgeTCPKeepAliveCountFlag),
+		SendBuffer:        c.Int(edgeTCPSendBufferFlag),
+		ReceiveBuffer:     c.Int(edgeTCPReceiveBufferFlag),
+	}
+	if c.IsSet(edgeTCPNoDelayFlag) {
+		noDelay := c.Bool(edgeTCPNoDelayFlag)
+		opts.NoDelay = &noDelay
+	}
+	return opts
+}
+
 func newPacketConfig(c *cli.Context, logger *zerolog.Logger) (*ingress.GlobalRouterConfig, error) {
 	ipv4Src, err := determineICMPv4Src(c.String("icmpv4-src"), logger)
 	if err != nil {
@@ -501,3 +797,20 @@ func findLocalAddr(dst net.IP, port int) (netip.Addr, error) {
 	localAddr := localAddrPort.Addr()
 	return localAddr, nil
 }
+
+// warnClockSkew reports that the certificate of the edge isn't valid at the local time, which is most likely because
+// the system clock is wrong.
+func warnClockSkew(log *zerolog.Logger) tlsconfig.ClockSkewHandler {
+	return func(skew time.Duration, tolerated bool) {
+		direction := "behind"
+		if skew < 0 {
+			direction = "ahead"
+			skew = -skew
+		}
+		if tolerated {
+			log.Warn().Msgf("The system clock is at least %s %s, the certificate of the edge is only accepted because of --%s. Make sure the clock is synchronized, e.g. with NTP", skew.Round(time.Second), direction, clockSkewToleranceFlag)
+			return
+		}
+		log.Error().Msgf("The system clock is at least %s %s, so the certificate of the edge looks invalid and cloudflared can't connect. Make sure the clock is synchronized, e.g. with NTP", skew.Round(time.Second), direction)
+	}
+}
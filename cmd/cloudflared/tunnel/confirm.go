@@ -0,0 +1,50 @@
+package tunnel
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+// errAborted is returned when the user doesn't confirm a destructive operation.
+var errAborted = errors.New("aborted, nothing was changed")
+
+// describeTunnel returns the name and ID of a tunnel, when its name is known.
+func describeTunnel(tunnelID uuid.UUID, names map[string]string) string {
+	if name, ok := names[tunnelID.String()]; ok {
+		return fmt.Sprintf("tunnel %s (%s)", name, tunnelID)
+	}
+	return fmt.Sprintf("tunnel %s", tunnelID)
+}
+
+// confirmDestruction shows what an operation will destroy and asks the user to confirm it, unless --yes is set or
+// stdin isn't a terminal. Scripts keep working as before, without a prompt.
+func confirmDestruction(c *cli.Context, summary string, details []string) error {
+	if c.Bool(yesFlag.Name) {
+		return nil
+	}
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	return askConfirmation(newPrompter(os.Stdin, os.Stderr), interactive, summary, details)
+}
+
+func askConfirmation(p *prompter, interactive bool, summary string, details []string) error {
+	if !interactive {
+		return nil
+	}
+	fmt.Fprintf(p.out, "%s:\n", summary)
+	for _, detail := range details {
+		fmt.Fprintf(p.out, "  - %s\n", detail)
+	}
+	confirmed, err := p.confirm("Proceed?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errAborted
+	}
+	return nil
+}
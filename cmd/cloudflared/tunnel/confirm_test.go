@@ -0,0 +1,34 @@
+package tunnel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskConfirmation(t *testing.T) {
+	details := []string{"tunnel prod (f70ff985-a4ef-4643-bbbc-4a0ed4fc8415)", "the credentials file /etc/cloudflared/prod.json"}
+
+	var out bytes.Buffer
+	err := askConfirmation(newPrompter(strings.NewReader("y\n"), &out), true, "Deleting 1 tunnel(s)", details)
+	require.NoError(t, err)
+	assert.Equal(t, "Deleting 1 tunnel(s):\n"+
+		"  - tunnel prod (f70ff985-a4ef-4643-bbbc-4a0ed4fc8415)\n"+
+		"  - the credentials file /etc/cloudflared/prod.json\n"+
+		"Proceed? (y/n) [n]: ", out.String())
+
+	// Anything but yes aborts
+	for _, answer := range []string{"\n", "no\n", "maybe\n"} {
+		err = askConfirmation(newPrompter(strings.NewReader(answer), &bytes.Buffer{}), true, "Deleting 1 tunnel(s)", details)
+		assert.Equal(t, errAborted, err)
+	}
+
+	// Without a terminal, nothing is asked and the operation proceeds
+	out.Reset()
+	err = askConfirmation(newPrompter(strings.NewReader("n\n"), &out), false, "Deleting 1 tunnel(s)", details)
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,194 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cfio"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+var migrateDryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "Only report which credentials files would be rewritten.",
+}
+
+func buildCredentialsCommand() *cli.Command {
+	return &cli.Command{
+		Name:               "credentials",
+		Category:           "Tunnel",
+		Usage:              "Manage the credentials files of tunnels",
+		UsageText:          "cloudflared tunnel [tunnel command options] credentials COMMAND [arguments...]",
+		Subcommands:        []*cli.Command{buildCredentialsMigrateCommand()},
+		CustomHelpTemplate: commandHelpTemplate(),
+	}
+}
+
+func buildCredentialsMigrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "migrate",
+		Action:    cliutil.ConfiguredAction(credentialsMigrateCommand),
+		Usage:     "Add the tunnel ID and name to credentials files created by old versions of cloudflared",
+		UsageText: "cloudflared tunnel [tunnel command options] credentials migrate [--dry-run] [DIRECTORY]",
+		Description: `Scans the credentials files (*.json) of DIRECTORY, which defaults to the directory of the origin certificate,
+  and rewrites the ones that lack the ID or name of their tunnel. The ID of those is taken from the name of the file,
+  e.g. 6ff42ae2-765d-4adf-8112-31c55c1551ef.json, and the name from a single listing of the account's tunnels.
+  Files are replaced atomically, and the ones of deleted tunnels are left untouched.`,
+		Flags:              []cli.Flag{migrateDryRunFlag},
+		CustomHelpTemplate: commandHelpTemplate(),
+	}
+}
+
+func credentialsMigrateCommand(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cliutil.UsageError(`"cloudflared tunnel credentials migrate" accepts at most one argument, the directory of the credentials files.`)
+	}
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+	dir := c.Args().First()
+	if dir == "" {
+		credential, err := sc.credential()
+		if err != nil {
+			return errors.Wrap(err, "couldn't find the directory of the origin certificate, give the directory of the credentials files instead")
+		}
+		dir = filepath.Dir(credential.CertPath())
+	}
+
+	files, err := scanCredentialsFiles(dir)
+	if err != nil {
+		return err
+	}
+	outdated := 0
+	for _, file := range files {
+		if file.outdated() {
+			outdated++
+		}
+	}
+	if outdated == 0 {
+		fmt.Printf("All %d credentials files in %s are up to date.\n", len(files), dir)
+		return nil
+	}
+
+	tunnels, err := sc.list(cfapi.NewTunnelFilter())
+	if err != nil {
+		return errors.Wrap(err, "couldn't list the tunnels to resolve their names")
+	}
+	return migrateCredentialsFiles(files, tunnels, c.Bool(migrateDryRunFlag.Name))
+}
+
+// credentialsFile is a credentials file found in a directory, with the ID of its tunnel if it can be told.
+type credentialsFile struct {
+	path        string
+	mode        os.FileMode
+	credentials connection.Credentials
+	tunnelID    uuid.UUID
+}
+
+// outdated tells whether the file lacks fields that credentials files have had since TUN-3581.
+func (f *credentialsFile) outdated() bool {
+	return f.credentials.TunnelID == uuid.Nil || f.credentials.TunnelName == ""
+}
+
+// scanCredentialsFiles returns the tunnel credentials files of dir. JSON files that aren't credentials, like
+// configuration files, are ignored.
+func scanCredentialsFiles(dir string) ([]*credentialsFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var files []*credentialsFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't read %s", path)
+		}
+		var credentials connection.Credentials
+		if err := json.Unmarshal(body, &credentials); err != nil || credentials.AccountTag == "" || len(credentials.TunnelSecret) == 0 {
+			continue
+		}
+		tunnelID := credentials.TunnelID
+		if tunnelID == uuid.Nil {
+			// Old credentials files are named after their tunnel's ID
+			tunnelID, _ = uuid.Parse(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		}
+		files = append(files, &credentialsFile{path: path, mode: info.Mode().Perm(), credentials: credentials, tunnelID: tunnelID})
+	}
+	return files, nil
+}
+
+// migrateCredentialsFiles adds the ID and name of their tunnel to outdated credentials files, and reports what was
+// done to each of them. It fails if any file couldn't be migrated.
+func migrateCredentialsFiles(files []*credentialsFile, tunnels []*cfapi.Tunnel, dryRun bool) error {
+	tunnelsByID := make(map[uuid.UUID]*cfapi.Tunnel, len(tunnels))
+	for _, tunnel := range tunnels {
+		tunnelsByID[tunnel.ID] = tunnel
+	}
+
+	var migrated, upToDate, failed int
+	for _, file := range files {
+		if !file.outdated() {
+			upToDate++
+			continue
+		}
+		if file.tunnelID == uuid.Nil {
+			fmt.Printf("Skipped %s: its tunnel can't be told from its content or name\n", file.path)
+			failed++
+			continue
+		}
+		tunnel, ok := tunnelsByID[file.tunnelID]
+		if !ok {
+			fmt.Printf("Skipped %s: tunnel %s doesn't exist or was deleted\n", file.path, file.tunnelID)
+			failed++
+			continue
+		}
+		if dryRun {
+			fmt.Printf("Would migrate %s for tunnel %s (%s)\n", file.path, tunnel.Name, tunnel.ID)
+			migrated++
+			continue
+		}
+		credentials := file.credentials
+		credentials.TunnelID = tunnel.ID
+		credentials.TunnelName = tunnel.Name
+		body, err := json.Marshal(&credentials)
+		if err != nil {
+			return errors.Wrap(err, "Unable to marshal tunnel credentials to JSON")
+		}
+		// The file is replaced, so it must get the permissions of the old one back
+		err = cfio.WriteFileAtomically(file.path, body)
+		if err == nil {
+			err = os.Chmod(file.path, file.mode)
+		}
+		if err != nil {
+			fmt.Printf("Failed to migrate %s: %v\n", file.path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Migrated %s for tunnel %s (%s)\n", file.path, tunnel.Name, tunnel.ID)
+		migrated++
+	}
+
+	verb := "Migrated"
+	if dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Printf("%s %d credentials files, %d were up to date, %d couldn't be migrated.\n", verb, migrated, upToDate, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d credentials files couldn't be migrated", failed)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+func TestMigrateCredentialsFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeJSON := func(name string, v any) string {
+		body, err := json.Marshal(v)
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, body, 0400))
+		return path
+	}
+	secret := []byte("secret")
+
+	oldTunnel := &cfapi.Tunnel{ID: uuid.New(), Name: "old"}
+	oldPath := writeJSON(oldTunnel.ID.String()+".json", connection.Credentials{AccountTag: "account", TunnelSecret: secret})
+	unnamedTunnel := &cfapi.Tunnel{ID: uuid.New(), Name: "unnamed"}
+	unnamedPath := writeJSON("renamed.json", connection.Credentials{AccountTag: "account", TunnelSecret: secret, TunnelID: unnamedTunnel.ID})
+	currentTunnel := &cfapi.Tunnel{ID: uuid.New(), Name: "current"}
+	writeJSON("current.json", connection.Credentials{AccountTag: "account", TunnelSecret: secret, TunnelID: currentTunnel.ID, TunnelName: "current"})
+	deletedID := uuid.New()
+	deletedPath := writeJSON(deletedID.String()+".json", connection.Credentials{AccountTag: "account", TunnelSecret: secret})
+	writeJSON("unknown.json", connection.Credentials{AccountTag: "account", TunnelSecret: secret})
+	writeJSON("config.json", map[string]string{"tunnel": "old"})
+
+	files, err := scanCredentialsFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+	tunnels := []*cfapi.Tunnel{oldTunnel, unnamedTunnel, currentTunnel}
+
+	readCredentials := func(path string) connection.Credentials {
+		body, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var credentials connection.Credentials
+		require.NoError(t, json.Unmarshal(body, &credentials))
+		return credentials
+	}
+
+	// A dry run doesn't change anything
+	require.Error(t, migrateCredentialsFiles(files, tunnels, true))
+	require.Equal(t, uuid.Nil, readCredentials(oldPath).TunnelID)
+
+	// The files of deleted tunnels, or whose tunnel can't be told, fail the migration but don't stop it
+	require.Error(t, migrateCredentialsFiles(files, tunnels, false))
+	require.Equal(t, connection.Credentials{AccountTag: "account", TunnelSecret: secret, TunnelID: oldTunnel.ID, TunnelName: "old"}, readCredentials(oldPath))
+	require.Equal(t, connection.Credentials{AccountTag: "account", TunnelSecret: secret, TunnelID: unnamedTunnel.ID, TunnelName: "unnamed"}, readCredentials(unnamedPath))
+	require.Equal(t, uuid.Nil, readCredentials(deletedPath).TunnelID)
+	info, err := os.Stat(oldPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0400), info.Mode().Perm())
+
+	files, err = scanCredentialsFiles(dir)
+	require.NoError(t, err)
+	require.Error(t, migrateCredentialsFiles(files, append(tunnels, &cfapi.Tunnel{ID: deletedID, Name: "restored"}), false))
+	require.Equal(t, "restored", readCredentials(deletedPath).TunnelName)
+}
@@ -0,0 +1,237 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	credentialsStoreTimeout = 30 * time.Second
+
+	gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	gcpMetadataTokenURL      = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// CredentialsStore reads the contents of a tunnel credentials file from somewhere else than the local disk, e.g. a
+// secrets manager, so that they don't have to be written to the disk of containerized deployments.
+type CredentialsStore interface {
+	// Read returns the tunnel credentials, as the JSON content of a credentials file.
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// newCredentialsStore returns the CredentialsStore that the URI points to. The supported URIs are:
+//
+//	env://VARIABLE
+//	vault://[HOST[:PORT]]/MOUNT/PATH[?field=FIELD&version=VERSION]
+//	aws-sm://SECRET_ID[?region=REGION&version=VERSION_ID]
+//	gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION]
+func newCredentialsStore(uri string) (CredentialsStore, error) {
+	// Secret IDs can be ARNs, which aren't valid hosts, so URIs are only parsed as URLs by the stores that need it
+	scheme, rest, _ := strings.Cut(uri, "://")
+	path, rawQuery, _ := strings.Cut(rest, "?")
+	path = strings.Trim(path, "/")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid credentials store %s", uri)
+	}
+	client := &http.Client{Timeout: credentialsStoreTimeout}
+	switch scheme {
+	case "env":
+		if path == "" {
+			return nil, fmt.Errorf("credentials store %s doesn't name an environment variable", uri)
+		}
+		return envCredentialsStore{variable: path}, nil
+	case "vault":
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid credentials store %s", uri)
+		}
+		return newVaultCredentialsStore(parsed, client)
+	case "aws-sm":
+		return newAWSCredentialsStore(path, query, client)
+	case "gcp-sm":
+		return newGCPCredentialsStore(path, client)
+	default:
+		return nil, fmt.Errorf("unknown credentials store %s, it should start with env://, vault://, aws-sm:// or gcp-sm://", uri)
+	}
+}
+
+// envCredentialsStore reads the credentials from an environment variable, e.g. one that the container runtime fills
+// from its own secrets.
+type envCredentialsStore struct {
+	variable string
+}
+
+func (s envCredentialsStore) Read(context.Context) ([]byte, error) {
+	contents, ok := os.LookupEnv(s.variable)
+	if !ok || contents == "" {
+		return nil, fmt.Errorf("environment variable %s is empty", s.variable)
+	}
+	return []byte(contents), nil
+}
+
+// vaultCredentialsStore reads the credentials from a secret of a HashiCorp Vault KV version 2 secrets engine. The
+// secret either has the fields of a credentials file, or holds the whole file in the field given by the URI. Vault
+// is authenticated to with the token of VAULT_TOKEN.
+type vaultCredentialsStore struct {
+	client    *http.Client
+	address   string
+	mount     string
+	path      string
+	field     string
+	version   string
+	token     string
+	namespace string
+}
+
+func newVaultCredentialsStore(uri *url.URL, client *http.Client) (*vaultCredentialsStore, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if uri.Host != "" {
+		address = "https://" + uri.Host
+	}
+	if address == "" {
+		return nil, fmt.Errorf("credentials store %s doesn't give the address of Vault, and VAULT_ADDR isn't set", uri)
+	}
+	mount, path, _ := strings.Cut(strings.Trim(uri.Path, "/"), "/")
+	if mount == "" || path == "" {
+		return nil, fmt.Errorf("credentials store %s should give the mount and the path of the secret, e.g. vault:///secret/cloudflared/my-tunnel", uri)
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN should be set to read the tunnel credentials from Vault")
+	}
+	return &vaultCredentialsStore{
+		client:    client,
+		address:   strings.TrimSuffix(address, "/"),
+		mount:     mount,
+		path:      path,
+		field:     uri.Query().Get("field"),
+		version:   uri.Query().Get("version"),
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+	}, nil
+}
+
+func (s *vaultCredentialsStore) Read(ctx context.Context) ([]byte, error) {
+	secretURL := fmt.Sprintf("%s/v1/%s/data/%s", s.address, s.mount, s.path)
+	if s.version != "" {
+		secretURL += "?version=" + url.QueryEscape(s.version)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, secretURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	var secret struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := doCredentialsStoreRequest(s.client, req, &secret); err != nil {
+		return nil, errors.Wrapf(err, "couldn't read the tunnel credentials from Vault secret %s/%s", s.mount, s.path)
+	}
+	if s.field == "" {
+		return json.Marshal(secret.Data.Data)
+	}
+	value, ok := secret.Data.Data[s.field].(string)
+	if !ok {
+		return nil, fmt.Errorf("Vault secret %s/%s doesn't have a field %s holding the tunnel credentials", s.mount, s.path, s.field)
+	}
+	return []byte(value), nil
+}
+
+// gcpCredentialsStore reads the credentials from a version of a GCP Secret Manager secret. It's authenticated to with
+// the access token of GOOGLE_OAUTH_ACCESS_TOKEN, or else with the one of the instance's service account.
+type gcpCredentialsStore struct {
+	client   *http.Client
+	endpoint string
+	name     string
+	tokenURL string
+}
+
+func newGCPCredentialsStore(name string, client *http.Client) (*gcpCredentialsStore, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) == 4 {
+		name += "/versions/latest"
+	} else if len(parts) != 6 {
+		return nil, fmt.Errorf("credentials store gcp-sm://%s should be like gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION]", name)
+	}
+	if parts[0] != "projects" || parts[2] != "secrets" || (len(parts) == 6 && parts[4] != "versions") {
+		return nil, fmt.Errorf("credentials store gcp-sm://%s should be like gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION]", name)
+	}
+	return &gcpCredentialsStore{
+		client:   client,
+		endpoint: gcpSecretManagerEndpoint,
+		name:     name,
+		tokenURL: gcpMetadataTokenURL,
+	}, nil
+}
+
+func (s *gcpCredentialsStore) Read(ctx context.Context) ([]byte, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get an access token for GCP Secret Manager")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v1/%s:access", s.endpoint, s.name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doCredentialsStoreRequest(s.client, req, &version); err != nil {
+		return nil, errors.Wrapf(err, "couldn't read the tunnel credentials from GCP secret %s", s.name)
+	}
+	return base64.StdEncoding.DecodeString(version.Payload.Data)
+}
+
+func (s *gcpCredentialsStore) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doCredentialsStoreRequest(s.client, req, &token); err != nil {
+		return "", errors.Wrap(err, "GOOGLE_OAUTH_ACCESS_TOKEN isn't set and the metadata server didn't give one")
+	}
+	return token.AccessToken, nil
+}
+
+// doCredentialsStoreRequest sends req and decodes its JSON response into v.
+func doCredentialsStoreRequest(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
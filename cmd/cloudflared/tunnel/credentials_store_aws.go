@@ -0,0 +1,205 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	awsSecretsManagerService = "secretsmanager"
+	awsContainerCredentials  = "http://169.254.170.2"
+)
+
+// awsCredentialsStore reads the credentials from an AWS Secrets Manager secret. It's authenticated to with the keys
+// of AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, or else with the role of the ECS task or EKS pod.
+type awsCredentialsStore struct {
+	client    *http.Client
+	endpoint  string
+	region    string
+	secretID  string
+	versionID string
+	now       func() time.Time
+}
+
+type awsKeys struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+}
+
+func newAWSCredentialsStore(secretID string, query url.Values, client *http.Client) (*awsCredentialsStore, error) {
+	if secretID == "" {
+		return nil, fmt.Errorf("credentials store aws-sm:// should give the name or ARN of the secret")
+	}
+	region := query.Get("region")
+	if region == "" && strings.HasPrefix(secretID, "arn:") {
+		// arn:aws:secretsmanager:REGION:ACCOUNT:secret:NAME
+		if parts := strings.Split(secretID, ":"); len(parts) > 3 {
+			region = parts[3]
+		}
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("credentials store aws-sm://%s doesn't give a region, and neither AWS_REGION nor AWS_DEFAULT_REGION is set", secretID)
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	return &awsCredentialsStore{
+		client:    client,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		region:    region,
+		secretID:  secretID,
+		versionID: query.Get("version"),
+		now:       time.Now,
+	}, nil
+}
+
+func (s *awsCredentialsStore) Read(ctx context.Context) ([]byte, error) {
+	keys, err := s.keys(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't find AWS credentials to read the tunnel credentials with")
+	}
+	input := map[string]string{"SecretId": s.secretID}
+	if s.versionID != "" {
+		input["VersionId"] = s.versionID
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, keys, s.region, awsSecretsManagerService, s.now())
+
+	var secret struct {
+		SecretString string
+		SecretBinary []byte
+	}
+	if err := doCredentialsStoreRequest(s.client, req, &secret); err != nil {
+		return nil, errors.Wrapf(err, "couldn't read the tunnel credentials from AWS secret %s", s.secretID)
+	}
+	if secret.SecretString != "" {
+		return []byte(secret.SecretString), nil
+	}
+	return secret.SecretBinary, nil
+}
+
+// keys returns the AWS credentials of the environment, or of the container's role.
+func (s *awsCredentialsStore) keys(ctx context.Context) (awsKeys, error) {
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		return awsKeys{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Token:           os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	credentialsURL := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		credentialsURL = awsContainerCredentials + relative
+	}
+	if credentialsURL == "" {
+		return awsKeys{}, fmt.Errorf("set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or run in a container with an IAM role")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, credentialsURL, nil)
+	if err != nil {
+		return awsKeys{}, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	} else if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return awsKeys{}, err
+		}
+		req.Header.Set("Authorization", strings.TrimSpace(string(token)))
+	}
+	var keys awsKeys
+	if err := doCredentialsStoreRequest(s.client, req, &keys); err != nil {
+		return awsKeys{}, err
+	}
+	return keys, nil
+}
+
+// signAWSRequest adds the AWS Signature Version 4 of req, whose body is body, to its headers.
+func signAWSRequest(req *http.Request, body []byte, keys awsKeys, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if keys.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", keys.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(awsSigningKey(keys.SecretAccessKey, date, region, service), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		keys.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func awsSigningKey(secretAccessKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,146 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storedCredentials = `{"AccountTag":"account","TunnelSecret":"c2VjcmV0","TunnelID":"6ff42ae2-765d-4adf-8112-31c55c1551ef"}`
+
+func TestNewCredentialsStore(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "token")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", "")
+
+	store, err := newCredentialsStore("env://TUNNEL_CREDS")
+	require.NoError(t, err)
+	assert.Equal(t, envCredentialsStore{variable: "TUNNEL_CREDS"}, store)
+
+	store, err = newCredentialsStore("vault://vault.example.com:8200/secret/cloudflared/my-tunnel?field=credentials")
+	require.NoError(t, err)
+	vault := store.(*vaultCredentialsStore)
+	assert.Equal(t, "https://vault.example.com:8200", vault.address)
+	assert.Equal(t, "secret", vault.mount)
+	assert.Equal(t, "cloudflared/my-tunnel", vault.path)
+	assert.Equal(t, "credentials", vault.field)
+
+	store, err = newCredentialsStore("aws-sm://arn:aws:secretsmanager:eu-west-1:123456789012:secret:cloudflared/my-tunnel-AbCdEf")
+	require.NoError(t, err)
+	aws := store.(*awsCredentialsStore)
+	assert.Equal(t, "arn:aws:secretsmanager:eu-west-1:123456789012:secret:cloudflared/my-tunnel-AbCdEf", aws.secretID)
+	assert.Equal(t, "https://secretsmanager.eu-west-1.amazonaws.com", aws.endpoint)
+
+	store, err = newCredentialsStore("gcp-sm://projects/my-project/secrets/my-tunnel")
+	require.NoError(t, err)
+	assert.Equal(t, "projects/my-project/secrets/my-tunnel/versions/latest", store.(*gcpCredentialsStore).name)
+
+	for _, invalid := range []string{
+		"file:///etc/cloudflared/creds.json",
+		"env://",
+		"vault:///secret",
+		"aws-sm://cloudflared/my-tunnel",
+		"gcp-sm://my-project/my-tunnel",
+	} {
+		_, err := newCredentialsStore(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestVaultCredentialsStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/cloudflared/my-tunnel", r.URL.Path)
+		assert.Equal(t, "token", r.Header.Get("X-Vault-Token"))
+		_, _ = io.WriteString(w, `{"data":{"data":{"AccountTag":"account","TunnelSecret":"c2VjcmV0","credentials":"{\"AccountTag\":\"other\"}"}}}`)
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	store, err := newCredentialsStore("vault:///secret/cloudflared/my-tunnel")
+	require.NoError(t, err)
+	body, err := store.Read(context.Background())
+	require.NoError(t, err)
+	var fields map[string]string
+	require.NoError(t, json.Unmarshal(body, &fields))
+	assert.Equal(t, "account", fields["AccountTag"])
+
+	store, err = newCredentialsStore("vault:///secret/cloudflared/my-tunnel?field=credentials")
+	require.NoError(t, err)
+	body, err = store.Read(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, `{"AccountTag":"other"}`, string(body))
+}
+
+func TestAWSCredentialsStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/20230102/us-east-1/secretsmanager/aws4_request, "+
+				"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature="))
+		var input map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		assert.Equal(t, "cloudflared/my-tunnel", input["SecretId"])
+		_ = json.NewEncoder(w).Encode(map[string]string{"SecretString": storedCredentials})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+
+	store, err := newCredentialsStore("aws-sm://cloudflared/my-tunnel?region=us-east-1")
+	require.NoError(t, err)
+	store.(*awsCredentialsStore).now = func() time.Time {
+		return time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	}
+	body, err := store.Read(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, storedCredentials, string(body))
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// Example of https://docs.aws.amazon.com/general/latest/gr/sigv4-calculate-signature.html
+	key := awsSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20150830", "us-east-1", "iam")
+	assert.Equal(t, "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9", hex.EncodeToString(key))
+}
+
+func TestGCPCredentialsStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = io.WriteString(w, `{"access_token":"token","expires_in":3599,"token_type":"Bearer"}`)
+		case "/v1/projects/my-project/secrets/my-tunnel/versions/3:access":
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(storedCredentials))},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+
+	store, err := newCredentialsStore("gcp-sm://projects/my-project/secrets/my-tunnel/versions/3")
+	require.NoError(t, err)
+	store.(*gcpCredentialsStore).endpoint = server.URL
+	store.(*gcpCredentialsStore).tokenURL = server.URL + "/token"
+	body, err := store.Read(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, storedCredentials, string(body))
+}
@@ -0,0 +1,96 @@
+package tunnel
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/dockerlabels"
+	"github.com/cloudflare/cloudflared/orchestration"
+)
+
+// startDockerLabels follows the labelled Docker containers, updating the generated ingress rules of the orchestrator
+// and routing the new hostnames to the named tunnel as containers start and stop.
+func startDockerLabels(
+	ctx context.Context,
+	c *cli.Context,
+	orchestrator *orchestration.Orchestrator,
+	namedTunnel *connection.NamedTunnelProperties,
+	log *zerolog.Logger,
+) error {
+	watcher, err := dockerlabels.NewWatcher(c.String(dockerHostFlag), log)
+	if err != nil {
+		return err
+	}
+	var router *dockerDNSRouter
+	if namedTunnel != nil && namedTunnel.QuickTunnelUrl == "" {
+		router = &dockerDNSRouter{c: c, namedTunnel: namedTunnel, routed: map[string]bool{}, log: log}
+	}
+	go func() {
+		_ = watcher.Run(ctx, func(routes []dockerlabels.Route) {
+			rules := make([]config.UnvalidatedIngressRule, len(routes))
+			hostnames := make([]string, len(routes))
+			for i, route := range routes {
+				rules[i] = route.IngressRule()
+				hostnames[i] = route.Hostname
+			}
+			if err := orchestrator.UpdateGeneratedRules(rules); err != nil {
+				log.Err(err).Msg("Failed to update the ingress rules of the Docker containers")
+				return
+			}
+			log.Info().Strs("hostnames", hostnames).Msg("Updated the ingress rules of the Docker containers")
+			if router != nil {
+				router.route(hostnames)
+			}
+		})
+	}()
+	return nil
+}
+
+// dockerDNSRouter routes the hostnames of containers to the tunnel, once per hostname. It needs the origin
+// certificate, without which DNS records have to be created by hand.
+type dockerDNSRouter struct {
+	c           *cli.Context
+	namedTunnel *connection.NamedTunnelProperties
+	client      cfapi.Client
+	disabled    bool
+	routed      map[string]bool
+	log         *zerolog.Logger
+}
+
+func (r *dockerDNSRouter) route(hostnames []string) {
+	if r.disabled {
+		return
+	}
+	for _, hostname := range hostnames {
+		if r.routed[hostname] || strings.HasPrefix(hostname, "*") {
+			continue
+		}
+		if r.client == nil {
+			sc, err := newSubcommandContext(r.c)
+			if err != nil {
+				r.log.Err(err).Msg("DNS routes of the Docker containers won't be created")
+				r.disabled = true
+				return
+			}
+			sc.log = r.log
+			if r.client, err = sc.client(); err != nil {
+				r.log.Err(err).Msg("DNS routes of the Docker containers won't be created, they need the origin certificate")
+				r.disabled = true
+				return
+			}
+		}
+		result, err := r.client.RouteTunnel(r.namedTunnel.Credentials.TunnelID, cfapi.NewDNSRoute(hostname, false))
+		if err != nil {
+			r.log.Err(err).Str("hostname", hostname).Msg("Failed to route the hostname of a Docker container to the tunnel")
+			continue
+		}
+		r.routed[hostname] = true
+		r.log.Info().Msg(result.SuccessSummary())
+	}
+}
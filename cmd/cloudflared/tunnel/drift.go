@@ -0,0 +1,132 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
+	"github.com/cloudflare/cloudflared/metrics"
+	"github.com/cloudflare/cloudflared/orchestration"
+)
+
+const (
+	connectorMetricsFlagName = "connector-metrics"
+	driftStatusTimeout       = 10 * time.Second
+)
+
+func buildDriftCommand() *cli.Command {
+	return &cli.Command{
+		Name:         "drift",
+		Action:       cliutil.ConfiguredAction(driftCommand),
+		BashComplete: cliutil.Complete(completeTunnels),
+		Usage:        "Compare the configuration file with the configuration that running connectors report",
+		UsageText:    "cloudflared tunnel [--config FILEPATH] ingress drift [--connector-metrics ADDRESS]... [TUNNEL]",
+		ArgsUsage:    "[TUNNEL]",
+		Description: "Computes the fingerprint of the configuration file and compares it with the fingerprints reported " +
+			"by connectors: the ones that every connector of TUNNEL reported when it registered, which requires the " +
+			"origin certificate, and the current ones served at /status by the metrics servers given with " +
+			"--connector-metrics. It fails if any connector runs a different configuration.",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  connectorMetricsFlagName,
+				Usage: "Address of the metrics server of a running connector, e.g. localhost:2000. Can be specified multiple times.",
+			},
+		},
+	}
+}
+
+func driftCommand(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cliutil.UsageError(`"cloudflared tunnel ingress drift" accepts at most one argument, the ID or name of the tunnel.`)
+	}
+	if c.NArg() == 0 && len(c.StringSlice(connectorMetricsFlagName)) == 0 {
+		return cliutil.UsageError(`"cloudflared tunnel ingress drift" needs a tunnel or at least one --%s.`, connectorMetricsFlagName)
+	}
+	conf, err := getConfiguration(c)
+	if err != nil {
+		return err
+	}
+	// Parse the rules the way the connector does, so that the fingerprints can be compared
+	log := logger.CreateLoggerFromContext(c, logger.DisableTerminalLog)
+	ing, err := ingress.ParseIngressFromConfigAndCLI(conf, c, log)
+	if err != nil {
+		return errors.Wrap(err, "Validation failed")
+	}
+	local, err := orchestration.ConfigFingerprint(&ing, ingress.NewWarpRoutingConfig(&conf.WarpRouting))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Local configuration fingerprint: %s\n", local)
+
+	drifted := 0
+	report := func(connector, fingerprint string) {
+		switch fingerprint {
+		case "":
+			fmt.Printf("%s: unknown, the connector doesn't report its configuration\n", connector)
+		case local:
+			fmt.Printf("%s: in sync\n", connector)
+		default:
+			drifted++
+			fmt.Printf("%s: drifted, it runs %s\n", connector, fingerprint)
+		}
+	}
+
+	for _, addr := range c.StringSlice(connectorMetricsFlagName) {
+		status, err := fetchConnectorStatus(addr)
+		if err != nil {
+			return err
+		}
+		report(addr, status.ConfigFingerprint)
+	}
+
+	if c.NArg() == 1 {
+		sc, err := newSubcommandContext(c)
+		if err != nil {
+			return err
+		}
+		tunnelID, err := sc.findID(c.Args().First())
+		if err != nil {
+			return errors.Wrap(err, "error parsing tunnel ID")
+		}
+		client, err := sc.client()
+		if err != nil {
+			return err
+		}
+		connectors, err := client.ListActiveClients(tunnelID)
+		if err != nil {
+			return err
+		}
+		for _, connector := range connectors {
+			report(fmt.Sprintf("connector %s", connector.ID), connector.ConfigFingerprint)
+		}
+	}
+
+	if drifted > 0 {
+		return fmt.Errorf("%d connector(s) run a different configuration", drifted)
+	}
+	return nil
+}
+
+func fetchConnectorStatus(addr string) (*metrics.Status, error) {
+	client := &http.Client{Timeout: driftStatusTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/status", addr))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the status of the connector at %s", addr)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the status of the connector at %s: %s", addr, resp.Status)
+	}
+	var status metrics.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the status of the connector at %s", addr)
+	}
+	return &status, nil
+}
@@ -0,0 +1,95 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
+	"github.com/cloudflare/cloudflared/orchestration"
+	"github.com/cloudflare/cloudflared/tlsconfig"
+)
+
+// effectiveConfig is what "tunnel run --dry-run" prints: the settings the tunnel would run with, once the
+// configuration file, the flags and the defaults are merged.
+type effectiveConfig struct {
+	TunnelID          string                      `json:"tunnelID"`
+	ConfigFile        string                      `json:"configFile,omitempty"`
+	ConfigFingerprint string                      `json:"configFingerprint"`
+	Ingress           []ingress.Rule              `json:"ingress"`
+	OriginRequest     ingress.OriginRequestConfig `json:"originRequest"`
+	WarpRouting       config.WarpRoutingConfig    `json:"warp-routing"`
+	Protocol          string                      `json:"protocol"`
+	Edge              effectiveEdgeConfig         `json:"edge"`
+	Flags             map[string]string           `json:"flags"`
+}
+
+type effectiveEdgeConfig struct {
+	Addresses     []string `json:"addresses,omitempty"`
+	Region        string   `json:"region,omitempty"`
+	IPVersion     string   `json:"ipVersion"`
+	BindAddress   string   `json:"bindAddress,omitempty"`
+	HAConnections int      `json:"haConnections"`
+	PostQuantum   bool     `json:"postQuantum"`
+	TLSPolicy     string   `json:"tlsPolicy"`
+	Retries       uint     `json:"retries"`
+	// ProtocolFallback is the order of protocols connections fall back through, if it's set
+	ProtocolFallback []string `json:"protocolFallback,omitempty"`
+	// HAProtocolMix is the mix of protocols of the HA connections, if it's set
+	HAProtocolMix string `json:"haProtocolMix,omitempty"`
+}
+
+// dryRun prints the effective configuration of the tunnel and exits without connecting to the edge.
+func dryRun(c *cli.Context, namedTunnel *connection.NamedTunnelProperties) error {
+	log := logger.CreateLoggerFromContext(c, logger.EnableTerminalLog)
+	logTransport := logger.CreateTransportLoggerFromContext(c, logger.EnableTerminalLog)
+	observer := connection.NewObserver(log, logTransport)
+
+	tunnelConfig, orchestratorConfig, err := prepareTunnelConfig(c, buildInfo, log, logTransport, observer, namedTunnel)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := orchestration.ConfigFingerprint(orchestratorConfig.Ingress, orchestratorConfig.WarpRouting)
+	if err != nil {
+		return err
+	}
+	edgeTLSPolicy, err := tlsconfig.ParsePolicy(c.String(edgeTLSMinVersionFlag), c.StringSlice(edgeTLSCipherSuitesFlag), c.StringSlice(edgeTLSCurvesFlag))
+	if err != nil {
+		return err
+	}
+
+	effective := effectiveConfig{
+		TunnelID:          namedTunnel.Credentials.TunnelID.String(),
+		ConfigFile:        config.GetConfiguration().Source(),
+		ConfigFingerprint: fingerprint,
+		Ingress:           ingress.RedactedRules(orchestratorConfig.Ingress.Rules),
+		OriginRequest:     orchestratorConfig.Ingress.Defaults.Redacted(),
+		WarpRouting:       orchestratorConfig.WarpRouting.RawConfig(),
+		Protocol:          tunnelConfig.ProtocolSelector.Current().String(),
+		Edge: effectiveEdgeConfig{
+			Addresses:     tunnelConfig.EdgeAddrs,
+			Region:        tunnelConfig.Region,
+			IPVersion:     tunnelConfig.EdgeIPVersion.String(),
+			HAConnections: tunnelConfig.HAConnections,
+			PostQuantum:   tunnelConfig.NeedPQ,
+			TLSPolicy:     edgeTLSPolicy.String(),
+			Retries:       tunnelConfig.Retries,
+			HAProtocolMix: tunnelConfig.HAProtocolMix.String(),
+		},
+		Flags: orchestratorConfig.ConfigurationFlags,
+	}
+	for _, p := range tunnelConfig.ProtocolFallback.Order {
+		effective.Edge.ProtocolFallback = append(effective.Edge.ProtocolFallback, p.String())
+	}
+	if tunnelConfig.EdgeBindAddr != nil {
+		effective.Edge.BindAddress = tunnelConfig.EdgeBindAddr.String()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(effective)
+}
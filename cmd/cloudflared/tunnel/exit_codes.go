@@ -0,0 +1,45 @@
+package tunnel
+
+import (
+	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+)
+
+// exitCodesHelp documents the exit codes in the help of the tunnel command.
+const exitCodesHelp = `EXIT CODES:
+	1	any other failure
+	2	invalid configuration file, ingress rules or flags
+	3	invalid tunnel credentials or token, or credentials refused by the API
+	4	missing origin certificate
+	5	edge unreachable
+	6	tunnel registration rejected by the edge
+	10	update failed
+	11	cloudflared was updated
+	255	invalid command line usage`
+
+// tunnelExitError gives the error that stopped the connections to the edge the exit code of its cause.
+func tunnelExitError(err error) error {
+	var (
+		discoveryErr    edgediscovery.DiscoveryError
+		dialErr         edgediscovery.DialError
+		noAddressesErr  edgediscovery.ErrNoAddressesLeft
+		quicDialErr     *connection.EdgeQuicDialError
+		idleTimeoutErr  *quic.IdleTimeoutError
+		registrationErr connection.ServerRegisterTunnelError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &registrationErr):
+		return cliutil.WithExitCode(cliutil.ExitCodeRegistrationRejected, err)
+	case errors.As(err, &discoveryErr), errors.As(err, &dialErr), errors.As(err, &noAddressesErr),
+		errors.As(err, &quicDialErr), errors.As(err, &idleTimeoutErr):
+		return cliutil.WithExitCode(cliutil.ExitCodeEdgeUnreachable, err)
+	default:
+		return err
+	}
+}
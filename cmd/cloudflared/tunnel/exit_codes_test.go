@@ -0,0 +1,64 @@
+package tunnel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/edgediscovery"
+)
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		exitCode int
+	}{
+		{
+			name:     "other error",
+			err:      fmt.Errorf("failed"),
+			exitCode: cliutil.ExitCodeError,
+		},
+		{
+			name:     "no edge address",
+			err:      tunnelExitError(edgediscovery.ErrNoAddressesLeft{}),
+			exitCode: cliutil.ExitCodeEdgeUnreachable,
+		},
+		{
+			name:     "edge dial error",
+			err:      tunnelExitError(&connection.EdgeQuicDialError{Cause: fmt.Errorf("timeout")}),
+			exitCode: cliutil.ExitCodeEdgeUnreachable,
+		},
+		{
+			name:     "registration rejected",
+			err:      tunnelExitError(connection.ServerRegisterTunnelError{Cause: fmt.Errorf("Unauthorized: tunnel not found"), Permanent: true}),
+			exitCode: cliutil.ExitCodeRegistrationRejected,
+		},
+		{
+			name:     "wrapped exit code",
+			err:      errors.Wrap(cliutil.WithExitCode(cliutil.ExitCodeConfig, fmt.Errorf("invalid ingress")), "error running tunnel"),
+			exitCode: cliutil.ExitCodeConfig,
+		},
+		{
+			name:     "API refused the credentials",
+			err:      errors.Wrap(cfapi.ErrUnauthorized, "REST request failed"),
+			exitCode: cliutil.ExitCodeCredentials,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			action := cliutil.WithErrorHandler(func(*cli.Context) error { return test.err })
+			err := action(cli.NewContext(cli.NewApp(), nil, nil))
+			exitCoder, ok := err.(cli.ExitCoder)
+			assert.True(t, ok)
+			assert.Equal(t, test.exitCode, exitCoder.ExitCode())
+			assert.Equal(t, test.err.Error(), err.Error())
+		})
+	}
+}
@@ -3,17 +3,27 @@ package tunnel
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/logger"
+	"github.com/cloudflare/cloudflared/proxy"
+	"github.com/cloudflare/cloudflared/recording"
 
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 )
 
-const ingressDataJSONFlagName = "json"
+const (
+	ingressDataJSONFlagName = "json"
+	ignoreHeaderFlagName    = "ignore-header"
+	methodFlagName          = "method"
+	headerFlagName          = "header"
+)
 
 var ingressDataJSON = &cli.StringFlag{
 	Name:    ingressDataJSONFlagName,
@@ -31,10 +41,16 @@ func buildIngressSubcommand() *cli.Command {
 		Hidden:    true,
 		Description: ` Cloudflared lets you route traffic from the internet to multiple different addresses on your
 		origin. Multiple-origin routing is configured by a set of rules. Each rule matches traffic
-		by its hostname or path, and routes it to an address. These rules are configured under the
-		'ingress' key of your config.yaml, for example:
+		by its hostname or path, and optionally its method and headers, and routes it to an address.
+		These rules are configured under the 'ingress' key of your config.yaml, for example:
 
 		ingress:
+		  - hostname: www.example.com
+		    path: /api/
+		    methods: [POST]
+		    headers:
+		      X-Env: ^staging$
+		    service: https://localhost:8003
 		  - hostname: www.example.com
 		    service: https://localhost:8000
 		  - hostname: *.example.xyz
@@ -44,11 +60,13 @@ func buildIngressSubcommand() *cli.Command {
 		    service: https://localhost:8002
 
 		To ensure cloudflared can route all incoming requests, the last rule must be a catch-all
-		rule that matches all traffic. You can validate these rules with the 'ingress validate'
-		command, and test which rule matches a particular URL with 'ingress rule <URL>'.
+		rule that matches all traffic. Rules are evaluated in order, and the first one that matches
+		applies. You can validate these rules with the 'ingress validate' command, which also warns
+		about rules that never match, and test which rule matches a particular URL with
+		'ingress rule <URL>'.
 
 		Multiple-origin routing is incompatible with the --url flag.`,
-		Subcommands: []*cli.Command{buildValidateIngressCommand(), buildTestURLCommand()},
+		Subcommands: []*cli.Command{buildValidateIngressCommand(), buildTestURLCommand(), buildReplayCommand(), buildDriftCommand()},
 	}
 }
 
@@ -68,13 +86,45 @@ func buildTestURLCommand() *cli.Command {
 		Name:      "rule",
 		Action:    cliutil.ConfiguredAction(testURLCommand),
 		Usage:     "Check which ingress rule matches a given request URL",
-		UsageText: "cloudflared tunnel [--config FILEPATH] ingress rule URL",
+		UsageText: "cloudflared tunnel [--config FILEPATH] ingress rule [--method METHOD] [--header \"NAME: VALUE\"] URL",
 		ArgsUsage: "URL",
 		Description: "Check which ingress rule matches a given request URL. " +
 			"Ingress rules match a request's hostname and path. Hostname is " +
 			"optional and is either a full hostname like `www.example.com` or a " +
 			"hostname with a `*` for its subdomains, e.g. `*.example.com`. Path " +
-			"is optional and matches a regular expression, like `/[a-zA-Z0-9_]+.html`",
+			"is optional and matches a regular expression, like `/[a-zA-Z0-9_]+.html`. " +
+			"Rules can also match the method and headers of the request, which are given with --method and --header.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  methodFlagName,
+				Usage: "Method of the request to test",
+				Value: http.MethodGet,
+			},
+			&cli.StringSliceFlag{
+				Name:  headerFlagName,
+				Usage: "Header of the request to test, like \"X-Env: staging\". Can be specified multiple times.",
+			},
+		},
+	}
+}
+
+func buildReplayCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "replay",
+		Action:    cliutil.ConfiguredAction(replayCommand),
+		Usage:     "Replay recorded traffic against the ingress configuration and report any response that changed",
+		UsageText: "cloudflared tunnel [--config FILEPATH] ingress replay [--ignore-header NAME] RECORDING",
+		ArgsUsage: "RECORDING",
+		Description: "Replays every request recorded with `cloudflared tunnel run --record-traffic` through the ingress " +
+			"rules of the configuration file, and compares the responses with the recorded ones. This can be used to " +
+			"check that a change to the ingress rules or to an origin does not change how existing traffic is served. " +
+			"Sensitive headers were redacted when recording, so they are neither replayed nor compared.",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  ignoreHeaderFlagName,
+				Usage: "Response header that is expected to differ between the recording and the replay. Can be specified multiple times.",
+			},
+		},
 	}
 }
 
@@ -82,15 +132,19 @@ func buildTestURLCommand() *cli.Command {
 func validateIngressCommand(c *cli.Context, warnings string) error {
 	conf, err := getConfiguration(c)
 	if err != nil {
-		return err
+		return cliutil.WithExitCode(cliutil.ExitCodeConfig, err)
 	}
 
-	if _, err := ingress.ParseIngress(conf); err != nil {
-		return errors.Wrap(err, "Validation failed")
+	ing, err := ingress.ParseIngress(conf)
+	if err != nil {
+		return cliutil.WithExitCode(cliutil.ExitCodeConfig, errors.Wrap(err, "Validation failed"))
 	}
 	if c.IsSet("url") {
 		return ingress.ErrURLIncompatibleWithIngress
 	}
+	for _, shadowed := range ing.ShadowedRules() {
+		fmt.Println("Warning:", shadowed)
+	}
 	if warnings != "" {
 		fmt.Println("Warning: unused keys detected in your config file. Here is a list of unused keys:")
 		fmt.Println(warnings)
@@ -138,8 +192,71 @@ func testURLCommand(c *cli.Context) error {
 		return errors.Wrap(err, "Validation failed")
 	}
 
-	_, i := ing.FindMatchingRule(requestURL.Hostname(), requestURL.Path)
+	req := &http.Request{
+		Method: strings.ToUpper(c.String(methodFlagName)),
+		URL:    requestURL,
+		Host:   requestURL.Hostname(),
+		Header: make(http.Header),
+	}
+	for _, header := range c.StringSlice(headerFlagName) {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("%s is not a valid header, it should be like \"NAME: VALUE\"", header)
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	_, i := ing.FindMatchingRequestRule(req)
 	fmt.Printf("Matched rule #%d\n", i+1)
 	fmt.Println(ing.Rules[i].MultiLineString())
 	return nil
 }
+
+// replayCommand replays recorded exchanges through the ingress rules and reports responses that differ.
+func replayCommand(c *cli.Context) error {
+	recordingPath := c.Args().First()
+	if recordingPath == "" {
+		return errors.New("cloudflared tunnel ingress replay expects a single argument, the recording file")
+	}
+	exchanges, err := recording.ReadFile(recordingPath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read recording")
+	}
+
+	conf := config.GetConfiguration()
+	fmt.Println("Using rules from", conf.Source())
+	ing, err := ingress.ParseIngress(conf)
+	if err != nil {
+		return errors.Wrap(err, "Validation failed")
+	}
+
+	log := logger.CreateLoggerFromContext(c, logger.DisableTerminalLog)
+	shutdownC := make(chan struct{})
+	defer close(shutdownC)
+	if err := ing.StartOrigins(log, shutdownC); err != nil {
+		return errors.Wrap(err, "failed to start origin")
+	}
+	originProxy := proxy.NewOriginProxy(ing, ingress.WarpRoutingConfig{}, nil, log, proxy.Options{})
+
+	changed := 0
+	for _, exchange := range exchanges {
+		result, err := recording.Replay(c.Context, originProxy, exchange, c.StringSlice(ignoreHeaderFlagName), log)
+		if err != nil {
+			return err
+		}
+		if len(result.Diffs) == 0 {
+			fmt.Printf("SAME    %s %s%s\n", exchange.Method, exchange.Host, exchange.URI)
+			continue
+		}
+		changed++
+		fmt.Printf("CHANGED %s %s%s\n", exchange.Method, exchange.Host, exchange.URI)
+		for _, diff := range result.Diffs {
+			fmt.Printf("\t%s\n", diff)
+		}
+	}
+
+	fmt.Printf("Replayed %d requests, %d responses changed\n", len(exchanges), changed)
+	if changed > 0 {
+		return fmt.Errorf("%d of %d replayed responses differ from the recording", changed, len(exchanges))
+	}
+	return nil
+}
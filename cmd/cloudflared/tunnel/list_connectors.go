@@ -0,0 +1,121 @@
+package tunnel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+// listedTunnel is a tunnel as "tunnel list" shows it, with a summary of its connectors.
+type listedTunnel struct {
+	cfapi.Tunnel      `yaml:",inline"`
+	connectorsSummary `yaml:",inline"`
+}
+
+// connectorsSummary tells whether a tunnel is served, and by up-to-date connectors.
+type connectorsSummary struct {
+	ActiveConnectors int `json:"active_connectors" yaml:"active_connectors"`
+	// OutdatedConnectors run a version older than the newest one that is running or than this cloudflared
+	OutdatedConnectors int `json:"outdated_connectors" yaml:"outdated_connectors"`
+}
+
+// summarizeConnectors fetches the connectors of the tunnels that have connections, and counts the ones that are
+// outdated compared to the newest version among them and currentVersion.
+func (sc *subcommandContext) summarizeConnectors(tunnels []*cfapi.Tunnel, currentVersion string) ([]*listedTunnel, error) {
+	client, err := sc.client()
+	if err != nil {
+		return nil, err
+	}
+	connectors := make([][]*cfapi.ActiveClient, len(tunnels))
+	err = runBulk(len(tunnels), sc.bulkConcurrency(), func(i int) error {
+		if len(tunnels[i].Connections) == 0 {
+			return nil
+		}
+		tunnelConnectors, err := client.ListActiveClients(tunnels[i].ID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the connectors of tunnel %s", tunnels[i].ID)
+		}
+		connectors[i] = tunnelConnectors
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newListedTunnels(tunnels, connectors, currentVersion), nil
+}
+
+func newListedTunnels(tunnels []*cfapi.Tunnel, connectors [][]*cfapi.ActiveClient, currentVersion string) []*listedTunnel {
+	newest := currentVersion
+	for _, tunnelConnectors := range connectors {
+		for _, connector := range tunnelConnectors {
+			if parseVersion(newest) == nil || olderVersion(newest, connector.Version) {
+				newest = connector.Version
+			}
+		}
+	}
+
+	listed := make([]*listedTunnel, len(tunnels))
+	for i, tunnel := range tunnels {
+		listed[i] = &listedTunnel{Tunnel: *tunnel}
+		for _, connector := range connectors[i] {
+			listed[i].ActiveConnectors++
+			if olderVersion(connector.Version, newest) {
+				listed[i].OutdatedConnectors++
+			}
+		}
+	}
+	return listed
+}
+
+// lastSeen is when the tunnel last had a connection, as shown by "tunnel list".
+func (t *listedTunnel) lastSeen() string {
+	switch {
+	case t.ActiveConnectors > 0 || len(t.Connections) > 0:
+		return "now"
+	case t.ConnsInactiveAt != nil:
+		return t.ConnsInactiveAt.Format(time.RFC3339)
+	default:
+		return "never"
+	}
+}
+
+func (t *listedTunnel) fmtConnectors() string {
+	if t.OutdatedConnectors > 0 {
+		return fmt.Sprintf("%d (%d outdated)", t.ActiveConnectors, t.OutdatedConnectors)
+	}
+	return strconv.Itoa(t.ActiveConnectors)
+}
+
+// parseVersion parses a cloudflared version such as 2023.3.0, it returns nil for other versions, e.g. DEV.
+func parseVersion(version string) []int {
+	parts := strings.Split(version, ".")
+	parsed := make([]int, len(parts))
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		parsed[i] = number
+	}
+	return parsed
+}
+
+// olderVersion tells whether version a is older than version b. Versions that can't be parsed aren't older or newer
+// than any other.
+func olderVersion(a, b string) bool {
+	parsedA, parsedB := parseVersion(a), parseVersion(b)
+	if parsedA == nil || parsedB == nil {
+		return false
+	}
+	for i := 0; i < len(parsedA) && i < len(parsedB); i++ {
+		if parsedA[i] != parsedB[i] {
+			return parsedA[i] < parsedB[i]
+		}
+	}
+	return len(parsedA) < len(parsedB)
+}
@@ -0,0 +1,51 @@
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+func TestOlderVersion(t *testing.T) {
+	assert.True(t, olderVersion("2023.3.0", "2023.3.1"))
+	assert.True(t, olderVersion("2022.12.1", "2023.1.0"))
+	assert.True(t, olderVersion("2023.3", "2023.3.0"))
+	assert.False(t, olderVersion("2023.3.1", "2023.3.1"))
+	assert.False(t, olderVersion("2023.10.0", "2023.9.0"))
+	assert.False(t, olderVersion("DEV", "2023.3.0"))
+	assert.False(t, olderVersion("2023.3.0", "DEV"))
+}
+
+func TestNewListedTunnels(t *testing.T) {
+	inactiveAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	served := &cfapi.Tunnel{ID: uuid.New(), Name: "served", Connections: []cfapi.Connection{{ColoName: "LIS"}}}
+	outdated := &cfapi.Tunnel{ID: uuid.New(), Name: "outdated", Connections: []cfapi.Connection{{ColoName: "LIS"}}}
+	dead := &cfapi.Tunnel{ID: uuid.New(), Name: "dead", ConnsInactiveAt: &inactiveAt}
+	unused := &cfapi.Tunnel{ID: uuid.New(), Name: "unused"}
+
+	listed := newListedTunnels(
+		[]*cfapi.Tunnel{served, outdated, dead, unused},
+		[][]*cfapi.ActiveClient{
+			{{Version: "2023.3.1"}, {Version: "2023.3.1"}},
+			{{Version: "2023.3.0"}, {Version: "2023.3.1"}},
+			nil,
+			nil,
+		},
+		"DEV",
+	)
+
+	assert.Equal(t, "2", listed[0].fmtConnectors())
+	assert.Equal(t, "now", listed[0].lastSeen())
+	assert.Equal(t, "2 (1 outdated)", listed[1].fmtConnectors())
+	assert.Equal(t, "0", listed[2].fmtConnectors())
+	assert.Equal(t, "2023-03-01T12:00:00Z", listed[2].lastSeen())
+	assert.Equal(t, "never", listed[3].lastSeen())
+
+	// Connectors older than this cloudflared are outdated too
+	listed = newListedTunnels([]*cfapi.Tunnel{served}, [][]*cfapi.ActiveClient{{{Version: "2023.3.1"}}}, "2023.4.0")
+	assert.Equal(t, 1, listed[0].OutdatedConnectors)
+}
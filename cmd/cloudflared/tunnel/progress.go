@@ -0,0 +1,146 @@
+package tunnel
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const progressRefreshPeriod = 100 * time.Millisecond
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// progress shows how far an operation on many items got, with a spinner when the output is a terminal, and
+// summarizes the result of every item once the operation is done.
+type progress struct {
+	out     io.Writer
+	label   string
+	items   []string
+	spinner bool
+
+	lock   sync.Mutex
+	done   int
+	failed []bool
+
+	stopC    chan struct{}
+	stoppedC chan struct{}
+}
+
+func newProgress(out io.Writer, label string, items []string, spinner bool) *progress {
+	return &progress{
+		out:      out,
+		label:    label,
+		items:    items,
+		spinner:  spinner,
+		failed:   make([]bool, len(items)),
+		stopC:    make(chan struct{}),
+		stoppedC: make(chan struct{}),
+	}
+}
+
+// start draws the spinner until finish is called.
+func (p *progress) start() {
+	if !p.spinner {
+		close(p.stoppedC)
+		return
+	}
+	go func() {
+		defer close(p.stoppedC)
+		ticker := time.NewTicker(progressRefreshPeriod)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			p.draw(spinnerFrames[frame%len(spinnerFrames)])
+			select {
+			case <-ticker.C:
+			case <-p.stopC:
+				// Clear the spinner line
+				fmt.Fprint(p.out, "\r\033[K")
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) draw(frame string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	failed := p.countFailed()
+	line := fmt.Sprintf("%s %s %d/%d", frame, p.label, p.done, len(p.items))
+	if failed > 0 {
+		line += fmt.Sprintf(" (%d failed)", failed)
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s", line)
+}
+
+// itemDone records the result of the item at index i.
+func (p *progress) itemDone(i int, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.done++
+	p.failed[i] = err != nil
+}
+
+// stop stops the spinner.
+func (p *progress) stop() {
+	close(p.stopC)
+	<-p.stoppedC
+}
+
+// finish stops the spinner and prints the result of every item.
+func (p *progress) finish() {
+	p.stop()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	failed := p.countFailed()
+	fmt.Fprintf(p.out, "%s: %d succeeded, %d failed\n", p.label, len(p.items)-failed, failed)
+	for i, item := range p.items {
+		status := "ok"
+		if p.failed[i] {
+			status = "failed"
+		}
+		fmt.Fprintf(p.out, "  %-7s %s\n", status, item)
+	}
+}
+
+func (p *progress) countFailed() int {
+	failed := 0
+	for _, itemFailed := range p.failed {
+		if itemFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// runBulkWithProgress is runBulk for operations on the given items that the user waits for. When there are several
+// items, it shows a spinner on a terminal unless --no-progress is set, and summarizes the result of every item.
+func (sc *subcommandContext) runBulkWithProgress(label string, items []string, op func(i int) error) error {
+	if len(items) < 2 {
+		return runBulk(len(items), sc.bulkConcurrency(), op)
+	}
+	p := sc.newProgress(label, items)
+	err := p.run(sc.bulkConcurrency(), op)
+	p.finish()
+	return err
+}
+
+// newProgress returns a progress for the given items, with a spinner on a terminal unless --no-progress is set.
+func (sc *subcommandContext) newProgress(label string, items []string) *progress {
+	spinner := !sc.c.Bool(noProgressFlag.Name) && term.IsTerminal(int(os.Stderr.Fd()))
+	return newProgress(os.Stderr, label, items, spinner)
+}
+
+// run starts the progress and calls op on every item with runBulk. Stop the progress with stop or finish afterwards.
+func (p *progress) run(concurrency int, op func(i int) error) error {
+	p.start()
+	return runBulk(len(p.items), concurrency, func(i int) error {
+		err := op(i)
+		p.itemDone(i, err)
+		return err
+	})
+}
@@ -0,0 +1,32 @@
+package tunnel
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressSummary(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out, "Deleting tunnels", []string{"a", "b", "c"}, false)
+	p.start()
+	p.itemDone(2, nil)
+	p.itemDone(0, fmt.Errorf("failed"))
+	p.itemDone(1, nil)
+	p.finish()
+	assert.Equal(t, "Deleting tunnels: 2 succeeded, 1 failed\n  failed  a\n  ok      b\n  ok      c\n", out.String())
+}
+
+func TestProgressSpinner(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out, "Deleting tunnels", []string{"a", "b"}, true)
+	p.start()
+	p.itemDone(0, fmt.Errorf("failed"))
+	p.finish()
+	// The spinner is drawn at least once, and cleared before the summary
+	assert.True(t, strings.HasPrefix(out.String(), "\r\033[K| Deleting tunnels "), out.String())
+	assert.True(t, strings.HasSuffix(out.String(), "\r\033[KDeleting tunnels: 1 succeeded, 1 failed\n  failed  a\n  ok      b\n"), out.String())
+}
@@ -0,0 +1,84 @@
+package tunnel
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/orchestration"
+)
+
+// remoteConfigCachePath returns the file that the remote configuration of the tunnel is cached in, by default next to
+// the origin certificate, or in the default configuration directory for tunnels run without one, e.g. with a token.
+func remoteConfigCachePath(c *cli.Context, credentials connection.Credentials, log *zerolog.Logger) (string, error) {
+	if path := c.String(remoteConfigCacheFlag.Name); path != "" {
+		return path, nil
+	}
+	fileName := fmt.Sprintf("%s.config.json", credentials.TunnelID)
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return "", err
+	}
+	sc.log = log
+	credential, err := sc.credential()
+	if err != nil {
+		dir := config.DefaultConfigDirectory()
+		if dir == "" {
+			return "", err
+		}
+		return filepath.Join(dir, fileName), nil
+	}
+	return filepath.Join(filepath.Dir(credential.CertPath()), fileName), nil
+}
+
+// loadRemoteConfig applies the remote configuration of the tunnel before its connections are registered, fetched from
+// the Cloudflare API, or else the one cached by the previous run. The edge pushes the updates once connected either way.
+func loadRemoteConfig(c *cli.Context, orchestrator *orchestration.Orchestrator, credentials connection.Credentials, log *zerolog.Logger) {
+	err := fetchRemoteConfig(c, orchestrator, credentials, log)
+	if err == nil {
+		return
+	}
+	log.Err(err).Msg("Failed to fetch the remote configuration of the tunnel, starting with the cached one")
+	version, err := orchestrator.LoadCachedRemoteConfig()
+	if err != nil {
+		log.Err(err).Msg("Failed to load the cached remote configuration, the tunnel starts with the local one until the edge pushes the remote one")
+		return
+	}
+	log.Info().Int32("version", version).Msg("Loaded the cached remote configuration")
+}
+
+func fetchRemoteConfig(c *cli.Context, orchestrator *orchestration.Orchestrator, credentials connection.Credentials, log *zerolog.Logger) error {
+	client, err := remoteConfigClient(c, credentials, log)
+	if err != nil {
+		return err
+	}
+	configuration, err := client.GetTunnelConfiguration(credentials.TunnelID)
+	if err != nil {
+		return err
+	}
+	if len(configuration.Config) == 0 || string(configuration.Config) == "null" {
+		log.Warn().Msg("The tunnel has no remote configuration, it's managed with a configuration file")
+		return nil
+	}
+	return orchestrator.UpdateConfig(configuration.Version, configuration.Config).Err
+}
+
+// remoteConfigClient returns the Cloudflare API client to fetch the remote configuration with: with the API token of
+// --remote-config-api-token and the account of the tunnel if it's set, which tunnels run with a token need since they
+// have no origin certificate, or else with the origin certificate.
+func remoteConfigClient(c *cli.Context, credentials connection.Credentials, log *zerolog.Logger) (cfapi.TunnelClient, error) {
+	if apiToken := c.String(remoteConfigAPITokenFlag.Name); apiToken != "" {
+		return cfapi.NewRESTClient(c.String("api-url"), credentials.AccountTag, "", apiToken, buildInfo.UserAgent(), log)
+	}
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return nil, err
+	}
+	sc.log = log
+	return sc.client()
+}
@@ -0,0 +1,45 @@
+package tunnel
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+func TestRemoteConfigClientWithAPIToken(t *testing.T) {
+	credentials := connection.Credentials{AccountTag: "account", TunnelID: uuid.New()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer api-token", r.Header.Get("Authorization"))
+		assert.Equal(t, fmt.Sprintf("/accounts/account/cfd_tunnel/%s/configurations", credentials.TunnelID), r.URL.Path)
+		fmt.Fprint(w, `{"success":true,"result":{"version":3,"config":{"ingress":[{"service":"http_status:404"}]}}}`)
+	}))
+	defer server.Close()
+
+	previousBuildInfo := buildInfo
+	buildInfo = &cliutil.BuildInfo{CloudflaredVersion: "test"}
+	defer func() { buildInfo = previousBuildInfo }()
+
+	flagSet := flag.NewFlagSet("test", flag.PanicOnError)
+	flagSet.String("api-url", server.URL, "")
+	flagSet.String(remoteConfigAPITokenFlag.Name, "api-token", "")
+	c := cli.NewContext(cli.NewApp(), flagSet, nil)
+	log := zerolog.Nop()
+
+	// No origin certificate is needed
+	client, err := remoteConfigClient(c, credentials, &log)
+	require.NoError(t, err)
+	configuration, err := client.GetTunnelConfiguration(credentials.TunnelID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), configuration.Version)
+}
@@ -0,0 +1,128 @@
+package tunnel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+// dnsRouteLine is a hostname to route, read from a file given to "route dns --from-file".
+type dnsRouteLine struct {
+	hostname          string
+	overwriteExisting bool
+}
+
+// parseDNSRoutesFile reads one hostname per line, optionally followed by --overwrite-dns (or -f) to overwrite its
+// existing DNS record. Empty lines and lines starting with # are skipped.
+func parseDNSRoutesFile(r io.Reader, overwriteAll bool) ([]dnsRouteLine, error) {
+	var routes []dnsRouteLine
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		route := dnsRouteLine{hostname: fields[0], overwriteExisting: overwriteAll}
+		for _, option := range fields[1:] {
+			switch strings.TrimLeft(option, "-") {
+			case overwriteDNSFlagName, "f":
+				route.overwriteExisting = true
+			default:
+				return nil, fmt.Errorf("line %d: unknown option %s, only --%s is supported", lineNumber, option, overwriteDNSFlagName)
+			}
+		}
+		if !validateHostname(route.hostname, true) {
+			return nil, fmt.Errorf("line %d: %s is not a valid hostname", lineNumber, route.hostname)
+		}
+		if previous, ok := seen[route.hostname]; ok {
+			return nil, fmt.Errorf("line %d: %s is already routed at line %d", lineNumber, route.hostname, previous)
+		}
+		seen[route.hostname] = lineNumber
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("the file doesn't list any hostname")
+	}
+	return routes, nil
+}
+
+// routeDNSFromFile routes every hostname of the file given with --from-file to the tunnel, and prints a table of the
+// results.
+func routeDNSFromFile(c *cli.Context) error {
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+	tunnelID, err := sc.findID(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	path := c.String(routeFromFileFlag.Name)
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer file.Close()
+	routes, err := parseDNSRoutesFile(file, c.Bool(overwriteDNSFlagName))
+	if err != nil {
+		return errors.Wrapf(err, "invalid routes in %s", path)
+	}
+
+	hostnames := make([]string, len(routes))
+	for i, route := range routes {
+		hostnames[i] = route.hostname
+	}
+	// The client is created before the hostnames are routed concurrently
+	if _, err := sc.client(); err != nil {
+		return err
+	}
+	results := make([]string, len(routes))
+	p := sc.newProgress("Routing hostnames", hostnames)
+	err = p.run(sc.bulkConcurrency(), func(i int) error {
+		res, err := sc.route(tunnelID, cfapi.NewDNSRoute(routes[i].hostname, routes[i].overwriteExisting))
+		if err != nil {
+			results[i] = fmt.Sprintf("failed: %v", err)
+			return errors.Wrapf(err, "failed to route %s", routes[i].hostname)
+		}
+		results[i] = dnsRouteChange(res)
+		return nil
+	})
+	p.stop()
+
+	writer := tabWriter()
+	_, _ = fmt.Fprintln(writer, "HOSTNAME\tOVERWRITE\tRESULT\t")
+	for i, route := range routes {
+		_, _ = fmt.Fprintf(writer, "%s\t%t\t%s\t\n", route.hostname, route.overwriteExisting, results[i])
+	}
+	_ = writer.Flush()
+	return err
+}
+
+func dnsRouteChange(res cfapi.HostnameRouteResult) string {
+	dnsRes, ok := res.(*cfapi.DNSRouteResult)
+	if !ok {
+		return res.SuccessSummary()
+	}
+	switch dnsRes.CName {
+	case cfapi.ChangeNew:
+		return "added"
+	case cfapi.ChangeUpdated:
+		return "overwritten"
+	case cfapi.ChangeUnchanged:
+		return "unchanged"
+	default:
+		return dnsRes.CName
+	}
+}
@@ -0,0 +1,40 @@
+package tunnel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDNSRoutesFile(t *testing.T) {
+	content := `# Hostnames of the app
+app.example.com
+  api.example.com   --overwrite-dns
+
+*.preview.example.com -f
+`
+	routes, err := parseDNSRoutesFile(strings.NewReader(content), false)
+	require.NoError(t, err)
+	assert.Equal(t, []dnsRouteLine{
+		{hostname: "app.example.com"},
+		{hostname: "api.example.com", overwriteExisting: true},
+		{hostname: "*.preview.example.com", overwriteExisting: true},
+	}, routes)
+
+	routes, err = parseDNSRoutesFile(strings.NewReader(content), true)
+	require.NoError(t, err)
+	assert.True(t, routes[0].overwriteExisting)
+
+	for content, expectedErr := range map[string]string{
+		"app.example.com --force\n":               "line 1: unknown option --force, only --overwrite-dns is supported",
+		"app.example.com\nnot a hostname!\n":      "line 2: unknown option a, only --overwrite-dns is supported",
+		"app.example.com\nbad/hostname\n":         "line 2: bad/hostname is not a valid hostname",
+		"app.example.com\n\napp.example.com -f\n": "line 3: app.example.com is already routed at line 1",
+		"# Nothing to route\n":                    "the file doesn't list any hostname",
+	} {
+		_, err := parseDNSRoutesFile(strings.NewReader(content), false)
+		assert.EqualError(t, err, expectedErr)
+	}
+}
@@ -0,0 +1,175 @@
+package tunnel
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+)
+
+const (
+	dnsRouteActive  = "active"
+	dnsRouteDeleted = "deleted tunnel"
+	dnsRouteUnknown = "unknown tunnel"
+)
+
+var dnsRouteStaleFlag = &cli.BoolFlag{
+	Name:  "stale",
+	Usage: "Only list the DNS routes to tunnels that are deleted or aren't in the account anymore",
+}
+
+func buildRouteDNSListCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Action:    cliutil.ConfiguredAction(routeDNSListCommand),
+		Usage:     "List the DNS CNAME records that route to a tunnel, or to any tunnel",
+		UsageText: "cloudflared tunnel route dns list [--stale] [TUNNEL]",
+		Description: `Lists the CNAME records of the zone of the origin certificate that route to the given tunnel, or to any
+tunnel, with whether their tunnel is active, deleted or unknown to the account. Records to deleted tunnels don't
+route anywhere and can be removed with "cloudflared tunnel route dns delete HOSTNAME".`,
+		Flags:        []cli.Flag{outputFormatFlag, dnsRouteStaleFlag},
+		BashComplete: cliutil.Complete(completeTunnels),
+	}
+}
+
+func buildRouteDNSDeleteCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "delete",
+		Action:      cliutil.ConfiguredAction(routeDNSDeleteCommand),
+		Usage:       "Delete the DNS CNAME records that route hostnames to tunnels",
+		UsageText:   "cloudflared tunnel route dns delete [--yes] HOSTNAME...",
+		Description: `Deletes the CNAME records of the given hostnames. Records that don't route to a tunnel are left untouched.`,
+		Flags:       []cli.Flag{yesFlag},
+	}
+}
+
+// listedDNSRoute is a DNS route to a tunnel, as listed by "route dns list".
+type listedDNSRoute struct {
+	Hostname   string    `json:"hostname"`
+	TunnelID   uuid.UUID `json:"tunnel_id"`
+	TunnelName string    `json:"tunnel_name,omitempty"`
+	Status     string    `json:"status"`
+	Proxied    bool      `json:"proxied"`
+	CreatedOn  time.Time `json:"created_on"`
+}
+
+func routeDNSListCommand(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cliutil.UsageError(`"cloudflared tunnel route dns list" accepts at most one argument, the ID or name of the tunnel.`)
+	}
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+	tunnelID := uuid.Nil
+	if c.NArg() == 1 {
+		if tunnelID, err = sc.findID(c.Args().First()); err != nil {
+			return errors.Wrap(err, "error parsing tunnel ID")
+		}
+	}
+
+	client, err := sc.client()
+	if err != nil {
+		return err
+	}
+	records, err := client.ListDNSRoutes(tunnelID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't list the DNS routes")
+	}
+	// Deleted tunnels are listed too, to tell stale routes apart
+	tunnels, err := sc.list(cfapi.NewTunnelFilter())
+	if err != nil {
+		return err
+	}
+	routes := describeDNSRoutes(records, tunnels, c.Bool(dnsRouteStaleFlag.Name))
+
+	if outputFormat := c.String(outputFormatFlag.Name); outputFormat != "" {
+		return renderOutput(outputFormat, routes)
+	}
+	if len(routes) == 0 {
+		fmt.Println("No DNS routes were found.")
+		return nil
+	}
+	writer := tabWriter()
+	defer writer.Flush()
+	_, _ = fmt.Fprintln(writer, "HOSTNAME\tTUNNEL ID\tTUNNEL NAME\tSTATUS\tPROXIED\tCREATED\t")
+	for _, route := range routes {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%t\t%s\t\n",
+			route.Hostname, route.TunnelID, route.TunnelName, route.Status, route.Proxied, route.CreatedOn.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// describeDNSRoutes tells the status of the tunnel of each DNS route, sorted by hostname. With onlyStale, the routes to
+// active tunnels are left out.
+func describeDNSRoutes(records []*cfapi.DNSRecord, tunnels []*cfapi.Tunnel, onlyStale bool) []*listedDNSRoute {
+	tunnelsByID := make(map[uuid.UUID]*cfapi.Tunnel, len(tunnels))
+	for _, tunnel := range tunnels {
+		tunnelsByID[tunnel.ID] = tunnel
+	}
+	routes := make([]*listedDNSRoute, 0, len(records))
+	for _, record := range records {
+		tunnelID, _ := record.TunnelID()
+		route := &listedDNSRoute{
+			Hostname:  record.Name,
+			TunnelID:  tunnelID,
+			Status:    dnsRouteUnknown,
+			Proxied:   record.Proxied,
+			CreatedOn: record.CreatedOn,
+		}
+		if tunnel, ok := tunnelsByID[tunnelID]; ok {
+			route.TunnelName = tunnel.Name
+			route.Status = dnsRouteActive
+			if !tunnel.DeletedAt.IsZero() {
+				route.Status = dnsRouteDeleted
+			}
+		}
+		if onlyStale && route.Status == dnsRouteActive {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Hostname < routes[j].Hostname
+	})
+	return routes
+}
+
+func routeDNSDeleteCommand(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return cliutil.UsageError(`"cloudflared tunnel route dns delete" requires at least 1 argument, the hostnames whose DNS route to delete.`)
+	}
+	hostnames := c.Args().Slice()
+	for _, hostname := range hostnames {
+		if !validateHostname(hostname, true) {
+			return errors.Errorf("%s is not a valid hostname", hostname)
+		}
+	}
+	if err := confirmDestruction(c, fmt.Sprintf("Deleting the DNS routes of %d hostname(s)", len(hostnames)), hostnames); err != nil {
+		return err
+	}
+
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+	client, err := sc.client()
+	if err != nil {
+		return err
+	}
+	return sc.runBulkWithProgress("Deleting DNS routes", hostnames, func(i int) error {
+		record, err := client.DeleteDNSRoute(hostnames[i])
+		if err != nil {
+			return errors.Wrapf(err, "couldn't delete the DNS route of %s", hostnames[i])
+		}
+		tunnelID, _ := record.TunnelID()
+		sc.log.Info().Msgf("Deleted CNAME %s which routed to tunnel %s", record.Name, tunnelID)
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+func TestDescribeDNSRoutes(t *testing.T) {
+	active := &cfapi.Tunnel{ID: uuid.New(), Name: "active"}
+	deleted := &cfapi.Tunnel{ID: uuid.New(), Name: "deleted", DeletedAt: time.Now()}
+	unknownID := uuid.New()
+	record := func(hostname string, tunnelID uuid.UUID) *cfapi.DNSRecord {
+		return &cfapi.DNSRecord{Type: "CNAME", Name: hostname, Content: tunnelID.String() + "." + cfapi.TunnelDomain}
+	}
+	records := []*cfapi.DNSRecord{
+		record("c.example.com", unknownID),
+		record("a.example.com", active.ID),
+		record("b.example.com", deleted.ID),
+	}
+
+	routes := describeDNSRoutes(records, []*cfapi.Tunnel{active, deleted}, false)
+	assert.Equal(t, []*listedDNSRoute{
+		{Hostname: "a.example.com", TunnelID: active.ID, TunnelName: "active", Status: dnsRouteActive},
+		{Hostname: "b.example.com", TunnelID: deleted.ID, TunnelName: "deleted", Status: dnsRouteDeleted},
+		{Hostname: "c.example.com", TunnelID: unknownID, Status: dnsRouteUnknown},
+	}, routes)
+
+	routes = describeDNSRoutes(records, []*cfapi.Tunnel{active, deleted}, true)
+	assert.Len(t, routes, 2)
+	assert.Equal(t, "b.example.com", routes[0].Hostname)
+}
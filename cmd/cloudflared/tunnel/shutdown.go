@@ -0,0 +1,132 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// unregisterTimeout is how long the connections to the edge have to finish unregistering before they are closed anyway.
+const unregisterTimeout = 5 * time.Second
+
+// tunnelShutdown holds what's needed to stop the connections to the edge in order.
+type tunnelShutdown struct {
+	// drainer tracks the requests in flight, without it they are unknown so the whole grace period is waited
+	drainer *connection.Drainer
+	// unregisterC is closed to unregister the connections from the edge
+	unregisterC chan struct{}
+	// stoppedC is closed once all the connections to the edge are closed
+	stoppedC chan struct{}
+	// cancel closes the connections to the edge that are left
+	cancel func()
+}
+
+// waitToShutdown waits for a graceful shutdown or for a component to fail, then stops the components in order:
+//  1. unregister the connections from the edge, so the edge doesn't route new requests to this connector anymore;
+//  2. wait for the in-flight requests to complete, up to gracePeriod, unless shutting down because of an error;
+//  3. close the connections to the edge;
+//  4. cancel the server context to stop the remaining components, like the metrics server, and wait for them.
+//
+// Each stage is logged with its duration, so a hanging shutdown shows where it's stuck.
+func waitToShutdown(wg *sync.WaitGroup,
+	cancelServerContext func(),
+	errC <-chan error,
+	graceShutdownC <-chan struct{},
+	gracePeriod time.Duration,
+	tunnel *tunnelShutdown,
+	log *zerolog.Logger,
+) error {
+	var err error
+	graceful := false
+	select {
+	case err = <-errC:
+		log.Error().Err(err).Msg("Initiating shutdown")
+	case <-graceShutdownC:
+		log.Debug().Msg("Graceful shutdown signalled")
+		graceful = true
+	}
+
+	// Discard the errors of the components while they stop, the first one that stops ends the drain early
+	componentStoppedC := make(chan struct{})
+	stopDiscarding := make(chan struct{})
+	go func() {
+		var once sync.Once
+		for {
+			select {
+			case <-errC:
+				once.Do(func() { close(componentStoppedC) })
+			case <-stopDiscarding:
+				return
+			}
+		}
+	}()
+	defer close(stopDiscarding)
+
+	var drainer *connection.Drainer
+	if tunnel != nil {
+		drainer = tunnel.drainer
+	}
+
+	stageStart := time.Now()
+	if tunnel != nil {
+		tunnel.unregister()
+	}
+	drainedC := drainer.Drain(gracePeriod)
+	logShutdownStage(log, "unregister connections", stageStart)
+
+	if graceful && gracePeriod > 0 {
+		stageStart = time.Now()
+		log.Info().Interface("inFlight", drainer.Status().InFlight).Msgf("Draining in-flight requests for up to %s", gracePeriod)
+		select {
+		case <-drainedC:
+			log.Info().Msg("All in-flight requests completed")
+		case <-time.After(gracePeriod):
+			if drainer != nil {
+				log.Warn().Interface("inFlight", drainer.Status().InFlight).Msg("Grace period expired before all in-flight requests completed")
+			}
+		case <-componentStoppedC:
+		}
+		logShutdownStage(log, "drain in-flight requests", stageStart)
+	}
+
+	if tunnel != nil {
+		stageStart = time.Now()
+		tunnel.close(log)
+		logShutdownStage(log, "close connections", stageStart)
+	}
+
+	stageStart = time.Now()
+	cancelServerContext()
+	wg.Wait()
+	logShutdownStage(log, "stop remaining components", stageStart)
+
+	return err
+}
+
+// unregister tells the connections to unregister from the edge. They stay open to serve the requests in flight.
+func (t *tunnelShutdown) unregister() {
+	if t.unregisterC != nil {
+		close(t.unregisterC)
+	}
+}
+
+// close waits for the connections to finish unregistering and close, then closes the ones left.
+func (t *tunnelShutdown) close(log *zerolog.Logger) {
+	if t.stoppedC != nil {
+		select {
+		case <-t.stoppedC:
+		case <-time.After(unregisterTimeout):
+			log.Warn().Msgf("Connections didn't unregister from the edge within %s, closing them", unregisterTimeout)
+		}
+	}
+	if t.cancel != nil {
+		t.cancel()
+	}
+}
+
+func logShutdownStage(log *zerolog.Logger, stage string, start time.Time) {
+	log.Info().Str("stage", stage).Dur("duration", time.Since(start)).Msg("Shutdown stage completed")
+}
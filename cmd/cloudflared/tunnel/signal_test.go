@@ -12,6 +12,8 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudflare/cloudflared/connection"
 )
 
 const tick = 100 * time.Millisecond
@@ -77,7 +79,7 @@ func TestWaitForShutdown(t *testing.T) {
 	go func() {
 		errC <- serverErr
 	}()
-	err := waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, &log)
+	err := waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, nil, &log)
 	assert.Equal(t, serverErr, err)
 	assert.True(t, contextCancelled)
 	assert.False(t, channelClosed(graceShutdownC))
@@ -91,7 +93,7 @@ func TestWaitForShutdown(t *testing.T) {
 		time.Sleep(tick)
 		errC <- serverErr
 	}()
-	err = waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, &log)
+	err = waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, nil, &log)
 	assert.Nil(t, err)
 	assert.True(t, contextCancelled)
 	assert.True(t, time.Now().Sub(startTime) < time.Second) // check that wait ended early
@@ -99,8 +101,63 @@ func TestWaitForShutdown(t *testing.T) {
 	// with graceShutdownC closed stop right away without grace period
 	contextCancelled = false
 	startTime = time.Now()
-	err = waitToShutdown(&wg, cancel, errC, graceShutdownC, 0, &log)
+	err = waitToShutdown(&wg, cancel, errC, graceShutdownC, 0, nil, &log)
+	assert.Nil(t, err)
+	assert.True(t, contextCancelled)
+	assert.True(t, time.Now().Sub(startTime) < time.Second) // check that wait ended early
+
+	// with a drainer, stop as soon as the in-flight requests completed
+	contextCancelled = false
+	startTime = time.Now()
+	drainer := connection.NewDrainer()
+	done, ok := drainer.Track(connection.InFlightHTTP)
+	assert.True(t, ok)
+	time.AfterFunc(tick, done)
+	err = waitToShutdown(&wg, cancel, errC, graceShutdownC, gracePeriod, &tunnelShutdown{drainer: drainer}, &log)
 	assert.Nil(t, err)
 	assert.True(t, contextCancelled)
 	assert.True(t, time.Now().Sub(startTime) < time.Second) // check that wait ended early
+	_, ok = drainer.Track(connection.InFlightHTTP)
+	assert.False(t, ok)
+}
+
+func TestWaitForShutdownOrder(t *testing.T) {
+	log := zerolog.Nop()
+
+	errC := make(chan error)
+	graceShutdownC := make(chan struct{})
+	close(graceShutdownC)
+	var wg sync.WaitGroup
+
+	var lock sync.Mutex
+	var stages []string
+	record := func(stage string) {
+		lock.Lock()
+		defer lock.Unlock()
+		stages = append(stages, stage)
+	}
+
+	drainer := connection.NewDrainer()
+	done, ok := drainer.Track(connection.InFlightHTTP)
+	assert.True(t, ok)
+
+	tunnel := &tunnelShutdown{
+		drainer:     drainer,
+		unregisterC: make(chan struct{}),
+		stoppedC:    make(chan struct{}),
+		cancel:      func() { record("tunnel cancelled") },
+	}
+	go func() {
+		<-tunnel.unregisterC
+		record("unregistered")
+		time.AfterFunc(tick, func() {
+			record("drained")
+			done()
+		})
+		close(tunnel.stoppedC)
+	}()
+
+	err := waitToShutdown(&wg, func() { record("server cancelled") }, errC, graceShutdownC, 5*time.Second, tunnel, &log)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"unregistered", "drained", "tunnel cancelled", "server cancelled"}, stages)
 }
@@ -0,0 +1,69 @@
+package tunnel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+)
+
+// newStaleConnectionsCleanup returns a function that deletes the connections of the previous run of this connector.
+// The connector ID changes on every run, so the last one is kept in a file next to the origin certificate, which is
+// needed to call the API anyway. The returned function is nil if there's no previous run to clean up after.
+func newStaleConnectionsCleanup(c *cli.Context, tunnelID, connectorID uuid.UUID, log *zerolog.Logger) (func() error, error) {
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return nil, err
+	}
+	sc.log = log
+	credential, err := sc.credential()
+	if err != nil {
+		return nil, err
+	}
+
+	path := connectorIDPath(credential.CertPath(), tunnelID)
+	previous, err := readConnectorID(path)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Ignoring the connector ID of the previous run stored in %s", path)
+	}
+	if err := os.WriteFile(path, []byte(connectorID.String()), 0600); err != nil {
+		return nil, errors.Wrapf(err, "failed to store the connector ID in %s", path)
+	}
+	if previous == uuid.Nil || previous == connectorID {
+		return nil, nil
+	}
+
+	return func() error {
+		client, err := sc.client()
+		if err != nil {
+			return err
+		}
+		params := cfapi.NewCleanupParams()
+		params.ForClient(previous)
+		log.Info().Msgf("Cleanup connections of connector %s", previous)
+		return client.CleanupConnections(tunnelID, params)
+	}, nil
+}
+
+func connectorIDPath(certPath string, tunnelID uuid.UUID) string {
+	return filepath.Join(filepath.Dir(certPath), fmt.Sprintf("%s.connector", tunnelID))
+}
+
+// readConnectorID returns the connector ID stored in path, or uuid.Nil if there's none.
+func readConnectorID(path string) (uuid.UUID, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(strings.TrimSpace(string(content)))
+}
@@ -0,0 +1,31 @@
+package tunnel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConnectorID(t *testing.T) {
+	dir := t.TempDir()
+	tunnelID := uuid.New()
+	path := connectorIDPath(filepath.Join(dir, "cert.pem"), tunnelID)
+	require.Equal(t, filepath.Join(dir, tunnelID.String()+".connector"), path)
+
+	connectorID, err := readConnectorID(path)
+	require.NoError(t, err)
+	require.Equal(t, uuid.Nil, connectorID)
+
+	expected := uuid.New()
+	require.NoError(t, os.WriteFile(path, []byte(expected.String()+"\n"), 0600))
+	connectorID, err = readConnectorID(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, connectorID)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a uuid"), 0600))
+	_, err = readConnectorID(path)
+	require.Error(t, err)
+}
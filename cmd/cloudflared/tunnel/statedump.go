@@ -0,0 +1,28 @@
+package tunnel
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/metrics"
+)
+
+// writeStateDump writes the state dump to path, or to the log if path is empty.
+func writeStateDump(dump *metrics.StateDump, path string, log *zerolog.Logger) {
+	var buf bytes.Buffer
+	if err := dump.Write(&buf); err != nil {
+		log.Err(err).Msg("Failed to dump state")
+		return
+	}
+	if path == "" {
+		log.Info().Msg(buf.String())
+		return
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		log.Err(err).Str("path", path).Msg("Failed to write state dump")
+		return
+	}
+	log.Info().Str("path", path).Msg("Wrote state dump")
+}
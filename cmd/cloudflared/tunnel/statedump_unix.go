@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package tunnel
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/metrics"
+)
+
+// dumpStateOnSignal writes a state dump every time cloudflared receives SIGUSR1, until ctx is done.
+func dumpStateOnSignal(ctx context.Context, dump *metrics.StateDump, path string, log *zerolog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-signals:
+			writeStateDump(dump, path, log)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package tunnel
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/metrics"
+)
+
+// dumpStateOnSignal does nothing on Windows, which has no SIGUSR1. The state dump is served by the metrics server at
+// /debug/state instead.
+func dumpStateOnSignal(ctx context.Context, dump *metrics.StateDump, path string, log *zerolog.Logger) {
+}
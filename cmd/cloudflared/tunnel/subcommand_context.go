@@ -1,12 +1,17 @@
 package tunnel
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -14,6 +19,8 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cfio"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/credentials"
 	"github.com/cloudflare/cloudflared/logger"
@@ -35,7 +42,9 @@ type subcommandContext struct {
 	log *zerolog.Logger
 	fs  fileSystem
 
-	// These fields should be accessed using their respective Getter
+	// These fields should be accessed using their respective Getter. clientLock makes the getters safe to call from
+	// bulk operations.
+	clientLock        sync.Mutex
 	tunnelstoreClient cfapi.Client
 	userCredential    *credentials.User
 }
@@ -57,6 +66,8 @@ func (sc *subcommandContext) credentialFinder(tunnelID uuid.UUID) CredFinder {
 }
 
 func (sc *subcommandContext) client() (cfapi.Client, error) {
+	sc.clientLock.Lock()
+	defer sc.clientLock.Unlock()
 	if sc.tunnelstoreClient != nil {
 		return sc.tunnelstoreClient, nil
 	}
@@ -75,7 +86,10 @@ func (sc *subcommandContext) credential() (*credentials.User, error) {
 	if sc.userCredential == nil {
 		uc, err := credentials.Read(sc.c.String(credentials.OriginCertFlag), sc.log)
 		if err != nil {
-			return nil, err
+			if errors.Is(err, credentials.ErrOriginCertNotFound) {
+				return nil, cliutil.WithExitCode(cliutil.ExitCodeOriginCertMissing, err)
+			}
+			return nil, cliutil.WithExitCode(cliutil.ExitCodeCredentials, err)
 		}
 		sc.userCredential = uc
 	}
@@ -111,21 +125,9 @@ func (sc *subcommandContext) create(name string, credentialsFilePath string, sec
 		return nil, errors.Wrap(err, "couldn't create client to talk to Cloudflare Tunnel backend")
 	}
 
-	var tunnelSecret []byte
-	if secret == "" {
-		tunnelSecret, err = generateTunnelSecret()
-		if err != nil {
-			return nil, errors.Wrap(err, "couldn't generate the secret for your new tunnel")
-		}
-	} else {
-		decodedSecret, err := base64.StdEncoding.DecodeString(secret)
-		if err != nil {
-			return nil, errors.Wrap(err, "Couldn't decode tunnel secret from base64")
-		}
-		tunnelSecret = []byte(decodedSecret)
-		if len(tunnelSecret) < 32 {
-			return nil, errors.New("Decoded tunnel secret must be at least 32 bytes long")
-		}
+	tunnelSecret, err := parseTunnelSecret(secret)
+	if err != nil {
+		return nil, err
 	}
 
 	tunnel, err := client.CreateTunnel(name, tunnelSecret)
@@ -141,6 +143,7 @@ func (sc *subcommandContext) create(name string, credentialsFilePath string, sec
 		AccountTag:   credential.AccountID(),
 		TunnelSecret: tunnelSecret,
 		TunnelID:     tunnel.ID,
+		TunnelName:   tunnel.Name,
 	}
 	usedCertPath := false
 	if credentialsFilePath == "" {
@@ -180,6 +183,79 @@ func (sc *subcommandContext) create(name string, credentialsFilePath string, sec
 	return &tunnel.Tunnel, nil
 }
 
+// parseTunnelSecret decodes a base64 encoded secret, or generates one if secret is empty.
+func parseTunnelSecret(secret string) ([]byte, error) {
+	if secret == "" {
+		tunnelSecret, err := generateTunnelSecret()
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't generate the secret for your tunnel")
+		}
+		return tunnelSecret, nil
+	}
+	tunnelSecret, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "Couldn't decode tunnel secret from base64")
+	}
+	if len(tunnelSecret) < 32 {
+		return nil, errors.New("Decoded tunnel secret must be at least 32 bytes long")
+	}
+	return tunnelSecret, nil
+}
+
+// rotateSecret gives the tunnel a new secret and writes it to the tunnel's credentials file, replacing the file
+// atomically so that a connector starting meanwhile never reads a partial file. With backup, the previous
+// credentials are kept next to it with a .bak suffix.
+func (sc *subcommandContext) rotateSecret(tunnelID uuid.UUID, secret string, backup bool) error {
+	credFinder := sc.credentialFinder(tunnelID)
+	credentialsFilePath, err := credFinder.Path()
+	if err != nil {
+		return err
+	}
+	previousBody, err := sc.fs.readFile(credentialsFilePath)
+	if err != nil {
+		return errors.Wrapf(err, "couldn't read tunnel credentials from %v", credentialsFilePath)
+	}
+	credentials, err := sc.readTunnelCredentials(credFinder)
+	if err != nil {
+		return err
+	}
+	if credentials.TunnelID != tunnelID {
+		return fmt.Errorf("%s holds the credentials of tunnel %s, not %s", credentialsFilePath, credentials.TunnelID, tunnelID)
+	}
+
+	tunnelSecret, err := parseTunnelSecret(secret)
+	if err != nil {
+		return err
+	}
+	client, err := sc.client()
+	if err != nil {
+		return errors.Wrap(err, "couldn't create client to talk to Cloudflare Tunnel backend")
+	}
+
+	// The backup is written before the secret changes, so that a failure leaves the tunnel untouched
+	if backup {
+		if err := cfio.WriteFileAtomically(credentialsFilePath+".bak", previousBody); err != nil {
+			return errors.Wrap(err, "couldn't back up the tunnel credentials")
+		}
+	}
+	if _, err := client.RotateTunnelSecret(tunnelID, tunnelSecret); err != nil {
+		return errors.Wrap(err, "Rotate Tunnel Secret API call failed")
+	}
+
+	credentials.TunnelSecret = tunnelSecret
+	body, err := json.Marshal(&credentials)
+	if err != nil {
+		return errors.Wrap(err, "Unable to marshal tunnel credentials to JSON")
+	}
+	if err := cfio.WriteFileAtomically(credentialsFilePath, body); err != nil {
+		return fmt.Errorf("The secret of tunnel %s was rotated, but cloudflared couldn't write the new credentials to %s: %v\n"+
+			"Save these credentials to run the tunnel, the previous ones don't work anymore:\n%s", tunnelID, credentialsFilePath, err, body)
+	}
+	sc.log.Info().Msgf("Rotated the secret of tunnel %s and wrote the new credentials to %s. Restart its connectors with them, "+
+		"new connections with the previous credentials are refused.", tunnelID, credentialsFilePath)
+	return nil
+}
+
 func (sc *subcommandContext) list(filter *cfapi.TunnelFilter) ([]*cfapi.Tunnel, error) {
 	client, err := sc.client()
 	if err != nil {
@@ -188,6 +264,63 @@ func (sc *subcommandContext) list(filter *cfapi.TunnelFilter) ([]*cfapi.Tunnel,
 	return client.ListTunnels(filter)
 }
 
+// allTunnelIDs returns the IDs of every tunnel of the account that isn't deleted, or only of the ones whose name starts
+// with namePrefix if it isn't empty. With staleAfter, only the tunnels with connections that have been stale for that
+// long are returned.
+func (sc *subcommandContext) allTunnelIDs(namePrefix string, staleAfter time.Duration) ([]uuid.UUID, error) {
+	filter := cfapi.NewTunnelFilter()
+	filter.NoDeleted()
+	if namePrefix != "" {
+		filter.ByNamePrefix(namePrefix)
+	}
+	tunnels, err := sc.list(filter)
+	if err != nil {
+		return nil, err
+	}
+	cacheTunnelNames(tunnels...)
+	tunnelIDs := make([]uuid.UUID, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		if staleAfter > 0 && !hasStaleConnections(tunnel.Connections, time.Now().Add(-staleAfter)) {
+			continue
+		}
+		tunnelIDs = append(tunnelIDs, tunnel.ID)
+	}
+	return tunnelIDs, nil
+}
+
+// hasStaleConnections tells whether some of the connections are waiting for a connector that went away to reconnect,
+// and were opened before openedBefore.
+func hasStaleConnections(connections []cfapi.Connection, openedBefore time.Time) bool {
+	for _, connection := range connections {
+		if connection.IsPendingReconnect && connection.OpenedAt.Before(openedBefore) {
+			return true
+		}
+	}
+	return false
+}
+
+// staleConnectors returns the connectors whose connections were all opened before openedBefore and are waiting for the
+// connector to reconnect. Connectors with a healthy connection are left out, since cleaning them up disconnects it.
+func staleConnectors(connectors []*cfapi.ActiveClient, openedBefore time.Time) []uuid.UUID {
+	var stale []uuid.UUID
+	for _, connector := range connectors {
+		if len(connector.Connections) == 0 {
+			continue
+		}
+		allStale := true
+		for _, connection := range connector.Connections {
+			if !connection.IsPendingReconnect || !connection.OpenedAt.Before(openedBefore) {
+				allStale = false
+				break
+			}
+		}
+		if allStale {
+			stale = append(stale, connector.ID)
+		}
+	}
+	return stale
+}
+
 func (sc *subcommandContext) delete(tunnelIDs []uuid.UUID) error {
 	forceFlagSet := sc.c.Bool("force")
 
@@ -196,7 +329,8 @@ func (sc *subcommandContext) delete(tunnelIDs []uuid.UUID) error {
 		return err
 	}
 
-	for _, id := range tunnelIDs {
+	return sc.runBulkWithProgress("Deleting tunnels", uuidStrings(tunnelIDs), func(i int) error {
+		id := tunnelIDs[i]
 		tunnel, err := client.GetTunnel(id)
 		if err != nil {
 			return errors.Wrapf(err, "Can't get tunnel information. Please check tunnel id: %s", id)
@@ -222,8 +356,35 @@ func (sc *subcommandContext) delete(tunnelIDs []uuid.UUID) error {
 				sc.log.Info().Msgf("Tunnel %v was deleted, but we could not remove its credentials file  %s: %s. Consider deleting this file manually.", id, tunnelCredentialsPath, err)
 			}
 		}
+		return nil
+	})
+}
+
+// bulkConcurrency is how many tunnels operations on many of them process at once.
+func (sc *subcommandContext) bulkConcurrency() int {
+	if concurrency := sc.c.Int(bulkConcurrencyFlag.Name); concurrency > 0 {
+		return concurrency
 	}
-	return nil
+	return defaultBulkConcurrency
+}
+
+// readStoredCredentials reads the tunnel credentials from the CredentialsStore that storeURI points to.
+func (sc *subcommandContext) readStoredCredentials(storeURI string) (connection.Credentials, error) {
+	store, err := newCredentialsStore(storeURI)
+	if err != nil {
+		return connection.Credentials{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsStoreTimeout)
+	defer cancel()
+	body, err := store.Read(ctx)
+	if err != nil {
+		return connection.Credentials{}, err
+	}
+	var credentials connection.Credentials
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return connection.Credentials{}, errInvalidJSONCredential{path: storeURI, err: err}
+	}
+	return credentials, nil
 }
 
 // findCredentials will choose the right way to find the credentials file, find it,
@@ -236,6 +397,8 @@ func (sc *subcommandContext) findCredentials(tunnelID uuid.UUID) (connection.Cre
 		if err = json.Unmarshal([]byte(credentialsContents), &credentials); err != nil {
 			err = errInvalidJSONCredential{path: "TUNNEL_CRED_CONTENTS", err: err}
 		}
+	} else if storeURI := sc.c.String(CredStoreFlag); storeURI != "" {
+		credentials, err = sc.readStoredCredentials(storeURI)
 	} else {
 		credFinder := sc.credentialFinder(tunnelID)
 		credentials, err = sc.readTunnelCredentials(credFinder)
@@ -243,6 +406,10 @@ func (sc *subcommandContext) findCredentials(tunnelID uuid.UUID) (connection.Cre
 	// This line ensures backwards compatibility with credentials files generated before
 	// TUN-3581. Those old credentials files don't have a TunnelID field, so we enrich the struct
 	// with the ID, which we have already resolved from the user input.
+	if err == nil && credentials.TunnelID == uuid.Nil {
+		sc.log.Warn().Msgf("The credentials of tunnel %s don't have its ID, they were created by an old version of cloudflared. "+
+			"Run `cloudflared tunnel credentials migrate` to update them.", tunnelID)
+	}
 	credentials.TunnelID = tunnelID
 	return credentials, err
 }
@@ -254,13 +421,16 @@ func (sc *subcommandContext) run(tunnelID uuid.UUID) error {
 			sc.log.Error().Msgf("The credentials file at %s contained invalid JSON. This is probably caused by passing the wrong filepath. Reminder: the credentials file is a .json file created via `cloudflared tunnel create`.", e.path)
 			sc.log.Error().Msgf("Invalid JSON when parsing credentials file: %s", e.err.Error())
 		}
-		return err
+		return cliutil.WithExitCode(cliutil.ExitCodeCredentials, err)
 	}
 
 	return sc.runWithCredentials(credentials)
 }
 
 func (sc *subcommandContext) runWithCredentials(credentials connection.Credentials) error {
+	if sc.c.Bool(dryRunFlag.Name) {
+		return dryRun(sc.c, &connection.NamedTunnelProperties{Credentials: credentials})
+	}
 	sc.log.Info().Str(LogFieldTunnelID, credentials.TunnelID.String()).Msg("Starting tunnel")
 
 	return StartServer(
@@ -287,13 +457,40 @@ func (sc *subcommandContext) cleanupConnections(tunnelIDs []uuid.UUID) error {
 	if err != nil {
 		return err
 	}
-	for _, tunnelID := range tunnelIDs {
+	return sc.runBulkWithProgress("Cleaning up connections", uuidStrings(tunnelIDs), func(i int) error {
+		tunnelID := tunnelIDs[i]
 		sc.log.Info().Msgf("Cleanup connection for tunnel %s%s", tunnelID, extraLog)
 		if err := client.CleanupConnections(tunnelID, params); err != nil {
-			sc.log.Error().Msgf("Error cleaning up connections for tunnel %v, error :%v", tunnelID, err)
+			return errors.Wrapf(err, "Error cleaning up connections for tunnel %v", tunnelID)
 		}
+		return nil
+	})
+}
+
+// cleanupStaleConnections cleans up the connections of the connectors of the tunnels whose connections have all been
+// stale for staleAfter, leaving the other connectors of the tunnels connected.
+func (sc *subcommandContext) cleanupStaleConnections(tunnelIDs []uuid.UUID, staleAfter time.Duration) error {
+	client, err := sc.client()
+	if err != nil {
+		return err
 	}
-	return nil
+	openedBefore := time.Now().Add(-staleAfter)
+	return sc.runBulkWithProgress("Cleaning up stale connections", uuidStrings(tunnelIDs), func(i int) error {
+		tunnelID := tunnelIDs[i]
+		connectors, err := client.ListActiveClients(tunnelID)
+		if err != nil {
+			return errors.Wrapf(err, "Error listing the connectors of tunnel %v", tunnelID)
+		}
+		for _, connectorID := range staleConnectors(connectors, openedBefore) {
+			sc.log.Info().Msgf("Cleanup connection for tunnel %s for connector-id %s", tunnelID, connectorID)
+			params := cfapi.NewCleanupParams()
+			params.ForClient(connectorID)
+			if err := client.CleanupConnections(tunnelID, params); err != nil {
+				return errors.Wrapf(err, "Error cleaning up connections of connector %s for tunnel %v", connectorID, tunnelID)
+			}
+		}
+		return nil
+	})
 }
 
 func (sc *subcommandContext) getTunnelTokenCredentials(tunnelID uuid.UUID) (*connection.TunnelToken, error) {
@@ -362,28 +559,143 @@ func (sc *subcommandContext) findID(input string) (uuid.UUID, error) {
 }
 
 // findIDs is just like mapping `findID` over a slice, but it only uses
-// one Tunnelstore API call per non-UUID input provided.
+// one Tunnelstore API call per non-UUID input provided. Inputs that are glob
+// patterns, e.g. staging-*, are resolved to every non-deleted tunnel whose name
+// matches.
 func (sc *subcommandContext) findIDs(inputs []string) ([]uuid.UUID, error) {
 	uuids, names := splitUuids(inputs)
+	if len(names) > 0 {
+		// The client is created once, before the names are looked up concurrently
+		if _, err := sc.client(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Patterns are resolved together with a single listing before the names are looked up concurrently, so the
+	// tunnel names cache is only written once
+	namedIDs := make([][]uuid.UUID, len(names))
+	var patterns []string
+	var patternIndexes []int
+	for i, name := range names {
+		if isNamePattern(name) {
+			patterns = append(patterns, name)
+			patternIndexes = append(patternIndexes, i)
+		}
+	}
+	if len(patterns) > 0 {
+		patternIDs, err := sc.findIDsByPatterns(patterns)
+		if err != nil {
+			return nil, err
+		}
+		for i, ids := range patternIDs {
+			namedIDs[patternIndexes[i]] = ids
+		}
+	}
+
+	err := runBulk(len(names), sc.bulkConcurrency(), func(i int) error {
+		if namedIDs[i] != nil {
+			return nil
+		}
 
-	for _, name := range names {
 		filter := cfapi.NewTunnelFilter()
 		filter.NoDeleted()
-		filter.ByName(name)
+		filter.ByName(names[i])
 
 		tunnels, err := sc.list(filter)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		if len(tunnels) != 1 {
-			return nil, fmt.Errorf("there should only be 1 non-deleted Tunnel named %s", name)
+			return fmt.Errorf("there should only be 1 non-deleted Tunnel named %s", names[i])
+		}
+
+		namedIDs[i] = []uuid.UUID{tunnels[0].ID}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Patterns can match tunnels that were also given otherwise
+	seen := make(map[uuid.UUID]bool, len(uuids))
+	ids := make([]uuid.UUID, 0, len(uuids))
+	for _, id := range append(uuids, flatten(namedIDs)...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// isNamePattern tells whether input is a glob pattern of tunnel names rather than a name.
+func isNamePattern(input string) bool {
+	return strings.ContainsAny(input, "*?[")
+}
+
+// findIDsByPatterns returns the IDs of the non-deleted tunnels whose name matches each glob pattern, with the syntax
+// of path.Match. It fails if no tunnel matches one of the patterns, so that a mistyped pattern isn't silently ignored.
+func (sc *subcommandContext) findIDsByPatterns(patterns []string) ([][]uuid.UUID, error) {
+	// The literal start shared by the patterns narrows down the tunnels to list
+	var prefix string
+	for i, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "%s is not a valid tunnel name pattern", pattern)
 		}
+		literal := pattern[:strings.IndexAny(pattern, "*?[\\")]
+		if i == 0 {
+			prefix = literal
+			continue
+		}
+		for !strings.HasPrefix(literal, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	filter := cfapi.NewTunnelFilter()
+	filter.NoDeleted()
+	if prefix != "" {
+		filter.ByNamePrefix(prefix)
+	}
+	tunnels, err := sc.list(filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tunnels, func(i, j int) bool {
+		return tunnels[i].Name < tunnels[j].Name
+	})
+
+	ids := make([][]uuid.UUID, len(patterns))
+	var matched []*cfapi.Tunnel
+	for i, pattern := range patterns {
+		for _, tunnel := range tunnels {
+			if ok, _ := path.Match(pattern, tunnel.Name); ok {
+				ids[i] = append(ids[i], tunnel.ID)
+				matched = append(matched, tunnel)
+			}
+		}
+		if len(ids[i]) == 0 {
+			return nil, fmt.Errorf("no non-deleted tunnel has a name matching %s", pattern)
+		}
+	}
+	cacheTunnelNames(matched...)
+	return ids, nil
+}
 
-		uuids = append(uuids, tunnels[0].ID)
+func flatten(idLists [][]uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, list := range idLists {
+		ids = append(ids, list...)
 	}
+	return ids
+}
 
-	return uuids, nil
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
 }
 
 func splitUuids(inputs []string) ([]uuid.UUID, []string) {
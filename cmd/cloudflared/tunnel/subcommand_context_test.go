@@ -1,10 +1,15 @@
 package tunnel
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
 
 	"github.com/cloudflare/cloudflared/cfapi"
@@ -116,6 +122,7 @@ func Test_subcommandContext_findCredentials(t *testing.T) {
 				AccountTag:   accountTag,
 				TunnelID:     tunnelID,
 				TunnelSecret: secret,
+				TunnelName:   name,
 			},
 		},
 		{
@@ -160,6 +167,7 @@ func Test_subcommandContext_findCredentials(t *testing.T) {
 				AccountTag:   accountTag,
 				TunnelID:     tunnelID,
 				TunnelSecret: secret,
+				TunnelName:   name,
 			},
 		},
 	}
@@ -186,6 +194,8 @@ func Test_subcommandContext_findCredentials(t *testing.T) {
 
 type deleteMockTunnelStore struct {
 	cfapi.Client
+	// Tunnels are deleted concurrently
+	lock             sync.Mutex
 	mockTunnels      map[uuid.UUID]mockTunnelBehaviour
 	deletedTunnelIDs []uuid.UUID
 }
@@ -208,6 +218,8 @@ func newDeleteMockTunnelStore(tunnels ...mockTunnelBehaviour) *deleteMockTunnelS
 }
 
 func (d *deleteMockTunnelStore) GetTunnel(tunnelID uuid.UUID) (*cfapi.Tunnel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	tunnel, ok := d.mockTunnels[tunnelID]
 	if !ok {
 		return nil, fmt.Errorf("Couldn't find tunnel: %v", tunnelID)
@@ -220,6 +232,8 @@ func (d *deleteMockTunnelStore) GetTunnelToken(tunnelID uuid.UUID) (string, erro
 }
 
 func (d *deleteMockTunnelStore) DeleteTunnel(tunnelID uuid.UUID) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	tunnel, ok := d.mockTunnels[tunnelID]
 	if !ok {
 		return fmt.Errorf("Couldn't find tunnel: %v", tunnelID)
@@ -236,6 +250,8 @@ func (d *deleteMockTunnelStore) DeleteTunnel(tunnelID uuid.UUID) error {
 }
 
 func (d *deleteMockTunnelStore) CleanupConnections(tunnelID uuid.UUID, _ *cfapi.CleanupParams) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	tunnel, ok := d.mockTunnels[tunnelID]
 	if !ok {
 		return fmt.Errorf("Couldn't find tunnel: %v", tunnelID)
@@ -243,6 +259,100 @@ func (d *deleteMockTunnelStore) CleanupConnections(tunnelID uuid.UUID, _ *cfapi.
 	return tunnel.cleanupErr
 }
 
+func (d *deleteMockTunnelStore) ListTunnels(*cfapi.TunnelFilter) ([]*cfapi.Tunnel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var tunnels []*cfapi.Tunnel
+	for _, tunnel := range d.mockTunnels {
+		tunnel := tunnel.tunnel
+		tunnels = append(tunnels, &tunnel)
+	}
+	return tunnels, nil
+}
+
+func Test_subcommandContext_allTunnelIDs(t *testing.T) {
+	defer func(path string) { tunnelNamesCachePath = path }(tunnelNamesCachePath)
+	tunnelNamesCachePath = filepath.Join(t.TempDir(), "tunnel_names.json")
+
+	staging := cfapi.Tunnel{ID: uuid.New(), Name: "staging", Connections: []cfapi.Connection{
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: time.Now().Add(-2 * time.Hour)},
+	}}
+	production := cfapi.Tunnel{ID: uuid.New(), Name: "production", Connections: []cfapi.Connection{
+		{ID: uuid.New(), OpenedAt: time.Now().Add(-2 * time.Hour)},
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: time.Now().Add(-time.Minute)},
+	}}
+	log := zerolog.Nop()
+	sc := &subcommandContext{
+		log:               &log,
+		tunnelstoreClient: newDeleteMockTunnelStore(mockTunnelBehaviour{tunnel: staging}, mockTunnelBehaviour{tunnel: production}),
+	}
+
+	tunnelIDs, err := sc.allTunnelIDs("", 0)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{staging.ID, production.ID}, tunnelIDs)
+
+	// Only staging has a connection that has been waiting for its connector for long
+	tunnelIDs, err = sc.allTunnelIDs("", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{staging.ID}, tunnelIDs)
+
+	// The names are cached for shell completion
+	assert.Equal(t, map[string]string{staging.ID.String(): "staging", production.ID.String(): "production"}, readTunnelNamesCache())
+}
+
+func TestStaleConnectors(t *testing.T) {
+	longAgo := time.Now().Add(-2 * time.Hour)
+	stale := &cfapi.ActiveClient{ID: uuid.New(), Connections: []cfapi.Connection{
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: longAgo},
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: longAgo},
+	}}
+	// A connector with a healthy connection is still running
+	partlyStale := &cfapi.ActiveClient{ID: uuid.New(), Connections: []cfapi.Connection{
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: longAgo},
+		{ID: uuid.New(), OpenedAt: longAgo},
+	}}
+	recentlyStale := &cfapi.ActiveClient{ID: uuid.New(), Connections: []cfapi.Connection{
+		{ID: uuid.New(), IsPendingReconnect: true, OpenedAt: time.Now().Add(-time.Minute)},
+	}}
+	healthy := &cfapi.ActiveClient{ID: uuid.New(), Connections: []cfapi.Connection{{ID: uuid.New(), OpenedAt: longAgo}}}
+
+	connectors := []*cfapi.ActiveClient{stale, partlyStale, recentlyStale, healthy, {ID: uuid.New()}}
+	assert.Equal(t, []uuid.UUID{stale.ID}, staleConnectors(connectors, time.Now().Add(-time.Hour)))
+}
+
+func Test_subcommandContext_findIDsByPatterns(t *testing.T) {
+	defer func(path string) { tunnelNamesCachePath = path }(tunnelNamesCachePath)
+	tunnelNamesCachePath = filepath.Join(t.TempDir(), "tunnel_names.json")
+
+	staging1 := cfapi.Tunnel{ID: uuid.New(), Name: "staging-1"}
+	staging2 := cfapi.Tunnel{ID: uuid.New(), Name: "staging-2"}
+	production := cfapi.Tunnel{ID: uuid.New(), Name: "production"}
+	log := zerolog.Nop()
+	sc := &subcommandContext{
+		c:   cli.NewContext(cli.NewApp(), flag.NewFlagSet("test", flag.PanicOnError), nil),
+		log: &log,
+		tunnelstoreClient: newDeleteMockTunnelStore(
+			mockTunnelBehaviour{tunnel: staging1},
+			mockTunnelBehaviour{tunnel: staging2},
+			mockTunnelBehaviour{tunnel: production},
+		),
+	}
+
+	tunnelIDs, err := sc.findIDs([]string{staging2.ID.String(), "staging-*"})
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{staging2.ID, staging1.ID}, tunnelIDs)
+	assert.Equal(t, "staging-1", readTunnelNamesCache()[staging1.ID.String()])
+
+	tunnelIDs, err = sc.findIDs([]string{"prod?ction", "*-[2-9]"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{production.ID, staging2.ID}, tunnelIDs)
+
+	_, err = sc.findIDs([]string{"dev-*"})
+	assert.Error(t, err)
+	_, err = sc.findIDs([]string{"staging-["})
+	assert.Error(t, err)
+}
+
 func Test_subcommandContext_Delete(t *testing.T) {
 	type fields struct {
 		c                 *cli.Context
@@ -315,11 +425,8 @@ func Test_subcommandContext_Delete(t *testing.T) {
 				t.Errorf("subcommandContext.findCredentials() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			got := tt.fields.tunnelstoreClient.deletedTunnelIDs
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("subcommandContext.findCredentials() = %v, want %v", got, tt.want)
-				return
-			}
+			// Tunnels are deleted concurrently, in any order
+			assert.ElementsMatch(t, tt.want, tt.fields.tunnelstoreClient.deletedTunnelIDs)
 		})
 	}
 }
@@ -368,3 +475,88 @@ func Test_subcommandContext_ValidateIngressCommand(t *testing.T) {
 		})
 	}
 }
+
+type rotateMockTunnelStore struct {
+	cfapi.Client
+	rotatedSecret []byte
+	rotateErr     error
+}
+
+func (r *rotateMockTunnelStore) RotateTunnelSecret(tunnelID uuid.UUID, tunnelSecret []byte) (*cfapi.TunnelWithToken, error) {
+	if r.rotateErr != nil {
+		return nil, r.rotateErr
+	}
+	r.rotatedSecret = tunnelSecret
+	return &cfapi.TunnelWithToken{Tunnel: cfapi.Tunnel{ID: tunnelID}}, nil
+}
+
+func Test_subcommandContext_rotateSecret(t *testing.T) {
+	log := zerolog.Nop()
+	tunnelID := uuid.New()
+	previous := connection.Credentials{
+		AccountTag:   "0000d4d14e84bd4ae5a6a02e0000ac63",
+		TunnelSecret: bytes.Repeat([]byte{1}, 32),
+		TunnelID:     tunnelID,
+	}
+	previousBody, err := json.Marshal(&previous)
+	require.NoError(t, err)
+
+	newContext := func(credentialsFilePath string, client cfapi.Client) *subcommandContext {
+		flagSet := flag.NewFlagSet("rotate", flag.PanicOnError)
+		flagSet.String(CredFileFlag, credentialsFilePath, "")
+		c := cli.NewContext(cli.NewApp(), flagSet, nil)
+		_ = c.Set(CredFileFlag, credentialsFilePath)
+		return &subcommandContext{c: c, log: &log, fs: realFileSystem{}, tunnelstoreClient: client}
+	}
+	readCredentials := func(path string) connection.Credentials {
+		body, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var credentials connection.Credentials
+		require.NoError(t, json.Unmarshal(body, &credentials))
+		return credentials
+	}
+
+	t.Run("rotates and keeps a backup", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), tunnelID.String()+".json")
+		require.NoError(t, os.WriteFile(path, previousBody, 0600))
+		client := &rotateMockTunnelStore{}
+
+		require.NoError(t, newContext(path, client).rotateSecret(tunnelID, "", true))
+		rotated := readCredentials(path)
+		assert.Len(t, client.rotatedSecret, 32)
+		assert.Equal(t, client.rotatedSecret, rotated.TunnelSecret)
+		assert.NotEqual(t, previous.TunnelSecret, rotated.TunnelSecret)
+		assert.Equal(t, previous.AccountTag, rotated.AccountTag)
+		assert.Equal(t, previous, readCredentials(path+".bak"))
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "the temporary file should be removed")
+	})
+
+	t.Run("uses the given secret", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), tunnelID.String()+".json")
+		require.NoError(t, os.WriteFile(path, previousBody, 0600))
+		secret := bytes.Repeat([]byte{2}, 32)
+
+		require.NoError(t, newContext(path, &rotateMockTunnelStore{}).rotateSecret(tunnelID, base64.StdEncoding.EncodeToString(secret), false))
+		assert.Equal(t, secret, readCredentials(path).TunnelSecret)
+		assert.NoFileExists(t, path+".bak")
+		assert.Error(t, newContext(path, &rotateMockTunnelStore{}).rotateSecret(tunnelID, base64.StdEncoding.EncodeToString([]byte("short")), false))
+	})
+
+	t.Run("keeps the credentials when the API call fails", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), tunnelID.String()+".json")
+		require.NoError(t, os.WriteFile(path, previousBody, 0600))
+
+		err := newContext(path, &rotateMockTunnelStore{rotateErr: errors.New("forbidden")}).rotateSecret(tunnelID, "", false)
+		assert.ErrorContains(t, err, "forbidden")
+		assert.Equal(t, previous, readCredentials(path))
+	})
+
+	t.Run("refuses the credentials of another tunnel", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "other.json")
+		require.NoError(t, os.WriteFile(path, previousBody, 0600))
+
+		assert.Error(t, newContext(path, &rotateMockTunnelStore{}).rotateSecret(uuid.New(), "", false))
+	})
+}
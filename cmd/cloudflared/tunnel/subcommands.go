@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -27,15 +28,18 @@ import (
 	"github.com/cloudflare/cloudflared/cmd/cloudflared/updater"
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/webhook"
 )
 
 const (
-	allSortByOptions     = "name, id, createdAt, deletedAt, numConnections"
+	allSortByOptions     = "name, id, createdAt (or created), deletedAt, numConnections"
 	connsSortByOptions   = "id, startedAt, numConnections, version"
 	CredFileFlagAlias    = "cred-file"
 	CredFileFlag         = "credentials-file"
 	CredContentsFlag     = "credentials-contents"
+	CredStoreFlag        = "credentials-store"
 	TunnelTokenFlag      = "token"
+	TunnelTokenFileFlag  = "token-file"
 	overwriteDNSFlagName = "overwrite-dns"
 
 	LogFieldTunnelID = "tunnelID"
@@ -95,6 +99,15 @@ var (
 		Usage:   "Inverts the sort order of the tunnel list.",
 		EnvVars: []string{"TUNNEL_LIST_INVERT_SORT"},
 	}
+	listPageFlag = &cli.UintFlag{
+		Name:  "page",
+		Usage: "Only list the given `PAGE` of tunnels, starting at 1, as split by Cloudflare in pages of --per-page tunnels. Sorting applies within the page.",
+	}
+	listPerPageFlag = &cli.UintFlag{
+		Name:  "per-page",
+		Value: 100,
+		Usage: "Number of tunnels of each page listed with --page",
+	}
 	featuresFlag = altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
 		Name:    "features",
 		Aliases: []string{"F"},
@@ -112,11 +125,39 @@ var (
 		Usage:   "Contents of the tunnel credentials JSON file to use. When provided along with credentials-file, this will take precedence.",
 		EnvVars: []string{"TUNNEL_CRED_CONTENTS"},
 	})
+	credentialsStoreFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name: CredStoreFlag,
+		Usage: "URI of the secrets manager to read the tunnel credentials from instead of a file: env://VARIABLE, " +
+			"vault://[HOST[:PORT]]/MOUNT/PATH[?field=FIELD], aws-sm://SECRET_ID[?region=REGION] or " +
+			"gcp-sm://projects/PROJECT/secrets/SECRET[/versions/VERSION]. credentials-contents takes precedence over it.",
+		EnvVars: []string{"TUNNEL_CRED_STORE"},
+	})
 	tunnelTokenFlag = altsrc.NewStringFlag(&cli.StringFlag{
 		Name:    TunnelTokenFlag,
 		Usage:   "The Tunnel token. When provided along with credentials, this will take precedence.",
 		EnvVars: []string{"TUNNEL_TOKEN"},
 	})
+	tunnelTokenFileFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    TunnelTokenFileFlag,
+		Usage:   "Filepath at which to read the Tunnel token, e.g. a mounted Kubernetes secret. The token flag takes precedence over it.",
+		EnvVars: []string{"TUNNEL_TOKEN_FILE"},
+	})
+	webhookSecretFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    "webhook-secret",
+		Usage:   "Sign webhook notifications with HMAC-SHA256 using this secret. The signature is sent in the " + webhook.SignatureHeader + " header.",
+		EnvVars: []string{"TUNNEL_WEBHOOK_SECRET"},
+	})
+	// alertSlackURLFlag and alertPagerDutyKeyFlag are secrets, so they're defined here to be in secretFlags
+	alertSlackURLFlag = altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+		Name:    "alert-slack-webhook-url",
+		Usage:   "Send alerts to this Slack incoming webhook. Multiple URLs may be specified.",
+		EnvVars: []string{"TUNNEL_ALERT_SLACK_WEBHOOK_URL"},
+	})
+	alertPagerDutyKeyFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    "alert-pagerduty-routing-key",
+		Usage:   "Trigger and resolve PagerDuty incidents for alerts, with this Events API v2 routing key.",
+		EnvVars: []string{"TUNNEL_ALERT_PAGERDUTY_ROUTING_KEY"},
+	})
 	forceDeleteFlag = &cli.BoolFlag{
 		Name:    "force",
 		Aliases: []string{"f"},
@@ -145,29 +186,82 @@ var (
 		Usage:   fmt.Sprintf("Sorts the list of connections of a tunnel by the given field. Valid options are {%s}", connsSortByOptions),
 		EnvVars: []string{"TUNNEL_INFO_SORT_BY"},
 	}
+	showInfoConnectionsFlag = &cli.BoolFlag{
+		Name:    "show-connections",
+		Usage:   "Also list every connection of the connectors, with its edge location and uptime.",
+		EnvVars: []string{"TUNNEL_INFO_SHOW_CONNECTIONS"},
+	}
 	invertInfoSortFlag = &cli.BoolFlag{
 		Name:    "invert-sort",
 		Usage:   "Inverts the sort order of the tunnel info.",
 		EnvVars: []string{"TUNNEL_INFO_INVERT_SORT"},
 	}
+	bulkConcurrencyFlag = &cli.IntFlag{
+		Name:    "concurrency",
+		Usage:   "Maximum number of tunnels or routes that are processed at once when several are given.",
+		Value:   defaultBulkConcurrency,
+		EnvVars: []string{"TUNNEL_BULK_CONCURRENCY"},
+	}
+	yesFlag = &cli.BoolFlag{
+		Name:    "yes",
+		Aliases: []string{"y"},
+		Usage:   "Don't ask for confirmation before destructive commands. Nothing is asked when stdin isn't a terminal either.",
+		EnvVars: []string{"TUNNEL_ASSUME_YES"},
+	}
+	noProgressFlag = &cli.BoolFlag{
+		Name:    "no-progress",
+		Usage:   "Don't show a spinner while several tunnels or routes are processed. It's only shown when the output is a terminal.",
+		EnvVars: []string{"TUNNEL_NO_PROGRESS"},
+	}
 	cleanupClientFlag = &cli.StringFlag{
 		Name:    "connector-id",
 		Aliases: []string{"c"},
 		Usage:   `Constraints the cleanup to stop the connections of a single Connector (by its ID). You can find the various Connectors (and their IDs) currently connected to your tunnel via 'cloudflared tunnel info <name>'.`,
 		EnvVars: []string{"TUNNEL_CLEANUP_CONNECTOR"},
 	}
+	cleanupAllFlag = &cli.BoolFlag{
+		Name:    "all",
+		Usage:   "Cleanup the connections of every tunnel of the account instead of the given ones.",
+		EnvVars: []string{"TUNNEL_CLEANUP_ALL"},
+	}
+	cleanupNamePrefixFlag = &cli.StringFlag{
+		Name:    "name-prefix",
+		Aliases: []string{"np"},
+		Usage:   "With --all, only cleanup the tunnels whose name starts with the given `NAME` prefix",
+		EnvVars: []string{"TUNNEL_CLEANUP_NAME_PREFIX"},
+	}
+	cleanupStaleAfterFlag = &cli.DurationFlag{
+		Name:    "stale-after",
+		Usage:   "With --all, only cleanup the connectors whose connections were all opened longer than `DURATION` ago and are still waiting for the connector to reconnect",
+		EnvVars: []string{"TUNNEL_CLEANUP_STALE_AFTER"},
+	}
 	overwriteDNSFlag = &cli.BoolFlag{
 		Name:    overwriteDNSFlagName,
 		Aliases: []string{"f"},
 		Usage:   `Overwrites existing DNS records with this hostname`,
 		EnvVars: []string{"TUNNEL_FORCE_PROVISIONING_DNS"},
 	}
+	routeFromFileFlag = &cli.StringFlag{
+		Name:      "from-file",
+		Usage:     "Route every hostname listed in `FILE`, one per line, optionally followed by --overwrite-dns",
+		TakesFile: true,
+	}
 	createSecretFlag = &cli.StringFlag{
 		Name:    "secret",
 		Aliases: []string{"s"},
-		Usage:   "Base64 encoded secret to set for the tunnel. The decoded secret must be at least 32 bytes long. If not specified, a random 32-byte secret will be generated.",
+		Usage:   "Base64 encoded secret to set for the tunnel, or - to read it from stdin. The decoded secret must be at least 32 bytes long. If not specified, a random 32-byte secret will be generated.",
 		EnvVars: []string{"TUNNEL_CREATE_SECRET"},
 	}
+	rotateSecretFlag = &cli.StringFlag{
+		Name:    "secret",
+		Aliases: []string{"s"},
+		Usage:   "Base64 encoded secret to set for the tunnel, or - to read it from stdin. The decoded secret must be at least 32 bytes long. If not specified, a random 32-byte secret will be generated.",
+		EnvVars: []string{"TUNNEL_ROTATE_SECRET"},
+	}
+	rotateBackupFlag = &cli.BoolFlag{
+		Name:  "backup",
+		Usage: "Keep the previous credentials in a file with the .bak suffix next to the credentials file.",
+	}
 	icmpv4SrcFlag = &cli.StringFlag{
 		Name:    "icmpv4-src",
 		Usage:   "Source address to send/receive ICMPv4 messages. If not provided cloudflared will dial a local address to determine the source IP or fallback to 0.0.0.0.",
@@ -178,6 +272,30 @@ var (
 		Usage:   "Source address and the interface name to send/receive ICMPv6 messages. If not provided cloudflared will dial a local address to determine the source IP or fallback to ::.",
 		EnvVars: []string{"TUNNEL_ICMPV6_SRC"},
 	}
+	cleanupOnStartFlag = altsrc.NewBoolFlag(&cli.BoolFlag{
+		Name:    "cleanup-on-start",
+		Usage:   "Delete the connections left by the previous run of this connector, e.g. after a crash, before registering new ones. It requires the origin certificate, next to which the connector ID of the last run is kept.",
+		EnvVars: []string{"TUNNEL_CLEANUP_ON_START"},
+	})
+	remoteConfigFlag = altsrc.NewBoolFlag(&cli.BoolFlag{
+		Name:    "remote-config",
+		Usage:   "Fetch the remote configuration of the tunnel from the Cloudflare API when starting, instead of waiting for the edge to push it, and cache it to start with it when the API can't be reached. The edge keeps pushing the updates. Fetching it requires the origin certificate, or --remote-config-api-token.",
+		EnvVars: []string{"TUNNEL_REMOTE_CONFIG"},
+	})
+	remoteConfigCacheFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    "remote-config-cache",
+		Usage:   "File to cache the remote configuration in with --remote-config. Defaults to TUNNEL-ID.config.json next to the origin certificate, or in the default configuration directory without one.",
+		EnvVars: []string{"TUNNEL_REMOTE_CONFIG_CACHE"},
+	})
+	remoteConfigAPITokenFlag = altsrc.NewStringFlag(&cli.StringFlag{
+		Name:    "remote-config-api-token",
+		Usage:   "Cloudflare API token that can read the Cloudflare Tunnel configurations of the account, to fetch the remote configuration with --remote-config without the origin certificate, e.g. when the tunnel runs with --token.",
+		EnvVars: []string{"TUNNEL_REMOTE_CONFIG_API_TOKEN"},
+	})
+	dryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the effective configuration, once the configuration file, flags and defaults are merged, and exit without connecting",
+	}
 )
 
 func buildCreateCommand() *cli.Command {
@@ -191,12 +309,34 @@ func buildCreateCommand() *cli.Command {
 
   For example, to create a tunnel named 'my-tunnel' run:
 
-  $ cloudflared tunnel create my-tunnel`,
+  $ cloudflared tunnel create my-tunnel
+
+  Automation that needs to know the secret in advance, e.g. to write the credentials file elsewhere without calling
+  the Cloudflare API, can provide it instead, preferably through stdin so that it doesn't show in the process list:
+
+  $ openssl rand -base64 32 | cloudflared tunnel create --secret - my-tunnel`,
 		Flags:              []cli.Flag{outputFormatFlag, credentialsFileFlagCLIOnly, createSecretFlag},
 		CustomHelpTemplate: commandHelpTemplate(),
 	}
 }
 
+// readSecretFlag returns the value of the secret flag with the given name, which is read from stdin when it's "-".
+func readSecretFlag(c *cli.Context, name string, stdin io.Reader) (string, error) {
+	secret := c.String(name)
+	if secret != "-" {
+		return secret, nil
+	}
+	content, err := io.ReadAll(io.LimitReader(stdin, 4096))
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't read the tunnel secret from stdin")
+	}
+	secret = strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", errors.New("the tunnel secret read from stdin is empty")
+	}
+	return secret, nil
+}
+
 // generateTunnelSecret as an array of 32 bytes using secure random number generator
 func generateTunnelSecret() ([]byte, error) {
 	randomBytes := make([]byte, 32)
@@ -218,8 +358,16 @@ func createCommand(c *cli.Context) error {
 	warningChecker := updater.StartWarningCheck(c)
 	defer warningChecker.LogWarningIfAny(sc.log)
 
-	_, err = sc.create(name, c.String(CredFileFlag), c.String(createSecretFlag.Name))
-	return errors.Wrap(err, "failed to create tunnel")
+	secret, err := readSecretFlag(c, createSecretFlag.Name, os.Stdin)
+	if err != nil {
+		return err
+	}
+	tunnel, err := sc.create(name, c.String(CredFileFlag), secret)
+	if err != nil {
+		return errors.Wrap(err, "failed to create tunnel")
+	}
+	cacheTunnelNames(tunnel)
+	return nil
 }
 
 func tunnelFilePath(tunnelID uuid.UUID, directory string) (string, error) {
@@ -244,13 +392,51 @@ func writeTunnelCredentials(filePath string, credentials *connection.Credentials
 	return ioutil.WriteFile(filePath, body, 400)
 }
 
+func buildRotateSecretCommand() *cli.Command {
+	return &cli.Command{
+		Name:         "rotate-secret",
+		Action:       cliutil.ConfiguredAction(rotateSecretCommand),
+		BashComplete: cliutil.Complete(completeTunnels),
+		Usage:        "Replace the secret of a tunnel",
+		UsageText:    "cloudflared tunnel [tunnel command options] rotate-secret [subcommand options] TUNNEL",
+		Description: `Gives a tunnel a new secret, e.g. after its credentials leaked, keeping its ID and DNS routes.
+  The credentials file of the tunnel is updated in place, and its connectors must be restarted with it:
+  new connections using the previous secret are refused.
+
+  For example, to rotate the secret of 'my-tunnel' and keep the previous credentials in a .bak file run:
+
+  $ cloudflared tunnel rotate-secret --backup my-tunnel`,
+		Flags:              []cli.Flag{credentialsFileFlagCLIOnly, rotateSecretFlag, rotateBackupFlag},
+		CustomHelpTemplate: commandHelpTemplate(),
+	}
+}
+
+func rotateSecretCommand(c *cli.Context) error {
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+	if c.NArg() != 1 {
+		return cliutil.UsageError(`"cloudflared tunnel rotate-secret" requires exactly 1 argument, the name or UUID of the tunnel.`)
+	}
+	tunnelID, err := sc.findID(c.Args().First())
+	if err != nil {
+		return errors.Wrap(err, "error parsing tunnel ID")
+	}
+	secret, err := readSecretFlag(c, rotateSecretFlag.Name, os.Stdin)
+	if err != nil {
+		return err
+	}
+	return sc.rotateSecret(tunnelID, secret, c.Bool(rotateBackupFlag.Name))
+}
+
 func buildListCommand() *cli.Command {
 	return &cli.Command{
 		Name:        "list",
 		Action:      cliutil.ConfiguredAction(listCommand),
 		Usage:       "List existing tunnels",
 		UsageText:   "cloudflared tunnel [tunnel command options] list [subcommand options]",
-		Description: "cloudflared tunnel list will display all active tunnels, their created time and associated connections. Use -d flag to include deleted tunnels. See the list of options to filter the list",
+		Description: "cloudflared tunnel list will display all active tunnels, their created time, their connectors, when they were last connected and their associated connections. Connectors running a version older than the newest one running or than this cloudflared are reported as outdated. Use -d flag to include deleted tunnels. See the list of options to filter the list",
 		Flags: []cli.Flag{
 			outputFormatFlag,
 			showDeletedFlag,
@@ -262,6 +448,9 @@ func buildListCommand() *cli.Command {
 			showRecentlyDisconnected,
 			sortByFlag,
 			invertSortFlag,
+			listPageFlag,
+			listPerPageFlag,
+			bulkConcurrencyFlag,
 		},
 		CustomHelpTemplate: commandHelpTemplate(),
 	}
@@ -302,11 +491,23 @@ func listCommand(c *cli.Context) error {
 	if maxFetch := c.Int("max-fetch-size"); maxFetch > 0 {
 		filter.MaxFetchSize(uint(maxFetch))
 	}
+	page, perPage := c.Uint(listPageFlag.Name), c.Uint(listPerPageFlag.Name)
+	if c.IsSet(listPageFlag.Name) || c.IsSet(listPerPageFlag.Name) {
+		if page == 0 {
+			page = 1
+		}
+		if perPage == 0 {
+			return cliutil.UsageError("--%s must be at least 1", listPerPageFlag.Name)
+		}
+		filter.Page(page)
+		filter.MaxFetchSize(perPage)
+	}
 
 	tunnels, err := sc.list(filter)
 	if err != nil {
 		return err
 	}
+	cacheTunnelNames(tunnels...)
 
 	// Sort the tunnels
 	sortBy := c.String("sort-by")
@@ -318,7 +519,7 @@ func listCommand(c *cli.Context) error {
 				return tunnels[i].Name < tunnels[j].Name
 			case "id":
 				return tunnels[i].ID.String() < tunnels[j].ID.String()
-			case "createdAt":
+			case "createdAt", "created":
 				return tunnels[i].CreatedAt.Unix() < tunnels[j].CreatedAt.Unix()
 			case "deletedAt":
 				return tunnels[i].DeletedAt.Unix() < tunnels[j].DeletedAt.Unix()
@@ -338,12 +539,24 @@ func listCommand(c *cli.Context) error {
 		sc.log.Error().Msgf("%s is not a valid sort field. Valid sort fields are %s. Defaulting to 'name'.", sortBy, allSortByOptions)
 	}
 
+	var currentVersion string
+	if buildInfo != nil {
+		currentVersion = buildInfo.CloudflaredVersion
+	}
+	listed, err := sc.summarizeConnectors(tunnels, currentVersion)
+	if err != nil {
+		return err
+	}
+
 	if outputFormat := c.String(outputFormatFlag.Name); outputFormat != "" {
-		return renderOutput(outputFormat, tunnels)
+		return renderOutput(outputFormat, listed)
 	}
 
-	if len(tunnels) > 0 {
-		formatAndPrintTunnelList(tunnels, c.Bool("show-recently-disconnected"))
+	if len(listed) > 0 {
+		formatAndPrintTunnelList(listed, c.Bool("show-recently-disconnected"))
+		if page > 0 && uint(len(tunnels)) == perPage {
+			fmt.Printf("There may be more tunnels, run the same command with --%s %d to list them.\n", listPageFlag.Name, page+1)
+		}
 	} else {
 		fmt.Println("No tunnels were found for the given filter flags. You can use 'cloudflared tunnel create' to create a tunnel.")
 	}
@@ -351,22 +564,24 @@ func listCommand(c *cli.Context) error {
 	return nil
 }
 
-func formatAndPrintTunnelList(tunnels []*cfapi.Tunnel, showRecentlyDisconnected bool) {
+func formatAndPrintTunnelList(tunnels []*listedTunnel, showRecentlyDisconnected bool) {
 	writer := tabWriter()
 	defer writer.Flush()
 
 	_, _ = fmt.Fprintln(writer, "You can obtain more detailed information for each tunnel with `cloudflared tunnel info <name/uuid>`")
 
 	// Print column headers with tabbed columns
-	_, _ = fmt.Fprintln(writer, "ID\tNAME\tCREATED\tCONNECTIONS\t")
+	_, _ = fmt.Fprintln(writer, "ID\tNAME\tCREATED\tCONNECTORS\tLAST SEEN\tCONNECTIONS\t")
 
 	// Loop through tunnels, create formatted string for each, and print using tabwriter
 	for _, t := range tunnels {
 		formattedStr := fmt.Sprintf(
-			"%s\t%s\t%s\t%s\t",
+			"%s\t%s\t%s\t%s\t%s\t%s\t",
 			t.ID,
 			t.Name,
 			t.CreatedAt.Format(time.RFC3339),
+			t.fmtConnectors(),
+			t.lastSeen(),
 			fmtConnections(t.Connections, showRecentlyDisconnected),
 		)
 		_, _ = fmt.Fprintln(writer, formattedStr)
@@ -400,16 +615,18 @@ func fmtConnections(connections []cfapi.Connection, showRecentlyDisconnected boo
 
 func buildInfoCommand() *cli.Command {
 	return &cli.Command{
-		Name:        "info",
-		Action:      cliutil.ConfiguredAction(tunnelInfo),
-		Usage:       "List details about the active connectors for a tunnel",
-		UsageText:   "cloudflared tunnel [tunnel command options] info [subcommand options] [TUNNEL]",
-		Description: "cloudflared tunnel info displays details about the active connectors for a given tunnel (identified by name or uuid).",
+		Name:         "info",
+		Action:       cliutil.ConfiguredAction(tunnelInfo),
+		BashComplete: cliutil.Complete(completeTunnels),
+		Usage:        "List details about the active connectors for a tunnel",
+		UsageText:    "cloudflared tunnel [tunnel command options] info [subcommand options] [TUNNEL]",
+		Description:  "cloudflared tunnel info displays details about the active connectors for a given tunnel (identified by name or uuid): their version, how long they have been connected and the edge locations they are connected to. Use --show-connections to also list each of their connections.",
 		Flags: []cli.Flag{
 			outputFormatFlag,
 			showRecentlyDisconnected,
 			sortInfoByFlag,
 			invertInfoSortFlag,
+			showInfoConnectionsFlag,
 		},
 		CustomHelpTemplate: commandHelpTemplate(),
 	}
@@ -449,7 +666,7 @@ func tunnelInfo(c *cli.Context) error {
 			switch sortBy {
 			case "id":
 				return clients[i].ID.String() < clients[j].ID.String()
-			case "createdAt":
+			case "createdAt", "startedAt":
 				return clients[i].RunAt.Unix() < clients[j].RunAt.Unix()
 			case "numConnections":
 				return len(clients[i].Connections) < len(clients[j].Connections)
@@ -485,7 +702,7 @@ func tunnelInfo(c *cli.Context) error {
 	}
 
 	if len(clients) > 0 {
-		formatAndPrintConnectionsList(info, c.Bool("show-recently-disconnected"))
+		formatAndPrintConnectionsList(info, c.Bool("show-recently-disconnected"), c.Bool(showInfoConnectionsFlag.Name))
 	} else {
 		fmt.Printf("Your tunnel %s does not have any active connection.\n", tunnelID)
 	}
@@ -506,7 +723,7 @@ func getTunnel(sc *subcommandContext, tunnelID uuid.UUID) (*cfapi.Tunnel, error)
 	return tunnels[0], nil
 }
 
-func formatAndPrintConnectionsList(tunnelInfo Info, showRecentlyDisconnected bool) {
+func formatAndPrintConnectionsList(tunnelInfo Info, showRecentlyDisconnected, showConnections bool) {
 	writer := tabWriter()
 	defer writer.Flush()
 
@@ -527,7 +744,8 @@ func formatAndPrintConnectionsList(tunnelInfo Info, showRecentlyDisconnected boo
 	}
 
 	// Print the connector table
-	_, _ = fmt.Fprintln(writer, "CONNECTOR ID\tCREATED\tARCHITECTURE\tVERSION\tORIGIN IP\tEDGE\t")
+	now := time.Now()
+	_, _ = fmt.Fprintln(writer, "CONNECTOR ID\tCREATED\tUPTIME\tARCHITECTURE\tVERSION\tORIGIN IP\tEDGE\t")
 	for _, c := range tunnelInfo.Connectors {
 		conns := fmtConnections(c.Connections, showRecentlyDisconnected)
 		if len(conns) == 0 {
@@ -535,9 +753,10 @@ func formatAndPrintConnectionsList(tunnelInfo Info, showRecentlyDisconnected boo
 		}
 		originIp := c.Connections[0].OriginIP.String()
 		formattedStr := fmt.Sprintf(
-			"%s\t%s\t%s\t%s\t%s\t%s\t",
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\t",
 			c.ID,
 			c.RunAt.Format(time.RFC3339),
+			fmtUptime(connectorUptime(c.Connections, now, showRecentlyDisconnected)),
 			c.Arch,
 			c.Version,
 			originIp,
@@ -545,6 +764,65 @@ func formatAndPrintConnectionsList(tunnelInfo Info, showRecentlyDisconnected boo
 		)
 		_, _ = fmt.Fprintln(writer, formattedStr)
 	}
+
+	if showConnections {
+		_, _ = fmt.Fprintln(writer)
+		_, _ = fmt.Fprintln(writer, "CONNECTOR ID\tCONNECTION ID\tEDGE\tORIGIN IP\tOPENED\tUPTIME\tSTATUS\t")
+		for _, row := range connectionRows(tunnelInfo.Connectors, now, showRecentlyDisconnected) {
+			_, _ = fmt.Fprintln(writer, row)
+		}
+	}
+}
+
+// connectorUptime is how long the oldest connection of a connector has been open.
+func connectorUptime(connections []cfapi.Connection, now time.Time, showRecentlyDisconnected bool) time.Duration {
+	var oldest time.Time
+	for _, connection := range connections {
+		if connection.IsPendingReconnect && !showRecentlyDisconnected {
+			continue
+		}
+		if oldest.IsZero() || connection.OpenedAt.Before(oldest) {
+			oldest = connection.OpenedAt
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return now.Sub(oldest)
+}
+
+func fmtUptime(uptime time.Duration) string {
+	if uptime <= 0 {
+		return "-"
+	}
+	return uptime.Truncate(time.Second).String()
+}
+
+// connectionRows formats a table row for every connection of the connectors.
+func connectionRows(connectors []*cfapi.ActiveClient, now time.Time, showRecentlyDisconnected bool) []string {
+	var rows []string
+	for _, c := range connectors {
+		for _, connection := range c.Connections {
+			status := "active"
+			if connection.IsPendingReconnect {
+				if !showRecentlyDisconnected {
+					continue
+				}
+				status = "pending reconnect"
+			}
+			rows = append(rows, fmt.Sprintf(
+				"%s\t%s\t%s\t%s\t%s\t%s\t%s\t",
+				c.ID,
+				connection.ID,
+				connection.ColoName,
+				connection.OriginIP,
+				connection.OpenedAt.Format(time.RFC3339),
+				fmtUptime(now.Sub(connection.OpenedAt)),
+				status,
+			))
+		}
+	}
+	return rows
 }
 
 func tabWriter() *tabwriter.Writer {
@@ -564,10 +842,11 @@ func buildDeleteCommand() *cli.Command {
 	return &cli.Command{
 		Name:               "delete",
 		Action:             cliutil.ConfiguredAction(deleteCommand),
+		BashComplete:       cliutil.Complete(completeTunnels),
 		Usage:              "Delete existing tunnel by UUID or name",
-		UsageText:          "cloudflared tunnel [tunnel command options] delete [subcommand options] TUNNEL",
-		Description:        "cloudflared tunnel delete will delete tunnels with the given tunnel UUIDs or names. A tunnel cannot be deleted if it has active connections. To delete the tunnel unconditionally, use -f flag.",
-		Flags:              []cli.Flag{credentialsFileFlagCLIOnly, forceDeleteFlag},
+		UsageText:          "cloudflared tunnel [tunnel command options] delete [subcommand options] TUNNEL...",
+		Description:        "cloudflared tunnel delete will delete tunnels with the given tunnel UUIDs or names, or whose names match the given glob patterns, e.g. 'staging-*'. The tunnels to delete are listed for confirmation, unless --yes is given. A tunnel cannot be deleted if it has active connections. To delete the tunnel unconditionally, use -f flag.",
+		Flags:              []cli.Flag{credentialsFileFlagCLIOnly, forceDeleteFlag, yesFlag, bulkConcurrencyFlag, noProgressFlag},
 		CustomHelpTemplate: commandHelpTemplate(),
 	}
 }
@@ -590,7 +869,27 @@ func deleteCommand(c *cli.Context) error {
 		return err
 	}
 
-	return sc.delete(tunnelIDs)
+	names := readTunnelNamesCache()
+	var details []string
+	for _, tunnelID := range tunnelIDs {
+		details = append(details, describeTunnel(tunnelID, names))
+		if c.Bool(forceDeleteFlag.Name) {
+			details = append(details, fmt.Sprintf("the connections of tunnel %s", tunnelID))
+		}
+		if path, err := sc.credentialFinder(tunnelID).Path(); err == nil {
+			details = append(details, fmt.Sprintf("the credentials file %s", path))
+		}
+	}
+	details = append(details, "DNS records and private network routes to these tunnels are kept, and stop working")
+	if err := confirmDestruction(c, fmt.Sprintf("Deleting %d tunnel(s) and their credentials files", len(tunnelIDs)), details); err != nil {
+		return err
+	}
+
+	if err := sc.delete(tunnelIDs); err != nil {
+		return err
+	}
+	forgetTunnelNames(tunnelIDs)
+	return nil
 }
 
 func renderOutput(format string, v interface{}) error {
@@ -610,27 +909,43 @@ func buildRunCommand() *cli.Command {
 	flags := []cli.Flag{
 		credentialsFileFlag,
 		credentialsContentsFlag,
+		credentialsStoreFlag,
 		postQuantumFlag,
 		selectProtocolFlag,
 		featuresFlag,
 		tunnelTokenFlag,
+		tunnelTokenFileFlag,
 		icmpv4SrcFlag,
 		icmpv6SrcFlag,
+		cleanupOnStartFlag,
+		remoteConfigFlag,
+		remoteConfigCacheFlag,
+		remoteConfigAPITokenFlag,
+		dryRunFlag,
 	}
 	flags = append(flags, configureProxyFlags(false)...)
 	return &cli.Command{
-		Name:      "run",
-		Action:    cliutil.ConfiguredAction(runCommand),
-		Usage:     "Proxy a local web server by running the given tunnel",
-		UsageText: "cloudflared tunnel [tunnel command options] run [subcommand options] [TUNNEL]",
+		Name:         "run",
+		Action:       cliutil.ConfiguredAction(runCommand),
+		BashComplete: cliutil.Complete(completeTunnels),
+		Usage:        "Proxy a local web server by running the given tunnel",
+		UsageText:    "cloudflared tunnel [tunnel command options] run [subcommand options] [TUNNEL]",
 		Description: `Runs the tunnel identified by name or UUID, creating highly available connections
   between your server and the Cloudflare edge. You can provide name or UUID of tunnel to run either as the
   last command line argument or in the configuration file using "tunnel: TUNNEL".
 
   This command requires the tunnel credentials file created when "cloudflared tunnel create" was run,
   however it does not need access to cert.pem from "cloudflared login" if you identify the tunnel by UUID.
+  Alternatively, the tunnel can be run with only the token printed by "cloudflared tunnel token", given with
+  --token or read from --token-file, in which case neither a credentials file nor cert.pem is needed.
   If you experience other problems running the tunnel, "cloudflared tunnel cleanup" may help by removing
-  any old connection records.
+  any old connection records, which --cleanup-on-start does for the previous run of this connector.
+  With --remote-config, the ingress rules managed in the Cloudflare dashboard or API are fetched when starting
+  and cached, so that no configuration file has to be shipped to the host. A tunnel run with a token fetches
+  them with --remote-config-api-token.
+
+  To review a change before restarting the tunnel, --dry-run resolves the credentials and prints the effective
+  ingress rules, origin request defaults, protocol and edge settings as JSON, then exits without connecting.
 `,
 		Flags:              flags,
 		CustomHelpTemplate: commandHelpTemplate(),
@@ -653,13 +968,24 @@ func runCommand(c *cli.Context) error {
 			"your origin will not be reachable. You should remove the `hostname` property to avoid this warning.")
 	}
 
+	if tokenPath := c.String(TunnelTokenFileFlag); tokenPath != "" && c.String(TunnelTokenFlag) == "" {
+		tokenStr, err := os.ReadFile(tokenPath)
+		if err != nil {
+			return cliutil.WithExitCode(cliutil.ExitCodeCredentials, errors.Wrap(err, "couldn't read the Tunnel token file"))
+		}
+		// The rest of the run tells token-based tunnels apart by the token flag being set
+		if err := c.Set(TunnelTokenFlag, strings.TrimSpace(string(tokenStr))); err != nil {
+			return err
+		}
+	}
+
 	// Check if token is provided and if not use default tunnelID flag method
 	if tokenStr := c.String(TunnelTokenFlag); tokenStr != "" {
-		if token, err := ParseToken(tokenStr); err == nil {
-			return sc.runWithCredentials(token.Credentials())
+		token, err := ParseToken(tokenStr)
+		if err != nil {
+			return cliutil.WithExitCode(cliutil.ExitCodeCredentials, errors.Wrap(err, "Provided Tunnel token is not valid"))
 		}
-
-		return cliutil.UsageError("Provided Tunnel token is not valid.")
+		return sc.runWithCredentials(token.Credentials())
 	} else {
 		tunnelRef := c.Args().First()
 		if tunnelRef == "" {
@@ -675,7 +1001,7 @@ func runCommand(c *cli.Context) error {
 }
 
 func ParseToken(tokenStr string) (*connection.TunnelToken, error) {
-	content, err := base64.StdEncoding.DecodeString(tokenStr)
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(tokenStr))
 	if err != nil {
 		return nil, err
 	}
@@ -684,6 +1010,9 @@ func ParseToken(tokenStr string) (*connection.TunnelToken, error) {
 	if err := json.Unmarshal(content, &token); err != nil {
 		return nil, err
 	}
+	if token.AccountTag == "" || len(token.TunnelSecret) == 0 || token.TunnelID == uuid.Nil {
+		return nil, errors.New("the token is missing its account tag, tunnel secret or tunnel ID")
+	}
 	return &token, nil
 }
 
@@ -699,29 +1028,69 @@ func buildCleanupCommand() *cli.Command {
 	return &cli.Command{
 		Name:               "cleanup",
 		Action:             cliutil.ConfiguredAction(cleanupCommand),
+		BashComplete:       cliutil.Complete(completeTunnels),
 		Usage:              "Cleanup tunnel connections",
-		UsageText:          "cloudflared tunnel [tunnel command options] cleanup [subcommand options] TUNNEL",
-		Description:        "Delete connections for tunnels with the given UUIDs or names.",
-		Flags:              []cli.Flag{cleanupClientFlag},
+		UsageText:          "cloudflared tunnel [tunnel command options] cleanup [subcommand options] TUNNEL|--all",
+		Description:        "Delete connections for tunnels with the given UUIDs or names, or with --all for every tunnel that isn't deleted, optionally only the ones whose name starts with --name-prefix and that have connections stale for longer than --stale-after.",
+		Flags:              []cli.Flag{cleanupClientFlag, cleanupAllFlag, cleanupNamePrefixFlag, cleanupStaleAfterFlag, yesFlag, bulkConcurrencyFlag, noProgressFlag},
 		CustomHelpTemplate: commandHelpTemplate(),
 	}
 }
 
 func cleanupCommand(c *cli.Context) error {
-	if c.NArg() < 1 {
+	all := c.Bool(cleanupAllFlag.Name)
+	if all && c.NArg() > 0 {
+		return cliutil.UsageError(`"cloudflared tunnel cleanup --all" doesn't accept tunnels as arguments.`)
+	}
+	if !all && c.NArg() < 1 {
 		return cliutil.UsageError(`"cloudflared tunnel cleanup" requires at least 1 argument, the IDs of the tunnels to cleanup connections.`)
 	}
+	for _, allOnlyFlag := range []string{cleanupNamePrefixFlag.Name, cleanupStaleAfterFlag.Name} {
+		if !all && c.IsSet(allOnlyFlag) {
+			return cliutil.UsageError(`"cloudflared tunnel cleanup --%s" requires --all.`, allOnlyFlag)
+		}
+	}
+	if c.IsSet(cleanupStaleAfterFlag.Name) && c.IsSet(cleanupClientFlag.Name) {
+		return cliutil.UsageError(`"cloudflared tunnel cleanup --%s" picks the stale connectors, it can't be used with --%s.`, cleanupStaleAfterFlag.Name, cleanupClientFlag.Name)
+	}
 
 	sc, err := newSubcommandContext(c)
 	if err != nil {
 		return err
 	}
 
-	tunnelIDs, err := sc.findIDs(c.Args().Slice())
+	var tunnelIDs []uuid.UUID
+	if all {
+		tunnelIDs, err = sc.allTunnelIDs(c.String(cleanupNamePrefixFlag.Name), c.Duration(cleanupStaleAfterFlag.Name))
+	} else {
+		tunnelIDs, err = sc.findIDs(c.Args().Slice())
+	}
 	if err != nil {
 		return err
 	}
+	if len(tunnelIDs) == 0 {
+		fmt.Println("No tunnel has connections to cleanup.")
+		return nil
+	}
 
+	names := readTunnelNamesCache()
+	details := make([]string, len(tunnelIDs))
+	for i, tunnelID := range tunnelIDs {
+		details[i] = describeTunnel(tunnelID, names)
+	}
+	summary := fmt.Sprintf("Cleaning up the connections of %d tunnel(s)", len(tunnelIDs))
+	if connector := c.String(cleanupClientFlag.Name); connector != "" {
+		summary = fmt.Sprintf("Cleaning up the connections of connector %s of %d tunnel(s)", connector, len(tunnelIDs))
+	} else if c.Duration(cleanupStaleAfterFlag.Name) > 0 {
+		summary = fmt.Sprintf("Cleaning up the connections of the stale connectors of %d tunnel(s)", len(tunnelIDs))
+	}
+	if err := confirmDestruction(c, summary, details); err != nil {
+		return err
+	}
+
+	if staleAfter := c.Duration(cleanupStaleAfterFlag.Name); staleAfter > 0 {
+		return sc.cleanupStaleConnections(tunnelIDs, staleAfter)
+	}
 	return sc.cleanupConnections(tunnelIDs)
 }
 
@@ -729,6 +1098,7 @@ func buildTokenCommand() *cli.Command {
 	return &cli.Command{
 		Name:               "token",
 		Action:             cliutil.ConfiguredAction(tokenCommand),
+		BashComplete:       cliutil.Complete(completeTunnels),
 		Usage:              "Fetch the credentials token for an existing tunnel (by name or UUID) that allows to run it",
 		UsageText:          "cloudflared tunnel [tunnel command options] token [subcommand options] TUNNEL",
 		Description:        "cloudflared tunnel token will fetch the credentials token for a given tunnel (by its name or UUID), which is then used to run the tunnel. This command fails if the tunnel does not exist or has been deleted. Use the flag `cloudflared tunnel token --cred-file /my/path/file.json TUNNEL` to output the token to the credentials JSON file. Note: this command only works for Tunnels created since cloudflared version 2022.3.0",
@@ -787,6 +1157,9 @@ func buildRouteCommand() *cli.Command {
 
 To route a hostname by creating a DNS CNAME record to a tunnel:
    cloudflared tunnel route dns <tunnel ID or name> <hostname>
+To list or delete those records:
+   cloudflared tunnel route dns list [<tunnel ID or name>]
+   cloudflared tunnel route dns delete <hostname>
 You can read more at: https://developers.cloudflare.com/cloudflare-one/connections/connect-apps/routing-to-tunnel/dns
 
 To use this tunnel as a load balancer origin, creating pool and load balancer if necessary:
@@ -801,12 +1174,21 @@ Further information about managing Cloudflare WARP traffic to your tunnel is ava
 		CustomHelpTemplate: commandHelpTemplate(),
 		Subcommands: []*cli.Command{
 			{
-				Name:        "dns",
-				Action:      cliutil.ConfiguredAction(routeDnsCommand),
-				Usage:       "HostnameRoute a hostname by creating a DNS CNAME record to a tunnel",
-				UsageText:   "cloudflared tunnel route dns [TUNNEL] [HOSTNAME]",
-				Description: `Creates a DNS CNAME record hostname that points to the tunnel.`,
-				Flags:       []cli.Flag{overwriteDNSFlag},
+				Name:      "dns",
+				Action:    cliutil.ConfiguredAction(routeDnsCommand),
+				Usage:     "HostnameRoute a hostname by creating a DNS CNAME record to a tunnel",
+				UsageText: "cloudflared tunnel route dns [TUNNEL] [HOSTNAME]\n   cloudflared tunnel route dns --from-file FILE [TUNNEL]",
+				Description: `Creates a DNS CNAME record hostname that points to the tunnel.
+
+With --from-file, creates one for every hostname listed in the file, one per line, and prints a table of the
+results. A hostname can be followed by --overwrite-dns to overwrite its existing record; --overwrite-dns on the
+command line overwrites them all. Empty lines and lines starting with # are skipped.
+
+To audit the existing routes, e.g. the ones left to deleted tunnels, and remove them:
+   cloudflared tunnel route dns list --stale
+   cloudflared tunnel route dns delete <hostname>`,
+				Flags:       []cli.Flag{overwriteDNSFlag, routeFromFileFlag, bulkConcurrencyFlag, noProgressFlag},
+				Subcommands: []*cli.Command{buildRouteDNSListCommand(), buildRouteDNSDeleteCommand()},
 			},
 			{
 				Name:        "lb",
@@ -884,6 +1266,12 @@ func validateHostname(s string, allowWildcardSubdomain bool) bool {
 }
 
 func routeDnsCommand(c *cli.Context) error {
+	if c.IsSet(routeFromFileFlag.Name) {
+		if c.NArg() != 1 {
+			return cliutil.UsageError(`This command expects the format "cloudflared tunnel route dns --from-file <file> <tunnel name/id>"`)
+		}
+		return routeDNSFromFile(c)
+	}
 	if c.NArg() != 2 {
 		return cliutil.UsageError(`This command expects the format "cloudflared tunnel route dns <tunnel name/id> <hostname>"`)
 	}
@@ -3,13 +3,18 @@ package tunnel
 import (
 	"encoding/base64"
 	"encoding/json"
+	"flag"
+	"net"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 
 	"github.com/cloudflare/cloudflared/cfapi"
 	"github.com/cloudflare/cloudflared/connection"
@@ -90,6 +95,30 @@ func Test_fmtConnections(t *testing.T) {
 	}
 }
 
+func TestConnectorUptimeAndConnectionRows(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	connectorID := uuid.MustParse("9b7f1a43-2a05-4b4e-a2b1-5d5e4cdb9a21")
+	connectionID := uuid.MustParse("3c1d7c9e-93a8-4bdb-9b0a-3ab1cc8e0e11")
+	pendingID := uuid.MustParse("7a0d4f5b-0c3e-4f54-9d1e-28f0a67d1c35")
+	connections := []cfapi.Connection{
+		{ColoName: "SFO", ID: connectionID, OriginIP: net.ParseIP("10.0.0.1"), OpenedAt: now.Add(-90 * time.Minute)},
+		{ColoName: "LAX", ID: pendingID, OriginIP: net.ParseIP("10.0.0.1"), OpenedAt: now.Add(-3 * time.Hour), IsPendingReconnect: true},
+	}
+
+	assert.Equal(t, 90*time.Minute, connectorUptime(connections, now, false))
+	assert.Equal(t, 3*time.Hour, connectorUptime(connections, now, true))
+	assert.Equal(t, time.Duration(0), connectorUptime(connections[1:], now, false))
+	assert.Equal(t, "1h30m0s", fmtUptime(90*time.Minute+300*time.Millisecond))
+	assert.Equal(t, "-", fmtUptime(0))
+
+	connectors := []*cfapi.ActiveClient{{ID: connectorID, Connections: connections}}
+	assert.Equal(t, []string{
+		"9b7f1a43-2a05-4b4e-a2b1-5d5e4cdb9a21\t3c1d7c9e-93a8-4bdb-9b0a-3ab1cc8e0e11\tSFO\t10.0.0.1\t2023-05-01T10:30:00Z\t1h30m0s\tactive\t",
+	}, connectionRows(connectors, now, false))
+	assert.Len(t, connectionRows(connectors, now, true), 2)
+	assert.Contains(t, connectionRows(connectors, now, true)[1], "\tLAX\t10.0.0.1\t2023-05-01T09:00:00Z\t3h0m0s\tpending reconnect\t")
+}
+
 func TestTunnelfilePath(t *testing.T) {
 	tunnelID, err := uuid.Parse("f48d8918-bc23-4647-9d48-082c5b76de65")
 	assert.NoError(t, err)
@@ -201,4 +230,34 @@ func Test_TunnelToken(t *testing.T) {
 	token, err = ParseToken(token64)
 	require.NoError(t, err)
 	require.Equal(t, token, expectedToken)
+
+	// Tokens read from files or environment variables often end with a newline
+	token, err = ParseToken(token64 + "\n")
+	require.NoError(t, err)
+	require.Equal(t, token, expectedToken)
+
+	incompleteJsonStr, err := json.Marshal(&connection.TunnelToken{AccountTag: "abc", TunnelSecret: []byte("secret")})
+	require.NoError(t, err)
+	_, err = ParseToken(base64.StdEncoding.EncodeToString(incompleteJsonStr))
+	require.Error(t, err)
+}
+
+func TestReadSecretFlag(t *testing.T) {
+	newContext := func(secret string) *cli.Context {
+		flagSet := flag.NewFlagSet("test", flag.PanicOnError)
+		flagSet.String(createSecretFlag.Name, "", "")
+		require.NoError(t, flagSet.Set(createSecretFlag.Name, secret))
+		return cli.NewContext(cli.NewApp(), flagSet, nil)
+	}
+
+	secret, err := readSecretFlag(newContext("c2VjcmV0"), createSecretFlag.Name, strings.NewReader("ignored"))
+	require.NoError(t, err)
+	require.Equal(t, "c2VjcmV0", secret)
+
+	secret, err = readSecretFlag(newContext("-"), createSecretFlag.Name, strings.NewReader("c2VjcmV0\n"))
+	require.NoError(t, err)
+	require.Equal(t, "c2VjcmV0", secret)
+
+	_, err = readSecretFlag(newContext("-"), createSecretFlag.Name, strings.NewReader(""))
+	require.Error(t, err)
 }
@@ -67,13 +67,13 @@ which virtual network's routing table you want to add the route to with:
 			{
 				Name:      "delete",
 				Action:    cliutil.ConfiguredAction(deleteRouteCommand),
-				Usage:     "Delete a row from your organization's private routing table",
-				UsageText: "cloudflared tunnel [--config FILEPATH] route ip delete [flags] [CIDR]",
-				Description: `Deletes the row for a given CIDR from your routing table. That portion of your network
+				Usage:     "Delete rows from your organization's private routing table",
+				UsageText: "cloudflared tunnel [--config FILEPATH] route ip delete [flags] [CIDR...]",
+				Description: `Deletes the rows for the given CIDRs from your routing table. Those portions of your network
 will no longer be reachable by the WARP clients. Note that if you use virtual
 networks, then you have to tell which virtual network whose routing table you
 have a row deleted from.`,
-				Flags: []cli.Flag{vnetFlag},
+				Flags: []cli.Flag{vnetFlag, yesFlag},
 			},
 			{
 				Name:      "get",
@@ -186,35 +186,63 @@ func deleteRouteCommand(c *cli.Context) error {
 		return err
 	}
 
-	if c.NArg() != 1 {
-		return errors.New("You must supply exactly one argument, the network whose route you want to delete (in CIDR form e.g. 1.2.3.4/32)")
+	if c.NArg() < 1 {
+		return errors.New("You must supply at least one argument, the networks whose route you want to delete (in CIDR form e.g. 1.2.3.4/32)")
 	}
 
-	_, network, err := net.ParseCIDR(c.Args().First())
-	if err != nil {
-		return errors.Wrap(err, "Invalid network CIDR")
-	}
-	if network == nil {
-		return errors.New("Invalid network CIDR")
-	}
-
-	params := cfapi.DeleteRouteParams{
-		Network: *network,
+	networks := make([]*net.IPNet, c.NArg())
+	for i, arg := range c.Args().Slice() {
+		_, network, err := net.ParseCIDR(arg)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid network CIDR %s", arg)
+		}
+		if network == nil {
+			return fmt.Errorf("Invalid network CIDR %s", arg)
+		}
+		networks[i] = network
 	}
 
+	var vnetID *uuid.UUID
 	if c.IsSet(vnetFlag.Name) {
-		vnetId, err := getVnetId(sc, c.String(vnetFlag.Name))
+		id, err := getVnetId(sc, c.String(vnetFlag.Name))
 		if err != nil {
 			return err
 		}
-		params.VNetID = &vnetId
+		vnetID = &id
 	}
 
-	if err := sc.deleteRoute(params); err != nil {
-		return errors.Wrap(err, "API error")
+	if _, err := sc.client(); err != nil {
+		return errors.Wrap(err, noClientMsg)
 	}
-	fmt.Printf("Successfully deleted route for %s\n", network)
-	return nil
+	details := make([]string, len(networks))
+	// Describe the routes the API knows, the deletion reports the ones it doesn't
+	_ = runBulk(len(networks), sc.bulkConcurrency(), func(i int) error {
+		network := networks[i]
+		details[i] = fmt.Sprintf("route for %s", network)
+		route, err := sc.getRouteByIP(cfapi.GetRouteByIpParams{Ip: network.IP, VNetID: vnetID})
+		if err == nil && route.Network.String() == network.String() {
+			details[i] = fmt.Sprintf("route for %s over tunnel %s (%s)", network, route.TunnelName, route.TunnelID)
+			if route.Comment != "" {
+				details[i] += fmt.Sprintf(", comment: %s", route.Comment)
+			}
+		}
+		return nil
+	})
+	if err := confirmDestruction(c, "Deleting private network routes", details); err != nil {
+		return err
+	}
+
+	items := make([]string, len(networks))
+	for i, network := range networks {
+		items[i] = network.String()
+	}
+	return sc.runBulkWithProgress("Deleting routes", items, func(i int) error {
+		if err := sc.deleteRoute(cfapi.DeleteRouteParams{Network: *networks[i], VNetID: vnetID}); err != nil {
+			return errors.Wrapf(err, "API error deleting the route for %s", networks[i])
+		}
+		fmt.Printf("Successfully deleted route for %s\n", networks[i])
+		return nil
+	})
 }
 
 func getRouteByIPCommand(c *cli.Context) error {
@@ -0,0 +1,242 @@
+package tunnel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cloudflare/cloudflared/cfapi"
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const (
+	wizardDefaultService = "http://localhost:8080"
+	wizardCatchAll       = "http_status:404"
+)
+
+func buildInitCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "init",
+		Action:    cliutil.ConfiguredAction(initCommand),
+		Usage:     "Set up a tunnel interactively, from logging in to running it as a service",
+		UsageText: "cloudflared tunnel [tunnel command options] init",
+		Description: `Guides you through setting up a tunnel: it logs in if there is no origin certificate yet, creates
+  the tunnel (or reuses the one with the same name), asks which local services to expose on which hostnames,
+  writes the configuration file, routes the hostnames to the tunnel in DNS, and optionally installs cloudflared
+  as a service. It does what "login", "create", "route dns" and "service install" do, in one flow.`,
+		CustomHelpTemplate: commandHelpTemplate(),
+	}
+}
+
+// wizardConfig is the configuration file that the wizard writes
+type wizardConfig struct {
+	Tunnel          string       `yaml:"tunnel"`
+	CredentialsFile string       `yaml:"credentials-file"`
+	Ingress         []wizardRule `yaml:"ingress"`
+}
+
+type wizardRule struct {
+	Hostname string `yaml:"hostname,omitempty"`
+	Service  string `yaml:"service"`
+}
+
+// prompter asks questions on a terminal.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out}
+}
+
+// ask returns the answer to question, or defaultValue if the answer is empty.
+func (p *prompter) ask(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", errors.Wrap(err, "failed to read the answer")
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+func (p *prompter) confirm(question string) (bool, error) {
+	answer, err := p.ask(question+" (y/n)", "n")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+func initCommand(c *cli.Context) error {
+	p := newPrompter(os.Stdin, os.Stdout)
+
+	fmt.Fprintln(p.out, "Step 1/6: log in to Cloudflare")
+	certPath, loggedIn, err := checkForExistingCert()
+	if err != nil {
+		return err
+	}
+	if loggedIn {
+		fmt.Fprintf(p.out, "Using the origin certificate at %s\n", certPath)
+	} else if err := login(c); err != nil {
+		return err
+	}
+
+	sc, err := newSubcommandContext(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out, "\nStep 2/6: create the tunnel")
+	defaultName, _ := os.Hostname()
+	name, err := p.ask("Name of the tunnel", defaultName)
+	if err != nil {
+		return err
+	}
+	tunnel, exists, err := sc.tunnelActive(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Fprintf(p.out, "Reusing the existing tunnel %s with ID %s\n", tunnel.Name, tunnel.ID)
+	} else if tunnel, err = sc.create(name, "", ""); err != nil {
+		return errors.Wrap(err, "failed to create tunnel")
+	}
+	cacheTunnelNames(tunnel)
+	credentialsPath, err := sc.credentialFinder(tunnel.ID).Path()
+	if err != nil {
+		return errors.Wrapf(err, "the credentials file of tunnel %s wasn't found, it's only written on the machine that created the tunnel", tunnel.ID)
+	}
+
+	fmt.Fprintln(p.out, "\nStep 3/6: pick the local services to expose")
+	rules, err := askServices(p)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.out, "\nStep 4/6: write the configuration file")
+	defaultConfigPath, err := homedir.Expand(filepath.Join(config.DefaultConfigSearchDirectories()[0], config.DefaultConfigFiles[0]))
+	if err != nil {
+		return err
+	}
+	configPath, err := p.ask("Configuration file", defaultConfigPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		overwrite, err := p.confirm(fmt.Sprintf("%s already exists, overwrite it?", configPath))
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return fmt.Errorf("%s was left as is", configPath)
+		}
+	}
+	if err := writeWizardConfig(configPath, wizardConfig{
+		Tunnel:          tunnel.ID.String(),
+		CredentialsFile: credentialsPath,
+		Ingress:         rules,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Wrote %s\n", configPath)
+
+	fmt.Fprintln(p.out, "\nStep 5/6: route the hostnames to the tunnel")
+	for _, rule := range rules {
+		if rule.Hostname == "" {
+			continue
+		}
+		res, err := sc.route(tunnel.ID, cfapi.NewDNSRoute(rule.Hostname, false))
+		if err != nil {
+			fmt.Fprintf(p.out, "Failed to route %s: %v\n", rule.Hostname, err)
+			continue
+		}
+		fmt.Fprintln(p.out, res.SuccessSummary())
+	}
+
+	fmt.Fprintln(p.out, "\nStep 6/6: run the tunnel")
+	install, err := p.confirm("Install cloudflared as a service, to run the tunnel at startup?")
+	if err != nil {
+		return err
+	}
+	if !install {
+		fmt.Fprintf(p.out, "Run the tunnel with: cloudflared tunnel --config %s run\n", configPath)
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "--config", configPath, "service", "install")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return errors.Wrap(cmd.Run(), "failed to install the service")
+}
+
+// askServices asks for hostnames and the local services to expose on them, and ends the rules with a catch-all one.
+func askServices(p *prompter) ([]wizardRule, error) {
+	var rules []wizardRule
+	for {
+		hostname, err := p.ask("Public hostname, e.g. app.example.com (leave empty when done)", "")
+		if err != nil {
+			return nil, err
+		}
+		if hostname == "" {
+			if len(rules) == 0 {
+				fmt.Fprintln(p.out, "Expose at least one service")
+				continue
+			}
+			break
+		}
+		service, err := p.ask(fmt.Sprintf("Local service for %s", hostname), wizardDefaultService)
+		if err != nil {
+			return nil, err
+		}
+		rule := wizardRule{Hostname: hostname, Service: service}
+		if err := validateWizardRules(append(rules, rule)); err != nil {
+			fmt.Fprintln(p.out, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return append(rules, wizardRule{Service: wizardCatchAll}), nil
+}
+
+// validateWizardRules checks rules the way the configuration file will be checked.
+func validateWizardRules(rules []wizardRule) error {
+	conf := config.Configuration{TunnelID: "wizard"}
+	for _, rule := range append(rules, wizardRule{Service: wizardCatchAll}) {
+		conf.Ingress = append(conf.Ingress, config.UnvalidatedIngressRule{Hostname: rule.Hostname, Service: rule.Service})
+	}
+	_, err := ingress.ParseIngress(&conf)
+	return err
+}
+
+func writeWizardConfig(path string, conf wizardConfig) error {
+	content, err := yaml.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
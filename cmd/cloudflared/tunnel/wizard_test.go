@@ -0,0 +1,57 @@
+package tunnel
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAskServices(t *testing.T) {
+	// An empty answer at first is refused, an invalid service is asked again, and the default service is used for an
+	// empty answer
+	answers := strings.Join([]string{
+		"",
+		"app.example.com", "localhost:8080",
+		"app.example.com", "http://localhost:3000",
+		"ssh.example.com", "",
+		"",
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	rules, err := askServices(newPrompter(strings.NewReader(answers), &out))
+	require.NoError(t, err)
+	require.Equal(t, []wizardRule{
+		{Hostname: "app.example.com", Service: "http://localhost:3000"},
+		{Hostname: "ssh.example.com", Service: wizardDefaultService},
+		{Service: wizardCatchAll},
+	}, rules)
+	require.Contains(t, out.String(), "Expose at least one service")
+
+	// The answers ran out
+	_, err = askServices(newPrompter(strings.NewReader("app.example.com\n"), &out))
+	require.Error(t, err)
+}
+
+func TestWriteWizardConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cloudflared", "config.yml")
+	require.NoError(t, writeWizardConfig(path, wizardConfig{
+		Tunnel:          "f48d8918-bc23-4647-9d48-082c5b76de65",
+		CredentialsFile: "/root/.cloudflared/f48d8918-bc23-4647-9d48-082c5b76de65.json",
+		Ingress: []wizardRule{
+			{Hostname: "app.example.com", Service: "http://localhost:3000"},
+			{Service: wizardCatchAll},
+		},
+	}))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `tunnel: f48d8918-bc23-4647-9d48-082c5b76de65
+credentials-file: /root/.cloudflared/f48d8918-bc23-4647-9d48-082c5b76de65.json
+ingress:
+    - hostname: app.example.com
+      service: http://localhost:3000
+    - service: http_status:404
+`, string(content))
+}
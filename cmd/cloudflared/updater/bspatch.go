@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	bsdiffMagic      = "BSDIFF40"
+	bsdiffHeaderSize = 32
+	// maxPatchedSize bounds the size of the binary a patch may produce
+	maxPatchedSize = 1 << 30
+)
+
+var errCorruptPatch = errors.New("corrupt patch")
+
+// bspatch applies a patch made by bsdiff 4 to old, and returns the new file. The patch is a header followed by three
+// bzip2 compressed blocks: the control block, made of triples of (bytes to add from the diff block to old, bytes to
+// copy from the extra block, offset to seek in old), the diff block, and the extra block.
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < bsdiffHeaderSize || string(patch[:len(bsdiffMagic)]) != bsdiffMagic {
+		return nil, fmt.Errorf("%w: not a %s patch", errCorruptPatch, bsdiffMagic)
+	}
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || newSize > maxPatchedSize ||
+		ctrlLen > int64(len(patch)-bsdiffHeaderSize) || diffLen > int64(len(patch)-bsdiffHeaderSize)-ctrlLen {
+		return nil, fmt.Errorf("%w: invalid header", errCorruptPatch)
+	}
+	ctrlStart := int64(bsdiffHeaderSize)
+	diffStart := ctrlStart + ctrlLen
+	extraStart := diffStart + diffLen
+	ctrl := bzip2.NewReader(bytes.NewReader(patch[ctrlStart:diffStart]))
+	diff := bzip2.NewReader(bytes.NewReader(patch[diffStart:extraStart]))
+	extra := bzip2.NewReader(bytes.NewReader(patch[extraStart:]))
+
+	newFile := make([]byte, newSize)
+	var oldPos, newPos int64
+	var ctrlBuf [24]byte
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrl, ctrlBuf[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		addLen := offtin(ctrlBuf[0:8])
+		copyLen := offtin(ctrlBuf[8:16])
+		seek := offtin(ctrlBuf[16:24])
+
+		// add the diff block to old
+		if addLen < 0 || addLen > newSize-newPos {
+			return nil, fmt.Errorf("%w: invalid control block", errCorruptPatch)
+		}
+		if _, err := io.ReadFull(diff, newFile[newPos:newPos+addLen]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		for i := int64(0); i < addLen; i++ {
+			if pos := oldPos + i; pos >= 0 && pos < int64(len(old)) {
+				newFile[newPos+i] += old[pos]
+			}
+		}
+		newPos += addLen
+		oldPos += addLen
+
+		// copy the extra block
+		if copyLen < 0 || copyLen > newSize-newPos {
+			return nil, fmt.Errorf("%w: invalid control block", errCorruptPatch)
+		}
+		if _, err := io.ReadFull(extra, newFile[newPos:newPos+copyLen]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errCorruptPatch, err)
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+	return newFile, nil
+}
+
+// offtin decodes the sign-magnitude little endian integers of bsdiff.
+func offtin(b []byte) int64 {
+	magnitude := int64(binary.LittleEndian.Uint64(b) &^ (1 << 63))
+	if b[7]&0x80 != 0 {
+		return -magnitude
+	}
+	return magnitude
+}
@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPatchOld = "cloudflared 2023.1.0 binary"
+	testPatchNew = "cloudflared 2023.2.0 binary with a new feature"
+)
+
+// testPatch is a bsdiff 4 patch from testPatchOld to testPatchNew
+var testPatch, _ = base64.StdEncoding.DecodeString("QlNESUZGNDAsAAAAAAAAACkAAAAAAAAALgAAAAAAAABCWmg5MUFZJlNZvEBKJgAABeAASAgICCAAISmm0GaBfArhdyRThQkLxASiYEJaaDkxQVkmU1lN2v4/AAAAwABiAiAAMM00Emg2kycXckU4UJBN2v4/QlpoOTFBWSZTWeCEVbwAAAGRgEAAI2EWgCAAIgNBkIBppoCoCOzgdlI2b8XckU4UJDghFW8A")
+
+func TestBspatch(t *testing.T) {
+	patched, err := bspatch([]byte(testPatchOld), testPatch)
+	require.NoError(t, err)
+	require.Equal(t, testPatchNew, string(patched))
+
+	_, err = bspatch([]byte(testPatchOld), []byte("not a patch"))
+	require.ErrorIs(t, err, errCorruptPatch)
+	_, err = bspatch([]byte(testPatchOld), testPatch[:len(testPatch)/2])
+	require.ErrorIs(t, err, errCorruptPatch)
+}
+
+func TestOfftin(t *testing.T) {
+	require.Equal(t, int64(0), offtin([]byte{0, 0, 0, 0, 0, 0, 0, 0}))
+	require.Equal(t, int64(258), offtin([]byte{2, 1, 0, 0, 0, 0, 0, 0}))
+	require.Equal(t, int64(-258), offtin([]byte{2, 1, 0, 0, 0, 0, 0, 0x80}))
+}
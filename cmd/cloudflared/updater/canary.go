@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// canaryTimeout bounds how long a new binary may take to pass its canary checks
+const canaryTimeout = 2 * time.Minute
+
+// canaryCheck returns a check that runs a new binary in its canary mode, "cloudflared tunnel canary", before it's
+// installed. In that mode the binary validates the configuration at configPath, probes the origins and connects to the
+// edge without taking traffic, and exits with an error if any of that fails.
+func canaryCheck(configPath string) func(binaryPath string) error {
+	return func(binaryPath string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), canaryTimeout)
+		defer cancel()
+
+		args := []string{"tunnel"}
+		if configPath != "" {
+			args = append(args, "--config", configPath)
+		}
+		args = append(args, "canary")
+		output, err := exec.CommandContext(ctx, binaryPath, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("the new version failed its canary checks, keeping the running one: %w\n%s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+}
@@ -22,7 +22,9 @@ func StartWarningCheck(c *cli.Context) VersionWarningChecker {
 			isForced:        false,
 			intendedVersion: "",
 		}
-		checkResult, err := CheckForUpdate(options)
+		// The update isn't applied, so nothing is worth logging
+		log := zerolog.Nop()
+		checkResult, err := CheckForUpdate(options, &log)
 		if err == nil {
 			checker.warningChan <- checkResult.UserMessage()
 		}
@@ -0,0 +1,26 @@
+package updater
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var updateAvailable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Name:      "update_available",
+		Help:      "1 for the newer version of cloudflared found by the last update check, absent if cloudflared is up to date",
+	},
+	[]string{"version"},
+)
+
+func init() {
+	prometheus.MustRegister(updateAvailable)
+}
+
+// reportUpdateAvailable reports the newer version found by an update check, none if version is empty.
+func reportUpdateAvailable(version string) {
+	updateAvailable.Reset()
+	if version != "" {
+		updateAvailable.WithLabelValues(version).Set(1)
+	}
+}
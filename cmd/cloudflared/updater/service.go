@@ -27,4 +27,11 @@ const (
 
 	// ClientVersionName is the url parameter key to send the version that this cloudflared is currently running with
 	ClientVersionName = "clientVersion"
+
+	// PatchKeyName is the url parameter key to send the format of the patches that this cloudflared can apply, so the
+	// checkin API may respond with a patch from the running version instead of the full binary
+	PatchKeyName = "patch"
+
+	// PatchFormatBsdiff is the format of bsdiff 4 patches
+	PatchFormatBsdiff = "bsdiff"
 )
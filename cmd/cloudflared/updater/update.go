@@ -2,6 +2,8 @@ package updater
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,11 +30,21 @@ const (
 	StagingUpdateURL              = "https://staging-update.argotunnel.com"
 
 	LogFieldVersion = "version"
+
+	// StableChannel and BetaChannel are the release channels that cloudflared can update from
+	StableChannel = "stable"
+	BetaChannel   = "beta"
+
+	// coordinationRetryInterval is how often an update deferred by the Coordinator is retried
+	coordinationRetryInterval = 5 * time.Minute
 )
 
 var (
 	version                string
 	BuiltForPackageManager = ""
+	// UpdatePublicKey is the base64 Ed25519 public key that verifies the signature of updates. It defaults to the key of
+	// the release signing key, and can be overridden at build time. Updates are refused without it.
+	UpdatePublicKey = defaultUpdatePublicKey
 )
 
 // BinaryUpdated implements ExitCoder interface, the app will exit with status code 11
@@ -49,6 +61,17 @@ func (u *statusSuccess) ExitCode() int {
 	return 11
 }
 
+// statusRolledBack implements ExitCoder interface, the app will exit with status code 11 as for an update
+type statusRolledBack struct{}
+
+func (u *statusRolledBack) Error() string {
+	return "cloudflared has been rolled back to the previously installed version"
+}
+
+func (u *statusRolledBack) ExitCode() int {
+	return 11
+}
+
 // UpdateErr implements ExitCoder interface, the app will exit with status code 10
 type statusErr struct {
 	err error
@@ -68,6 +91,11 @@ type updateOptions struct {
 	isStaging       bool
 	isForced        bool
 	intendedVersion string
+	// coordinator, if set, is asked before applying an update
+	coordinator Coordinator
+	// canary runs the new binary in its canary mode before installing it, with the configuration at configPath
+	canary     bool
+	configPath string
 }
 
 type UpdateOutcome struct {
@@ -75,6 +103,42 @@ type UpdateOutcome struct {
 	Version     string
 	UserMessage string
 	Error       error
+	// Deferred is set when an update is available but the coordinator asked to wait
+	Deferred bool
+	// Available is the newer version found by the check, whether or not it was applied
+	Available string
+}
+
+// Coordinator coordinates the updates of the connectors of a tunnel, so that they don't all restart at once.
+type Coordinator interface {
+	// MayUpdate tells if this connector may update to version now.
+	MayUpdate(version string) (bool, error)
+}
+
+// UpdatePolicy restricts when the AutoUpdater applies updates.
+type UpdatePolicy struct {
+	// Window, if set, is the maintenance window out of which updates are deferred.
+	Window *Window
+	// MinUptime defers updates until cloudflared has been running for this long.
+	MinUptime time.Duration
+	// Coordinator, if set, is asked before applying an update.
+	Coordinator Coordinator
+}
+
+// deferral returns how long to wait from now before updating, 0 if updating now is allowed.
+func (p UpdatePolicy) deferral(now, startTime time.Time) time.Duration {
+	var wait time.Duration
+	if uptime := now.Sub(startTime); uptime < p.MinUptime {
+		wait = p.MinUptime - uptime
+	}
+	if p.Window != nil {
+		opens := p.Window.Next(now.Add(wait))
+		if opens.IsZero() {
+			return maxWindowSearch
+		}
+		wait = opens.Sub(now)
+	}
+	return wait
 }
 
 func (uo *UpdateOutcome) noUpdate() bool {
@@ -85,7 +149,7 @@ func Init(v string) {
 	version = v
 }
 
-func CheckForUpdate(options updateOptions) (CheckResult, error) {
+func CheckForUpdate(options updateOptions, log *zerolog.Logger) (CheckResult, error) {
 	cfdPath, err := os.Executable()
 	if err != nil {
 		return nil, err
@@ -100,11 +164,46 @@ func CheckForUpdate(options updateOptions) (CheckResult, error) {
 		cfdPath = encodeWindowsPath(cfdPath)
 	}
 
+	publicKey, err := updatePublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var validate func(string) error
+	if options.canary {
+		validate = canaryCheck(options.configPath)
+	}
+
 	s := NewWorkersService(version, url, cfdPath, Options{IsBeta: options.isBeta,
-		IsForced: options.isForced, RequestedVersion: options.intendedVersion})
+		IsForced: options.isForced, RequestedVersion: options.intendedVersion, PublicKey: publicKey, Validate: validate, Log: log})
 
 	return s.Check()
 }
+
+// updatePublicKey decodes UpdatePublicKey, it's nil if it isn't set.
+func updatePublicKey() (ed25519.PublicKey, error) {
+	if UpdatePublicKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(UpdatePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid update public key %q", UpdatePublicKey)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// rollback restores the cloudflared binary that the last update replaced.
+func rollback() error {
+	cfdPath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		cfdPath = encodeWindowsPath(cfdPath)
+	}
+	return Rollback(cfdPath)
+}
+
 func encodeWindowsPath(path string) string {
 	// We do this because Windows allows spaces in directories such as
 	// Program Files but does not allow these directories to be spaced in batch files.
@@ -117,15 +216,25 @@ func encodeWindowsPath(path string) string {
 
 func applyUpdate(options updateOptions, update CheckResult) UpdateOutcome {
 	if update.Version() == "" || options.updateDisabled {
-		return UpdateOutcome{UserMessage: update.UserMessage()}
+		return UpdateOutcome{UserMessage: update.UserMessage(), Available: update.Version()}
+	}
+
+	if options.coordinator != nil {
+		mayUpdate, err := options.coordinator.MayUpdate(update.Version())
+		if err != nil {
+			return UpdateOutcome{Error: err, Available: update.Version()}
+		}
+		if !mayUpdate {
+			return UpdateOutcome{Version: update.Version(), UserMessage: update.UserMessage(), Deferred: true, Available: update.Version()}
+		}
 	}
 
 	err := update.Apply()
 	if err != nil {
-		return UpdateOutcome{Error: err}
+		return UpdateOutcome{Error: err, Available: update.Version()}
 	}
 
-	return UpdateOutcome{Updated: true, Version: update.Version(), UserMessage: update.UserMessage()}
+	return UpdateOutcome{Updated: true, Version: update.Version(), UserMessage: update.UserMessage(), Available: update.Version()}
 }
 
 // Update is the handler for the update command from the command line
@@ -141,7 +250,22 @@ func Update(c *cli.Context) error {
 		return nil
 	}
 
-	isBeta := c.Bool("beta")
+	if c.Bool("rollback") {
+		if err := rollback(); err != nil {
+			return &statusErr{err}
+		}
+		log.Info().Msg("cloudflared has been rolled back to the previously installed version")
+		return &statusRolledBack{}
+	}
+
+	channel := c.String("channel")
+	if c.Bool("beta") {
+		channel = BetaChannel
+	}
+	if channel != StableChannel && channel != BetaChannel {
+		return &statusErr{fmt.Errorf("unknown release channel %q, it must be %s or %s", channel, StableChannel, BetaChannel)}
+	}
+	isBeta := channel == BetaChannel
 	if isBeta {
 		log.Info().Msg("cloudflared is set to update to the latest beta version")
 	}
@@ -156,12 +280,22 @@ func Update(c *cli.Context) error {
 		log.Info().Msg("cloudflared is set to upgrade to the latest publish version regardless of the current version")
 	}
 
+	isCanary := c.Bool("canary")
+	if isCanary {
+		if runtime.GOOS == "windows" {
+			return &statusErr{fmt.Errorf("canary updates are not supported on Windows")}
+		}
+		log.Info().Msg("cloudflared is set to check the new version in canary mode before installing it")
+	}
+
 	updateOutcome := loggedUpdate(log, updateOptions{
 		updateDisabled:  false,
 		isBeta:          isBeta,
 		isStaging:       isStaging,
 		isForced:        isForced,
 		intendedVersion: c.String("version"),
+		canary:          isCanary,
+		configPath:      config.GetConfiguration().Source(),
 	})
 	if updateOutcome.Error != nil {
 		return &statusErr{updateOutcome.Error}
@@ -177,13 +311,16 @@ func Update(c *cli.Context) error {
 
 // Checks for an update and applies it if one is available
 func loggedUpdate(log *zerolog.Logger, options updateOptions) UpdateOutcome {
-	checkResult, err := CheckForUpdate(options)
+	checkResult, err := CheckForUpdate(options, log)
 	if err != nil {
 		log.Err(err).Msg("update check failed")
 		return UpdateOutcome{Error: err}
 	}
 
 	updateOutcome := applyUpdate(options, checkResult)
+	if updateOutcome.Deferred {
+		log.Info().Str(LogFieldVersion, updateOutcome.Version).Msg("Deferring update until other connectors of the tunnel have updated")
+	}
 	if updateOutcome.Updated {
 		log.Info().Str(LogFieldVersion, updateOutcome.Version).Msg("cloudflared has been updated")
 	}
@@ -197,9 +334,11 @@ func loggedUpdate(log *zerolog.Logger, options updateOptions) UpdateOutcome {
 // AutoUpdater periodically checks for new version of cloudflared.
 type AutoUpdater struct {
 	configurable     *configurable
+	policy           UpdatePolicy
 	listeners        *gracenet.Net
 	updateConfigChan chan *configurable
 	log              *zerolog.Logger
+	startTime        time.Time
 }
 
 // AutoUpdaterConfigurable is the attributes of AutoUpdater that can be reconfigured during runtime
@@ -208,12 +347,14 @@ type configurable struct {
 	freq    time.Duration
 }
 
-func NewAutoUpdater(updateDisabled bool, freq time.Duration, listeners *gracenet.Net, log *zerolog.Logger) *AutoUpdater {
+func NewAutoUpdater(updateDisabled bool, freq time.Duration, policy UpdatePolicy, listeners *gracenet.Net, log *zerolog.Logger) *AutoUpdater {
 	return &AutoUpdater{
 		configurable:     createUpdateConfig(updateDisabled, freq, log),
+		policy:           policy,
 		listeners:        listeners,
 		updateConfigChan: make(chan *configurable),
 		log:              log,
+		startTime:        time.Now(),
 	}
 }
 
@@ -235,24 +376,41 @@ func createUpdateConfig(updateDisabled bool, freq time.Duration, log *zerolog.Lo
 func (a *AutoUpdater) Run(ctx context.Context) error {
 	ticker := time.NewTicker(a.configurable.freq)
 	for {
-		updateOutcome := loggedUpdate(a.log, updateOptions{updateDisabled: !a.configurable.enabled})
-		if updateOutcome.Updated {
-			Init(updateOutcome.Version)
-			if IsSysV() {
-				// SysV doesn't have a mechanism to keep service alive, we have to restart the process
-				a.log.Info().Msg("Restarting service managed by SysV...")
-				pid, err := a.listeners.StartProcess()
-				if err != nil {
-					a.log.Err(err).Msg("Unable to restart server automatically")
-					return &statusErr{err: err}
+		// checkC fires when the next check is due, which is sooner than the next tick if this one is deferred
+		var checkC <-chan time.Time = ticker.C
+		if wait := a.policy.deferral(time.Now(), a.startTime); a.configurable.enabled && wait > 0 {
+			a.log.Debug().Msgf("Deferring the update check by %s to respect the update policy", wait)
+			checkC = time.After(wait)
+		} else {
+			updateOutcome := loggedUpdate(a.log, updateOptions{updateDisabled: !a.configurable.enabled, coordinator: a.policy.Coordinator})
+			// a failed check leaves the last known availability
+			if updateOutcome.Error == nil || updateOutcome.Available != "" {
+				reportUpdateAvailable(updateOutcome.Available)
+			}
+			if updateOutcome.Available != "" && !updateOutcome.Updated {
+				a.log.Warn().Str(LogFieldVersion, updateOutcome.Available).Msgf("cloudflared %s is available, this connector runs %s. Update it with `cloudflared update` or let it auto-update.", updateOutcome.Available, version)
+			}
+			if updateOutcome.Updated {
+				Init(updateOutcome.Version)
+				if IsSysV() {
+					// SysV doesn't have a mechanism to keep service alive, we have to restart the process
+					a.log.Info().Msg("Restarting service managed by SysV...")
+					pid, err := a.listeners.StartProcess()
+					if err != nil {
+						a.log.Err(err).Msg("Unable to restart server automatically")
+						return &statusErr{err: err}
+					}
+					// stop old process after autoupdate. Otherwise we create a new process
+					// after each update
+					a.log.Info().Msgf("PID of the new process is %d", pid)
 				}
-				// stop old process after autoupdate. Otherwise we create a new process
-				// after each update
-				a.log.Info().Msgf("PID of the new process is %d", pid)
+				return &statusSuccess{newVersion: updateOutcome.Version}
+			} else if updateOutcome.UserMessage != "" {
+				a.log.Warn().Msg(updateOutcome.UserMessage)
+			}
+			if updateOutcome.Deferred && coordinationRetryInterval < a.configurable.freq {
+				checkC = time.After(coordinationRetryInterval)
 			}
-			return &statusSuccess{newVersion: updateOutcome.Version}
-		} else if updateOutcome.UserMessage != "" {
-			a.log.Warn().Msg(updateOutcome.UserMessage)
 		}
 
 		select {
@@ -263,7 +421,7 @@ func (a *AutoUpdater) Run(ctx context.Context) error {
 			a.configurable = newConfigurable
 			ticker = time.NewTicker(a.configurable.freq)
 			// Check if there is new version of cloudflared after receiving new AutoUpdaterConfigurable
-		case <-ticker.C:
+		case <-checkC:
 		}
 	}
 }
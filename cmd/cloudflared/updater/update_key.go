@@ -0,0 +1,7 @@
+package updater
+
+// defaultUpdatePublicKey is the base64 Ed25519 public key of the key that signs cloudflared releases. Every build
+// verifies updates with it, unless UpdatePublicKey is overridden at build time with UPDATE_PUBLIC_KEY.
+//
+// It must be set to the public key of the release signing key before the update server starts signing releases.
+const defaultUpdatePublicKey = ""
@@ -6,15 +6,17 @@ import (
 	"testing"
 
 	"github.com/facebookgo/grace/gracenet"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
 )
 
 func TestDisabledAutoUpdater(t *testing.T) {
 	listeners := &gracenet.Net{}
 	log := zerolog.Nop()
-	autoupdater := NewAutoUpdater(false, 0, listeners, &log)
+	autoupdater := NewAutoUpdater(false, 0, UpdatePolicy{}, listeners, &log)
 	ctx, cancel := context.WithCancel(context.Background())
 	errC := make(chan error)
 	go func() {
@@ -38,3 +40,29 @@ func TestCheckInWithUpdater(t *testing.T) {
 	// Assuming this runs either on a release or development version, then the Worker will never have anything to tell us.
 	assert.Empty(t, warning)
 }
+
+func TestUpdateAvailability(t *testing.T) {
+	update := &WorkersVersion{version: "2023.3.0"}
+	outcome := applyUpdate(updateOptions{updateDisabled: true}, update)
+	assert.False(t, outcome.Updated)
+	assert.Equal(t, "2023.3.0", outcome.Available)
+
+	availableVersions := func() []string {
+		families, err := prometheus.DefaultGatherer.Gather()
+		require.NoError(t, err)
+		var versions []string
+		for _, family := range families {
+			if family.GetName() != "cloudflared_update_available" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				versions = append(versions, metric.GetLabel()[0].GetValue())
+			}
+		}
+		return versions
+	}
+	reportUpdateAvailable(outcome.Available)
+	assert.Equal(t, []string{"2023.3.0"}, availableVersions())
+	reportUpdateAvailable("")
+	assert.Empty(t, availableVersions())
+}
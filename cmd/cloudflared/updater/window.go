@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxWindowSearch bounds how far ahead Window.Next looks for the window to open.
+const maxWindowSearch = 366 * 24 * time.Hour
+
+// Window is a maintenance window during which auto-update is allowed to run. It's written like the schedule of a
+// crontab entry: minute, hour, day of month, month and day of week, each a "*", a value, a range or a list of them,
+// optionally with a step. E.g. "* 2-4 * * 1-5" is from 2:00 to 4:59 on weekdays. Times are local.
+// Like in crontab, when both the day of month and the day of week are restricted, i.e. don't start with "*", a day
+// matches if either of them does: "* 2 1 * 0" is at 2:00 on the 1st of the month and on Sundays.
+type Window struct {
+	spec                              string
+	minutes, hours, days, months, dow map[int]bool
+	// eitherDay is whether a day matching the day of month or the day of week is enough
+	eitherDay bool
+}
+
+// ParseWindow parses a maintenance window.
+func ParseWindow(spec string) (*Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("maintenance window %q must have 5 fields: minute, hour, day of month, month and day of week", spec)
+	}
+	w := &Window{spec: spec}
+	var err error
+	for _, f := range []struct {
+		field    string
+		min, max int
+		values   *map[int]bool
+	}{
+		{fields[0], 0, 59, &w.minutes},
+		{fields[1], 0, 23, &w.hours},
+		{fields[2], 1, 31, &w.days},
+		{fields[3], 1, 12, &w.months},
+		{fields[4], 0, 6, &w.dow},
+	} {
+		if *f.values, err = parseWindowField(f.field, f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+		}
+	}
+	w.eitherDay = !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")
+	return w, nil
+}
+
+func parseWindowField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = rangePart
+		}
+		first, last := min, max
+		if part != "*" {
+			startPart, endPart, isRange := strings.Cut(part, "-")
+			var err error
+			if first, err = strconv.Atoi(startPart); err != nil {
+				return nil, fmt.Errorf("invalid value in %q", part)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(endPart); err != nil {
+					return nil, fmt.Errorf("invalid value in %q", part)
+				}
+			}
+		}
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := first; v <= last; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Contains tells if t is within the window.
+func (w *Window) Contains(t time.Time) bool {
+	return w.minutes[t.Minute()] && w.hours[t.Hour()] && w.months[int(t.Month())] && w.containsDay(t)
+}
+
+func (w *Window) containsDay(t time.Time) bool {
+	if w.eitherDay {
+		return w.days[t.Day()] || w.dow[int(t.Weekday())]
+	}
+	return w.days[t.Day()] && w.dow[int(t.Weekday())]
+}
+
+// Next returns when the window is next open from t, which is t itself if it's within the window. It returns the zero
+// time if the window never opens, like on the 31st of February.
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for deadline := t.Add(maxWindowSearch); next.Before(deadline); next = next.Add(time.Minute) {
+		if w.Contains(next) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (w *Window) String() string {
+	return w.spec
+}
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "0-30/10 2,3 1-15 */2 6,0", "59 23 31 12 6"} {
+		_, err := ParseWindow(spec)
+		assert.NoError(t, err, spec)
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 7", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseWindow(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	// From 2:00 to 3:59 on weekends
+	window, err := ParseWindow("* 2-3 * * 6,0")
+	require.NoError(t, err)
+
+	// Friday
+	friday := time.Date(2023, time.March, 3, 12, 30, 15, 0, time.UTC)
+	assert.False(t, window.Contains(friday))
+	assert.Equal(t, time.Date(2023, time.March, 4, 2, 0, 0, 0, time.UTC), window.Next(friday))
+
+	saturday := time.Date(2023, time.March, 4, 3, 59, 30, 0, time.UTC)
+	assert.True(t, window.Contains(saturday))
+	assert.Equal(t, saturday, window.Next(saturday))
+
+	// Sunday after the window closed, it opens again next Saturday
+	sunday := time.Date(2023, time.March, 5, 4, 0, 0, 0, time.UTC)
+	assert.False(t, window.Contains(sunday))
+	assert.Equal(t, time.Date(2023, time.March, 11, 2, 0, 0, 0, time.UTC), window.Next(sunday))
+
+	never, err := ParseWindow("* * 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, never.Next(friday).IsZero())
+}
+
+func TestWindowDayOfMonthOrDayOfWeek(t *testing.T) {
+	// At 2:00 on the 1st of the month and on Sundays
+	window, err := ParseWindow("0 2 1 * 0")
+	require.NoError(t, err)
+
+	// Wednesday the 1st
+	assert.True(t, window.Contains(time.Date(2023, time.March, 1, 2, 0, 0, 0, time.UTC)))
+	// Sunday the 5th
+	assert.True(t, window.Contains(time.Date(2023, time.March, 5, 2, 0, 0, 0, time.UTC)))
+	// Thursday the 2nd
+	assert.False(t, window.Contains(time.Date(2023, time.March, 2, 2, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2023, time.March, 5, 2, 0, 0, 0, time.UTC), window.Next(time.Date(2023, time.March, 2, 2, 0, 0, 0, time.UTC)))
+
+	// Only the day of week is restricted, so both must match
+	window, err = ParseWindow("0 2 */2 * 0")
+	require.NoError(t, err)
+	assert.False(t, window.Contains(time.Date(2023, time.March, 1, 2, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2023, time.March, 12, 2, 0, 0, 0, time.UTC)))
+	assert.True(t, window.Contains(time.Date(2023, time.March, 5, 2, 0, 0, 0, time.UTC)))
+}
+
+func TestUpdatePolicyDeferral(t *testing.T) {
+	start := time.Date(2023, time.March, 3, 1, 0, 0, 0, time.UTC)
+	now := start.Add(time.Hour)
+
+	assert.Zero(t, UpdatePolicy{}.deferral(now, start))
+	assert.Zero(t, UpdatePolicy{MinUptime: time.Hour}.deferral(now, start))
+	assert.Equal(t, time.Hour, UpdatePolicy{MinUptime: 2 * time.Hour}.deferral(now, start))
+
+	window, err := ParseWindow("* 2-3 * * *")
+	require.NoError(t, err)
+	assert.Zero(t, UpdatePolicy{Window: window}.deferral(now, start))
+	// The window is still open once the minimum uptime is reached
+	assert.Equal(t, time.Hour, UpdatePolicy{Window: window, MinUptime: 2 * time.Hour}.deferral(now, start))
+	// The window is closed once the minimum uptime is reached, so wait for the next day
+	assert.Equal(t, 24*time.Hour, UpdatePolicy{Window: window, MinUptime: 3 * time.Hour}.deferral(now, start))
+}
+
+type fakeCoordinator struct {
+	mayUpdate bool
+}
+
+func (c *fakeCoordinator) MayUpdate(string) (bool, error) {
+	return c.mayUpdate, nil
+}
+
+func TestApplyUpdateDeferredByCoordinator(t *testing.T) {
+	update := &WorkersVersion{version: "2023.3.0"}
+	outcome := applyUpdate(updateOptions{coordinator: &fakeCoordinator{}}, update)
+	assert.True(t, outcome.Deferred)
+	assert.False(t, outcome.Updated)
+	assert.Equal(t, "2023.3.0", outcome.Version)
+}
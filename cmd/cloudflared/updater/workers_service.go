@@ -1,10 +1,13 @@
 package updater
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"runtime"
+
+	"github.com/rs/zerolog"
 )
 
 // Options are the update options supported by the
@@ -17,13 +20,24 @@ type Options struct {
 
 	// RequestedVersion is the specific version to upgrade or downgrade to
 	RequestedVersion string
+
+	// PublicKey verifies the signature of the downloaded binaries. Without it, no update can be applied.
+	PublicKey ed25519.PublicKey
+
+	// Validate, if set, checks the downloaded binary before it replaces the running one
+	Validate func(binaryPath string) error
+
+	// Log, if set, reports the problems that don't fail an update, such as a patch that can't be applied
+	Log *zerolog.Logger
 }
 
 // VersionResponse is the JSON response from the Workers API endpoint
 type VersionResponse struct {
 	URL          string `json:"url"`
+	PatchURL     string `json:"patchUrl"`
 	Version      string `json:"version"`
 	Checksum     string `json:"checksum"`
+	Signature    string `json:"signature"`
 	IsCompressed bool   `json:"compressed"`
 	UserMessage  string `json:"userMessage"`
 	ShouldUpdate bool   `json:"shouldUpdate"`
@@ -41,6 +55,10 @@ type WorkersService struct {
 
 // NewWorkersService creates a new updater Service object.
 func NewWorkersService(currentVersion, url, targetPath string, opts Options) Service {
+	if opts.Log == nil {
+		log := zerolog.Nop()
+		opts.Log = &log
+	}
 	return &WorkersService{
 		currentVersion: currentVersion,
 		url:            url,
@@ -60,6 +78,7 @@ func (s *WorkersService) Check() (CheckResult, error) {
 	q.Add(OSKeyName, runtime.GOOS)
 	q.Add(ArchitectureKeyName, runtime.GOARCH)
 	q.Add(ClientVersionName, s.currentVersion)
+	q.Add(PatchKeyName, PatchFormatBsdiff)
 
 	if s.opts.IsBeta {
 		q.Add(BetaKeyName, "true")
@@ -90,5 +109,5 @@ func (s *WorkersService) Check() (CheckResult, error) {
 		versionToUpdate = v.Version
 	}
 
-	return NewWorkersVersion(v.URL, versionToUpdate, v.Checksum, s.targetPath, v.UserMessage, v.IsCompressed), nil
+	return NewWorkersVersion(v.URL, v.PatchURL, versionToUpdate, v.Checksum, v.Signature, s.targetPath, v.UserMessage, v.IsCompressed, s.opts.PublicKey, s.opts.Validate, s.opts.Log), nil
 }
@@ -7,7 +7,9 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,11 +24,24 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
 var testFilePath = filepath.Join(os.TempDir(), "test")
 
+// testPrivateKey signs the binaries served by the test server
+var (
+	testPrivateKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	testPublicKey  = testPrivateKey.Public().(ed25519.PublicKey)
+)
+
+// sign returns the signature of a binary with content
+func sign(content string) string {
+	digest := sha256.Sum256([]byte(content))
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(testPrivateKey, digest[:]))
+}
+
 func respondWithJSON(w http.ResponseWriter, v interface{}, status int) {
 	data, _ := json.Marshal(v)
 
@@ -79,8 +94,13 @@ func updateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	shouldUpdate := requestedVersion != "" || IsNewerVersion(query.Get(ClientVersionName), version)
 
+	signature := sign(version)
+	if query.Get("unsigned") == "true" {
+		signature = ""
+	}
+
 	v := VersionResponse{
-		URL: url, Version: version, Checksum: checksum, UserMessage: userMessage, ShouldUpdate: shouldUpdate,
+		URL: url, Version: version, Checksum: checksum, Signature: signature, UserMessage: userMessage, ShouldUpdate: shouldUpdate,
 	}
 	respondWithJSON(w, v, http.StatusOK)
 }
@@ -93,7 +113,7 @@ func gzipUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	checksum := fmt.Sprintf("%x", h.Sum(nil))
 
 	url := fmt.Sprintf("http://%s/gzip-download.tgz", r.Host)
-	v := VersionResponse{URL: url, Version: version, Checksum: checksum, ShouldUpdate: true}
+	v := VersionResponse{URL: url, Version: version, Checksum: checksum, Signature: sign(version), ShouldUpdate: true}
 	respondWithJSON(w, v, http.StatusOK)
 }
 
@@ -130,6 +150,24 @@ func betaHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithData(w, []byte(mostRecentBetaVersion), http.StatusOK)
 }
 
+// patchedUpdateHandler offers testPatchNew, along with a patch from testPatchOld
+func patchedUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	host := fmt.Sprintf("http://%s", r.Host)
+	patchURL := ""
+	if r.URL.Query().Get(PatchKeyName) == PatchFormatBsdiff {
+		patchURL = host + "/patch"
+	}
+	v := VersionResponse{
+		URL:          host + "/patched-download",
+		PatchURL:     patchURL,
+		Version:      "2023.2.0",
+		Checksum:     fmt.Sprintf("%x", sha256.Sum256([]byte(testPatchNew))),
+		Signature:    sign(testPatchNew),
+		ShouldUpdate: true,
+	}
+	respondWithJSON(w, v, http.StatusOK)
+}
+
 func failureHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, VersionResponse{Error: "unsupported os and architecture"}, http.StatusBadRequest)
 }
@@ -200,6 +238,7 @@ func createServer() *httptest.Server {
 	mux.HandleFunc("/fail", failureHandler)
 	mux.HandleFunc("/compressed", gzipUpdateHandler)
 	mux.HandleFunc("/gzip-download.tgz", compressedDownloadHandler)
+	mux.HandleFunc("/patched", patchedUpdateHandler)
 	return httptest.NewServer(mux)
 }
 
@@ -216,9 +255,10 @@ func TestUpdateService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 	log.Println("server url: ", ts.URL)
 
-	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{})
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, v.Version(), mostRecentVersion)
@@ -230,14 +270,75 @@ func TestUpdateService(t *testing.T) {
 	require.Equal(t, string(dat), mostRecentVersion)
 }
 
+func TestRollback(t *testing.T) {
+	ts := createServer()
+	defer ts.Close()
+
+	createTestFile(t, testFilePath)
+	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
+
+	require.Error(t, Rollback(testFilePath))
+
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
+	v, err := s.Check()
+	require.NoError(t, err)
+	require.NoError(t, v.Apply())
+
+	require.NoError(t, Rollback(testFilePath))
+	dat, err := ioutil.ReadFile(testFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "2020.08.04", string(dat))
+
+	// Rolling back again restores the update
+	require.NoError(t, Rollback(testFilePath))
+	dat, err = ioutil.ReadFile(testFilePath)
+	require.NoError(t, err)
+	require.Equal(t, mostRecentVersion, string(dat))
+}
+
+func TestUpdateServiceRefusesUnverifiedUpdates(t *testing.T) {
+	ts := createServer()
+	defer ts.Close()
+
+	createTestFile(t, testFilePath)
+	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
+
+	// Without a key to verify it
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{})
+	v, err := s.Check()
+	require.NoError(t, err)
+	require.Error(t, v.Apply())
+
+	// Unsigned
+	s = NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater?unsigned=true", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
+	v, err = s.Check()
+	require.NoError(t, err)
+	require.Error(t, v.Apply())
+
+	// Signed by another key
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s = NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: otherPublicKey})
+	v, err = s.Check()
+	require.NoError(t, err)
+	require.Error(t, v.Apply())
+
+	dat, err := ioutil.ReadFile(testFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "2020.08.04", string(dat))
+}
+
 func TestBetaUpdateService(t *testing.T) {
 	ts := createServer()
 	defer ts.Close()
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{IsBeta: true})
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{IsBeta: true, PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, v.Version(), mostRecentBetaVersion)
@@ -255,8 +356,9 @@ func TestFailUpdateService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/fail", ts.URL), testFilePath, Options{})
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/fail", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.Error(t, err)
 	require.Nil(t, v)
@@ -268,8 +370,9 @@ func TestNoUpdateService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService(mostRecentVersion, fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{})
+	s := NewWorkersService(mostRecentVersion, fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.NotNil(t, v)
@@ -282,8 +385,9 @@ func TestForcedUpdateService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService("2020.8.5", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{IsForced: true})
+	s := NewWorkersService("2020.8.5", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{IsForced: true, PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, v.Version(), mostRecentVersion)
@@ -301,9 +405,10 @@ func TestUpdateSpecificVersionService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 	reqVersion := "2020.9.1"
 
-	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{RequestedVersion: reqVersion})
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{RequestedVersion: reqVersion, PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, reqVersion, v.Version())
@@ -321,8 +426,9 @@ func TestCompressedUpdateService(t *testing.T) {
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/compressed", ts.URL), testFilePath, Options{})
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/compressed", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, "2020.09.02", v.Version())
@@ -334,16 +440,116 @@ func TestCompressedUpdateService(t *testing.T) {
 	require.Equal(t, "2020.09.02", string(dat))
 }
 
+func TestPatchedUpdateService(t *testing.T) {
+	ts := createServer()
+	defer ts.Close()
+	var patchDownloads, fullDownloads int
+	mux := ts.Config.Handler.(*http.ServeMux)
+	mux.HandleFunc("/patch", func(w http.ResponseWriter, r *http.Request) {
+		patchDownloads++
+		respondWithData(w, testPatch, http.StatusOK)
+	})
+	mux.HandleFunc("/patched-download", func(w http.ResponseWriter, r *http.Request) {
+		fullDownloads++
+		respondWithData(w, []byte(testPatchNew), http.StatusOK)
+	})
+	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
+
+	for _, test := range []struct {
+		binary        string
+		fullDownloads int
+	}{
+		{binary: testPatchOld},
+		// The patch doesn't apply to a modified binary, so it's downloaded in full
+		{binary: strings.ToUpper(testPatchOld), fullDownloads: 1},
+	} {
+		patchDownloads, fullDownloads = 0, 0
+		require.NoError(t, os.WriteFile(testFilePath, []byte(test.binary), 0755))
+
+		var logs bytes.Buffer
+		logger := zerolog.New(&logs)
+		s := NewWorkersService("2023.1.0", fmt.Sprintf("%s/patched", ts.URL), testFilePath, Options{PublicKey: testPublicKey, Log: &logger})
+		v, err := s.Check()
+		require.NoError(t, err)
+		require.NoError(t, v.Apply())
+
+		dat, err := ioutil.ReadFile(testFilePath)
+		require.NoError(t, err)
+		require.Equal(t, testPatchNew, string(dat))
+		require.Equal(t, 1, patchDownloads)
+		require.Equal(t, test.fullDownloads, fullDownloads)
+		if test.fullDownloads > 0 {
+			require.Contains(t, logs.String(), `"level":"warn"`)
+			require.Contains(t, logs.String(), "Failed to update from a patch")
+		} else {
+			require.Empty(t, logs.String())
+		}
+	}
+}
+
 func TestUpdateWhenRunningKnownBuggyVersion(t *testing.T) {
 	ts := createServer()
 	defer ts.Close()
 
 	createTestFile(t, testFilePath)
 	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
 
-	s := NewWorkersService(knownBuggyVersion, fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{})
+	s := NewWorkersService(knownBuggyVersion, fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey})
 	v, err := s.Check()
 	require.NoError(t, err)
 	require.Equal(t, v.Version(), mostRecentVersion)
 	require.Equal(t, v.UserMessage(), expectedUserMsg)
 }
+
+func TestCanaryUpdateService(t *testing.T) {
+	ts := createServer()
+	defer ts.Close()
+
+	createTestFile(t, testFilePath)
+	defer os.Remove(testFilePath)
+	defer os.Remove(testFilePath + previousBinarySuffix)
+
+	// A binary that fails its checks isn't installed
+	var checked string
+	failing := func(binaryPath string) error {
+		checked = binaryPath
+		return errors.New("canary failed")
+	}
+	s := NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey, Validate: failing})
+	v, err := s.Check()
+	require.NoError(t, err)
+	require.Error(t, v.Apply())
+	require.Equal(t, testFilePath+".new", checked)
+	require.NoFileExists(t, checked)
+	dat, err := ioutil.ReadFile(testFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "2020.08.04", string(dat))
+
+	passing := func(string) error { return nil }
+	s = NewWorkersService("2020.8.2", fmt.Sprintf("%s/updater", ts.URL), testFilePath, Options{PublicKey: testPublicKey, Validate: passing})
+	v, err = s.Check()
+	require.NoError(t, err)
+	require.NoError(t, v.Apply())
+	dat, err = ioutil.ReadFile(testFilePath)
+	require.NoError(t, err)
+	require.Equal(t, mostRecentVersion, string(dat))
+}
+
+func TestCanaryCheck(t *testing.T) {
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	binaryPath := filepath.Join(dir, "cloudflared")
+	// The binary records its arguments, and fails if there is no configuration
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n[ \"$2\" = --config ] || { echo no configuration; exit 1; }\n", argsPath)
+	require.NoError(t, os.WriteFile(binaryPath, []byte(script), 0755))
+
+	require.NoError(t, canaryCheck("/etc/cloudflared/config.yml")(binaryPath))
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	require.Equal(t, "tunnel --config /etc/cloudflared/config.yml canary\n", string(args))
+
+	err = canaryCheck("")(binaryPath)
+	require.ErrorContains(t, err, "no configuration")
+}
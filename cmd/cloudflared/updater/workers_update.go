@@ -3,7 +3,9 @@ package updater
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -16,23 +18,27 @@ import (
 	"strings"
 	"text/template"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
 const (
 	clientTimeout = time.Second * 60
 	// stop the service
-	// rename cloudflared.exe to cloudflared.exe.old
+	// delete the cloudflared.exe.previous kept by the last update
+	// rename cloudflared.exe to cloudflared.exe.previous, to be able to roll back to it
 	// rename cloudflared.exe.new to cloudflared.exe
-	// delete cloudflared.exe.old
 	// start the service
 	// exit with code 0 if we've reached this point indicating success.
 	windowsUpdateCommandTemplate = `sc stop cloudflared >nul 2>&1
+del "{{.OldPath}}" >nul 2>&1
 rename "{{.TargetPath}}" {{.OldName}}
 rename "{{.NewPath}}" {{.BinaryName}}
-del "{{.OldPath}}"
 sc start cloudflared >nul 2>&1
 exit /b 0`
 	batchFileName = "cfd_update.bat"
+	// previousBinarySuffix is appended to the path of cloudflared to name the binary it replaced
+	previousBinarySuffix = ".previous"
 )
 
 // Prepare some data to insert into the template.
@@ -49,28 +55,43 @@ type batchData struct {
 // It contains everything needed to perform a version upgrade
 type WorkersVersion struct {
 	downloadURL  string
+	patchURL     string
 	checksum     string
+	signature    string
 	version      string
 	targetPath   string
 	isCompressed bool
 	userMessage  string
+	publicKey    ed25519.PublicKey
+	validate     func(binaryPath string) error
+	log          *zerolog.Logger
 }
 
 // NewWorkersVersion creates a new Version object. This is normally created by a WorkersService JSON checkin response
 // url is where to download the file
+// patchURL, if set, is where to download a bsdiff patch from the running binary to the file, which is tried first
 // version is the version of this update
 // checksum is the expected checksum of the downloaded file
+// signature is the base64 Ed25519 signature of the SHA-256 digest of the downloaded file
 // target path is where the file should be replace. Normally this the running cloudflared's path
 // userMessage is a possible message to convey back to the user after having checked in with the Updater Service
 // isCompressed tells whether the asset to update cloudflared is compressed or not
-func NewWorkersVersion(url, version, checksum, targetPath, userMessage string, isCompressed bool) CheckResult {
+// publicKey verifies the signature, the update is refused if it's missing
+// validate, if set, checks the verified binary before it replaces the running one, the update is refused if it fails
+// log reports the patches that fail to apply, before the file is downloaded in full
+func NewWorkersVersion(url, patchURL, version, checksum, signature, targetPath, userMessage string, isCompressed bool, publicKey ed25519.PublicKey, validate func(binaryPath string) error, log *zerolog.Logger) CheckResult {
 	return &WorkersVersion{
 		downloadURL:  url,
+		patchURL:     patchURL,
 		version:      version,
 		checksum:     checksum,
+		signature:    signature,
 		targetPath:   targetPath,
 		isCompressed: isCompressed,
 		userMessage:  userMessage,
+		publicKey:    publicKey,
+		validate:     validate,
+		log:          log,
 	}
 }
 
@@ -78,20 +99,77 @@ func NewWorkersVersion(url, version, checksum, targetPath, userMessage string, i
 // This includes signature and checksum validation,
 // replacing the binary, etc
 func (v *WorkersVersion) Apply() error {
+	// fail before downloading if the update can't be verified
+	if len(v.publicKey) == 0 {
+		return errors.New("this build of cloudflared has no key to verify updates, refusing to apply an unverified update")
+	}
+	if v.signature == "" {
+		return errors.New("the update isn't signed, refusing to apply it")
+	}
+
 	newFilePath := fmt.Sprintf("%s.new", v.targetPath)
 	os.Remove(newFilePath) //remove any failed updates before download
 
-	// download the file
-	if err := download(v.downloadURL, newFilePath, v.isCompressed); err != nil {
+	// download the file, from the patch if there's one that applies to the running binary, in full otherwise
+	patched := false
+	if v.patchURL != "" {
+		if err := v.downloadPatched(newFilePath); err != nil {
+			v.log.Warn().Err(err).Str(LogFieldVersion, v.version).Msg("Failed to update from a patch, downloading the full binary instead")
+		} else {
+			patched = true
+		}
+	}
+	if !patched {
+		os.Remove(newFilePath)
+		if err := download(v.downloadURL, newFilePath, v.isCompressed); err != nil {
+			return err
+		}
+	}
+
+	// check that the file is what is expected, and that it was published by Cloudflare
+	digest, err := fileDigest(newFilePath)
+	if err != nil {
+		return err
+	}
+	if err := isValidChecksum(v.checksum, digest); err != nil {
+		return err
+	}
+	if err := isValidSignature(v.publicKey, v.signature, digest); err != nil {
 		return err
 	}
 
-	// check that the file is what is expected
-	if err := isValidChecksum(v.checksum, newFilePath); err != nil {
+	// only promote the new binary if it passes its checks
+	if v.validate != nil {
+		if err := v.validate(newFilePath); err != nil {
+			os.Remove(newFilePath)
+			return err
+		}
+	}
+
+	return replaceBinary(v.targetPath, newFilePath)
+}
+
+// Rollback restores the binary that the last update replaced. The replaced binary is kept in turn, so rolling back
+// again restores it.
+func Rollback(targetPath string) error {
+	previousFilePath := targetPath + previousBinarySuffix
+	if _, err := os.Stat(previousFilePath); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("there is no previously installed version to roll back to")
+		}
 		return err
 	}
+	newFilePath := fmt.Sprintf("%s.new", targetPath)
+	os.Remove(newFilePath)
+	if err := os.Rename(previousFilePath, newFilePath); err != nil {
+		return err
+	}
+	return replaceBinary(targetPath, newFilePath)
+}
 
-	oldFilePath := fmt.Sprintf("%s.old", v.targetPath)
+// replaceBinary moves the binary at newFilePath to targetPath, keeping the binary it replaces next to it.
+func replaceBinary(targetPath, newFilePath string) error {
+	oldFilePath := targetPath + previousBinarySuffix
 	// Windows requires more effort to self update, especially when it is running as a service:
 	// you have to stop the service (if running as one) in order to move/rename the binary
 	// but now the binary isn't running though, so an external process
@@ -99,25 +177,25 @@ func (v *WorkersVersion) Apply() error {
 	// the easiest way to do this is with a batch file (or with a DLL, but that gets ugly for a cross compiled binary like cloudflared)
 	// a batch file isn't ideal, but it is the simplest path forward for the constraints Windows creates
 	if runtime.GOOS == "windows" {
-		if err := writeBatchFile(v.targetPath, newFilePath, oldFilePath); err != nil {
+		if err := writeBatchFile(targetPath, newFilePath, oldFilePath); err != nil {
 			return err
 		}
-		rootDir := filepath.Dir(v.targetPath)
+		rootDir := filepath.Dir(targetPath)
 		batchPath := filepath.Join(rootDir, batchFileName)
 		return runWindowsBatch(batchPath)
 	}
 
-	// now move the current file out, move the new file in and delete the old file
-	if err := os.Rename(v.targetPath, oldFilePath); err != nil {
+	// now move the current file out and the new file in, the current file is kept to be able to roll back to it
+	os.Remove(oldFilePath)
+	if err := os.Rename(targetPath, oldFilePath); err != nil {
 		return err
 	}
 
-	if err := os.Rename(newFilePath, v.targetPath); err != nil {
+	if err := os.Rename(newFilePath, targetPath); err != nil {
 		//attempt rollback
-		os.Rename(oldFilePath, v.targetPath)
+		os.Rename(oldFilePath, targetPath)
 		return err
 	}
-	os.Remove(oldFilePath)
 
 	return nil
 }
@@ -176,6 +254,39 @@ func download(url, filepath string, isCompressed bool) error {
 	return err
 }
 
+// downloadPatched builds the new binary at filePath by applying the patch to the running binary. It fails if the
+// result isn't the expected file, e.g. because the running binary was modified.
+func (v *WorkersVersion) downloadPatched(filePath string) error {
+	client := &http.Client{
+		Timeout: clientTimeout,
+	}
+	resp, err := client.Get(v.patchURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download the patch: %s", resp.Status)
+	}
+	patch, err := io.ReadAll(io.LimitReader(resp.Body, maxPatchedSize))
+	if err != nil {
+		return err
+	}
+	old, err := os.ReadFile(v.targetPath)
+	if err != nil {
+		return err
+	}
+	patched, err := bspatch(old, patch)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(patched)
+	if err := isValidChecksum(v.checksum, digest[:]); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, patched, 0755)
+}
+
 // isCompressedFile is a really simple file extension check to see if this is a macos tar and gzipped
 func isCompressedFile(urlstring string) bool {
 	if strings.HasSuffix(urlstring, ".tgz") {
@@ -189,20 +300,24 @@ func isCompressedFile(urlstring string) bool {
 	return strings.HasSuffix(u.Path, ".tgz")
 }
 
-// checks if the checksum in the json response matches the checksum of the file download
-func isValidChecksum(checksum, filePath string) error {
+// fileDigest returns the SHA-256 digest of the file
+func fileDigest(filePath string) ([]byte, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return err
+		return nil, err
 	}
+	return h.Sum(nil), nil
+}
 
-	hash := fmt.Sprintf("%x", h.Sum(nil))
+// checks if the checksum in the json response matches the checksum of the file download
+func isValidChecksum(checksum string, digest []byte) error {
+	hash := fmt.Sprintf("%x", digest)
 
 	if checksum != hash {
 		return errors.New("checksum validation failed")
@@ -210,6 +325,18 @@ func isValidChecksum(checksum, filePath string) error {
 	return nil
 }
 
+// checks if the signature in the json response is the signature of the file download by publicKey
+func isValidSignature(publicKey ed25519.PublicKey, signature string, digest []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(publicKey, digest, sig) {
+		return errors.New("signature validation failed")
+	}
+	return nil
+}
+
 // writeBatchFile writes a batch file out to disk
 // see the dicussion on why it has to be done this way
 func writeBatchFile(targetPath string, newPath string, oldPath string) error {
@@ -41,6 +41,9 @@ var (
 const (
 	// BastionFlag is to enable bastion, or jump host, operation
 	BastionFlag = "bastion"
+
+	// redactedValue replaces the secrets of the configuration when it is shown
+	redactedValue = "REDACTED"
 )
 
 // DefaultConfigDirectory returns the default directory of the config file
@@ -179,6 +182,132 @@ type UnvalidatedIngressRule struct {
 	Path          string              `json:"path,omitempty"`
 	Service       string              `json:"service,omitempty"`
 	OriginRequest OriginRequestConfig `yaml:"originRequest" json:"originRequest"`
+	Filters       []FilterConfig      `yaml:"filters,omitempty" json:"filters,omitempty"`
+
+	// Methods optionally restricts the rule to requests with one of these methods, e.g. POST.
+	Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+	// Headers optionally restricts the rule to requests with all of these headers, each with a value that matches the
+	// regex it maps to, e.g. X-Env: ^staging$.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Origins are set instead of Service when the service of the rule is a list of origins.
+	Origins []WeightedOrigin `yaml:"-" json:"-"`
+}
+
+// WeightedOrigin is one of the origins that an ingress rule balances its requests over, when its service is a list:
+//
+//	service:
+//	  - url: http://10.0.0.1:8080
+//	    weight: 2
+//	  - url: http://10.0.0.2:8080
+//	  - url: http://10.0.0.3:8080
+//	    backup: true
+type WeightedOrigin struct {
+	URL string `yaml:"url" json:"url"`
+	// Weight is the share of the requests the origin gets relative to the other origins, 1 by default.
+	Weight uint16 `yaml:"weight,omitempty" json:"weight,omitempty"`
+	// Backup origins only get requests when none of the other origins is healthy.
+	Backup bool `yaml:"backup,omitempty" json:"backup,omitempty"`
+}
+
+// UnmarshalYAML accepts either a URL or a list of origins as the service of the rule.
+func (r *UnvalidatedIngressRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain UnvalidatedIngressRule
+	var fields map[string]yaml.Node
+	if err := unmarshal(&fields); err != nil {
+		return unmarshal((*plain)(r))
+	}
+	service, ok := fields["service"]
+	if !ok || service.Kind != yaml.SequenceNode {
+		return unmarshal((*plain)(r))
+	}
+
+	var origins []WeightedOrigin
+	if err := service.Decode(&origins); err != nil {
+		return err
+	}
+	// The rest of the rule is decoded without the list, which doesn't fit in Service
+	rest := yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for key, value := range fields {
+		if key == "service" {
+			continue
+		}
+		value := value
+		rest.Content = append(rest.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &value)
+	}
+	if err := rest.Decode((*plain)(r)); err != nil {
+		return err
+	}
+	r.Origins = origins
+	return nil
+}
+
+// MarshalYAML writes the origins of the rule as its service when it has some.
+func (r UnvalidatedIngressRule) MarshalYAML() (interface{}, error) {
+	type plain UnvalidatedIngressRule
+	if len(r.Origins) == 0 {
+		return plain(r), nil
+	}
+	var node, origins yaml.Node
+	if err := node.Encode(plain(r)); err != nil {
+		return nil, err
+	}
+	if err := origins.Encode(r.Origins); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "service" {
+			node.Content[i+1] = &origins
+		}
+	}
+	return &node, nil
+}
+
+// UnmarshalJSON accepts either a URL or a list of origins as the service of the rule.
+func (r *UnvalidatedIngressRule) UnmarshalJSON(data []byte) error {
+	type plain UnvalidatedIngressRule
+	rule := struct {
+		*plain
+		Service json.RawMessage `json:"service,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	if len(rule.Service) > 0 && rule.Service[0] == '[' {
+		return json.Unmarshal(rule.Service, &r.Origins)
+	}
+	if len(rule.Service) > 0 {
+		return json.Unmarshal(rule.Service, &r.Service)
+	}
+	return nil
+}
+
+// MarshalJSON writes the origins of the rule as its service when it has some.
+func (r UnvalidatedIngressRule) MarshalJSON() ([]byte, error) {
+	type plain UnvalidatedIngressRule
+	var service interface{}
+	if len(r.Origins) > 0 {
+		service = r.Origins
+	} else if r.Service != "" {
+		service = r.Service
+	}
+	return json.Marshal(struct {
+		plain
+		Service interface{} `json:"service,omitempty"`
+	}{plain: plain(r), Service: service})
+}
+
+// FilterConfig decides what happens to the requests matching an ingress rule that also match Expression. Filters are
+// evaluated in order and the first one that matches applies.
+type FilterConfig struct {
+	// Expression is a CEL expression over the request, see the ingress/filter package for the available attributes.
+	Expression string `yaml:"expression" json:"expression"`
+	// Action is one of allow, deny or route.
+	Action string `yaml:"action" json:"action"`
+	// Status is the HTTP status code to respond with when the action is deny. Defaults to 403.
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	// Service is the HTTP origin that handles the request when the action is route.
+	Service string `yaml:"service,omitempty" json:"service,omitempty"`
 }
 
 // OriginRequestConfig is a set of optional fields that users may set to
@@ -227,8 +356,207 @@ type OriginRequestConfig struct {
 	IPRules []IngressIPRule `yaml:"ipRules" json:"ipRules,omitempty"`
 	// Attempt to connect to origin with HTTP/2
 	Http2Origin *bool `yaml:"http2Origin" json:"http2Origin,omitempty"`
+	// Speak HTTP/2 with prior knowledge (h2c) to cleartext origins
+	H2COrigin *bool `yaml:"h2cOrigin" json:"h2cOrigin,omitempty"`
 	// Access holds all access related configs
 	Access *AccessConfig `yaml:"access" json:"access,omitempty"`
+	// Plugins are middleware plugins that run, in order, on requests matching the rule
+	Plugins []PluginConfig `yaml:"plugins,omitempty" json:"plugins,omitempty"`
+	// AuthService is called to authorize requests before they are proxied
+	AuthService *AuthServiceConfig `yaml:"authService" json:"authService,omitempty"`
+	// Adds headers identifying the connector, connection and colo that proxied the request
+	ConnectorHeaders *bool `yaml:"connectorHeaders" json:"connectorHeaders,omitempty"`
+	// ResponseRewrite rewrites references to the origin's address in responses to the public hostname
+	ResponseRewrite *ResponseRewriteConfig `yaml:"responseRewrite" json:"responseRewrite,omitempty"`
+	// HTTPHeaders adds and removes headers of the requests sent to the origin
+	HTTPHeaders *HTTPHeadersConfig `yaml:"httpHeaders" json:"httpHeaders,omitempty"`
+	// SetResponseHeaders are headers set on responses, replacing the values the origin gave them
+	SetResponseHeaders map[string]string `yaml:"setResponseHeaders,omitempty" json:"setResponseHeaders,omitempty"`
+	// RemoveResponseHeaders are headers removed from responses
+	RemoveResponseHeaders []string `yaml:"removeResponseHeaders,omitempty" json:"removeResponseHeaders,omitempty"`
+	// Mirror sends a copy of some requests to a shadow origin
+	Mirror *MirrorConfig `yaml:"mirror" json:"mirror,omitempty"`
+	// Socket tunes the TCP sockets of connections to the origin
+	Socket *SocketConfig `yaml:"socket" json:"socket,omitempty"`
+	// StreamingMode is "passthrough" to proxy bodies without buffering them, flushing every chunk of the response.
+	StreamingMode *string `yaml:"streamingMode" json:"streamingMode,omitempty"`
+	// SSEHeartbeatInterval is how long a server-sent events stream may stay silent before cloudflared sends a comment
+	// line to keep it alive
+	SSEHeartbeatInterval *CustomDuration `yaml:"sseHeartbeatInterval" json:"sseHeartbeatInterval,omitempty"`
+	// WebsocketPingInterval is how often cloudflared pings both ends of a websocket to keep it alive
+	WebsocketPingInterval *CustomDuration `yaml:"websocketPingInterval" json:"websocketPingInterval,omitempty"`
+	// WebsocketIdleTimeout is how long a websocket may go without a message before cloudflared closes it
+	WebsocketIdleTimeout *CustomDuration `yaml:"websocketIdleTimeout" json:"websocketIdleTimeout,omitempty"`
+	// UDPIdleTimeout is how long a UDP session to a udp:// service may go without a datagram before cloudflared ends it
+	UDPIdleTimeout *CustomDuration `yaml:"udpIdleTimeout" json:"udpIdleTimeout,omitempty"`
+	// RequestTimeout is how long a request to the origin, including its response body, may take
+	RequestTimeout *CustomDuration `yaml:"requestTimeout" json:"requestTimeout,omitempty"`
+	// MaxRequestBodySize is the largest request body, in bytes, that is proxied to the origin
+	MaxRequestBodySize *int64 `yaml:"maxRequestBodySize" json:"maxRequestBodySize,omitempty"`
+	// MaxResponseBodySize is the largest response body, in bytes, that is proxied from the origin
+	MaxResponseBodySize *int64 `yaml:"maxResponseBodySize" json:"maxResponseBodySize,omitempty"`
+	// Retries is how many times a failed idempotent request without a body is sent to the origin again
+	Retries *uint `yaml:"retries" json:"retries,omitempty"`
+	// RetryOn are the failures that are retried: connect-failure and 5xx status codes
+	RetryOn []string `yaml:"retryOn,omitempty" json:"retryOn,omitempty"`
+	// RetryTimeout caps the time spent on all the attempts of a request
+	RetryTimeout *CustomDuration `yaml:"retryTimeout" json:"retryTimeout,omitempty"`
+	// TLS restricts the TLS connections to the origin
+	TLS *TLSPolicyConfig `yaml:"tls" json:"tls,omitempty"`
+	// Discovery configures the registry that consul:// and etcd:// services are resolved from
+	Discovery *DiscoveryConfig `yaml:"discovery" json:"discovery,omitempty"`
+	// FileServer configures how file:// services serve the files of their directory
+	FileServer *FileServerConfig `yaml:"fileServer" json:"fileServer,omitempty"`
+	// HealthCheck actively checks that the origins are healthy
+	HealthCheck *HealthCheckConfig `yaml:"healthCheck" json:"healthCheck,omitempty"`
+	// Cache caches the responses of the origin to GET and HEAD requests
+	Cache *CacheConfig `yaml:"cache" json:"cache,omitempty"`
+	// RateLimit limits the rate of requests of every client IP
+	RateLimit *RateLimitConfig `yaml:"rateLimit" json:"rateLimit,omitempty"`
+}
+
+// RateLimitConfig configures the token bucket that limits the requests of an ingress rule from each client IP, as
+// reported by the edge in the Cf-Connecting-Ip header.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained rate of requests allowed from a client IP.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+	// Burst is how many requests a client IP can make at once, RequestsPerSecond rounded up by default.
+	Burst int `yaml:"burst" json:"burst,omitempty"`
+}
+
+// CacheConfig configures the cache of the responses of the origin of an ingress rule, which honors their Cache-Control
+// and validators like a shared HTTP cache.
+type CacheConfig struct {
+	// MaxSize is the total size of the cached responses in bytes, 64 MiB by default.
+	MaxSize int64 `yaml:"maxSize" json:"maxSize,omitempty"`
+	// MaxObjectSize is the size of the largest response body that is cached in bytes, 1 MiB by default.
+	MaxObjectSize int64 `yaml:"maxObjectSize" json:"maxObjectSize,omitempty"`
+	// Directory keeps the cached response bodies in files of this directory rather than in memory.
+	Directory string `yaml:"directory" json:"directory,omitempty"`
+}
+
+// HealthCheckConfig configures the requests that check the origins of an ingress rule, and the response to the
+// requests of the rule while none of its origins is healthy.
+type HealthCheckConfig struct {
+	// Path requested from the origins, / by default.
+	Path string `yaml:"path" json:"path,omitempty"`
+	// Interval between the checks of an origin, 10s by default.
+	Interval *CustomDuration `yaml:"interval" json:"interval,omitempty"`
+	// Timeout of a check, 5s by default.
+	Timeout *CustomDuration `yaml:"timeout" json:"timeout,omitempty"`
+	// ExpectedStatus is the status code of healthy origins. Any 2xx or 3xx status by default.
+	ExpectedStatus int `yaml:"expectedStatus" json:"expectedStatus,omitempty"`
+	// ErrorPage is a file served with a 503 status while no origin is healthy, instead of a plain text message.
+	ErrorPage string `yaml:"errorPage" json:"errorPage,omitempty"`
+}
+
+// FileServerConfig configures how a file:// service serves the files of its directory.
+type FileServerConfig struct {
+	// DirectoryListing lists the files of directories that don't have an index file, instead of responding with 404.
+	DirectoryListing bool `yaml:"directoryListing" json:"directoryListing,omitempty"`
+	// IndexFiles are the files served for a directory, the first one that exists. Defaults to index.html.
+	IndexFiles []string `yaml:"indexFiles" json:"indexFiles,omitempty"`
+}
+
+// DiscoveryConfig configures how the origins of a consul://, etcd:// or srv:// service are discovered.
+type DiscoveryConfig struct {
+	// Address is the URL of the Consul agent or etcd endpoint. Defaults to $CONSUL_HTTP_ADDR or http://127.0.0.1:8500
+	// for Consul, and http://127.0.0.1:2379 for etcd. For SRV records, it's the host:port of the DNS server to query
+	// instead of the system's resolver.
+	Address string `yaml:"address" json:"address,omitempty"`
+	// Token is the Consul ACL token, defaulting to $CONSUL_HTTP_TOKEN, or the etcd auth token.
+	Token string `yaml:"token" json:"token,omitempty"`
+	// Scheme is how discovered origins are reached, http or https. Defaults to http.
+	Scheme string `yaml:"scheme" json:"scheme,omitempty"`
+	// RefreshInterval is how often SRV records are resolved again. Defaults to 30 seconds.
+	RefreshInterval *CustomDuration `yaml:"refreshInterval" json:"refreshInterval,omitempty"`
+}
+
+// Redacted returns a copy of the configuration with the token redacted, for showing it by /config and --dry-run.
+func (c *DiscoveryConfig) Redacted() *DiscoveryConfig {
+	if c == nil || c.Token == "" {
+		return c
+	}
+	redacted := *c
+	redacted.Token = redactedValue
+	return &redacted
+}
+
+// TLSPolicyConfig restricts what TLS connections may negotiate. Unset fields keep Go's defaults.
+type TLSPolicyConfig struct {
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string `yaml:"minVersion" json:"minVersion,omitempty"`
+	// CipherSuites are the IANA names of the allowed TLS 1.0-1.2 cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	CipherSuites []string `yaml:"cipherSuites" json:"cipherSuites,omitempty"`
+	// Curves are the allowed curves in order of preference, among X25519, P256, P384 and P521.
+	Curves []string `yaml:"curves" json:"curves,omitempty"`
+	// CheckRevocation rejects certificates revoked according to the CRLs listed in them. OCSP isn't supported.
+	CheckRevocation bool `yaml:"checkRevocation" json:"checkRevocation,omitempty"`
+}
+
+// SocketConfig tunes TCP sockets. Unset fields keep the operating system's defaults.
+type SocketConfig struct {
+	// NoDelay sends small writes immediately instead of coalescing them (TCP_NODELAY). Defaults to true.
+	NoDelay *bool `yaml:"noDelay" json:"noDelay,omitempty"`
+	// KeepAliveInterval is the time between keepalive probes once the connection is idle. It's only applied on Linux, macOS, FreeBSD, NetBSD and DragonFly BSD.
+	KeepAliveInterval *CustomDuration `yaml:"keepAliveInterval" json:"keepAliveInterval,omitempty"`
+	// KeepAliveCount is how many unanswered keepalive probes drop the connection. It's only applied on Linux, macOS, FreeBSD, NetBSD and DragonFly BSD.
+	KeepAliveCount *int `yaml:"keepAliveCount" json:"keepAliveCount,omitempty"`
+	// SendBuffer is the size of the socket's send buffer in bytes (SO_SNDBUF).
+	SendBuffer *int `yaml:"sendBuffer" json:"sendBuffer,omitempty"`
+	// ReceiveBuffer is the size of the socket's receive buffer in bytes (SO_RCVBUF).
+	ReceiveBuffer *int `yaml:"receiveBuffer" json:"receiveBuffer,omitempty"`
+}
+
+// MirrorConfig asynchronously sends a copy of a share of the requests to a shadow origin. Its responses are discarded.
+type MirrorConfig struct {
+	// Service is the HTTP origin that receives the copies.
+	Service string `yaml:"service" json:"service"`
+	// Percentage of the requests that are mirrored, from 0 to 100. Defaults to 100.
+	Percentage *float64 `yaml:"percentage" json:"percentage,omitempty"`
+	// Timeout bounds how long a mirrored request may take. Defaults to 30 seconds.
+	Timeout *CustomDuration `yaml:"timeout" json:"timeout,omitempty"`
+	// MaxBodyBytes bounds the size of the request bodies that are mirrored, which are copied as the primary origin
+	// reads them. The copies of requests with larger bodies are aborted. Defaults to 1 MiB.
+	MaxBodyBytes *int64 `yaml:"maxBodyBytes" json:"maxBodyBytes,omitempty"`
+	// MaxConcurrent bounds how many mirrored requests are in flight at once. Requests beyond it aren't mirrored.
+	// Defaults to 100.
+	MaxConcurrent *int `yaml:"maxConcurrent" json:"maxConcurrent,omitempty"`
+}
+
+// HTTPHeadersConfig changes the headers of the requests sent to the origin. Headers are removed before others are
+// added.
+type HTTPHeadersConfig struct {
+	// Add sets headers, replacing the values sent by the eyeball, e.g. an internal authentication token.
+	Add map[string]string `yaml:"add" json:"add,omitempty"`
+	// Remove removes headers, e.g. Cf-Connecting-Ip.
+	Remove []string `yaml:"remove" json:"remove,omitempty"`
+}
+
+// Redacted returns a copy of the configuration with the values of the added headers redacted, since they can be
+// authentication tokens, for showing it by /config and --dry-run.
+func (c *HTTPHeadersConfig) Redacted() *HTTPHeadersConfig {
+	if c == nil || len(c.Add) == 0 {
+		return c
+	}
+	redacted := *c
+	redacted.Add = make(map[string]string, len(c.Add))
+	for name := range c.Add {
+		redacted.Add[name] = redactedValue
+	}
+	return &redacted
+}
+
+// ResponseRewriteConfig selects which response headers are rewritten from the origin's address to the public hostname.
+type ResponseRewriteConfig struct {
+	// Location rewrites Location headers that point to the origin.
+	Location bool `yaml:"location" json:"location,omitempty"`
+	// CookieDomain rewrites the Domain attribute of cookies set for the origin.
+	CookieDomain bool `yaml:"cookieDomain" json:"cookieDomain,omitempty"`
+	// CookiePath rewrites the Path attribute of cookies from an origin path prefix to a public one.
+	CookiePath map[string]string `yaml:"cookiePath" json:"cookiePath,omitempty"`
+	// InternalHosts are other addresses the origin refers to itself with, besides the service's.
+	InternalHosts []string `yaml:"internalHosts" json:"internalHosts,omitempty"`
 }
 
 type AccessConfig struct {
@@ -242,6 +570,35 @@ type AccessConfig struct {
 	AudTag []string `yaml:"audTag" json:"audTag"`
 }
 
+// AuthServiceConfig configures an external service that decides whether requests are allowed. Exactly one of URL
+// and Command must be set.
+type AuthServiceConfig struct {
+	// URL of an HTTP endpoint that responds with 2xx to allow a request.
+	URL string `yaml:"url" json:"url,omitempty"`
+	// Command to execute, followed by its arguments. It exits with 0 to allow a request.
+	Command []string `yaml:"command" json:"command,omitempty"`
+	// Timeout bounds how long the service may take to make a decision.
+	Timeout *CustomDuration `yaml:"timeout" json:"timeout,omitempty"`
+	// InjectHeaders are copied from the service's response to allowed requests.
+	InjectHeaders []string `yaml:"injectHeaders" json:"injectHeaders,omitempty"`
+}
+
+// PluginConfig enables a registered middleware plugin, or a WASM plugin.
+type PluginConfig struct {
+	// Name is the name the plugin was registered with. It defaults to the file name of Path for WASM plugins.
+	Name string `yaml:"name" json:"name"`
+	// Path is the WebAssembly module of a WASM plugin. It's loaded again when it changes.
+	Path string `yaml:"path" json:"path,omitempty"`
+	// Config is passed to the plugin when it's created.
+	Config map[string]interface{} `yaml:"config" json:"config,omitempty"`
+	// Timeout bounds how long each plugin hook may run.
+	Timeout *CustomDuration `yaml:"timeout" json:"timeout,omitempty"`
+	// MaxBodyBytes bounds how much of each response body is passed to the plugin.
+	MaxBodyBytes *int `yaml:"maxBodyBytes" json:"maxBodyBytes,omitempty"`
+	// MaxMemoryBytes bounds the memory of each instance of a WASM plugin.
+	MaxMemoryBytes *int `yaml:"maxMemoryBytes" json:"maxMemoryBytes,omitempty"`
+}
+
 type IngressIPRule struct {
 	Prefix *string `yaml:"prefix" json:"prefix"`
 	Ports  []int   `yaml:"ports" json:"ports"`
@@ -249,7 +606,10 @@ type IngressIPRule struct {
 }
 
 type Configuration struct {
-	TunnelID      string `yaml:"tunnel"`
+	TunnelID string `yaml:"tunnel"`
+	// Include lists files, or glob patterns of files, relative to the configuration file, whose ingress rules are
+	// merged into the ones of the configuration file.
+	Include       []string `yaml:"include"`
 	Ingress       []UnvalidatedIngressRule
 	WarpRouting   WarpRoutingConfig   `yaml:"warp-routing"`
 	OriginRequest OriginRequestConfig `yaml:"originRequest"`
@@ -404,6 +764,10 @@ func ReadConfigFile(c *cli.Context, log *zerolog.Logger) (settings *configFileSe
 		return nil, "", errors.Wrap(err, "error parsing YAML in config file at "+configFile)
 	}
 	configuration.sourceFile = configFile
+	if err := configuration.mergeIncludedIngress(c.String(ConfigDirFlag)); err != nil {
+		configuration.sourceFile = ""
+		return nil, "", err
+	}
 
 	// Parse it again, with strict mode, to find warnings.
 	if file, err := os.Open(configFile); err == nil {
@@ -149,6 +149,51 @@ var rawJsonConfig = []byte(`
 }
 `)
 
+func TestUnmarshalIngressRuleOrigins(t *testing.T) {
+	rawYAML := `
+ingress:
+  - hostname: app.example.com
+    service:
+      - url: http://10.0.0.1:8080
+        weight: 2
+      - url: http://10.0.0.2:8080
+        backup: true
+  - service: http_status:404
+`
+	origins := []WeightedOrigin{
+		{URL: "http://10.0.0.1:8080", Weight: 2},
+		{URL: "http://10.0.0.2:8080", Backup: true},
+	}
+	var conf configFileSettings
+	require.NoError(t, yaml.Unmarshal([]byte(rawYAML), &conf))
+	require.Len(t, conf.Ingress, 2)
+	assert.Equal(t, "app.example.com", conf.Ingress[0].Hostname)
+	assert.Equal(t, "", conf.Ingress[0].Service)
+	assert.Equal(t, origins, conf.Ingress[0].Origins)
+	assert.Equal(t, "http_status:404", conf.Ingress[1].Service)
+	assert.Nil(t, conf.Ingress[1].Origins)
+
+	for _, tc := range []struct {
+		name          string
+		marshalFunc   func(in interface{}) (out []byte, err error)
+		unMarshalFunc func(in []byte, out interface{}) (err error)
+	}{
+		{"json", json.Marshal, json.Unmarshal},
+		{"yaml", yaml.Marshal, yaml.Unmarshal},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serialized, err := tc.marshalFunc(conf.Ingress)
+			require.NoError(t, err)
+			var rules []UnvalidatedIngressRule
+			require.NoError(t, tc.unMarshalFunc(serialized, &rules))
+			require.Len(t, rules, 2)
+			assert.Equal(t, origins, rules[0].Origins)
+			assert.Equal(t, "http_status:404", rules[1].Service)
+			assert.Nil(t, rules[1].Origins)
+		})
+	}
+}
+
 func TestMarshalUnmarshalOriginRequest(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -218,3 +263,37 @@ func assertConfig(
 
 	require.Equal(t, config2, config)
 }
+
+func TestDiscoveryConfigRedacted(t *testing.T) {
+	discovery := &DiscoveryConfig{Address: "http://127.0.0.1:8500", Token: "consul-token"}
+
+	// The configuration is serialized as is, since it's sent to the edge
+	serialized, err := json.Marshal(discovery)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"address":"http://127.0.0.1:8500","token":"consul-token"}`, string(serialized))
+
+	redacted := discovery.Redacted()
+	assert.Equal(t, "REDACTED", redacted.Token)
+	assert.Equal(t, discovery.Address, redacted.Address)
+	assert.Equal(t, "consul-token", discovery.Token)
+
+	var unset *DiscoveryConfig
+	assert.Nil(t, unset.Redacted())
+}
+
+func TestHTTPHeadersConfigRedacted(t *testing.T) {
+	headers := &HTTPHeadersConfig{Add: map[string]string{"Authorization": "Bearer origin-token"}, Remove: []string{"Cf-Connecting-Ip"}}
+
+	// The configuration is serialized as is, since it's sent to the edge
+	serialized, err := json.Marshal(headers)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"add":{"Authorization":"Bearer origin-token"},"remove":["Cf-Connecting-Ip"]}`, string(serialized))
+
+	redacted := headers.Redacted()
+	assert.Equal(t, map[string]string{"Authorization": "REDACTED"}, redacted.Add)
+	assert.Equal(t, headers.Remove, redacted.Remove)
+	assert.Equal(t, "Bearer origin-token", headers.Add["Authorization"])
+
+	var unset *HTTPHeadersConfig
+	assert.Nil(t, unset.Redacted())
+}
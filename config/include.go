@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ConfigDirFlag is the flag of the directory of ingress fragment files to merge into the configuration.
+const ConfigDirFlag = "config-dir"
+
+// ingressFragment is a file of ingress rules that's included by the configuration file, e.g. the rules that a team
+// manages on its own. It can't have other settings.
+type ingressFragment struct {
+	Ingress []UnvalidatedIngressRule `yaml:"ingress"`
+}
+
+// mergeIncludedIngress merges the ingress rules of the files that the configuration includes, and then of the
+// fragments of configDir, into the rules of the configuration file, before its catch-all rule. Included files are
+// merged in the order of the include list, the files matching the same pattern in lexical order, as are the fragments
+// of configDir. Two files routing the same hostname and path is a conflict.
+func (c *Configuration) mergeIncludedIngress(configDir string) error {
+	paths, err := c.includedFiles(configDir)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	rules := c.Ingress
+	var catchAll []UnvalidatedIngressRule
+	if n := len(rules); n > 0 && isCatchAll(rules[n-1]) {
+		rules, catchAll = rules[:n-1:n-1], rules[n-1:]
+	}
+	sources := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		sources[ruleKey(rule)] = c.sourceFile
+	}
+	for _, path := range paths {
+		fragment, err := readIngressFragment(path)
+		if err != nil {
+			return err
+		}
+		for i, rule := range fragment.Ingress {
+			if isCatchAll(rule) {
+				return fmt.Errorf("rule #%d of %s matches all requests, only the configuration file %s can have a catch-all rule", i+1, path, c.sourceFile)
+			}
+			key := ruleKey(rule)
+			if source, ok := sources[key]; ok && source != path {
+				return fmt.Errorf("rule #%d of %s routes hostname %q and path %q, which %s already routes", i+1, path, rule.Hostname, rule.Path, source)
+			}
+			sources[key] = path
+			rules = append(rules, rule)
+		}
+	}
+	c.Ingress = append(rules, catchAll...)
+	return nil
+}
+
+// includedFiles returns the files matching the include patterns, relative to the configuration file, followed by the
+// YAML files of configDir. Files are only returned once.
+func (c *Configuration) includedFiles(configDir string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{c.sourceFile: true}
+	add := func(matches []string) {
+		sort.Strings(matches)
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	baseDir := filepath.Dir(c.sourceFile)
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid include pattern %s", pattern)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("included file %s doesn't exist", pattern)
+		}
+		add(matches)
+	}
+	if configDir != "" {
+		if _, err := os.Stat(configDir); err != nil {
+			return nil, errors.Wrapf(err, "invalid --%s", ConfigDirFlag)
+		}
+		var matches []string
+		for _, extension := range []string{"*.yml", "*.yaml"} {
+			found, err := filepath.Glob(filepath.Join(configDir, extension))
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, found...)
+		}
+		add(matches)
+	}
+	return paths, nil
+}
+
+func readIngressFragment(path string) (*ingressFragment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	var fragment ingressFragment
+	if err := decoder.Decode(&fragment); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "error parsing YAML in included ingress file at "+path)
+	}
+	return &fragment, nil
+}
+
+func isCatchAll(rule UnvalidatedIngressRule) bool {
+	return (rule.Hostname == "" || rule.Hostname == "*") && rule.Path == "" && len(rule.Methods) == 0 && len(rule.Headers) == 0
+}
+
+// ruleKey identifies the requests a rule matches. Rules that also match on methods or headers don't conflict with
+// the rules of other files, since they're meant to route some of the requests of a hostname and path elsewhere.
+func ruleKey(rule UnvalidatedIngressRule) string {
+	key := rule.Hostname + "\x00" + rule.Path
+	if len(rule.Methods) > 0 || len(rule.Headers) > 0 {
+		key += fmt.Sprintf("\x00%v\x00%v", rule.Methods, rule.Headers)
+	}
+	return key
+}
+
+// ReadConfiguration reads the configuration file anew, with the ingress rules of the files it includes and of the
+// fragments of configDir, e.g. to reload it once it changed.
+func ReadConfiguration(configFile, configDir string) (*Configuration, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var conf Configuration
+	if err := yaml.NewDecoder(file).Decode(&conf); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "error parsing YAML in config file at "+configFile)
+	}
+	conf.sourceFile = configFile
+	if err := conf.mergeIncludedIngress(configDir); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// SourceDirectories returns the directories of the files the configuration is read from: the configuration file, the
+// files it includes and configDir.
+func (c *Configuration) SourceDirectories(configDir string) []string {
+	dirs := []string{filepath.Dir(c.sourceFile)}
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(c.sourceFile), pattern)
+		}
+		dirs = append(dirs, filepath.Dir(pattern))
+	}
+	if configDir != "" {
+		dirs = append(dirs, configDir)
+	}
+	sort.Strings(dirs)
+	unique := dirs[:0]
+	for i, dir := range dirs {
+		if i == 0 || dir != dirs[i-1] {
+			unique = append(unique, dir)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestMergeIncludedIngress(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	writeConfigFile(t, filepath.Join(dir, "teams", "b.yml"), `
+ingress:
+  - hostname: b.example.com
+    service: http://localhost:8002
+  - hostname: main.example.com
+    methods: [POST]
+    service: http://localhost:8012
+`)
+	writeConfigFile(t, filepath.Join(dir, "teams", "a.yml"), `
+ingress:
+  - hostname: a.example.com
+    service: http://localhost:8001
+  - hostname: a.example.com
+    path: /api
+    service: http://localhost:8011
+`)
+	writeConfigFile(t, filepath.Join(dir, "conf.d", "c.yaml"), `
+ingress:
+  - hostname: c.example.com
+    service: http://localhost:8003
+`)
+
+	conf := Configuration{
+		Include: []string{"teams/*.yml"},
+		Ingress: []UnvalidatedIngressRule{
+			{Hostname: "main.example.com", Service: "http://localhost:8000"},
+			{Service: "http_status:404"},
+		},
+		sourceFile: configFile,
+	}
+	require.NoError(t, conf.mergeIncludedIngress(filepath.Join(dir, "conf.d")))
+	var hostnames []string
+	for _, rule := range conf.Ingress {
+		hostnames = append(hostnames, rule.Hostname+rule.Path)
+	}
+	assert.Equal(t, []string{"main.example.com", "a.example.com", "a.example.com/api", "b.example.com", "main.example.com", "c.example.com", ""}, hostnames)
+}
+
+func TestMergeIncludedIngressErrors(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	writeConfigFile(t, filepath.Join(dir, "conflict.yml"), `
+ingress:
+  - hostname: main.example.com
+    service: http://localhost:8001
+`)
+	writeConfigFile(t, filepath.Join(dir, "catch-all.yml"), `
+ingress:
+  - service: http://localhost:8001
+`)
+	writeConfigFile(t, filepath.Join(dir, "settings.yml"), `
+tunnel: other
+`)
+
+	for _, include := range []string{"conflict.yml", "catch-all.yml", "settings.yml", "missing.yml", "[.yml"} {
+		conf := Configuration{
+			Include:    []string{include},
+			Ingress:    []UnvalidatedIngressRule{{Hostname: "main.example.com", Service: "http://localhost:8000"}},
+			sourceFile: configFile,
+		}
+		assert.Error(t, conf.mergeIncludedIngress(""), include)
+	}
+
+	// A pattern that matches nothing is fine, a missing directory isn't
+	conf := Configuration{Include: []string{"teams/*.yml"}, sourceFile: configFile}
+	assert.NoError(t, conf.mergeIncludedIngress(""))
+	assert.Error(t, conf.mergeIncludedIngress(filepath.Join(dir, "missing")))
+}
+
+func TestReadConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	writeConfigFile(t, configFile, `
+tunnel: my-tunnel
+include:
+  - teams/*.yml
+ingress:
+  - service: http_status:404
+`)
+	writeConfigFile(t, filepath.Join(dir, "teams", "a.yml"), `
+ingress:
+  - hostname: a.example.com
+    service: http://localhost:8001
+`)
+
+	_, err := ReadConfiguration(configFile, filepath.Join(dir, "conf.d"))
+	require.Error(t, err)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0700))
+	conf, err := ReadConfiguration(configFile, filepath.Join(dir, "conf.d"))
+	require.NoError(t, err)
+	assert.Equal(t, "my-tunnel", conf.TunnelID)
+	assert.Equal(t, configFile, conf.Source())
+	require.Len(t, conf.Ingress, 2)
+	assert.Equal(t, "a.example.com", conf.Ingress[0].Hostname)
+	assert.Equal(t, []string{dir, filepath.Join(dir, "conf.d"), filepath.Join(dir, "teams")}, conf.SourceDirectories(filepath.Join(dir, "conf.d")))
+}
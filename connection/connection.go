@@ -54,6 +54,8 @@ type Credentials struct {
 	AccountTag   string
 	TunnelSecret []byte
 	TunnelID     uuid.UUID
+	// TunnelName is informational, so that a credentials file tells which tunnel it's for at a glance
+	TunnelName string `json:",omitempty"`
 }
 
 func (c *Credentials) Auth() pogs.TunnelAuth {
@@ -244,12 +246,26 @@ func (c *localProxyConnection) SetWriteDeadline(t time.Time) error {
 // ResponseWriter is the response path for a request back through cloudflared's tunnel.
 type ResponseWriter interface {
 	WriteRespHeaders(status int, header http.Header) error
+	// WriteInterimResponse forwards an informational (1xx) response, such as 103 Early Hints, before the final
+	// response written with WriteRespHeaders.
+	WriteInterimResponse(status int, header http.Header) error
 	AddTrailer(trailerName, trailerValue string)
 	http.ResponseWriter
 	http.Hijacker
 	io.Writer
 }
 
+// validateInterimResponse checks that status can be sent as an interim response.
+func validateInterimResponse(status int, finalResponseWritten bool) error {
+	if finalResponseWritten {
+		return fmt.Errorf("can't write a %d response after the final response", status)
+	}
+	if status < http.StatusContinue || status >= http.StatusOK || status == http.StatusSwitchingProtocols {
+		return fmt.Errorf("%d isn't an interim response status", status)
+	}
+	return nil
+}
+
 type ConnectedFuse interface {
 	Connected()
 	IsConnected() bool
@@ -270,6 +286,16 @@ func shouldFlush(headers http.Header) bool {
 	return false
 }
 
+// IsServerSentEvent returns true when the headers describe a server-sent events stream.
+func IsServerSentEvent(headers http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(headers.Get(contentTypeHeader)), sseContentType)
+}
+
+// IsGRPC tells whether the content type of a request or response is gRPC.
+func IsGRPC(headers http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(headers.Get(contentTypeHeader)), grpcContentType)
+}
+
 func uint8ToString(input uint8) string {
 	return strconv.FormatUint(uint64(input), 10)
 }
@@ -91,6 +91,11 @@ func (moc *mockOriginProxy) ProxyHTTP(
 		originRespEndpoint(w, http.StatusInternalServerError, []byte(http.StatusText(http.StatusInternalServerError)))
 	case "/error":
 		return fmt.Errorf("Failed to proxy to origin")
+	case "/early_hints":
+		if err := w.WriteInterimResponse(http.StatusEarlyHints, http.Header{"Link": []string{"</style.css>; rel=preload"}}); err != nil {
+			return err
+		}
+		originRespEndpoint(w, http.StatusOK, []byte(http.StatusText(http.StatusOK)))
 	default:
 		originRespEndpoint(w, http.StatusNotFound, []byte("page not found"))
 	}
@@ -86,7 +86,7 @@ func (c *controlStream) ServeControlStream(
 	}
 
 	c.observer.logConnected(registrationDetails.UUID, c.connIndex, registrationDetails.Location, c.edgeAddress, c.protocol)
-	c.observer.sendConnectedEvent(c.connIndex, c.protocol, registrationDetails.Location)
+	c.observer.sendConnectedEvent(registrationDetails.UUID, c.connIndex, registrationDetails.Location, c.edgeAddress, c.protocol)
 	c.connectedFuse.Connected()
 
 	// if conn index is 0 and tunnel is not remotely managed, then send local ingress rules configuration
@@ -114,7 +114,7 @@ func (c *controlStream) waitForUnregister(ctx context.Context, rpcClient NamedTu
 		c.stoppedGracefully = true
 	}
 
-	c.observer.sendUnregisteringEvent(c.connIndex)
+	c.observer.sendUnregisteringEvent(c.connIndex, c.stoppedGracefully)
 	rpcClient.GracefulShutdown(ctx, c.gracePeriod)
 	c.observer.log.Info().
 		Int(management.EventTypeKey, int(management.Cloudflared)).
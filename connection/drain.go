@@ -0,0 +1,155 @@
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kinds of in-flight work tracked by a Drainer.
+const (
+	InFlightHTTP       = "http"
+	InFlightWebsocket  = "websocket"
+	InFlightTCP        = "tcp"
+	InFlightUDPSession = "udp"
+)
+
+var (
+	inFlightGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "in_flight",
+			Help:      "Number of requests and sessions being proxied, by kind",
+		},
+		[]string{"kind"},
+	)
+	drainingGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "draining",
+			Help:      "1 if cloudflared is waiting for in-flight requests and sessions to complete before shutting down, 0 otherwise",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(inFlightGauge, drainingGauge)
+}
+
+// Drainer tracks the requests and sessions in flight, so a graceful shutdown can wait for them to complete instead of
+// cutting them after a fixed grace period. Draining starts once the connections are unregistered from the edge, so
+// the requests the edge routed before it learnt about the unregistration are still accepted.
+// A nil Drainer tracks nothing and never drains.
+type Drainer struct {
+	lock      sync.Mutex
+	inFlight  map[string]int
+	draining  bool
+	drained   bool
+	startedAt time.Time
+	deadline  time.Time
+	drainedC  chan struct{}
+}
+
+// DrainStatus reports the progress of a drain.
+type DrainStatus struct {
+	Draining  bool           `json:"draining"`
+	StartedAt *time.Time     `json:"startedAt,omitempty"`
+	Deadline  *time.Time     `json:"deadline,omitempty"`
+	InFlight  map[string]int `json:"inFlight"`
+}
+
+func NewDrainer() *Drainer {
+	return &Drainer{
+		inFlight: make(map[string]int),
+		drainedC: make(chan struct{}),
+	}
+}
+
+// Track registers a request or session of the given kind. It returns false once the drain completed, in which case the
+// request must be rejected because the connections are about to close. Otherwise, done must be called when it
+// completes.
+func (d *Drainer) Track(kind string) (done func(), ok bool) {
+	if d == nil {
+		return func() {}, true
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.drained {
+		return nil, false
+	}
+	d.inFlight[kind]++
+	inFlightGauge.WithLabelValues(kind).Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() { d.release(kind) })
+	}, true
+}
+
+func (d *Drainer) release(kind string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.inFlight[kind]--
+	inFlightGauge.WithLabelValues(kind).Dec()
+	d.closeIfDrained()
+}
+
+// Drain starts waiting for the requests and sessions in flight, it must be called once the connections are
+// unregistered. The returned channel is closed once the in-flight count reaches 0, after which new requests are
+// rejected. timeout is only reported in the status because waiting is up to the caller. Calling Drain again returns
+// the same channel.
+func (d *Drainer) Drain(timeout time.Duration) <-chan struct{} {
+	if d == nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return d.drainedC
+	}
+	d.draining = true
+	d.startedAt = time.Now()
+	d.deadline = d.startedAt.Add(timeout)
+	drainingGauge.Set(1)
+	d.closeIfDrained()
+	return d.drainedC
+}
+
+// closeIfDrained closes drainedC once draining and nothing is in flight. It must be called with lock held.
+func (d *Drainer) closeIfDrained() {
+	if d.draining && !d.drained && d.totalInFlight() == 0 {
+		d.drained = true
+		close(d.drainedC)
+	}
+}
+
+// Status returns a snapshot of the requests and sessions in flight and of the drain progress.
+func (d *Drainer) Status() DrainStatus {
+	if d == nil {
+		return DrainStatus{InFlight: map[string]int{}}
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	status := DrainStatus{
+		Draining: d.draining,
+		InFlight: make(map[string]int, len(d.inFlight)),
+	}
+	for kind, count := range d.inFlight {
+		status.InFlight[kind] = count
+	}
+	if d.draining {
+		startedAt, deadline := d.startedAt, d.deadline
+		status.StartedAt, status.Deadline = &startedAt, &deadline
+	}
+	return status
+}
+
+func (d *Drainer) totalInFlight() int {
+	total := 0
+	for _, count := range d.inFlight {
+		total += count
+	}
+	return total
+}
@@ -0,0 +1,60 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainer(t *testing.T) {
+	drainer := NewDrainer()
+	httpDone, ok := drainer.Track(InFlightHTTP)
+	require.True(t, ok)
+	udpDone, ok := drainer.Track(InFlightUDPSession)
+	require.True(t, ok)
+
+	status := drainer.Status()
+	require.False(t, status.Draining)
+	require.Nil(t, status.Deadline)
+	require.Equal(t, map[string]int{InFlightHTTP: 1, InFlightUDPSession: 1}, status.InFlight)
+
+	drainedC := drainer.Drain(time.Minute)
+	// The edge may still route requests it sent before the connections unregistered
+	tcpDone, ok := drainer.Track(InFlightTCP)
+	require.True(t, ok, "requests in flight from the edge must be accepted while draining")
+	require.Equal(t, drainedC, drainer.Drain(time.Minute))
+
+	status = drainer.Status()
+	require.True(t, status.Draining)
+	require.NotNil(t, status.Deadline)
+
+	httpDone()
+	// Calling done twice must not count the request twice
+	httpDone()
+	select {
+	case <-drainedC:
+		t.Fatal("drained with a session in flight")
+	default:
+	}
+	udpDone()
+	tcpDone()
+	select {
+	case <-drainedC:
+	default:
+		t.Fatal("not drained after the in-flight requests completed")
+	}
+	require.Equal(t, map[string]int{InFlightHTTP: 0, InFlightTCP: 0, InFlightUDPSession: 0}, drainer.Status().InFlight)
+
+	_, ok = drainer.Track(InFlightHTTP)
+	require.False(t, ok, "new requests must be rejected once drained")
+}
+
+func TestNilDrainer(t *testing.T) {
+	var drainer *Drainer
+	done, ok := drainer.Track(InFlightHTTP)
+	require.True(t, ok)
+	done()
+	require.Nil(t, drainer.Drain(time.Minute))
+	require.False(t, drainer.Status().Draining)
+}
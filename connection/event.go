@@ -1,12 +1,31 @@
 package connection
 
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/uuid"
+)
+
 // Event is something that happened to a connection, e.g. disconnection or registration.
 type Event struct {
 	Index     uint8
 	EventType Status
-	Location  string
-	Protocol  Protocol
-	URL       string
+	// Location is the colo the connection is registered with, for Connected events.
+	Location string
+	Protocol Protocol
+	URL      string
+	// ConnectionID and EdgeAddress identify the registered connection, for Connected events.
+	ConnectionID uuid.UUID
+	EdgeAddress  net.IP
+	// ConfigVersion is the version of the configuration applied, for ConfigUpdated events.
+	ConfigVersion int32
+	// Err is why registering the connection failed for RegistrationError events, and why the connection was lost for
+	// Disconnected events. It's nil if the connection was closed gracefully.
+	Err error
+	// ShuttingDown is true for Unregistering events sent because cloudflared is shutting down, rather than because the
+	// connection was lost.
+	ShuttingDown bool
 }
 
 // Status is the status of a connection.
@@ -25,4 +44,31 @@ const (
 	RegisteringTunnel
 	// We're unregistering tunnel from the edge in preparation for a disconnect
 	Unregistering
+	// RegistrationError means the edge refused to register the connection.
+	RegistrationError
+	// ConfigUpdated means a new remote configuration was applied. It's not specific to a connection.
+	ConfigUpdated
 )
+
+func (s Status) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	case SetURL:
+		return "set_url"
+	case RegisteringTunnel:
+		return "registering"
+	case Unregistering:
+		return "unregistering"
+	case RegistrationError:
+		return "registration_error"
+	case ConfigUpdated:
+		return "config_updated"
+	default:
+		return fmt.Sprintf("unknown status %d", int(s))
+	}
+}
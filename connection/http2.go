@@ -285,6 +285,26 @@ func (rp *http2RespWriter) WriteRespHeaders(status int, header http.Header) erro
 	return nil
 }
 
+// WriteInterimResponse sends an informational response to the edge. The final response is still written with
+// WriteRespHeaders.
+func (rp *http2RespWriter) WriteInterimResponse(status int, header http.Header) error {
+	if err := validateInterimResponse(status, rp.hijacked() || rp.statusWritten); err != nil {
+		return err
+	}
+	userHeaders := make(http.Header, len(header))
+	for name, values := range header {
+		if !IsControlResponseHeader(strings.ToLower(name)) {
+			userHeaders[name] = values
+		}
+	}
+	// The final response replaces these headers
+	rp.w.Header().Set(CanonicalResponseUserHeaders, SerializeHeaders(userHeaders))
+	rp.setResponseMetaHeader(responseMetaHeaderOrigin)
+	rp.w.WriteHeader(status)
+	rp.flusher.Flush()
+	return nil
+}
+
 func (rp *http2RespWriter) Header() http.Header {
 	return rp.respHeaders
 }
@@ -362,6 +382,13 @@ func (rp *http2RespWriter) Write(p []byte) (n int, err error) {
 	return n, err
 }
 
+// Flush sends the data written so far to the edge, regardless of whether this response is flushed on every write.
+func (rp *http2RespWriter) Flush() {
+	if rp.flusher != nil && !rp.hijacked() {
+		rp.flusher.Flush()
+	}
+}
+
 func (rp *http2RespWriter) Close() error {
 	return nil
 }
@@ -10,6 +10,8 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
 	"sync"
 	"testing"
 	"time"
@@ -21,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/http2"
 
+	"github.com/cloudflare/cloudflared/h2mux"
 	"github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
@@ -163,6 +166,44 @@ func TestServeHTTP(t *testing.T) {
 	wg.Wait()
 }
 
+func TestServeHTTPEarlyHints(t *testing.T) {
+	http2Conn, edgeConn := newTestHTTP2Connection()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		http2Conn.Serve(ctx)
+	}()
+
+	edgeHTTP2Conn, err := testTransport.NewClientConn(edgeConn)
+	require.NoError(t, err)
+
+	var interimStatuses []int
+	var interimUserHeaders []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(status int, header textproto.MIMEHeader) error {
+			interimStatuses = append(interimStatuses, status)
+			interimUserHeaders = append(interimUserHeaders, header.Get(CanonicalResponseUserHeaders))
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, "http://localhost:8080/early_hints", nil)
+	require.NoError(t, err)
+	resp, err := edgeHTTP2Conn.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, []int{http.StatusEarlyHints}, interimStatuses)
+	userHeaders, err := DeserializeHeaders(interimUserHeaders[0])
+	require.NoError(t, err)
+	require.Equal(t, []h2mux.Header{{Name: "Link", Value: "</style.css>; rel=preload"}}, userHeaders)
+
+	cancel()
+	wg.Wait()
+}
+
 type mockNamedTunnelRPCClient struct {
 	shouldFail   error
 	registered   chan struct{}
@@ -515,8 +556,9 @@ func TestGracefulShutdownHTTP2(t *testing.T) {
 	wg.Wait()
 
 	events.assertSawEvent(t, Event{
-		Index:     http2Conn.connIndex,
-		EventType: Unregistering,
+		Index:        http2Conn.connIndex,
+		EventType:    Unregistering,
+		ShuttingDown: true,
 	})
 }
 
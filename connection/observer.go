@@ -3,6 +3,7 @@ package connection
 import (
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
@@ -24,6 +25,7 @@ type Observer struct {
 	metrics         *tunnelMetrics
 	tunnelEventChan chan Event
 	addSinkChan     chan EventSink
+	removeSinkChan  chan EventSink
 }
 
 type EventSink interface {
@@ -37,6 +39,7 @@ func NewObserver(log, logTransport *zerolog.Logger) *Observer {
 		metrics:         newTunnelMetrics(),
 		tunnelEventChan: make(chan Event, observerChannelBufferSize),
 		addSinkChan:     make(chan EventSink, observerChannelBufferSize),
+		removeSinkChan:  make(chan EventSink, observerChannelBufferSize),
 	}
 	go o.dispatchEvents()
 	return o
@@ -46,8 +49,46 @@ func (o *Observer) RegisterSink(sink EventSink) {
 	o.addSinkChan <- sink
 }
 
+// Subscribe returns a channel that receives the events of the given types, or all events if no type is given. Events
+// are dropped rather than delaying other sinks if the subscriber falls more than bufferSize events behind.
+// The returned function ends the subscription and closes the channel.
+func (o *Observer) Subscribe(bufferSize int, types ...Status) (<-chan Event, func()) {
+	s := &subscription{
+		events: make(chan Event, bufferSize),
+		types:  make(map[Status]bool, len(types)),
+		log:    o.log,
+	}
+	for _, t := range types {
+		s.types[t] = true
+	}
+	o.RegisterSink(s)
+	var once sync.Once
+	return s.events, func() {
+		once.Do(func() {
+			o.removeSinkChan <- s
+		})
+	}
+}
+
+type subscription struct {
+	events chan Event
+	types  map[Status]bool
+	log    *zerolog.Logger
+}
+
+func (s *subscription) OnTunnelEvent(event Event) {
+	if len(s.types) > 0 && !s.types[event.EventType] {
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		s.log.Warn().Msgf("Dropping %s event because the subscriber isn't keeping up", event.EventType)
+	}
+}
+
+// logConnected only logs the registration, sendConnectedEvent notifies sinks about it.
 func (o *Observer) logConnected(connectionID uuid.UUID, connIndex uint8, location string, address net.IP, protocol Protocol) {
-	o.sendEvent(Event{Index: connIndex, EventType: Connected, Location: location})
 	o.log.Info().
 		Int(management.EventTypeKey, int(management.Cloudflared)).
 		Str(LogFieldConnectionID, connectionID.String()).
@@ -63,8 +104,15 @@ func (o *Observer) sendRegisteringEvent(connIndex uint8) {
 	o.sendEvent(Event{Index: connIndex, EventType: RegisteringTunnel})
 }
 
-func (o *Observer) sendConnectedEvent(connIndex uint8, protocol Protocol, location string) {
-	o.sendEvent(Event{Index: connIndex, EventType: Connected, Protocol: protocol, Location: location})
+func (o *Observer) sendConnectedEvent(connectionID uuid.UUID, connIndex uint8, location string, address net.IP, protocol Protocol) {
+	o.sendEvent(Event{
+		Index:        connIndex,
+		EventType:    Connected,
+		Protocol:     protocol,
+		Location:     location,
+		ConnectionID: connectionID,
+		EdgeAddress:  address,
+	})
 }
 
 func (o *Observer) SendURL(url string) {
@@ -82,12 +130,23 @@ func (o *Observer) SendReconnect(connIndex uint8) {
 	o.sendEvent(Event{Index: connIndex, EventType: Reconnecting})
 }
 
-func (o *Observer) sendUnregisteringEvent(connIndex uint8) {
-	o.sendEvent(Event{Index: connIndex, EventType: Unregistering})
+func (o *Observer) sendUnregisteringEvent(connIndex uint8, shuttingDown bool) {
+	o.sendEvent(Event{Index: connIndex, EventType: Unregistering, ShuttingDown: shuttingDown})
+}
+
+// SendDisconnect reports that the connection at connIndex was closed, because of cause if it wasn't on purpose.
+func (o *Observer) SendDisconnect(connIndex uint8, cause error) {
+	o.sendEvent(Event{Index: connIndex, EventType: Disconnected, Err: cause})
 }
 
-func (o *Observer) SendDisconnect(connIndex uint8) {
-	o.sendEvent(Event{Index: connIndex, EventType: Disconnected})
+// SendRegistrationError reports that the connection at connIndex failed to register with the edge.
+func (o *Observer) SendRegistrationError(connIndex uint8, err error) {
+	o.sendEvent(Event{Index: connIndex, EventType: RegistrationError, Err: err})
+}
+
+// SendConfigUpdated reports that the remote configuration at version was applied.
+func (o *Observer) SendConfigUpdated(version int32) {
+	o.sendEvent(Event{EventType: ConfigUpdated, ConfigVersion: version})
 }
 
 func (o *Observer) sendEvent(e Event) {
@@ -105,7 +164,11 @@ func (o *Observer) dispatchEvents() {
 		select {
 		case sink := <-o.addSinkChan:
 			sinks = append(sinks, sink)
+		case sink := <-o.removeSinkChan:
+			sinks = removeSink(o.drainNewSinks(sinks), sink)
 		case evt := <-o.tunnelEventChan:
+			// Sinks registered before the event was sent must see it, even if both are pending.
+			sinks = o.drainNewSinks(sinks)
 			for _, sink := range sinks {
 				sink.OnTunnelEvent(evt)
 			}
@@ -113,6 +176,30 @@ func (o *Observer) dispatchEvents() {
 	}
 }
 
+func (o *Observer) drainNewSinks(sinks []EventSink) []EventSink {
+	for {
+		select {
+		case sink := <-o.addSinkChan:
+			sinks = append(sinks, sink)
+		default:
+			return sinks
+		}
+	}
+}
+
+// removeSink removes a subscription from sinks and closes its channel.
+func removeSink(sinks []EventSink, target EventSink) []EventSink {
+	for i, sink := range sinks {
+		if sink == target {
+			if s, ok := sink.(*subscription); ok {
+				close(s.events)
+			}
+			return append(sinks[:i], sinks[i+1:]...)
+		}
+	}
+	return sinks
+}
+
 type EventSinkFunc func(event Event)
 
 func (f EventSinkFunc) OnTunnelEvent(event Event) {
@@ -1,11 +1,14 @@
 package connection
 
 import (
+	"errors"
+	"net"
 	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
@@ -102,3 +105,44 @@ func (s *eventCollectorSink) assertSawEvent(t *testing.T, event Event) {
 	defer s.mu.Unlock()
 	assert.Contains(t, s.observedEvents, event)
 }
+
+func TestObserverSubscribe(t *testing.T) {
+	observer := NewObserver(&log, &log)
+
+	all, unsubscribeAll := observer.Subscribe(4)
+	disconnections, unsubscribe := observer.Subscribe(4, Disconnected)
+
+	cause := errors.New("connection reset")
+	observer.sendConnectedEvent(uuid.New(), 0, "SFO", net.IPv4(198, 41, 192, 7), QUIC)
+	observer.SendDisconnect(0, cause)
+
+	event := receiveEvent(t, all)
+	assert.Equal(t, Connected, event.EventType)
+	assert.Equal(t, "SFO", event.Location)
+	assert.Equal(t, "198.41.192.7", event.EdgeAddress.String())
+	assert.Equal(t, Disconnected, receiveEvent(t, all).EventType)
+
+	event = receiveEvent(t, disconnections)
+	assert.Equal(t, Disconnected, event.EventType)
+	assert.Equal(t, cause, event.Err)
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-disconnections
+	assert.False(t, open)
+
+	// Other subscriptions are unaffected
+	observer.SendURL("my-url.com")
+	assert.Equal(t, SetURL, receiveEvent(t, all).EventType)
+	unsubscribeAll()
+}
+
+func receiveEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event{}
+	}
+}
@@ -3,6 +3,8 @@ package connection
 import (
 	"fmt"
 	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -86,6 +88,125 @@ type TLSSettings struct {
 type ProtocolSelector interface {
 	Current() Protocol
 	Fallback() (Protocol, bool)
+	// FallbackFrom returns the protocol that a connection using protocol falls back to, if any.
+	FallbackFrom(protocol Protocol) (Protocol, bool)
+}
+
+// ProtocolFallbackPolicy is how connections fall back to other protocols when they keep failing with theirs.
+type ProtocolFallbackPolicy struct {
+	// Order are the protocols connections use, from the preferred one to the last resort. Empty keeps the selection
+	// of the protocol flag.
+	Order []Protocol
+	// Retries is how many times a connection is retried with a protocol before it falls back to the next one. 0 keeps
+	// the retries of the connections.
+	Retries uint
+	// Cooldown is how long a connection stays on a fallback protocol before it tries the preferred protocol again the
+	// next time it reconnects. 0 means it stays on the fallback protocol.
+	Cooldown time.Duration
+}
+
+// ParseProtocolFallbackOrder parses the protocols of a fallback order.
+func ParseProtocolFallbackOrder(names []string, log *zerolog.Logger) ([]Protocol, error) {
+	var order []Protocol
+	for _, name := range names {
+		var protocol Protocol
+		switch strings.TrimSpace(name) {
+		case QUIC.String():
+			protocol = QUIC
+		case HTTP2.String():
+			protocol = HTTP2
+		case "h2mux":
+			log.Warn().Msg("h2mux is no longer a supported protocol: http2 is used in its place in the protocol fallback order.")
+			protocol = HTTP2
+		default:
+			return nil, fmt.Errorf("unknown protocol %q in the protocol fallback order, the protocols are %s and %s", name, QUIC, HTTP2)
+		}
+		if protocolIndex(order, protocol) >= 0 {
+			continue
+		}
+		order = append(order, protocol)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("the protocol fallback order is empty")
+	}
+	return order, nil
+}
+
+// ProtocolMix assigns protocols to HA connections by their index, so that a connector keeps connections with several
+// protocols, e.g. 2 quic connections and 2 http2 ones, to hedge against network paths that block UDP.
+type ProtocolMix []Protocol
+
+// ParseProtocolMix parses a mix of protocol:count entries, e.g. quic:2 and http2:2. Connections are assigned the
+// protocols in the order of the entries.
+func ParseProtocolMix(entries []string) (ProtocolMix, error) {
+	var mix ProtocolMix
+	for _, entry := range entries {
+		name, countStr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("%q of the HA protocol mix isn't a protocol:count entry", entry)
+		}
+		var protocol Protocol
+		switch name {
+		case QUIC.String():
+			protocol = QUIC
+		case HTTP2.String():
+			protocol = HTTP2
+		default:
+			return nil, fmt.Errorf("unknown protocol %q in the HA protocol mix, the protocols are %s and %s", name, QUIC, HTTP2)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("the count of %q of the HA protocol mix must be a positive number", entry)
+		}
+		for i := 0; i < count; i++ {
+			mix = append(mix, protocol)
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("the HA protocol mix is empty")
+	}
+	return mix, nil
+}
+
+// Protocol returns the protocol the mix assigns to the connection with the given index, if any.
+func (m ProtocolMix) Protocol(connIndex int) (Protocol, bool) {
+	if connIndex < 0 || connIndex >= len(m) {
+		return 0, false
+	}
+	return m[connIndex], true
+}
+
+// Protocols returns the distinct protocols of the mix.
+func (m ProtocolMix) Protocols() []Protocol {
+	var protocols []Protocol
+	for _, p := range m {
+		if protocolIndex(protocols, p) < 0 {
+			protocols = append(protocols, p)
+		}
+	}
+	return protocols
+}
+
+func (m ProtocolMix) String() string {
+	var entries []string
+	for i := 0; i < len(m); {
+		count := 1
+		for i+count < len(m) && m[i+count] == m[i] {
+			count++
+		}
+		entries = append(entries, fmt.Sprintf("%s:%d", m[i], count))
+		i += count
+	}
+	return strings.Join(entries, ",")
+}
+
+func protocolIndex(protocols []Protocol, protocol Protocol) int {
+	for i, p := range protocols {
+		if p == protocol {
+			return i
+		}
+	}
+	return -1
 }
 
 // staticProtocolSelector will not provide a different protocol for Fallback
@@ -101,6 +222,39 @@ func (s *staticProtocolSelector) Fallback() (Protocol, bool) {
 	return s.current, false
 }
 
+func (s *staticProtocolSelector) FallbackFrom(protocol Protocol) (Protocol, bool) {
+	return protocol, false
+}
+
+// orderedProtocolSelector falls back through the protocols of a fallback policy in their order
+type orderedProtocolSelector struct {
+	order []Protocol
+}
+
+// NewOrderedProtocolSelector returns a selector that starts with the first protocol of order, and falls back through
+// the others in turn.
+func NewOrderedProtocolSelector(order []Protocol) ProtocolSelector {
+	return &orderedProtocolSelector{
+		order: order,
+	}
+}
+
+func (s *orderedProtocolSelector) Current() Protocol {
+	return s.order[0]
+}
+
+func (s *orderedProtocolSelector) Fallback() (Protocol, bool) {
+	return s.FallbackFrom(s.order[0])
+}
+
+func (s *orderedProtocolSelector) FallbackFrom(protocol Protocol) (Protocol, bool) {
+	i := protocolIndex(s.order, protocol)
+	if i < 0 || i == len(s.order)-1 {
+		return protocol, false
+	}
+	return s.order[i+1], true
+}
+
 // remoteProtocolSelector will fetch a list of remote protocols to provide for edge discovery
 type remoteProtocolSelector struct {
 	lock sync.RWMutex
@@ -160,6 +314,10 @@ func (s *remoteProtocolSelector) Fallback() (Protocol, bool) {
 	return s.current.fallback()
 }
 
+func (s *remoteProtocolSelector) FallbackFrom(protocol Protocol) (Protocol, bool) {
+	return protocol.fallback()
+}
+
 func getProtocol(protocolPool []Protocol, fetchFunc edgediscovery.PercentageFetcher, switchThreshold int32) (Protocol, error) {
 	protocolPercentages, err := fetchFunc()
 	if err != nil {
@@ -202,6 +360,10 @@ func (s *defaultProtocolSelector) Fallback() (Protocol, bool) {
 	return s.current.fallback()
 }
 
+func (s *defaultProtocolSelector) FallbackFrom(protocol Protocol) (Protocol, bool) {
+	return protocol.fallback()
+}
+
 func NewProtocolSelector(
 	protocolFlag string,
 	accountTag string,
@@ -171,3 +171,54 @@ func TestAutoProtocolSelectorNoRefreshWithToken(t *testing.T) {
 	fetcher.protocolPercents = edgediscovery.ProtocolPercents{edgediscovery.ProtocolPercent{Protocol: "http2", Percentage: 100}}
 	assert.Equal(t, QUIC, selector.Current())
 }
+
+func TestParseProtocolFallbackOrder(t *testing.T) {
+	order, err := ParseProtocolFallbackOrder([]string{"quic", "http2"}, &log)
+	assert.NoError(t, err)
+	assert.Equal(t, []Protocol{QUIC, HTTP2}, order)
+
+	// h2mux is replaced by http2, which is only tried once
+	order, err = ParseProtocolFallbackOrder([]string{"http2", "h2mux", "quic"}, &log)
+	assert.NoError(t, err)
+	assert.Equal(t, []Protocol{HTTP2, QUIC}, order)
+
+	_, err = ParseProtocolFallbackOrder([]string{"quic", "websocket"}, &log)
+	assert.Error(t, err)
+	_, err = ParseProtocolFallbackOrder(nil, &log)
+	assert.Error(t, err)
+}
+
+func TestOrderedProtocolSelector(t *testing.T) {
+	selector := NewOrderedProtocolSelector([]Protocol{HTTP2, QUIC})
+	assert.Equal(t, HTTP2, selector.Current())
+
+	fallback, ok := selector.Fallback()
+	assert.True(t, ok)
+	assert.Equal(t, QUIC, fallback)
+
+	fallback, ok = selector.FallbackFrom(HTTP2)
+	assert.True(t, ok)
+	assert.Equal(t, QUIC, fallback)
+
+	_, ok = selector.FallbackFrom(QUIC)
+	assert.False(t, ok)
+}
+
+func TestParseProtocolMix(t *testing.T) {
+	mix, err := ParseProtocolMix([]string{"quic:2", " http2:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolMix{QUIC, QUIC, HTTP2}, mix)
+	assert.Equal(t, "quic:2,http2:1", mix.String())
+	assert.Equal(t, []Protocol{QUIC, HTTP2}, mix.Protocols())
+
+	protocol, ok := mix.Protocol(2)
+	assert.True(t, ok)
+	assert.Equal(t, HTTP2, protocol)
+	_, ok = mix.Protocol(3)
+	assert.False(t, ok)
+
+	for _, entries := range [][]string{nil, {"quic"}, {"quic:0"}, {"quic:two"}, {"h2mux:2"}} {
+		_, err = ParseProtocolMix(entries)
+		assert.Error(t, err, entries)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"net/netip"
+	"net/textproto"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/otel/attribute"
@@ -48,8 +50,31 @@ const (
 var (
 	portForConnIndex = make(map[uint8]int, 0)
 	portMapMutex     sync.Mutex
+
+	droppedTrailers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "dropped_trailers_total",
+			Help:      "Number of response trailers that couldn't be forwarded because QUIC connections don't support them",
+		},
+	)
+	droppedInterimResponses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: TunnelSubsystem,
+			Name:      "dropped_interim_responses_total",
+			Help:      "Number of interim responses that couldn't be forwarded because QUIC connections don't support them",
+		},
+	)
+	// grpcTrailersWarned is set once the trailers of a gRPC response have been dropped and logged as a warning
+	grpcTrailersWarned atomic.Bool
 )
 
+func init() {
+	prometheus.MustRegister(droppedTrailers, droppedInterimResponses)
+}
+
 // QUICConnection represents the type that facilitates Proxying via QUIC streams.
 type QUICConnection struct {
 	session      quic.Connection
@@ -63,6 +88,7 @@ type QUICConnection struct {
 	controlStreamHandler ControlStreamHandler
 	connOptions          *tunnelpogs.ConnectionOptions
 	connIndex            uint8
+	drainer              *Drainer
 
 	udpUnregisterTimeout time.Duration
 }
@@ -81,13 +107,15 @@ func NewQUICConnection(
 	logger *zerolog.Logger,
 	packetRouterConfig *ingress.GlobalRouterConfig,
 	udpUnregisterTimeout time.Duration,
+	drainer *Drainer,
 ) (*QUICConnection, error) {
 	udpConn, err := createUDPConnForConnIndex(connIndex, localAddr, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	session, err := quic.Dial(ctx, udpConn, edgeAddr, tlsConfig, quicConfig)
+	pathMTU := quicpogs.NewPathMTU(connIndex)
+	session, err := quic.Dial(ctx, udpConn, edgeAddr, tlsConfig, pathMTU.TraceConnection(quicConfig))
 	if err != nil {
 		// close the udp server socket in case of error connecting to the edge
 		udpConn.Close()
@@ -101,7 +129,7 @@ func NewQUICConnection(
 	}
 
 	sessionDemuxChan := make(chan *packet.Session, demuxChanCapacity)
-	datagramMuxer := quicpogs.NewDatagramMuxerV2(session, logger, sessionDemuxChan)
+	datagramMuxer := quicpogs.NewDatagramMuxerV2(session, pathMTU, logger, sessionDemuxChan)
 	sessionManager := datagramsession.NewManager(logger, datagramMuxer.SendToSession, sessionDemuxChan)
 	packetRouter := ingress.NewPacketRouter(packetRouterConfig, datagramMuxer, logger, orchestrator.WarpRoutingEnabled)
 
@@ -116,6 +144,7 @@ func NewQUICConnection(
 		connOptions:          connOptions,
 		connIndex:            connIndex,
 		udpUnregisterTimeout: udpUnregisterTimeout,
+		drainer:              drainer,
 	}, nil
 }
 
@@ -272,7 +301,7 @@ func (q *QUICConnection) dispatchRequest(ctx context.Context, stream *quicpogs.R
 		if err != nil {
 			return err, false
 		}
-		w := newHTTPResponseAdapter(stream)
+		w := newHTTPResponseAdapter(stream, q.logger)
 		return originProxy.ProxyHTTP(&w, tracedReq, request.Type == quicpogs.ConnectionTypeWebsocket), w.connectResponseSent
 
 	case quicpogs.ConnectionTypeTCP:
@@ -297,22 +326,43 @@ func (q *QUICConnection) handleRPCStream(rpcStream *quicpogs.RPCServerStream) er
 	return nil
 }
 
-// RegisterUdpSession is the RPC method invoked by edge to register and run a session
-func (q *QUICConnection) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext string) (*tunnelpogs.RegisterUdpSessionResponse, error) {
+// UDPOriginProxy is implemented by origin proxies that take the UDP sessions of the hostnames of their ingress rules.
+type UDPOriginProxy interface {
+	// DialUDP opens the socket of a session to the origin of hostname. It also returns the idle timeout the ingress
+	// rule sets for the session, or 0 to go with the one the edge asks for.
+	DialUDP(hostname string) (ingress.UDPProxy, time.Duration, error)
+}
+
+// RegisterUdpSession is the RPC method invoked by edge to register and run a session. Sessions with a hostname go to
+// the UDP origin service of its ingress rule, the others to dstIP:dstPort. The max datagram size of the session is the
+// smallest of the one the edge asks for and the one that fits the path, and it's sent back in the response.
+func (q *QUICConnection) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext, hostname string, maxDatagramSize uint16) (*tunnelpogs.RegisterUdpSessionResponse, error) {
+	dst := fmt.Sprintf("%s:%d", dstIP, dstPort)
+	if hostname != "" {
+		dst = hostname
+	}
 	traceCtx := tracing.NewTracedContext(ctx, traceContext, q.logger)
 	ctx, registerSpan := traceCtx.Tracer().Start(traceCtx, "register-session", trace.WithAttributes(
 		attribute.String("session-id", sessionID.String()),
-		attribute.String("dst", fmt.Sprintf("%s:%d", dstIP, dstPort)),
+		attribute.String("dst", dst),
 	))
 	log := q.logger.With().Int(management.EventTypeKey, int(management.UDP)).Logger()
-	// Each session is a series of datagram from an eyeball to a dstIP:dstPort.
-	// (src port, dst IP, dst port) uniquely identifies a session, so it needs a dedicated connected socket.
-	originProxy, err := ingress.DialUDP(dstIP, dstPort)
+	done, ok := q.drainer.Track(InFlightUDPSession)
+	if !ok {
+		err := errors.New("cloudflared is shutting down and rejects new UDP sessions")
+		tracing.EndWithErrorStatus(registerSpan, err)
+		return nil, err
+	}
+	originProxy, closeAfterIdle, err := q.dialUDP(dstIP, dstPort, hostname)
 	if err != nil {
-		log.Err(err).Msgf("Failed to create udp proxy to %s:%d", dstIP, dstPort)
+		done()
+		log.Err(err).Msgf("Failed to create udp proxy to %s", dst)
 		tracing.EndWithErrorStatus(registerSpan, err)
 		return nil, err
 	}
+	if closeAfterIdle > 0 {
+		closeAfterIdleHint = closeAfterIdle
+	}
 	registerSpan.SetAttributes(
 		attribute.Bool("socket-bind-success", true),
 		attribute.String("src", originProxy.LocalAddr().String()),
@@ -320,30 +370,68 @@ func (q *QUICConnection) RegisterUdpSession(ctx context.Context, sessionID uuid.
 
 	session, err := q.sessionManager.RegisterSession(ctx, sessionID, originProxy)
 	if err != nil {
+		done()
+		_ = originProxy.Close()
 		log.Err(err).Str("sessionID", sessionID.String()).Msgf("Failed to register udp session")
 		tracing.EndWithErrorStatus(registerSpan, err)
 		return nil, err
 	}
 
-	go q.serveUDPSession(session, closeAfterIdleHint)
+	sessionMaxDatagramSize := q.sessionMaxDatagramSize(maxDatagramSize)
+	go func() {
+		defer done()
+		q.serveUDPSession(session, closeAfterIdleHint, sessionMaxDatagramSize)
+	}()
 
 	log.Debug().
 		Str("sessionID", sessionID.String()).
 		Str("src", originProxy.LocalAddr().String()).
-		Str("dst", fmt.Sprintf("%s:%d", dstIP, dstPort)).
+		Str("dst", dst).
+		Int("maxDatagramSize", sessionMaxDatagramSize).
 		Msgf("Registered session")
+	registerSpan.SetAttributes(attribute.Int("max-datagram-size", sessionMaxDatagramSize))
 	tracing.End(registerSpan)
 
 	resp := tunnelpogs.RegisterUdpSessionResponse{
-		Spans: traceCtx.GetProtoSpans(),
+		Spans:           traceCtx.GetProtoSpans(),
+		MaxDatagramSize: uint16(sessionMaxDatagramSize),
 	}
 
 	return &resp, nil
 }
 
-func (q *QUICConnection) serveUDPSession(session *datagramsession.Session, closeAfterIdleHint time.Duration) {
+// dialUDP opens the socket of a session to the UDP origin service of hostname, or to dstIP:dstPort when hostname is
+// empty. It also returns the idle timeout of the ingress rule of hostname, if it sets one.
+func (q *QUICConnection) dialUDP(dstIP net.IP, dstPort uint16, hostname string) (ingress.UDPProxy, time.Duration, error) {
+	if hostname == "" {
+		// Each session is a series of datagram from an eyeball to a dstIP:dstPort.
+		// (src port, dst IP, dst port) uniquely identifies a session, so it needs a dedicated connected socket.
+		originProxy, err := ingress.DialUDP(dstIP, dstPort)
+		return originProxy, 0, err
+	}
+	originProxy, err := q.orchestrator.GetOriginProxy()
+	if err != nil {
+		return nil, 0, err
+	}
+	udpOriginProxy, ok := originProxy.(UDPOriginProxy)
+	if !ok {
+		return nil, 0, fmt.Errorf("UDP sessions to %s aren't supported", hostname)
+	}
+	return udpOriginProxy.DialUDP(hostname)
+}
+
+// sessionMaxDatagramSize negotiates the max datagram size of a session with the one the edge asks for, 0 if it doesn't.
+func (q *QUICConnection) sessionMaxDatagramSize(edgeMaxDatagramSize uint16) int {
+	size := q.datagramMuxer.MaxSessionPayloadSize()
+	if edgeMaxDatagramSize > 0 && int(edgeMaxDatagramSize) < size {
+		size = int(edgeMaxDatagramSize)
+	}
+	return size
+}
+
+func (q *QUICConnection) serveUDPSession(session *datagramsession.Session, closeAfterIdleHint time.Duration, maxDatagramSize int) {
 	ctx := q.session.Context()
-	closedByRemote, err := session.Serve(ctx, closeAfterIdleHint)
+	closedByRemote, err := session.Serve(ctx, closeAfterIdleHint, maxDatagramSize)
 	// If session is terminated by remote, then we know it has been unregistered from session manager and edge
 	if !closedByRemote {
 		if err != nil {
@@ -426,20 +514,37 @@ type httpResponseAdapter struct {
 	*quicpogs.RequestServerStream
 	headers             http.Header
 	connectResponseSent bool
+	// grpc is set when the response is a gRPC response
+	grpc bool
+	log  *zerolog.Logger
 }
 
-func newHTTPResponseAdapter(s *quicpogs.RequestServerStream) httpResponseAdapter {
-	return httpResponseAdapter{RequestServerStream: s, headers: make(http.Header)}
+func newHTTPResponseAdapter(s *quicpogs.RequestServerStream, log *zerolog.Logger) httpResponseAdapter {
+	return httpResponseAdapter{RequestServerStream: s, headers: make(http.Header), log: log}
 }
 
+// AddTrailer drops the trailer: the response metadata is sent once, before the body, so QUIC connections can't carry
+// trailers. Ingress rules to grpc:// and grpcs:// origins are rejected unless every connection uses http2, but other
+// origins can still send trailers.
 func (hrw *httpResponseAdapter) AddTrailer(trailerName, trailerValue string) {
-	// we do not support trailers over QUIC
+	droppedTrailers.Inc()
+	// gRPC clients fail the calls that end without a grpc-status trailer, so that's worth more than a debug log
+	if hrw.grpc && grpcTrailersWarned.CompareAndSwap(false, true) {
+		hrw.log.Warn().Str("trailer", trailerName).Msg("Dropped the trailers of a gRPC response, which gRPC clients need: run the tunnel with --protocol http2 to proxy gRPC services")
+		return
+	}
+	hrw.log.Debug().Str("trailer", trailerName).Msg("Dropped a response trailer, trailers are only supported by the http2 protocol")
 }
 
 func (hrw *httpResponseAdapter) WriteRespHeaders(status int, header http.Header) error {
+	hrw.grpc = IsGRPC(header)
 	metadata := make([]quicpogs.Metadata, 0)
 	metadata = append(metadata, quicpogs.Metadata{Key: "HttpStatus", Val: strconv.Itoa(status)})
 	for k, vv := range header {
+		// The trailers announced by the origin are dropped, so they aren't announced to the eyeball
+		if k == "Trailer" {
+			continue
+		}
 		for _, v := range vv {
 			httpHeaderKey := fmt.Sprintf("%s:%s", HTTPHeaderKey, k)
 			metadata = append(metadata, quicpogs.Metadata{Key: httpHeaderKey, Val: v})
@@ -449,6 +554,17 @@ func (hrw *httpResponseAdapter) WriteRespHeaders(status int, header http.Header)
 	return hrw.WriteConnectResponseData(nil, metadata...)
 }
 
+// WriteInterimResponse drops the interim response: the response metadata is sent once, so QUIC connections can only
+// carry the final response. Interim responses are hints that eyeballs don't depend on.
+func (hrw *httpResponseAdapter) WriteInterimResponse(status int, header http.Header) error {
+	if err := validateInterimResponse(status, hrw.connectResponseSent); err != nil {
+		return err
+	}
+	droppedInterimResponses.Inc()
+	hrw.log.Debug().Int("status", status).Msg("Dropped an interim response, interim responses are only supported by the http2 protocol")
+	return nil
+}
+
 func (hrw *httpResponseAdapter) Header() http.Header {
 	return hrw.headers
 }
@@ -482,10 +598,21 @@ func buildHTTPRequest(
 	connIndex uint8,
 	log *zerolog.Logger,
 ) (*tracing.TracedHTTPRequest, error) {
-	metadata := connectRequest.MetadataMap()
+	var method, host string
+	numHeaders := 0
+	for _, metadata := range connectRequest.Metadata {
+		switch metadata.Key {
+		case HTTPMethodKey:
+			method = metadata.Val
+		case HTTPHostKey:
+			host = metadata.Val
+		default:
+			if strings.Contains(metadata.Key, HTTPHeaderKey) {
+				numHeaders++
+			}
+		}
+	}
 	dest := connectRequest.Dest
-	method := metadata[HTTPMethodKey]
-	host := metadata[HTTPHostKey]
 	isWebsocket := connectRequest.Type == quicpogs.ConnectionTypeWebsocket
 
 	req, err := http.NewRequestWithContext(ctx, method, dest, body)
@@ -494,14 +621,24 @@ func buildHTTPRequest(
 	}
 
 	req.Host = host
+	// The header map is sized up front so it doesn't have to grow while the headers are added
+	req.Header = make(http.Header, numHeaders)
+	// The values of all headers share one backing array, instead of allocating a slice for each header
+	values := make([]string, 0, numHeaders)
 	for _, metadata := range connectRequest.Metadata {
 		if strings.Contains(metadata.Key, HTTPHeaderKey) {
 			// metadata.Key is off the format httpHeaderKey:<HTTPHeader>
-			httpHeaderKey := strings.Split(metadata.Key, ":")
-			if len(httpHeaderKey) != 2 {
+			_, name, ok := strings.Cut(metadata.Key, ":")
+			if !ok || strings.Contains(name, ":") {
 				return nil, fmt.Errorf("header Key: %s malformed", metadata.Key)
 			}
-			req.Header.Add(httpHeaderKey[1], metadata.Val)
+			name = textproto.CanonicalMIMEHeaderKey(name)
+			if existing, ok := req.Header[name]; ok {
+				req.Header[name] = append(existing, metadata.Val)
+				continue
+			}
+			values = append(values, metadata.Val)
+			req.Header[name] = values[len(values)-1 : len(values) : len(values)]
 		}
 	}
 	// Go's http.Client automatically sends chunked request body if this value is not set on the
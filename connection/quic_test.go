@@ -497,6 +497,47 @@ func (moc *mockOriginProxyWithRequest) ProxyTCP(ctx context.Context, rwa ReadWri
 	return nil
 }
 
+func TestHTTPResponseAdapterDropsTrailers(t *testing.T) {
+	stream := &bufferStream{}
+	log := zerolog.Nop()
+	w := newHTTPResponseAdapter(&quicpogs.RequestServerStream{ReadWriteCloser: stream}, &log)
+	header := http.Header{"Content-Type": {"application/grpc"}, "Trailer": {"Grpc-Status", "Grpc-Message"}}
+	require.NoError(t, w.WriteRespHeaders(http.StatusOK, header))
+	w.AddTrailer("Grpc-Status", "0")
+
+	resp, err := (&quicpogs.RequestClientStream{ReadWriteCloser: stream}).ReadConnectResponseData()
+	require.NoError(t, err)
+	assert.Equal(t, []quicpogs.Metadata{
+		{Key: "HttpStatus", Val: "200"},
+		{Key: "HttpHeader:Content-Type", Val: "application/grpc"},
+	}, resp.Metadata)
+}
+
+func TestHTTPResponseAdapterDropsInterimResponses(t *testing.T) {
+	stream := &bufferStream{}
+	log := zerolog.Nop()
+	w := newHTTPResponseAdapter(&quicpogs.RequestServerStream{ReadWriteCloser: stream}, &log)
+	require.NoError(t, w.WriteInterimResponse(http.StatusEarlyHints, http.Header{"Link": {"</style.css>; rel=preload"}}))
+	assert.Error(t, w.WriteInterimResponse(http.StatusOK, nil))
+	require.NoError(t, w.WriteRespHeaders(http.StatusOK, http.Header{"Content-Type": {"text/html"}}))
+	assert.Error(t, w.WriteInterimResponse(http.StatusEarlyHints, nil))
+
+	resp, err := (&quicpogs.RequestClientStream{ReadWriteCloser: stream}).ReadConnectResponseData()
+	require.NoError(t, err)
+	assert.Equal(t, []quicpogs.Metadata{
+		{Key: "HttpStatus", Val: "200"},
+		{Key: "HttpHeader:Content-Type", Val: "text/html"},
+	}, resp.Metadata)
+}
+
+type bufferStream struct {
+	bytes.Buffer
+}
+
+func (*bufferStream) Close() error {
+	return nil
+}
+
 func TestServeUDPSession(t *testing.T) {
 	// Start a UDP Listener for QUIC.
 	udpAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
@@ -611,7 +652,7 @@ func serveSession(ctx context.Context, qc *QUICConnection, edgeQUICSession quic.
 
 	sessionDone := make(chan struct{})
 	go func() {
-		qc.serveUDPSession(session, time.Millisecond*50)
+		qc.serveUDPSession(session, time.Millisecond*50, 0)
 		close(sessionDone)
 	}()
 
@@ -689,7 +730,7 @@ type mockSessionRPCServer struct {
 	calledUnregisterChan chan struct{}
 }
 
-func (s mockSessionRPCServer) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeIdleAfter time.Duration, traceContext string) (*pogs.RegisterUdpSessionResponse, error) {
+func (s mockSessionRPCServer) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeIdleAfter time.Duration, traceContext, hostname string, maxDatagramSize uint16) (*pogs.RegisterUdpSessionResponse, error) {
 	return nil, fmt.Errorf("mockSessionRPCServer doesn't implement RegisterUdpSession")
 }
 
@@ -726,6 +767,7 @@ func testQUICConnection(udpListenerAddr net.Addr, t *testing.T, index uint8) *QU
 		&log,
 		nil,
 		5*time.Second,
+		nil,
 	)
 	require.NoError(t, err)
 	return qc
@@ -742,3 +784,29 @@ func (m *mockReaderNoopWriter) Write(p []byte) (n int, err error) {
 func (m *mockReaderNoopWriter) Close() error {
 	return nil
 }
+
+func BenchmarkBuildHTTPRequest(b *testing.B) {
+	connectRequest := &quicpogs.ConnectRequest{
+		Dest: "http://test.com/path?query=value",
+		Type: quicpogs.ConnectionTypeHTTP,
+		Metadata: []quicpogs.Metadata{
+			{Key: "HttpHeader:Accept", Val: "text/html"},
+			{Key: "HttpHeader:Accept-Encoding", Val: "gzip"},
+			{Key: "HttpHeader:Cf-Connecting-Ip", Val: "198.51.100.7"},
+			{Key: "HttpHeader:Cf-Ray", Val: "7d6b1a2b3c4d5e6f-LHR"},
+			{Key: "HttpHeader:Cookie", Val: "session=abc"},
+			{Key: "HttpHeader:User-Agent", Val: "curl/8.0"},
+			{Key: "HttpHeader:X-Forwarded-Proto", Val: "https"},
+			{Key: "HttpHost", Val: "test.com"},
+			{Key: "HttpMethod", Val: "GET"},
+		},
+	}
+	log := zerolog.Nop()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := buildHTTPRequest(context.Background(), connectRequest, http.NoBody, 0, &log); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package connection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	quicpogs "github.com/cloudflare/cloudflared/quic"
+)
+
+// defaultMaxStreamReceiveWindow is the maximum stream flow control window of quic-go.
+const defaultMaxStreamReceiveWindow = 6 << 20
+
+// QUICTransportConfig tunes the transport parameters of QUIC connections to the edge. Zero values keep the defaults,
+// which suit most links. Links with long round trips, like satellite and mobile links, may need longer timeouts and
+// larger flow control windows.
+type QUICTransportConfig struct {
+	// MaxIdleTimeout is how long a connection can go without receiving anything before it's closed.
+	MaxIdleTimeout time.Duration
+	// KeepAlivePeriod is how often a connection is pinged to keep it alive. It must be shorter than MaxIdleTimeout.
+	KeepAlivePeriod time.Duration
+	// InitialStreamReceiveWindow and MaxStreamReceiveWindow are the bytes a stream may receive before the edge has to
+	// wait for them to be read. The window starts at the initial size, and grows up to the maximum as needed.
+	InitialStreamReceiveWindow uint64
+	MaxStreamReceiveWindow     uint64
+	// MaxIncomingStreams is how many streams the edge may open at once on a connection.
+	MaxIncomingStreams int64
+}
+
+// Validate reports settings that can't work together.
+func (c QUICTransportConfig) Validate() error {
+	if c.MaxIdleTimeout < 0 || c.KeepAlivePeriod < 0 || c.MaxIncomingStreams < 0 {
+		return fmt.Errorf("QUIC transport parameters can't be negative")
+	}
+	idleTimeout := c.MaxIdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = quicpogs.MaxIdleTimeout
+	}
+	if c.KeepAlivePeriod >= idleTimeout {
+		return fmt.Errorf("the QUIC keepalive period %s must be shorter than the idle timeout %s", c.KeepAlivePeriod, idleTimeout)
+	}
+	maxStreamWindow := c.MaxStreamReceiveWindow
+	if maxStreamWindow == 0 {
+		maxStreamWindow = defaultMaxStreamReceiveWindow
+	}
+	if c.InitialStreamReceiveWindow > maxStreamWindow {
+		return fmt.Errorf("the initial QUIC stream window of %d bytes is larger than its maximum of %d bytes", c.InitialStreamReceiveWindow, maxStreamWindow)
+	}
+	return nil
+}
+
+// Apply overrides the transport parameters of config that are set in c.
+func (c QUICTransportConfig) Apply(config *quic.Config) {
+	if c.MaxIdleTimeout > 0 {
+		config.MaxIdleTimeout = c.MaxIdleTimeout
+		// The keepalive period of the defaults may not fit the new idle timeout
+		if c.KeepAlivePeriod == 0 && config.KeepAlivePeriod >= c.MaxIdleTimeout {
+			config.KeepAlivePeriod = c.MaxIdleTimeout / 2
+		}
+	}
+	if c.KeepAlivePeriod > 0 {
+		config.KeepAlivePeriod = c.KeepAlivePeriod
+	}
+	if c.InitialStreamReceiveWindow > 0 {
+		config.InitialStreamReceiveWindow = c.InitialStreamReceiveWindow
+		// A connection window smaller than the window of one of its streams would hold the stream back
+		if config.InitialConnectionReceiveWindow < c.InitialStreamReceiveWindow {
+			config.InitialConnectionReceiveWindow = c.InitialStreamReceiveWindow
+		}
+	}
+	if c.MaxStreamReceiveWindow > 0 {
+		config.MaxStreamReceiveWindow = c.MaxStreamReceiveWindow
+		if config.MaxConnectionReceiveWindow < c.MaxStreamReceiveWindow {
+			config.MaxConnectionReceiveWindow = c.MaxStreamReceiveWindow
+		}
+	}
+	if c.MaxIncomingStreams > 0 {
+		config.MaxIncomingStreams = c.MaxIncomingStreams
+		config.MaxIncomingUniStreams = c.MaxIncomingStreams
+	}
+}
@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQUICTransportConfigValidate(t *testing.T) {
+	valid := []QUICTransportConfig{
+		{},
+		{MaxIdleTimeout: time.Minute, KeepAlivePeriod: 10 * time.Second},
+		{InitialStreamReceiveWindow: 1 << 20, MaxStreamReceiveWindow: 32 << 20},
+		{MaxIncomingStreams: 1000},
+	}
+	for _, c := range valid {
+		assert.NoError(t, c.Validate(), "%+v", c)
+	}
+
+	invalid := []QUICTransportConfig{
+		{MaxIdleTimeout: -time.Second},
+		{MaxIncomingStreams: -1},
+		{MaxIdleTimeout: 10 * time.Second, KeepAlivePeriod: 10 * time.Second},
+		// Longer than the default idle timeout
+		{KeepAlivePeriod: 10 * time.Second},
+		{InitialStreamReceiveWindow: 2 << 20, MaxStreamReceiveWindow: 1 << 20},
+		// Larger than the default maximum window
+		{InitialStreamReceiveWindow: 8 << 20},
+	}
+	for _, c := range invalid {
+		assert.Error(t, c.Validate(), "%+v", c)
+	}
+}
+
+func TestQUICTransportConfigApply(t *testing.T) {
+	defaults := func() *quic.Config {
+		return &quic.Config{
+			MaxIdleTimeout:        5 * time.Second,
+			KeepAlivePeriod:       time.Second,
+			MaxIncomingStreams:    1 << 60,
+			MaxIncomingUniStreams: 1 << 60,
+		}
+	}
+
+	config := defaults()
+	QUICTransportConfig{}.Apply(config)
+	assert.Equal(t, defaults(), config)
+
+	config = defaults()
+	QUICTransportConfig{
+		MaxIdleTimeout:             time.Minute,
+		KeepAlivePeriod:            10 * time.Second,
+		InitialStreamReceiveWindow: 1 << 20,
+		MaxStreamReceiveWindow:     32 << 20,
+		MaxIncomingStreams:         1000,
+	}.Apply(config)
+	assert.Equal(t, time.Minute, config.MaxIdleTimeout)
+	assert.Equal(t, 10*time.Second, config.KeepAlivePeriod)
+	assert.Equal(t, uint64(1<<20), config.InitialStreamReceiveWindow)
+	assert.Equal(t, uint64(32<<20), config.MaxStreamReceiveWindow)
+	// The connection windows are never smaller than the windows of its streams
+	assert.Equal(t, uint64(1<<20), config.InitialConnectionReceiveWindow)
+	assert.Equal(t, uint64(32<<20), config.MaxConnectionReceiveWindow)
+	assert.Equal(t, int64(1000), config.MaxIncomingStreams)
+	assert.Equal(t, int64(1000), config.MaxIncomingUniStreams)
+
+	// The default keepalive period is shortened to fit a shorter idle timeout
+	config = defaults()
+	config.KeepAlivePeriod = 10 * time.Second
+	QUICTransportConfig{MaxIdleTimeout: 4 * time.Second}.Apply(config)
+	require.Equal(t, 4*time.Second, config.MaxIdleTimeout)
+	assert.Equal(t, 2*time.Second, config.KeepAlivePeriod)
+}
@@ -3,6 +3,7 @@ package credentials
 import (
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,6 +19,20 @@ const (
 	OriginCertFlag        = "origincert"
 )
 
+// ErrOriginCertNotFound matches the errors returned when there is no origin certificate at all, as opposed to one
+// that can't be read or decoded.
+var ErrOriginCertNotFound = errors.New("origin certificate not found")
+
+type originCertNotFoundError string
+
+func (e originCertNotFoundError) Error() string {
+	return string(e)
+}
+
+func (e originCertNotFoundError) Is(target error) bool {
+	return target == ErrOriginCertNotFound
+}
+
 type namedTunnelToken struct {
 	ZoneID    string `json:"zoneID"`
 	AccountID string `json:"accountID"`
@@ -94,7 +109,7 @@ func readOriginCert(originCertPath string) ([]byte, error) {
 func FindOriginCert(originCertPath string, log *zerolog.Logger) (string, error) {
 	if originCertPath == "" {
 		log.Error().Msgf("Cannot determine default origin certificate path. No file %s in %v. You need to specify the origin certificate path by specifying the origincert option in the configuration file, or set TUNNEL_ORIGIN_CERT environment variable", DefaultCredentialFile, config.DefaultConfigSearchDirectories())
-		return "", fmt.Errorf("client didn't specify origincert path")
+		return "", originCertNotFoundError("client didn't specify origincert path")
 	}
 	var err error
 	originCertPath, err = homedir.Expand(originCertPath)
@@ -114,7 +129,7 @@ If you don't have a certificate signed by Cloudflare, run the command:
 
 	cloudflared login
 `, originCertPath)
-		return "", fmt.Errorf("cannot find a valid certificate at the path %s", originCertPath)
+		return "", originCertNotFoundError(fmt.Sprintf("cannot find a valid certificate at the path %s", originCertPath))
 	}
 
 	return originCertPath, nil
@@ -107,4 +107,12 @@ func TestFindOriginCert_Missing(t *testing.T) {
 	certPath := path.Join(dir, originCertFile)
 	_, err := FindOriginCert(certPath, &nopLog)
 	require.Error(t, err)
+	require.ErrorIs(t, err, ErrOriginCertNotFound)
+
+	// Read wraps the error, it can still be told apart from an invalid certificate
+	_, err = Read(certPath, &nopLog)
+	require.ErrorIs(t, err, ErrOriginCertNotFound)
+	_, err = Read("test-cert-no-token.pem", &nopLog)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrOriginCertNotFound)
 }
@@ -84,7 +84,7 @@ func TestManagerServe(t *testing.T) {
 
 			sessionDone := make(chan struct{})
 			go func() {
-				closedByRemote, err := session.Serve(ctx, time.Minute*2)
+				closedByRemote, err := session.Serve(ctx, time.Minute*2, 0)
 				closeSession := &errClosedSession{
 					message:  remoteUnregisterMsg,
 					byRemote: true,
@@ -156,7 +156,7 @@ func TestUnregisterSessionCloseSession(t *testing.T) {
 		close(unregisteredChan)
 	}()
 
-	closedByRemote, err := session.Serve(ctx, time.Minute)
+	closedByRemote, err := session.Serve(ctx, time.Minute, 0)
 	require.True(t, closedByRemote)
 	require.Error(t, err)
 
@@ -193,7 +193,7 @@ func TestManagerCtxDoneCloseSessions(t *testing.T) {
 		cancel()
 	}()
 
-	closedByRemote, err := session.Serve(ctx, time.Minute)
+	closedByRemote, err := session.Serve(ctx, time.Minute, 0)
 	require.False(t, closedByRemote)
 	require.Error(t, err)
 
@@ -11,11 +11,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	"github.com/cloudflare/cloudflared/cfio"
 	"github.com/cloudflare/cloudflared/packet"
 )
 
 const (
 	defaultCloseIdleAfter = time.Second * 210
+	// maxPacketSize is the size of the read buffer of sessions that leave the max datagram size to the transport
+	maxPacketSize = 1500
 )
 
 func SessionIdleErr(timeout time.Duration) error {
@@ -49,14 +52,26 @@ type Session struct {
 	log          *zerolog.Logger
 }
 
-func (s *Session) Serve(ctx context.Context, closeAfterIdle time.Duration) (closedByRemote bool, err error) {
+// Serve proxies the datagrams of the session until it's idle for closeAfterIdle, closed or ctx is done. Datagrams from
+// the destination with a payload larger than maxDatagramSize are dropped, 0 leaves the limit to the transport.
+func (s *Session) Serve(ctx context.Context, closeAfterIdle time.Duration, maxDatagramSize int) (closedByRemote bool, err error) {
 	go func() {
 		// QUIC implementation copies data to another buffer before returning https://github.com/quic-go/quic-go/blob/v0.24.0/session.go#L1967-L1975
 		// This makes it safe to share readBuffer between iterations
-		const maxPacketSize = 1500
-		readBuffer := make([]byte, maxPacketSize)
+		bufferSize := maxPacketSize
+		if maxDatagramSize > 0 {
+			// One more byte tells datagrams that are too large from the ones that just fit, reads truncate the rest
+			bufferSize = maxDatagramSize + 1
+		}
+		pooled, err := cfio.GetBuffer(ctx, bufferSize)
+		if err != nil {
+			s.closeChan <- err
+			return
+		}
+		defer cfio.PutBuffer(pooled)
+		readBuffer := *pooled
 		for {
-			if closeSession, err := s.dstToTransport(readBuffer); err != nil {
+			if closeSession, err := s.dstToTransport(readBuffer, maxDatagramSize); err != nil {
 				if errors.Is(err, net.ErrClosed) {
 					s.log.Debug().Msg("Destination connection closed")
 				} else {
@@ -110,9 +125,14 @@ func (s *Session) waitForCloseCondition(ctx context.Context, closeAfterIdle time
 	}
 }
 
-func (s *Session) dstToTransport(buffer []byte) (closeSession bool, err error) {
+func (s *Session) dstToTransport(buffer []byte, maxDatagramSize int) (closeSession bool, err error) {
 	n, err := s.dstConn.Read(buffer)
 	s.markActive()
+	if maxDatagramSize > 0 && n > maxDatagramSize {
+		// The edge was told it won't get datagrams this large
+		s.log.Debug().Int("maxDatagramSize", maxDatagramSize).Msg("Dropped datagram from destination that exceeds the max datagram size of the session")
+		return err != nil, err
+	}
 	// https://pkg.go.dev/io#Reader suggests caller should always process n > 0 bytes
 	if n > 0 || err == nil {
 		session := packet.Session{
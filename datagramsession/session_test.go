@@ -51,7 +51,7 @@ func testSessionReturns(t *testing.T, closeBy closeMethod, closeAfterIdle time.D
 	ctx, cancel := context.WithCancel(context.Background())
 	sessionDone := make(chan struct{})
 	go func() {
-		closedByRemote, err := session.Serve(ctx, closeAfterIdle)
+		closedByRemote, err := session.Serve(ctx, closeAfterIdle, 0)
 		switch closeBy {
 		case closeByContext:
 			require.Equal(t, context.Canceled, err)
@@ -128,7 +128,7 @@ func testActiveSessionNotClosed(t *testing.T, readFromDst bool, writeToDst bool)
 	ctx, cancel := context.WithCancel(context.Background())
 	errGroup, ctx := errgroup.WithContext(ctx)
 	errGroup.Go(func() error {
-		session.Serve(ctx, closeAfterIdle)
+		session.Serve(ctx, closeAfterIdle, 0)
 		if time.Now().Before(startTime.Add(activeTime)) {
 			return fmt.Errorf("session closed while it's still active")
 		}
@@ -212,7 +212,7 @@ func TestZeroBytePayload(t *testing.T) {
 	errGroup, ctx := errgroup.WithContext(ctx)
 	errGroup.Go(func() error {
 		// Read from underlying conn and send to transport
-		closedByRemote, err := session.Serve(ctx, time.Minute*2)
+		closedByRemote, err := session.Serve(ctx, time.Minute*2, 0)
 		require.Equal(t, context.Canceled, err)
 		require.False(t, closedByRemote)
 		return nil
@@ -231,6 +231,40 @@ func TestZeroBytePayload(t *testing.T) {
 	require.NoError(t, errGroup.Wait())
 }
 
+// Datagrams from the origin that are larger than the max datagram size of the session are dropped
+func TestMaxDatagramSize(t *testing.T) {
+	const maxDatagramSize = 10
+	origin, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer origin.Close()
+	cfdConn, err := net.DialUDP("udp", nil, origin.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+
+	sessionID := uuid.New()
+	sentChan := make(chan []byte, 2)
+	sender := func(session *packet.Session) error {
+		sentChan <- append([]byte(nil), session.Payload...)
+		return nil
+	}
+	mg := NewManager(&nopLogger, sender, nil)
+	session := mg.newSession(sessionID, cfdConn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go session.Serve(ctx, time.Minute*2, maxDatagramSize)
+
+	for _, payload := range [][]byte{make([]byte, maxDatagramSize+1), make([]byte, maxDatagramSize)} {
+		_, err := origin.WriteToUDP(payload, cfdConn.LocalAddr().(*net.UDPAddr))
+		require.NoError(t, err)
+	}
+	require.Len(t, <-sentChan, maxDatagramSize)
+	select {
+	case payload := <-sentChan:
+		t.Fatalf("unexpected datagram of %d bytes", len(payload))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 type mockTransportSender struct {
 	expectedSessionID uuid.UUID
 	expectedPayload   []byte
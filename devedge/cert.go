@@ -0,0 +1,64 @@
+package devedge
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const certValidity = 7 * 24 * time.Hour
+
+// edgeServerNames are the SNIs cloudflared uses when it dials the edge, see connection.Protocol.TLSSettings.
+var edgeServerNames = []string{"cftunnel.com", "h2.cftunnel.com", "quic.cftunnel.com"}
+
+// generateCertificate creates a self-signed certificate valid for the edge server names. The certificate is its own
+// CA, so writing its PEM encoding to a file and passing that file to `cloudflared tunnel run --cacert` is enough
+// for cloudflared to trust the dev edge.
+func generateCertificate() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "failed to generate dev edge private key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "failed to generate certificate serial number")
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"cloudflared dev edge"}, CommonName: edgeServerNames[0]},
+		DNSNames:              edgeServerNames,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "failed to create dev edge certificate")
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, errors.Wrap(err, "failed to encode dev edge private key")
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	return cert, certPEM, nil
+}
@@ -0,0 +1,236 @@
+// Package devedge implements a minimal, local stand-in for Cloudflare's edge. It accepts HTTP2 tunnel connections
+// from `cloudflared tunnel run`, serves enough of the registration RPC for them to register, and proxies HTTP
+// requests it receives locally through the registered connections. This allows ingress rules and origin behaviour to
+// be developed and tested without Internet access or a Cloudflare account.
+package devedge
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/net/http2"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+const (
+	// Location reported to cloudflared when a connection registers.
+	Location = "DEV"
+
+	LogFieldConnIndex  = "connIndex"
+	LogFieldRemoteAddr = "remoteAddr"
+)
+
+var (
+	errNoConnections = errors.New("no tunnel connection is registered with the dev edge")
+
+	// Hop-by-hop headers can't be forwarded over HTTP2, see https://www.rfc-editor.org/rfc/rfc7540#section-8.1.2.2
+	hopByHopHeaders = []string{
+		"Connection",
+		"Keep-Alive",
+		"Proxy-Connection",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+)
+
+// Edge is a local mock of the Cloudflare edge for a single tunnel.
+type Edge struct {
+	credentials connection.Credentials
+	tlsConfig   *tls.Config
+	caCertPEM   []byte
+	log         *zerolog.Logger
+
+	connsLock sync.RWMutex
+	conns     map[*tunnelConnection]struct{}
+	next      uint32
+
+	configLock  sync.RWMutex
+	localConfig []byte
+}
+
+// New creates an Edge that only accepts connections authenticated with the given credentials.
+func New(credentials connection.Credentials, log *zerolog.Logger) (*Edge, error) {
+	cert, certPEM, err := generateCertificate()
+	if err != nil {
+		return nil, err
+	}
+	return &Edge{
+		credentials: credentials,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+		caCertPEM: certPEM,
+		log:       log,
+		conns:     make(map[*tunnelConnection]struct{}),
+	}, nil
+}
+
+// CACertPEM returns the PEM-encoded certificate cloudflared must trust (via --cacert) to connect to this edge.
+func (e *Edge) CACertPEM() []byte {
+	return e.caCertPEM
+}
+
+// LocalConfiguration returns the last configuration pushed by a connector, if any.
+func (e *Edge) LocalConfiguration() []byte {
+	e.configLock.RLock()
+	defer e.configLock.RUnlock()
+	return e.localConfig
+}
+
+// Connections returns how many tunnel connections are currently registered.
+func (e *Edge) Connections() int {
+	e.connsLock.RLock()
+	defer e.connsLock.RUnlock()
+	return len(e.conns)
+}
+
+// ServeTunnels accepts tunnel connections from cloudflared until ctx is cancelled or the listener fails.
+func (e *Edge) ServeTunnels(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go e.serveTunnelConnection(ctx, tls.Server(conn, e.tlsConfig))
+	}
+}
+
+func (e *Edge) serveTunnelConnection(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	log := e.log.With().Str(LogFieldRemoteAddr, conn.RemoteAddr().String()).Logger()
+
+	transport := &http2.Transport{}
+	clientConn, err := transport.NewClientConn(conn)
+	if err != nil {
+		log.Err(err).Msg("Failed to establish HTTP2 session with connector")
+		return
+	}
+	tc := &tunnelConnection{clientConn: clientConn}
+	defer e.unregister(tc)
+
+	if err := tc.serveControlStream(ctx, e, &log); err != nil && ctx.Err() == nil {
+		log.Err(err).Msg("Control stream terminated")
+	}
+}
+
+func (e *Edge) authenticate(auth connection.Credentials) error {
+	if auth.TunnelID != e.credentials.TunnelID {
+		return fmt.Errorf("unknown tunnel %s", auth.TunnelID)
+	}
+	if auth.AccountTag != e.credentials.AccountTag || string(auth.TunnelSecret) != string(e.credentials.TunnelSecret) {
+		return fmt.Errorf("invalid credentials for tunnel %s", auth.TunnelID)
+	}
+	return nil
+}
+
+func (e *Edge) register(tc *tunnelConnection) {
+	e.connsLock.Lock()
+	defer e.connsLock.Unlock()
+	e.conns[tc] = struct{}{}
+}
+
+func (e *Edge) unregister(tc *tunnelConnection) {
+	e.connsLock.Lock()
+	defer e.connsLock.Unlock()
+	delete(e.conns, tc)
+}
+
+func (e *Edge) setLocalConfiguration(config []byte) {
+	e.configLock.Lock()
+	defer e.configLock.Unlock()
+	e.localConfig = config
+}
+
+// pickConnection round robins requests across the registered connections.
+func (e *Edge) pickConnection() *tunnelConnection {
+	e.connsLock.RLock()
+	defer e.connsLock.RUnlock()
+	if len(e.conns) == 0 {
+		return nil
+	}
+	skip := int(atomic.AddUint32(&e.next, 1) % uint32(len(e.conns)))
+	for tc := range e.conns {
+		if skip == 0 {
+			return tc
+		}
+		skip--
+	}
+	return nil
+}
+
+// ServeHTTP proxies an eyeball request through one of the registered tunnel connections, the same way the edge
+// would for a hostname routed to the tunnel.
+func (e *Edge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tc := e.pickConnection()
+	if tc == nil {
+		http.Error(w, errNoConnections.Error(), http.StatusBadGateway)
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	req.URL.Scheme = "https"
+	req.URL.Host = r.Host
+	for _, h := range hopByHopHeaders {
+		req.Header.Del(h)
+	}
+	if r.ContentLength == 0 {
+		req.Body = nil
+	}
+
+	resp, err := tc.clientConn.RoundTrip(req)
+	if err != nil {
+		e.log.Err(err).Uint8(LogFieldConnIndex, tc.index).Msg("Failed to proxy request to connector")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	userHeaders, err := connection.DeserializeHeaders(resp.Header.Get(connection.CanonicalResponseUserHeaders))
+	if err != nil {
+		e.log.Err(err).Uint8(LogFieldConnIndex, tc.index).Msg("Connector sent malformed response headers")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, h := range userHeaders {
+		w.Header().Add(h.Name, h.Value)
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				e.log.Debug().Err(err).Msg("Error reading response body from connector")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package devedge
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tracing"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+)
+
+var testCredentials = connection.Credentials{
+	AccountTag:   "dev",
+	TunnelSecret: []byte("0123456789abcdef0123456789abcdef"),
+	TunnelID:     uuid.New(),
+}
+
+type mockFuse struct{}
+
+func (mockFuse) Connected()        {}
+func (mockFuse) IsConnected() bool { return true }
+
+type mockOrchestrator struct{}
+
+func (mockOrchestrator) UpdateConfig(int32, []byte) *tunnelpogs.UpdateConfigurationResponse {
+	return &tunnelpogs.UpdateConfigurationResponse{}
+}
+
+func (mockOrchestrator) GetConfigJSON() ([]byte, error) {
+	return []byte(`{"ingress":[]}`), nil
+}
+
+func (mockOrchestrator) GetOriginProxy() (connection.OriginProxy, error) {
+	return mockOriginProxy{}, nil
+}
+
+func (mockOrchestrator) WarpRoutingEnabled() bool {
+	return false
+}
+
+type mockOriginProxy struct{}
+
+func (mockOriginProxy) ProxyHTTP(w connection.ResponseWriter, tr *tracing.TracedHTTPRequest, _ bool) error {
+	body, err := io.ReadAll(tr.Body)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("X-Origin-Host", tr.Host)
+	header.Set("X-Origin-Path", tr.URL.Path)
+	if err := w.WriteRespHeaders(http.StatusTeapot, header); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (mockOriginProxy) ProxyTCP(context.Context, connection.ReadWriteAcker, *connection.TCPRequest) error {
+	return nil
+}
+
+// connectTunnel dials the edge the way the supervisor does for the http2 protocol and serves the connection.
+func connectTunnel(t *testing.T, ctx context.Context, edge *Edge, addr string, credentials connection.Credentials) <-chan error {
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(edge.CACertPEM()))
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, ServerName: "h2.cftunnel.com"})
+	require.NoError(t, err)
+
+	log := zerolog.Nop()
+	observer := connection.NewObserver(&log, &log)
+	controlStream := connection.NewControlStream(
+		observer,
+		mockFuse{},
+		&connection.NamedTunnelProperties{Credentials: credentials},
+		0,
+		nil,
+		nil,
+		make(chan struct{}),
+		time.Second,
+		connection.HTTP2,
+	)
+	tunnelConn := connection.NewHTTP2Connection(conn, mockOrchestrator{}, &tunnelpogs.ConnectionOptions{}, observer, 0, controlStream, &log)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- tunnelConn.Serve(ctx)
+	}()
+	return errC
+}
+
+func newTestEdge(t *testing.T, ctx context.Context) (*Edge, string) {
+	log := zerolog.Nop()
+	edge, err := New(testCredentials, &log)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go edge.ServeTunnels(ctx, listener)
+	return edge, listener.Addr().String()
+}
+
+func TestProxyThroughTunnel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	edge, addr := newTestEdge(t, ctx)
+
+	resp := httptest.NewRecorder()
+	edge.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://app.example.com/", nil))
+	assert.Equal(t, http.StatusBadGateway, resp.Code)
+
+	connectTunnel(t, ctx, edge, addr, testCredentials)
+	require.Eventually(t, func() bool { return edge.Connections() == 1 }, time.Second*5, time.Millisecond*10)
+	require.Eventually(t, func() bool { return edge.LocalConfiguration() != nil }, time.Second*5, time.Millisecond*10)
+	assert.JSONEq(t, `{"ingress":[]}`, string(edge.LocalConfiguration()))
+
+	server := httptest.NewServer(edge)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/echo", strings.NewReader("hello"))
+			require.NoError(t, err)
+			req.Host = "app.example.com"
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+			assert.Equal(t, "app.example.com", resp.Header.Get("X-Origin-Host"))
+			assert.Equal(t, "/echo", resp.Header.Get("X-Origin-Path"))
+			assert.Equal(t, "hello", string(body))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRejectInvalidCredentials(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	edge, addr := newTestEdge(t, ctx)
+
+	credentials := testCredentials
+	credentials.TunnelSecret = []byte("not the tunnel secret")
+	errC := connectTunnel(t, ctx, edge, addr, credentials)
+
+	select {
+	case err := <-errC:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("connection with invalid credentials was not rejected")
+	}
+	assert.Equal(t, 0, edge.Connections())
+}
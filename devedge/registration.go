@@ -0,0 +1,140 @@
+package devedge
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"golang.org/x/net/http2"
+	"zombiezen.com/go/capnproto2/rpc"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tunnelrpc"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+)
+
+// tunnelConnection is a single HTTP2 connection from cloudflared. Requests are sent to cloudflared as HTTP2 requests
+// on this connection, and the first of them is the control stream used for the registration RPC.
+type tunnelConnection struct {
+	clientConn *http2.ClientConn
+	index      uint8
+}
+
+// controlStream joins the request body we write to and the response body cloudflared writes to into a single
+// bidirectional stream.
+type controlStream struct {
+	io.ReadCloser
+	writer io.WriteCloser
+}
+
+func (s *controlStream) Write(p []byte) (int, error) {
+	return s.writer.Write(p)
+}
+
+func (s *controlStream) Close() error {
+	_ = s.writer.Close()
+	return s.ReadCloser.Close()
+}
+
+func (tc *tunnelConnection) serveControlStream(ctx context.Context, edge *Edge, log *zerolog.Logger) error {
+	reqBody, reqBodyWriter := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+edgeServerNames[0], reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(connection.InternalUpgradeHeader, connection.ControlStreamUpgrade)
+
+	resp, err := tc.clientConn.RoundTrip(req)
+	if err != nil {
+		reqBodyWriter.Close()
+		return err
+	}
+	stream := &controlStream{ReadCloser: resp.Body, writer: reqBodyWriter}
+	defer stream.Close()
+
+	// RPC logs are very robust, create a new logger that only logs error to reduce noise
+	rpcLogger := log.Level(zerolog.ErrorLevel)
+	rpcTransport := tunnelrpc.NewTransportLogger(&rpcLogger, rpc.StreamTransport(stream))
+	defer rpcTransport.Close()
+
+	main := tunnelpogs.RegistrationServer_ServerToClient(&registrationServer{
+		edge: edge,
+		conn: tc,
+		log:  log,
+	})
+	rpcConn := rpc.NewConn(
+		rpcTransport,
+		rpc.MainInterface(main.Client),
+		tunnelrpc.ConnLog(&rpcLogger),
+	)
+	defer rpcConn.Close()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-waitC(rpcConn):
+		return nil
+	}
+}
+
+func waitC(conn *rpc.Conn) <-chan struct{} {
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		_ = conn.Wait()
+	}()
+	return doneC
+}
+
+// registrationServer implements tunnelpogs.RegistrationServer for a single tunnel connection.
+type registrationServer struct {
+	edge *Edge
+	conn *tunnelConnection
+	log  *zerolog.Logger
+}
+
+func (s *registrationServer) RegisterConnection(
+	ctx context.Context,
+	auth tunnelpogs.TunnelAuth,
+	tunnelID uuid.UUID,
+	connIndex byte,
+	options *tunnelpogs.ConnectionOptions,
+) (*tunnelpogs.ConnectionDetails, error) {
+	err := s.edge.authenticate(connection.Credentials{
+		AccountTag:   auth.AccountTag,
+		TunnelSecret: auth.TunnelSecret,
+		TunnelID:     tunnelID,
+	})
+	if err != nil {
+		s.log.Err(err).Uint8(LogFieldConnIndex, connIndex).Msg("Rejected connection registration")
+		return nil, err
+	}
+
+	s.conn.index = connIndex
+	s.edge.register(s.conn)
+
+	connectionID := uuid.New()
+	event := s.log.Info().Uint8(LogFieldConnIndex, connIndex).Str("connection", connectionID.String())
+	if options != nil {
+		event = event.Str("version", options.Client.Version).Str("arch", options.Client.Arch)
+	}
+	event.Msg("Registered tunnel connection")
+
+	return &tunnelpogs.ConnectionDetails{
+		UUID:     connectionID,
+		Location: Location,
+	}, nil
+}
+
+func (s *registrationServer) UnregisterConnection(ctx context.Context) {
+	s.edge.unregister(s.conn)
+	s.log.Info().Uint8(LogFieldConnIndex, s.conn.index).Msg("Unregistered tunnel connection")
+}
+
+func (s *registrationServer) UpdateLocalConfiguration(ctx context.Context, config []byte) error {
+	s.edge.setLocalConfiguration(config)
+	s.log.Info().RawJSON("config", config).Msg("Received local configuration from connector")
+	return nil
+}
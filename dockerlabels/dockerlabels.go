@@ -0,0 +1,282 @@
+// Package dockerlabels generates ingress rules from the labels of the containers run by the local Docker daemon, so a
+// container is exposed through the tunnel by labelling it, and stops being exposed when it stops.
+package dockerlabels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/retry"
+)
+
+const (
+	// HostnameLabel is the public hostname a container is exposed at.
+	HostnameLabel = "cloudflared.hostname"
+	// PortLabel is the port the container serves HTTP on.
+	PortLabel = "cloudflared.port"
+	// SchemeLabel is the scheme of the container's origin, http or https. Defaults to http.
+	SchemeLabel = "cloudflared.scheme"
+
+	// DefaultHost is where the Docker daemon listens unless DOCKER_HOST says otherwise.
+	DefaultHost = "unix:///var/run/docker.sock"
+
+	maxBackoff = 30 * time.Second
+)
+
+// Route is an origin exposed by a labelled container.
+type Route struct {
+	Hostname  string
+	Service   string
+	Container string
+}
+
+// IngressRule returns the ingress rule that sends the route's hostname to its container.
+func (r Route) IngressRule() config.UnvalidatedIngressRule {
+	return config.UnvalidatedIngressRule{
+		Hostname: r.Hostname,
+		Service:  r.Service,
+	}
+}
+
+// Watcher follows the containers of a Docker daemon through its Engine API.
+type Watcher struct {
+	client  *http.Client
+	baseURL string
+	log     *zerolog.Logger
+}
+
+// NewWatcher connects to the Docker daemon at host, e.g. unix:///var/run/docker.sock or tcp://127.0.0.1:2375. The
+// DOCKER_HOST environment variable, or else DefaultHost, is used if host is empty.
+func NewWatcher(host string, log *zerolog.Logger) (*Watcher, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid Docker host %s", host)
+	}
+	w := &Watcher{client: &http.Client{}, log: log}
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		dialer := net.Dialer{}
+		w.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		}
+		w.baseURL = "http://docker"
+	case "tcp", "http":
+		w.baseURL = "http://" + u.Host
+	case "https":
+		w.baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("Docker host %s isn't supported, it must be a unix, tcp, http or https URL", host)
+	}
+	return w, nil
+}
+
+// Run calls update with the routes of the running containers every time a labelled container starts or stops, until
+// ctx is done. Connections to the daemon are retried with backoff, keeping the last routes in the meantime.
+func (w *Watcher) Run(ctx context.Context, update func(routes []Route)) error {
+	backoff := retry.BackoffHandler{RetryForever: true, MaxRetries: 5, MaxBackoff: maxBackoff}
+	for {
+		err := w.watch(ctx, func(routes []Route) {
+			backoff.ResetNow()
+			update(routes)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		w.log.Err(err).Msg("Lost the connection to the Docker daemon, the routes of the containers are kept")
+		if !backoff.Backoff(ctx) {
+			return nil
+		}
+	}
+}
+
+// watch lists the containers, then lists them again on every container event until the event stream ends.
+func (w *Watcher) watch(ctx context.Context, update func(routes []Route)) error {
+	// Subscribe to the events before listing the containers, so no container starting in between is missed
+	events, err := w.get(ctx, "/events", map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die"},
+		"label": {HostnameLabel},
+	})
+	if err != nil {
+		return err
+	}
+	defer events.Body.Close()
+
+	list := func() error {
+		routes, err := w.Routes(ctx)
+		if err != nil {
+			return err
+		}
+		update(routes)
+		return nil
+	}
+	if err := list(); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(events.Body)
+	for {
+		var event struct {
+			Action string `json:"Action"`
+			Actor  struct {
+				ID string `json:"ID"`
+			} `json:"Actor"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			return errors.Wrap(err, "failed to read Docker events")
+		}
+		w.log.Debug().Str("container", event.Actor.ID).Str("action", event.Action).Msg("Labelled container changed")
+		if err := list(); err != nil {
+			return err
+		}
+	}
+}
+
+// Routes returns the routes of the labelled containers currently running.
+func (w *Watcher) Routes(ctx context.Context) ([]Route, error) {
+	resp, err := w.get(ctx, "/containers/json", map[string][]string{
+		"label":  {HostnameLabel},
+		"status": {"running"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var containers []container
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, errors.Wrap(err, "failed to decode Docker containers")
+	}
+	return routesFromContainers(containers, w.log), nil
+}
+
+func (w *Watcher) get(ctx context.Context, path string, filters map[string][]string) (*http.Response, error) {
+	encodedFilters, err := json.Marshal(filters)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.baseURL+path+"?filters="+url.QueryEscape(string(encodedFilters)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reach the Docker daemon")
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Docker daemon answered %s to %s", resp.Status, path)
+	}
+	return resp, nil
+}
+
+// container is the part of the Engine API's container summary the routes are made of.
+type container struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Labels     map[string]string `json:"Labels"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+func (c *container) name() string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
+
+// address returns the IP the container can be reached at. Containers sharing the host's network are reached on
+// localhost, others on the first network, by name, they have an address in.
+func (c *container) address() string {
+	if c.HostConfig.NetworkMode == "host" {
+		return "127.0.0.1"
+	}
+	networks := make([]string, 0, len(c.NetworkSettings.Networks))
+	for network := range c.NetworkSettings.Networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		if ip := c.NetworkSettings.Networks[network].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// routesFromContainers turns labelled containers into routes sorted by hostname. Containers with invalid labels are
+// skipped, and when several containers claim a hostname the first one by name gets it.
+func routesFromContainers(containers []container, log *zerolog.Logger) []Route {
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].name() < containers[j].name()
+	})
+	routes := make([]Route, 0, len(containers))
+	claimed := make(map[string]string, len(containers))
+	for _, c := range containers {
+		name := c.name()
+		hostname := strings.ToLower(strings.TrimSpace(c.Labels[HostnameLabel]))
+		if hostname == "" {
+			continue
+		}
+		if owner, ok := claimed[hostname]; ok {
+			log.Warn().Str("container", name).Msgf("Ignoring the container because %s is already routed to %s", hostname, owner)
+			continue
+		}
+		port, err := strconv.ParseUint(c.Labels[PortLabel], 10, 16)
+		if err != nil || port == 0 {
+			log.Warn().Str("container", name).Msgf("Ignoring the container because its %s label %q isn't a valid port", PortLabel, c.Labels[PortLabel])
+			continue
+		}
+		scheme := c.Labels[SchemeLabel]
+		switch scheme {
+		case "":
+			scheme = "http"
+		case "http", "https":
+		default:
+			log.Warn().Str("container", name).Msgf("Ignoring the container because its %s label %q must be http or https", SchemeLabel, scheme)
+			continue
+		}
+		address := c.address()
+		if address == "" {
+			log.Warn().Str("container", name).Msg("Ignoring the container because it has no IP address")
+			continue
+		}
+		claimed[hostname] = name
+		routes = append(routes, Route{
+			Hostname:  hostname,
+			Service:   fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(address, strconv.FormatUint(port, 10))),
+			Container: name,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Hostname < routes[j].Hostname
+	})
+	return routes
+}
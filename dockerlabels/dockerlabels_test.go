@@ -0,0 +1,161 @@
+package dockerlabels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+var testLogger = zerolog.Nop()
+
+func labelledContainer(name, hostname, port, ip string) container {
+	c := container{
+		ID:     name + "-id",
+		Names:  []string{"/" + name},
+		Labels: map[string]string{HostnameLabel: hostname, PortLabel: port},
+	}
+	c.NetworkSettings.Networks = map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	}{"bridge": {IPAddress: ip}}
+	return c
+}
+
+func TestRoutesFromContainers(t *testing.T) {
+	hostNetwork := labelledContainer("host-app", "host.example.com", "9000", "")
+	hostNetwork.HostConfig.NetworkMode = "host"
+	tlsApp := labelledContainer("tls-app", "tls.example.com", "8443", "172.17.0.4")
+	tlsApp.Labels[SchemeLabel] = "https"
+	badScheme := labelledContainer("bad-scheme", "bad-scheme.example.com", "80", "172.17.0.5")
+	badScheme.Labels[SchemeLabel] = "ftp"
+
+	routes := routesFromContainers([]container{
+		labelledContainer("web-2", "web.example.com", "80", "172.17.0.3"),
+		labelledContainer("web-1", "Web.example.com", "8080", "172.17.0.2"),
+		labelledContainer("no-port", "no-port.example.com", "", "172.17.0.6"),
+		labelledContainer("bad-port", "bad-port.example.com", "70000", "172.17.0.7"),
+		labelledContainer("no-ip", "no-ip.example.com", "80", ""),
+		hostNetwork,
+		tlsApp,
+		badScheme,
+	}, &testLogger)
+
+	require.Equal(t, []Route{
+		{Hostname: "host.example.com", Service: "http://127.0.0.1:9000", Container: "host-app"},
+		{Hostname: "tls.example.com", Service: "https://172.17.0.4:8443", Container: "tls-app"},
+		{Hostname: "web.example.com", Service: "http://172.17.0.2:8080", Container: "web-1"},
+	}, routes)
+}
+
+// fakeDaemon serves the containers and a stream of events like the Docker Engine API.
+type fakeDaemon struct {
+	lock       sync.Mutex
+	containers []container
+	events     chan string
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil || filters["label"][0] != HostnameLabel {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/containers/json":
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		_ = json.NewEncoder(w).Encode(d.containers)
+	case "/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case action := <-d.events:
+				fmt.Fprintf(w, `{"Type":"container","Action":"%s","Actor":{"ID":"id"}}`+"\n", action)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeDaemon) setContainers(containers ...container) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.containers = containers
+}
+
+func TestWatcher(t *testing.T) {
+	daemon := &fakeDaemon{events: make(chan string)}
+	daemon.setContainers(labelledContainer("web", "web.example.com", "80", "172.17.0.2"))
+	server := httptest.NewServer(daemon)
+	t.Cleanup(server.Close)
+
+	watcher, err := NewWatcher(strings.Replace(server.URL, "http://", "tcp://", 1), &testLogger)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	updates := make(chan []Route)
+	go func() {
+		_ = watcher.Run(ctx, func(routes []Route) {
+			select {
+			case updates <- routes:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	nextUpdate := func() []Route {
+		select {
+		case routes := <-updates:
+			return routes
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the routes")
+			return nil
+		}
+	}
+	require.Equal(t, []Route{{Hostname: "web.example.com", Service: "http://172.17.0.2:80", Container: "web"}}, nextUpdate())
+
+	daemon.setContainers(
+		labelledContainer("web", "web.example.com", "80", "172.17.0.2"),
+		labelledContainer("api", "api.example.com", "8080", "172.17.0.3"),
+	)
+	daemon.events <- "start"
+	require.Equal(t, []Route{
+		{Hostname: "api.example.com", Service: "http://172.17.0.3:8080", Container: "api"},
+		{Hostname: "web.example.com", Service: "http://172.17.0.2:80", Container: "web"},
+	}, nextUpdate())
+
+	daemon.setContainers()
+	daemon.events <- "die"
+	require.Empty(t, nextUpdate())
+}
+
+func TestNewWatcher(t *testing.T) {
+	watcher, err := NewWatcher("unix:///var/run/docker.sock", &testLogger)
+	require.NoError(t, err)
+	require.Equal(t, "http://docker", watcher.baseURL)
+
+	watcher, err = NewWatcher("tcp://127.0.0.1:2375", &testLogger)
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:2375", watcher.baseURL)
+
+	t.Setenv("DOCKER_HOST", "https://docker.example.com:2376")
+	watcher, err = NewWatcher("", &testLogger)
+	require.NoError(t, err)
+	require.Equal(t, "https://docker.example.com:2376", watcher.baseURL)
+
+	_, err = NewWatcher("ssh://docker.example.com", &testLogger)
+	require.Error(t, err)
+}
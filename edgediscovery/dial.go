@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/sockopt"
 )
 
 // DialEdgeWithH2Mux makes a TLS connection to a Cloudflare edge node
@@ -16,6 +18,7 @@ func DialEdge(
 	tlsConfig *tls.Config,
 	edgeTCPAddr *net.TCPAddr,
 	localIP net.IP,
+	socketOptions sockopt.Options,
 ) (net.Conn, error) {
 	// Inherit from parent context so we can cancel (Ctrl-C) while dialing
 	dialCtx, dialCancel := context.WithTimeout(ctx, timeout)
@@ -25,7 +28,8 @@ func DialEdge(
 	if localIP != nil {
 		dialer.LocalAddr = &net.TCPAddr{IP: localIP, Port: 0}
 	}
-	edgeConn, err := dialer.DialContext(dialCtx, "tcp", edgeTCPAddr.String())
+	socketOptions.Dialer(&dialer)
+	edgeConn, err := socketOptions.WrapDial(dialer.DialContext)(dialCtx, "tcp", edgeTCPAddr.String())
 	if err != nil {
 		return nil, newDialError(err, "DialContext error")
 	}
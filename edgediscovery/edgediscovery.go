@@ -29,6 +29,19 @@ type Edge struct {
 	log *zerolog.Logger
 }
 
+// DiscoveryError is returned when the addresses of the edge can't be resolved.
+type DiscoveryError struct {
+	cause error
+}
+
+func (e DiscoveryError) Error() string {
+	return e.cause.Error()
+}
+
+func (e DiscoveryError) Unwrap() error {
+	return e.cause
+}
+
 // ------------------------------------
 // Constructors
 // ------------------------------------
@@ -38,7 +51,7 @@ type Edge struct {
 func ResolveEdge(log *zerolog.Logger, region string, edgeIpVersion allregions.ConfigIPVersion) (*Edge, error) {
 	regions, err := allregions.ResolveEdge(log, region, edgeIpVersion)
 	if err != nil {
-		return new(Edge), err
+		return new(Edge), DiscoveryError{cause: err}
 	}
 	return &Edge{
 		log:     log,
@@ -50,7 +63,7 @@ func ResolveEdge(log *zerolog.Logger, region string, edgeIpVersion allregions.Co
 func StaticEdge(log *zerolog.Logger, hostnames []string) (*Edge, error) {
 	regions, err := allregions.StaticEdge(hostnames, log)
 	if err != nil {
-		return new(Edge), err
+		return new(Edge), DiscoveryError{cause: err}
 	}
 	return &Edge{
 		log:     log,
@@ -0,0 +1,119 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+const fetchFlagsTimeout = 5 * time.Second
+
+var flagGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "features",
+		Name:      "flag",
+		Help:      "Feature flags fetched at startup, 1 if the flag is enabled for this connector and 0 otherwise",
+	},
+	[]string{"name"},
+)
+
+func init() {
+	prometheus.MustRegister(flagGauge)
+}
+
+// Flag is the rollout of a feature flag.
+type Flag struct {
+	Enabled bool `json:"enabled"`
+	// Percentage, if set, enables the flag for this percentage of accounts only.
+	Percentage *uint32 `json:"percentage,omitempty"`
+}
+
+// Flags are feature flags that gate new behaviors, so they can be rolled out gradually without releasing a new binary.
+// The names of the flags are the names of the features sent to the edge, e.g. "support_datagram_v2".
+type Flags map[string]Flag
+
+// FetchFlags gets the feature flags served as a JSON object at url.
+func FetchFlags(url string) (Flags, error) {
+	client := &http.Client{Timeout: fetchFlagsTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the feature flags")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the feature flags: %s responded with %s", url, resp.Status)
+	}
+	var flags Flags
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the feature flags")
+	}
+	return flags, nil
+}
+
+// Select tells which flags are enabled for the account identified by key. A flag with a percentage is enabled for the
+// same accounts every time, so every connector of an account behaves the same.
+func (f Flags) Select(key string) map[string]bool {
+	selected := make(map[string]bool, len(f))
+	for name, flag := range f {
+		enabled := flag.Enabled
+		if enabled && flag.Percentage != nil {
+			enabled = bucket(name, key) < *flag.Percentage
+		}
+		selected[name] = enabled
+	}
+	return selected
+}
+
+// bucket hashes the flag name and the key into [0, 100), so that each flag selects a different set of accounts.
+func bucket(name, key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % 100
+}
+
+// Apply adds the enabled flags to clientFeatures and removes the disabled ones, then logs the flags and reports them in
+// metrics.
+func Apply(clientFeatures []string, selected map[string]bool, log *zerolog.Logger) []string {
+	applied := make([]string, 0, len(clientFeatures)+len(selected))
+	for _, feature := range clientFeatures {
+		if enabled, ok := selected[feature]; !ok || enabled {
+			applied = append(applied, feature)
+		}
+	}
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		enabled := selected[name]
+		if enabled && !containsString(applied, name) {
+			applied = append(applied, name)
+		}
+		if enabled {
+			flagGauge.WithLabelValues(name).Set(1)
+		} else {
+			flagGauge.WithLabelValues(name).Set(0)
+		}
+		log.Info().Str("flag", name).Bool("enabled", enabled).Msg("Feature flag")
+	}
+	return applied
+}
+
+func containsString(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
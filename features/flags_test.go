@@ -0,0 +1,70 @@
+package features
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchFlags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"new_feature": {"enabled": true, "percentage": 10}, "support_datagram_v2": {"enabled": false}}`))
+	}))
+	defer server.Close()
+
+	flags, err := FetchFlags(server.URL)
+	require.NoError(t, err)
+	require.Len(t, flags, 2)
+	assert.True(t, flags["new_feature"].Enabled)
+	assert.Equal(t, uint32(10), *flags["new_feature"].Percentage)
+	assert.False(t, flags[FeatureDatagramV2].Enabled)
+
+	_, err = FetchFlags(server.URL + "/notfound")
+	assert.Error(t, err)
+}
+
+func TestSelectPercentage(t *testing.T) {
+	half := uint32(50)
+	flags := Flags{
+		"rollout":  {Enabled: true, Percentage: &half},
+		"disabled": {Enabled: false, Percentage: &half},
+		"enabled":  {Enabled: true},
+	}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("account%d", i)
+		selected := flags.Select(key)
+		assert.True(t, selected["enabled"])
+		assert.False(t, selected["disabled"])
+		// The same account is always selected the same way
+		assert.Equal(t, selected["rollout"], flags.Select(key)["rollout"])
+		if selected["rollout"] {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 500, enabled, 100)
+}
+
+func TestApply(t *testing.T) {
+	log := zerolog.Nop()
+	applied := Apply(DefaultFeatures, map[string]bool{
+		FeatureDatagramV2:     false,
+		FeaturePostQuantum:    true,
+		FeatureQUICSupportEOF: true,
+	}, &log)
+	assert.NotContains(t, applied, FeatureDatagramV2)
+	assert.Contains(t, applied, FeaturePostQuantum)
+	assert.Contains(t, applied, FeatureSerializedHeaders)
+	assert.Len(t, applied, len(DefaultFeatures))
+	// The defaults are left alone
+	assert.Contains(t, DefaultFeatures, FeatureDatagramV2)
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 	"sync"
+
+	"github.com/cloudflare/cloudflared/cfio"
 )
 
 type ReadWriteLengther interface {
@@ -375,6 +377,8 @@ type streamChunk struct {
 	eof      bool
 
 	buffer []byte
+	// pooled is the buffer from the shared buffer manager that buffer is a slice of, nil if it was allocated
+	pooled *[]byte
 	offset int
 }
 
@@ -399,7 +403,15 @@ func (s *MuxedStream) getChunk() *streamChunk {
 	}
 
 	if toCopy > 0 {
-		buf := make([]byte, toCopy)
+		// The writer of the connection can't wait for the budget while it holds the stream, since it's the one that
+		// releases chunks. Chunks are released as soon as they are written, so they go over the budget instead.
+		var buf []byte
+		if pooled, err := cfio.TryGetBuffer(toCopy); err != nil {
+			buf = make([]byte, toCopy)
+		} else {
+			chunk.pooled = pooled
+			buf = *pooled
+		}
 		writeLen, _ := s.writeBuffer.Read(buf)
 		chunk.buffer = buf[:writeLen]
 		s.sendWindow -= uint32(writeLen)
@@ -437,6 +449,15 @@ func (c *streamChunk) sendDataFrame() bool {
 	return c.sendData
 }
 
+// release returns the chunk's buffer once its data frames are written.
+func (c *streamChunk) release() {
+	if c.pooled != nil {
+		cfio.PutBuffer(c.pooled)
+		c.pooled = nil
+	}
+	c.buffer = nil
+}
+
 func (c *streamChunk) nextDataFrame(frameSize int) (payload []byte, endStream bool) {
 	bytesLeft := len(c.buffer) - c.offset
 	if frameSize > bytesLeft {
@@ -172,6 +172,7 @@ func (w *MuxWriter) run(log *zerolog.Logger) error {
 func (w *MuxWriter) writeStreamData(stream *MuxedStream, log *zerolog.Logger) error {
 	log.Debug().Msgf("mux - write: writable: streamID: %d", stream.streamID)
 	chunk := stream.getChunk()
+	defer chunk.release()
 	w.metricsUpdater.updateReceiveWindow(stream.getReceiveWindow())
 	w.metricsUpdater.updateSendWindow(stream.getSendWindow())
 	if chunk.sendHeadersFrame() {
@@ -0,0 +1,62 @@
+package hooks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitCommand splits command into the program and its arguments with the quoting rules of a POSIX shell: words are
+// separated by whitespace, single quotes keep everything literally, double quotes keep everything but backslash
+// escapes of \, ", $ and `, and a backslash outside of quotes escapes the next character. Nothing is expanded.
+func SplitCommand(command string) ([]string, error) {
+	var (
+		args   []string
+		word   strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			if i+1 == len(command) {
+				return nil, fmt.Errorf("command %q ends with a backslash", command)
+			}
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("command %q has an unterminated single quote", command)
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("\\\"$`", command[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(command[i])
+			}
+			if i == len(command) {
+				return nil, fmt.Errorf("command %q has an unterminated double quote", command)
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,33 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{command: "", args: nil},
+		{command: "  notify  ", args: []string{"notify"}},
+		{command: "notify --level warn", args: []string{"notify", "--level", "warn"}},
+		{command: `"/opt/my scripts/notify.sh" 'tunnel is down'`, args: []string{"/opt/my scripts/notify.sh", "tunnel is down"}},
+		{command: `echo '$HOME "quoted"' "\$HOME \"quoted\" \n"`, args: []string{"echo", `$HOME "quoted"`, `$HOME "quoted" \n`}},
+		{command: `echo a\ b c\\d`, args: []string{"echo", "a b", `c\d`}},
+		{command: `echo '' "" x""y`, args: []string{"echo", "", "", "xy"}},
+	}
+	for _, test := range tests {
+		args, err := SplitCommand(test.command)
+		require.NoError(t, err, test.command)
+		assert.Equal(t, test.args, args, test.command)
+	}
+
+	for _, command := range []string{`echo 'unterminated`, `echo "unterminated`, `echo "escaped\"`, `echo trailing\`} {
+		_, err := SplitCommand(command)
+		assert.Error(t, err, command)
+	}
+}
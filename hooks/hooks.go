@@ -0,0 +1,164 @@
+// Package hooks runs user-specified commands when the state of the tunnel's connections changes, so local automation
+// such as failover scripts or status pages can react to it.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+const (
+	// Environment variables describing the event to the command.
+	EnvEvent             = "CLOUDFLARED_EVENT"
+	EnvConnIndex         = "CLOUDFLARED_CONN_INDEX"
+	EnvLocation          = "CLOUDFLARED_LOCATION"
+	EnvProtocol          = "CLOUDFLARED_PROTOCOL"
+	EnvURL               = "CLOUDFLARED_URL"
+	EnvActiveConnections = "CLOUDFLARED_ACTIVE_CONNECTIONS"
+
+	// EventConnected is the value of EnvEvent when a connection to the edge is registered.
+	EventConnected = "connected"
+	// EventDisconnected is the value of EnvEvent when a registered connection to the edge is lost.
+	EventDisconnected = "disconnected"
+	// EventURLAssigned is the value of EnvEvent when a quick tunnel is given its URL.
+	EventURLAssigned = "url_assigned"
+
+	// DefaultTimeout bounds how long a hook may run before it's killed.
+	DefaultTimeout = time.Minute
+
+	queueSize = 64
+)
+
+// Config are the commands to run for each event, as the program followed by its arguments. They aren't run through a
+// shell, SplitCommand parses them from a command line. Empty commands are skipped.
+type Config struct {
+	OnConnected    []string
+	OnDisconnected []string
+	OnURLAssigned  []string
+	// Timeout bounds how long a hook may run. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// IsEmpty returns true if no hooks are configured.
+func (c Config) IsEmpty() bool {
+	return len(c.OnConnected) == 0 && len(c.OnDisconnected) == 0 && len(c.OnURLAssigned) == 0
+}
+
+type invocation struct {
+	command []string
+	env     []string
+}
+
+// Runner is a connection.EventSink that runs the configured hooks. Hooks run one at a time, in the order of the
+// events, so a slow hook delays the following ones rather than racing with them.
+type Runner struct {
+	config Config
+	queue  chan invocation
+	log    *zerolog.Logger
+
+	// Only accessed from OnTunnelEvent, which the observer calls from a single goroutine.
+	connected map[uint8]bool
+}
+
+// NewRunner creates a Runner. Hooks are run until ctx is done.
+func NewRunner(ctx context.Context, config Config, log *zerolog.Logger) *Runner {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	r := &Runner{
+		config:    config,
+		queue:     make(chan invocation, queueSize),
+		log:       log,
+		connected: make(map[uint8]bool),
+	}
+	go r.run(ctx)
+	return r
+}
+
+func (r *Runner) OnTunnelEvent(event connection.Event) {
+	switch event.EventType {
+	case connection.Connected:
+		if r.connected[event.Index] {
+			return
+		}
+		r.connected[event.Index] = true
+		r.enqueue(r.config.OnConnected, EventConnected, r.connEnv(event))
+	case connection.Disconnected:
+		// Disconnected is also sent when an attempt to connect fails, which isn't a change of state.
+		if !r.connected[event.Index] {
+			return
+		}
+		r.connected[event.Index] = false
+		r.enqueue(r.config.OnDisconnected, EventDisconnected, r.connEnv(event))
+	case connection.SetURL:
+		r.enqueue(r.config.OnURLAssigned, EventURLAssigned, []string{fmt.Sprintf("%s=%s", EnvURL, event.URL)})
+	}
+}
+
+func (r *Runner) connEnv(event connection.Event) []string {
+	env := []string{
+		fmt.Sprintf("%s=%d", EnvConnIndex, event.Index),
+		fmt.Sprintf("%s=%d", EnvActiveConnections, r.activeConnections()),
+	}
+	if event.Location != "" {
+		env = append(env, fmt.Sprintf("%s=%s", EnvLocation, event.Location))
+	}
+	if event.EventType == connection.Connected {
+		env = append(env, fmt.Sprintf("%s=%s", EnvProtocol, event.Protocol))
+	}
+	return env
+}
+
+func (r *Runner) activeConnections() int {
+	active := 0
+	for _, connected := range r.connected {
+		if connected {
+			active++
+		}
+	}
+	return active
+}
+
+func (r *Runner) enqueue(command []string, event string, env []string) {
+	if len(command) == 0 {
+		return
+	}
+	select {
+	case r.queue <- invocation{command: command, env: append(env, fmt.Sprintf("%s=%s", EnvEvent, event))}:
+	default:
+		r.log.Warn().Str("event", event).Msg("Skipping hook because too many are pending")
+	}
+}
+
+func (r *Runner) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case inv := <-r.queue:
+			if err := r.exec(ctx, inv); err != nil {
+				r.log.Err(err).Strs("command", inv.command).Msg("Hook failed")
+			}
+		}
+	}
+}
+
+func (r *Runner) exec(ctx context.Context, inv invocation) error {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, inv.command[0], inv.command[1:]...)
+	cmd.Env = append(os.Environ(), inv.env...)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		r.log.Debug().Strs("command", inv.command).Msgf("Hook output: %s", output)
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// writeHookScript creates a script that appends the hook environment variables to a file, one line per run.
+func writeHookScript(t *testing.T) (string, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test scripts use sh")
+	}
+	dir := t.TempDir()
+	output := filepath.Join(dir, "events")
+	script := filepath.Join(dir, "hook.sh")
+	content := `#!/bin/sh
+echo "$CLOUDFLARED_EVENT|$CLOUDFLARED_CONN_INDEX|$CLOUDFLARED_LOCATION|$CLOUDFLARED_PROTOCOL|$CLOUDFLARED_ACTIVE_CONNECTIONS|$CLOUDFLARED_URL" >> ` + output + "\n"
+	require.NoError(t, os.WriteFile(script, []byte(content), 0700))
+	return script, output
+}
+
+func waitForLines(t *testing.T, path string, count int) []string {
+	var lines []string
+	require.Eventually(t, func() bool {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		lines = strings.Split(strings.TrimSpace(string(content)), "\n")
+		return len(lines) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+	return lines
+}
+
+func TestRunner(t *testing.T) {
+	script, output := writeHookScript(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := zerolog.Nop()
+	runner := NewRunner(ctx, Config{
+		OnConnected:    []string{script},
+		OnDisconnected: []string{script},
+		OnURLAssigned:  []string{script},
+	}, &log)
+
+	runner.OnTunnelEvent(connection.Event{EventType: connection.SetURL, URL: "https://example.trycloudflare.com"})
+	// A failed attempt to connect isn't a disconnection
+	runner.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	runner.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected, Location: "SFO", Protocol: connection.QUIC})
+	runner.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected, Location: "SFO", Protocol: connection.QUIC})
+	runner.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Disconnected})
+
+	lines := waitForLines(t, output, 3)
+	assert.Equal(t, []string{
+		"url_assigned|||||https://example.trycloudflare.com",
+		"connected|0|SFO|quic|1|",
+		"disconnected|0|||0|",
+	}, lines)
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook test uses sleep")
+	}
+	script, output := writeHookScript(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := zerolog.Nop()
+	runner := NewRunner(ctx, Config{
+		OnConnected:    []string{"sleep", "10"},
+		OnDisconnected: []string{script},
+		Timeout:        10 * time.Millisecond,
+	}, &log)
+
+	runner.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected})
+	runner.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Disconnected})
+	assert.Equal(t, []string{"disconnected|0|||0|"}, waitForLines(t, output, 1))
+}
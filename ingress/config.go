@@ -2,12 +2,14 @@ package ingress
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/ipaccess"
+	"github.com/cloudflare/cloudflared/sockopt"
 	"github.com/cloudflare/cloudflared/tlsconfig"
 )
 
@@ -265,9 +267,60 @@ func originRequestFromConfig(c config.OriginRequestConfig) OriginRequestConfig {
 	if c.Http2Origin != nil {
 		out.Http2Origin = *c.Http2Origin
 	}
+	if c.H2COrigin != nil {
+		out.H2COrigin = *c.H2COrigin
+	}
 	if c.Access != nil {
 		out.Access = *c.Access
 	}
+	out.Plugins = c.Plugins
+	out.AuthService = c.AuthService
+	if c.ConnectorHeaders != nil {
+		out.ConnectorHeaders = *c.ConnectorHeaders
+	}
+	out.ResponseRewrite = c.ResponseRewrite
+	out.HTTPHeaders = c.HTTPHeaders
+	out.SetResponseHeaders = c.SetResponseHeaders
+	out.RemoveResponseHeaders = c.RemoveResponseHeaders
+	out.Mirror = c.Mirror
+	out.Socket = c.Socket
+	if c.StreamingMode != nil {
+		out.StreamingMode = StreamingMode(*c.StreamingMode)
+	}
+	if c.SSEHeartbeatInterval != nil {
+		out.SSEHeartbeatInterval = *c.SSEHeartbeatInterval
+	}
+	if c.WebsocketPingInterval != nil {
+		out.WebsocketPingInterval = *c.WebsocketPingInterval
+	}
+	if c.WebsocketIdleTimeout != nil {
+		out.WebsocketIdleTimeout = *c.WebsocketIdleTimeout
+	}
+	if c.UDPIdleTimeout != nil {
+		out.UDPIdleTimeout = *c.UDPIdleTimeout
+	}
+	if c.RequestTimeout != nil {
+		out.RequestTimeout = *c.RequestTimeout
+	}
+	if c.MaxRequestBodySize != nil {
+		out.MaxRequestBodySize = *c.MaxRequestBodySize
+	}
+	if c.MaxResponseBodySize != nil {
+		out.MaxResponseBodySize = *c.MaxResponseBodySize
+	}
+	if c.Retries != nil {
+		out.Retries = *c.Retries
+	}
+	out.RetryOn = c.RetryOn
+	if c.RetryTimeout != nil {
+		out.RetryTimeout = *c.RetryTimeout
+	}
+	out.TLS = c.TLS
+	out.Discovery = c.Discovery
+	out.FileServer = c.FileServer
+	out.HealthCheck = c.HealthCheck
+	out.Cache = c.Cache
+	out.RateLimit = c.RateLimit
 	return out
 }
 
@@ -287,7 +340,8 @@ type OriginRequestConfig struct {
 	KeepAliveTimeout config.CustomDuration `yaml:"keepAliveTimeout" json:"keepAliveTimeout"`
 	// HTTP proxy maximum keepalive connection pool size
 	KeepAliveConnections int `yaml:"keepAliveConnections" json:"keepAliveConnections"`
-	// Sets the HTTP Host header for the local webserver.
+	// Sets the HTTP Host header for the local webserver. It can refer to the labels matched by the wildcard of the
+	// rule's hostname with $1, and to the public hostname with ${host}.
 	HTTPHostHeader string `yaml:"httpHostHeader" json:"httpHostHeader"`
 	// Hostname on the origin server certificate.
 	OriginServerName string `yaml:"originServerName" json:"originServerName"`
@@ -313,9 +367,175 @@ type OriginRequestConfig struct {
 	IPRules []ipaccess.Rule `yaml:"ipRules" json:"ipRules"`
 	// Attempt to connect to origin with HTTP/2
 	Http2Origin bool `yaml:"http2Origin" json:"http2Origin"`
+	// H2COrigin speaks HTTP/2 with prior knowledge (h2c) to http:// and unix: origins, e.g. gRPC servers without TLS.
+	// Websocket upgrades still use HTTP/1.1.
+	H2COrigin bool `yaml:"h2cOrigin" json:"h2cOrigin,omitempty"`
 
 	// Access holds all access related configs
 	Access config.AccessConfig `yaml:"access" json:"access,omitempty"`
+
+	// Plugins are middleware plugins that run, in order, on requests matching the rule
+	Plugins []config.PluginConfig `yaml:"plugins,omitempty" json:"plugins,omitempty"`
+
+	// AuthService is called to authorize requests before they are proxied
+	AuthService *config.AuthServiceConfig `yaml:"authService" json:"authService,omitempty"`
+
+	// Adds headers identifying the connector, connection and colo that proxied the request
+	ConnectorHeaders bool `yaml:"connectorHeaders" json:"connectorHeaders,omitempty"`
+
+	// ResponseRewrite rewrites references to the origin's address in responses to the public hostname
+	ResponseRewrite *config.ResponseRewriteConfig `yaml:"responseRewrite" json:"responseRewrite,omitempty"`
+
+	// HTTPHeaders adds and removes headers of the requests sent to the origin, after the eyeball's headers and the
+	// connector headers are set
+	HTTPHeaders *config.HTTPHeadersConfig `yaml:"httpHeaders" json:"httpHeaders,omitempty"`
+
+	// SetResponseHeaders are headers set on responses, replacing the values the origin gave them, e.g. security
+	// headers like Strict-Transport-Security
+	SetResponseHeaders map[string]string `yaml:"setResponseHeaders,omitempty" json:"setResponseHeaders,omitempty"`
+
+	// RemoveResponseHeaders are headers removed from responses, e.g. the ones identifying the origin's software
+	RemoveResponseHeaders []string `yaml:"removeResponseHeaders,omitempty" json:"removeResponseHeaders,omitempty"`
+
+	// Mirror sends a copy of some requests to a shadow origin
+	Mirror *config.MirrorConfig `yaml:"mirror" json:"mirror,omitempty"`
+
+	// Socket tunes the TCP sockets of connections to the origin
+	Socket *config.SocketConfig `yaml:"socket" json:"socket,omitempty"`
+
+	// StreamingMode selects whether bodies may be buffered on their way between the eyeball and the origin
+	StreamingMode StreamingMode `yaml:"streamingMode" json:"streamingMode,omitempty"`
+
+	// SSEHeartbeatInterval is how long a server-sent events response may stay silent before cloudflared sends a comment
+	// line to the eyeball, so that intermediaries don't time the stream out. 0 disables heartbeats.
+	SSEHeartbeatInterval config.CustomDuration `yaml:"sseHeartbeatInterval" json:"sseHeartbeatInterval,omitempty"`
+
+	// WebsocketPingInterval is how often cloudflared sends a ping frame to the eyeball and to the origin of a
+	// websocket, so that intermediaries don't drop it while it's idle. The websockets that cloudflared ends itself for
+	// TCP services are only pinged on the eyeball side. 0 keeps the defaults: no pings through websockets proxied to
+	// HTTP origins, and a ping every 54 seconds to the eyeballs of TCP services.
+	WebsocketPingInterval config.CustomDuration `yaml:"websocketPingInterval" json:"websocketPingInterval,omitempty"`
+
+	// WebsocketIdleTimeout closes the websockets proxied to HTTP origins once no message went through them in either
+	// direction for this long. Control frames, like pings, don't count. 0 means no timeout.
+	WebsocketIdleTimeout config.CustomDuration `yaml:"websocketIdleTimeout" json:"websocketIdleTimeout,omitempty"`
+
+	// UDPIdleTimeout ends the UDP sessions proxied to udp:// services once no datagram went through them in either
+	// direction for this long. 0 leaves it to the edge, which asks for the timeout of each session.
+	UDPIdleTimeout config.CustomDuration `yaml:"udpIdleTimeout" json:"udpIdleTimeout,omitempty"`
+
+	// RequestTimeout is how long a request to the origin may take, from sending it to reading the last byte of its
+	// response. Requests that time out before the origin responds get a 504. Websockets, server-sent events and gRPC
+	// streams are long-lived, so they're exempt. 0 means no timeout.
+	RequestTimeout config.CustomDuration `yaml:"requestTimeout" json:"requestTimeout,omitempty"`
+
+	// MaxRequestBodySize is the largest request body, in bytes, that is proxied to the origin. Larger requests are
+	// rejected with 413. 0 means no limit.
+	MaxRequestBodySize int64 `yaml:"maxRequestBodySize" json:"maxRequestBodySize,omitempty"`
+
+	// MaxResponseBodySize is the largest response body, in bytes, that is proxied from the origin. Responses that
+	// announce a larger body are replaced by a 502, the others are cut once they exceed it. 0 means no limit.
+	MaxResponseBodySize int64 `yaml:"maxResponseBodySize" json:"maxResponseBodySize,omitempty"`
+
+	// Retries is how many times an idempotent request without a body is sent to the origin again after failing in a
+	// way listed in RetryOn, with a jittered exponential backoff. 0 disables retries.
+	Retries uint `yaml:"retries" json:"retries,omitempty"`
+
+	// RetryOn are the failures that are retried: connect-failure, when the origin can't be connected to, and 5xx
+	// status codes like 502 and 503. Only connect failures are retried by default.
+	RetryOn []string `yaml:"retryOn,omitempty" json:"retryOn,omitempty"`
+
+	// RetryTimeout caps the time spent on all the attempts of a request, after which the last failure is returned.
+	// It's 10 seconds by default.
+	RetryTimeout config.CustomDuration `yaml:"retryTimeout" json:"retryTimeout,omitempty"`
+
+	// TLS restricts the versions, cipher suites and curves of TLS connections to the origin, and checks revocation
+	TLS *config.TLSPolicyConfig `yaml:"tls" json:"tls,omitempty"`
+
+	// Discovery configures the registry that consul:// and etcd:// services are resolved from
+	Discovery *config.DiscoveryConfig `yaml:"discovery" json:"discovery,omitempty"`
+
+	// FileServer configures how file:// services serve the files of their directory
+	FileServer *config.FileServerConfig `yaml:"fileServer" json:"fileServer,omitempty"`
+
+	// HealthCheck actively checks that the origins are healthy
+	HealthCheck *config.HealthCheckConfig `yaml:"healthCheck" json:"healthCheck,omitempty"`
+
+	// Cache caches the responses of the origin to GET and HEAD requests
+	Cache *config.CacheConfig `yaml:"cache" json:"cache,omitempty"`
+
+	// RateLimit limits the rate of requests of every client IP
+	RateLimit *config.RateLimitConfig `yaml:"rateLimit" json:"rateLimit,omitempty"`
+}
+
+// StreamingMode selects how bodies are proxied between the eyeball and the origin.
+type StreamingMode string
+
+const (
+	// StreamingModeBuffered lets middleware and mirrors buffer bodies, and responses are flushed when the connection
+	// decides to. This is the default.
+	StreamingModeBuffered StreamingMode = ""
+	// StreamingModePassthrough never buffers bodies: response body middleware and mirrors are skipped, and responses
+	// are flushed to the eyeball in small chunks as soon as they're read from the origin. It suits video streaming and
+	// large downloads.
+	StreamingModePassthrough StreamingMode = "passthrough"
+)
+
+func (m StreamingMode) validate() error {
+	switch m {
+	case StreamingModeBuffered, StreamingModePassthrough:
+		return nil
+	default:
+		return fmt.Errorf("unknown streamingMode %q, it must be %q or unset", m, StreamingModePassthrough)
+	}
+}
+
+// Redacted returns a copy of the configuration with its secrets redacted, for showing it by /config and --dry-run.
+// The configuration itself is serialized as is, since it's also sent to the edge.
+func (c OriginRequestConfig) Redacted() OriginRequestConfig {
+	c.HTTPHeaders = c.HTTPHeaders.Redacted()
+	c.Discovery = c.Discovery.Redacted()
+	return c
+}
+
+// RedactedRules returns copies of the rules with the secrets of their configuration redacted.
+func RedactedRules(rules []Rule) []Rule {
+	redacted := make([]Rule, len(rules))
+	for i, rule := range rules {
+		rule.Config = rule.Config.Redacted()
+		redacted[i] = rule
+	}
+	return redacted
+}
+
+// tlsPolicy returns the restrictions of TLS connections to the origin.
+func (c OriginRequestConfig) tlsPolicy() (tlsconfig.Policy, error) {
+	if c.TLS == nil {
+		return tlsconfig.Policy{}, nil
+	}
+	return tlsconfig.ParsePolicy(c.TLS.MinVersion, c.TLS.CipherSuites, c.TLS.Curves)
+}
+
+// socketOptions returns the settings of the sockets connecting to the origin.
+func (c OriginRequestConfig) socketOptions() sockopt.Options {
+	var opts sockopt.Options
+	if c.Socket == nil {
+		return opts
+	}
+	opts.NoDelay = c.Socket.NoDelay
+	if c.Socket.KeepAliveInterval != nil {
+		opts.KeepAliveInterval = c.Socket.KeepAliveInterval.Duration
+	}
+	if c.Socket.KeepAliveCount != nil {
+		opts.KeepAliveCount = *c.Socket.KeepAliveCount
+	}
+	if c.Socket.SendBuffer != nil {
+		opts.SendBuffer = *c.Socket.SendBuffer
+	}
+	if c.Socket.ReceiveBuffer != nil {
+		opts.ReceiveBuffer = *c.Socket.ReceiveBuffer
+	}
+	return opts
 }
 
 func (defaults *OriginRequestConfig) setConnectTimeout(overrides config.OriginRequestConfig) {
@@ -427,12 +647,171 @@ func (defaults *OriginRequestConfig) setHttp2Origin(overrides config.OriginReque
 	}
 }
 
+func (defaults *OriginRequestConfig) setH2COrigin(overrides config.OriginRequestConfig) {
+	if val := overrides.H2COrigin; val != nil {
+		defaults.H2COrigin = *val
+	}
+}
+
 func (defaults *OriginRequestConfig) setAccess(overrides config.OriginRequestConfig) {
 	if val := overrides.Access; val != nil {
 		defaults.Access = *val
 	}
 }
 
+func (defaults *OriginRequestConfig) setAuthService(overrides config.OriginRequestConfig) {
+	if val := overrides.AuthService; val != nil {
+		defaults.AuthService = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setPlugins(overrides config.OriginRequestConfig) {
+	if val := overrides.Plugins; len(val) > 0 {
+		defaults.Plugins = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setConnectorHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.ConnectorHeaders; val != nil {
+		defaults.ConnectorHeaders = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setHTTPHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.HTTPHeaders; val != nil {
+		defaults.HTTPHeaders = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setResponseHeaders(overrides config.OriginRequestConfig) {
+	if val := overrides.SetResponseHeaders; val != nil {
+		defaults.SetResponseHeaders = val
+	}
+	if val := overrides.RemoveResponseHeaders; val != nil {
+		defaults.RemoveResponseHeaders = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setResponseRewrite(overrides config.OriginRequestConfig) {
+	if val := overrides.ResponseRewrite; val != nil {
+		defaults.ResponseRewrite = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setMirror(overrides config.OriginRequestConfig) {
+	if val := overrides.Mirror; val != nil {
+		defaults.Mirror = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setSocket(overrides config.OriginRequestConfig) {
+	if val := overrides.Socket; val != nil {
+		defaults.Socket = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setStreamingMode(overrides config.OriginRequestConfig) {
+	if val := overrides.StreamingMode; val != nil {
+		defaults.StreamingMode = StreamingMode(*val)
+	}
+}
+
+func (defaults *OriginRequestConfig) setSSEHeartbeatInterval(overrides config.OriginRequestConfig) {
+	if val := overrides.SSEHeartbeatInterval; val != nil {
+		defaults.SSEHeartbeatInterval = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setWebsocketPingInterval(overrides config.OriginRequestConfig) {
+	if val := overrides.WebsocketPingInterval; val != nil {
+		defaults.WebsocketPingInterval = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setWebsocketIdleTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.WebsocketIdleTimeout; val != nil {
+		defaults.WebsocketIdleTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setUDPIdleTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.UDPIdleTimeout; val != nil {
+		defaults.UDPIdleTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRequestTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.RequestTimeout; val != nil {
+		defaults.RequestTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setMaxRequestBodySize(overrides config.OriginRequestConfig) {
+	if val := overrides.MaxRequestBodySize; val != nil {
+		defaults.MaxRequestBodySize = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setMaxResponseBodySize(overrides config.OriginRequestConfig) {
+	if val := overrides.MaxResponseBodySize; val != nil {
+		defaults.MaxResponseBodySize = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRetries(overrides config.OriginRequestConfig) {
+	if val := overrides.Retries; val != nil {
+		defaults.Retries = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRetryOn(overrides config.OriginRequestConfig) {
+	if val := overrides.RetryOn; val != nil {
+		defaults.RetryOn = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRetryTimeout(overrides config.OriginRequestConfig) {
+	if val := overrides.RetryTimeout; val != nil {
+		defaults.RetryTimeout = *val
+	}
+}
+
+func (defaults *OriginRequestConfig) setTLS(overrides config.OriginRequestConfig) {
+	if val := overrides.TLS; val != nil {
+		defaults.TLS = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setDiscovery(overrides config.OriginRequestConfig) {
+	if val := overrides.Discovery; val != nil {
+		defaults.Discovery = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setFileServer(overrides config.OriginRequestConfig) {
+	if val := overrides.FileServer; val != nil {
+		defaults.FileServer = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setHealthCheck(overrides config.OriginRequestConfig) {
+	if val := overrides.HealthCheck; val != nil {
+		defaults.HealthCheck = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setCache(overrides config.OriginRequestConfig) {
+	if val := overrides.Cache; val != nil {
+		defaults.Cache = val
+	}
+}
+
+func (defaults *OriginRequestConfig) setRateLimit(overrides config.OriginRequestConfig) {
+	if val := overrides.RateLimit; val != nil {
+		defaults.RateLimit = val
+	}
+}
+
 // SetConfig gets config for the requests that cloudflared sends to origins.
 // Each field has a setter method which sets a value for the field by trying to find:
 //  1. The user config for this rule
@@ -460,7 +839,33 @@ func setConfig(defaults OriginRequestConfig, overrides config.OriginRequestConfi
 	cfg.setProxyType(overrides)
 	cfg.setIPRules(overrides)
 	cfg.setHttp2Origin(overrides)
+	cfg.setH2COrigin(overrides)
 	cfg.setAccess(overrides)
+	cfg.setPlugins(overrides)
+	cfg.setAuthService(overrides)
+	cfg.setConnectorHeaders(overrides)
+	cfg.setResponseRewrite(overrides)
+	cfg.setHTTPHeaders(overrides)
+	cfg.setResponseHeaders(overrides)
+	cfg.setMirror(overrides)
+	cfg.setSocket(overrides)
+	cfg.setStreamingMode(overrides)
+	cfg.setSSEHeartbeatInterval(overrides)
+	cfg.setWebsocketPingInterval(overrides)
+	cfg.setWebsocketIdleTimeout(overrides)
+	cfg.setUDPIdleTimeout(overrides)
+	cfg.setRequestTimeout(overrides)
+	cfg.setMaxRequestBodySize(overrides)
+	cfg.setMaxResponseBodySize(overrides)
+	cfg.setRetries(overrides)
+	cfg.setRetryOn(overrides)
+	cfg.setRetryTimeout(overrides)
+	cfg.setTLS(overrides)
+	cfg.setDiscovery(overrides)
+	cfg.setFileServer(overrides)
+	cfg.setHealthCheck(overrides)
+	cfg.setCache(overrides)
+	cfg.setRateLimit(overrides)
 
 	return cfg
 }
@@ -473,6 +878,8 @@ func ConvertToRawOriginConfig(c OriginRequestConfig) config.OriginRequestConfig
 	var keepAliveTimeout *config.CustomDuration
 	var proxyAddress *string
 	var access *config.AccessConfig
+	var sseHeartbeatInterval *config.CustomDuration
+	var requestTimeout *config.CustomDuration
 
 	if c.ConnectTimeout != defaultHTTPConnectTimeout {
 		connectTimeout = &c.ConnectTimeout
@@ -495,6 +902,28 @@ func ConvertToRawOriginConfig(c OriginRequestConfig) config.OriginRequestConfig
 	if c.Access.Required {
 		access = &c.Access
 	}
+	if c.SSEHeartbeatInterval.Duration != 0 {
+		sseHeartbeatInterval = &c.SSEHeartbeatInterval
+	}
+	if c.RequestTimeout.Duration != 0 {
+		requestTimeout = &c.RequestTimeout
+	}
+	var retryTimeout *config.CustomDuration
+	if c.RetryTimeout.Duration != 0 {
+		retryTimeout = &c.RetryTimeout
+	}
+	var websocketPingInterval *config.CustomDuration
+	if c.WebsocketPingInterval.Duration != 0 {
+		websocketPingInterval = &c.WebsocketPingInterval
+	}
+	var websocketIdleTimeout *config.CustomDuration
+	if c.WebsocketIdleTimeout.Duration != 0 {
+		websocketIdleTimeout = &c.WebsocketIdleTimeout
+	}
+	var udpIdleTimeout *config.CustomDuration
+	if c.UDPIdleTimeout.Duration != 0 {
+		udpIdleTimeout = &c.UDPIdleTimeout
+	}
 
 	return config.OriginRequestConfig{
 		ConnectTimeout:         connectTimeout,
@@ -514,7 +943,34 @@ func ConvertToRawOriginConfig(c OriginRequestConfig) config.OriginRequestConfig
 		ProxyType:              emptyStringToNil(c.ProxyType),
 		IPRules:                convertToRawIPRules(c.IPRules),
 		Http2Origin:            defaultBoolToNil(c.Http2Origin),
+		H2COrigin:              defaultBoolToNil(c.H2COrigin),
 		Access:                 access,
+		Plugins:                c.Plugins,
+		AuthService:            c.AuthService,
+		ConnectorHeaders:       defaultBoolToNil(c.ConnectorHeaders),
+		ResponseRewrite:        c.ResponseRewrite,
+		HTTPHeaders:            c.HTTPHeaders,
+		SetResponseHeaders:     c.SetResponseHeaders,
+		RemoveResponseHeaders:  c.RemoveResponseHeaders,
+		Mirror:                 c.Mirror,
+		Socket:                 c.Socket,
+		StreamingMode:          emptyStringToNil(string(c.StreamingMode)),
+		SSEHeartbeatInterval:   sseHeartbeatInterval,
+		WebsocketPingInterval:  websocketPingInterval,
+		WebsocketIdleTimeout:   websocketIdleTimeout,
+		UDPIdleTimeout:         udpIdleTimeout,
+		RequestTimeout:         requestTimeout,
+		MaxRequestBodySize:     zeroInt64ToNil(c.MaxRequestBodySize),
+		MaxResponseBodySize:    zeroInt64ToNil(c.MaxResponseBodySize),
+		Retries:                zeroUIntToNil(c.Retries),
+		RetryOn:                c.RetryOn,
+		RetryTimeout:           retryTimeout,
+		TLS:                    c.TLS,
+		Discovery:              c.Discovery,
+		FileServer:             c.FileServer,
+		HealthCheck:            c.HealthCheck,
+		Cache:                  c.Cache,
+		RateLimit:              c.RateLimit,
 	}
 }
 
@@ -558,3 +1014,11 @@ func zeroUIntToNil(v uint) *uint {
 
 	return &v
 }
+
+func zeroInt64ToNil(v int64) *int64 {
+	if v == 0 {
+		return nil
+	}
+
+	return &v
+}
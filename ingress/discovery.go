@@ -0,0 +1,152 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/retry"
+)
+
+const (
+	consulScheme = "consul"
+	etcdScheme   = "etcd"
+	srvScheme    = "srv"
+
+	discoveryMaxBackoff = 30 * time.Second
+)
+
+// discoverer watches a service registry for the addresses of an origin.
+type discoverer interface {
+	// watch calls update with the full list of addresses every time it may have changed, until ctx is done or the
+	// registry can't be reached.
+	watch(ctx context.Context, update func(addresses []weightedAddress)) error
+}
+
+// discoveredService is an HTTP origin whose addresses are discovered from a service registry, e.g.
+// consul://web?tag=primary, etcd:///services/web/ or srv://_web._tcp.example.com. Requests are balanced over the
+// addresses in round-robin order, weighted if the registry gives weights.
+type discoveredService struct {
+	service    string
+	discoverer discoverer
+	scheme     string
+	pool       originPool
+	transport  *lazyTransport
+	log        *zerolog.Logger
+}
+
+func isDiscoveryService(service string) bool {
+	for _, scheme := range []string{consulScheme, etcdScheme, srvScheme} {
+		if strings.HasPrefix(service, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+func newDiscoveredService(service string, cfg *config.DiscoveryConfig) (*discoveredService, error) {
+	if cfg == nil {
+		cfg = &config.DiscoveryConfig{}
+	}
+	scheme := cfg.Scheme
+	switch scheme {
+	case "":
+		scheme = "http"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("discovery scheme %s isn't supported, it must be http or https", scheme)
+	}
+
+	u, err := url.Parse(service)
+	if err != nil {
+		return nil, err
+	}
+	var d discoverer
+	switch u.Scheme {
+	case consulScheme:
+		d, err = newConsulDiscoverer(u, cfg)
+	case etcdScheme:
+		d, err = newEtcdDiscoverer(u, cfg)
+	case srvScheme:
+		d, err = newSRVDiscoverer(u, cfg)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid service %s", service)
+	}
+	return &discoveredService{service: service, discoverer: d, scheme: scheme}, nil
+}
+
+func (o *discoveredService) String() string {
+	return o.service
+}
+
+func (o *discoveredService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *discoveredService) start(log *zerolog.Logger, shutdownC <-chan struct{}, cfg OriginRequestConfig) error {
+	o.log = log
+	o.transport = newLazyTransport(o, cfg, log)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-shutdownC
+		cancel()
+	}()
+	go o.watch(ctx)
+	return nil
+}
+
+// watch keeps the pool up to date with the registry, reconnecting to it with backoff.
+func (o *discoveredService) watch(ctx context.Context) {
+	backoff := retry.BackoffHandler{RetryForever: true, MaxRetries: 5, MaxBackoff: discoveryMaxBackoff}
+	for {
+		err := o.discoverer.watch(ctx, func(addresses []weightedAddress) {
+			backoff.ResetNow()
+			if o.pool.set(addresses) {
+				o.log.Info().Str("service", o.service).Strs("origins", o.pool.list()).Msg("Discovered origins changed")
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		o.log.Err(err).Str("service", o.service).Msg("Failed to watch the service registry, the last discovered origins are kept")
+		if !backoff.Backoff(ctx) {
+			return
+		}
+	}
+}
+
+func (o *discoveredService) RoundTrip(req *http.Request) (*http.Response, error) {
+	address, ok := o.pool.pick()
+	if !ok {
+		return nil, fmt.Errorf("no origin has been discovered for %s", o.service)
+	}
+	req.URL.Scheme = o.scheme
+	req.URL.Host = address
+	return o.transport.RoundTrip(req)
+}
+
+// parseDiscoveredAddress returns the host:port of an address published in a registry, which can be a URL.
+func parseDiscoveredAddress(address string) (string, bool) {
+	address = strings.TrimSpace(address)
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return "", false
+		}
+		address = u.Host
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", false
+	}
+	return address, true
+}
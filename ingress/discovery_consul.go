@@ -0,0 +1,124 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	consulDefaultAddress = "http://127.0.0.1:8500"
+	// consulWait is how long a blocking query waits for the service to change
+	consulWait = "5m"
+)
+
+// consulDiscoverer finds the healthy instances of a Consul service, watching them with blocking queries.
+type consulDiscoverer struct {
+	client  *http.Client
+	address string
+	service string
+	tag     string
+	token   string
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func newConsulDiscoverer(u *url.URL, cfg *config.DiscoveryConfig) (*consulDiscoverer, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("the Consul service name is missing, e.g. consul://web")
+	}
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = consulDefaultAddress
+	} else if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	return &consulDiscoverer{
+		client:  &http.Client{},
+		address: strings.TrimSuffix(address, "/"),
+		service: u.Host,
+		tag:     u.Query().Get("tag"),
+		token:   token,
+	}, nil
+}
+
+func (d *consulDiscoverer) watch(ctx context.Context, update func(addresses []weightedAddress)) error {
+	index := uint64(0)
+	for {
+		addresses, newIndex, err := d.query(ctx, index)
+		if err != nil {
+			return err
+		}
+		update(equallyWeighted(addresses))
+		if newIndex < index {
+			// The index going backwards means that Consul's state was reset
+			newIndex = 0
+		} else if newIndex == 0 {
+			// Queries for index 0 don't block, this one would be sent again right away
+			newIndex = 1
+		}
+		index = newIndex
+	}
+}
+
+// query returns the addresses of the healthy instances of the service once their index passes index.
+func (d *consulDiscoverer) query(ctx context.Context, index uint64) ([]string, uint64, error) {
+	params := url.Values{"passing": {"true"}, "index": {strconv.FormatUint(index, 10)}, "wait": {consulWait}}
+	if d.tag != "" {
+		params.Set("tag", d.tag)
+	}
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s?%s", d.address, url.PathEscape(d.service), params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.token != "" {
+		req.Header.Set("X-Consul-Token", d.token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul responded with %s", resp.Status)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode the consul response: %w", err)
+	}
+	addresses := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return addresses, newIndex, nil
+}
@@ -0,0 +1,169 @@
+package ingress
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const etcdDefaultAddress = "http://127.0.0.1:2379"
+
+// etcdDiscoverer finds the addresses stored under a key prefix of etcd, through its JSON gateway. Every key under the
+// prefix holds one address, as host:port or as a URL.
+type etcdDiscoverer struct {
+	client  *http.Client
+	address string
+	prefix  string
+	token   string
+}
+
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []struct {
+		Value string `json:"value"`
+	} `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Events []json.RawMessage `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newEtcdDiscoverer(u *url.URL, cfg *config.DiscoveryConfig) (*etcdDiscoverer, error) {
+	// etcd://services/web and etcd:///services/web/ both name a key prefix
+	prefix := u.Host + u.Path
+	if prefix == "" {
+		return nil, fmt.Errorf("the etcd key prefix is missing, e.g. etcd:///services/web/")
+	}
+	address := cfg.Address
+	if address == "" {
+		address = etcdDefaultAddress
+	} else if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &etcdDiscoverer{
+		client:  &http.Client{},
+		address: strings.TrimSuffix(address, "/"),
+		prefix:  prefix,
+		token:   cfg.Token,
+	}, nil
+}
+
+func (d *etcdDiscoverer) watch(ctx context.Context, update func(addresses []weightedAddress)) error {
+	addresses, revision, err := d.list(ctx)
+	if err != nil {
+		return err
+	}
+	update(equallyWeighted(addresses))
+
+	body := map[string]any{
+		"create_request": map[string]any{
+			"key":            d.key(),
+			"range_end":      d.rangeEnd(),
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	}
+	resp, err := d.post(ctx, "/v3/watch", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var watchResp etcdWatchResponse
+		if err := decoder.Decode(&watchResp); err != nil {
+			return fmt.Errorf("etcd watch ended: %w", err)
+		}
+		if watchResp.Error != nil {
+			return fmt.Errorf("etcd watch failed: %s", watchResp.Error.Message)
+		}
+		if watchResp.Result == nil || len(watchResp.Result.Events) == 0 {
+			continue
+		}
+		// Listing the prefix again is simpler than applying the events, and changes are rare
+		if addresses, _, err = d.list(ctx); err != nil {
+			return err
+		}
+		update(equallyWeighted(addresses))
+	}
+}
+
+// list returns the addresses under the prefix, and the revision they were read at.
+func (d *etcdDiscoverer) list(ctx context.Context) ([]string, int64, error) {
+	resp, err := d.post(ctx, "/v3/kv/range", map[string]any{"key": d.key(), "range_end": d.rangeEnd()})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	var rangeResp etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rangeResp); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode the etcd response: %w", err)
+	}
+	var addresses []string
+	for _, kv := range rangeResp.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			continue
+		}
+		if address, ok := parseDiscoveredAddress(string(value)); ok {
+			addresses = append(addresses, address)
+		}
+	}
+	revision, _ := strconv.ParseInt(rangeResp.Header.Revision, 10, 64)
+	return addresses, revision, nil
+}
+
+func (d *etcdDiscoverer) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.address+path, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.token != "" {
+		req.Header.Set("Authorization", d.token)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd responded with %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (d *etcdDiscoverer) key() string {
+	return base64.StdEncoding.EncodeToString([]byte(d.prefix))
+}
+
+// rangeEnd returns the end of the range of keys starting with the prefix: the prefix with its last byte incremented.
+func (d *etcdDiscoverer) rangeEnd() string {
+	end := []byte(d.prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return base64.StdEncoding.EncodeToString(end[:i+1])
+		}
+	}
+	// Every byte is 0xff, so the range ends with the last key
+	return base64.StdEncoding.EncodeToString([]byte{0})
+}
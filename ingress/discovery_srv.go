@@ -0,0 +1,101 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const srvDefaultRefreshInterval = 30 * time.Second
+
+// srvDiscoverer resolves the SRV records of a service periodically. Only the targets with the lowest priority are
+// used, balanced by their weight, since higher priorities are meant as fallbacks.
+type srvDiscoverer struct {
+	resolver        *net.Resolver
+	name            string
+	refreshInterval time.Duration
+}
+
+func newSRVDiscoverer(u *url.URL, cfg *config.DiscoveryConfig) (*srvDiscoverer, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("the SRV record name is missing, e.g. srv://_web._tcp.example.com")
+	}
+	refreshInterval := srvDefaultRefreshInterval
+	if cfg.RefreshInterval != nil {
+		if cfg.RefreshInterval.Duration <= 0 {
+			return nil, fmt.Errorf("the SRV refresh interval must be positive")
+		}
+		refreshInterval = cfg.RefreshInterval.Duration
+	}
+	resolver := net.DefaultResolver
+	if cfg.Address != "" {
+		server := cfg.Address
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		dialer := net.Dialer{}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &srvDiscoverer{
+		resolver:        resolver,
+		name:            u.Host,
+		refreshInterval: refreshInterval,
+	}, nil
+}
+
+func (d *srvDiscoverer) watch(ctx context.Context, update func(addresses []weightedAddress)) error {
+	ticker := time.NewTicker(d.refreshInterval)
+	defer ticker.Stop()
+	for {
+		addresses, err := d.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		update(addresses)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve returns the targets of the SRV records with the lowest priority.
+func (d *srvDiscoverer) resolve(ctx context.Context) ([]weightedAddress, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []weightedAddress
+	var priority uint16
+	for _, record := range records {
+		// A target of "." means that the service is decidedly not available
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			continue
+		}
+		if len(addresses) > 0 && record.Priority > priority {
+			continue
+		}
+		if len(addresses) == 0 || record.Priority < priority {
+			addresses = addresses[:0]
+			priority = record.Priority
+		}
+		addresses = append(addresses, weightedAddress{
+			address: net.JoinHostPort(target, strconv.Itoa(int(record.Port))),
+			weight:  record.Weight,
+		})
+	}
+	return addresses, nil
+}
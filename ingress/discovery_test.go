@@ -0,0 +1,311 @@
+package ingress
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestOriginPool(t *testing.T) {
+	var pool originPool
+	_, ok := pool.pick()
+	assert.False(t, ok)
+
+	assert.True(t, pool.set(equallyWeighted([]string{"10.0.0.2:80", "10.0.0.1:80", "10.0.0.2:80"})))
+	assert.False(t, pool.set(equallyWeighted([]string{"10.0.0.1:80", "10.0.0.2:80"})))
+	var picked []string
+	for i := 0; i < 4; i++ {
+		member, ok := pool.pick()
+		require.True(t, ok)
+		picked = append(picked, member)
+	}
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.1:80", "10.0.0.2:80"}, picked)
+
+	// Members that are down are skipped until they're back
+	assert.True(t, pool.markDown("10.0.0.1:80", time.Now().Add(time.Hour)))
+	assert.False(t, pool.markDown("10.0.0.3:80", time.Now().Add(time.Hour)))
+	for i := 0; i < 2; i++ {
+		member, ok := pool.pick()
+		require.True(t, ok)
+		assert.Equal(t, "10.0.0.2:80", member)
+	}
+	assert.True(t, pool.markDown("10.0.0.2:80", time.Now().Add(time.Hour)))
+	_, ok = pool.pick()
+	assert.False(t, ok)
+	_, ok = pool.pickAny()
+	assert.True(t, ok)
+	assert.True(t, pool.markDown("10.0.0.1:80", time.Now()))
+	member, ok := pool.pick()
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.1:80", member)
+}
+
+func TestOriginPoolWeighted(t *testing.T) {
+	var pool originPool
+	assert.True(t, pool.set([]weightedAddress{
+		{address: "10.0.0.1:80", weight: 3},
+		{address: "10.0.0.2:80", weight: 1},
+		{address: "10.0.0.3:80", weight: 0},
+	}))
+	picked := map[string]int{}
+	var first []string
+	for i := 0; i < 8; i++ {
+		member, ok := pool.pick()
+		require.True(t, ok)
+		picked[member]++
+		if i < 4 {
+			first = append(first, member)
+		}
+	}
+	assert.Equal(t, map[string]int{"10.0.0.1:80": 6, "10.0.0.2:80": 2}, picked)
+	// Smooth weighted round-robin doesn't send the heavier member all its requests in a row
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.1:80", "10.0.0.2:80", "10.0.0.1:80"}, first)
+
+	assert.True(t, pool.set([]weightedAddress{{address: "10.0.0.1:80", weight: 1}, {address: "10.0.0.2:80", weight: 1}}))
+	assert.False(t, pool.weighted)
+}
+
+func TestConsulDiscoverer(t *testing.T) {
+	var queries int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/web", r.URL.Path)
+		assert.Equal(t, "primary", r.URL.Query().Get("tag"))
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		switch atomic.AddInt32(&queries, 1) {
+		case 1:
+			assert.Equal(t, "0", r.URL.Query().Get("index"))
+			w.Header().Set("X-Consul-Index", "5")
+			fmt.Fprint(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"Port":8080}},{"Node":{"Address":"10.0.0.9"},"Service":{"Address":"10.0.0.2","Port":8080}}]`)
+		case 2:
+			assert.Equal(t, "5", r.URL.Query().Get("index"))
+			w.Header().Set("X-Consul-Index", "6")
+			fmt.Fprint(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"Port":8080}}]`)
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	// Closed after the watch is canceled, since the server waits for the blocked queries
+	t.Cleanup(registry.Close)
+
+	u, err := url.Parse("consul://web?tag=primary")
+	require.NoError(t, err)
+	d, err := newConsulDiscoverer(u, &config.DiscoveryConfig{Address: registry.URL, Token: "secret"})
+	require.NoError(t, err)
+
+	updates := watchUpdates(t, d)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, <-updates)
+	assert.Equal(t, []string{"10.0.0.1:8080"}, <-updates)
+}
+
+func TestConsulDiscovererWithoutIndex(t *testing.T) {
+	indexes := make(chan string, 2)
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		indexes <- r.URL.Query().Get("index")
+		if r.URL.Query().Get("index") != "0" {
+			<-r.Context().Done()
+			return
+		}
+		// A proxy in front of Consul could drop X-Consul-Index
+		fmt.Fprint(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"Port":8080}}]`)
+	}))
+	t.Cleanup(registry.Close)
+
+	u, err := url.Parse("consul://web")
+	require.NoError(t, err)
+	d, err := newConsulDiscoverer(u, &config.DiscoveryConfig{Address: registry.URL})
+	require.NoError(t, err)
+
+	updates := watchUpdates(t, d)
+	assert.Equal(t, []string{"10.0.0.1:8080"}, <-updates)
+	assert.Equal(t, "0", <-indexes)
+	assert.Equal(t, "1", <-indexes)
+}
+
+func TestEtcdDiscoverer(t *testing.T) {
+	var lists int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			assert.Contains(t, string(body), base64.StdEncoding.EncodeToString([]byte("/services/web/")))
+			values := []string{"10.0.0.1:8080", "http://10.0.0.2:8080", "not an address"}
+			if atomic.AddInt32(&lists, 1) > 1 {
+				values = values[1:]
+			}
+			kvs := ""
+			for i, value := range values {
+				if i > 0 {
+					kvs += ","
+				}
+				kvs += fmt.Sprintf(`{"value":%q}`, base64.StdEncoding.EncodeToString([]byte(value)))
+			}
+			fmt.Fprintf(w, `{"header":{"revision":"7"},"kvs":[%s]}`, kvs)
+		case "/v3/watch":
+			assert.Contains(t, string(body), `"start_revision":"8"`)
+			fmt.Fprintln(w, `{"result":{"created":true}}`)
+			fmt.Fprintln(w, `{"result":{"events":[{"type":"DELETE"}]}}`)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(registry.Close)
+
+	u, err := url.Parse("etcd:///services/web/")
+	require.NoError(t, err)
+	d, err := newEtcdDiscoverer(u, &config.DiscoveryConfig{Address: registry.URL})
+	require.NoError(t, err)
+
+	updates := watchUpdates(t, d)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, <-updates)
+	assert.Equal(t, []string{"10.0.0.2:8080"}, <-updates)
+}
+
+func TestDiscoveredService(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("discovered"))
+	}))
+	defer origin.Close()
+	originURL, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("index") != "0" {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprintf(w, `[{"Service":{"Address":"%s","Port":%s}}]`, originURL.Hostname(), originURL.Port())
+	}))
+	t.Cleanup(registry.Close)
+
+	rawYAML := fmt.Sprintf(`
+ingress:
+- hostname: app.example.com
+  service: consul://web
+  originRequest:
+    discovery:
+      address: %s
+- service: http_status:404
+`, registry.URL)
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "consul://web", ing.Rules[0].Service.String())
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+
+	service := ing.Rules[0].Service.(*discoveredService)
+	require.Eventually(t, func() bool {
+		return len(service.pool.list()) == 1
+	}, time.Second, 10*time.Millisecond)
+	req, err := http.NewRequest(http.MethodGet, "http://app.example.com/", nil)
+	require.NoError(t, err)
+	resp, err := service.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "discovered", string(body))
+
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+- service: consul://web
+  originRequest:
+    discovery:
+      scheme: ftp
+`))
+	assert.Error(t, err)
+}
+
+func watchUpdates(t *testing.T, d discoverer) <-chan []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	updates := make(chan []string, 10)
+	go func() {
+		_ = d.watch(ctx, func(weighted []weightedAddress) {
+			addresses := make([]string, len(weighted))
+			for i, address := range weighted {
+				addresses[i] = address.address
+			}
+			updates <- addresses
+		})
+	}()
+	return updates
+}
+
+func TestSRVDiscoverer(t *testing.T) {
+	var priority uint32 = 10
+	mux := dns.NewServeMux()
+	mux.HandleFunc("_web._tcp.example.com.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		header := dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: 60}
+		m.Answer = []dns.RR{
+			&dns.SRV{Hdr: header, Priority: 20, Weight: 1, Port: 8080, Target: "backup.example.com."},
+			&dns.SRV{Hdr: header, Priority: uint16(atomic.LoadUint32(&priority)), Weight: 3, Port: 8080, Target: "web-1.example.com."},
+			&dns.SRV{Hdr: header, Priority: 10, Weight: 1, Port: 8081, Target: "web-2.example.com."},
+		}
+		_ = w.WriteMsg(m)
+	})
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{PacketConn: conn, Handler: mux}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+
+	u, err := url.Parse("srv://_web._tcp.example.com")
+	require.NoError(t, err)
+	d, err := newSRVDiscoverer(u, &config.DiscoveryConfig{
+		Address:         conn.LocalAddr().String(),
+		RefreshInterval: &config.CustomDuration{Duration: 10 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	updates := make(chan []weightedAddress, 10)
+	go func() {
+		_ = d.watch(ctx, func(addresses []weightedAddress) {
+			select {
+			case updates <- addresses:
+			default:
+			}
+		})
+	}()
+	assert.ElementsMatch(t, []weightedAddress{
+		{address: "web-1.example.com:8080", weight: 3},
+		{address: "web-2.example.com:8081", weight: 1},
+	}, <-updates)
+
+	atomic.StoreUint32(&priority, 5)
+	require.Eventually(t, func() bool {
+		addresses := <-updates
+		return len(addresses) == 1 && addresses[0] == weightedAddress{address: "web-1.example.com:8080", weight: 3}
+	}, time.Second, time.Millisecond)
+
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+- service: srv://_web._tcp.example.com
+  originRequest:
+    discovery:
+      refreshInterval: -1s
+`))
+	assert.Error(t, err)
+}
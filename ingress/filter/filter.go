@@ -0,0 +1,228 @@
+// Package filter evaluates expressions over the attributes of eyeball requests, so ingress rules can allow, deny or
+// reroute requests based on more than their hostname and path.
+//
+// Expressions are written in the Common Expression Language (CEL), evaluated by cel-go, with its string extension,
+// e.g. lowerAscii, and the inCIDR method, which checks whether a string is an IP address in the given network. The
+// patterns of matches and the networks of inCIDR must be literals: they're compiled along with the expression rather
+// than for each request.
+//
+// The request is available as the request variable, with the fields:
+//
+//	request.method  - the HTTP method, e.g. "GET"
+//	request.host    - the Host header
+//	request.path    - the path, without the query string
+//	request.query   - the raw query string
+//	request.headers - a map of the request headers, with lowercase names. Repeated headers are joined with ", "
+//	request.ip      - the IP address of the eyeball client
+//	request.country - the two letter country code of the eyeball client
+package filter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/interpreter"
+)
+
+const (
+	methodAttribute  = "request.method"
+	hostAttribute    = "request.host"
+	pathAttribute    = "request.path"
+	queryAttribute   = "request.query"
+	headersAttribute = "request.headers"
+	ipAttribute      = "request.ip"
+	countryAttribute = "request.country"
+
+	inCIDRFunction  = "inCIDR"
+	inCIDROverload  = "string_inCIDR_string"
+	matchesFunction = "matches"
+
+	// Headers set by the edge with information about the eyeball client.
+	clientIPHeader      = "Cf-Connecting-Ip"
+	clientCountryHeader = "Cf-Ipcountry"
+)
+
+var (
+	envOnce sync.Once
+	env     *cel.Env
+	envErr  error
+)
+
+// filterEnv returns the CEL environment that expressions are compiled in.
+func filterEnv() (*cel.Env, error) {
+	envOnce.Do(func() {
+		env, envErr = cel.NewEnv(
+			cel.Variable(methodAttribute, cel.StringType),
+			cel.Variable(hostAttribute, cel.StringType),
+			cel.Variable(pathAttribute, cel.StringType),
+			cel.Variable(queryAttribute, cel.StringType),
+			cel.Variable(headersAttribute, cel.MapType(cel.StringType, cel.StringType)),
+			cel.Variable(ipAttribute, cel.StringType),
+			cel.Variable(countryAttribute, cel.StringType),
+			ext.Strings(),
+			cel.Function(inCIDRFunction,
+				cel.MemberOverload(inCIDROverload, []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+					cel.BinaryBinding(func(ip, cidr ref.Val) ref.Val {
+						_, network, err := net.ParseCIDR(string(cidr.(types.String)))
+						if err != nil {
+							return types.NewErr("%s isn't a network: %v", cidr, err)
+						}
+						return inCIDR(ip, network)
+					}),
+				),
+			),
+		)
+	})
+	return env, envErr
+}
+
+// inCIDROptimization parses the networks of inCIDR when the expression is compiled, like cel-go compiles the patterns
+// of matches.
+var inCIDROptimization = &interpreter.RegexOptimization{
+	Function:   inCIDRFunction,
+	OverloadID: inCIDROverload,
+	RegexIndex: 1,
+	Factory: func(call interpreter.InterpretableCall, cidr string) (interpreter.InterpretableCall, error) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		return interpreter.NewCall(call.ID(), call.Function(), call.OverloadID(), call.Args(), func(values ...ref.Val) ref.Val {
+			if len(values) != 2 {
+				return types.NoSuchOverloadErr()
+			}
+			return inCIDR(values[0], network)
+		}), nil
+	},
+}
+
+func inCIDR(ip ref.Val, network *net.IPNet) ref.Val {
+	parsed := net.ParseIP(string(ip.(types.String)))
+	return types.Bool(parsed != nil && network.Contains(parsed))
+}
+
+// requireLiteralArguments rejects the calls to matches and inCIDR whose pattern or network isn't a literal, which
+// would have to be compiled for each request.
+func requireLiteralArguments(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	call, ok := i.(interpreter.InterpretableCall)
+	if !ok {
+		return i, nil
+	}
+	switch call.Function() {
+	case matchesFunction, inCIDRFunction:
+		args := call.Args()
+		if len(args) != 2 {
+			return i, nil
+		}
+		if _, ok := args[1].(interpreter.InterpretableConst); !ok {
+			return nil, fmt.Errorf("the argument of %s must be a literal", call.Function())
+		}
+	}
+	return i, nil
+}
+
+// Expression is a compiled filter expression. It's safe for concurrent use.
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+// Compile parses expr, checks that it only refers to known attributes and functions and evaluates to a bool, and
+// compiles its patterns and networks.
+func Compile(expr string) (*Expression, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	env, err := filterEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid expression %q: it evaluates to %s rather than bool", expr, ast.OutputType())
+	}
+	program, err := env.Program(ast,
+		cel.EvalOptions(cel.OptOptimize),
+		cel.CustomDecorator(requireLiteralArguments),
+		cel.OptimizeRegex(inCIDROptimization),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+	return &Expression{source: expr, program: program}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression against the request described by attrs.
+func (e *Expression) Eval(attrs Attributes) (bool, error) {
+	out, _, err := e.program.Eval(attrs.activation())
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate %q: %w", e.source, err)
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("failed to evaluate %q: it evaluated to %v rather than a bool", e.source, out)
+	}
+	return matched, nil
+}
+
+// Attributes are the parts of a request that expressions can refer to.
+type Attributes struct {
+	Method  string
+	Host    string
+	Path    string
+	Query   string
+	Headers http.Header
+	IP      string
+	Country string
+}
+
+// AttributesFromRequest extracts the attributes of an eyeball request proxied by the edge.
+func AttributesFromRequest(r *http.Request) Attributes {
+	host := r.Host
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	attrs := Attributes{
+		Method:  r.Method,
+		Host:    host,
+		Headers: r.Header,
+		IP:      r.Header.Get(clientIPHeader),
+		Country: r.Header.Get(clientCountryHeader),
+	}
+	if r.URL != nil {
+		attrs.Path = r.URL.Path
+		attrs.Query = r.URL.RawQuery
+	}
+	return attrs
+}
+
+func (a Attributes) activation() map[string]interface{} {
+	headers := make(map[string]string, len(a.Headers))
+	for name, values := range a.Headers {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return map[string]interface{}{
+		methodAttribute:  a.Method,
+		hostAttribute:    a.Host,
+		pathAttribute:    a.Path,
+		queryAttribute:   a.Query,
+		headersAttribute: headers,
+		ipAttribute:      a.IP,
+		countryAttribute: a.Country,
+	}
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAttributes() Attributes {
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.com/v1/users?limit=10", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Cf-Connecting-Ip", "198.51.100.7")
+	req.Header.Set("Cf-Ipcountry", "NZ")
+	return AttributesFromRequest(req)
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{expr: `request.method == "POST"`, expected: true},
+		{expr: `request.method != 'POST'`, expected: false},
+		{expr: `request.host == "api.example.com" && request.path.startsWith("/v1/")`, expected: true},
+		{expr: `request.path.endsWith("/admin") || request.query.contains("limit=")`, expected: true},
+		{expr: `request.path.matches("^/v[0-9]+/users$")`, expected: true},
+		{expr: `request.country in ["US", "CA"]`, expected: false},
+		{expr: `!(request.country in ["US", "CA"])`, expected: true},
+		{expr: `request.ip.inCIDR("198.51.100.0/24")`, expected: true},
+		{expr: `request.ip.inCIDR("10.0.0.0/8")`, expected: false},
+		{expr: `request.headers["x-api-key"] == "secret"`, expected: true},
+		{expr: `request.headers.accept == "text/html, application/json"`, expected: true},
+		{expr: `"authorization" in request.headers`, expected: false},
+		{expr: `size(request.path) > 5 && size(request.headers) >= 4`, expected: true},
+		{expr: `request.method.lowerAscii() == "post"`, expected: true},
+		{expr: `[1, 2][1] == 2`, expected: true},
+		{expr: `-1 < 0`, expected: true},
+		// Errors on one side of a logical operator are ignored if the other side decides the result
+		{expr: `request.headers["authorization"] == "token" || true`, expected: true},
+		{expr: `false && request.headers["authorization"] == "token"`, expected: false},
+	}
+	attrs := testAttributes()
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, err := Compile(test.expr)
+			require.NoError(t, err)
+			matched, err := expr.Eval(attrs)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, matched)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		`request.headers["authorization"] == "token"`,
+		`request.headers.authorization.startsWith("Bearer ")`,
+	}
+	attrs := testAttributes()
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			expr, err := Compile(test)
+			require.NoError(t, err)
+			_, err = expr.Eval(attrs)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`request.method ==`,
+		`response.status == 200`,
+		`request.path.unknown("/")`,
+		`startsWith("/")`,
+		`request.path.startsWith("/", "/")`,
+		`request.path.matches("[")`,
+		`request.ip.inCIDR("10.0.0.0")`,
+		`request.method == "GET`,
+		`request.method == "GET" )`,
+		`request.method = "GET"`,
+		// Types are checked when compiling
+		`request.method`,
+		`request.method == 1`,
+		`request.path < 1`,
+		`request.method.startsWith(1)`,
+		// Patterns and networks must be literals
+		`request.path.matches(request.headers["x-pattern"])`,
+		`request.ip.inCIDR(request.headers["x-network"])`,
+	}
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			_, err := Compile(test)
+			assert.Error(t, err)
+		})
+	}
+}
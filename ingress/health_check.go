@@ -0,0 +1,264 @@
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	defaultHealthCheckPath     = "/"
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	// healthCheckMaxBodyBytes bounds how much of the response to a health check is read, so its connection is reused
+	healthCheckMaxBodyBytes = 64 * 1024
+
+	unhealthyOriginMessage = "The origin of this hostname is unhealthy\n"
+)
+
+var originHealth = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "origin",
+		Name:      "healthy",
+		Help:      "Whether an origin passed its last health check (1) or not (0)",
+	},
+	[]string{"origin"},
+)
+
+func init() {
+	prometheus.MustRegister(originHealth)
+}
+
+// HealthCheck periodically requests a path from the origins of a rule, configured with the healthCheck origin
+// request setting. The origins of a list that fail their health check don't get requests, and the requests for a rule
+// that has no healthy origin are answered right away with a 503 and ErrorPage, instead of after the connect timeout.
+type HealthCheck struct {
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// ExpectedStatus is the status code of healthy origins, or 0 for any 2xx or 3xx status.
+	ExpectedStatus int
+	// ErrorPage is the body of the responses while no origin is healthy.
+	ErrorPage []byte
+
+	// host is the Host header of the checks, if the rule has a single hostname
+	host    string
+	targets []*healthCheckTarget
+}
+
+// healthCheckTarget is an origin of a rule and the result of its last health check.
+type healthCheckTarget struct {
+	origin  HTTPOriginProxy
+	name    string
+	healthy atomic.Bool
+	// setHealthy is called with the result of every check
+	setHealthy func(healthy bool)
+
+	lock      sync.Mutex
+	lastCheck time.Time
+	lastError error
+}
+
+// OriginHealth is the result of the last health check of an origin.
+type OriginHealth struct {
+	// Rule is the index of the ingress rule of the origin
+	Rule      int       `json:"rule"`
+	Origin    string    `json:"origin"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"lastCheck"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// newHealthCheck returns nil if the service isn't an HTTP origin, a unix socket or a list of origins.
+func newHealthCheck(cfg *config.HealthCheckConfig, service OriginService, hostname string) (*HealthCheck, error) {
+	h := &HealthCheck{
+		Path:           defaultHealthCheckPath,
+		Interval:       defaultHealthCheckInterval,
+		Timeout:        defaultHealthCheckTimeout,
+		ExpectedStatus: cfg.ExpectedStatus,
+		ErrorPage:      []byte(unhealthyOriginMessage),
+	}
+	if cfg.Path != "" {
+		h.Path = cfg.Path
+	}
+	if h.Path[0] != '/' {
+		return nil, fmt.Errorf("health check path %s must start with /", h.Path)
+	}
+	if cfg.Interval != nil {
+		h.Interval = cfg.Interval.Duration
+	}
+	if cfg.Timeout != nil {
+		h.Timeout = cfg.Timeout.Duration
+	}
+	if h.Interval <= 0 || h.Timeout <= 0 {
+		return nil, errors.New("health check interval and timeout must be positive")
+	}
+	if h.ExpectedStatus != 0 && (h.ExpectedStatus < 100 || h.ExpectedStatus > 599) {
+		return nil, fmt.Errorf("health check expected status %d isn't a valid status code", h.ExpectedStatus)
+	}
+	if cfg.ErrorPage != "" {
+		page, err := os.ReadFile(cfg.ErrorPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the health check error page")
+		}
+		h.ErrorPage = page
+	}
+	if hostname != "" && hostname != "*" && hostname[0] != '*' {
+		h.host = hostname
+	}
+
+	switch service := service.(type) {
+	case *httpService:
+		h.targets = []*healthCheckTarget{{origin: service, name: service.String()}}
+	case *unixSocketPath:
+		h.targets = []*healthCheckTarget{{origin: service, name: service.String()}}
+	case *originGroup:
+		for _, member := range service.members {
+			address := member.String()
+			h.targets = append(h.targets, &healthCheckTarget{
+				origin: member,
+				name:   address,
+				setHealthy: func(healthy bool) {
+					service.primary.setHealthy(address, healthy)
+					service.backup.setHealthy(address, healthy)
+				},
+			})
+		}
+	default:
+		// Like the other origin request settings, healthCheck can be set for all the rules, some of which don't have
+		// origins to check, e.g. http_status:404
+		return nil, nil
+	}
+	// Origins are healthy until they fail a check
+	for _, target := range h.targets {
+		target.healthy.Store(true)
+	}
+	return h, nil
+}
+
+// Healthy tells whether any origin of the rule is healthy.
+func (h *HealthCheck) Healthy() bool {
+	for _, target := range h.targets {
+		if target.healthy.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// start checks every origin until shutdownC is closed, right away and then every Interval.
+func (h *HealthCheck) start(log *zerolog.Logger, shutdownC <-chan struct{}) {
+	for _, target := range h.targets {
+		go h.watch(target, log, shutdownC)
+	}
+}
+
+func (h *HealthCheck) watch(target *healthCheckTarget, log *zerolog.Logger, shutdownC <-chan struct{}) {
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		h.run(target, log)
+		select {
+		case <-shutdownC:
+			originHealth.DeleteLabelValues(target.name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthCheck) run(target *healthCheckTarget, log *zerolog.Logger) {
+	err := h.check(target.origin)
+	healthy := err == nil
+	target.lock.Lock()
+	target.lastCheck = time.Now()
+	target.lastError = err
+	target.lock.Unlock()
+
+	if target.setHealthy != nil {
+		target.setHealthy(healthy)
+	}
+	if healthy {
+		originHealth.WithLabelValues(target.name).Set(1)
+	} else {
+		originHealth.WithLabelValues(target.name).Set(0)
+	}
+	if wasHealthy := target.healthy.Swap(healthy); wasHealthy != healthy {
+		if healthy {
+			log.Info().Str("origin", target.name).Msg("Origin passed its health check and is healthy again")
+		} else {
+			log.Warn().Err(err).Str("origin", target.name).Msg("Origin failed its health check and is unhealthy")
+		}
+	}
+}
+
+func (h *HealthCheck) check(origin HTTPOriginProxy) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	// The origin service rewrites the URL to reach the origin
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+h.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = h.host
+	req.Header.Set("User-Agent", "cloudflared-health-check")
+	resp, err := origin.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, healthCheckMaxBodyBytes))
+	if !h.isExpectedStatus(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *HealthCheck) isExpectedStatus(status int) bool {
+	if h.ExpectedStatus != 0 {
+		return status == h.ExpectedStatus
+	}
+	return status >= 200 && status < 400
+}
+
+// status returns the result of the last check of every origin.
+func (h *HealthCheck) status(rule int) []OriginHealth {
+	statuses := make([]OriginHealth, len(h.targets))
+	for i, target := range h.targets {
+		target.lock.Lock()
+		statuses[i] = OriginHealth{
+			Rule:      rule,
+			Origin:    target.name,
+			Healthy:   target.healthy.Load(),
+			LastCheck: target.lastCheck,
+		}
+		if target.lastError != nil {
+			statuses[i].LastError = target.lastError.Error()
+		}
+		target.lock.Unlock()
+	}
+	return statuses
+}
+
+// OriginHealth returns the result of the last health check of the origins of every rule that has health checks.
+func (ing Ingress) OriginHealth() []OriginHealth {
+	var statuses []OriginHealth
+	for i, rule := range ing.Rules {
+		if rule.HealthCheck != nil {
+			statuses = append(statuses, rule.HealthCheck.status(i)...)
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,124 @@
+package ingress
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheck(t *testing.T) {
+	var failing atomic.Bool
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/healthz", r.URL.Path)
+		assert.Equal(t, "app.example.com", r.Host)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(origin.Close)
+	errorPage := filepath.Join(t.TempDir(), "down.html")
+	require.NoError(t, os.WriteFile(errorPage, []byte("<h1>Down</h1>"), 0o600))
+
+	ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+ingress:
+  - hostname: app.example.com
+    service: %s
+    originRequest:
+      healthCheck:
+        path: /healthz
+        interval: 10ms
+        expectedStatus: 204
+        errorPage: %s
+  - service: http_status:404
+`, origin.URL, errorPage)))
+	require.NoError(t, err)
+	healthCheck := ing.Rules[0].HealthCheck
+	require.NotNil(t, healthCheck)
+	assert.Equal(t, []byte("<h1>Down</h1>"), healthCheck.ErrorPage)
+	assert.Nil(t, ing.Rules[1].HealthCheck)
+
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+	assert.True(t, healthCheck.Healthy())
+
+	failing.Store(true)
+	require.Eventually(t, func() bool { return !healthCheck.Healthy() }, time.Second, 5*time.Millisecond)
+	health := ing.OriginHealth()
+	require.Len(t, health, 1)
+	assert.Equal(t, origin.URL, health[0].Origin)
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, "unexpected status 500", health[0].LastError)
+
+	failing.Store(false)
+	require.Eventually(t, healthCheck.Healthy, time.Second, 5*time.Millisecond)
+}
+
+func TestHealthCheckOriginGroup(t *testing.T) {
+	var failing atomic.Bool
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("flaky"))
+	}))
+	t.Cleanup(flaky.Close)
+	backup := newNamedOrigin(t, "backup")
+
+	ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+ingress:
+  - service:
+      - url: %s
+      - url: %s
+        backup: true
+    originRequest:
+      healthCheck:
+        interval: 10ms
+`, flaky.URL, backup.URL)))
+	require.NoError(t, err)
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+
+	name, err := roundTripOriginName(t, ing.Rules[0].Service, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "flaky", name)
+
+	// The backup origin gets the requests while the other one is unhealthy, so the rule is still healthy
+	failing.Store(true)
+	require.Eventually(t, func() bool {
+		name, err := roundTripOriginName(t, ing.Rules[0].Service, nil)
+		return err == nil && name == "backup"
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, ing.Rules[0].HealthCheck.Healthy())
+	assert.Len(t, ing.OriginHealth(), 2)
+}
+
+func TestParseHealthCheckErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"{path: healthz}",
+		"{interval: 0s}",
+		"{timeout: -1s}",
+		"{expectedStatus: 1000}",
+		"{errorPage: /nonexistent/down.html}",
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      healthCheck: ` + invalid + `
+`))
+		assert.Error(t, err, invalid)
+	}
+}
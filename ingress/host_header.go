@@ -0,0 +1,86 @@
+package ingress
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// httpHostHeader can be a template that refers to the public hostname of the request, so that a rule with a wildcard
+// hostname can serve many virtual hosts of the origin:
+//   - $1 or ${1} is replaced by the labels matched by the * of a rule hostname like *.apps.example.com
+//   - ${host} is replaced by the public hostname
+//
+// For example, with the hostname *.apps.example.com and the httpHostHeader $1.internal:8080, a request to
+// blog.apps.example.com is sent to the origin with the Host blog.internal:8080.
+const (
+	hostHeaderWildcardVar = "1"
+	hostHeaderHostVar     = "host"
+)
+
+func isHostHeaderTemplate(hostHeader string) bool {
+	return strings.Contains(hostHeader, "$")
+}
+
+// validateHostHeaderTemplate checks that a templated httpHostHeader only uses variables the rule can provide.
+func validateHostHeaderTemplate(hostHeader, ruleHostname string) error {
+	var err error
+	os.Expand(hostHeader, func(name string) string {
+		switch {
+		case err != nil:
+		case name == hostHeaderHostVar:
+		case name == hostHeaderWildcardVar && strings.HasPrefix(ruleHostname, "*."):
+		case name == hostHeaderWildcardVar:
+			err = fmt.Errorf("httpHostHeader %s uses $1, which needs a hostname starting with *.", hostHeader)
+		default:
+			err = fmt.Errorf("httpHostHeader %s uses the unknown variable %s, only $1 and ${host} are supported", hostHeader, name)
+		}
+		return ""
+	})
+	return err
+}
+
+// expandHostHeader returns the Host header to send to the origin for a request to reqHost that matched a rule with
+// ruleHostname.
+func expandHostHeader(hostHeader, ruleHostname, reqHost string) string {
+	if !isHostHeaderTemplate(hostHeader) {
+		return hostHeader
+	}
+	return os.Expand(hostHeader, func(name string) string {
+		switch name {
+		case hostHeaderHostVar:
+			return reqHost
+		case hostHeaderWildcardVar:
+			return wildcardLabels(ruleHostname, reqHost)
+		default:
+			return ""
+		}
+	})
+}
+
+// wildcardLabels returns the part of reqHost matched by the * of ruleHostname. Hostnames are compared
+// case-insensitively, and the port of reqHost is left out.
+func wildcardLabels(ruleHostname, reqHost string) string {
+	reqHost = stripPort(reqHost)
+	suffix := strings.TrimPrefix(ruleHostname, "*")
+	if labels, ok := trimSuffixFold(reqHost, suffix); ok {
+		return labels
+	}
+	// The rule may have matched the punycode form of its hostname
+	if punycode, err := idna.Lookup.ToASCII(strings.TrimPrefix(suffix, ".")); err == nil {
+		if labels, ok := trimSuffixFold(reqHost, "."+punycode); ok {
+			return labels
+		}
+	}
+	return ""
+}
+
+// trimSuffixFold is strings.TrimSuffix, ignoring the case of ASCII letters. It tells whether s had the suffix.
+func trimSuffixFold(s, suffix string) (string, bool) {
+	if len(s) < len(suffix) || !strings.EqualFold(s[len(s)-len(suffix):], suffix) {
+		return s, false
+	}
+	return s[:len(s)-len(suffix)], true
+}
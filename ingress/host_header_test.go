@@ -0,0 +1,81 @@
+package ingress
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandHostHeader(t *testing.T) {
+	tests := []struct {
+		hostHeader   string
+		ruleHostname string
+		reqHost      string
+		expected     string
+	}{
+		{"internal.example.com", "*.apps.example.com", "blog.apps.example.com", "internal.example.com"},
+		{"$1.internal:8080", "*.apps.example.com", "blog.apps.example.com", "blog.internal:8080"},
+		{"${1}-app.internal", "*.apps.example.com", "v2.blog.apps.example.com", "v2.blog-app.internal"},
+		{"${host}.internal", "", "blog.example.com", "blog.example.com.internal"},
+		{"$1.internal", "*.bücher.example", "shop.xn--bcher-kva.example", "shop.internal"},
+		{"$1.internal", "*.apps.example.com", "Blog.APPS.Example.com", "Blog.internal"},
+		{"$1.internal", "*.apps.example.com", "blog.apps.example.com:8443", "blog.internal"},
+		{"$1.internal", "*.bücher.example", "shop.XN--BCHER-KVA.example:443", "shop.internal"},
+		{"$1.internal", "*.apps.example.com", "other.example.com", ".internal"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, expandHostHeader(test.hostHeader, test.ruleHostname, test.reqHost), test.hostHeader)
+	}
+}
+
+func TestValidateHostHeaderTemplate(t *testing.T) {
+	assert.NoError(t, validateHostHeaderTemplate("internal.example.com", ""))
+	assert.NoError(t, validateHostHeaderTemplate("$1.internal:8080", "*.apps.example.com"))
+	assert.NoError(t, validateHostHeaderTemplate("${host}", "app.example.com"))
+	assert.EqualError(t, validateHostHeaderTemplate("$1.internal", "app.example.com"), "httpHostHeader $1.internal uses $1, which needs a hostname starting with *.")
+	assert.EqualError(t, validateHostHeaderTemplate("${path}.internal", "*.example.com"), "httpHostHeader ${path}.internal uses the unknown variable path, only $1 and ${host} are supported")
+}
+
+func TestParseTemplatedHostHeader(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host + " " + r.Header.Get("X-Forwarded-Host")))
+	}))
+	defer origin.Close()
+
+	rawYAML := `
+ingress:
+- hostname: "*.apps.example.com"
+  service: ` + origin.URL + `
+  originRequest:
+    httpHostHeader: $1.internal:8080
+- service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.NoError(t, ing.StartOrigins(testLogger, make(chan struct{})))
+
+	req, err := http.NewRequest(http.MethodGet, origin.URL, nil)
+	require.NoError(t, err)
+	req.Host = "blog.apps.example.com"
+	resp, err := ing.Rules[0].Service.(*httpService).RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "blog.internal:8080 blog.apps.example.com", string(body))
+
+	rawYAML = `
+ingress:
+- hostname: app.example.com
+  service: http://localhost:8000
+  originRequest:
+    httpHostHeader: $1.internal
+- service: http_status:404
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
@@ -3,14 +3,19 @@ package ingress
 import (
 	"fmt"
 	"net"
+	"net/http"
+	"net/textproto"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/net/http/httpguts"
 	"golang.org/x/net/idna"
 
 	"github.com/cloudflare/cloudflared/config"
@@ -21,7 +26,7 @@ import (
 var (
 	ErrNoIngressRules             = errors.New("The config file doesn't contain any ingress rules")
 	ErrNoIngressRulesCLI          = errors.New("No ingress rules were defined in provided config (if any) nor from the cli, cloudflared will return 503 for all incoming HTTP requests")
-	errLastRuleNotCatchAll        = errors.New("The last ingress rule must match all URLs (i.e. it should not have a hostname or path filter)")
+	errLastRuleNotCatchAll        = errors.New("The last ingress rule must match all URLs (i.e. it should not have a hostname, path, methods or headers filter)")
 	errBadWildcard                = errors.New("Hostname patterns can have at most one wildcard character (\"*\") and it can only be used for subdomains, e.g. \"*.example.com\"")
 	errHostnameContainsPort       = errors.New("Hostname cannot contain a port")
 	ErrURLIncompatibleWithIngress = errors.New("You can't set the --url flag (or $TUNNEL_URL) when using multiple-origin ingress rules")
@@ -36,24 +41,36 @@ const (
 // FindMatchingRule returns the index of the Ingress Rule which matches the given
 // hostname and path. This function assumes the last rule matches everything,
 // which is the case if the rules were instantiated via the ingress#Validate method.
+// Rules that match on the method or headers of requests are skipped, see FindMatchingRequestRule.
 //
 // Negative index rule signifies local cloudflared rules (not-user defined).
 func (ing Ingress) FindMatchingRule(hostname, path string) (*Rule, int) {
-	// The hostname might contain port. We only want to compare the host part with the rule
-	host, _, err := net.SplitHostPort(hostname)
-	if err == nil {
-		hostname = host
-	}
-	for i, rule := range ing.InternalRules {
-		if rule.Matches(hostname, path) {
+	return ing.findMatchingRule(hostname, path, "", nil)
+}
+
+// FindMatchingRequestRule is like FindMatchingRule, but also matches the method and headers of the request.
+func (ing Ingress) FindMatchingRequestRule(req *http.Request) (*Rule, int) {
+	return ing.findMatchingRule(req.Host, req.URL.Path, req.Method, req.Header)
+}
+
+func (ing Ingress) findMatchingRule(hostname, path, method string, header http.Header) (*Rule, int) {
+	hostname = stripPort(hostname)
+	for i := range ing.InternalRules {
+		if ing.InternalRules[i].Matches(hostname, path) {
 			// Local rule matches return a negative rule index to distiguish local rules from user-defined rules in logs
 			// Full range would be [-1 .. )
-			return &rule, -1 - i
+			return &ing.InternalRules[i], -1 - i
 		}
 	}
-	for i, rule := range ing.Rules {
-		if rule.Matches(hostname, path) {
-			return &rule, i
+	if ing.matcher != nil && ing.matcher.numRules == len(ing.Rules) {
+		if i := ing.matcher.match(ing.Rules, hostname, path, method, header); i >= 0 {
+			return &ing.Rules[i], i
+		}
+	} else {
+		for i := range ing.Rules {
+			if ing.Rules[i].Matches(hostname, path) && ing.Rules[i].matchesMethodAndHeaders(method, header) {
+				return &ing.Rules[i], i
+			}
 		}
 	}
 
@@ -61,6 +78,17 @@ func (ing Ingress) FindMatchingRule(hostname, path string) (*Rule, int) {
 	return &ing.Rules[i], i
 }
 
+// stripPort removes the port the hostname might contain, since rules only match the host part.
+func stripPort(hostname string) string {
+	// Hostnames without a colon are skipped because the error of SplitHostPort allocates.
+	if strings.IndexByte(hostname, ':') >= 0 {
+		if host, _, err := net.SplitHostPort(hostname); err == nil {
+			return host
+		}
+	}
+	return hostname
+}
+
 func matchHost(ruleHost, reqHost string) bool {
 	if ruleHost == reqHost {
 		return true
@@ -81,6 +109,9 @@ type Ingress struct {
 	// Rules that are provided by the user from remote or local configuration
 	Rules    []Rule              `json:"ingress"`
 	Defaults OriginRequestConfig `json:"originRequest"`
+
+	// matcher finds the rule for a request without going through all of them. It's built when rules are parsed.
+	matcher *ruleMatcher
 }
 
 // ParseIngress parses ingress rules, but does not send HTTP requests to the origins.
@@ -91,6 +122,35 @@ func ParseIngress(conf *config.Configuration) (Ingress, error) {
 	return validateIngress(conf.Ingress, originRequestFromConfig(conf.OriginRequest))
 }
 
+// ParseGeneratedRules parses rules generated at runtime, e.g. from container labels, which are served before the
+// catch-all rule of the user's ingress rules and so don't need one.
+func ParseGeneratedRules(rules []config.UnvalidatedIngressRule, defaults OriginRequestConfig) ([]Rule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	catchAll := config.UnvalidatedIngressRule{Service: "http_status:404"}
+	ing, err := validateIngress(append(append([]config.UnvalidatedIngressRule{}, rules...), catchAll), defaults)
+	if err != nil {
+		return nil, err
+	}
+	return ing.Rules[:len(rules)], nil
+}
+
+// WithGeneratedRules returns ing with the rules parsed by ParseGeneratedRules matched after its own rules, before their
+// catch-all rule, so they can't override them. The matcher is rebuilt over all the rules.
+func (ing Ingress) WithGeneratedRules(generated []Rule) Ingress {
+	if len(generated) == 0 || len(ing.Rules) == 0 {
+		return ing
+	}
+	catchAll := len(ing.Rules) - 1
+	rules := make([]Rule, 0, len(ing.Rules)+len(generated))
+	rules = append(rules, ing.Rules[:catchAll]...)
+	rules = append(rules, generated...)
+	ing.Rules = append(rules, ing.Rules[catchAll])
+	ing.matcher = newRuleMatcher(ing.Rules)
+	return ing
+}
+
 // ParseIngressFromConfigAndCLI will parse the configuration rules from config files for ingress
 // rules and then attempt to parse CLI for ingress rules.
 // Will always return at least one valid ingress rule. If none are provided by the user, the default
@@ -208,6 +268,67 @@ func (ing Ingress) StartOrigins(
 		if err := rule.Service.start(log, shutdownC, rule.Config); err != nil {
 			return errors.Wrapf(err, "Error starting local service %s", rule.Service)
 		}
+		if rule.Mirror != nil {
+			if err := rule.Mirror.Service.start(log, shutdownC, rule.Config); err != nil {
+				return errors.Wrapf(err, "Error starting mirror service %s", rule.Mirror.Service)
+			}
+		}
+		if rule.HealthCheck != nil {
+			rule.HealthCheck.start(log, shutdownC)
+		}
+		if rule.Cache != nil {
+			if err := rule.Cache.start(log, shutdownC); err != nil {
+				return errors.Wrapf(err, "Error starting the cache of local service %s", rule.Service)
+			}
+		}
+		if rule.RateLimit != nil {
+			rule.RateLimit.start(shutdownC)
+		}
+		for _, f := range rule.Filters {
+			if f.Service == nil {
+				continue
+			}
+			if err := f.Service.start(log, shutdownC, rule.Config); err != nil {
+				return errors.Wrapf(err, "Error starting local service %s", f.Service)
+			}
+		}
+	}
+	return nil
+}
+
+// Plugins returns the middleware plugins of the rules, which have to be closed once the rules aren't served anymore.
+func (ing Ingress) Plugins() []middleware.Plugin {
+	var plugins []middleware.Plugin
+	for _, rule := range ing.Rules {
+		for _, handler := range rule.Handlers {
+			if plugin, ok := handler.(middleware.Plugin); ok {
+				plugins = append(plugins, plugin)
+			}
+		}
+	}
+	return plugins
+}
+
+// WarmupOrigins prepares the connections to every origin service that defers it until its first request, so that
+// misconfigured origins are reported now rather than when they're first used.
+func (ing Ingress) WarmupOrigins() error {
+	for _, rule := range ing.Rules {
+		services := []OriginService{rule.Service}
+		if rule.Mirror != nil {
+			services = append(services, rule.Mirror.Service)
+		}
+		for _, f := range rule.Filters {
+			if f.Service != nil {
+				services = append(services, f.Service)
+			}
+		}
+		for _, service := range services {
+			if w, ok := service.(warmer); ok {
+				if err := w.warmup(); err != nil {
+					return errors.Wrapf(err, "Error preparing connections to origin service %s", service)
+				}
+			}
+		}
 	}
 	return nil
 }
@@ -242,13 +363,132 @@ func validateAccessConfiguration(cfg *config.AccessConfig) error {
 	return nil
 }
 
-func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginRequestConfig) (Ingress, error) {
+func newAuthService(cfg *config.AuthServiceConfig) (*middleware.AuthService, error) {
+	var timeout time.Duration
+	if cfg.Timeout != nil {
+		timeout = cfg.Timeout.Duration
+	}
+	switch {
+	case cfg.URL != "" && len(cfg.Command) > 0:
+		return nil, errors.New("only one of url and command can be set")
+	case cfg.URL != "":
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%s is not an http or https URL", cfg.URL)
+		}
+		return middleware.NewHTTPAuthService(cfg.URL, timeout, cfg.InjectHeaders), nil
+	case len(cfg.Command) > 0:
+		return middleware.NewCommandAuthService(cfg.Command, timeout, cfg.InjectHeaders), nil
+	default:
+		return nil, errors.New("either url or command must be set")
+	}
+}
+
+func newPlugin(cfg config.PluginConfig) (middleware.Plugin, error) {
+	var limits middleware.PluginLimits
+	if cfg.Timeout != nil {
+		limits.Timeout = cfg.Timeout.Duration
+	}
+	if cfg.MaxBodyBytes != nil {
+		limits.MaxBodyBytes = *cfg.MaxBodyBytes
+	}
+	if cfg.MaxMemoryBytes != nil {
+		limits.MaxMemoryBytes = *cfg.MaxMemoryBytes
+	}
+	if cfg.Path != "" {
+		return middleware.NewWASMPlugin(cfg.Name, cfg.Path, cfg.Config, limits)
+	}
+	return middleware.NewPlugin(cfg.Name, cfg.Config, limits)
+}
+
+// RetryOnConnectFailure is the retryOn condition of the requests to an origin that can't be connected to.
+const RetryOnConnectFailure = "connect-failure"
+
+// validateRetryOn checks that the retryOn conditions are connect-failure or 5xx status codes.
+func validateRetryOn(conditions []string) error {
+	for _, condition := range conditions {
+		if condition == RetryOnConnectFailure {
+			continue
+		}
+		if status, err := strconv.Atoi(condition); err != nil || status < 500 || status > 599 {
+			return fmt.Errorf("retryOn condition %q should be %s or a 5xx status code", condition, RetryOnConnectFailure)
+		}
+	}
+	return nil
+}
+
+// validateHTTPHeaders checks the headers that are added to and removed from the requests to the origin. The Host header
+// is set with httpHostHeader instead.
+func validateHTTPHeaders(headers *config.HTTPHeadersConfig) error {
+	if headers == nil {
+		return nil
+	}
+	for name, value := range headers.Add {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("httpHeaders can't add %q, it isn't a valid header name", name)
+		}
+		if strings.EqualFold(name, "Host") {
+			return fmt.Errorf("httpHeaders can't add the Host header, set httpHostHeader instead")
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("httpHeaders can't add header %s, its value isn't valid", name)
+		}
+	}
+	for _, name := range headers.Remove {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("httpHeaders can't remove %q, it isn't a valid header name", name)
+		}
+	}
+	return nil
+}
+
+// newResponseRewriter rewrites the addresses the origin is reached at, along with the configured ones.
+func newResponseRewriter(cfg *config.ResponseRewriteConfig, service OriginService, originRequest OriginRequestConfig) *middleware.ResponseRewriter {
+	internalHosts := append([]string{}, cfg.InternalHosts...)
+	if s, ok := service.(*httpService); ok {
+		internalHosts = append(internalHosts, s.url.Host)
+	}
+	if group, ok := service.(*originGroup); ok {
+		for _, s := range group.services {
+			internalHosts = append(internalHosts, s.url.Host)
+		}
+	}
+	if originRequest.HTTPHostHeader != "" && !isHostHeaderTemplate(originRequest.HTTPHostHeader) {
+		internalHosts = append(internalHosts, originRequest.HTTPHostHeader)
+	}
+	return middleware.NewResponseRewriter(middleware.ResponseRewriteOptions{
+		InternalHosts: internalHosts,
+		Location:      cfg.Location,
+		CookieDomain:  cfg.CookieDomain,
+		CookiePaths:   cfg.CookiePath,
+	})
+}
+
+func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginRequestConfig) (_ Ingress, err error) {
 	rules := make([]Rule, len(ingress))
+	// The plugins that were created aren't used if a rule is invalid
+	var plugins []middleware.Plugin
+	defer func() {
+		if err != nil {
+			for _, plugin := range plugins {
+				_ = plugin.Close()
+			}
+		}
+	}()
 	for i, r := range ingress {
 		cfg := setConfig(defaults, r.OriginRequest)
 		var service OriginService
 
-		if prefix := "unix:"; strings.HasPrefix(r.Service, prefix) {
+		if len(r.Origins) > 0 {
+			group, err := newOriginGroup(r.Origins)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid service", i+1)
+			}
+			service = group
+		} else if prefix := "unix:"; strings.HasPrefix(r.Service, prefix) {
 			// No validation necessary for unix socket filepath services
 			path := strings.TrimPrefix(r.Service, prefix)
 			service = &unixSocketPath{path: path, scheme: "http"}
@@ -290,6 +530,18 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			// leave the URL field empty for now.
 			cfg.BastionMode = true
 			service = newBastionService()
+		} else if isDiscoveryService(r.Service) {
+			discovered, err := newDiscoveredService(r.Service, cfg.Discovery)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid service", i+1)
+			}
+			service = discovered
+		} else if isFileService(r.Service) {
+			fileService, err := newFileService(r.Service)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid service", i+1)
+			}
+			service = fileService
 		} else {
 			// Validate URL services
 			u, err := url.Parse(r.Service)
@@ -306,11 +558,59 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			}
 			if isHTTPService(u) {
 				service = &httpService{url: u}
+			} else if isUDPService(u) {
+				udp, err := newUDPService(u)
+				if err != nil {
+					return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid service", i+1)
+				}
+				service = udp
 			} else {
 				service = newTCPOverWSService(u)
 			}
 		}
 
+		if err := cfg.StreamingMode.validate(); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid originRequest", i+1)
+		}
+		if cfg.SSEHeartbeatInterval.Duration < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: sseHeartbeatInterval can't be negative", i+1)
+		}
+		if cfg.WebsocketPingInterval.Duration < 0 || cfg.WebsocketIdleTimeout.Duration < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: websocket durations can't be negative", i+1)
+		}
+		if cfg.UDPIdleTimeout.Duration < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: udpIdleTimeout can't be negative", i+1)
+		}
+		if err := validateHostHeaderTemplate(cfg.HTTPHostHeader, r.Hostname); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid originRequest", i+1)
+		}
+		if s, ok := service.(*httpService); ok && isHostHeaderTemplate(cfg.HTTPHostHeader) {
+			s.ruleHostname = r.Hostname
+		}
+		if group, ok := service.(*originGroup); ok && isHostHeaderTemplate(cfg.HTTPHostHeader) {
+			for _, s := range group.services {
+				s.ruleHostname = r.Hostname
+			}
+		}
+		if cfg.RequestTimeout.Duration < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: requestTimeout can't be negative", i+1)
+		}
+		if cfg.MaxRequestBodySize < 0 || cfg.MaxResponseBodySize < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: body sizes can't be negative", i+1)
+		}
+		if cfg.RetryTimeout.Duration < 0 {
+			return Ingress{}, fmt.Errorf("Rule #%d has an invalid originRequest: retryTimeout can't be negative", i+1)
+		}
+		if err := validateRetryOn(cfg.RetryOn); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid originRequest", i+1)
+		}
+		if err := validateHTTPHeaders(cfg.HTTPHeaders); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid originRequest", i+1)
+		}
+		if _, err := cfg.tlsPolicy(); err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid originRequest", i+1)
+		}
+
 		var handlers []middleware.Handler
 		if access := r.OriginRequest.Access; access != nil {
 			if err := validateAccessConfiguration(access); err != nil {
@@ -321,6 +621,78 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 				handlers = append(handlers, verifier)
 			}
 		}
+		if authService := cfg.AuthService; authService != nil {
+			handler, err := newAuthService(authService)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid authService", i+1)
+			}
+			handlers = append(handlers, handler)
+		}
+		if responseRewrite := cfg.ResponseRewrite; responseRewrite != nil {
+			handlers = append(handlers, newResponseRewriter(responseRewrite, service, cfg))
+		}
+		for _, pluginConfig := range cfg.Plugins {
+			plugin, err := newPlugin(pluginConfig)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid plugin", i+1)
+			}
+			plugins = append(plugins, plugin)
+			handlers = append(handlers, plugin)
+		}
+		// Headers are rewritten last, so the configured ones are the ones the eyeball gets
+		if len(cfg.SetResponseHeaders) > 0 || len(cfg.RemoveResponseHeaders) > 0 {
+			headerRewriter, err := middleware.NewHeaderRewriter(cfg.SetResponseHeaders, cfg.RemoveResponseHeaders)
+			if err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid response headers", i+1)
+			}
+			handlers = append(handlers, headerRewriter)
+		}
+
+		var mirror *Mirror
+		if cfg.Mirror != nil {
+			var err error
+			if mirror, err = newMirror(cfg.Mirror); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid mirror", i+1)
+			}
+		}
+
+		var healthCheck *HealthCheck
+		if cfg.HealthCheck != nil {
+			var err error
+			if healthCheck, err = newHealthCheck(cfg.HealthCheck, service, r.Hostname); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid healthCheck", i+1)
+			}
+		}
+
+		var cache *ResponseCache
+		if cfg.Cache != nil {
+			var err error
+			if cache, err = newResponseCache(cfg.Cache); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid cache", i+1)
+			}
+		}
+
+		var rateLimit *RateLimit
+		if cfg.RateLimit != nil {
+			var err error
+			if rateLimit, err = newRateLimit(cfg.RateLimit); err != nil {
+				return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid rateLimit", i+1)
+			}
+		}
+
+		filters, err := parseFilters(r.Filters)
+		if err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has an invalid filter", i+1)
+		}
+
+		methods, err := parseMethods(r.Methods)
+		if err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid methods", i+1)
+		}
+		headers, err := parseHeaderMatchers(r.Headers)
+		if err != nil {
+			return Ingress{}, errors.Wrapf(err, "Rule #%d has invalid headers", i+1)
+		}
 
 		if err := validateHostname(r, i, len(ingress)); err != nil {
 			return Ingress{}, err
@@ -351,11 +723,53 @@ func validateIngress(ingress []config.UnvalidatedIngressRule, defaults OriginReq
 			punycodeHostname: punycodeHostname,
 			Service:          service,
 			Path:             pathRegexp,
+			Methods:          methods,
+			Headers:          headers,
 			Handlers:         handlers,
+			Filters:          filters,
+			Mirror:           mirror,
+			HealthCheck:      healthCheck,
+			Cache:            cache,
+			RateLimit:        rateLimit,
 			Config:           cfg,
 		}
 	}
-	return Ingress{Rules: rules, Defaults: defaults}, nil
+	return Ingress{Rules: rules, Defaults: defaults, matcher: newRuleMatcher(rules)}, nil
+}
+
+// parseMethods validates the methods an ingress rule matches, which are case-insensitive in the configuration.
+func parseMethods(methods []string) ([]string, error) {
+	var parsed []string
+	for _, method := range methods {
+		if !httpguts.ValidHeaderFieldName(method) {
+			return nil, fmt.Errorf("%q isn't a valid HTTP method", method)
+		}
+		parsed = append(parsed, strings.ToUpper(method))
+	}
+	return parsed, nil
+}
+
+// parseHeaderMatchers compiles the regexes the headers of the requests matching an ingress rule must match, sorted by
+// header name. The Host header is matched with the hostname of the rule instead.
+func parseHeaderMatchers(headers map[string]string) ([]HeaderMatcher, error) {
+	var parsed []HeaderMatcher
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("%q isn't a valid header name", name)
+		}
+		if strings.EqualFold(name, "Host") {
+			return nil, fmt.Errorf("the Host header can't be matched, set hostname instead")
+		}
+		regex, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "header %s has an invalid regex", name)
+		}
+		parsed = append(parsed, HeaderMatcher{Name: textproto.CanonicalMIMEHeaderKey(name), Value: &Regexp{Regexp: regex}})
+	}
+	sort.Slice(parsed, func(i, j int) bool {
+		return parsed[i].Name < parsed[j].Name
+	})
+	return parsed, nil
 }
 
 func validateHostname(r config.UnvalidatedIngressRule, ruleIndex, totalRules int) error {
@@ -371,7 +785,7 @@ func validateHostname(r config.UnvalidatedIngressRule, ruleIndex, totalRules int
 	}
 
 	// The last rule should catch all hostnames.
-	isCatchAllRule := (r.Hostname == "" || r.Hostname == "*") && r.Path == ""
+	isCatchAllRule := (r.Hostname == "" || r.Hostname == "*") && r.Path == "" && len(r.Methods) == 0 && len(r.Headers) == 0
 	isLastRule := ruleIndex == totalRules-1
 	if isLastRule && !isCatchAllRule {
 		return errLastRuleNotCatchAll
@@ -395,5 +809,36 @@ func (e errRuleShouldNotBeCatchAll) Error() string {
 }
 
 func isHTTPService(url *url.URL) bool {
-	return url.Scheme == "http" || url.Scheme == "https" || url.Scheme == "ws" || url.Scheme == "wss"
+	switch url.Scheme {
+	case "http", "https", "ws", "wss", "grpc", "grpcs":
+		return true
+	default:
+		return false
+	}
+}
+
+// isGRPCService tells whether the origin is a gRPC server, which is always spoken to over HTTP/2: with prior
+// knowledge for grpc://, and negotiated with ALPN for grpcs://.
+func isGRPCService(url *url.URL) bool {
+	return url.Scheme == "grpc" || url.Scheme == "grpcs"
+}
+
+// GRPCRule returns the index of the first rule whose origin, or one of whose origins, is a gRPC server, or -1 if there
+// is none. gRPC responses end with trailers, which only http2 connections to the edge can carry.
+func (ing Ingress) GRPCRule() int {
+	for i, rule := range ing.Rules {
+		switch service := rule.Service.(type) {
+		case *httpService:
+			if isGRPCService(service.url) {
+				return i
+			}
+		case *originGroup:
+			for _, member := range service.members {
+				if isGRPCService(member.url) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
 }
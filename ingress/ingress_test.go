@@ -1,21 +1,28 @@
 package ingress
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
 	"github.com/cloudflare/cloudflared/ipaccess"
+	"github.com/cloudflare/cloudflared/sockopt"
 	"github.com/cloudflare/cloudflared/tlsconfig"
 )
 
@@ -711,6 +718,79 @@ func TestFindMatchingRule(t *testing.T) {
 	}
 }
 
+func TestFindMatchingRequestRule(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+  - hostname: api.example.com
+    path: ^/api/
+    methods: [post, PUT]
+    headers:
+      X-Env: ^staging$
+    service: http://localhost:8001
+  - hostname: api.example.com
+    path: ^/api/
+    methods: [POST]
+    service: http://localhost:8002
+  - hostname: api.example.com
+    service: http://localhost:8003
+  - service: http_status:404
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"POST", "PUT"}, ing.Rules[0].Methods)
+
+	tests := []struct {
+		method        string
+		path          string
+		header        http.Header
+		wantRuleIndex int
+	}{
+		{method: http.MethodPost, path: "/api/users", header: http.Header{"X-Env": []string{"staging"}}, wantRuleIndex: 0},
+		{method: http.MethodPut, path: "/api/users", header: http.Header{"X-Env": []string{"production", "staging"}}, wantRuleIndex: 0},
+		{method: http.MethodPost, path: "/api/users", header: http.Header{"X-Env": []string{"staging-2"}}, wantRuleIndex: 1},
+		{method: http.MethodPost, path: "/api/users", header: http.Header{}, wantRuleIndex: 1},
+		{method: http.MethodPut, path: "/api/users", header: http.Header{}, wantRuleIndex: 2},
+		{method: http.MethodGet, path: "/api/users", header: http.Header{"X-Env": []string{"staging"}}, wantRuleIndex: 2},
+		{method: http.MethodPost, path: "/", header: http.Header{"X-Env": []string{"staging"}}, wantRuleIndex: 2},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "http://api.example.com:8443"+test.path, nil)
+		req.Header = test.header
+		_, ruleIndex := ing.FindMatchingRequestRule(req)
+		assert.Equal(t, test.wantRuleIndex, ruleIndex, "%s %s %v", test.method, test.path, test.header)
+		assert.True(t, ing.Rules[ruleIndex].MatchesRequest(req))
+	}
+
+	// Without the method and headers, only the rules that don't match on them apply
+	_, ruleIndex := ing.FindMatchingRule("api.example.com", "/api/users")
+	assert.Equal(t, 2, ruleIndex)
+
+	for _, invalid := range []string{
+		`methods: ["BAD METHOD"]`,
+		`headers: {"Bad Header": value}`,
+		`headers: {Host: api.example.com}`,
+		`headers: {X-Env: "["}`,
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - hostname: api.example.com
+    ` + invalid + `
+    service: http://localhost:8001
+  - service: http_status:404
+`))
+		assert.Error(t, err, invalid)
+	}
+
+	// The last rule must match every request
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+  - hostname: api.example.com
+    service: http://localhost:8001
+  - methods: [GET]
+    service: http_status:404
+`))
+	assert.Equal(t, errLastRuleNotCatchAll, err)
+}
+
 func TestIsHTTPService(t *testing.T) {
 	tests := []struct {
 		url    *url.URL
@@ -827,6 +907,312 @@ func TestParseAccessConfig(t *testing.T) {
 	}
 }
 
+type headerPlugin struct {
+	value string
+}
+
+func (p headerPlugin) Name() string { return "header" }
+
+func (p headerPlugin) Handle(context.Context, *http.Request) (*middleware.HandleResult, error) {
+	return &middleware.HandleResult{}, nil
+}
+
+func (p headerPlugin) HandleResponseHeaders(_ context.Context, _ *http.Request, _ int, header http.Header) error {
+	header.Set("X-Header-Plugin", p.value)
+	return nil
+}
+
+func (p headerPlugin) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+func (p headerPlugin) Close() error { return nil }
+
+func TestParsePlugins(t *testing.T) {
+	middleware.RegisterPlugin("ingress-test-header", func(cfg map[string]interface{}) (middleware.Plugin, error) {
+		value, _ := cfg["value"].(string)
+		return headerPlugin{value: value}, nil
+	})
+
+	rawYAML := `
+ingress:
+  - hostname: plugin.example.com
+    service: https://localhost:8000
+    originRequest:
+      plugins:
+        - name: ingress-test-header
+          timeout: 2s
+          config:
+            value: rule
+  - service: https://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Len(t, ing.Rules[0].Handlers, 1)
+	assert.Empty(t, ing.Rules[1].Handlers)
+	require.Len(t, ing.Plugins(), 1)
+
+	header := http.Header{}
+	plugin := ing.Rules[0].Handlers[0].(middleware.ResponseHandler)
+	require.NoError(t, plugin.HandleResponseHeaders(context.Background(), nil, http.StatusOK, header))
+	assert.Equal(t, "rule", header.Get("X-Header-Plugin"))
+
+	rawYAML = `
+ingress:
+  - service: https://localhost:8000
+    originRequest:
+      plugins:
+        - name: not-registered
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	assert.Error(t, err)
+
+	rawYAML = `
+ingress:
+  - service: https://localhost:8000
+    originRequest:
+      plugins:
+        - path: middleware/testdata/deny.wasm
+          maxMemoryBytes: 1048576
+`
+	ing, err = ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Len(t, ing.Rules[0].Handlers, 1)
+	assert.Equal(t, "deny.wasm", ing.Rules[0].Handlers[0].Name())
+}
+
+func TestParseAuthService(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         string
+		expectError bool
+	}{
+		{
+			name: "url",
+			cfg:  "{url: http://localhost:8181/authz}",
+		},
+		{
+			name: "command",
+			cfg:  "{command: [opa-check, --policy, ingress]}",
+		},
+		{
+			name:        "url and command",
+			cfg:         "{url: http://localhost:8181/authz, command: [opa-check]}",
+			expectError: true,
+		},
+		{
+			name:        "neither url nor command",
+			cfg:         "{timeout: 1s}",
+			expectError: true,
+		},
+		{
+			name:        "url is not http",
+			cfg:         "{url: tcp://localhost:8181}",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawYAML := fmt.Sprintf(`
+ingress:
+  - service: https://localhost:8000
+    originRequest:
+      authService: %s
+`, test.cfg)
+			ing, err := ParseIngress(MustReadIngress(rawYAML))
+			if test.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, ing.Rules[0].Handlers, 1)
+			assert.IsType(t, &middleware.AuthService{}, ing.Rules[0].Handlers[0])
+		})
+	}
+}
+
+func TestParseResponseRewrite(t *testing.T) {
+	rawYAML := `
+ingress:
+  - hostname: app.example.com
+    service: http://localhost:8080
+    originRequest:
+      httpHostHeader: app.internal
+      responseRewrite:
+        location: true
+        cookieDomain: true
+  - service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Len(t, ing.Rules[0].Handlers, 1)
+	rewriter, ok := ing.Rules[0].Handlers[0].(*middleware.ResponseRewriter)
+	require.True(t, ok)
+
+	req := httptest.NewRequest(http.MethodGet, "http://app.internal/", nil)
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	for _, location := range []string{"http://localhost:8080/login", "http://app.internal/login"} {
+		header := http.Header{"Location": {location}}
+		require.NoError(t, rewriter.HandleResponseHeaders(context.Background(), req, http.StatusFound, header))
+		assert.Equal(t, "https://app.example.com/login", header.Get("Location"))
+	}
+	assert.Empty(t, ing.Rules[1].Handlers)
+}
+
+func TestParseResponseHeaders(t *testing.T) {
+	rawYAML := `
+ingress:
+  - hostname: app.example.com
+    service: http://localhost:8080
+  - service: http://localhost:8081
+    originRequest:
+      setResponseHeaders:
+        X-Frame-Options: SAMEORIGIN
+originRequest:
+  setResponseHeaders:
+    Strict-Transport-Security: max-age=31536000
+  removeResponseHeaders:
+    - Server
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "https://app.example.com/", nil)
+	for i, expected := range []http.Header{
+		{"Strict-Transport-Security": {"max-age=31536000"}},
+		// Rules replace the headers set by default
+		{"X-Frame-Options": {"SAMEORIGIN"}},
+	} {
+		require.Len(t, ing.Rules[i].Handlers, 1)
+		rewriter, ok := ing.Rules[i].Handlers[0].(*middleware.HeaderRewriter)
+		require.True(t, ok)
+		header := http.Header{"Server": {"nginx"}}
+		require.NoError(t, rewriter.HandleResponseHeaders(context.Background(), req, http.StatusOK, header))
+		assert.Equal(t, expected, header)
+	}
+
+	_, err = ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      setResponseHeaders:
+        "Bad Header": value
+`))
+	assert.Error(t, err)
+}
+
+func TestParseHTTPHeaders(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      httpHeaders:
+        add:
+          X-Internal-Token: secret
+        remove:
+          - Cf-Connecting-Ip
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &config.HTTPHeadersConfig{
+		Add:    map[string]string{"X-Internal-Token": "secret"},
+		Remove: []string{"Cf-Connecting-Ip"},
+	}, ing.Rules[0].Config.HTTPHeaders)
+
+	for _, invalid := range []string{
+		"{add: {Host: internal.example.com}}",
+		`{add: {"Bad Header": value}}`,
+		`{remove: ["bad:header"]}`,
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      httpHeaders: ` + invalid + `
+`))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	rawYAML := `
+ingress:
+  - hostname: api.example.com
+    service: https://localhost:8000
+    filters:
+      - expression: request.ip.inCIDR("10.0.0.0/8")
+        action: allow
+      - expression: request.country in ["KP", "IR"]
+        action: deny
+        status: 451
+      - expression: request.path.startsWith("/v2/")
+        action: route
+        service: http://localhost:8001
+  - service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	filters := ing.Rules[0].Filters
+	require.Len(t, filters, 3)
+	assert.Equal(t, FilterAllow, filters[0].Action)
+	assert.Equal(t, FilterDeny, filters[1].Action)
+	assert.Equal(t, 451, filters[1].StatusCode)
+	assert.Equal(t, FilterRoute, filters[2].Action)
+	assert.Equal(t, "http://localhost:8001", filters[2].Service.String())
+	assert.Equal(t, config.FilterConfig{
+		Expression: `request.path.startsWith("/v2/")`,
+		Action:     "route",
+		Service:    "http://localhost:8001",
+	}, filters[2].RawConfig())
+
+	for _, invalid := range []string{
+		"{expression: 'request.path ==', action: deny}",
+		"{expression: 'true', action: block}",
+		"{expression: 'true', action: deny, status: 1000}",
+		"{expression: 'true', action: route}",
+		"{expression: 'true', action: route, service: 'tcp://localhost:22'}",
+	} {
+		rawYAML := fmt.Sprintf(`
+ingress:
+  - service: https://localhost:8000
+    filters:
+      - %s
+`, invalid)
+		_, err := ParseIngress(MustReadIngress(rawYAML))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	rawYAML := `
+ingress:
+  - service: https://localhost:8000
+    filters:
+      - expression: request.headers["x-role"] == "admin"
+        action: allow
+      - expression: request.path.startsWith("/admin")
+        action: deny
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	rule := &ing.Rules[0]
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/admin", nil)
+	req.Header.Set("X-Role", "admin")
+	f, err := rule.MatchFilter(req)
+	require.NoError(t, err)
+	assert.Equal(t, FilterAllow, f.Action)
+
+	// The allow filter fails to evaluate without the header, so it's skipped
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/admin", nil)
+	f, err = rule.MatchFilter(req)
+	require.NoError(t, err)
+	assert.Equal(t, FilterDeny, f.Action)
+
+	req = httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	f, err = rule.MatchFilter(req)
+	assert.Error(t, err)
+	assert.Nil(t, f)
+}
+
 func MustReadIngress(s string) *config.Configuration {
 	var conf config.Configuration
 	err := yaml.Unmarshal([]byte(s), &conf)
@@ -835,3 +1221,332 @@ func MustReadIngress(s string) *config.Configuration {
 	}
 	return &conf
 }
+
+func TestParseMirror(t *testing.T) {
+	rawYAML := `
+ingress:
+  - hostname: app.example.com
+    service: http://localhost:8080
+    originRequest:
+      mirror:
+        service: http://localhost:9090
+        percentage: 12.5
+        maxBodyBytes: 4096
+  - service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	mirror := ing.Rules[0].Mirror
+	require.NotNil(t, mirror)
+	assert.Equal(t, "http://localhost:9090", mirror.Service.String())
+	assert.Equal(t, 12.5, mirror.Percentage)
+	assert.Equal(t, defaultMirrorTimeout, mirror.Timeout)
+	assert.Equal(t, int64(4096), mirror.MaxBodyBytes)
+	assert.Nil(t, ing.Rules[1].Mirror)
+
+	for _, invalid := range []string{
+		"{service: tcp://localhost:9090}",
+		"{service: http://localhost:9090/shadow}",
+		"{service: http://localhost:9090, percentage: 150}",
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      mirror: ` + invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseSocketOptions(t *testing.T) {
+	rawYAML := `
+originRequest:
+  socket:
+    noDelay: false
+    keepAliveCount: 4
+ingress:
+  - hostname: bulk.example.com
+    service: http://localhost:8080
+    originRequest:
+      socket:
+        sendBuffer: 4194304
+        receiveBuffer: 4194304
+  - service: http://localhost:8081
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+
+	noDelay := false
+	// Socket settings of a rule replace the defaults as a whole
+	assert.Equal(t, sockopt.Options{SendBuffer: 4194304, ReceiveBuffer: 4194304}, ing.Rules[0].Config.socketOptions())
+	assert.Equal(t, sockopt.Options{NoDelay: &noDelay, KeepAliveCount: 4}, ing.Rules[1].Config.socketOptions())
+}
+
+func TestParseStreamingMode(t *testing.T) {
+	rawYAML := `
+ingress:
+- hostname: video.example.com
+  service: http://localhost:8000
+  originRequest:
+    streamingMode: passthrough
+- service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, StreamingModePassthrough, ing.Rules[0].Config.StreamingMode)
+	require.Equal(t, StreamingModeBuffered, ing.Rules[1].Config.StreamingMode)
+
+	rawYAML = `
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    streamingMode: unbuffered
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestParseSSEHeartbeatInterval(t *testing.T) {
+	rawYAML := `
+originRequest:
+  sseHeartbeatInterval: 15s
+ingress:
+- hostname: events.example.com
+  service: http://localhost:8000
+  originRequest:
+    sseHeartbeatInterval: 5s
+- service: http://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, ing.Rules[0].Config.SSEHeartbeatInterval.Duration)
+	require.Equal(t, 15*time.Second, ing.Rules[1].Config.SSEHeartbeatInterval.Duration)
+
+	rawYAML = `
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    sseHeartbeatInterval: -1s
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestParseWebsocketKeepalive(t *testing.T) {
+	rawYAML := `
+originRequest:
+  websocketPingInterval: 30s
+ingress:
+- hostname: terminal.example.com
+  service: http://localhost:8000
+  originRequest:
+    websocketPingInterval: 10s
+    websocketIdleTimeout: 1h
+- service: tcp://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, ing.Rules[0].Config.WebsocketPingInterval.Duration)
+	require.Equal(t, time.Hour, ing.Rules[0].Config.WebsocketIdleTimeout.Duration)
+	require.Equal(t, 30*time.Second, ing.Rules[1].Config.WebsocketPingInterval.Duration)
+	require.Equal(t, time.Duration(0), ing.Rules[1].Config.WebsocketIdleTimeout.Duration)
+
+	rawYAML = `
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    websocketIdleTimeout: -1s
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestParseUDPService(t *testing.T) {
+	rawYAML := `
+ingress:
+- hostname: game.example.com
+  service: udp://localhost:27015
+  originRequest:
+    udpIdleTimeout: 2m
+- service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, "udp://localhost:27015", ing.Rules[0].Service.String())
+	require.Implements(t, (*UDPOriginProxy)(nil), ing.Rules[0].Service)
+	require.Equal(t, 2*time.Minute, ing.Rules[0].Config.UDPIdleTimeout.Duration)
+
+	for _, invalid := range []string{
+		"service: udp://localhost",
+		"service: udp://localhost:27015\n  originRequest:\n    udpIdleTimeout: -1s",
+	} {
+		_, err = ParseIngress(MustReadIngress("ingress:\n- " + invalid + "\n"))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestUDPServiceDialUDP(t *testing.T) {
+	origin, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer origin.Close()
+
+	u, err := url.Parse("udp://" + origin.LocalAddr().String())
+	require.NoError(t, err)
+	service, err := newUDPService(u)
+	require.NoError(t, err)
+	conn, err := service.DialUDP()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, addr, err := origin.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+	require.Equal(t, conn.LocalAddr().String(), addr.String())
+}
+
+func TestParseRequestTimeout(t *testing.T) {
+	rawYAML := `
+ingress:
+- hostname: api.example.com
+  service: http://localhost:8000
+  originRequest:
+    requestTimeout: 30s
+- service: http://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, ing.Rules[0].Config.RequestTimeout.Duration)
+	require.Equal(t, time.Duration(0), ing.Rules[1].Config.RequestTimeout.Duration)
+
+	rawYAML = `
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    requestTimeout: -5s
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestParseBodySizeLimits(t *testing.T) {
+	rawYAML := `
+originRequest:
+  maxRequestBodySize: 1048576
+ingress:
+- hostname: upload.example.com
+  service: http://localhost:8000
+  originRequest:
+    maxRequestBodySize: 104857600
+    maxResponseBodySize: 1024
+- service: http://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, int64(104857600), ing.Rules[0].Config.MaxRequestBodySize)
+	require.Equal(t, int64(1024), ing.Rules[0].Config.MaxResponseBodySize)
+	require.Equal(t, int64(1048576), ing.Rules[1].Config.MaxRequestBodySize)
+	require.Equal(t, int64(0), ing.Rules[1].Config.MaxResponseBodySize)
+
+	rawYAML = `
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    maxResponseBodySize: -1
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestParseTLSPolicy(t *testing.T) {
+	rawYAML := `
+originRequest:
+  tls:
+    minVersion: "1.2"
+ingress:
+- hostname: strict.example.com
+  service: https://localhost:8443
+  originRequest:
+    tls:
+      minVersion: "1.3"
+      curves: [X25519]
+      checkRevocation: true
+- service: https://localhost:8444
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+
+	policy, err := ing.Rules[0].Config.tlsPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, tlsconfig.Policy{MinVersion: tls.VersionTLS13, CurvePreferences: []tls.CurveID{tls.X25519}}, policy)
+	assert.True(t, ing.Rules[0].Config.TLS.CheckRevocation)
+	policy, err = ing.Rules[1].Config.tlsPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, tlsconfig.Policy{MinVersion: tls.VersionTLS12}, policy)
+
+	rawYAML = `
+ingress:
+- service: https://localhost:8443
+  originRequest:
+    tls:
+      cipherSuites: [TLS_RSA_WITH_NULL]
+`
+	_, err = ParseIngress(MustReadIngress(rawYAML))
+	require.Error(t, err)
+}
+
+func TestWarmupOrigins(t *testing.T) {
+	rawYAML := `
+ingress:
+- hostname: tls.example.com
+  service: https://localhost:8443
+  originRequest:
+    caPool: /does/not/exist.pem
+- service: http://localhost:8000
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+
+	log := zerolog.Nop()
+	shutdownC := make(chan struct{})
+	defer close(shutdownC)
+	// Transports are only built on first use, so the missing CA pool doesn't stop the origins from starting
+	require.NoError(t, ing.StartOrigins(&log, shutdownC))
+	_, err = ing.Rules[0].Service.(HTTPOriginProxy).RoundTrip(httptest.NewRequest(http.MethodGet, "https://tls.example.com", nil))
+	require.Error(t, err)
+	require.Error(t, ing.WarmupOrigins())
+
+	ing.Rules = ing.Rules[1:]
+	require.NoError(t, ing.WarmupOrigins())
+}
+
+func TestParseRetries(t *testing.T) {
+	rawYAML := `
+ingress:
+- hostname: api.example.com
+  service: http://localhost:8000
+  originRequest:
+    retries: 2
+    retryOn: [connect-failure, "502", "503"]
+    retryTimeout: 5s
+- service: http://localhost:8001
+`
+	ing, err := ParseIngress(MustReadIngress(rawYAML))
+	require.NoError(t, err)
+	require.Equal(t, uint(2), ing.Rules[0].Config.Retries)
+	require.Equal(t, []string{RetryOnConnectFailure, "502", "503"}, ing.Rules[0].Config.RetryOn)
+	require.Equal(t, 5*time.Second, ing.Rules[0].Config.RetryTimeout.Duration)
+	require.Equal(t, uint(0), ing.Rules[1].Config.Retries)
+
+	for _, invalid := range []string{"retryOn: [timeout]", "retryOn: [\"404\"]", "retryTimeout: -1s"} {
+		_, err = ParseIngress(MustReadIngress(`
+ingress:
+- service: http://localhost:8000
+  originRequest:
+    ` + invalid + `
+`))
+		require.Error(t, err, invalid)
+	}
+}
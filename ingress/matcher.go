@@ -0,0 +1,75 @@
+package ingress
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ruleMatcher indexes rules by hostname, so finding the rule for a request only evaluates the path regexes of the
+// rules for its hostname, instead of every rule in order. Matching doesn't allocate.
+type ruleMatcher struct {
+	// Indexes of the rules for each exact hostname, in order
+	exact map[string][]int
+	// Indexes of the rules for each wildcard hostname, keyed by the suffix after the wildcard, e.g. ".example.com"
+	wildcard map[string][]int
+	// Indexes of the rules that match any hostname, in order
+	anyHost []int
+	// Number of rules the matcher was built for, so it's not used for rules that changed since
+	numRules int
+}
+
+func newRuleMatcher(rules []Rule) *ruleMatcher {
+	m := &ruleMatcher{
+		exact:    make(map[string][]int),
+		wildcard: make(map[string][]int),
+		numRules: len(rules),
+	}
+	for i, rule := range rules {
+		if rule.Hostname == "" || rule.Hostname == "*" {
+			m.anyHost = append(m.anyHost, i)
+			continue
+		}
+		m.add(rule.Hostname, i)
+		if rule.punycodeHostname != "" {
+			m.add(rule.punycodeHostname, i)
+		}
+	}
+	return m
+}
+
+func (m *ruleMatcher) add(hostname string, ruleIndex int) {
+	if strings.HasPrefix(hostname, "*.") {
+		suffix := strings.TrimPrefix(hostname, "*")
+		m.wildcard[suffix] = append(m.wildcard[suffix], ruleIndex)
+		return
+	}
+	m.exact[hostname] = append(m.exact[hostname], ruleIndex)
+}
+
+// match returns the index of the first rule that matches hostname, path, method and header, or -1 if none does.
+func (m *ruleMatcher) match(rules []Rule, hostname, path, method string, header http.Header) int {
+	best := -1
+	best = firstMatch(rules, m.exact[hostname], path, method, header, best)
+	for i := 0; i < len(hostname); i++ {
+		if hostname[i] == '.' {
+			if candidates, ok := m.wildcard[hostname[i:]]; ok {
+				best = firstMatch(rules, candidates, path, method, header, best)
+			}
+		}
+	}
+	return firstMatch(rules, m.anyHost, path, method, header, best)
+}
+
+// firstMatch returns the first of the candidate rules that comes before best and matches path, method and header, or
+// best if there is none.
+func firstMatch(rules []Rule, candidates []int, path, method string, header http.Header, best int) int {
+	for _, i := range candidates {
+		if best >= 0 && i >= best {
+			break
+		}
+		if rules[i].matchesPath(path) && rules[i].matchesMethodAndHeaders(method, header) {
+			return i
+		}
+	}
+	return best
+}
@@ -0,0 +1,111 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestRuleMatcher(t *testing.T) {
+	rulesYAML := `
+ingress:
+ - hostname: api.example.com
+   path: ^/v2/
+   service: https://localhost:8000
+ - hostname: "*.example.com"
+   path: /admin
+   service: https://localhost:8001
+ - hostname: api.example.com
+   service: https://localhost:8002
+ - hostname: "*.example.com"
+   service: https://localhost:8003
+ - hostname: "*.a.example.com"
+   service: https://localhost:8004
+ - hostname: môô.example.org
+   service: https://localhost:8005
+ - path: /health
+   service: https://localhost:8006
+ - service: http_status:404
+`
+	ing, err := ParseIngress(MustReadIngress(rulesYAML))
+	require.NoError(t, err)
+	require.NotNil(t, ing.matcher)
+
+	hostnames := []string{"api.example.com", "b.a.example.com", "a.example.com", "example.com", "xn--m-sfaa.example.org", "môô.example.org", "other.org", ""}
+	paths := []string{"", "/", "/v2/users", "/admin", "/health", "/v1/admin"}
+	for _, hostname := range hostnames {
+		for _, path := range paths {
+			expected := len(ing.Rules) - 1
+			for i := range ing.Rules {
+				if ing.Rules[i].Matches(hostname, path) {
+					expected = i
+					break
+				}
+			}
+			_, i := ing.FindMatchingRule(hostname, path)
+			assert.Equal(t, expected, i, "hostname %q path %q", hostname, path)
+		}
+	}
+}
+
+func TestRuleMatcherWithGeneratedRules(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+ - hostname: api.example.com
+   service: https://localhost:8000
+ - service: http_status:404
+`))
+	require.NoError(t, err)
+	generated, err := ParseGeneratedRules([]config.UnvalidatedIngressRule{
+		{Hostname: "api.example.com", Service: "https://localhost:8001"},
+		{Hostname: "app.example.com", Service: "https://localhost:8002"},
+	}, ing.Defaults)
+	require.NoError(t, err)
+
+	served := ing.WithGeneratedRules(generated)
+	require.Len(t, served.Rules, 4)
+	require.Len(t, ing.Rules, 2)
+	require.Equal(t, len(served.Rules), served.matcher.numRules)
+	for hostname, expected := range map[string]int{"api.example.com": 0, "app.example.com": 2, "other.example.com": 3} {
+		_, i := served.FindMatchingRule(hostname, "/")
+		assert.Equal(t, expected, i, hostname)
+	}
+}
+
+func manyRulesIngress(b *testing.B, numRules int) Ingress {
+	var rulesYAML strings.Builder
+	rulesYAML.WriteString("ingress:\n")
+	for i := 0; i < numRules; i++ {
+		fmt.Fprintf(&rulesYAML, " - hostname: tunnel%d.example.com\n   path: ^/api/v%d/\n   service: https://localhost:%d\n", i, i%3, 8000+i)
+	}
+	rulesYAML.WriteString(" - service: http_status:404\n")
+	ing, err := ParseIngress(MustReadIngress(rulesYAML.String()))
+	require.NoError(b, err)
+	return ing
+}
+
+func BenchmarkFindMatchManyRules(b *testing.B) {
+	ing := manyRulesIngress(b, 500)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ing.FindMatchingRule("tunnel499.example.com", "/api/v1/users")
+		ing.FindMatchingRule("unknown.example.com", "/")
+	}
+}
+
+func BenchmarkFindMatchManyRulesLinear(b *testing.B) {
+	ing := manyRulesIngress(b, 500)
+	ing.matcher = nil
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ing.FindMatchingRule("tunnel499.example.com", "/api/v1/users")
+		ing.FindMatchingRule("unknown.example.com", "/")
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+const (
+	// DefaultAuthServiceTimeout bounds how long the authorization service may take to make a decision.
+	DefaultAuthServiceTimeout = 5 * time.Second
+
+	headerKeyForwardedMethod = "X-Forwarded-Method"
+	headerKeyForwardedHost   = "X-Forwarded-Host"
+	headerKeyForwardedURI    = "X-Forwarded-Uri"
+)
+
+// AuthRequest describes the request being authorized. It's written as JSON to the stdin of authorization commands.
+type AuthRequest struct {
+	Method  string      `json:"method"`
+	Host    string      `json:"host"`
+	URI     string      `json:"uri"`
+	Headers http.Header `json:"headers"`
+}
+
+// AuthCommandResponse is what an authorization command may write to stdout to inject headers into allowed requests.
+type AuthCommandResponse struct {
+	Headers map[string]string `json:"headers"`
+}
+
+// AuthService is a Handler that asks an external service whether a request should be proxied, similar to
+// ext_authz or forward auth. The service is either an HTTP endpoint or a command:
+//   - The HTTP endpoint receives a GET with the original headers, and X-Forwarded-Method, X-Forwarded-Host and
+//     X-Forwarded-Uri. A 2xx response allows the request, any other status is returned to the eyeball along with
+//     its Location, WWW-Authenticate and Set-Cookie headers.
+//   - The command receives an AuthRequest on stdin. Exiting with 0 allows the request, exiting with any other code
+//     denies it with 403 Forbidden. It may write an AuthCommandResponse to stdout.
+//
+// When a request is allowed, the headers named in injectHeaders are copied from the service's response to the request.
+// If the service can't be reached, the request fails rather than being allowed.
+type AuthService struct {
+	url           string
+	command       []string
+	timeout       time.Duration
+	injectHeaders []string
+	client        *http.Client
+}
+
+// NewHTTPAuthService creates an AuthService calling the HTTP endpoint at url.
+func NewHTTPAuthService(url string, timeout time.Duration, injectHeaders []string) *AuthService {
+	if timeout <= 0 {
+		timeout = DefaultAuthServiceTimeout
+	}
+	return &AuthService{
+		url:           url,
+		timeout:       timeout,
+		injectHeaders: injectHeaders,
+		client: &http.Client{
+			// The response of the authorization service is returned as is, including redirects to a login page.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// NewCommandAuthService creates an AuthService executing command, which is the program followed by its arguments.
+func NewCommandAuthService(command []string, timeout time.Duration, injectHeaders []string) *AuthService {
+	if timeout <= 0 {
+		timeout = DefaultAuthServiceTimeout
+	}
+	return &AuthService{
+		command:       command,
+		timeout:       timeout,
+		injectHeaders: injectHeaders,
+	}
+}
+
+func (a *AuthService) Name() string {
+	return "AuthService"
+}
+
+func (a *AuthService) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	// Injected headers can only come from the authorization service, never from the eyeball.
+	for _, name := range a.injectHeaders {
+		r.Header.Del(name)
+	}
+	if len(a.command) > 0 {
+		return a.handleCommand(ctx, r)
+	}
+	return a.handleHTTP(ctx, r)
+}
+
+func (a *AuthService) handleHTTP(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	authReq, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		authReq.Header[name] = values
+	}
+	authReq.Header.Set(headerKeyForwardedMethod, r.Method)
+	authReq.Header.Set(headerKeyForwardedHost, r.Host)
+	authReq.Header.Set(headerKeyForwardedURI, r.URL.RequestURI())
+
+	resp, err := a.client.Do(authReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach authorization service: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HandleResult{
+			ShouldFilterRequest: true,
+			StatusCode:          resp.StatusCode,
+			Reason:              fmt.Sprintf("authorization service responded with %s", resp.Status),
+			Header:              deniedResponseHeaders(resp.Header),
+		}, nil
+	}
+	for _, name := range a.injectHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			r.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	return &HandleResult{}, nil
+}
+
+// deniedResponseHeaders returns the headers of a denied authorization response that the eyeball needs to authenticate.
+func deniedResponseHeaders(header http.Header) http.Header {
+	denied := make(http.Header)
+	for _, name := range []string{"Location", "Www-Authenticate", "Set-Cookie"} {
+		if values := header.Values(name); len(values) > 0 {
+			denied[name] = values
+		}
+	}
+	return denied
+}
+
+func (a *AuthService) handleCommand(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	input, err := json.Marshal(AuthRequest{
+		Method:  r.Method,
+		Host:    r.Host,
+		URI:     r.URL.RequestURI(),
+		Headers: r.Header,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, a.command[0], a.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return &HandleResult{
+			ShouldFilterRequest: true,
+			StatusCode:          http.StatusForbidden,
+			Reason:              fmt.Sprintf("authorization command exited with %d", exitErr.ExitCode()),
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run authorization command: %w", err)
+	}
+
+	if len(a.injectHeaders) == 0 || len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return &HandleResult{}, nil
+	}
+	var resp AuthCommandResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid authorization command output: %w", err)
+	}
+	returned := make(http.Header, len(resp.Headers))
+	for name, value := range resp.Headers {
+		returned.Set(name, value)
+	}
+	for _, name := range a.injectHeaders {
+		if value := returned.Get(name); value != "" {
+			r.Header.Set(name, value)
+		}
+	}
+	return &HandleResult{}, nil
+}
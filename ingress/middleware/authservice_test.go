@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPAuthService(t *testing.T) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "example.com", r.Header.Get(headerKeyForwardedHost))
+		assert.Equal(t, "/path?q=1", r.Header.Get(headerKeyForwardedURI))
+		if r.Header.Get(headerKeyForwardedMethod) != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Header().Set("Location", "https://login.example.com")
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		w.Header().Set("X-User", "alice")
+		w.Header().Set("X-Internal", "secret")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer authServer.Close()
+
+	auth := NewHTTPAuthService(authServer.URL, time.Second, []string{"x-user"})
+
+	tests := []struct {
+		name           string
+		method         string
+		authorization  string
+		expectFiltered bool
+		expectStatus   int
+		expectUser     string
+		expectLocation string
+	}{
+		{
+			name:          "allowed",
+			method:        http.MethodPost,
+			authorization: "Bearer token",
+			expectUser:    "alice",
+		},
+		{
+			name:           "redirected to login",
+			method:         http.MethodPost,
+			expectFiltered: true,
+			expectStatus:   http.StatusFound,
+			expectLocation: "https://login.example.com",
+		},
+		{
+			name:           "denied",
+			method:         http.MethodGet,
+			authorization:  "Bearer token",
+			expectFiltered: true,
+			expectStatus:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "http://example.com/path?q=1", nil)
+			req.Header.Set("X-User", "spoofed")
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			result, err := auth.Handle(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectFiltered, result.ShouldFilterRequest)
+			if test.expectFiltered {
+				assert.Equal(t, test.expectStatus, result.StatusCode)
+				assert.Equal(t, test.expectLocation, result.Header.Get("Location"))
+			}
+			assert.Equal(t, test.expectUser, req.Header.Get("X-User"))
+			assert.Empty(t, req.Header.Get("X-Internal"))
+		})
+	}
+}
+
+func TestHTTPAuthServiceUnreachable(t *testing.T) {
+	authServer := httptest.NewServer(http.NotFoundHandler())
+	authServer.Close()
+
+	auth := NewHTTPAuthService(authServer.URL, time.Second, nil)
+	_, err := auth.Handle(context.Background(), httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	assert.Error(t, err)
+}
+
+func TestCommandAuthService(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("authorization command test uses sh")
+	}
+
+	allow := NewCommandAuthService(
+		[]string{"sh", "-c", `grep -q '"host":"example.com"' && echo '{"headers":{"X-User":"alice","X-Internal":"secret"}}'`},
+		time.Second,
+		[]string{"X-User"},
+	)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	result, err := allow.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, result.ShouldFilterRequest)
+	assert.Equal(t, "alice", req.Header.Get("X-User"))
+	assert.Empty(t, req.Header.Get("X-Internal"))
+
+	req = httptest.NewRequest(http.MethodGet, "http://other.com", nil)
+	result, err = allow.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, result.ShouldFilterRequest)
+	assert.Equal(t, http.StatusForbidden, result.StatusCode)
+
+	slow := NewCommandAuthService([]string{"sleep", "10"}, 10*time.Millisecond, nil)
+	_, err = slow.Handle(context.Background(), httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// HeaderRewriter sets and removes response headers before they're sent to the eyeball, e.g. to add security headers
+// like Strict-Transport-Security, or to strip the ones that identify the origin's software.
+type HeaderRewriter struct {
+	set    http.Header
+	remove []string
+}
+
+// NewHeaderRewriter creates a HeaderRewriter that removes the headers of remove, then sets the ones of set, replacing
+// the values the origin gave them.
+func NewHeaderRewriter(set map[string]string, remove []string) (*HeaderRewriter, error) {
+	rw := &HeaderRewriter{set: make(http.Header, len(set))}
+	for name, value := range set {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("%q isn't a valid header name", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("the value of header %s isn't valid", name)
+		}
+		rw.set.Set(name, value)
+	}
+	for _, name := range remove {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("%q isn't a valid header name", name)
+		}
+		rw.remove = append(rw.remove, http.CanonicalHeaderKey(name))
+	}
+	return rw, nil
+}
+
+func (rw *HeaderRewriter) Name() string {
+	return "HeaderRewriter"
+}
+
+// Handle doesn't change the request, responses are rewritten by HandleResponseHeaders.
+func (rw *HeaderRewriter) Handle(context.Context, *http.Request) (*HandleResult, error) {
+	return &HandleResult{}, nil
+}
+
+func (rw *HeaderRewriter) HandleResponseHeaders(_ context.Context, _ *http.Request, _ int, header http.Header) error {
+	for _, name := range rw.remove {
+		header.Del(name)
+	}
+	for name, values := range rw.set {
+		header[name] = append([]string{}, values...)
+	}
+	return nil
+}
+
+// HandleResponseBody passes the body through, see MaxBodyBytes.
+func (rw *HeaderRewriter) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+// MaxBodyBytes is 0 because only headers are rewritten, so the body is streamed untouched.
+func (rw *HeaderRewriter) MaxBodyBytes() int {
+	return 0
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderRewriter(t *testing.T) {
+	rw, err := NewHeaderRewriter(map[string]string{
+		"strict-transport-security": "max-age=31536000; includeSubDomains",
+		"X-Frame-Options":           "DENY",
+	}, []string{"server", "X-Powered-By"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "https://app.example.com/", nil)
+	header := http.Header{
+		"Server":          {"nginx/1.18.0"},
+		"X-Powered-By":    {"PHP/7.4"},
+		"X-Frame-Options": {"SAMEORIGIN"},
+		"Content-Type":    {"text/html"},
+	}
+	require.NoError(t, rw.HandleResponseHeaders(context.Background(), req, http.StatusOK, header))
+	assert.Equal(t, http.Header{
+		"Strict-Transport-Security": {"max-age=31536000; includeSubDomains"},
+		"X-Frame-Options":           {"DENY"},
+		"Content-Type":              {"text/html"},
+	}, header)
+}
+
+func TestNewHeaderRewriterInvalid(t *testing.T) {
+	_, err := NewHeaderRewriter(map[string]string{"Bad Name": "value"}, nil)
+	assert.Error(t, err)
+	_, err = NewHeaderRewriter(map[string]string{"X-Header": "line\nbreak"}, nil)
+	assert.Error(t, err)
+	_, err = NewHeaderRewriter(nil, []string{"bad:name"})
+	assert.Error(t, err)
+}
@@ -11,6 +11,8 @@ type HandleResult struct {
 	// The status code to return in case ShouldFilterRequest is true.
 	StatusCode int
 	Reason     string
+	// Headers to return in case ShouldFilterRequest is true.
+	Header http.Header
 }
 
 type Handler interface {
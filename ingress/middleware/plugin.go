@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPluginTimeout bounds how long a single plugin hook may run when the plugin config doesn't set one.
+	DefaultPluginTimeout = time.Second
+	// DefaultPluginMaxBodyBytes bounds how much of each response body is passed to a plugin when the plugin config
+	// doesn't set a limit.
+	DefaultPluginMaxBodyBytes = 1024 * 1024
+	// DefaultPluginMaxMemoryBytes bounds the memory of each instance of a WASM plugin when the plugin config doesn't
+	// set a limit.
+	DefaultPluginMaxMemoryBytes = 16 * 1024 * 1024
+)
+
+// ResponseHandler is a Handler that also sees the response returned by the origin before it's sent to the eyeball.
+type ResponseHandler interface {
+	Handler
+	// HandleResponseHeaders is called once with the origin response status and headers. Headers can be modified in place.
+	HandleResponseHeaders(ctx context.Context, r *http.Request, status int, header http.Header) error
+	// HandleResponseBody is called with each chunk of the response body, and returns what should be sent instead.
+	HandleResponseBody(ctx context.Context, r *http.Request, chunk []byte) ([]byte, error)
+}
+
+// Plugin is a middleware that can be enabled per ingress rule from the configuration file. It has hooks on the
+// request headers, and on the response headers and body.
+type Plugin interface {
+	ResponseHandler
+	// Close releases the resources of the plugin once the ingress rules it was created for aren't served anymore.
+	// Hooks that are running finish, but the hooks called afterwards may fail.
+	Close() error
+}
+
+// PluginFactory creates a plugin from the config given in the ingress rule.
+type PluginFactory func(config map[string]interface{}) (Plugin, error)
+
+// PluginLimits bounds the resources a plugin can use while handling a request.
+type PluginLimits struct {
+	// Timeout bounds each hook call. Requests are failed if a hook doesn't return in time.
+	Timeout time.Duration
+	// MaxBodyBytes bounds how much of the response body is passed to the plugin. The rest is streamed unmodified.
+	MaxBodyBytes int
+	// MaxMemoryBytes bounds the memory of each instance of a WASM plugin.
+	MaxMemoryBytes int
+}
+
+var (
+	pluginsLock sync.RWMutex
+	plugins     = make(map[string]PluginFactory)
+)
+
+// RegisterPlugin makes a plugin available to ingress rules under name. It panics if name is already registered, so
+// it's meant to be called from init functions.
+func RegisterPlugin(name string, factory PluginFactory) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	if _, ok := plugins[name]; ok {
+		panic(fmt.Sprintf("middleware plugin %s is already registered", name))
+	}
+	plugins[name] = factory
+}
+
+// RegisteredPlugins returns the names of all registered plugins.
+func RegisteredPlugins() []string {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPlugin creates a new instance of the plugin registered under name, enforcing limits on its hooks. A new instance
+// is created every time the ingress rules are reloaded, so plugins pick up config changes without restarting.
+func NewPlugin(name string, config map[string]interface{}, limits PluginLimits) (Plugin, error) {
+	pluginsLock.RLock()
+	factory, ok := plugins[name]
+	pluginsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware plugin %s, registered plugins are %v", name, RegisteredPlugins())
+	}
+	plugin, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create middleware plugin %s: %w", name, err)
+	}
+	return limitPlugin(plugin, limits), nil
+}
+
+// limitPlugin enforces limits, or their defaults, on the hooks of plugin.
+func limitPlugin(plugin Plugin, limits PluginLimits) Plugin {
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultPluginTimeout
+	}
+	if limits.MaxBodyBytes <= 0 {
+		limits.MaxBodyBytes = DefaultPluginMaxBodyBytes
+	}
+	return &limitedPlugin{Plugin: plugin, limits: limits}
+}
+
+// limitedPlugin enforces PluginLimits on every hook of a plugin.
+type limitedPlugin struct {
+	Plugin
+	limits PluginLimits
+}
+
+func (p *limitedPlugin) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	// Like the response headers, the request headers are only modified once the hook returned in time.
+	modified := new(http.Request)
+	*modified = *r
+	modified.Header = r.Header.Clone()
+	var result *HandleResult
+	if err := p.withTimeout(ctx, func(ctx context.Context) (err error) {
+		result, err = p.Plugin.Handle(ctx, modified)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	replaceHeader(r.Header, modified.Header)
+	if result == nil {
+		return &HandleResult{}, nil
+	}
+	return result, nil
+}
+
+func (p *limitedPlugin) HandleResponseHeaders(ctx context.Context, r *http.Request, status int, header http.Header) error {
+	// The plugin works on a copy so a hook that times out can't modify the headers while they are being written.
+	modified := header.Clone()
+	if err := p.withTimeout(ctx, func(ctx context.Context) error {
+		return p.Plugin.HandleResponseHeaders(ctx, r, status, modified)
+	}); err != nil {
+		return err
+	}
+	replaceHeader(header, modified)
+	return nil
+}
+
+// replaceHeader makes header the same as modified, keeping the map the caller holds.
+func replaceHeader(header, modified http.Header) {
+	for name := range header {
+		delete(header, name)
+	}
+	for name, values := range modified {
+		header[name] = values
+	}
+}
+
+// HandleResponseBody runs the body hook on a copy of chunk, since the caller reuses its buffer even if the hook
+// times out. Callers stop passing chunks once MaxBodyBytes have been seen.
+func (p *limitedPlugin) HandleResponseBody(ctx context.Context, r *http.Request, chunk []byte) ([]byte, error) {
+	chunk = append([]byte(nil), chunk...)
+	var out []byte
+	if err := p.withTimeout(ctx, func(ctx context.Context) (err error) {
+		out, err = p.Plugin.HandleResponseBody(ctx, r, chunk)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MaxBodyBytes returns how much of each response body should be passed to the plugin.
+func (p *limitedPlugin) MaxBodyBytes() int {
+	return p.limits.MaxBodyBytes
+}
+
+// withTimeout runs hook in its own goroutine so a misbehaving plugin can't hold up the request past the timeout.
+// Anything the hook writes must only be read if withTimeout returns nil.
+func (p *limitedPlugin) withTimeout(ctx context.Context, hook func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, p.limits.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hook(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("middleware plugin %s failed: %w", p.Name(), err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("middleware plugin %s did not finish within %s: %w", p.Name(), p.limits.Timeout, ctx.Err())
+	}
+}
+
+// BodyLimiter is implemented by response handlers that only want to see the beginning of response bodies.
+type BodyLimiter interface {
+	MaxBodyBytes() int
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlugin struct {
+	header string
+	delay  time.Duration
+}
+
+func (p *testPlugin) Name() string { return "test" }
+
+func (p *testPlugin) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	select {
+	case <-time.After(p.delay):
+		return &HandleResult{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *testPlugin) HandleResponseHeaders(_ context.Context, _ *http.Request, _ int, header http.Header) error {
+	header.Set(p.header, "set")
+	header.Del("X-Remove")
+	return nil
+}
+
+func (p *testPlugin) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+func (p *testPlugin) Close() error { return nil }
+
+func init() {
+	RegisterPlugin("test", func(config map[string]interface{}) (Plugin, error) {
+		header, ok := config["header"].(string)
+		if !ok {
+			return nil, errors.New("header is required")
+		}
+		delay, _ := config["delay"].(time.Duration)
+		return &testPlugin{header: header, delay: delay}, nil
+	})
+}
+
+func TestNewPlugin(t *testing.T) {
+	_, err := NewPlugin("unknown", nil, PluginLimits{})
+	assert.Error(t, err)
+
+	_, err = NewPlugin("test", nil, PluginLimits{})
+	assert.Error(t, err)
+
+	plugin, err := NewPlugin("test", map[string]interface{}{"header": "X-Test"}, PluginLimits{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPluginMaxBodyBytes, plugin.(BodyLimiter).MaxBodyBytes())
+	assert.Contains(t, RegisteredPlugins(), "test")
+
+	assert.Panics(t, func() {
+		RegisterPlugin("test", nil)
+	})
+}
+
+func TestPluginResponseHeaders(t *testing.T) {
+	plugin, err := NewPlugin("test", map[string]interface{}{"header": "X-Test"}, PluginLimits{})
+	require.NoError(t, err)
+
+	header := http.Header{"X-Remove": {"value"}, "X-Keep": {"value"}}
+	require.NoError(t, plugin.HandleResponseHeaders(context.Background(), nil, http.StatusOK, header))
+	assert.Equal(t, http.Header{"X-Test": {"set"}, "X-Keep": {"value"}}, header)
+}
+
+func TestPluginTimeout(t *testing.T) {
+	plugin, err := NewPlugin("test", map[string]interface{}{"header": "X-Test", "delay": time.Minute}, PluginLimits{Timeout: time.Millisecond})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	_, err = plugin.Handle(context.Background(), req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	headerKeyForwardedProto = "X-Forwarded-Proto"
+	headerKeySetCookie      = "Set-Cookie"
+)
+
+// ResponseRewriteOptions selects which parts of origin responses a ResponseRewriter rewrites.
+type ResponseRewriteOptions struct {
+	// InternalHosts are the addresses the origin may refer to itself with. Hosts with a port only match that port,
+	// hosts without one match any port.
+	InternalHosts []string
+	// Location rewrites Location and Content-Location headers that point to an internal host.
+	Location bool
+	// CookieDomain rewrites the Domain attribute of cookies set for an internal host.
+	CookieDomain bool
+	// CookiePaths rewrites the Path attribute of cookies from the origin path prefix (key) to the public one (value).
+	CookiePaths map[string]string
+}
+
+type pathRewrite struct {
+	from, to string
+}
+
+// ResponseRewriter rewrites references to the origin's internal address in response headers to the public hostname
+// of the request, so applications that emit absolute internal URLs work behind the tunnel.
+type ResponseRewriter struct {
+	hostsWithPort map[string]bool
+	hostnames     map[string]bool
+	// Cookie domains don't have a port, so they match the hostname of any internal host.
+	cookieDomains map[string]bool
+	options       ResponseRewriteOptions
+	// Longest prefixes first, so the most specific one applies.
+	cookiePaths []pathRewrite
+}
+
+// NewResponseRewriter creates a ResponseRewriter.
+func NewResponseRewriter(options ResponseRewriteOptions) *ResponseRewriter {
+	rw := &ResponseRewriter{
+		hostsWithPort: make(map[string]bool),
+		hostnames:     make(map[string]bool),
+		cookieDomains: make(map[string]bool),
+		options:       options,
+	}
+	for _, host := range options.InternalHosts {
+		host = strings.ToLower(host)
+		u := &url.URL{Host: host}
+		if u.Hostname() == "" {
+			continue
+		}
+		if u.Port() != "" {
+			rw.hostsWithPort[host] = true
+		} else {
+			rw.hostnames[u.Hostname()] = true
+		}
+		rw.cookieDomains[u.Hostname()] = true
+	}
+	for from, to := range options.CookiePaths {
+		// Without trailing slashes, the rest of a matching path is either empty or starts with a slash.
+		rw.cookiePaths = append(rw.cookiePaths, pathRewrite{from: strings.TrimSuffix(from, "/"), to: strings.TrimSuffix(to, "/")})
+	}
+	sort.Slice(rw.cookiePaths, func(i, j int) bool {
+		return len(rw.cookiePaths[i].from) > len(rw.cookiePaths[j].from)
+	})
+	return rw
+}
+
+func (rw *ResponseRewriter) Name() string {
+	return "ResponseRewriter"
+}
+
+// Handle doesn't change the request, responses are rewritten by HandleResponseHeaders.
+func (rw *ResponseRewriter) Handle(context.Context, *http.Request) (*HandleResult, error) {
+	return &HandleResult{}, nil
+}
+
+func (rw *ResponseRewriter) HandleResponseHeaders(_ context.Context, r *http.Request, _ int, header http.Header) error {
+	publicHost := rw.publicHost(r)
+	if publicHost == "" {
+		return nil
+	}
+	if rw.options.Location {
+		for _, key := range []string{"Location", "Content-Location"} {
+			if location := header.Get(key); location != "" {
+				header.Set(key, rw.rewriteLocation(location, publicHost, publicScheme(r)))
+			}
+		}
+	}
+	if rw.options.CookieDomain || len(rw.cookiePaths) > 0 {
+		cookies := header[headerKeySetCookie]
+		for i, cookie := range cookies {
+			cookies[i] = rw.rewriteCookie(cookie, publicHost)
+		}
+	}
+	return nil
+}
+
+// HandleResponseBody passes the body through, see MaxBodyBytes.
+func (rw *ResponseRewriter) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+// MaxBodyBytes is 0 because only headers are rewritten, so the body is streamed untouched.
+func (rw *ResponseRewriter) MaxBodyBytes() int {
+	return 0
+}
+
+func (rw *ResponseRewriter) isInternal(host string) bool {
+	host = strings.ToLower(host)
+	if rw.hostsWithPort[host] {
+		return true
+	}
+	return rw.hostnames[(&url.URL{Host: host}).Hostname()]
+}
+
+// publicHost returns the host the eyeball requested. If the Host header was replaced with httpHostHeader, which is
+// one of the internal hosts, the original one was moved to X-Forwarded-Host.
+func (rw *ResponseRewriter) publicHost(r *http.Request) string {
+	if rw.isInternal(r.Host) {
+		return r.Header.Get("X-Forwarded-Host")
+	}
+	return r.Host
+}
+
+func publicScheme(r *http.Request) string {
+	if proto := r.Header.Get(headerKeyForwardedProto); proto == "http" || proto == "https" {
+		return proto
+	}
+	return "https"
+}
+
+func (rw *ResponseRewriter) rewriteLocation(location, publicHost, scheme string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" || !rw.isInternal(u.Host) {
+		return location
+	}
+	u.Host = publicHost
+	if u.Scheme != "" {
+		u.Scheme = scheme
+	}
+	return u.String()
+}
+
+// rewriteCookie rewrites the attributes of a Set-Cookie header value in place, keeping the ones it doesn't know
+// about untouched.
+func (rw *ResponseRewriter) rewriteCookie(cookie, publicHost string) string {
+	parts := strings.Split(cookie, ";")
+	// The first part is the cookie's name and value
+	for i := 1; i < len(parts); i++ {
+		name, value, _ := strings.Cut(parts[i], "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "domain":
+			domain := strings.TrimPrefix(strings.TrimSpace(value), ".")
+			if rw.options.CookieDomain && rw.cookieDomains[strings.ToLower(domain)] {
+				parts[i] = " Domain=" + (&url.URL{Host: publicHost}).Hostname()
+			}
+		case "path":
+			path := strings.TrimSpace(value)
+			for _, rewrite := range rw.cookiePaths {
+				if hasPathPrefix(path, rewrite.from) {
+					newPath := rewrite.to + strings.TrimPrefix(path, rewrite.from)
+					if newPath == "" {
+						newPath = "/"
+					}
+					parts[i] = " Path=" + newPath
+					break
+				}
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// hasPathPrefix checks if prefix is made of whole segments of path, so /app doesn't match /application.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
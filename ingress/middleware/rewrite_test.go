@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseRewriterLocation(t *testing.T) {
+	rw := NewResponseRewriter(ResponseRewriteOptions{
+		InternalHosts: []string{"localhost:8080", "app.internal"},
+		Location:      true,
+	})
+
+	tests := []struct {
+		location string
+		expected string
+	}{
+		{location: "http://localhost:8080/login?next=%2F", expected: "https://app.example.com/login?next=%2F"},
+		{location: "http://APP.internal:9000/", expected: "https://app.example.com/"},
+		{location: "//localhost:8080/login", expected: "//app.example.com/login"},
+		// Different port than the internal one
+		{location: "http://localhost:9090/login", expected: "http://localhost:9090/login"},
+		{location: "https://login.example.com/", expected: "https://login.example.com/"},
+		{location: "/relative", expected: "/relative"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "https://app.example.com/", nil)
+		header := http.Header{"Location": {test.location}}
+		require.NoError(t, rw.HandleResponseHeaders(context.Background(), req, http.StatusFound, header))
+		assert.Equal(t, test.expected, header.Get("Location"), test.location)
+	}
+}
+
+func TestResponseRewriterHostHeader(t *testing.T) {
+	rw := NewResponseRewriter(ResponseRewriteOptions{
+		InternalHosts: []string{"localhost:8080", "origin.internal"},
+		Location:      true,
+	})
+	// The Host header was replaced with httpHostHeader before the request was sent to the origin
+	req := httptest.NewRequest(http.MethodGet, "http://origin.internal/", nil)
+	req.Header.Set("X-Forwarded-Host", "app.example.com")
+	req.Header.Set(headerKeyForwardedProto, "http")
+	header := http.Header{"Location": {"http://origin.internal/home"}}
+	require.NoError(t, rw.HandleResponseHeaders(context.Background(), req, http.StatusFound, header))
+	assert.Equal(t, "http://app.example.com/home", header.Get("Location"))
+}
+
+func TestResponseRewriterCookies(t *testing.T) {
+	rw := NewResponseRewriter(ResponseRewriteOptions{
+		InternalHosts: []string{"localhost:8080"},
+		CookieDomain:  true,
+		CookiePaths:   map[string]string{"/app": "/", "/app/admin/": "/admin"},
+	})
+	req := httptest.NewRequest(http.MethodGet, "https://app.example.com:8443/", nil)
+	header := http.Header{}
+	header.Add("Set-Cookie", "session=abc; Domain=.localhost; Path=/app; Secure; HttpOnly")
+	header.Add("Set-Cookie", "admin=1; path=/app/admin/users; SameSite=Strict")
+	header.Add("Set-Cookie", "other=1; Domain=example.org; Path=/application")
+	require.NoError(t, rw.HandleResponseHeaders(context.Background(), req, http.StatusOK, header))
+	assert.Equal(t, []string{
+		"session=abc; Domain=app.example.com; Path=/; Secure; HttpOnly",
+		"admin=1; Path=/admin/users; SameSite=Strict",
+		"other=1; Domain=example.org; Path=/application",
+	}, header.Values("Set-Cookie"))
+}
@@ -0,0 +1,16 @@
+;; Denies every request.
+;; Built with: wat2wasm deny.wat
+(module
+  (memory (export "memory") 1)
+  (global $next (mut i32) (i32.const 1024))
+  (data (i32.const 0) "{\"deny\":{\"status\":401,\"reason\":\"missing credentials\",\"headers\":{\"WWW-Authenticate\":\"Basic\"}}}")
+
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $next))
+    (global.set $next (i32.add (global.get $next) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "on_request") (param i32 i32) (result i64)
+    (i64.const 93))
+)
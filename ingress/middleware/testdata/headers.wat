@@ -0,0 +1,40 @@
+;; Sets a request header, removes the Server response header and upper-cases response bodies.
+;; Built with: wat2wasm headers.wat
+(module
+  (memory (export "memory") 2)
+  (global $next (mut i32) (i32.const 1024))
+  (data (i32.const 0) "{\"setHeaders\":{\"X-Plugin\":\"wasm\"}}")
+  (data (i32.const 64) "{\"removeHeaders\":[\"Server\"]}")
+
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $next))
+    (global.set $next (i32.add (global.get $next) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "free") (param i32)
+    (global.set $next (i32.const 1024)))
+
+  (func (export "on_request") (param i32 i32) (result i64)
+    (i64.const 34))
+
+  (func (export "on_response_headers") (param i32 i32) (result i64)
+    (i64.const 274877906972))
+
+  (func (export "on_response_body") (param $ptr i32) (param $len i32) (result i64)
+    (local $i i32)
+    (local $c i32)
+    (block
+      (loop
+        (br_if 1 (i32.ge_u (local.get $i) (local.get $len)))
+        (local.set $c (i32.load8_u (i32.add (local.get $ptr) (local.get $i))))
+        (if (i32.lt_u (i32.sub (local.get $c) (i32.const 97)) (i32.const 26))
+          (then
+            (i32.store8 (i32.add (local.get $ptr) (local.get $i))
+              (i32.sub (local.get $c) (i32.const 32)))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br 0)))
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $ptr)) (i64.const 32))
+      (i64.extend_i32_u (local.get $len))))
+)
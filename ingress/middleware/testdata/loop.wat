@@ -0,0 +1,16 @@
+;; Never returns from on_request.
+;; Built with: wat2wasm loop.wat
+(module
+  (memory (export "memory") 1)
+  (global $next (mut i32) (i32.const 1024))
+
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $next))
+    (global.set $next (i32.add (global.get $next) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "on_request") (param i32 i32) (result i64)
+    (loop (br 0))
+    (i64.const 0))
+)
@@ -0,0 +1,389 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// WASM plugins are WebAssembly modules run by wazero, so they can be written in any language that compiles to WASM and
+// added without rebuilding cloudflared. A module exports its memory, an allocator, and any of the hooks:
+//
+//	malloc(size i32) i32                       required, allocates the input of the hooks
+//	free(ptr i32)                              optional, releases the inputs and outputs of the hooks
+//	configure(ptr i32, len i32)                optional, gets the JSON of the plugin's config once per instance
+//	on_request(ptr i32, len i32) i64           gets a wasmRequest, returns a wasmRequestAction
+//	on_response_headers(ptr i32, len i32) i64  gets a wasmResponse, returns a wasmHeaderAction
+//	on_response_body(ptr i32, len i32) i64     gets a chunk of the response body, returns the chunk to send instead
+//
+// Hooks return where their output is in memory, as ptr<<32 | len, or 0 to leave the request or response unchanged.
+// Instances of the module are reused across requests, but never run more than one hook at a time.
+const (
+	wasmMalloc            = "malloc"
+	wasmFree              = "free"
+	wasmConfigure         = "configure"
+	wasmOnRequest         = "on_request"
+	wasmOnResponseHeaders = "on_response_headers"
+	wasmOnResponseBody    = "on_response_body"
+
+	// wasmReloadInterval is how often the module file is checked for changes
+	wasmReloadInterval = time.Second
+	// wasmMaxIdleInstances bounds how many instances are kept between requests
+	wasmMaxIdleInstances = 16
+	wasmPageSize         = 64 * 1024
+)
+
+// wasmRequest is what the on_request hook gets.
+type wasmRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Host    string      `json:"host"`
+	Headers http.Header `json:"headers"`
+}
+
+// wasmResponse is what the on_response_headers hook gets.
+type wasmResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+}
+
+// wasmHeaderAction changes headers. Headers are removed before others are set.
+type wasmHeaderAction struct {
+	SetHeaders    map[string]string `json:"setHeaders"`
+	RemoveHeaders []string          `json:"removeHeaders"`
+}
+
+func (a *wasmHeaderAction) apply(header http.Header) {
+	for _, name := range a.RemoveHeaders {
+		header.Del(name)
+	}
+	for name, value := range a.SetHeaders {
+		header.Set(name, value)
+	}
+}
+
+// wasmRequestAction is what the on_request hook returns: it changes the headers of the request, or denies it.
+type wasmRequestAction struct {
+	wasmHeaderAction
+	Deny *struct {
+		Status  int               `json:"status"`
+		Reason  string            `json:"reason"`
+		Headers map[string]string `json:"headers"`
+	} `json:"deny"`
+}
+
+// NewWASMPlugin loads the WebAssembly module at path as a plugin, enforcing limits on its hooks. The module is loaded
+// again when the file changes, requests that are already running finish with the previous one.
+func NewWASMPlugin(name, path string, config map[string]interface{}, limits PluginLimits) (Plugin, error) {
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	if limits.MaxMemoryBytes <= 0 {
+		limits.MaxMemoryBytes = DefaultPluginMaxMemoryBytes
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for middleware plugin %s: %w", name, err)
+	}
+	plugin := &wasmPlugin{
+		name:        name,
+		path:        path,
+		config:      configJSON,
+		memoryPages: uint32((limits.MaxMemoryBytes + wasmPageSize - 1) / wasmPageSize),
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load middleware plugin %s: %w", name, err)
+	}
+	if plugin.module, err = plugin.load(info); err != nil {
+		return nil, fmt.Errorf("failed to load middleware plugin %s from %s: %w", name, path, err)
+	}
+	plugin.checkedAt = time.Now()
+	// Instantiating the module once reports the errors of its configure function now
+	instance, err := plugin.module.instantiate(context.Background(), plugin.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start middleware plugin %s: %w", name, err)
+	}
+	plugin.module.release(instance)
+	return limitPlugin(plugin, limits), nil
+}
+
+// wasmPlugin runs the hooks of a WebAssembly module.
+type wasmPlugin struct {
+	name        string
+	path        string
+	config      []byte
+	memoryPages uint32
+
+	lock      sync.Mutex
+	module    *wasmModule
+	checkedAt time.Time
+	closed    bool
+}
+
+// wasmModule is a version of the module file, with the instances that are idle.
+type wasmModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	modTime  time.Time
+	size     int64
+	idle     chan api.Module
+
+	lock sync.Mutex
+	// Number of instances running a hook, the runtime is closed once the module was replaced and they're done
+	inUse    int
+	replaced bool
+}
+
+func (p *wasmPlugin) Name() string {
+	return p.name
+}
+
+func (p *wasmPlugin) load(info os.FileInfo) (*wasmModule, error) {
+	binary, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(p.memoryPages).
+		// Hooks that exceed their timeout are interrupted
+		WithCloseOnContextDone(true))
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	if _, ok := compiled.ExportedFunctions()[wasmMalloc]; !ok {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("the module doesn't export %s", wasmMalloc)
+	}
+	if len(compiled.ExportedMemories()) == 0 {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("the module doesn't export its memory")
+	}
+	return &wasmModule{
+		runtime:  runtime,
+		compiled: compiled,
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		idle:     make(chan api.Module, wasmMaxIdleInstances),
+	}, nil
+}
+
+// current returns the module to run hooks with, loading the file again if it changed, or nil once the plugin is closed.
+// A file that fails to load is ignored until it changes again.
+func (p *wasmPlugin) current() *wasmModule {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil
+	}
+	if time.Since(p.checkedAt) < wasmReloadInterval {
+		return p.module
+	}
+	p.checkedAt = time.Now()
+	info, err := os.Stat(p.path)
+	if err != nil || (info.ModTime().Equal(p.module.modTime) && info.Size() == p.module.size) {
+		return p.module
+	}
+	module, err := p.load(info)
+	if err != nil {
+		p.module.modTime, p.module.size = info.ModTime(), info.Size()
+		return p.module
+	}
+	p.module.replace()
+	p.module = module
+	return module
+}
+
+// Close releases the runtime of the module once the hooks it's running are done.
+func (p *wasmPlugin) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.module.replace()
+	}
+	return nil
+}
+
+// instantiate returns an idle instance of the module, or a new one.
+func (m *wasmModule) instantiate(ctx context.Context, config []byte) (api.Module, error) {
+	m.lock.Lock()
+	m.inUse++
+	m.lock.Unlock()
+	select {
+	case instance := <-m.idle:
+		return instance, nil
+	default:
+	}
+	// Instances are anonymous, so there can be many of them
+	instance, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		m.release(nil)
+		return nil, err
+	}
+	if configure := instance.ExportedFunction(wasmConfigure); configure != nil {
+		if _, err := callWithInput(ctx, instance, configure, config); err != nil {
+			_ = instance.Close(ctx)
+			m.release(nil)
+			return nil, err
+		}
+	}
+	return instance, nil
+}
+
+// release makes an instance idle again, or closes it if it can't be reused.
+func (m *wasmModule) release(instance api.Module) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.inUse--
+	if instance != nil && !instance.IsClosed() {
+		reused := false
+		if !m.replaced {
+			select {
+			case m.idle <- instance:
+				reused = true
+			default:
+			}
+		}
+		if !reused {
+			_ = instance.Close(context.Background())
+		}
+	}
+	m.closeIfDone()
+}
+
+// replace closes the module once the hooks it's running are done.
+func (m *wasmModule) replace() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.replaced = true
+	m.closeIfDone()
+}
+
+// closeIfDone closes the runtime of a replaced module that isn't running hooks anymore. m.lock must be held.
+func (m *wasmModule) closeIfDone() {
+	if m.replaced && m.inUse == 0 {
+		_ = m.runtime.Close(context.Background())
+	}
+}
+
+// call runs the hook with input, and returns its output, or nil if the module doesn't have the hook or it left the
+// input unchanged.
+func (p *wasmPlugin) call(ctx context.Context, hook string, input []byte) ([]byte, error) {
+	module := p.current()
+	if module == nil {
+		return nil, fmt.Errorf("the plugin is closed")
+	}
+	instance, err := module.instantiate(ctx, p.config)
+	if err != nil {
+		return nil, err
+	}
+	fn := instance.ExportedFunction(hook)
+	if fn == nil {
+		module.release(instance)
+		return nil, nil
+	}
+	output, err := callWithInput(ctx, instance, fn, input)
+	if err != nil {
+		// The state of an instance that trapped can't be trusted
+		_ = instance.Close(ctx)
+	}
+	module.release(instance)
+	return output, err
+}
+
+// callWithInput copies input to the memory of instance and calls fn with it. It returns a copy of the output of fn.
+func callWithInput(ctx context.Context, instance api.Module, fn api.Function, input []byte) ([]byte, error) {
+	results, err := instance.ExportedFunction(wasmMalloc).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	inputPtr := uint32(results[0])
+	if !instance.Memory().Write(inputPtr, input) {
+		return nil, fmt.Errorf("%s returned memory out of range", wasmMalloc)
+	}
+	free := instance.ExportedFunction(wasmFree)
+	if free != nil {
+		defer free.Call(ctx, uint64(inputPtr))
+	}
+	results, err = fn.Call(ctx, uint64(inputPtr), uint64(len(input)))
+	if err != nil || len(results) == 0 || results[0] == 0 {
+		return nil, err
+	}
+	outputPtr, outputLen := uint32(results[0]>>32), uint32(results[0])
+	output, ok := instance.Memory().Read(outputPtr, outputLen)
+	if !ok {
+		return nil, fmt.Errorf("%s returned memory out of range", fn.Definition().Name())
+	}
+	output = append(make([]byte, 0, len(output)), output...)
+	if free != nil && outputPtr != inputPtr {
+		_, _ = free.Call(ctx, uint64(outputPtr))
+	}
+	return output, nil
+}
+
+func (p *wasmPlugin) Handle(ctx context.Context, r *http.Request) (*HandleResult, error) {
+	input, err := json.Marshal(wasmRequest{Method: r.Method, URL: r.URL.String(), Host: r.Host, Headers: r.Header})
+	if err != nil {
+		return nil, err
+	}
+	output, err := p.call(ctx, wasmOnRequest, input)
+	if err != nil || output == nil {
+		return &HandleResult{}, err
+	}
+	var action wasmRequestAction
+	if err := json.Unmarshal(output, &action); err != nil {
+		return nil, fmt.Errorf("invalid output of %s: %w", wasmOnRequest, err)
+	}
+	if deny := action.Deny; deny != nil {
+		result := &HandleResult{ShouldFilterRequest: true, StatusCode: deny.Status, Reason: deny.Reason, Header: make(http.Header)}
+		if result.StatusCode == 0 {
+			result.StatusCode = http.StatusForbidden
+		}
+		for name, value := range deny.Headers {
+			result.Header.Set(name, value)
+		}
+		return result, nil
+	}
+	action.apply(r.Header)
+	return &HandleResult{}, nil
+}
+
+func (p *wasmPlugin) HandleResponseHeaders(ctx context.Context, _ *http.Request, status int, header http.Header) error {
+	input, err := json.Marshal(wasmResponse{Status: status, Headers: header})
+	if err != nil {
+		return err
+	}
+	output, err := p.call(ctx, wasmOnResponseHeaders, input)
+	if err != nil || output == nil {
+		return err
+	}
+	var action wasmHeaderAction
+	if err := json.Unmarshal(output, &action); err != nil {
+		return fmt.Errorf("invalid output of %s: %w", wasmOnResponseHeaders, err)
+	}
+	action.apply(header)
+	return nil
+}
+
+func (p *wasmPlugin) HandleResponseBody(ctx context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	output, err := p.call(ctx, wasmOnResponseBody, chunk)
+	if err != nil {
+		return nil, err
+	}
+	if output == nil {
+		return chunk, nil
+	}
+	return output, nil
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWASMPlugin(t *testing.T) {
+	plugin, err := NewWASMPlugin("", "testdata/headers.wasm", map[string]interface{}{"key": "value"}, PluginLimits{})
+	require.NoError(t, err)
+	assert.Equal(t, "headers.wasm", plugin.Name())
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	result, err := plugin.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.False(t, result.ShouldFilterRequest)
+	assert.Equal(t, "wasm", req.Header.Get("X-Plugin"))
+
+	header := http.Header{"Server": {"origin"}, "Content-Type": {"text/plain"}}
+	require.NoError(t, plugin.HandleResponseHeaders(context.Background(), req, http.StatusOK, header))
+	assert.Equal(t, http.Header{"Content-Type": {"text/plain"}}, header)
+
+	// Instances are reused, so the hooks have to keep working
+	for i := 0; i < 3; i++ {
+		body, err := plugin.HandleResponseBody(context.Background(), req, []byte("hello, world"))
+		require.NoError(t, err)
+		assert.Equal(t, "HELLO, WORLD", string(body))
+	}
+}
+
+func TestWASMPluginClose(t *testing.T) {
+	plugin, err := NewWASMPlugin("", "testdata/headers.wasm", nil, PluginLimits{})
+	require.NoError(t, err)
+	module := plugin.(*limitedPlugin).Plugin.(*wasmPlugin).module
+
+	require.NoError(t, plugin.Close())
+	assert.True(t, module.replaced)
+	// Closing again is a no-op
+	require.NoError(t, plugin.Close())
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	_, err = plugin.Handle(context.Background(), req)
+	assert.ErrorContains(t, err, "closed")
+}
+
+func TestWASMPluginDeny(t *testing.T) {
+	plugin, err := NewWASMPlugin("deny", "testdata/deny.wasm", nil, PluginLimits{})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	result, err := plugin.Handle(context.Background(), req)
+	require.NoError(t, err)
+	assert.True(t, result.ShouldFilterRequest)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	assert.Equal(t, "missing credentials", result.Reason)
+	assert.Equal(t, "Basic", result.Header.Get("WWW-Authenticate"))
+
+	// The module doesn't have response hooks
+	body, err := plugin.HandleResponseBody(context.Background(), req, []byte("body"))
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+}
+
+func TestWASMPluginLimits(t *testing.T) {
+	plugin, err := NewWASMPlugin("loop", "testdata/loop.wasm", nil, PluginLimits{Timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	require.NoError(t, err)
+	_, err = plugin.Handle(context.Background(), req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// headers.wasm needs 2 pages of memory
+	_, err = NewWASMPlugin("headers", "testdata/headers.wasm", nil, PluginLimits{MaxMemoryBytes: wasmPageSize})
+	assert.Error(t, err)
+
+	_, err = NewWASMPlugin("missing", "testdata/missing.wasm", nil, PluginLimits{})
+	assert.Error(t, err)
+}
+
+func TestWASMPluginReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	copyFile(t, "testdata/headers.wasm", path)
+	plugin, err := NewWASMPlugin("plugin", path, nil, PluginLimits{})
+	require.NoError(t, err)
+	wasm := plugin.(*limitedPlugin).Plugin.(*wasmPlugin)
+
+	handle := func() *HandleResult {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		require.NoError(t, err)
+		result, err := plugin.Handle(context.Background(), req)
+		require.NoError(t, err)
+		return result
+	}
+	assert.False(t, handle().ShouldFilterRequest)
+
+	modified := time.Now().Add(time.Minute)
+	copyFile(t, "testdata/deny.wasm", path)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+	wasm.checkedAt = time.Time{}
+	assert.True(t, handle().ShouldFilterRequest)
+
+	// A module that fails to load is ignored
+	require.NoError(t, os.WriteFile(path, []byte("not wasm"), 0600))
+	require.NoError(t, os.Chtimes(path, modified.Add(time.Minute), modified.Add(time.Minute)))
+	wasm.checkedAt = time.Time{}
+	assert.True(t, handle().ShouldFilterRequest)
+}
+
+func copyFile(t *testing.T, from, to string) {
+	content, err := os.ReadFile(from)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(to, content, 0600))
+}
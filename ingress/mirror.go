@@ -0,0 +1,106 @@
+package ingress
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	defaultMirrorTimeout       = 30 * time.Second
+	defaultMirrorMaxBodyBytes  = 1024 * 1024
+	defaultMirrorMaxConcurrent = 100
+)
+
+// Mirror sends copies of a share of the requests matching a rule to a shadow origin, so a new backend can be tested
+// with real traffic. Responses from the shadow origin are discarded.
+type Mirror struct {
+	// Service receives the copies of the requests.
+	Service OriginService
+	// Percentage of the requests that are mirrored, from 0 to 100.
+	Percentage float64
+	// Timeout bounds how long a mirrored request may take.
+	Timeout time.Duration
+	// MaxBodyBytes bounds the size of the request bodies that are mirrored.
+	MaxBodyBytes int64
+	// MaxConcurrent bounds how many mirrored requests are in flight at once, 0 meaning the default.
+	MaxConcurrent int
+
+	inFlight atomic.Int64
+}
+
+func newMirror(cfg *config.MirrorConfig) (*Mirror, error) {
+	service, err := parseHTTPService(cfg.Service)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mirror{
+		Service:       service,
+		Percentage:    100,
+		Timeout:       defaultMirrorTimeout,
+		MaxBodyBytes:  defaultMirrorMaxBodyBytes,
+		MaxConcurrent: defaultMirrorMaxConcurrent,
+	}
+	if cfg.Percentage != nil {
+		m.Percentage = *cfg.Percentage
+	}
+	if m.Percentage < 0 || m.Percentage > 100 {
+		return nil, fmt.Errorf("mirror percentage must be between 0 and 100, got %v", m.Percentage)
+	}
+	if cfg.Timeout != nil {
+		m.Timeout = cfg.Timeout.Duration
+	}
+	if cfg.MaxBodyBytes != nil {
+		m.MaxBodyBytes = *cfg.MaxBodyBytes
+	}
+	if cfg.MaxConcurrent != nil {
+		m.MaxConcurrent = *cfg.MaxConcurrent
+	}
+	if m.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("mirror maxConcurrent can't be negative, got %d", m.MaxConcurrent)
+	}
+	return m, nil
+}
+
+// Sample decides whether a request should be mirrored.
+func (m *Mirror) Sample() bool {
+	return rand.Float64()*100 < m.Percentage
+}
+
+// Acquire reserves one of the mirrored requests that can be in flight, and returns false if they all are. A reserved
+// request is given back with Release.
+func (m *Mirror) Acquire() bool {
+	maxConcurrent := int64(m.MaxConcurrent)
+	if maxConcurrent == 0 {
+		maxConcurrent = defaultMirrorMaxConcurrent
+	}
+	if m.inFlight.Add(1) > maxConcurrent {
+		m.inFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+// Release gives back a mirrored request reserved with Acquire.
+func (m *Mirror) Release() {
+	m.inFlight.Add(-1)
+}
+
+// parseHTTPService parses the address of an HTTP origin that isn't the main service of a rule.
+func parseHTTPService(rawService string) (*httpService, error) {
+	u, err := url.Parse(rawService)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%s is an invalid service, it must be an http or https origin", rawService)
+	}
+	if u.Path != "" {
+		return nil, fmt.Errorf("%s is an invalid service, proxying to a different path on the origin service isn't supported", rawService)
+	}
+	return &httpService{url: u}, nil
+}
@@ -0,0 +1,195 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+const (
+	fileScheme = "file"
+
+	defaultIndexFile = "index.html"
+)
+
+// fileService serves the files of a directory, e.g. file:///var/www/site, like a static web server: index files are
+// served for directories, which can also be listed, and ETag, conditional and Range requests are supported. Hidden
+// files, whose name starts with a dot, aren't served.
+type fileService struct {
+	service    string
+	root       string
+	listing    bool
+	indexFiles []string
+}
+
+func isFileService(service string) bool {
+	return strings.HasPrefix(service, fileScheme+"://")
+}
+
+func newFileService(service string) (*fileService, error) {
+	u, err := url.Parse(service)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("%s is an invalid file service, it should be an absolute path like file:///var/www/site", service)
+	}
+	root := filepath.FromSlash(u.Path)
+	// file:///C:/site is C:\site on Windows
+	if len(root) > 1 && filepath.VolumeName(root[1:]) != "" {
+		root = root[1:]
+	}
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("%s is an invalid file service, it should be an absolute path like file:///var/www/site", service)
+	}
+	return &fileService{service: service, root: filepath.Clean(root)}, nil
+}
+
+func (o *fileService) String() string {
+	return o.service
+}
+
+func (o *fileService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+func (o *fileService) start(_ *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
+	info, err := os.Stat(o.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s isn't a directory", o.root)
+	}
+	o.indexFiles = []string{defaultIndexFile}
+	if cfg.FileServer != nil {
+		o.listing = cfg.FileServer.DirectoryListing
+		if len(cfg.FileServer.IndexFiles) > 0 {
+			o.indexFiles = cfg.FileServer.IndexFiles
+		}
+	}
+	return nil
+}
+
+func (o *fileService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	// http.Dir doesn't let requests escape the root directory
+	dir := http.Dir(o.root)
+	file, info, err := openFile(dir, name)
+	if err != nil {
+		o.serveError(w, r, err)
+		return
+	}
+	defer file.Close()
+	if !info.IsDir() {
+		serveFile(w, r, file, info)
+		return
+	}
+
+	if name != "/" && !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, path.Base(name)+"/", http.StatusMovedPermanently)
+		return
+	}
+	for _, indexFile := range o.indexFiles {
+		index, indexInfo, err := openFile(dir, path.Join(name, indexFile))
+		if err != nil {
+			continue
+		}
+		if !indexInfo.IsDir() {
+			serveFile(w, r, index, indexInfo)
+			_ = index.Close()
+			return
+		}
+		_ = index.Close()
+	}
+	if !o.listing {
+		http.NotFound(w, r)
+		return
+	}
+	entries, err := file.Readdir(-1)
+	if err != nil {
+		o.serveError(w, r, err)
+		return
+	}
+	listDirectory(w, r, name, entries)
+}
+
+func openFile(dir http.Dir, name string) (http.File, fs.FileInfo, error) {
+	file, err := dir.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+func (o *fileService) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(w, r)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// serveFile serves the file with an ETag made of its modification time and size, like nginx's. http.ServeContent
+// detects its MIME type, and handles the conditional and Range requests.
+func serveFile(w http.ResponseWriter, r *http.Request, file http.File, info fs.FileInfo) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().Unix(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+func listDirectory(w http.ResponseWriter, r *http.Request, name string, entries []fs.FileInfo) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	title := html.EscapeString(name)
+	fmt.Fprintf(w, "<!doctype html>\n<title>Index of %s</title>\n<h1>Index of %s</h1>\n<pre>\n", title, title)
+	if name != "/" {
+		fmt.Fprintln(w, `<a href="../">../</a>`)
+	}
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	fmt.Fprintln(w, "</pre>")
+}
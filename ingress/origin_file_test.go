@@ -0,0 +1,96 @@
+package ingress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+func TestNewFileService(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are POSIX ones")
+	}
+	service, err := newFileService("file:///var/www/site/")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/www/site", service.root)
+
+	service, err = newFileService("file://localhost/var/www")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/www", service.root)
+
+	for _, invalid := range []string{"file://var/www", "file:relative/path"} {
+		_, err := newFileService(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFileServiceServeHTTP(t *testing.T) {
+	root := t.TempDir()
+	writeFile := func(name, content string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+	writeFile("index.html", "<h1>home</h1>")
+	writeFile("style.css", "body{}")
+	writeFile("docs/guide.txt", "0123456789")
+	writeFile(".git/config", "secret")
+
+	service, err := newFileService("file://" + filepath.ToSlash(root))
+	require.NoError(t, err)
+	require.NoError(t, service.start(testLogger, nil, OriginRequestConfig{}))
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, req)
+		return w
+	}
+
+	resp := serve(http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "<h1>home</h1>", resp.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"))
+
+	resp = serve(http.MethodGet, "/style.css", nil)
+	assert.Equal(t, "text/css; charset=utf-8", resp.Header().Get("Content-Type"))
+	etag := resp.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	resp = serve(http.MethodGet, "/style.css", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+
+	resp = serve(http.MethodGet, "/docs/guide.txt", http.Header{"Range": {"bytes=2-4"}})
+	assert.Equal(t, http.StatusPartialContent, resp.Code)
+	assert.Equal(t, "234", resp.Body.String())
+
+	resp = serve(http.MethodGet, "/docs", nil)
+	assert.Equal(t, http.StatusMovedPermanently, resp.Code)
+	assert.Equal(t, "/docs/", resp.Header().Get("Location"))
+
+	// Directories without an index file aren't listed by default
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/docs/", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/.git/config", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/../../etc/passwd", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/missing.html", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodPost, "/index.html", nil).Code)
+
+	require.NoError(t, service.start(testLogger, nil, OriginRequestConfig{
+		FileServer: &config.FileServerConfig{DirectoryListing: true, IndexFiles: []string{"home.html"}},
+	}))
+	resp = serve(http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `<a href="docs/">docs/</a>`)
+	assert.Contains(t, resp.Body.String(), `<a href="index.html">index.html</a>`)
+	assert.NotContains(t, resp.Body.String(), ".git")
+}
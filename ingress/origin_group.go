@@ -0,0 +1,180 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+// originFailTimeout is how long an origin of a group doesn't get requests after cloudflared failed to connect to it.
+const originFailTimeout = 10 * time.Second
+
+// originGroup is an HTTP origin service made of several origins, when the service of a rule is a list of origins.
+// Requests are balanced over the origins by weight, and fail over to the backup origins when none of the other
+// origins is healthy. An origin is unhealthy for originFailTimeout after cloudflared failed to connect to it, in which
+// case requests without a body are retried against another origin, and while it fails its health checks.
+type originGroup struct {
+	origins []config.WeightedOrigin
+	// members are the services of the origins, in the same order
+	members  []*httpService
+	services map[string]*httpService
+	primary  originPool
+	backup   originPool
+	log      *zerolog.Logger
+}
+
+func newOriginGroup(origins []config.WeightedOrigin) (*originGroup, error) {
+	group := &originGroup{
+		origins:  origins,
+		services: make(map[string]*httpService, len(origins)),
+	}
+	var primary, backup []weightedAddress
+	for _, origin := range origins {
+		u, err := url.Parse(origin.URL)
+		if err != nil {
+			return nil, err
+		}
+		if !isHTTPService(u) || u.Hostname() == "" {
+			return nil, fmt.Errorf("%s is an invalid origin, it should be an HTTP URL like http://localhost:8080", origin.URL)
+		}
+		if u.Path != "" {
+			return nil, fmt.Errorf("%s is an invalid origin, ingress rules don't support proxying to a different path on the origin service", origin.URL)
+		}
+		address := u.String()
+		if _, ok := group.services[address]; ok {
+			return nil, fmt.Errorf("origin %s is listed more than once", origin.URL)
+		}
+		member := &httpService{url: u}
+		group.members = append(group.members, member)
+		group.services[address] = member
+
+		weight := origin.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		if origin.Backup {
+			backup = append(backup, weightedAddress{address: address, weight: weight})
+		} else {
+			primary = append(primary, weightedAddress{address: address, weight: weight})
+		}
+	}
+	if len(primary) == 0 {
+		return nil, errors.New("a list of origins needs at least one origin that isn't a backup")
+	}
+	group.primary.set(primary)
+	group.backup.set(backup)
+	return group, nil
+}
+
+// Origins returns the origins of the rule when its service is a list of origins.
+func (r *Rule) Origins() []config.WeightedOrigin {
+	if group, ok := r.Service.(*originGroup); ok {
+		return group.origins
+	}
+	return nil
+}
+
+func (o *originGroup) String() string {
+	addresses := make([]string, len(o.origins))
+	for i, origin := range o.origins {
+		addresses[i] = origin.URL
+	}
+	return strings.Join(addresses, ", ")
+}
+
+func (o *originGroup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.origins)
+}
+
+func (o *originGroup) start(log *zerolog.Logger, shutdownC <-chan struct{}, cfg OriginRequestConfig) error {
+	o.log = log
+	for _, service := range o.services {
+		if err := service.start(log, shutdownC, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *originGroup) warmup() error {
+	for _, service := range o.services {
+		if err := service.warmup(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probe succeeds if any origin of the group accepts connections, since the group fails over to it.
+func (o *originGroup) probe(timeout time.Duration) error {
+	var err error
+	for _, service := range o.services {
+		if err = service.probe(timeout); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// pick returns the next healthy origin, a backup one if no other origin is healthy, and otherwise the next origin in
+// case it recovered.
+func (o *originGroup) pick() *httpService {
+	address, ok := o.primary.pick()
+	if !ok {
+		address, ok = o.backup.pick()
+	}
+	if !ok {
+		address, _ = o.primary.pickAny()
+	}
+	return o.services[address]
+}
+
+func (o *originGroup) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests without a body can be sent again, but the services rewrite them
+	canRetry := IsBodiless(req)
+	original := req
+	if canRetry {
+		req.Body = http.NoBody
+		original = req.Clone(req.Context())
+	}
+	for attempt := 1; ; attempt++ {
+		service := o.pick()
+		resp, err := service.RoundTrip(req)
+		if err == nil || !isDialError(err) {
+			return resp, err
+		}
+		o.primary.markDown(service.String(), time.Now().Add(originFailTimeout))
+		o.backup.markDown(service.String(), time.Now().Add(originFailTimeout))
+		o.log.Warn().Err(err).Str("origin", service.String()).Msgf("Failed to connect to the origin, it won't get requests for %s", originFailTimeout)
+		if !canRetry || attempt >= len(o.services) {
+			return nil, err
+		}
+		req = original.Clone(original.Context())
+	}
+}
+
+// IsBodiless tells whether req has no body. The requests received over HTTP/2 always have a Body, even when their
+// Content-Length is 0 and they aren't chunked, and their Body can then be replaced with http.NoBody.
+func IsBodiless(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	// The requests received over QUIC only announce chunked bodies in their header
+	return req.ContentLength == 0 && len(req.TransferEncoding) == 0 && req.Header.Get("Transfer-Encoding") == ""
+}
+
+// isDialError tells whether the request failed because the origin couldn't be connected to, in which case the
+// origin didn't get the request.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,156 @@
+package ingress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNamedOrigin(t *testing.T, name string) *httptest.Server {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name))
+	}))
+	t.Cleanup(origin.Close)
+	return origin
+}
+
+func roundTripOriginName(t *testing.T, service OriginService, body io.Reader) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, "http://app.example.com/", body)
+	require.NoError(t, err)
+	resp, err := service.(HTTPOriginProxy).RoundTrip(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	name, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(name), nil
+}
+
+func TestOriginGroup(t *testing.T) {
+	heavy := newNamedOrigin(t, "heavy")
+	light := newNamedOrigin(t, "light")
+	backup := newNamedOrigin(t, "backup")
+	down := newNamedOrigin(t, "down")
+	down.Close()
+
+	ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+ingress:
+  - hostname: app.example.com
+    service:
+      - url: %s
+        weight: 3
+      - url: %s
+      - url: %s
+        backup: true
+  - hostname: down.example.com
+    service:
+      - url: %s
+      - url: %s
+        backup: true
+  - service: http_status:404
+`, heavy.URL, light.URL, backup.URL, down.URL, backup.URL)))
+	require.NoError(t, err)
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+
+	// Requests are balanced by weight, the backup origin doesn't get any
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		name, err := roundTripOriginName(t, ing.Rules[0].Service, nil)
+		require.NoError(t, err)
+		counts[name]++
+	}
+	assert.Equal(t, map[string]int{"heavy": 6, "light": 2}, counts)
+
+	// A request with a body isn't retried, but the origin that failed is skipped afterwards
+	_, err = roundTripOriginName(t, ing.Rules[1].Service, strings.NewReader("body"))
+	require.Error(t, err)
+	name, err := roundTripOriginName(t, ing.Rules[1].Service, strings.NewReader("body"))
+	require.NoError(t, err)
+	assert.Equal(t, "backup", name)
+}
+
+func TestOriginGroupRetriesRequestsWithoutBody(t *testing.T) {
+	up := newNamedOrigin(t, "up")
+	down := newNamedOrigin(t, "down")
+	down.Close()
+
+	tests := []struct {
+		name string
+		body func() io.Reader
+	}{
+		{name: "nil body", body: func() io.Reader { return nil }},
+		// Like the requests received over HTTP/2, these have a Body with a Content-Length of 0
+		{name: "empty body", body: func() io.Reader { return io.NopCloser(strings.NewReader("")) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+ingress:
+  - service:
+      - url: %s
+      - url: %s
+`, down.URL, up.URL)))
+			require.NoError(t, err)
+			shutdownC := make(chan struct{})
+			t.Cleanup(func() { close(shutdownC) })
+			require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+
+			for i := 0; i < 4; i++ {
+				name, err := roundTripOriginName(t, ing.Rules[0].Service, test.body())
+				require.NoError(t, err)
+				assert.Equal(t, "up", name)
+			}
+		})
+	}
+}
+
+func TestGRPCRuleOriginGroup(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+  - hostname: app.example.com
+    service:
+      - url: http://localhost:8080
+      - url: http://localhost:8081
+  - hostname: grpc.example.com
+    service:
+      - url: http://localhost:8080
+      - url: grpc://localhost:50051
+        backup: true
+  - service: http_status:404
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, ing.GRPCRule())
+
+	ing, err = ParseIngress(MustReadIngress(`
+ingress:
+  - service:
+      - url: http://localhost:8080
+      - url: http://localhost:8081
+`))
+	require.NoError(t, err)
+	assert.Equal(t, -1, ing.GRPCRule())
+}
+
+func TestParseOriginGroupErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"[{url: 'tcp://localhost:22'}]",
+		"[{url: 'http://localhost:8080/path'}]",
+		"[{url: 'http://localhost:8080'}, {url: 'http://localhost:8080'}]",
+		"[{url: 'http://localhost:8080', backup: true}]",
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: ` + invalid + `
+`))
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,169 @@
+package ingress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// weightedAddress is an address of an origin, with the share of the requests it should get relative to the other
+// addresses of the pool.
+type weightedAddress struct {
+	address string
+	weight  uint16
+}
+
+// equallyWeighted gives the same weight to all the addresses.
+func equallyWeighted(addresses []string) []weightedAddress {
+	weighted := make([]weightedAddress, len(addresses))
+	for i, address := range addresses {
+		weighted[i] = weightedAddress{address: address, weight: 1}
+	}
+	return weighted
+}
+
+type poolMember struct {
+	weightedAddress
+	// current is the smooth weighted round-robin counter of the member
+	current int
+	// downUntil is when the member can be picked again after it failed
+	downUntil time.Time
+	// unhealthy members failed their last health check
+	unhealthy bool
+}
+
+// originPool is the set of addresses an origin service balances its requests over. Its members can change while
+// requests are proxied, e.g. when they're discovered from a service registry.
+type originPool struct {
+	lock    sync.Mutex
+	members []poolMember
+	// weighted is false when all members have the same weight, and are picked in plain round-robin order
+	weighted bool
+	next     int
+}
+
+// set replaces the members of the pool, and returns true if they changed. An address listed more than once keeps its
+// first weight.
+func (p *originPool) set(addresses []weightedAddress) bool {
+	addresses = append([]weightedAddress(nil), addresses...)
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return addresses[i].address < addresses[j].address
+	})
+	members := make([]poolMember, 0, len(addresses))
+	weighted := false
+	for i, address := range addresses {
+		if i > 0 && address.address == addresses[i-1].address {
+			continue
+		}
+		if len(members) > 0 && address.weight != members[0].weight {
+			weighted = true
+		}
+		members = append(members, poolMember{weightedAddress: address})
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if equalMembers(p.members, members) {
+		return false
+	}
+	p.members = members
+	p.weighted = weighted
+	return true
+}
+
+// pick returns the next member of the pool that isn't down, or false if there is none. Members with the same weight
+// are picked in round-robin order, otherwise in smooth weighted round-robin order, so that heavier members don't get
+// their requests in bursts. Members with a weight of 0 are only picked if all members have a weight of 0.
+func (p *originPool) pick() (string, bool) {
+	return p.pickMember(false)
+}
+
+// pickAny is like pick, but also picks the members that are down.
+func (p *originPool) pickAny() (string, bool) {
+	return p.pickMember(true)
+}
+
+func (p *originPool) pickMember(includeDown bool) (string, bool) {
+	now := time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	isUp := func(member *poolMember) bool {
+		return includeDown || (!member.unhealthy && !now.Before(member.downUntil))
+	}
+	if !p.weighted {
+		for range p.members {
+			member := &p.members[p.next%len(p.members)]
+			p.next = (p.next + 1) % len(p.members)
+			if isUp(member) {
+				return member.address, true
+			}
+		}
+		return "", false
+	}
+	total := 0
+	best := -1
+	for i := range p.members {
+		if !isUp(&p.members[i]) {
+			continue
+		}
+		p.members[i].current += int(p.members[i].weight)
+		total += int(p.members[i].weight)
+		if best < 0 || p.members[i].current > p.members[best].current {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	p.members[best].current -= total
+	return p.members[best].address, true
+}
+
+// markDown stops picking the member with the given address until the given time, and returns false if there is none.
+func (p *originPool) markDown(address string, until time.Time) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i := range p.members {
+		if p.members[i].address == address {
+			p.members[i].downUntil = until
+			return true
+		}
+	}
+	return false
+}
+
+// setHealthy records the result of the last health check of the member with the given address, and returns false if
+// there is none. Unhealthy members aren't picked until they're healthy again.
+func (p *originPool) setHealthy(address string, healthy bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i := range p.members {
+		if p.members[i].address == address {
+			p.members[i].unhealthy = !healthy
+			return true
+		}
+	}
+	return false
+}
+
+func (p *originPool) list() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	addresses := make([]string, len(p.members))
+	for i, member := range p.members {
+		addresses[i] = member.address
+	}
+	return addresses
+}
+
+func equalMembers(a, b []poolMember) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].weightedAddress != b[i].weightedAddress {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package ingress
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/errgroup"
+)
+
+// prober is implemented by origin services that cloudflared connects to, as opposed to the ones it serves itself.
+type prober interface {
+	// probe checks that the origin accepts connections.
+	probe(timeout time.Duration) error
+}
+
+// ProbeOrigins checks that every origin service accepts connections, waiting at most the connectTimeout of its rule.
+// It only opens and closes a connection, so origins don't see a request. The origins are probed concurrently, so it
+// takes as long as the slowest one.
+func (ing Ingress) ProbeOrigins() error {
+	var probes errgroup.Group
+	for _, rule := range ing.Rules {
+		services := []OriginService{rule.Service}
+		if rule.Mirror != nil {
+			services = append(services, rule.Mirror.Service)
+		}
+		for _, f := range rule.Filters {
+			if f.Service != nil {
+				services = append(services, f.Service)
+			}
+		}
+		timeout := rule.Config.ConnectTimeout.Duration
+		for _, service := range services {
+			if p, ok := service.(prober); ok {
+				service := service
+				probes.Go(func() error {
+					return errors.Wrapf(p.probe(timeout), "Origin service %s is unreachable", service)
+				})
+			}
+		}
+	}
+	return probes.Wait()
+}
+
+func (o *httpService) probe(timeout time.Duration) error {
+	port := o.url.Port()
+	if port == "" {
+		switch o.url.Scheme {
+		case "https", "wss", "grpcs":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	address := net.JoinHostPort(o.url.Hostname(), port)
+	if isGRPCService(o.url) {
+		return probeHTTP2(address, o.url.Scheme == "grpcs", o.url.Hostname(), timeout)
+	}
+	return probeAddress("tcp", address, timeout)
+}
+
+// probeHTTP2 checks that a gRPC origin speaks HTTP/2, by sending the connection preface and waiting for the SETTINGS
+// frame that starts the origin's side of the connection. The certificate of TLS origins is checked by the requests,
+// the probe only checks that they negotiate h2.
+func probeHTTP2(address string, useTLS bool, serverName string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if useTLS {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			NextProtos:         []string{http2.NextProtoTLS},
+			InsecureSkipVerify: true,
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		if protocol := tlsConn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+			return fmt.Errorf("gRPC origin doesn't support HTTP/2, it negotiated %q", protocol)
+		}
+		conn = tlsConn
+	}
+	if _, err := io.WriteString(conn, http2.ClientPreface); err != nil {
+		return err
+	}
+	framer := http2.NewFramer(conn, conn)
+	if err := framer.WriteSettings(); err != nil {
+		return err
+	}
+	frame, err := framer.ReadFrame()
+	if err != nil {
+		return errors.Wrap(err, "gRPC origin doesn't speak HTTP/2")
+	}
+	if _, ok := frame.(*http2.SettingsFrame); !ok {
+		return fmt.Errorf("gRPC origin doesn't speak HTTP/2, it started with a %s frame", frame.Header().Type)
+	}
+	return nil
+}
+
+func (o *unixSocketPath) probe(timeout time.Duration) error {
+	return probeAddress("unix", o.path, timeout)
+}
+
+func (o *tcpOverWSService) probe(timeout time.Duration) error {
+	// The bastion's destination is chosen by each client
+	if o.isBastion {
+		return nil
+	}
+	return probeAddress("tcp", o.dest, timeout)
+}
+
+func probeAddress(network, address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
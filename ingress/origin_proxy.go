@@ -32,9 +32,9 @@ func (o *httpService) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Rewrite the request URL so that it goes to the origin service.
 	req.URL.Host = o.url.Host
 	switch o.url.Scheme {
-	case "ws":
+	case "ws", "grpc":
 		req.URL.Scheme = "http"
-	case "wss":
+	case "wss", "grpcs":
 		req.URL.Scheme = "https"
 	default:
 		req.URL.Scheme = o.url.Scheme
@@ -44,7 +44,7 @@ func (o *httpService) RoundTrip(req *http.Request) (*http.Response, error) {
 		// For incoming requests, the Host header is promoted to the Request.Host field and removed from the Header map.
 		// Pass the original Host header as X-Forwarded-Host.
 		req.Header.Set("X-Forwarded-Host", req.Host)
-		req.Host = o.hostHeader
+		req.Host = expandHostHeader(o.hostHeader, o.ruleHostname, req.Host)
 	}
 	return o.transport.RoundTrip(req)
 }
@@ -80,7 +80,7 @@ func (o *tcpOverWSService) EstablishConnection(ctx context.Context, dest string)
 		dest = o.dest
 	}
 
-	conn, err := o.dialer.DialContext(ctx, "tcp", dest)
+	conn, err := o.socketOptions.WrapDial(o.dialer.DialContext)(ctx, "tcp", dest)
 	if err != nil {
 		return nil, err
 	}
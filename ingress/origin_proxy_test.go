@@ -1,6 +1,7 @@
 package ingress
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
 
 	"github.com/cloudflare/cloudflared/carrier"
 	"github.com/cloudflare/cloudflared/websocket"
@@ -199,3 +201,167 @@ func tcpListenRoutine(listener net.Listener, closeChan chan struct{}) {
 		}
 	}()
 }
+
+func TestHTTPServiceH2COrigin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			for k, v := range websocket.NewResponseHeader(r) {
+				w.Header().Set(k, v[0])
+			}
+			w.Header().Set("X-Proto", r.Proto)
+			w.WriteHeader(http.StatusSwitchingProtocols)
+			return
+		}
+		_, _ = w.Write([]byte(r.Proto))
+	})
+	// The origin speaks HTTP/2 to clients sending its preface, and HTTP/1.1 to the others
+	http1Listener := &connListener{Listener: listener, conns: make(chan net.Conn)}
+	go func() { _ = http.Serve(http1Listener, handler) }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(http1Listener.conns)
+				return
+			}
+			reader := bufio.NewReader(conn)
+			go func() {
+				conn := &peekedConn{Conn: conn, reader: reader}
+				if preface, err := reader.Peek(len(http2.ClientPreface)); err == nil && string(preface) == http2.ClientPreface {
+					(&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+					return
+				}
+				http1Listener.conns <- conn
+			}()
+		}
+	}()
+
+	originURL := &url.URL{Scheme: "http", Host: listener.Addr().String()}
+	roundTrip := func(cfg OriginRequestConfig, req *http.Request) *http.Response {
+		httpService := &httpService{
+			url: originURL,
+		}
+		require.NoError(t, httpService.start(testLogger, make(chan struct{}), cfg))
+		resp, err := httpService.RoundTrip(req)
+		require.NoError(t, err)
+		return resp
+	}
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, originURL.String(), nil)
+		require.NoError(t, err)
+		return req
+	}
+	readBody := func(resp *http.Response) string {
+		respBody, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(respBody)
+	}
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, "HTTP/2.0", readBody(roundTrip(OriginRequestConfig{H2COrigin: true}, newRequest())))
+	}
+
+	// h2c is opt-in, http2Origin only negotiates HTTP/2 with TLS origins
+	require.Equal(t, "HTTP/1.1", readBody(roundTrip(OriginRequestConfig{Http2Origin: true}, newRequest())))
+
+	// HTTP/2 transports can't upgrade requests to websockets
+	req := newRequest()
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-Websocket-Version", "13")
+	req.Header.Set("Sec-Websocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	resp := roundTrip(OriginRequestConfig{H2COrigin: true}, req)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "HTTP/1.1", resp.Header.Get("X-Proto"))
+}
+
+// connListener accepts the connections sent to conns.
+type connListener struct {
+	net.Listener
+	conns chan net.Conn
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	conn, ok := <-l.conns
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return conn, nil
+}
+
+// peekedConn reads the bytes that were peeked from the connection before the rest.
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func TestGRPCService(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write([]byte(r.Proto))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		server := &http2.Server{}
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	tlsOrigin := httptest.NewUnstartedServer(handler)
+	tlsOrigin.EnableHTTP2 = true
+	tlsOrigin.StartTLS()
+	defer tlsOrigin.Close()
+	http1Origin := httptest.NewTLSServer(handler)
+	defer http1Origin.Close()
+	tlsAddress := tlsOrigin.Listener.Addr().String()
+
+	ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+originRequest:
+  noTLSVerify: true
+ingress:
+  - hostname: cleartext.example.com
+    service: grpc://%s
+  - hostname: tls.example.com
+    service: grpcs://%s
+  - service: grpcs://%s
+`, listener.Addr(), tlsAddress, http1Origin.Listener.Addr())))
+	require.NoError(t, err)
+	require.NoError(t, ing.StartOrigins(testLogger, make(chan struct{})))
+
+	for _, rule := range ing.Rules[:2] {
+		req, err := http.NewRequest(http.MethodPost, "http://"+rule.Hostname+"/service/Method", nil)
+		require.NoError(t, err)
+		resp, err := rule.Service.(HTTPOriginProxy).RoundTrip(req)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", string(body))
+		assert.Equal(t, "0", resp.Trailer.Get("Grpc-Status"))
+	}
+
+	// Origins that don't speak HTTP/2 are rejected rather than spoken to over HTTP/1.1
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/service/Method", nil)
+	require.NoError(t, err)
+	_, err = ing.Rules[2].Service.(HTTPOriginProxy).RoundTrip(req)
+	assert.Error(t, err)
+
+	require.Error(t, ing.ProbeOrigins())
+	ing.Rules = ing.Rules[:2]
+	require.NoError(t, ing.ProbeOrigins())
+}
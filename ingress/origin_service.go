@@ -10,16 +10,21 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
+	"golang.org/x/net/http2"
 
 	"github.com/cloudflare/cloudflared/hello"
 	"github.com/cloudflare/cloudflared/ipaccess"
 	"github.com/cloudflare/cloudflared/management"
+	"github.com/cloudflare/cloudflared/sockopt"
 	"github.com/cloudflare/cloudflared/socks"
 	"github.com/cloudflare/cloudflared/tlsconfig"
+	"github.com/cloudflare/cloudflared/websocket"
 )
 
 const (
@@ -39,11 +44,75 @@ type OriginService interface {
 	MarshalJSON() ([]byte, error)
 }
 
+// warmer is implemented by origin services that defer preparing their connections until their first request.
+type warmer interface {
+	// warmup prepares the connections to the origin now, reporting any error in its configuration.
+	warmup() error
+}
+
+// lazyTransport builds the transport to an origin on first use, so that starting a connector with many rules doesn't
+// wait on loading certificate pools for origins that may never receive a request.
+type lazyTransport struct {
+	// Underlying value is http.RoundTripper
+	transport atomic.Value
+	lock      sync.Mutex
+	build     func() (http.RoundTripper, error)
+}
+
+func newLazyTransport(service OriginService, cfg OriginRequestConfig, log *zerolog.Logger) *lazyTransport {
+	return &lazyTransport{
+		build: func() (http.RoundTripper, error) {
+			transport, err := newHTTPTransport(service, cfg, log)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := service.(*httpService); ok && isGRPCService(s.url) {
+				return newGRPCTransport(transport, isCleartextOrigin(service), cfg)
+			}
+			if cfg.H2COrigin && isCleartextOrigin(service) {
+				h2cTransport, err := newH2CTransport(transport, cfg)
+				if err != nil {
+					return nil, err
+				}
+				return &websocketHTTP1Transport{RoundTripper: h2cTransport, http1: transport}, nil
+			}
+			return transport, nil
+		},
+	}
+}
+
+// get returns the transport, building it if this is its first use. Failures aren't cached, so fixing the origin's
+// configuration on disk, like a missing CA pool, takes effect on the next request.
+func (t *lazyTransport) get() (http.RoundTripper, error) {
+	if transport, ok := t.transport.Load().(http.RoundTripper); ok {
+		return transport, nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if transport, ok := t.transport.Load().(http.RoundTripper); ok {
+		return transport, nil
+	}
+	transport, err := t.build()
+	if err != nil {
+		return nil, err
+	}
+	t.transport.Store(transport)
+	return transport, nil
+}
+
+func (t *lazyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
 // unixSocketPath is an OriginService representing a unix socket (which accepts HTTP or HTTPS)
 type unixSocketPath struct {
 	path      string
 	scheme    string
-	transport *http.Transport
+	transport *lazyTransport
 }
 
 func (o *unixSocketPath) String() string {
@@ -55,14 +124,15 @@ func (o *unixSocketPath) String() string {
 }
 
 func (o *unixSocketPath) start(log *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
-	transport, err := newHTTPTransport(o, cfg, log)
-	if err != nil {
-		return err
-	}
-	o.transport = transport
+	o.transport = newLazyTransport(o, cfg, log)
 	return nil
 }
 
+func (o *unixSocketPath) warmup() error {
+	_, err := o.transport.get()
+	return err
+}
+
 func (o unixSocketPath) MarshalJSON() ([]byte, error) {
 	return json.Marshal(o.String())
 }
@@ -70,19 +140,22 @@ func (o unixSocketPath) MarshalJSON() ([]byte, error) {
 type httpService struct {
 	url        *url.URL
 	hostHeader string
-	transport  *http.Transport
+	// ruleHostname is the hostname of the rule, which httpHostHeader templates can refer to
+	ruleHostname string
+	transport    *lazyTransport
 }
 
 func (o *httpService) start(log *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
-	transport, err := newHTTPTransport(o, cfg, log)
-	if err != nil {
-		return err
-	}
 	o.hostHeader = cfg.HTTPHostHeader
-	o.transport = transport
+	o.transport = newLazyTransport(o, cfg, log)
 	return nil
 }
 
+func (o *httpService) warmup() error {
+	_, err := o.transport.get()
+	return err
+}
+
 func (o *httpService) String() string {
 	return o.url.String()
 }
@@ -118,6 +191,7 @@ type tcpOverWSService struct {
 	isBastion     bool
 	streamHandler streamHandlerFunc
 	dialer        net.Dialer
+	socketOptions sockopt.Options
 }
 
 type socksProxyOverWSService struct {
@@ -185,6 +259,8 @@ func (o *tcpOverWSService) start(log *zerolog.Logger, _ <-chan struct{}, cfg Ori
 	}
 	o.dialer.Timeout = cfg.ConnectTimeout.Duration
 	o.dialer.KeepAlive = cfg.TCPKeepAlive.Duration
+	o.socketOptions = cfg.socketOptions()
+	o.socketOptions.Dialer(&o.dialer)
 	return nil
 }
 
@@ -338,6 +414,9 @@ func (nrc *NopReadCloser) Close() error {
 	return nil
 }
 
+// originRevocationChecker is shared by all origins, so that CRLs are fetched once.
+var originRevocationChecker = tlsconfig.NewRevocationChecker()
+
 func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerolog.Logger) (*http.Transport, error) {
 	originCertPool, err := tlsconfig.LoadOriginCA(cfg.CAPool, log)
 	if err != nil {
@@ -357,6 +436,14 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 	if _, isHelloWorld := service.(*helloWorld); !isHelloWorld && cfg.OriginServerName != "" {
 		httpTransport.TLSClientConfig.ServerName = cfg.OriginServerName
 	}
+	tlsPolicy, err := cfg.tlsPolicy()
+	if err != nil {
+		return nil, err
+	}
+	tlsPolicy.Apply(httpTransport.TLSClientConfig)
+	if cfg.TLS != nil && cfg.TLS.CheckRevocation {
+		httpTransport.TLSClientConfig.VerifyConnection = originRevocationChecker.VerifyConnection
+	}
 
 	dialer := &net.Dialer{
 		Timeout:   cfg.ConnectTimeout.Duration,
@@ -365,9 +452,11 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 	if cfg.NoHappyEyeballs {
 		dialer.FallbackDelay = -1 // As of Golang 1.12, a negative delay disables "happy eyeballs"
 	}
+	socketOptions := cfg.socketOptions()
+	socketOptions.Dialer(dialer)
 
 	// DialContext depends on which kind of origin is being used.
-	dialContext := dialer.DialContext
+	dialContext := socketOptions.WrapDial(dialer.DialContext)
 	switch service := service.(type) {
 
 	// If this origin is a unix socket, enforce network type "unix".
@@ -384,6 +473,94 @@ func newHTTPTransport(service OriginService, cfg OriginRequestConfig, log *zerol
 	return &httpTransport, nil
 }
 
+// isCleartextOrigin tells whether the origin is reached without TLS, in which case HTTP/2 can't be negotiated with ALPN.
+func isCleartextOrigin(service OriginService) bool {
+	switch service := service.(type) {
+	case *httpService:
+		return service.url.Scheme == "http" || service.url.Scheme == "grpc"
+	case *unixSocketPath:
+		return service.scheme != "https"
+	default:
+		return false
+	}
+}
+
+// newHTTP2Transport returns an HTTP/2 transport that keeps the idle connection, response header and compression
+// settings of transport, and pings idle connections to the origin like TCP keepalives would.
+func newHTTP2Transport(transport *http.Transport, cfg OriginRequestConfig) (*http2.Transport, error) {
+	// The HTTP/2 transport reads these settings from the HTTP/1.1 transport it's configured on
+	http2Transport, err := http2.ConfigureTransports(transport.Clone())
+	if err != nil {
+		return nil, err
+	}
+	// ConfigureTransports only lets the HTTP/1.1 transport dial new connections, and this one is used on its own
+	http2Transport.ConnPool = nil
+	http2Transport.ReadIdleTimeout = cfg.TCPKeepAlive.Duration
+	return http2Transport, nil
+}
+
+// newH2CTransport speaks HTTP/2 to a cleartext origin with prior knowledge (h2c), dialing it like transport does. The
+// requests to the origin are multiplexed over the pooled connections.
+func newH2CTransport(transport *http.Transport, cfg OriginRequestConfig) (*http2.Transport, error) {
+	h2cTransport, err := newHTTP2Transport(transport, cfg)
+	if err != nil {
+		return nil, err
+	}
+	h2cTransport.AllowHTTP = true
+	h2cTransport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		return transport.DialContext(ctx, network, addr)
+	}
+	return h2cTransport, nil
+}
+
+// websocketHTTP1Transport sends websocket upgrades with HTTP/1.1, since they can't go through HTTP/2 transports.
+type websocketHTTP1Transport struct {
+	http.RoundTripper
+	http1 *http.Transport
+}
+
+func (t *websocketHTTP1Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if websocket.IsWebSocketUpgrade(req) {
+		return t.http1.RoundTrip(req)
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// newGRPCTransport speaks HTTP/2 to a gRPC origin, without falling back to HTTP/1.1 like transport does when TLS
+// origins don't negotiate h2: gRPC needs HTTP/2 streams and trailers.
+func newGRPCTransport(transport *http.Transport, cleartext bool, cfg OriginRequestConfig) (*http2.Transport, error) {
+	if cleartext {
+		return newH2CTransport(transport, cfg)
+	}
+	grpcTransport, err := newHTTP2Transport(transport, cfg)
+	if err != nil {
+		return nil, err
+	}
+	grpcTransport.TLSClientConfig = transport.TLSClientConfig
+	grpcTransport.DialTLSContext = func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := transport.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		if transport.TLSHandshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, transport.TLSHandshakeTimeout)
+			defer cancel()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if protocol := tlsConn.ConnectionState().NegotiatedProtocol; protocol != http2.NextProtoTLS {
+			_ = conn.Close()
+			return nil, fmt.Errorf("gRPC origin %s doesn't support HTTP/2, it negotiated %q", addr, protocol)
+		}
+		return tlsConn, nil
+	}
+	return grpcTransport, nil
+}
+
 // MockOriginHTTPService should only be used by other packages to mock OriginService. Set Transport to configure desired RoundTripper behavior.
 type MockOriginHTTPService struct {
 	Transport http.RoundTripper
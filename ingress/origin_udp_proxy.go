@@ -1,9 +1,13 @@
 package ingress
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
+
+	"github.com/rs/zerolog"
 )
 
 type UDPProxy interface {
@@ -11,6 +15,12 @@ type UDPProxy interface {
 	LocalAddr() net.Addr
 }
 
+// UDPOriginProxy can be implemented by origin services that take UDP sessions of their hostname.
+type UDPOriginProxy interface {
+	// DialUDP opens the socket of a new session to the origin.
+	DialUDP() (UDPProxy, error)
+}
+
 type udpProxy struct {
 	*net.UDPConn
 }
@@ -30,3 +40,44 @@ func DialUDP(dstIP net.IP, dstPort uint16) (UDPProxy, error) {
 
 	return &udpProxy{udpConn}, nil
 }
+
+// udpService is a UDP origin, like a game or VoIP server, that the edge sends the datagrams of its hostname to.
+// Each session from an eyeball gets its own socket to the origin, so that the replies can be told apart.
+type udpService struct {
+	dest string
+}
+
+func isUDPService(url *url.URL) bool {
+	return url.Scheme == "udp"
+}
+
+func newUDPService(url *url.URL) (*udpService, error) {
+	// There's no port UDP services commonly listen on
+	if url.Port() == "" {
+		return nil, fmt.Errorf("%s is an invalid address, UDP services need a port", url)
+	}
+	return &udpService{
+		dest: url.Host,
+	}, nil
+}
+
+func (o *udpService) DialUDP() (UDPProxy, error) {
+	// The hostname of the origin is resolved for every session, so that sessions follow it when it moves
+	conn, err := net.Dial("udp", o.dest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create UDP proxy to origin %s: %w", o.dest, err)
+	}
+	return &udpProxy{conn.(*net.UDPConn)}, nil
+}
+
+func (o *udpService) String() string {
+	return fmt.Sprintf("udp://%s", o.dest)
+}
+
+func (o *udpService) start(log *zerolog.Logger, _ <-chan struct{}, cfg OriginRequestConfig) error {
+	return nil
+}
+
+func (o udpService) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
@@ -0,0 +1,110 @@
+package ingress
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	// clientIPHeader is set by the edge to the IP of the eyeball client
+	clientIPHeader = "Cf-Connecting-Ip"
+	// rateLimitSweepInterval is how often the buckets of the clients that are back to their burst are dropped
+	rateLimitSweepInterval = time.Minute
+)
+
+// RateLimit limits the requests of a rule from each client IP with a token bucket, configured with the rateLimit
+// origin request setting. The bucket of a client holds up to Burst tokens and gets RequestsPerSecond tokens every
+// second, and every request takes one. Requests without a Cf-Connecting-Ip header share a bucket.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimit(cfg *config.RateLimitConfig) (*RateLimit, error) {
+	if cfg.RequestsPerSecond <= 0 {
+		return nil, errors.New("rate limit requestsPerSecond must be positive")
+	}
+	if cfg.Burst < 0 {
+		return nil, errors.New("rate limit burst can't be negative")
+	}
+	r := &RateLimit{
+		RequestsPerSecond: cfg.RequestsPerSecond,
+		Burst:             cfg.Burst,
+		buckets:           make(map[string]*tokenBucket),
+	}
+	if r.Burst == 0 {
+		r.Burst = int(math.Ceil(r.RequestsPerSecond))
+	}
+	return r, nil
+}
+
+// Allow takes a token from the bucket of the client of req. When the bucket is empty, the request must be rejected,
+// and Allow returns how long until the bucket has a token again.
+func (r *RateLimit) Allow(req *http.Request) (bool, time.Duration) {
+	return r.allow(req.Header.Get(clientIPHeader), time.Now())
+}
+
+func (r *RateLimit) allow(client string, now time.Time) (bool, time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	bucket, ok := r.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(r.Burst), updated: now}
+		r.buckets[client] = bucket
+	}
+	r.refill(bucket, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / r.RequestsPerSecond * float64(time.Second))
+}
+
+func (r *RateLimit) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens = math.Min(float64(r.Burst), bucket.tokens+elapsed.Seconds()*r.RequestsPerSecond)
+		bucket.updated = now
+	}
+}
+
+// start regularly drops the buckets that are full, which are the same as new ones, until shutdownC is closed, so the
+// clients that stopped making requests don't keep using memory.
+func (r *RateLimit) start(shutdownC <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(rateLimitSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdownC:
+				return
+			case now := <-ticker.C:
+				r.sweep(now)
+			}
+		}
+	}()
+}
+
+func (r *RateLimit) sweep(now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for client, bucket := range r.buckets {
+		r.refill(bucket, now)
+		if bucket.tokens >= float64(r.Burst) {
+			delete(r.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ingress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      rateLimit:
+        requestsPerSecond: 2
+        burst: 3
+`))
+	require.NoError(t, err)
+	rateLimit := ing.Rules[0].RateLimit
+	require.NotNil(t, rateLimit)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		allowed, _ := rateLimit.allow("198.51.100.1", now)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := rateLimit.allow("198.51.100.1", now)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// Other clients have their own bucket
+	allowed, _ = rateLimit.allow("198.51.100.2", now)
+	assert.True(t, allowed)
+
+	// Tokens come back at the configured rate
+	allowed, _ = rateLimit.allow("198.51.100.1", now.Add(500*time.Millisecond))
+	assert.True(t, allowed)
+	allowed, retryAfter = rateLimit.allow("198.51.100.1", now.Add(750*time.Millisecond))
+	assert.False(t, allowed)
+	assert.Equal(t, 250*time.Millisecond, retryAfter)
+
+	// Buckets that are full again are dropped
+	rateLimit.sweep(now.Add(time.Second))
+	assert.Len(t, rateLimit.buckets, 1)
+	rateLimit.sweep(now.Add(2 * time.Second))
+	assert.Empty(t, rateLimit.buckets)
+}
+
+func TestRateLimitDefaultBurst(t *testing.T) {
+	ing, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      rateLimit:
+        requestsPerSecond: 0.5
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, ing.Rules[0].RateLimit.Burst)
+}
+
+func TestParseRateLimitErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"{burst: 10}",
+		"{requestsPerSecond: -1}",
+		"{requestsPerSecond: 10, burst: -1}",
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      rateLimit: ` + invalid + `
+`))
+		assert.Error(t, err, invalid)
+	}
+}
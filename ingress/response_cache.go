@@ -0,0 +1,538 @@
+package ingress
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/config"
+)
+
+const (
+	defaultCacheMaxSize       = 64 * 1024 * 1024
+	defaultCacheMaxObjectSize = 1024 * 1024
+
+	cacheHit         = "hit"
+	cacheMiss        = "miss"
+	cacheRevalidated = "revalidated"
+	cacheBypass      = "bypass"
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "cloudflared",
+			Subsystem: "cache",
+			Name:      "requests",
+			Help:      "Count of requests to the rules that cache responses, by whether they were answered from the cache (hit), after revalidating a stale response (revalidated), by the origin (miss), or couldn't be cached (bypass)",
+		},
+		[]string{"result"},
+	)
+	cacheSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "cloudflared",
+			Subsystem: "cache",
+			Name:      "size_bytes",
+			Help:      "Size of the responses in the caches of the rules",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests, cacheSize)
+}
+
+// cacheableStatus are the status codes of the responses that can be cached when they have an explicit freshness
+// lifetime.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// ResponseCache caches the responses of the origin of a rule to GET and HEAD requests, configured with the cache
+// origin request setting. It works like a shared HTTP cache: only the responses that have an explicit freshness
+// lifetime or a validator are cached, and stale responses are revalidated with a conditional request. The least
+// recently used responses are evicted once the cache is over MaxSize.
+type ResponseCache struct {
+	MaxSize       int64
+	MaxObjectSize int64
+	// Directory keeps the cached response bodies in files rather than in memory.
+	Directory string
+
+	// dir is the directory of this cache within Directory, created when the cache starts
+	dir string
+	log *zerolog.Logger
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// lru has the most recently used entries first
+	lru  *list.List
+	size int64
+}
+
+type cacheEntry struct {
+	key    string
+	status int
+	header http.Header
+	// vary has the values of the request headers listed in the Vary header of the response
+	vary map[string]string
+	// body is nil when the body is in file
+	body     []byte
+	file     string
+	bodySize int64
+
+	// storedAt is when the response was received, and initialAge its age at that time
+	storedAt   time.Time
+	initialAge time.Duration
+	lifetime   time.Duration
+}
+
+func newResponseCache(cfg *config.CacheConfig) (*ResponseCache, error) {
+	c := &ResponseCache{
+		MaxSize:       defaultCacheMaxSize,
+		MaxObjectSize: defaultCacheMaxObjectSize,
+		Directory:     cfg.Directory,
+		entries:       make(map[string]*list.Element),
+		lru:           list.New(),
+	}
+	if cfg.MaxSize != 0 {
+		c.MaxSize = cfg.MaxSize
+	}
+	if cfg.MaxObjectSize != 0 {
+		c.MaxObjectSize = cfg.MaxObjectSize
+	}
+	if c.MaxSize < 0 || c.MaxObjectSize < 0 {
+		return nil, errors.New("cache sizes must be positive")
+	}
+	if c.MaxObjectSize > c.MaxSize {
+		return nil, fmt.Errorf("cache maxObjectSize %d is larger than its maxSize %d", c.MaxObjectSize, c.MaxSize)
+	}
+	return c, nil
+}
+
+// start creates the directory of the cache if the bodies are kept on disk, which is removed with the cached responses
+// when shutdownC is closed.
+func (c *ResponseCache) start(log *zerolog.Logger, shutdownC <-chan struct{}) error {
+	c.log = log
+	if c.Directory != "" {
+		if err := os.MkdirAll(c.Directory, 0o700); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp(c.Directory, "cloudflared-cache-")
+		if err != nil {
+			return err
+		}
+		c.dir = dir
+	}
+	go func() {
+		<-shutdownC
+		c.clear()
+	}()
+	return nil
+}
+
+func (c *ResponseCache) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	cacheSize.Sub(float64(c.size))
+	c.size = 0
+	if c.dir != "" {
+		if err := os.RemoveAll(c.dir); err != nil && c.log != nil {
+			c.log.Err(err).Msgf("Failed to remove the cache directory %s", c.dir)
+		}
+	}
+}
+
+// Wrap returns an origin that answers the requests from the cache when it can, and otherwise from origin.
+func (c *ResponseCache) Wrap(origin HTTPOriginProxy) HTTPOriginProxy {
+	return &cachingOrigin{cache: c, origin: origin}
+}
+
+type cachingOrigin struct {
+	cache  *ResponseCache
+	origin HTTPOriginProxy
+}
+
+func (o *cachingOrigin) RoundTrip(req *http.Request) (*http.Response, error) {
+	return o.cache.roundTrip(o.origin, req)
+}
+
+func (c *ResponseCache) roundTrip(origin http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if !isCacheableRequest(req) {
+		cacheRequests.WithLabelValues(cacheBypass).Inc()
+		return origin.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	entry := c.lookup(key, req)
+	if entry != nil && entry.age(time.Now()) < entry.lifetime {
+		if resp, ok := c.serve(entry, req); ok {
+			cacheRequests.WithLabelValues(cacheHit).Inc()
+			return resp, nil
+		}
+		entry = nil
+	}
+
+	originReq := req
+	if entry != nil {
+		etag, lastModified := entry.header.Get("ETag"), entry.header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			originReq = req.Clone(req.Context())
+			originReq.Header.Del("If-None-Match")
+			originReq.Header.Del("If-Modified-Since")
+			if etag != "" {
+				originReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				originReq.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+	resp, err := origin.RoundTrip(originReq)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && originReq != req && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		entry = c.refresh(entry, resp.Header)
+		if resp, ok := c.serve(entry, req); ok {
+			cacheRequests.WithLabelValues(cacheRevalidated).Inc()
+			return resp, nil
+		}
+		// The body of the entry is gone, so ask for the whole response
+		resp, err = origin.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cacheRequests.WithLabelValues(cacheMiss).Inc()
+	if req.Method == http.MethodGet {
+		c.store(key, req, resp)
+	}
+	return resp, nil
+}
+
+// isCacheableRequest tells whether the request can be answered from the cache, and its response cached. Requests with
+// credentials aren't, since their responses are specific to the user, and neither are those where the client asks
+// for a response from the origin.
+func isCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Range") != "" {
+		return false
+	}
+	directives := parseCacheControl(req.Header)
+	if _, ok := directives["no-store"]; ok {
+		return false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	return req.Header.Get("Pragma") != "no-cache"
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Host + req.URL.RequestURI()
+}
+
+func (c *ResponseCache) lookup(key string, req *http.Request) *cacheEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	for name, value := range entry.vary {
+		if strings.Join(req.Header.Values(name), ", ") != value {
+			return nil
+		}
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// serve returns the cached response, or false if its body was evicted in the meantime.
+func (c *ResponseCache) serve(entry *cacheEntry, req *http.Request) (*http.Response, bool) {
+	header := entry.header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(entry.age(time.Now())/time.Second), 10))
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.status, http.StatusText(entry.status)),
+		StatusCode:    entry.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          http.NoBody,
+		ContentLength: entry.bodySize,
+		Request:       req,
+	}
+	if etag := header.Get("ETag"); etag != "" && matchesETag(req.Header.Get("If-None-Match"), etag) {
+		resp.Status = fmt.Sprintf("%d %s", http.StatusNotModified, http.StatusText(http.StatusNotModified))
+		resp.StatusCode = http.StatusNotModified
+		resp.ContentLength = 0
+		header.Del("Content-Length")
+		return resp, true
+	}
+	if req.Method == http.MethodHead {
+		return resp, true
+	}
+	if entry.file == "" {
+		resp.Body = io.NopCloser(bytes.NewReader(entry.body))
+		return resp, true
+	}
+	file, err := os.Open(entry.file)
+	if err != nil {
+		return nil, false
+	}
+	resp.Body = file
+	return resp, true
+}
+
+// matchesETag tells whether the If-None-Match header of a request matches etag, with a weak comparison.
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh updates the cached response with the headers of a 304 response from the origin.
+func (c *ResponseCache) refresh(entry *cacheEntry, header http.Header) *cacheEntry {
+	now := time.Now()
+	refreshed := *entry
+	refreshed.header = entry.header.Clone()
+	for _, name := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified", "Age"} {
+		if values := header.Values(name); len(values) > 0 {
+			refreshed.header[name] = values
+		}
+	}
+	refreshed.lifetime, _ = freshnessLifetime(refreshed.header, now)
+	refreshed.storedAt = now
+	refreshed.initialAge = initialAge(refreshed.header, now)
+	c.put(&refreshed)
+	return &refreshed
+}
+
+// store caches the response once its body has been read completely, if it can be cached.
+func (c *ResponseCache) store(key string, req *http.Request, resp *http.Response) {
+	if !cacheableStatus[resp.StatusCode] || resp.Header.Get("Set-Cookie") != "" {
+		return
+	}
+	if resp.ContentLength > c.MaxObjectSize {
+		return
+	}
+	now := time.Now()
+	lifetime, ok := freshnessLifetime(resp.Header, now)
+	if !ok {
+		return
+	}
+	if lifetime <= 0 && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return
+	}
+	vary := make(map[string]string)
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				vary[name] = strings.Join(req.Header.Values(name), ", ")
+			}
+		}
+	}
+	entry := &cacheEntry{
+		key:        key,
+		status:     resp.StatusCode,
+		header:     resp.Header.Clone(),
+		vary:       vary,
+		storedAt:   now,
+		initialAge: initialAge(resp.Header, now),
+		lifetime:   lifetime,
+	}
+	resp.Body = &cachingBody{ReadCloser: resp.Body, cache: c, entry: entry}
+}
+
+// cachingBody copies the response body as it's read, and caches the response once it's been read completely.
+type cachingBody struct {
+	io.ReadCloser
+	cache *ResponseCache
+	entry *cacheEntry
+	buf   bytes.Buffer
+	// skip is set once the body is too large to be cached
+	skip bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.skip {
+		if int64(b.buf.Len()+n) > b.cache.MaxObjectSize {
+			b.skip = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.skip {
+		b.skip = true
+		b.cache.add(b.entry, b.buf.Bytes())
+	}
+	return n, err
+}
+
+func (c *ResponseCache) add(entry *cacheEntry, body []byte) {
+	entry.bodySize = int64(len(body))
+	entry.header.Del("Content-Length")
+	entry.header.Set("Content-Length", strconv.FormatInt(entry.bodySize, 10))
+	if c.dir == "" {
+		entry.body = body
+	} else {
+		file, err := os.CreateTemp(c.dir, "response-")
+		if err != nil {
+			c.log.Err(err).Msg("Failed to create a file for a cached response")
+			return
+		}
+		_, err = file.Write(body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			c.log.Err(err).Msg("Failed to write a cached response")
+			_ = os.Remove(file.Name())
+			return
+		}
+		entry.file = file.Name()
+	}
+	c.put(entry)
+}
+
+// put replaces the cached response of the key of entry, and evicts the least recently used responses until the cache
+// fits in MaxSize.
+func (c *ResponseCache) put(entry *cacheEntry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element, element.Value.(*cacheEntry).file != entry.file)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size()
+	cacheSize.Add(float64(entry.size()))
+	for c.size > c.MaxSize {
+		c.remove(c.lru.Back(), true)
+	}
+}
+
+// remove must be called with the lock held.
+func (c *ResponseCache) remove(element *list.Element, removeFile bool) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+	cacheSize.Sub(float64(entry.size()))
+	if removeFile && entry.file != "" {
+		_ = os.Remove(entry.file)
+	}
+}
+
+func (e *cacheEntry) size() int64 {
+	size := e.bodySize + int64(len(e.key))
+	for name, values := range e.header {
+		for _, value := range values {
+			size += int64(len(name) + len(value))
+		}
+	}
+	return size
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	return e.initialAge + now.Sub(e.storedAt)
+}
+
+// freshnessLifetime returns how long a response is fresh, or false if it can't be stored by a shared cache.
+func freshnessLifetime(header http.Header, now time.Time) (time.Duration, bool) {
+	directives := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "private"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, true
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 0 {
+				return 0, true
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, true
+		}
+		date := now
+		if parsed, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = parsed
+		}
+		return expiresAt.Sub(date), true
+	}
+	// Only responses with a validator can be cached without an explicit lifetime, and they're revalidated every time
+	return 0, true
+}
+
+// initialAge is the age of a response when it's received, from its Age and Date headers.
+func initialAge(header http.Header, now time.Time) time.Duration {
+	var age time.Duration
+	if date, err := http.ParseTime(header.Get("Date")); err == nil && now.After(date) {
+		age = now.Sub(date)
+	}
+	if seconds, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && time.Duration(seconds)*time.Second > age {
+		age = time.Duration(seconds) * time.Second
+	}
+	return age
+}
+
+// parseCacheControl returns the directives of the Cache-Control header, with their value if they have one.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return directives
+}
@@ -0,0 +1,169 @@
+package ingress
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCachedOrigin returns a cached origin that answers with the number of requests it got, and the headers set by
+// handler.
+func newCachedOrigin(t *testing.T, cache string, handler func(w http.ResponseWriter, r *http.Request)) (HTTPOriginProxy, *atomic.Int32) {
+	var requests atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := requests.Add(1)
+		handler(w, r)
+		if r.Method != http.MethodHead {
+			_, _ = fmt.Fprintf(w, "response %d", count)
+		}
+	}))
+	t.Cleanup(origin.Close)
+
+	ing, err := ParseIngress(MustReadIngress(fmt.Sprintf(`
+ingress:
+  - service: %s
+    originRequest:
+      cache: %s
+`, origin.URL, cache)))
+	require.NoError(t, err)
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(testLogger, shutdownC))
+	rule := ing.Rules[0]
+	require.NotNil(t, rule.Cache)
+	return rule.Cache.Wrap(rule.Service.(HTTPOriginProxy)), &requests
+}
+
+func roundTripCached(t *testing.T, origin HTTPOriginProxy, method, path string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(method, "http://app.example.com"+path, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := origin.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestResponseCache(t *testing.T) {
+	origin, requests := newCachedOrigin(t, "{}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+	})
+
+	_, body := roundTripCached(t, origin, http.MethodGet, "/fresh", nil)
+	assert.Equal(t, "response 1", body)
+	resp, body := roundTripCached(t, origin, http.MethodGet, "/fresh", nil)
+	assert.Equal(t, "response 1", body)
+	assert.Equal(t, "0", resp.Header.Get("Age"))
+	resp, body = roundTripCached(t, origin, http.MethodHead, "/fresh", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "", body)
+	resp, _ = roundTripCached(t, origin, http.MethodGet, "/fresh", http.Header{"If-None-Match": {`"v1"`}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Clients can ask for a response from the origin
+	_, body = roundTripCached(t, origin, http.MethodGet, "/fresh", http.Header{"Cache-Control": {"no-cache"}})
+	assert.Equal(t, "response 2", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/fresh", http.Header{"Authorization": {"Bearer token"}})
+	assert.Equal(t, "response 3", body)
+
+	_, body = roundTripCached(t, origin, http.MethodGet, "/private", nil)
+	assert.Equal(t, "response 4", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/private", nil)
+	assert.Equal(t, "response 5", body)
+
+	english := http.Header{"Accept-Language": {"en"}}
+	_, body = roundTripCached(t, origin, http.MethodGet, "/vary", english)
+	assert.Equal(t, "response 6", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/vary", english)
+	assert.Equal(t, "response 6", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/vary", http.Header{"Accept-Language": {"fr"}})
+	assert.Equal(t, "response 7", body)
+}
+
+func TestResponseCacheRevalidation(t *testing.T) {
+	origin, requests := newCachedOrigin(t, "{}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	})
+
+	_, body := roundTripCached(t, origin, http.MethodGet, "/", nil)
+	assert.Equal(t, "response 1", body)
+	resp, body := roundTripCached(t, origin, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "response 1", body)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	origin, requests := newCachedOrigin(t, fmt.Sprintf("{maxSize: 300, maxObjectSize: 20, directory: %s}", dir), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/large" {
+			_, _ = w.Write([]byte(strings.Repeat("x", 20)))
+		}
+	})
+
+	_, body := roundTripCached(t, origin, http.MethodGet, "/large", nil)
+	assert.Equal(t, strings.Repeat("x", 20)+"response 1", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/large", nil)
+	assert.Equal(t, strings.Repeat("x", 20)+"response 2", body)
+
+	for _, path := range []string{"/a", "/b", "/a", "/c"} {
+		roundTripCached(t, origin, http.MethodGet, path, nil)
+	}
+	// The cache has room for two responses, so /b was evicted when /c was stored
+	assert.Equal(t, int32(5), requests.Load())
+	_, body = roundTripCached(t, origin, http.MethodGet, "/c", nil)
+	assert.Equal(t, "response 5", body)
+	_, body = roundTripCached(t, origin, http.MethodGet, "/b", nil)
+	assert.Equal(t, "response 6", body)
+
+	// The bodies are kept on disk
+	dirs, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+	files, err := os.ReadDir(filepath.Join(dir, dirs[0].Name()))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestParseResponseCacheErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"{maxSize: -1}",
+		"{maxSize: 1024, maxObjectSize: 2048}",
+	} {
+		_, err := ParseIngress(MustReadIngress(`
+ingress:
+  - service: http://localhost:8080
+    originRequest:
+      cache: ` + invalid + `
+`))
+		assert.Error(t, err, invalid)
+	}
+}
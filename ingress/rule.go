@@ -2,6 +2,7 @@ package ingress
 
 import (
 	"encoding/json"
+	"net/http"
 	"regexp"
 	"strings"
 
@@ -20,6 +21,12 @@ type Rule struct {
 	// Path is an optional regex that can specify path-driven ingress rules.
 	Path *Regexp `json:"path"`
 
+	// Methods optionally restricts the rule to requests with one of these methods.
+	Methods []string `json:"methods,omitempty"`
+
+	// Headers optionally restricts the rule to requests with all of these headers.
+	Headers []HeaderMatcher `json:"headers,omitempty"`
+
 	// A (probably local) address. Requests for a hostname which matches this
 	// rule's hostname pattern will be proxied to the service running on this
 	// address.
@@ -28,6 +35,21 @@ type Rule struct {
 	// Handlers is a list of functions that acts as a middleware during ProxyHTTP
 	Handlers []middleware.Handler
 
+	// Filters decide whether requests are allowed, denied or routed to another service, based on their attributes.
+	Filters []Filter `json:"filters,omitempty"`
+
+	// Mirror sends copies of requests to a shadow origin. It's configured in originRequest.
+	Mirror *Mirror `json:"-"`
+
+	// HealthCheck checks that the origins are healthy. It's configured in originRequest.
+	HealthCheck *HealthCheck `json:"-"`
+
+	// Cache caches the responses of the origin. It's configured in originRequest.
+	Cache *ResponseCache `json:"-"`
+
+	// RateLimit limits the rate of requests of every client. It's configured in originRequest.
+	RateLimit *RateLimit `json:"-"`
+
 	// Configure the request cloudflared sends to this specific origin.
 	Config OriginRequestConfig `json:"originRequest"`
 }
@@ -46,6 +68,18 @@ func (r Rule) MultiLineString() string {
 		out.WriteString(r.Path.Regexp.String())
 		out.WriteRune('\n')
 	}
+	if len(r.Methods) > 0 {
+		out.WriteString("\tmethods: ")
+		out.WriteString(strings.Join(r.Methods, ", "))
+		out.WriteRune('\n')
+	}
+	for _, h := range r.Headers {
+		out.WriteString("\theader: ")
+		out.WriteString(h.Name)
+		out.WriteString(": ")
+		out.WriteString(h.Value.String())
+		out.WriteRune('\n')
+	}
 	out.WriteString("\tservice: ")
 	out.WriteString(r.Service.String())
 	return out.String()
@@ -63,8 +97,54 @@ func (r *Rule) Matches(hostname, path string) bool {
 	if r.punycodeHostname != "" {
 		punycodeHostMatch = matchHost(r.punycodeHostname, hostname)
 	}
-	pathMatch := r.Path == nil || r.Path.Regexp == nil || r.Path.Regexp.MatchString(path)
-	return (hostMatch || punycodeHostMatch) && pathMatch
+	return (hostMatch || punycodeHostMatch) && r.matchesPath(path)
+}
+
+// MatchesRequest checks if the rule matches the hostname, path, method and headers of a request.
+func (r *Rule) MatchesRequest(req *http.Request) bool {
+	return r.Matches(stripPort(req.Host), req.URL.Path) && r.matchesMethodAndHeaders(req.Method, req.Header)
+}
+
+func (r *Rule) matchesPath(path string) bool {
+	return r.Path == nil || r.Path.Regexp == nil || r.Path.Regexp.MatchString(path)
+}
+
+// matchesMethodAndHeaders checks the request against the optional methods and headers of the rule. A rule that has
+// some never matches when they aren't known, i.e. method is empty and header nil.
+func (r *Rule) matchesMethodAndHeaders(method string, header http.Header) bool {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			if m == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, h := range r.Headers {
+		if !h.matches(header) {
+			return false
+		}
+	}
+	return true
+}
+
+// HeaderMatcher matches requests with a header whose value matches a regex. The header name is canonical.
+type HeaderMatcher struct {
+	Name  string  `json:"name"`
+	Value *Regexp `json:"value"`
+}
+
+func (h HeaderMatcher) matches(header http.Header) bool {
+	for _, value := range header[h.Name] {
+		if h.Value.MatchString(value) {
+			return true
+		}
+	}
+	return false
 }
 
 // Regexp adds unmarshalling from json for regexp.Regexp
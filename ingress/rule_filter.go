@@ -0,0 +1,126 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress/filter"
+)
+
+// FilterAction is what happens to a request that matches a Filter.
+type FilterAction string
+
+const (
+	// FilterAllow proxies the request to the rule's service, without evaluating the remaining filters.
+	FilterAllow FilterAction = "allow"
+	// FilterDeny responds to the request with the filter's status code.
+	FilterDeny FilterAction = "deny"
+	// FilterRoute proxies the request to the filter's service instead of the rule's.
+	FilterRoute FilterAction = "route"
+
+	defaultFilterStatus = http.StatusForbidden
+)
+
+// Filter applies an action to the requests matching an expression.
+type Filter struct {
+	Expression *filter.Expression
+	Action     FilterAction
+	// StatusCode is the response status of denied requests.
+	StatusCode int
+	// Service handles the requests routed by this filter.
+	Service OriginService
+}
+
+func newFilter(cfg config.FilterConfig) (Filter, error) {
+	expr, err := filter.Compile(cfg.Expression)
+	if err != nil {
+		return Filter{}, err
+	}
+	f := Filter{Expression: expr, Action: FilterAction(cfg.Action)}
+	switch f.Action {
+	case FilterAllow:
+	case FilterDeny:
+		f.StatusCode = cfg.Status
+		if f.StatusCode == 0 {
+			f.StatusCode = defaultFilterStatus
+		}
+		if f.StatusCode < 100 || f.StatusCode > 999 {
+			return Filter{}, fmt.Errorf("invalid HTTP status code: %d", f.StatusCode)
+		}
+	case FilterRoute:
+		service, err := parseHTTPService(cfg.Service)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.Service = service
+	default:
+		return Filter{}, fmt.Errorf("unknown filter action %q, it must be one of %s, %s or %s", cfg.Action, FilterAllow, FilterDeny, FilterRoute)
+	}
+	return f, nil
+}
+
+// RawConfig returns the configuration the filter was created from.
+func (f Filter) RawConfig() config.FilterConfig {
+	cfg := config.FilterConfig{
+		Expression: f.Expression.String(),
+		Action:     string(f.Action),
+	}
+	if f.Action == FilterDeny {
+		cfg.Status = f.StatusCode
+	}
+	if f.Service != nil {
+		cfg.Service = f.Service.String()
+	}
+	return cfg
+}
+
+func (f Filter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.RawConfig())
+}
+
+// MatchFilter returns the first filter of the rule that matches req, or nil if there is none.
+// A deny filter that fails to evaluate is considered to match, so that mistakes don't let requests through. The
+// error is returned alongside so it can be reported.
+func (r *Rule) MatchFilter(req *http.Request) (*Filter, error) {
+	if len(r.Filters) == 0 {
+		return nil, nil
+	}
+	attrs := filter.AttributesFromRequest(req)
+	var evalErr error
+	for i := range r.Filters {
+		f := &r.Filters[i]
+		matched, err := f.Expression.Eval(attrs)
+		if err != nil {
+			if f.Action == FilterDeny {
+				return f, err
+			}
+			if evalErr == nil {
+				evalErr = err
+			}
+			continue
+		}
+		if matched {
+			return f, nil
+		}
+	}
+	return nil, evalErr
+}
+
+func parseFilters(filters []config.FilterConfig) ([]Filter, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	parsed := make([]Filter, len(filters))
+	for i, cfg := range filters {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "filter #%d", i+1)
+		}
+		parsed[i] = f
+	}
+	return parsed, nil
+}
@@ -0,0 +1,91 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShadowedRule is an ingress rule that never matches, because a previous rule matches all of its requests.
+type ShadowedRule struct {
+	// Index of the rule that never matches
+	Index int
+	// Index of the previous rule that matches its requests
+	ShadowedBy int
+}
+
+func (s ShadowedRule) String() string {
+	return fmt.Sprintf("Rule #%d never matches, because rule #%d matches all of its requests", s.Index+1, s.ShadowedBy+1)
+}
+
+// ShadowedRules returns the rules that never match, because of the order rules are evaluated in. Rules whose path
+// or header regexes only overlap aren't reported, since it can't be known whether a request matches both.
+func (ing Ingress) ShadowedRules() []ShadowedRule {
+	var shadowed []ShadowedRule
+	for j := range ing.Rules {
+		for i := 0; i < j; i++ {
+			if ing.Rules[i].covers(&ing.Rules[j]) {
+				shadowed = append(shadowed, ShadowedRule{Index: j, ShadowedBy: i})
+				break
+			}
+		}
+	}
+	return shadowed
+}
+
+// covers checks if the rule matches every request that other matches.
+func (r *Rule) covers(other *Rule) bool {
+	return r.coversHostname(other) && r.coversPath(other) && r.coversMethods(other) && r.coversHeaders(other)
+}
+
+func (r *Rule) coversHostname(other *Rule) bool {
+	if r.Hostname == "" || r.Hostname == "*" || r.Hostname == other.Hostname {
+		return true
+	}
+	// *.example.com matches both www.example.com and *.www.example.com
+	return strings.HasPrefix(r.Hostname, "*.") && strings.HasSuffix(other.Hostname, strings.TrimPrefix(r.Hostname, "*"))
+}
+
+func (r *Rule) coversPath(other *Rule) bool {
+	if r.Path == nil || r.Path.Regexp == nil {
+		return true
+	}
+	return other.Path != nil && other.Path.Regexp != nil && r.Path.String() == other.Path.String()
+}
+
+func (r *Rule) coversMethods(other *Rule) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+	if len(other.Methods) == 0 {
+		return false
+	}
+	for _, method := range other.Methods {
+		found := false
+		for _, m := range r.Methods {
+			if m == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) coversHeaders(other *Rule) bool {
+	for _, h := range r.Headers {
+		found := false
+		for _, o := range other.Headers {
+			if o.Name == h.Name && o.Value.String() == h.Value.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
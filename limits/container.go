@@ -0,0 +1,49 @@
+// Package limits adapts cloudflared to the resources it's given: it sizes the Go runtime to the CPU and memory limits
+// of its container, and sheds load before it runs out of them.
+package limits
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+)
+
+// memoryLimitRatio is the share of the container's memory limit given to the Go runtime as its soft memory limit,
+// leaving room for memory the runtime doesn't manage.
+const memoryLimitRatio = 0.9
+
+// ContainerLimits are the resources available to the process, as enforced by its cgroup.
+type ContainerLimits struct {
+	// CPUs is the CPU quota in number of CPUs, or 0 if there's no quota.
+	CPUs float64
+	// MemoryBytes is the memory limit, or 0 if there's no limit.
+	MemoryBytes int64
+}
+
+// ApplyContainerLimits sets GOMAXPROCS to the container's CPU quota and the Go runtime's soft memory limit below the
+// container's memory limit, so the runtime doesn't schedule more threads than it can run or grow the heap until the
+// process is killed. Values set explicitly with the GOMAXPROCS and GOMEMLIMIT environment variables are kept.
+func ApplyContainerLimits(log *zerolog.Logger) ContainerLimits {
+	limits, err := readContainerLimits()
+	if err != nil {
+		log.Debug().Err(err).Msg("Unable to read the container's resource limits")
+		return limits
+	}
+
+	if _, ok := os.LookupEnv("GOMAXPROCS"); !ok && limits.CPUs > 0 {
+		procs := int(math.Ceil(limits.CPUs))
+		if procs < runtime.GOMAXPROCS(0) {
+			runtime.GOMAXPROCS(procs)
+			log.Info().Msgf("Set GOMAXPROCS to %d to match the container's CPU quota", procs)
+		}
+	}
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok && limits.MemoryBytes > 0 {
+		memLimit := int64(float64(limits.MemoryBytes) * memoryLimitRatio)
+		debug.SetMemoryLimit(memLimit)
+		log.Info().Msgf("Set the Go memory limit to %d MB to stay within the container's memory limit", memLimit/1024/1024)
+	}
+	return limits
+}
@@ -0,0 +1,118 @@
+//go:build linux
+
+package limits
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+func readContainerLimits() (ContainerLimits, error) {
+	return readCgroupLimits(cgroupRoot, "/proc/self/cgroup")
+}
+
+// readCgroupLimits reads the CPU quota and memory limit of the process's cgroup, for both cgroup v2 (unified) and v1
+// hierarchies.
+func readCgroupLimits(root, procCgroupPath string) (ContainerLimits, error) {
+	paths, err := parseProcCgroup(procCgroupPath)
+	if err != nil {
+		return ContainerLimits{}, err
+	}
+	var limits ContainerLimits
+	if unified, ok := paths[""]; ok {
+		dir := cgroupDir(root, "", unified)
+		limits.CPUs = readCPUMax(filepath.Join(dir, "cpu.max"))
+		limits.MemoryBytes = readLimit(filepath.Join(dir, "memory.max"))
+	}
+	if cpuPath, ok := paths["cpu"]; ok {
+		dir := cgroupDir(root, "cpu", cpuPath)
+		quota := readLimit(filepath.Join(dir, "cpu.cfs_quota_us"))
+		period := readLimit(filepath.Join(dir, "cpu.cfs_period_us"))
+		if quota > 0 && period > 0 {
+			limits.CPUs = float64(quota) / float64(period)
+		}
+	}
+	if memoryPath, ok := paths["memory"]; ok {
+		dir := cgroupDir(root, "memory", memoryPath)
+		limit := readLimit(filepath.Join(dir, "memory.limit_in_bytes"))
+		// Without a limit, cgroup v1 reports a huge number rounded to the page size
+		if limit > 0 && limit < 1<<62 {
+			limits.MemoryBytes = limit
+		}
+	}
+	return limits, nil
+}
+
+// parseProcCgroup maps the controllers of the process's cgroups to their path. The unified hierarchy of cgroup v2 is
+// mapped from the empty controller.
+func parseProcCgroup(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Lines are formatted as hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// cgroupDir returns the directory of a cgroup. In containers, the cgroup namespace usually makes the process's cgroup
+// the root of the hierarchy, so the root is used if the full path doesn't exist.
+func cgroupDir(root, controller, path string) string {
+	base := filepath.Join(root, controller)
+	dir := filepath.Join(base, path)
+	if _, err := os.Stat(dir); err != nil {
+		return base
+	}
+	return dir
+}
+
+// readCPUMax parses the cpu.max file of cgroup v2, formatted as "$MAX $PERIOD" where $MAX may be "max".
+func readCPUMax(path string) float64 {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+// readLimit reads a file holding a single number, returning 0 if it doesn't exist, is "max" or is negative.
+func readLimit(path string) int64 {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
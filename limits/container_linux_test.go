@@ -0,0 +1,75 @@
+//go:build linux
+
+package limits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestReadCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected ContainerLimits
+	}{
+		{
+			name: "cgroup v2",
+			files: map[string]string{
+				"proc":                         "0::/docker/abc\n",
+				"cgroup/docker/abc/cpu.max":    "150000 100000\n",
+				"cgroup/docker/abc/memory.max": "134217728\n",
+			},
+			expected: ContainerLimits{CPUs: 1.5, MemoryBytes: 128 * 1024 * 1024},
+		},
+		{
+			name: "cgroup v2 without limits, in a cgroup namespace",
+			files: map[string]string{
+				"proc":              "0::/\n",
+				"cgroup/cpu.max":    "max 100000\n",
+				"cgroup/memory.max": "max\n",
+			},
+		},
+		{
+			name: "cgroup v1",
+			files: map[string]string{
+				"proc": "12:memory:/kubepods/pod1\n4:cpu,cpuacct:/kubepods/pod1\n",
+				"cgroup/cpu/kubepods/pod1/cpu.cfs_quota_us":  "50000\n",
+				"cgroup/cpu/kubepods/pod1/cpu.cfs_period_us": "100000\n",
+				// The cgroup namespace hides the path, so the controller's root is used
+				"cgroup/memory/memory.limit_in_bytes": "268435456\n",
+			},
+			expected: ContainerLimits{CPUs: 0.5, MemoryBytes: 256 * 1024 * 1024},
+		},
+		{
+			name: "cgroup v1 without limits",
+			files: map[string]string{
+				"proc":                                "12:memory:/\n4:cpu,cpuacct:/\n",
+				"cgroup/cpu/cpu.cfs_quota_us":         "-1\n",
+				"cgroup/cpu/cpu.cfs_period_us":        "100000\n",
+				"cgroup/memory/memory.limit_in_bytes": "9223372036854771712\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, test.files)
+			limits, err := readCgroupLimits(filepath.Join(root, "cgroup"), filepath.Join(root, "proc"))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, limits)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package limits
+
+import "errors"
+
+func readContainerLimits() (ContainerLimits, error) {
+	return ContainerLimits{}, errors.New("container limits are only supported on Linux")
+}
@@ -0,0 +1,32 @@
+package limits
+
+import (
+	"math"
+	"runtime/debug"
+)
+
+// SetMemoryLimit sets the Go runtime's soft memory limit, taking precedence over GOMEMLIMIT and the limit derived
+// from the container. The garbage collector runs more often as the process gets close to it. A limit that isn't
+// positive is ignored: the runtime would take 0 as a limit that is always exceeded.
+func SetMemoryLimit(bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	debug.SetMemoryLimit(bytes)
+}
+
+// MemoryLimit returns the Go runtime's soft memory limit, or 0 if it has none.
+func MemoryLimit() int64 {
+	// A negative value reads the limit without changing it
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return 0
+	}
+	return limit
+}
+
+// ShedMemoryThreshold is the memory above which load should be shed given the soft memory limit: once the garbage
+// collector can't keep the process under the limit, accepting more requests only grows it further.
+func ShedMemoryThreshold(memoryLimit int64) int64 {
+	return memoryLimit / 100 * 105
+}
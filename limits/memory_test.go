@@ -0,0 +1,25 @@
+package limits
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	defer debug.SetMemoryLimit(previous)
+
+	SetMemoryLimit(512 * 1024 * 1024)
+	require.Equal(t, int64(512*1024*1024), MemoryLimit())
+	require.Equal(t, int64(512*1024*1024/100*105), ShedMemoryThreshold(MemoryLimit()))
+
+	// 0 isn't a limit
+	SetMemoryLimit(0)
+	require.Equal(t, int64(512*1024*1024), MemoryLimit())
+
+	SetMemoryLimit(math.MaxInt64)
+	require.Zero(t, MemoryLimit())
+}
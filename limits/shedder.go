@@ -0,0 +1,142 @@
+package limits
+
+import (
+	"context"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultCheckInterval = 100 * time.Millisecond
+	// Load is shed until the measurements drop below this share of their limit, so shedding doesn't flap around it.
+	recoveryRatio = 0.9
+	// Weight of the latest scheduler latency measurement in its moving average.
+	latencySmoothing = 0.25
+
+	memoryTotalMetric    = "/memory/classes/total:bytes"
+	memoryReleasedMetric = "/memory/classes/heap/released:bytes"
+)
+
+var (
+	sheddingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "limits",
+		Name:      "shedding_load",
+		Help:      "1 if requests are rejected because cloudflared is overloaded, 0 otherwise",
+	})
+	schedulerLatencyGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "cloudflared",
+		Subsystem: "limits",
+		Name:      "scheduler_latency_seconds",
+		Help:      "Moving average of how late goroutines are scheduled",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(sheddingGauge, schedulerLatencyGauge)
+}
+
+// ShedderConfig sets the thresholds above which a LoadShedder sheds load. A zero threshold isn't enforced.
+type ShedderConfig struct {
+	// MaxSchedulerLatency is how late goroutines may be scheduled, which grows when the process is starved of CPU.
+	MaxSchedulerLatency time.Duration
+	// MaxMemoryBytes is how much memory the Go runtime may hold.
+	MaxMemoryBytes int64
+	// CheckInterval is how often the measurements are taken. Defaults to 100ms.
+	CheckInterval time.Duration
+}
+
+// LoadShedder tells when cloudflared is overloaded, so new requests can be rejected to let the in-flight ones
+// complete, instead of slowing all of them down until the process is killed.
+type LoadShedder struct {
+	config     ShedderConfig
+	log        *zerolog.Logger
+	overloaded atomic.Bool
+	// latency is the moving average of the scheduler latency. It's only accessed by Run.
+	latency time.Duration
+}
+
+// NewLoadShedder creates a LoadShedder. It doesn't measure anything until Run is called.
+func NewLoadShedder(config ShedderConfig, log *zerolog.Logger) *LoadShedder {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultCheckInterval
+	}
+	return &LoadShedder{config: config, log: log}
+}
+
+// Enabled checks if any threshold is set.
+func (s *LoadShedder) Enabled() bool {
+	return s.config.MaxSchedulerLatency > 0 || s.config.MaxMemoryBytes > 0
+}
+
+// Overloaded checks if load should be shed.
+func (s *LoadShedder) Overloaded() bool {
+	return s.overloaded.Load()
+}
+
+// Run measures the load until ctx is done. The scheduler latency is how late a ticker fires compared to when it
+// should have.
+func (s *LoadShedder) Run(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	samples := []metrics.Sample{{Name: memoryTotalMetric}, {Name: memoryReleasedMetric}}
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	expected := time.Now().Add(s.config.CheckInterval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			lateness := time.Since(expected)
+			if lateness < 0 {
+				lateness = 0
+			}
+			expected = now.Add(s.config.CheckInterval)
+			var memory int64
+			if s.config.MaxMemoryBytes > 0 {
+				metrics.Read(samples)
+				memory = int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+			}
+			s.update(lateness, memory)
+		}
+	}
+}
+
+func (s *LoadShedder) update(lateness time.Duration, memory int64) {
+	s.latency = time.Duration(latencySmoothing*float64(lateness) + (1-latencySmoothing)*float64(s.latency))
+	schedulerLatencyGauge.Set(s.latency.Seconds())
+
+	latencyRatio := ratio(int64(s.latency), int64(s.config.MaxSchedulerLatency))
+	memoryRatio := ratio(memory, s.config.MaxMemoryBytes)
+	wasOverloaded := s.overloaded.Load()
+	overloaded := latencyRatio > 1 || memoryRatio > 1
+	if wasOverloaded && !overloaded {
+		// Keep shedding until the load has dropped clearly below the limits
+		overloaded = latencyRatio > recoveryRatio || memoryRatio > recoveryRatio
+	}
+	if overloaded == wasOverloaded {
+		return
+	}
+	s.overloaded.Store(overloaded)
+	if overloaded {
+		sheddingGauge.Set(1)
+		s.log.Warn().Dur("schedulerLatency", s.latency).Int64("memoryBytes", memory).Msg("cloudflared is overloaded, new requests are rejected until the load drops")
+	} else {
+		sheddingGauge.Set(0)
+		s.log.Info().Dur("schedulerLatency", s.latency).Int64("memoryBytes", memory).Msg("cloudflared is no longer overloaded")
+	}
+}
+
+// ratio returns value/limit, or 0 if there's no limit.
+func ratio(value, limit int64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return float64(value) / float64(limit)
+}
@@ -0,0 +1,47 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedderHysteresis(t *testing.T) {
+	log := zerolog.Nop()
+	shedder := NewLoadShedder(ShedderConfig{MaxMemoryBytes: 1000}, &log)
+	assert.True(t, shedder.Enabled())
+
+	shedder.update(0, 900)
+	assert.False(t, shedder.Overloaded())
+	shedder.update(0, 1100)
+	assert.True(t, shedder.Overloaded())
+	// Still shedding until memory is below 90% of the limit
+	shedder.update(0, 950)
+	assert.True(t, shedder.Overloaded())
+	shedder.update(0, 850)
+	assert.False(t, shedder.Overloaded())
+}
+
+func TestLoadShedderLatency(t *testing.T) {
+	log := zerolog.Nop()
+	shedder := NewLoadShedder(ShedderConfig{MaxSchedulerLatency: 100 * time.Millisecond}, &log)
+
+	// A single late tick is smoothed out
+	shedder.update(200*time.Millisecond, 0)
+	assert.False(t, shedder.Overloaded())
+	for i := 0; i < 10; i++ {
+		shedder.update(200*time.Millisecond, 0)
+	}
+	assert.True(t, shedder.Overloaded())
+	for i := 0; i < 20; i++ {
+		shedder.update(0, 0)
+	}
+	assert.False(t, shedder.Overloaded())
+}
+
+func TestLoadShedderDisabled(t *testing.T) {
+	log := zerolog.Nop()
+	assert.False(t, NewLoadShedder(ShedderConfig{}, &log).Enabled())
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -14,6 +15,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"golang.org/x/net/trace"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
 )
 
 const (
@@ -25,12 +30,26 @@ type Config struct {
 	ReadyServer         *ReadyServer
 	QuickTunnelHostname string
 	Orchestrator        orchestrator
+	// Drainer reports the requests in flight and the progress of graceful shutdowns.
+	Drainer *connection.Drainer
+	// StateDump, if set, is served at /debug/state.
+	StateDump *StateDump
+	// BuildInfo, if set, is served as JSON at /buildinfo.
+	BuildInfo *cliutil.BuildInfo
 
 	ShutdownTimeout time.Duration
 }
 
 type orchestrator interface {
 	GetVersionedConfigJSON() ([]byte, error)
+	ConfigStatus() (version int32, fingerprint string)
+	OriginHealth() []ingress.OriginHealth
+}
+
+// Status is served at /status, to tell which configuration a connector runs.
+type Status struct {
+	ConfigVersion     int32  `json:"configVersion"`
+	ConfigFingerprint string `json:"configFingerprint"`
 }
 
 func newMetricsHandler(
@@ -60,6 +79,39 @@ func newMetricsHandler(
 			}
 			_, _ = w.Write(json)
 		})
+		router.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			version, fingerprint := config.Orchestrator.ConfigStatus()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(Status{ConfigVersion: version, ConfigFingerprint: fingerprint})
+		})
+		router.HandleFunc("/origins", func(w http.ResponseWriter, r *http.Request) {
+			health := config.Orchestrator.OriginHealth()
+			if health == nil {
+				health = []ingress.OriginHealth{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(health)
+		})
+	}
+	if config.Drainer != nil {
+		router.HandleFunc("/drain", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(config.Drainer.Status())
+		})
+	}
+	if config.BuildInfo != nil {
+		router.HandleFunc("/buildinfo", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(config.BuildInfo)
+		})
+	}
+	if config.StateDump != nil {
+		router.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			if err := config.StateDump.Write(w); err != nil {
+				log.Err(err).Msg("Failed to serve state dump")
+			}
+		})
 	}
 
 	return router
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cmd/cloudflared/cliutil"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+func TestBuildInfoEndpoint(t *testing.T) {
+	log := zerolog.Nop()
+	buildInfo := &cliutil.BuildInfo{
+		GoOS:               "linux",
+		GoVersion:          "go1.19.6",
+		GoArch:             "amd64",
+		CloudflaredVersion: "2023.3.0",
+		BuildTags:          []string{},
+		Features:           []string{"quic"},
+		Protocols:          []string{"quic", "http2"},
+	}
+
+	router := newMetricsHandler(Config{BuildInfo: buildInfo}, &log)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/buildinfo", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{
+		"go_os": "linux",
+		"go_version": "go1.19.6",
+		"go_arch": "amd64",
+		"build_type": "",
+		"cloudflared_version": "2023.3.0",
+		"build_tags": [],
+		"features": ["quic"],
+		"protocols": ["quic", "http2"]
+	}`, rec.Body.String())
+
+	// Not served without build info
+	router = newMetricsHandler(Config{}, &log)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/buildinfo", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+type mockOrchestrator struct {
+	version      int32
+	fingerprint  string
+	originHealth []ingress.OriginHealth
+}
+
+func (m *mockOrchestrator) GetVersionedConfigJSON() ([]byte, error) {
+	return []byte(`{}`), nil
+}
+
+func (m *mockOrchestrator) ConfigStatus() (int32, string) {
+	return m.version, m.fingerprint
+}
+
+func (m *mockOrchestrator) OriginHealth() []ingress.OriginHealth {
+	return m.originHealth
+}
+
+func TestStatusEndpoint(t *testing.T) {
+	log := zerolog.Nop()
+	router := newMetricsHandler(Config{Orchestrator: &mockOrchestrator{version: 3, fingerprint: "0123456789abcdef"}}, &log)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"configVersion":3,"configFingerprint":"0123456789abcdef"}`, rec.Body.String())
+}
+
+func TestOriginsEndpoint(t *testing.T) {
+	log := zerolog.Nop()
+	orchestrator := &mockOrchestrator{}
+	router := newMetricsHandler(Config{Orchestrator: orchestrator}, &log)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/origins", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `[]`, rec.Body.String())
+
+	orchestrator.originHealth = []ingress.OriginHealth{{Rule: 1, Origin: "http://localhost:8080", LastError: "connection refused"}}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/origins", nil))
+	require.JSONEq(t, `[{"rule":1,"origin":"http://localhost:8080","healthy":false,"lastCheck":"0001-01-01T00:00:00Z","lastError":"connection refused"}]`, rec.Body.String())
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"runtime/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cloudflare/cloudflared/limits"
+)
+
+const (
+	runtimeNamespace = "cloudflared"
+	runtimeSubsystem = "runtime"
+)
+
+// runtimeMetric maps a metric of the Go runtime to a Prometheus metric.
+type runtimeMetric struct {
+	name      string
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+// runtimeCollector exports the memory and garbage collector statistics of the Go runtime that the default Go collector
+// doesn't, so cloudflared's memory limit can be tuned to share a host with other processes. Metrics that the running
+// Go version doesn't support are left out.
+type runtimeCollector struct {
+	metrics     []runtimeMetric
+	memoryLimit *prometheus.Desc
+}
+
+func newRuntimeCollector() *runtimeCollector {
+	supported := make(map[string]bool)
+	for _, description := range metrics.All() {
+		supported[description.Name] = true
+	}
+
+	c := &runtimeCollector{
+		memoryLimit: prometheus.NewDesc(
+			prometheus.BuildFQName(runtimeNamespace, runtimeSubsystem, "memory_limit_bytes"),
+			"Soft memory limit of the Go runtime, 0 if there's none",
+			nil, nil,
+		),
+	}
+	for _, m := range []struct {
+		runtimeName string
+		name        string
+		help        string
+		valueType   prometheus.ValueType
+	}{
+		{"/memory/classes/total:bytes", "memory_total_bytes", "Memory mapped by the Go runtime", prometheus.GaugeValue},
+		{"/memory/classes/heap/released:bytes", "memory_released_bytes", "Memory returned to the operating system but still mapped by the Go runtime", prometheus.GaugeValue},
+		{"/gc/heap/goal:bytes", "gc_heap_goal_bytes", "Heap size at which the next garbage collection will be triggered", prometheus.GaugeValue},
+		{"/gc/cycles/total:gc-cycles", "gc_cycles_total", "Count of completed garbage collection cycles", prometheus.CounterValue},
+		{"/gc/cycles/forced:gc-cycles", "gc_forced_cycles_total", "Count of garbage collection cycles forced by the application", prometheus.CounterValue},
+		{"/gc/limiter/last-enabled:gc-cycle", "gc_limiter_last_enabled_cycle", "Last garbage collection cycle whose CPU usage was capped by the limiter, because the memory limit was about to be exceeded", prometheus.GaugeValue},
+	} {
+		if !supported[m.runtimeName] {
+			continue
+		}
+		c.metrics = append(c.metrics, runtimeMetric{
+			name:      m.runtimeName,
+			desc:      prometheus.NewDesc(prometheus.BuildFQName(runtimeNamespace, runtimeSubsystem, m.name), m.help, nil, nil),
+			valueType: m.valueType,
+		})
+	}
+	return c
+}
+
+func (c *runtimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.memoryLimit
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+}
+
+func (c *runtimeCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.memoryLimit, prometheus.GaugeValue, float64(limits.MemoryLimit()))
+
+	samples := make([]metrics.Sample, len(c.metrics))
+	for i, m := range c.metrics {
+		samples[i].Name = m.name
+	}
+	metrics.Read(samples)
+	for i, sample := range samples {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.metrics[i].desc, c.metrics[i].valueType, float64(sample.Value.Uint64()))
+	}
+}
+
+func init() {
+	prometheus.MustRegister(newRuntimeCollector())
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(newRuntimeCollector()))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	require.True(t, names["cloudflared_runtime_memory_limit_bytes"])
+	require.True(t, names["cloudflared_runtime_memory_total_bytes"])
+	require.True(t, names["cloudflared_runtime_gc_heap_goal_bytes"])
+	require.True(t, names["cloudflared_runtime_gc_cycles_total"])
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+// StateDump writes what's needed to diagnose a connector that hangs without attaching a debugger: the state of its
+// connections to the edge, the requests and sessions in flight, every metric, including the counters by ingress rule
+// and the sessions being proxied, and the stacks of all goroutines.
+type StateDump struct {
+	// Connections, if set, tracks the state of the connections to the edge.
+	Connections *tunnelstate.ConnTracker
+	// Drainer, if set, tracks the requests and sessions in flight.
+	Drainer *connection.Drainer
+}
+
+// Write writes the state dump to w.
+func (d *StateDump) Write(w io.Writer) error {
+	fmt.Fprintf(w, "cloudflared state dump at %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	fmt.Fprintln(w, "\n== Connections")
+	if d.Connections != nil {
+		connections := d.Connections.Connections()
+		indexes := make([]int, 0, len(connections))
+		for index := range connections {
+			indexes = append(indexes, int(index))
+		}
+		sort.Ints(indexes)
+		for _, index := range indexes {
+			ci := connections[uint8(index)]
+			fmt.Fprintf(w, "connection %d: connected=%t protocol=%s location=%s\n", index, ci.IsConnected, ci.Protocol, ci.Location)
+		}
+	}
+
+	fmt.Fprintln(w, "\n== In flight")
+	status, err := json.Marshal(d.Drainer.Status())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s\n", status)
+
+	fmt.Fprintln(w, "\n== Metrics")
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "\n== Goroutines")
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
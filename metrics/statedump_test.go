@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+)
+
+func TestStateDump(t *testing.T) {
+	drainer := connection.NewDrainer()
+	done, ok := drainer.Track(connection.InFlightWebsocket)
+	require.True(t, ok)
+	defer done()
+
+	dump := &StateDump{
+		Connections: tunnelstate.MockedConnTracker(map[uint8]tunnelstate.ConnectionInfo{
+			1: {IsConnected: false, Protocol: connection.HTTP2},
+			0: {IsConnected: true, Protocol: connection.QUIC, Location: "lis01"},
+		}),
+		Drainer: drainer,
+	}
+	var buf bytes.Buffer
+	require.NoError(t, dump.Write(&buf))
+
+	out := buf.String()
+	require.Contains(t, out, "connection 0: connected=true protocol=quic location=lis01\nconnection 1: connected=false protocol=http2")
+	require.Contains(t, out, `"inFlight":{"websocket":1}`)
+	require.Contains(t, out, "cloudflared_tunnel_in_flight")
+	require.Contains(t, out, "goroutine")
+	require.Contains(t, out, "TestStateDump")
+}
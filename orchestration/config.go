@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 
 	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/proxy"
+	"github.com/cloudflare/cloudflared/recording"
 )
 
 type newRemoteConfig struct {
@@ -25,6 +28,35 @@ type Config struct {
 	// Extra settings used to configure this instance but that are not eligible for remotely management
 	// ie. (--protocol, --loglevel, ...)
 	ConfigurationFlags map[string]string
+
+	// Recorder, if set, records HTTP exchanges proxied with any version of the ingress rules.
+	Recorder *recording.Recorder
+
+	// Observer, if set, is notified when a new remote configuration is applied.
+	Observer *connection.Observer
+
+	// LoadShedder, if set, makes the proxy reject requests while cloudflared is overloaded.
+	LoadShedder proxy.LoadShedder
+
+	// Drainer, if set, tracks the requests in flight so a graceful shutdown can wait for them.
+	Drainer *connection.Drainer
+
+	// WarmupOrigins prepares the connections to origins when the ingress rules are applied, instead of on their first
+	// request, so that misconfigured origins are reported right away.
+	WarmupOrigins bool
+
+	// ProbeOrigins checks that the origins of a configuration update accept connections before it's applied, and keeps
+	// the current configuration if they don't.
+	ProbeOrigins bool
+
+	// TrailersSupported is set when every connection to the edge uses the http2 protocol, the only one that carries
+	// response trailers. Without it, ingress rules to gRPC origins are rejected, since gRPC clients fail the calls that
+	// end without their grpc-status trailer.
+	TrailersSupported bool
+
+	// RemoteConfigCache, if set, is the file that the last remote configuration applied is cached in, to start with it
+	// when the Cloudflare API can't be reached.
+	RemoteConfigCache string
 }
 
 func (rc *newLocalConfig) MarshalJSON() ([]byte, error) {
@@ -55,9 +87,20 @@ func convertToUnvalidatedIngressRules(i ingress.Ingress) []config.UnvalidatedIng
 		newRule := config.UnvalidatedIngressRule{
 			Hostname:      rule.Hostname,
 			Path:          path,
+			Methods:       rule.Methods,
 			Service:       rule.Service.String(),
+			Origins:       rule.Origins(),
 			OriginRequest: ingress.ConvertToRawOriginConfig(rule.Config),
 		}
+		for _, h := range rule.Headers {
+			if newRule.Headers == nil {
+				newRule.Headers = make(map[string]string)
+			}
+			newRule.Headers[h.Name] = h.Value.String()
+		}
+		for _, f := range rule.Filters {
+			newRule.Filters = append(newRule.Filters, f.RawConfig())
+		}
 
 		result = append(result, newRule)
 	}
@@ -0,0 +1,73 @@
+package orchestration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// fingerprintLength is the number of hex characters of a fingerprint
+const fingerprintLength = 16
+
+// ingressConfigJSON is how the effective configuration is serialized, e.g. for the /config endpoint
+type ingressConfigJSON struct {
+	Ingress       []ingress.Rule              `json:"ingress"`
+	WarpRouting   config.WarpRoutingConfig    `json:"warp-routing"`
+	OriginRequest ingress.OriginRequestConfig `json:"originRequest"`
+}
+
+func newIngressConfigJSON(ing *ingress.Ingress, warpRouting ingress.WarpRoutingConfig) ingressConfigJSON {
+	return ingressConfigJSON{
+		Ingress:       ing.Rules,
+		WarpRouting:   warpRouting.RawConfig(),
+		OriginRequest: ing.Defaults,
+	}
+}
+
+// redacted returns a copy of the configuration with its secrets redacted, for showing it.
+func (c ingressConfigJSON) redacted() ingressConfigJSON {
+	c.Ingress = ingress.RedactedRules(c.Ingress)
+	c.OriginRequest = c.OriginRequest.Redacted()
+	return c
+}
+
+// EffectiveConfigJSON serializes the effective configuration, secrets included, so that configurations from different
+// sources can be compared.
+func EffectiveConfigJSON(ing *ingress.Ingress, warpRouting ingress.WarpRoutingConfig) ([]byte, error) {
+	return json.Marshal(newIngressConfigJSON(ing, warpRouting))
+}
+
+// RedactedConfigJSON serializes the effective configuration the way the /config endpoint shows it, with its secrets
+// redacted.
+func RedactedConfigJSON(ing *ingress.Ingress, warpRouting ingress.WarpRoutingConfig) ([]byte, error) {
+	return json.Marshal(newIngressConfigJSON(ing, warpRouting).redacted())
+}
+
+// ConfigFingerprint is a stable hash of the effective configuration. It's the same for connectors running the same
+// ingress rules, origin request settings and WARP routing, whatever their configuration version or file layout, so it
+// can be compared across a fleet to detect drift.
+func ConfigFingerprint(ing *ingress.Ingress, warpRouting ingress.WarpRoutingConfig) (string, error) {
+	// encoding/json sorts map keys, so the serialization is deterministic
+	serialized, err := EffectiveConfigJSON(ing, warpRouting)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(serialized)
+	return hex.EncodeToString(sum[:])[:fingerprintLength], nil
+}
+
+// ConfigStatus returns the version and fingerprint of the current configuration.
+func (o *Orchestrator) ConfigStatus() (int32, string) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return o.currentVersion, o.fingerprint
+}
+
+// ConfigFingerprint returns the fingerprint of the current configuration, which connections report when they register.
+func (o *Orchestrator) ConfigFingerprint() string {
+	_, fingerprint := o.ConfigStatus()
+	return fingerprint
+}
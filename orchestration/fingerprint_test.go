@@ -0,0 +1,107 @@
+package orchestration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+func TestConfigFingerprint(t *testing.T) {
+	parse := func(rules ...config.UnvalidatedIngressRule) *ingress.Ingress {
+		ing, err := ingress.ParseIngress(&config.Configuration{
+			TunnelID: "fingerprint",
+			Ingress:  rules,
+		})
+		require.NoError(t, err)
+		return &ing
+	}
+	warpRouting := ingress.NewWarpRoutingConfig(&config.WarpRoutingConfig{})
+	catchAll := config.UnvalidatedIngressRule{Service: "http_status:404"}
+	hello := config.UnvalidatedIngressRule{Hostname: "hello.example.com", Service: "http://localhost:8080"}
+
+	fingerprint, err := ConfigFingerprint(parse(hello, catchAll), warpRouting)
+	require.NoError(t, err)
+	require.Len(t, fingerprint, fingerprintLength)
+
+	// Stable for the same configuration
+	same, err := ConfigFingerprint(parse(hello, catchAll), warpRouting)
+	require.NoError(t, err)
+	require.Equal(t, fingerprint, same)
+
+	// Changes with the rules
+	other, err := ConfigFingerprint(parse(catchAll), warpRouting)
+	require.NoError(t, err)
+	require.NotEqual(t, fingerprint, other)
+
+	// Changes with a secret
+	withToken := func(token string) config.UnvalidatedIngressRule {
+		rule := hello
+		rule.OriginRequest.HTTPHeaders = &config.HTTPHeadersConfig{Add: map[string]string{"Authorization": token}}
+		return rule
+	}
+	oldToken, err := ConfigFingerprint(parse(withToken("Bearer old-token"), catchAll), warpRouting)
+	require.NoError(t, err)
+	newToken, err := ConfigFingerprint(parse(withToken("Bearer new-token"), catchAll), warpRouting)
+	require.NoError(t, err)
+	require.NotEqual(t, oldToken, newToken)
+
+	// Changes with WARP routing
+	warpRouting = ingress.NewWarpRoutingConfig(&config.WarpRoutingConfig{Enabled: true})
+	other, err = ConfigFingerprint(parse(hello, catchAll), warpRouting)
+	require.NoError(t, err)
+	require.NotEqual(t, fingerprint, other)
+}
+
+func TestConfigStatus(t *testing.T) {
+	orchestrator, err := NewOrchestrator(context.Background(), &Config{Ingress: &ingress.Ingress{}}, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+	version, initial := orchestrator.ConfigStatus()
+	require.Equal(t, int32(-1), version)
+	require.NotEmpty(t, initial)
+
+	updateWithValidation(t, orchestrator, 2, []byte(`{"ingress": [{"service": "http_status:404"}]}`))
+	version, updated := orchestrator.ConfigStatus()
+	require.Equal(t, int32(2), version)
+	require.NotEqual(t, initial, updated)
+
+	expected, err := ConfigFingerprint(orchestrator.config.Ingress, orchestrator.config.WarpRouting)
+	require.NoError(t, err)
+	require.Equal(t, expected, updated)
+	require.Equal(t, updated, orchestrator.ConfigFingerprint())
+}
+
+func TestConfigJSONRedactsSecrets(t *testing.T) {
+	orchestrator, err := NewOrchestrator(context.Background(), &Config{Ingress: &ingress.Ingress{}}, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+	updateWithValidation(t, orchestrator, 1, []byte(`{
+	"ingress": [{
+		"service": "http://localhost:8080",
+		"originRequest": {"httpHeaders": {"add": {"Authorization": "Bearer origin-token"}}}
+	}],
+	"originRequest": {"discovery": {"token": "consul-token"}}
+}`))
+
+	// The configuration sent to the edge keeps the secrets
+	configJSON, err := orchestrator.GetConfigJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(configJSON), "Bearer origin-token")
+	require.Contains(t, string(configJSON), "consul-token")
+
+	// The one shown by /config doesn't
+	versionedJSON, err := orchestrator.GetVersionedConfigJSON()
+	require.NoError(t, err)
+	require.NotContains(t, string(versionedJSON), "origin-token")
+	require.Contains(t, string(versionedJSON), `"Authorization":"REDACTED"`)
+	require.NotContains(t, string(versionedJSON), "consul-token")
+	require.Contains(t, string(versionedJSON), `"token":"REDACTED"`)
+
+	redactedJSON, err := RedactedConfigJSON(orchestrator.config.Ingress, orchestrator.config.WarpRouting)
+	require.NoError(t, err)
+	require.NotContains(t, string(redactedJSON), "origin-token")
+	require.NotContains(t, string(redactedJSON), "consul-token")
+	require.Equal(t, "Bearer origin-token", orchestrator.config.Ingress.Rules[0].Config.HTTPHeaders.Add["Authorization"])
+}
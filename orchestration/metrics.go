@@ -18,8 +18,32 @@ var (
 			Help:      "Configuration Version",
 		},
 	)
+	configRejections = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "config_rejections_total",
+			Help:      "Count of configurations rejected because their origins were unreachable",
+		},
+	)
+	localConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Subsystem: MetricsSubsystem,
+			Name:      "local_config_reloads_total",
+			Help:      "Count of reloads of the local configuration file, by result: applied, unchanged, invalid or failed",
+		},
+		[]string{"result"},
+	)
+)
+
+const (
+	reloadApplied   = "applied"
+	reloadUnchanged = "unchanged"
+	reloadInvalid   = "invalid"
+	reloadFailed    = "failed"
 )
 
 func init() {
-	prometheus.MustRegister(configVersion)
+	prometheus.MustRegister(configVersion, configRejections, localConfigReloads)
 }
@@ -1,6 +1,7 @@
 package orchestration
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,32 +14,42 @@ import (
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
 	"github.com/cloudflare/cloudflared/proxy"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/tunnelstate"
 )
 
 // Orchestrator manages configurations so they can be updatable during runtime
 // properties are static, so it can be read without lock
 // currentVersion and config are read/write infrequently, so their access are synchronized with RWMutex
-// access to proxy is synchronized with atmoic.Value, because it uses copy-on-write to provide scalable frequently
+// access to proxy is synchronized with atomic.Pointer, because it uses copy-on-write to provide scalable frequently
 // read when update is infrequent
 type Orchestrator struct {
 	currentVersion int32
 	// Used by UpdateConfig to make sure one update at a time
 	lock sync.RWMutex
-	// Underlying value is proxy.Proxy, can be read without the lock, but still needs the lock to update
-	proxy atomic.Value
+	// Can be read without the lock, but still needs the lock to update
+	proxy atomic.Pointer[proxy.Proxy]
 	// Set of internal ingress rules defined at cloudflared startup (separate from user-defined ingress rules)
-	internalRules      []ingress.Rule
+	internalRules []ingress.Rule
+	// Rules generated at runtime, e.g. from container labels, served after the user-defined ingress rules
+	generatedRules     []ingress.Rule
 	warpRoutingEnabled atomic.Bool
 	config             *Config
 	tags               []tunnelpogs.Tag
-	log                *zerolog.Logger
+	// Tracks the connections to the edge for the proxy, nil if there is no observer
+	connTracker *tunnelstate.ConnTracker
+	// fingerprint of the current configuration, recomputed whenever it changes
+	fingerprint string
+	log         *zerolog.Logger
 
 	// orchestrator must not handle any more updates after shutdownC is closed
 	shutdownC <-chan struct{}
 	// Closing proxyShutdownC will close the previous proxy
 	proxyShutdownC chan<- struct{}
+	// Middleware plugins of the rules the proxy serves, closed once no proxy serves them
+	plugins []middleware.Plugin
 }
 
 func NewOrchestrator(ctx context.Context, config *Config, tags []tunnelpogs.Tag, internalRules []ingress.Rule, log *zerolog.Logger) (*Orchestrator, error) {
@@ -53,6 +64,10 @@ func NewOrchestrator(ctx context.Context, config *Config, tags []tunnelpogs.Tag,
 		log:            log,
 		shutdownC:      ctx.Done(),
 	}
+	if config.Observer != nil {
+		o.connTracker = tunnelstate.NewConnTracker(log)
+		config.Observer.RegisterSink(o.connTracker)
+	}
 	if err := o.updateIngress(*config.Ingress, config.WarpRouting); err != nil {
 		return nil, err
 	}
@@ -62,10 +77,23 @@ func NewOrchestrator(ctx context.Context, config *Config, tags []tunnelpogs.Tag,
 
 // UpdateConfig creates a new proxy with the new ingress rules
 func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.UpdateConfigurationResponse {
+	// Every connection receives the configuration, so versions that are already applied aren't parsed and probed again.
+	// Probing waits for the origins, so it's done before taking the lock that requests need to get the proxy.
+	var newConf newRemoteConfig
+	var err error
+	if o.isNewVersion(version) {
+		if err = json.Unmarshal(config, &newConf); err != nil {
+			err = errors.Wrap(err, "failed to deserialize new configuration")
+		} else {
+			err = o.probeOrigins(newConf.Ingress)
+		}
+	}
+
 	o.lock.Lock()
 	defer o.lock.Unlock()
 
 	if o.currentVersion >= version {
+		closePlugins(newConf.Ingress.Plugins(), o.log)
 		o.log.Debug().
 			Int32("current_version", o.currentVersion).
 			Int32("received_version", version).
@@ -74,19 +102,12 @@ func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.Up
 			LastAppliedVersion: o.currentVersion,
 		}
 	}
-	var newConf newRemoteConfig
-	if err := json.Unmarshal(config, &newConf); err != nil {
-		o.log.Err(err).
-			Int32("version", version).
-			Str("config", string(config)).
-			Msgf("Failed to deserialize new configuration")
-		return &tunnelpogs.UpdateConfigurationResponse{
-			LastAppliedVersion: o.currentVersion,
-			Err:                err,
-		}
+	if err == nil {
+		err = o.updateIngress(newConf.Ingress, newConf.WarpRouting)
+	} else {
+		closePlugins(newConf.Ingress.Plugins(), o.log)
 	}
-
-	if err := o.updateIngress(newConf.Ingress, newConf.WarpRouting); err != nil {
+	if err != nil {
 		o.log.Err(err).
 			Int32("version", version).
 			Str("config", string(config)).
@@ -103,19 +124,111 @@ func (o *Orchestrator) UpdateConfig(version int32, config []byte) *tunnelpogs.Up
 		Str("config", string(config)).
 		Msg("Updated to new configuration")
 	configVersion.Set(float64(version))
+	o.cacheRemoteConfig(version, config)
+	if o.config.Observer != nil {
+		o.config.Observer.SendConfigUpdated(version)
+	}
 	return &tunnelpogs.UpdateConfigurationResponse{
 		LastAppliedVersion: o.currentVersion,
 	}
 }
 
+// UpdateGeneratedRules replaces the rules generated at runtime, which are matched after the user-defined ingress
+// rules, before their catch-all rule, and are kept across remote configuration updates. The previous rules are kept if the new ones are invalid.
+func (o *Orchestrator) UpdateGeneratedRules(rules []config.UnvalidatedIngressRule) error {
+	o.lock.RLock()
+	defaults := o.config.Ingress.Defaults
+	o.lock.RUnlock()
+	generatedRules, err := ingress.ParseGeneratedRules(rules, defaults)
+	if err != nil {
+		return err
+	}
+	if err := o.probeOrigins(ingress.Ingress{Rules: generatedRules}); err != nil {
+		closePlugins(ingress.Ingress{Rules: generatedRules}.Plugins(), o.log)
+		return err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	previousRules := o.generatedRules
+	o.generatedRules = generatedRules
+	if err := o.updateIngress(*o.config.Ingress, o.config.WarpRouting); err != nil {
+		o.generatedRules = previousRules
+		return err
+	}
+	return nil
+}
+
+// ReloadLocalConfig reads the local configuration file again, with the files it includes and the fragments of
+// configDir, and applies its ingress rules and WARP routing without restarting the connections to the edge. The current
+// rules are kept if the new ones are invalid, and the local configuration doesn't apply anymore once the tunnel is
+// configured remotely. It returns whether the rules changed.
+func (o *Orchestrator) ReloadLocalConfig(configFile, configDir string) (bool, error) {
+	conf, err := config.ReadConfiguration(configFile, configDir)
+	if err != nil {
+		localConfigReloads.WithLabelValues(reloadInvalid).Inc()
+		return false, err
+	}
+	ingressRules, err := ingress.ParseIngress(conf)
+	if err != nil {
+		localConfigReloads.WithLabelValues(reloadInvalid).Inc()
+		return false, errors.Wrap(err, "invalid ingress rules")
+	}
+	warpRouting := ingress.NewWarpRoutingConfig(&conf.WarpRouting)
+	if err := o.probeOrigins(ingressRules); err != nil {
+		closePlugins(ingressRules.Plugins(), o.log)
+		localConfigReloads.WithLabelValues(reloadFailed).Inc()
+		return false, err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.currentVersion >= 0 {
+		closePlugins(ingressRules.Plugins(), o.log)
+		localConfigReloads.WithLabelValues(reloadFailed).Inc()
+		return false, fmt.Errorf("the tunnel is configured remotely since version %d, the local configuration file doesn't apply", o.currentVersion)
+	}
+	// The configurations are compared with their secrets, so that rotating only a secret is applied
+	current, err := EffectiveConfigJSON(o.config.Ingress, o.config.WarpRouting)
+	if err != nil {
+		return false, err
+	}
+	reloaded, err := EffectiveConfigJSON(&ingressRules, warpRouting)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(current, reloaded) {
+		closePlugins(ingressRules.Plugins(), o.log)
+		localConfigReloads.WithLabelValues(reloadUnchanged).Inc()
+		return false, nil
+	}
+	if err := o.updateIngress(ingressRules, warpRouting); err != nil {
+		localConfigReloads.WithLabelValues(reloadFailed).Inc()
+		return false, err
+	}
+	localConfigReloads.WithLabelValues(reloadApplied).Inc()
+	return true, nil
+}
+
 // The caller is responsible to make sure there is no concurrent access
-func (o *Orchestrator) updateIngress(ingressRules ingress.Ingress, warpRouting ingress.WarpRoutingConfig) error {
+func (o *Orchestrator) updateIngress(ingressRules ingress.Ingress, warpRouting ingress.WarpRoutingConfig) (err error) {
+	// The plugins of rules that aren't applied are closed, unless the current proxy serves them too
+	defer func() {
+		if err != nil {
+			o.closeUnusedPlugins(ingressRules.WithGeneratedRules(o.generatedRules).Plugins())
+		}
+	}()
 	select {
 	case <-o.shutdownC:
 		return fmt.Errorf("cloudflared already shutdown")
 	default:
 	}
 
+	if i := ingressRules.GRPCRule(); i >= 0 && !o.config.TrailersSupported {
+		return fmt.Errorf("rule #%d proxies to a gRPC origin, which needs response trailers that only the %s protocol carries: run the tunnel with --protocol %s", i+1, connection.HTTP2, connection.HTTP2)
+	}
+
 	// Assign the internal ingress rules to the parsed ingress
 	ingressRules.InternalRules = o.internalRules
 
@@ -128,28 +241,107 @@ func (o *Orchestrator) updateIngress(ingressRules ingress.Ingress, warpRouting i
 	// The upside is we don't need to restart proxy from last version, which can fail
 	// The downside is new version might have ingress rule that require previous version to be shutdown first
 	// The downside is minimized because none of the ingress.OriginService implementation have that requirement
+	// The configuration keeps the user-defined rules only, the proxy serves the generated ones too
+	servedRules := ingressRules.WithGeneratedRules(o.generatedRules)
+
 	proxyShutdownC := make(chan struct{})
-	if err := ingressRules.StartOrigins(o.log, proxyShutdownC); err != nil {
+	if err := servedRules.StartOrigins(o.log, proxyShutdownC); err != nil {
 		return errors.Wrap(err, "failed to start origin")
 	}
-	proxy := proxy.NewOriginProxy(ingressRules, warpRouting, o.tags, o.log)
-	o.proxy.Store(proxy)
-	o.config.Ingress = &ingressRules
-	o.config.WarpRouting = warpRouting
-	if warpRouting.Enabled {
-		o.warpRoutingEnabled.Store(true)
-	} else {
-		o.warpRoutingEnabled.Store(false)
+	if o.config.WarmupOrigins {
+		if err := servedRules.WarmupOrigins(); err != nil {
+			close(proxyShutdownC)
+			return errors.Wrap(err, "failed to warm up origin")
+		}
 	}
+	originProxy := proxy.NewOriginProxy(servedRules, warpRouting, o.tags, o.log, proxy.Options{
+		Recorder:    o.config.Recorder,
+		Connections: o.connTracker,
+		Shedder:     o.config.LoadShedder,
+		Drainer:     o.config.Drainer,
+	})
+	o.applyIngress(originProxy, &ingressRules, warpRouting)
 
 	// If proxyShutdownC is nil, there is no previous running proxy
 	if o.proxyShutdownC != nil {
 		close(o.proxyShutdownC)
 	}
 	o.proxyShutdownC = proxyShutdownC
+	// The plugins of the previous rules that the new proxy doesn't serve are closed with the previous proxy
+	previousPlugins := o.plugins
+	o.plugins = servedRules.Plugins()
+	o.closeUnusedPlugins(previousPlugins)
 	return nil
 }
 
+// closeUnusedPlugins closes the plugins that the current proxy doesn't serve.
+// The caller is responsible to make sure there is no concurrent access
+func (o *Orchestrator) closeUnusedPlugins(plugins []middleware.Plugin) {
+	served := make(map[middleware.Plugin]bool, len(o.plugins))
+	for _, plugin := range o.plugins {
+		served[plugin] = true
+	}
+	var unused []middleware.Plugin
+	for _, plugin := range plugins {
+		if !served[plugin] {
+			unused = append(unused, plugin)
+		}
+	}
+	closePlugins(unused, o.log)
+}
+
+func closePlugins(plugins []middleware.Plugin, log *zerolog.Logger) {
+	for _, plugin := range plugins {
+		if err := plugin.Close(); err != nil {
+			log.Err(err).Msgf("Failed to close middleware plugin %s", plugin.Name())
+		}
+	}
+}
+
+// isNewVersion checks if version is newer than the current configuration.
+func (o *Orchestrator) isNewVersion(version int32) bool {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	return version > o.currentVersion
+}
+
+// probeOrigins checks that the origins of a configuration update accept connections if ProbeOrigins is set, so that
+// the current configuration is kept when they don't. It must be called without holding the lock.
+func (o *Orchestrator) probeOrigins(ingressRules ingress.Ingress) error {
+	if !o.config.ProbeOrigins {
+		return nil
+	}
+	if err := ingressRules.ProbeOrigins(); err != nil {
+		configRejections.Inc()
+		return errors.Wrap(err, "kept the current configuration")
+	}
+	return nil
+}
+
+// applyIngress swaps the proxy serving requests and the configuration it was created from.
+// The caller is responsible to make sure there is no concurrent access
+func (o *Orchestrator) applyIngress(p *proxy.Proxy, ingressRules *ingress.Ingress, warpRouting ingress.WarpRoutingConfig) {
+	o.proxy.Store(p)
+	o.config.Ingress = ingressRules
+	o.config.WarpRouting = warpRouting
+	o.warpRoutingEnabled.Store(warpRouting.Enabled)
+	fingerprint, err := ConfigFingerprint(ingressRules, warpRouting)
+	if err != nil {
+		o.log.Err(err).Msg("Failed to compute the fingerprint of the configuration")
+	}
+	o.fingerprint = fingerprint
+}
+
+// OriginHealth returns the result of the last health check of the origins of the current configuration.
+func (o *Orchestrator) OriginHealth() []ingress.OriginHealth {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	if o.config.Ingress == nil {
+		return nil
+	}
+	return o.config.Ingress.OriginHealth()
+}
+
 // GetConfigJSON returns the current json serialization of the config as the edge understands it
 func (o *Orchestrator) GetConfigJSON() ([]byte, error) {
 	o.lock.RLock()
@@ -171,42 +363,29 @@ func (o *Orchestrator) GetVersionedConfigJSON() ([]byte, error) {
 	o.lock.RLock()
 	defer o.lock.RUnlock()
 	var currentConfiguration = struct {
-		Version int32 `json:"version"`
-		Config  struct {
-			Ingress       []ingress.Rule              `json:"ingress"`
-			WarpRouting   config.WarpRoutingConfig    `json:"warp-routing"`
-			OriginRequest ingress.OriginRequestConfig `json:"originRequest"`
-		} `json:"config"`
+		Version int32             `json:"version"`
+		Config  ingressConfigJSON `json:"config"`
 	}{
 		Version: o.currentVersion,
-		Config: struct {
-			Ingress       []ingress.Rule              `json:"ingress"`
-			WarpRouting   config.WarpRoutingConfig    `json:"warp-routing"`
-			OriginRequest ingress.OriginRequestConfig `json:"originRequest"`
-		}{
-			Ingress:       o.config.Ingress.Rules,
-			WarpRouting:   o.config.WarpRouting.RawConfig(),
-			OriginRequest: o.config.Ingress.Defaults,
-		},
+		Config:  newIngressConfigJSON(o.config.Ingress, o.config.WarpRouting).redacted(),
 	}
 	return json.Marshal(currentConfiguration)
 }
 
 // GetOriginProxy returns an interface to proxy to origin. It satisfies connection.ConfigManager interface
 func (o *Orchestrator) GetOriginProxy() (connection.OriginProxy, error) {
-	val := o.proxy.Load()
-	if val == nil {
+	p := o.proxy.Load()
+	if p == nil {
 		err := fmt.Errorf("origin proxy not configured")
 		o.log.Error().Msg(err.Error())
 		return nil, err
 	}
-	proxy, ok := val.(connection.OriginProxy)
-	if !ok {
-		err := fmt.Errorf("origin proxy has unexpected value %+v", val)
-		o.log.Error().Msg(err.Error())
-		return nil, err
-	}
-	return proxy, nil
+	return p, nil
+}
+
+// ConnTracker returns the tracker of the connections to the edge, nil if the orchestrator has no observer.
+func (o *Orchestrator) ConnTracker() *tunnelstate.ConnTracker {
+	return o.connTracker
 }
 
 func (o *Orchestrator) WarpRoutingEnabled() bool {
@@ -222,4 +401,6 @@ func (o *Orchestrator) waitToCloseLastProxy() {
 		close(o.proxyShutdownC)
 		o.proxyShutdownC = nil
 	}
+	closePlugins(o.plugins, o.log)
+	o.plugins = nil
 }
@@ -9,7 +9,10 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -22,6 +25,7 @@ import (
 	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
 	"github.com/cloudflare/cloudflared/management"
 	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
@@ -210,6 +214,58 @@ func TestUpdateConfiguration_FromMigration(t *testing.T) {
 	require.Len(t, orchestrator.config.Ingress.Rules, 1)
 }
 
+// Validates that a configuration whose origins are unreachable is rejected, keeping the current one.
+func TestUpdateConfiguration_RejectUnreachableOrigin(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	defer origin.Close()
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachableAddr := closedListener.Addr().String()
+	require.NoError(t, closedListener.Close())
+
+	initConfig := &Config{
+		Ingress:      &ingress.Ingress{},
+		ProbeOrigins: true,
+	}
+	orchestrator, err := NewOrchestrator(context.Background(), initConfig, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+
+	configJSONV1 := []byte(fmt.Sprintf(`
+{
+    "ingress": [
+        {
+            "service": "%s"
+        }
+    ]
+}
+`, origin.URL))
+	updateWithValidation(t, orchestrator, 1, configJSONV1)
+	originProxyV1, err := orchestrator.GetOriginProxy()
+	require.NoError(t, err)
+
+	configJSONV2 := []byte(fmt.Sprintf(`
+{
+    "ingress": [
+        {
+            "service": "http://%s"
+        }
+    ],
+    "warp-routing": {
+        "enabled": true
+    }
+}
+`, unreachableAddr))
+	resp := orchestrator.UpdateConfig(2, configJSONV2)
+	require.Error(t, resp.Err)
+	require.Equal(t, int32(1), resp.LastAppliedVersion)
+
+	originProxy, err := orchestrator.GetOriginProxy()
+	require.NoError(t, err)
+	require.Equal(t, originProxyV1, originProxy)
+	require.Equal(t, origin.URL, orchestrator.config.Ingress.Rules[0].Service.String())
+	require.False(t, orchestrator.WarpRoutingEnabled())
+}
+
 // Validates that the default ingress rule will be set if there is no rule provided from the remote.
 func TestUpdateConfiguration_WithoutIngressRule(t *testing.T) {
 	initConfig := &Config{
@@ -232,6 +288,205 @@ func TestUpdateConfiguration_WithoutIngressRule(t *testing.T) {
 	require.Len(t, orchestrator.config.Ingress.Rules, 1)
 }
 
+// Validates that gRPC origins are only accepted when every connection to the edge carries trailers
+func TestUpdateConfiguration_GRPCNeedsTrailers(t *testing.T) {
+	orchestrator, err := NewOrchestrator(context.Background(), &Config{Ingress: &ingress.Ingress{}}, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+
+	grpcConfig := []byte(`
+{
+	"ingress": [
+		{
+			"service": "grpc://localhost:50051"
+		}
+	]
+}
+`)
+	resp := orchestrator.UpdateConfig(1, grpcConfig)
+	require.Error(t, resp.Err)
+	require.Contains(t, resp.Err.Error(), "--protocol http2")
+
+	// The gRPC origin may be one of the origins of a rule
+	groupedGRPCConfig := []byte(`
+{
+	"ingress": [
+		{
+			"service": [{"url": "http://localhost:8080"}, {"url": "grpc://localhost:50051"}]
+		}
+	]
+}
+`)
+	resp = orchestrator.UpdateConfig(2, groupedGRPCConfig)
+	require.Error(t, resp.Err)
+	require.Contains(t, resp.Err.Error(), "--protocol http2")
+
+	orchestrator.config.TrailersSupported = true
+	updateWithValidation(t, orchestrator, 3, grpcConfig)
+	updateWithValidation(t, orchestrator, 4, groupedGRPCConfig)
+}
+
+// Validates that generated rules are matched before the user-defined rules, and are kept across configuration updates
+func TestUpdateGeneratedRules(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer origin.Close()
+
+	initConfig := &Config{
+		Ingress: &ingress.Ingress{},
+	}
+	orchestrator, err := NewOrchestrator(context.Background(), initConfig, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+	updateWithValidation(t, orchestrator, 1, []byte(`{"ingress": [{"service": "http_status:404"}]}`))
+
+	require.NoError(t, orchestrator.UpdateGeneratedRules([]config.UnvalidatedIngressRule{
+		{Hostname: "app.example.com", Service: origin.URL},
+	}))
+	assertStatus := func(hostname string, status int) {
+		originProxy, err := orchestrator.GetOriginProxy()
+		require.NoError(t, err)
+		resp, err := proxyHTTP(originProxy, hostname)
+		require.NoError(t, err)
+		require.Equal(t, status, resp.StatusCode)
+	}
+	assertStatus("app.example.com", http.StatusTeapot)
+	assertStatus("other.example.com", http.StatusNotFound)
+	require.Len(t, orchestrator.config.Ingress.Rules, 1)
+
+	updateWithValidation(t, orchestrator, 2, []byte(`{"ingress": [{"service": "http_status:503"}]}`))
+	assertStatus("app.example.com", http.StatusTeapot)
+	assertStatus("other.example.com", http.StatusServiceUnavailable)
+
+	require.Error(t, orchestrator.UpdateGeneratedRules([]config.UnvalidatedIngressRule{
+		{Hostname: "app.example.com", Service: "http_status:42"},
+	}))
+	assertStatus("app.example.com", http.StatusTeapot)
+
+	// The user's rules take precedence over the generated ones
+	updateWithValidation(t, orchestrator, 3, []byte(`{"ingress": [{"hostname": "app.example.com", "service": "http_status:410"}, {"service": "http_status:503"}]}`))
+	assertStatus("app.example.com", http.StatusGone)
+
+	require.NoError(t, orchestrator.UpdateGeneratedRules(nil))
+	assertStatus("app.example.com", http.StatusGone)
+	assertStatus("other.example.com", http.StatusServiceUnavailable)
+}
+
+func TestReloadLocalConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0600))
+	}
+	writeConfig(`
+ingress:
+  - service: http_status:404
+`)
+	initConfig := &Config{
+		Ingress: &ingress.Ingress{},
+	}
+	orchestrator, err := NewOrchestrator(context.Background(), initConfig, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+	assertStatus := func(status int) {
+		originProxy, err := orchestrator.GetOriginProxy()
+		require.NoError(t, err)
+		resp, err := proxyHTTP(originProxy, "app.example.com")
+		require.NoError(t, err)
+		require.Equal(t, status, resp.StatusCode)
+	}
+
+	changed, err := orchestrator.ReloadLocalConfig(configFile, "")
+	require.NoError(t, err)
+	require.True(t, changed)
+	assertStatus(http.StatusNotFound)
+
+	changed, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Invalid rules keep the current ones
+	writeConfig(`
+ingress:
+  - hostname: app.example.com
+    service: http_status:503
+`)
+	_, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.Error(t, err)
+	assertStatus(http.StatusNotFound)
+
+	writeConfig(`
+ingress:
+  - service: http_status:503
+`)
+	changed, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.NoError(t, err)
+	require.True(t, changed)
+	assertStatus(http.StatusServiceUnavailable)
+
+	// Rotating a secret is a change, even though it's redacted where the configuration is shown
+	writeConfig(`
+ingress:
+  - service: http_status:503
+originRequest:
+  httpHeaders:
+    add:
+      Authorization: Bearer old-token
+`)
+	changed, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.NoError(t, err)
+	require.True(t, changed)
+	writeConfig(`
+ingress:
+  - service: http_status:503
+originRequest:
+  httpHeaders:
+    add:
+      Authorization: Bearer new-token
+`)
+	changed, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "Bearer new-token", orchestrator.config.Ingress.Defaults.HTTPHeaders.Add["Authorization"])
+
+	// The local configuration doesn't apply anymore once the tunnel is configured remotely
+	updateWithValidation(t, orchestrator, 1, []byte(`{"ingress": [{"service": "http_status:404"}]}`))
+	_, err = orchestrator.ReloadLocalConfig(configFile, "")
+	require.Error(t, err)
+	assertStatus(http.StatusNotFound)
+}
+
+func TestRemoteConfigCache(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "tunnel.config.json")
+	newOrchestrator := func() *Orchestrator {
+		initConfig := &Config{
+			Ingress:           &ingress.Ingress{},
+			RemoteConfigCache: cachePath,
+		}
+		orchestrator, err := NewOrchestrator(context.Background(), initConfig, testTags, []ingress.Rule{}, &testLogger)
+		require.NoError(t, err)
+		return orchestrator
+	}
+
+	_, err := newOrchestrator().LoadCachedRemoteConfig()
+	require.Error(t, err)
+
+	updateWithValidation(t, newOrchestrator(), 3, []byte(`{"ingress": [{"service": "http_status:418"}]}`))
+
+	orchestrator := newOrchestrator()
+	version, err := orchestrator.LoadCachedRemoteConfig()
+	require.NoError(t, err)
+	require.Equal(t, int32(3), version)
+	originProxy, err := orchestrator.GetOriginProxy()
+	require.NoError(t, err)
+	resp, err := proxyHTTP(originProxy, "app.example.com")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTeapot, resp.StatusCode)
+
+	// Newer versions pushed by the edge replace the cache
+	updateWithValidation(t, orchestrator, 4, []byte(`{"ingress": [{"service": "http_status:503"}]}`))
+	version, err = newOrchestrator().LoadCachedRemoteConfig()
+	require.NoError(t, err)
+	require.Equal(t, int32(4), version)
+}
+
 // TestConcurrentUpdateAndRead makes sure orchestrator can receive updates and return origin proxy concurrently
 func TestConcurrentUpdateAndRead(t *testing.T) {
 	const (
@@ -586,6 +841,79 @@ func TestClosePreviousProxies(t *testing.T) {
 	require.Nil(t, resp)
 }
 
+// closeTrackingPlugin records whether it was closed
+type closeTrackingPlugin struct {
+	closed *atomic.Bool
+}
+
+func (p closeTrackingPlugin) Name() string { return "orchestration-test-close" }
+
+func (p closeTrackingPlugin) Handle(context.Context, *http.Request) (*middleware.HandleResult, error) {
+	return &middleware.HandleResult{}, nil
+}
+
+func (p closeTrackingPlugin) HandleResponseHeaders(context.Context, *http.Request, int, http.Header) error {
+	return nil
+}
+
+func (p closeTrackingPlugin) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return chunk, nil
+}
+
+func (p closeTrackingPlugin) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+// TestClosePlugins makes sure the middleware plugins are closed once no proxy serves the rules they were created for
+func TestClosePlugins(t *testing.T) {
+	var closedLock sync.Mutex
+	closed := make(map[string]*atomic.Bool)
+	middleware.RegisterPlugin("orchestration-test-close", func(cfg map[string]interface{}) (middleware.Plugin, error) {
+		closedLock.Lock()
+		defer closedLock.Unlock()
+		id, _ := cfg["id"].(string)
+		closed[id] = new(atomic.Bool)
+		return closeTrackingPlugin{closed: closed[id]}, nil
+	})
+	isClosed := func(id string) bool {
+		closedLock.Lock()
+		defer closedLock.Unlock()
+		return closed[id].Load()
+	}
+	configWithPlugin := func(id, service string) []byte {
+		return []byte(fmt.Sprintf(`{"ingress": [{"service": %q, "originRequest": {"plugins": [{"name": "orchestration-test-close", "config": {"id": %q}}]}}]}`, service, id))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	orchestrator, err := NewOrchestrator(ctx, &Config{Ingress: &ingress.Ingress{}}, testTags, []ingress.Rule{}, &testLogger)
+	require.NoError(t, err)
+
+	updateWithValidation(t, orchestrator, 1, configWithPlugin("v1", "http_status:404"))
+	require.False(t, isClosed("v1"))
+
+	// Replacing the generated rules keeps serving the rules of the configuration
+	require.NoError(t, orchestrator.UpdateGeneratedRules([]config.UnvalidatedIngressRule{
+		{Hostname: "app.example.com", Service: "http_status:418"},
+	}))
+	require.False(t, isClosed("v1"))
+
+	// The plugins of a configuration that isn't applied are closed
+	resp := orchestrator.UpdateConfig(2, configWithPlugin("rejected", "grpc://localhost:50051"))
+	require.Error(t, resp.Err)
+	require.True(t, isClosed("rejected"))
+	require.False(t, isClosed("v1"))
+
+	// The plugins of the previous configuration are closed when it's replaced
+	updateWithValidation(t, orchestrator, 3, configWithPlugin("v3", "http_status:404"))
+	require.True(t, isClosed("v1"))
+	require.False(t, isClosed("v3"))
+
+	// The plugins of the last configuration are closed on shutdown
+	cancel()
+	require.Eventually(t, func() bool { return isClosed("v3") }, time.Second, 10*time.Millisecond)
+}
+
 // TestPersistentConnection makes sure updating the ingress doesn't intefere with existing connections
 func TestPersistentConnection(t *testing.T) {
 	const (
@@ -0,0 +1,52 @@
+package orchestration
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/cfio"
+)
+
+// cachedRemoteConfig is the last remote configuration applied, as it's cached on disk.
+type cachedRemoteConfig struct {
+	Version int32           `json:"version"`
+	Config  json.RawMessage `json:"config"`
+}
+
+// cacheRemoteConfig writes the remote configuration that was just applied to the cache file, if there's one, so that
+// the next run can start with it when the Cloudflare API can't be reached.
+// The caller is responsible to make sure there is no concurrent access
+func (o *Orchestrator) cacheRemoteConfig(version int32, config []byte) {
+	if o.config.RemoteConfigCache == "" {
+		return
+	}
+	content, err := json.Marshal(cachedRemoteConfig{Version: version, Config: config})
+	if err == nil {
+		err = cfio.WriteFileAtomically(o.config.RemoteConfigCache, content)
+	}
+	if err != nil {
+		o.log.Err(err).Msgf("Failed to cache the remote configuration in %s", o.config.RemoteConfigCache)
+	}
+}
+
+// LoadCachedRemoteConfig applies the remote configuration cached by a previous run, and returns its version. It's
+// meant for when the Cloudflare API can't be reached at startup: the edge pushes newer versions once connected.
+func (o *Orchestrator) LoadCachedRemoteConfig() (int32, error) {
+	if o.config.RemoteConfigCache == "" {
+		return 0, errors.New("there is no cache of the remote configuration")
+	}
+	content, err := os.ReadFile(o.config.RemoteConfigCache)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read the cached remote configuration")
+	}
+	var cached cachedRemoteConfig
+	if err := json.Unmarshal(content, &cached); err != nil {
+		return 0, errors.Wrapf(err, "invalid cached remote configuration in %s", o.config.RemoteConfigCache)
+	}
+	if resp := o.UpdateConfig(cached.Version, cached.Config); resp.Err != nil {
+		return 0, resp.Err
+	}
+	return cached.Version, nil
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	requestBodyKind  = "request"
+	responseBodyKind = "response"
+)
+
+// bodyTooLargeError is returned when a body exceeds the maxRequestBodySize or maxResponseBodySize of its rule.
+type bodyTooLargeError struct {
+	body  string
+	limit int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	option := "maxRequestBodySize"
+	if e.body == responseBodyKind {
+		option = "maxResponseBodySize"
+	}
+	return fmt.Sprintf("%s body is larger than the %s of %d bytes", e.body, option, e.limit)
+}
+
+func newBodyTooLargeError(body string, limit int64) *bodyTooLargeError {
+	oversizedBodies.WithLabelValues(body).Inc()
+	return &bodyTooLargeError{body: body, limit: limit}
+}
+
+// limitedBody fails reads once more than limit bytes have been read, after returning the first limit bytes.
+type limitedBody struct {
+	io.ReadCloser
+	body      string
+	limit     int64
+	remaining int64
+	err       error
+}
+
+func newLimitedBody(body io.ReadCloser, kind string, limit int64) *limitedBody {
+	return &limitedBody{ReadCloser: body, body: kind, limit: limit, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// Read one byte more than allowed to tell a body of exactly limit bytes from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.err = newBodyTooLargeError(b.body, b.limit)
+		return n, b.err
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// limitRequestBody rejects requests that announce a body larger than limit, and limits the others. The returned
+// body, if any, tells whether the limit was exceeded while the request was sent to the origin.
+func limitRequestBody(req *http.Request, limit int64) (*limitedBody, error) {
+	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if announcesTooLargeBody(req, limit) {
+		return nil, newBodyTooLargeError(requestBodyKind, limit)
+	}
+	body := newLimitedBody(req.Body, requestBodyKind, limit)
+	req.Body = body
+	return body, nil
+}
+
+// announcesTooLargeBody tells whether the Content-Length of the request is over limit, so it can be rejected before
+// any of its body is read.
+func announcesTooLargeBody(req *http.Request, limit int64) bool {
+	return limit > 0 && req.ContentLength > limit
+}
+
+// limitResponseBody fails responses that announce a body larger than limit, and cuts the others once they exceed it.
+func limitResponseBody(resp *http.Response, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	if resp.ContentLength > limit {
+		return newBodyTooLargeError(responseBodyKind, limit)
+	}
+	resp.Body = newLimitedBody(resp.Body, responseBodyKind, limit)
+	return nil
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+func TestProxyBodySizeLimits(t *testing.T) {
+	var originRequests int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&originRequests, 1)
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			return
+		}
+		switch r.URL.Path {
+		case "/announced":
+			w.Header().Set("Content-Length", "20")
+			_, _ = w.Write([]byte(strings.Repeat("a", 20)))
+		case "/streamed":
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(strings.Repeat("a", 20)))
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer origin.Close()
+
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: http.DefaultTransport},
+				Config:  ingress.OriginRequestConfig{MaxRequestBodySize: 10, MaxResponseBodySize: 10},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+	proxyRequest := func(path string, body io.Reader) (*mockHTTPRespWriter, error) {
+		req, err := http.NewRequest(http.MethodPost, origin.URL+path, body)
+		require.NoError(t, err)
+		if req.ContentLength == 0 && body != nil {
+			// Like the requests received from the edge, the ones with a body of unknown length are chunked
+			req.ContentLength = -1
+		}
+		w := newMockHTTPRespWriter()
+		return w, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false)
+	}
+
+	w, err := proxyRequest("/", strings.NewReader("small"))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&originRequests))
+
+	// A request announcing a large body never reaches the origin
+	w, err = proxyRequest("/", strings.NewReader(strings.Repeat("a", 11)))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&originRequests))
+
+	// A chunked request is rejected once it exceeds the limit
+	w, err = proxyRequest("/", io.MultiReader(strings.NewReader(strings.Repeat("a", 8)), strings.NewReader(strings.Repeat("a", 8))))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// A response announcing a large body is replaced by an error before anything is written
+	w, err = proxyRequest("/announced", nil)
+	assert.EqualError(t, err, "response body is larger than the maxResponseBodySize of 10 bytes")
+	assert.False(t, w.Flushed)
+	assert.Empty(t, w.Body.String())
+
+	// A streamed response is cut once it exceeds the limit
+	w, err = proxyRequest("/streamed", nil)
+	assert.EqualError(t, err, "response body is larger than the maxResponseBodySize of 10 bytes")
+	assert.Equal(t, strings.Repeat("a", 10), w.Body.String())
+}
+
+func TestProxyBodySizeLimitsSkipMirror(t *testing.T) {
+	requests := make(chan mirroredRequest, 1)
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: echoBodyTransport{}},
+				Mirror: &ingress.Mirror{
+					Service:      ingress.MockOriginHTTPService{Transport: recordingTransport{requests: requests}},
+					Percentage:   100,
+					Timeout:      time.Second,
+					MaxBodyBytes: 100,
+				},
+				Config: ingress.OriginRequestConfig{MaxRequestBodySize: 10},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	for _, body := range []io.Reader{
+		strings.NewReader(strings.Repeat("a", 11)),
+		io.MultiReader(strings.NewReader(strings.Repeat("a", 8)), strings.NewReader(strings.Repeat("a", 8))),
+	} {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/upload", body)
+		require.NoError(t, err)
+		if req.ContentLength == 0 {
+			req.ContentLength = -1
+		}
+		w := newMockHTTPRespWriter()
+		require.NoError(t, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	}
+	select {
+	case mirrored := <-requests:
+		t.Fatalf("unexpected mirrored request %+v", mirrored)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// The gRPC status codes that cloudflared ends gRPC calls with when it can't proxy them.
+const (
+	grpcStatusCancelled         = 1
+	grpcStatusDeadlineExceeded  = 4
+	grpcStatusResourceExhausted = 8
+	grpcStatusUnavailable       = 14
+
+	grpcStatusHeader  = "Grpc-Status"
+	grpcMessageHeader = "Grpc-Message"
+)
+
+// grpcStatusError is returned once a gRPC request that couldn't be proxied has been answered with a gRPC status.
+type grpcStatusError struct {
+	err error
+}
+
+func (e *grpcStatusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *grpcStatusError) Unwrap() error {
+	return e.err
+}
+
+// writeGRPCError answers a gRPC request that couldn't be proxied with a trailers-only response, whose headers carry
+// the status of the call: gRPC clients expect a status rather than an HTTP error. Unlike trailers, headers reach the
+// clients over every protocol.
+func writeGRPCError(w connection.ResponseWriter, err error) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/grpc")
+	header.Set(grpcStatusHeader, strconv.Itoa(grpcStatus(err)))
+	header.Set(grpcMessageHeader, encodeGRPCMessage(err.Error()))
+	return w.WriteRespHeaders(http.StatusOK, header)
+}
+
+// endGRPCStream ends a gRPC response whose body couldn't be read from the origin with an UNAVAILABLE status, so the
+// call fails with a status rather than a missing one.
+func endGRPCStream(w connection.ResponseWriter, err error) {
+	w.AddTrailer(grpcStatusHeader, strconv.Itoa(grpcStatusUnavailable))
+	w.AddTrailer(grpcMessageHeader, encodeGRPCMessage("origin stream ended abruptly: "+err.Error()))
+}
+
+func grpcStatus(err error) int {
+	var tooLarge *bodyTooLargeError
+	var timedOut *requestTimeoutError
+	switch {
+	case errors.As(err, &tooLarge):
+		return grpcStatusResourceExhausted
+	case errors.As(err, &timedOut), errors.Is(err, context.DeadlineExceeded):
+		return grpcStatusDeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return grpcStatusCancelled
+	default:
+		return grpcStatusUnavailable
+	}
+}
+
+// encodeGRPCMessage percent-encodes a grpc-message like gRPC does: printable ASCII characters other than % are kept.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+// trailerRecorder records the trailers that mockHTTPRespWriter ignores.
+type trailerRecorder struct {
+	*mockHTTPRespWriter
+	trailers http.Header
+}
+
+func (w *trailerRecorder) AddTrailer(trailerName, trailerValue string) {
+	w.trailers.Add(trailerName, trailerValue)
+}
+
+type failingBody struct{}
+
+func (failingBody) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func (failingBody) Close() error {
+	return nil
+}
+
+type grpcTransport struct{}
+
+func (grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Path {
+	case "/unreachable":
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	case "/reset":
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/grpc"}},
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader("partial"), failingBody{})),
+		}, nil
+	default:
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	}
+}
+
+func TestProxyGRPCErrors(t *testing.T) {
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{{
+			Service: ingress.MockOriginHTTPService{Transport: grpcTransport{}},
+			Config:  ingress.OriginRequestConfig{MaxRequestBodySize: 5},
+		}},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	tests := []struct {
+		name             string
+		path             string
+		body             string
+		expectedStatus   string
+		expectedTrailers http.Header
+	}{
+		{
+			name:           "origin unreachable",
+			path:           "/unreachable",
+			expectedStatus: "14",
+		},
+		{
+			name:           "request too large",
+			path:           "/",
+			body:           "too large",
+			expectedStatus: "8",
+		},
+		{
+			name: "origin stream reset",
+			path: "/reset",
+			expectedTrailers: http.Header{
+				"Grpc-Status":  {"14"},
+				"Grpc-Message": {"origin stream ended abruptly: connection reset by peer"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body io.Reader
+			if test.body != "" {
+				body = strings.NewReader(test.body)
+			}
+			req, err := http.NewRequest(http.MethodPost, "http://example.com"+test.path, body)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/grpc")
+			w := &trailerRecorder{mockHTTPRespWriter: newMockHTTPRespWriter(), trailers: http.Header{}}
+			err = proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/grpc", w.Header().Get("Content-Type"))
+			if test.expectedTrailers != nil {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedTrailers, w.trailers)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, w.Header().Get("Grpc-Status"))
+			assert.NotEmpty(t, w.Header().Get("Grpc-Message"))
+		})
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	assert.Equal(t, "origin is 100%25 down", encodeGRPCMessage("origin is 100% down"))
+	assert.Equal(t, "caf%C3%A9%0A", encodeGRPCMessage("café\n"))
+}
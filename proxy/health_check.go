@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// serveUnhealthyOrigin answers the requests of a rule that has no healthy origin right away, with a 503 and the
+// error page of its health check, instead of waiting for the connection to the origin to fail.
+func serveUnhealthyOrigin(w connection.ResponseWriter, healthCheck *ingress.HealthCheck) error {
+	unhealthyOriginRequests.Inc()
+	header := http.Header{}
+	header.Set("Content-Type", http.DetectContentType(healthCheck.ErrorPage))
+	header.Set("Content-Length", strconv.Itoa(len(healthCheck.ErrorPage)))
+	// The origin might be healthy again after its next check
+	header.Set("Retry-After", strconv.Itoa(int(math.Ceil(healthCheck.Interval.Seconds()))))
+	if err := w.WriteRespHeaders(http.StatusServiceUnavailable, header); err != nil {
+		return err
+	}
+	_, err := w.Write(healthCheck.ErrorPage)
+	return err
+}
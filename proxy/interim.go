@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// forwardInterimResponses returns req with a client trace that forwards the informational responses of the origin,
+// such as 103 Early Hints, to the eyeball before the final response. 100 Continue concerns only the connection to
+// the origin, so it isn't forwarded.
+func (p *Proxy) forwardInterimResponses(req *http.Request, w connection.ResponseWriter) *http.Request {
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(status int, header textproto.MIMEHeader) error {
+			if status == http.StatusContinue {
+				return nil
+			}
+			// Interim responses are hints, failing to forward one mustn't fail the request
+			if err := w.WriteInterimResponse(status, http.Header(header)); err != nil {
+				p.log.Debug().Err(err).Int("status", status).Msg("Failed to forward interim response")
+			}
+			return nil
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
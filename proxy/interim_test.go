@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type interimRespWriter struct {
+	*mockHTTPRespWriter
+	statuses []int
+	headers  []http.Header
+}
+
+func (w *interimRespWriter) WriteInterimResponse(status int, header http.Header) error {
+	w.statuses = append(w.statuses, status)
+	w.headers = append(w.headers, header)
+	return nil
+}
+
+func TestProxyForwardsEarlyHints(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("page"))
+	}))
+	defer origin.Close()
+
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{Service: ingress.MockOriginHTTPService{Transport: http.DefaultTransport}},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	responseWriter := &interimRespWriter{mockHTTPRespWriter: newMockHTTPRespWriter()}
+	req, err := http.NewRequest(http.MethodGet, origin.URL, nil)
+	require.NoError(t, err)
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+
+	assert.Equal(t, []int{http.StatusEarlyHints}, responseWriter.statuses)
+	assert.Equal(t, "</style.css>; rel=preload; as=style", responseWriter.headers[0].Get("Link"))
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "page", responseWriter.Body.String())
+}
@@ -35,6 +35,15 @@ var (
 		},
 		[]string{"status_code"},
 	)
+	requestsByRule = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "requests_by_rule",
+			Help:      "Count of HTTP requests by the index of the ingress rule they matched",
+		},
+		[]string{"rule"},
+	)
 	requestErrors = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: connection.MetricsNamespace,
@@ -43,6 +52,65 @@ var (
 			Help:      "Count of error proxying to origin",
 		},
 	)
+	shedRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "shed_requests",
+			Help:      "Count of requests rejected because cloudflared was overloaded",
+		},
+	)
+	mirroredRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "mirrored_requests",
+			Help:      "Count of requests mirrored to shadow origins, by result",
+		},
+		[]string{"result"},
+	)
+	oversizedBodies = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "oversized_bodies",
+			Help:      "Count of request and response bodies larger than the limit of their ingress rule",
+		},
+		[]string{"body"},
+	)
+	unhealthyOriginRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "unhealthy_origin_requests",
+			Help:      "Count of requests answered with a 503 because no origin of their ingress rule was healthy",
+		},
+	)
+	rateLimitedRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "rate_limited_requests",
+			Help:      "Count of requests answered with a 429 because their client exceeded the rate limit of their ingress rule",
+		},
+	)
+	retriedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "origin_retries",
+			Help:      "Count of requests sent to the origin again, by the retryOn condition that the previous attempt met",
+		},
+		[]string{"reason"},
+	)
+	timedOutRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "timed_out_requests",
+			Help:      "Count of requests canceled because they exceeded the requestTimeout of their ingress rule",
+		},
+	)
 	activeTCPSessions = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: connection.MetricsNamespace,
@@ -59,6 +127,15 @@ var (
 			Help:      "Total count of TCP sessions that have been proxied to any origin",
 		},
 	)
+	activeUDPSessionsByRule = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: "udp",
+			Name:      "active_sessions_by_rule",
+			Help:      "Concurrent count of UDP sessions proxied to the udp:// service of each ingress rule",
+		},
+		[]string{"rule"},
+	)
 )
 
 func init() {
@@ -66,9 +143,18 @@ func init() {
 		totalRequests,
 		concurrentRequests,
 		responseByCode,
+		requestsByRule,
 		requestErrors,
+		mirroredRequests,
+		shedRequests,
+		oversizedBodies,
+		timedOutRequests,
+		unhealthyOriginRequests,
+		rateLimitedRequests,
+		retriedRequests,
 		activeTCPSessions,
 		totalTCPSessions,
+		activeUDPSessionsByRule,
 	)
 }
 
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
+)
+
+// responseHandlers returns the middleware of rule that wants to see responses from the origin.
+func responseHandlers(rule *ingress.Rule) []middleware.ResponseHandler {
+	var handlers []middleware.ResponseHandler
+	for _, handler := range rule.Handlers {
+		if responseHandler, ok := handler.(middleware.ResponseHandler); ok {
+			handlers = append(handlers, responseHandler)
+		}
+	}
+	return handlers
+}
+
+func applyResponseHeaderMiddleware(handlers []middleware.ResponseHandler, req *http.Request, status int, header http.Header) error {
+	for _, handler := range handlers {
+		if err := handler.HandleResponseHeaders(req.Context(), req, status, header); err != nil {
+			return err
+		}
+	}
+	if rewritesBody(handlers) {
+		// The body can be rewritten, so the origin's content length no longer applies.
+		header.Del("Content-Length")
+	}
+	return nil
+}
+
+// rewritesBody checks if any of the handlers wants to see the response body.
+func rewritesBody(handlers []middleware.ResponseHandler) bool {
+	for _, handler := range handlers {
+		if maxBodyBytes(handler) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// responseBodyMiddlewareWriter passes each chunk of the response body through the response handlers before writing
+// it to the eyeball. Handlers only see as much of the body as their limit allows, the rest is written unmodified.
+type responseBodyMiddlewareWriter struct {
+	w        io.Writer
+	ctx      context.Context
+	req      *http.Request
+	handlers []middleware.ResponseHandler
+	seen     []int
+}
+
+func newResponseBodyMiddlewareWriter(w io.Writer, req *http.Request, handlers []middleware.ResponseHandler) io.Writer {
+	if !rewritesBody(handlers) {
+		return w
+	}
+	return &responseBodyMiddlewareWriter{
+		w:        w,
+		ctx:      req.Context(),
+		req:      req,
+		handlers: handlers,
+		seen:     make([]int, len(handlers)),
+	}
+}
+
+func (w *responseBodyMiddlewareWriter) Write(p []byte) (int, error) {
+	chunk := p
+	for i, handler := range w.handlers {
+		remaining := maxBodyBytes(handler) - w.seen[i]
+		if remaining <= 0 {
+			continue
+		}
+		head, tail := chunk, []byte(nil)
+		if len(chunk) > remaining {
+			head, tail = chunk[:remaining], chunk[remaining:]
+		}
+		w.seen[i] += len(head)
+		out, err := handler.HandleResponseBody(w.ctx, w.req, head)
+		if err != nil {
+			return 0, err
+		}
+		if len(tail) > 0 {
+			out = append(append([]byte(nil), out...), tail...)
+		}
+		chunk = out
+	}
+	if len(chunk) > 0 {
+		if _, err := w.w.Write(chunk); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func maxBodyBytes(handler middleware.ResponseHandler) int {
+	if limiter, ok := handler.(middleware.BodyLimiter); ok {
+		return limiter.MaxBodyBytes()
+	}
+	return middleware.DefaultPluginMaxBodyBytes
+}
+
+// middlewareResponseWriter runs the response middleware of a rule on the responses that don't come from an origin:
+// the ones of local services such as hello_world, http_status and file://, and the ones cloudflared answers with
+// itself, such as errors. Responses of origins go through the middleware in proxyHTTPRequest.
+type middlewareResponseWriter struct {
+	connection.ResponseWriter
+	req         *http.Request
+	handlers    []middleware.ResponseHandler
+	body        io.Writer
+	wroteHeader bool
+	err         error
+}
+
+func newMiddlewareResponseWriter(w connection.ResponseWriter, req *http.Request, handlers []middleware.ResponseHandler) connection.ResponseWriter {
+	if len(handlers) == 0 {
+		return w
+	}
+	return &middlewareResponseWriter{ResponseWriter: w, req: req, handlers: handlers, body: w}
+}
+
+func (w *middlewareResponseWriter) WriteRespHeaders(status int, header http.Header) error {
+	w.wroteHeader = true
+	// Like the responses of origins, upgraded connections are streams that middleware doesn't apply to
+	if status == http.StatusSwitchingProtocols {
+		return w.ResponseWriter.WriteRespHeaders(status, header)
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	if err := applyResponseHeaderMiddleware(w.handlers, w.req, status, header); err != nil {
+		w.err = errors.Wrap(err, "Error processing response headers")
+		_ = w.ResponseWriter.WriteRespHeaders(http.StatusBadGateway, nil)
+		return w.err
+	}
+	w.body = newResponseBodyMiddlewareWriter(w.ResponseWriter, w.req, w.handlers)
+	return w.ResponseWriter.WriteRespHeaders(status, header)
+}
+
+func (w *middlewareResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	_ = w.WriteRespHeaders(status, w.ResponseWriter.Header())
+}
+
+func (w *middlewareResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.body.Write(p)
+}
+
+func (w *middlewareResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type upperCasePlugin struct{}
+
+func (upperCasePlugin) Name() string { return "upper-case" }
+
+func (upperCasePlugin) Handle(_ context.Context, r *http.Request) (*middleware.HandleResult, error) {
+	if r.Header.Get("X-Deny") != "" {
+		return &middleware.HandleResult{ShouldFilterRequest: true, StatusCode: http.StatusForbidden, Reason: "denied"}, nil
+	}
+	return nil, nil
+}
+
+func (upperCasePlugin) HandleResponseHeaders(_ context.Context, _ *http.Request, _ int, header http.Header) error {
+	header.Set("X-Plugin", "upper-case")
+	return nil
+}
+
+func (upperCasePlugin) HandleResponseBody(_ context.Context, _ *http.Request, chunk []byte) ([]byte, error) {
+	return bytes.ToUpper(chunk), nil
+}
+
+func (upperCasePlugin) Close() error { return nil }
+
+type bodyOriginTransport struct {
+	body string
+}
+
+func (t bodyOriginTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Length": {"11"}},
+		Body:          io.NopCloser(strings.NewReader(t.body)),
+		ContentLength: int64(len(t.body)),
+	}, nil
+}
+
+func init() {
+	middleware.RegisterPlugin("upper-case", func(map[string]interface{}) (middleware.Plugin, error) {
+		return upperCasePlugin{}, nil
+	})
+}
+
+func TestProxyPlugin(t *testing.T) {
+	plugin, err := middleware.NewPlugin("upper-case", nil, middleware.PluginLimits{MaxBodyBytes: 5, Timeout: time.Second})
+	require.NoError(t, err)
+
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Hostname: "*",
+				Service: ingress.MockOriginHTTPService{
+					Transport: bodyOriginTransport{body: "hello world"},
+				},
+				Handlers: []middleware.Handler{plugin},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	responseWriter := newMockHTTPRespWriter()
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, "upper-case", responseWriter.Header().Get("X-Plugin"))
+	assert.Empty(t, responseWriter.Header().Get("Content-Length"))
+	assert.Equal(t, "HELLO world", responseWriter.Body.String())
+
+	responseWriter = newMockHTTPRespWriter()
+	req, err = http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Deny", "true")
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusForbidden, responseWriter.Code)
+}
+
+func TestProxyFilters(t *testing.T) {
+	rawYAML := `
+ingress:
+  - service: http://localhost:8000
+    filters:
+      - expression: request.headers["cf-ipcountry"] == "ZZ"
+        action: deny
+        status: 451
+      - expression: request.path.startsWith("/v2/")
+        action: route
+        service: http://localhost:8001
+`
+	var cfg config.Configuration
+	require.NoError(t, yaml.Unmarshal([]byte(rawYAML), &cfg))
+	ing, err := ingress.ParseIngress(&cfg)
+	require.NoError(t, err)
+	// Replace the origins with mocks that tell which one handled the request
+	ing.Rules[0].Service = ingress.MockOriginHTTPService{Transport: bodyOriginTransport{body: "v1"}}
+	ing.Rules[0].Filters[1].Service = ingress.MockOriginHTTPService{Transport: bodyOriginTransport{body: "v2"}}
+
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	tests := []struct {
+		path         string
+		country      string
+		expectStatus int
+		expectBody   string
+	}{
+		{path: "/v1/users", country: "NZ", expectStatus: http.StatusOK, expectBody: "v1"},
+		{path: "/v2/users", country: "NZ", expectStatus: http.StatusOK, expectBody: "v2"},
+		{path: "/v2/users", country: "ZZ", expectStatus: http.StatusUnavailableForLegalReasons},
+		// The deny filter can't be evaluated without the header, so the request is denied
+		{path: "/v1/users", expectStatus: http.StatusUnavailableForLegalReasons},
+	}
+	for _, test := range tests {
+		responseWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodGet, "http://example.com"+test.path, nil)
+		require.NoError(t, err)
+		if test.country != "" {
+			req.Header.Set("Cf-Ipcountry", test.country)
+		}
+		require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		assert.Equal(t, test.expectStatus, responseWriter.Code)
+		assert.Equal(t, test.expectBody, responseWriter.Body.String())
+	}
+}
+
+func TestProxyResponseMiddlewareForLocalResponses(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "index.html"), []byte("hello"), 0600))
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "limited.example.com",
+				Service:  "http_status:200",
+				OriginRequest: config.OriginRequestConfig{
+					RateLimit:          &config.RateLimitConfig{RequestsPerSecond: 0.5, Burst: 1},
+					SetResponseHeaders: map[string]string{"X-Frame-Options": "DENY"},
+				},
+			},
+			{
+				Service: "file://" + root,
+				OriginRequest: config.OriginRequestConfig{
+					SetResponseHeaders: map[string]string{"X-Frame-Options": "DENY"},
+					Plugins:            []config.PluginConfig{{Name: "upper-case"}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ing.StartOrigins(&zerolog.Logger{}, make(chan struct{})))
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	proxyRequest := func(host string) *mockHTTPRespWriter {
+		req, err := http.NewRequest(http.MethodGet, "http://"+host, nil)
+		require.NoError(t, err)
+		w := newMockHTTPRespWriter()
+		require.NoError(t, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		return w
+	}
+
+	// Responses of local services
+	w := proxyRequest("example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "upper-case", w.Header().Get("X-Plugin"))
+	assert.Equal(t, "HELLO", w.Body.String())
+
+	// Responses cloudflared generates
+	assert.Equal(t, http.StatusOK, proxyRequest("limited.example.com").Code)
+	w = proxyRequest("limited.example.com")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+}
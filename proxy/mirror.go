@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+const (
+	mirrorResultSent    = "sent"
+	mirrorResultError   = "error"
+	mirrorResultSkipped = "skipped"
+)
+
+var (
+	errMirrorBodyTooLarge   = errors.New("the request body is too large to be mirrored")
+	errMirrorBodyIncomplete = errors.New("the primary origin didn't read the whole request body")
+)
+
+// mirrorRequest sends a copy of req to the rule's shadow origin in the background. The body is copied to the shadow
+// origin as the primary origin reads it, without ever making it wait: the copy is aborted if its body exceeds the
+// mirror's limit, so that at most that much is held for a shadow origin that lags behind. Requests that announce a
+// larger body, or beyond the concurrent mirrored requests of the mirror, aren't mirrored. It must be called before
+// req is proxied to the primary origin, which modifies it. Requests whose body is over the maxRequestBodySize of the
+// rule aren't mirrored either, since the primary origin rejects them.
+func (p *Proxy) mirrorRequest(mirror *ingress.Mirror, req *http.Request, cfRay string, maxRequestBodySize int64) {
+	shadowProxy, ok := mirror.Service.(ingress.HTTPOriginProxy)
+	if !ok {
+		return
+	}
+	maxBodyBytes := mirror.MaxBodyBytes
+	if maxRequestBodySize > 0 && maxRequestBodySize < maxBodyBytes {
+		maxBodyBytes = maxRequestBodySize
+	}
+	if req.ContentLength > maxBodyBytes || !mirror.Acquire() {
+		mirroredRequests.WithLabelValues(mirrorResultSkipped).Inc()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirror.Timeout)
+	shadowReq := req.Clone(ctx)
+	shadowReq.Body = http.NoBody
+	var body *mirrorBody
+	if req.Body != nil && req.Body != http.NoBody {
+		body = newMirrorBody(maxBodyBytes)
+		req.Body = &teeBody{ReadCloser: req.Body, mirror: body}
+		shadowReq.Body = body
+	}
+
+	go func() {
+		defer mirror.Release()
+		defer cancel()
+		resp, err := shadowProxy.RoundTrip(shadowReq)
+		if body != nil {
+			_ = body.Close()
+		}
+		if err != nil {
+			if errors.Is(err, errMirrorBodyTooLarge) {
+				mirroredRequests.WithLabelValues(mirrorResultSkipped).Inc()
+				return
+			}
+			mirroredRequests.WithLabelValues(mirrorResultError).Inc()
+			p.log.Debug().Err(err).Str(LogFieldCFRay, cfRay).Msgf("Failed to mirror request to %s", mirror.Service)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		mirroredRequests.WithLabelValues(mirrorResultSent).Inc()
+	}()
+}
+
+// teeBody is the request body read by the primary origin, which copies what is read to the mirrored request.
+type teeBody struct {
+	io.ReadCloser
+	mirror *mirrorBody
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.mirror.write(p[:n])
+	}
+	if err != nil {
+		b.mirror.finish(err)
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	b.mirror.finish(errMirrorBodyIncomplete)
+	return b.ReadCloser.Close()
+}
+
+// mirrorBody is the body of a mirrored request, which is what the primary origin read of the original body. Writes
+// never block: once more than max bytes are written, the body fails with errMirrorBodyTooLarge instead.
+type mirrorBody struct {
+	lock  sync.Mutex
+	ready *sync.Cond
+	buf   bytes.Buffer
+	// written is how much of the original body was written so far.
+	written int64
+	max     int64
+	// err is what reads return once buf is drained: io.EOF once the whole body was written, or why it can't be.
+	err    error
+	closed bool
+}
+
+func newMirrorBody(max int64) *mirrorBody {
+	b := &mirrorBody{max: max}
+	b.ready = sync.NewCond(&b.lock)
+	return b
+}
+
+func (b *mirrorBody) write(p []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err != nil || b.closed {
+		return
+	}
+	b.written += int64(len(p))
+	if b.written > b.max {
+		b.err = errMirrorBodyTooLarge
+		b.buf.Reset()
+	} else {
+		b.buf.Write(p)
+	}
+	b.ready.Broadcast()
+}
+
+// finish ends the body with err, unless it already ended.
+func (b *mirrorBody) finish(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.ready.Broadcast()
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.buf.Len() == 0 && b.err == nil && !b.closed {
+		b.ready.Wait()
+	}
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if b.err == errMirrorBodyTooLarge {
+		return 0, b.err
+	}
+	if b.buf.Len() > 0 {
+		return b.buf.Read(p)
+	}
+	return 0, b.err
+}
+
+func (b *mirrorBody) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	b.buf.Reset()
+	b.ready.Broadcast()
+	return nil
+}
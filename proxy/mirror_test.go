@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type mirroredRequest struct {
+	path string
+	body string
+}
+
+type recordingTransport struct {
+	requests chan mirroredRequest
+}
+
+func (t recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.requests <- mirroredRequest{path: r.URL.Path, body: string(body)}
+	return &http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(strings.NewReader("ignored"))}, nil
+}
+
+type echoBodyTransport struct{}
+
+func (echoBodyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+func TestProxyMirror(t *testing.T) {
+	requests := make(chan mirroredRequest, 1)
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: echoBodyTransport{}},
+				Mirror: &ingress.Mirror{
+					Service:      ingress.MockOriginHTTPService{Transport: recordingTransport{requests: requests}},
+					Percentage:   100,
+					Timeout:      time.Second,
+					MaxBodyBytes: 5,
+				},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	tests := []struct {
+		body         string
+		expectMirror bool
+	}{
+		{body: "hello", expectMirror: true},
+		// Bodies larger than MaxBodyBytes aren't mirrored, but still reach the primary origin whole
+		{body: "hello world", expectMirror: false},
+	}
+	for _, test := range tests {
+		responseWriter := newMockHTTPRespWriter()
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/submit", strings.NewReader(test.body))
+		require.NoError(t, err)
+		require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		// The shadow origin's response is discarded
+		assert.Equal(t, http.StatusOK, responseWriter.Code)
+		assert.Equal(t, test.body, responseWriter.Body.String())
+
+		if test.expectMirror {
+			select {
+			case mirrored := <-requests:
+				assert.Equal(t, mirroredRequest{path: "/submit", body: test.body}, mirrored)
+			case <-time.After(time.Second):
+				t.Fatal("request wasn't mirrored")
+			}
+		} else {
+			select {
+			case mirrored := <-requests:
+				t.Fatalf("unexpected mirrored request %+v", mirrored)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+}
+
+type blockingTransport struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t blockingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.started <- struct{}{}
+	<-t.release
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestProxyMirrorMaxConcurrent(t *testing.T) {
+	shadow := blockingTransport{started: make(chan struct{}, 2), release: make(chan struct{})}
+	defer close(shadow.release)
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: echoBodyTransport{}},
+				Mirror: &ingress.Mirror{
+					Service:       ingress.MockOriginHTTPService{Transport: shadow},
+					Percentage:    100,
+					Timeout:       time.Second,
+					MaxBodyBytes:  5,
+					MaxConcurrent: 1,
+				},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		require.NoError(t, proxy.ProxyHTTP(newMockHTTPRespWriter(), tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	}
+
+	// The first mirrored request is still in flight, so the second one isn't mirrored
+	select {
+	case <-shadow.started:
+	case <-time.After(time.Second):
+		t.Fatal("request wasn't mirrored")
+	}
+	select {
+	case <-shadow.started:
+		t.Fatal("more mirrored requests than MaxConcurrent are in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMirrorBody(t *testing.T) {
+	body := newMirrorBody(5)
+	tee := &teeBody{ReadCloser: io.NopCloser(strings.NewReader("hello")), mirror: body}
+	read, err := io.ReadAll(tee)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(read))
+	mirrored, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(mirrored))
+
+	// The primary origin reads the whole body even though the mirrored one is aborted, without waiting for it
+	body = newMirrorBody(5)
+	tee = &teeBody{ReadCloser: io.NopCloser(strings.NewReader("hello world")), mirror: body}
+	read, err = io.ReadAll(tee)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(read))
+	_, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, errMirrorBodyTooLarge)
+
+	// The mirrored body is incomplete when the primary origin stops reading
+	body = newMirrorBody(5)
+	tee = &teeBody{ReadCloser: io.NopCloser(strings.NewReader("hello")), mirror: body}
+	buf := make([]byte, 2)
+	_, err = tee.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, tee.Close())
+	mirrored, err = io.ReadAll(body)
+	assert.ErrorIs(t, err, errMirrorBodyIncomplete)
+	assert.Equal(t, "he", string(mirrored))
+}
@@ -14,12 +14,17 @@ import (
 
 	"github.com/cloudflare/cloudflared/carrier"
 	"github.com/cloudflare/cloudflared/cfio"
+	"github.com/cloudflare/cloudflared/config"
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/ingress/middleware"
 	"github.com/cloudflare/cloudflared/management"
+	"github.com/cloudflare/cloudflared/recording"
 	"github.com/cloudflare/cloudflared/stream"
 	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/tunnelstate"
+	"github.com/cloudflare/cloudflared/websocket"
 )
 
 const (
@@ -34,6 +39,14 @@ const (
 	LogFieldDestAddr      = "destAddr"
 
 	trailerHeaderName = "Trailer"
+
+	// Headers added to requests to origins that enable connectorHeaders, so they can tell which connector served them.
+	ConnectorIDHeader        = "Cf-Connector-Id"
+	ConnectorConnIndexHeader = "Cf-Connector-Conn-Index"
+	ConnectorColoHeader      = "Cf-Connector-Colo"
+	ConnectorProtocolHeader  = "Cf-Connector-Protocol"
+
+	connectorIDTagName = "ID"
 )
 
 // Proxy represents a means to Proxy between cloudflared and the origin services.
@@ -42,19 +55,45 @@ type Proxy struct {
 	warpRouting  *ingress.WarpRoutingService
 	management   *ingress.ManagementService
 	tags         []tunnelpogs.Tag
+	recorder     *recording.Recorder
+	connections  *tunnelstate.ConnTracker
+	shedder      LoadShedder
+	drainer      *connection.Drainer
 	log          *zerolog.Logger
 }
 
+// LoadShedder tells when new requests should be rejected because cloudflared is overloaded.
+type LoadShedder interface {
+	Overloaded() bool
+}
+
+// Options are the optional dependencies of a Proxy, each of them disabled when it's nil.
+type Options struct {
+	// Recorder records the HTTP exchanges.
+	Recorder *recording.Recorder
+	// Connections tells the colo of the connections for rules that enable connectorHeaders.
+	Connections *tunnelstate.ConnTracker
+	// Shedder rejects requests while it reports an overload.
+	Shedder LoadShedder
+	// Drainer tracks the requests in flight and rejects new ones during a graceful shutdown.
+	Drainer *connection.Drainer
+}
+
 // NewOriginProxy returns a new instance of the Proxy struct.
 func NewOriginProxy(
 	ingressRules ingress.Ingress,
 	warpRouting ingress.WarpRoutingConfig,
 	tags []tunnelpogs.Tag,
 	log *zerolog.Logger,
+	options Options,
 ) *Proxy {
 	proxy := &Proxy{
 		ingressRules: ingressRules,
 		tags:         tags,
+		recorder:     options.Recorder,
+		connections:  options.Connections,
+		shedder:      options.Shedder,
+		drainer:      options.Drainer,
 		log:          log,
 	}
 	if warpRouting.Enabled {
@@ -73,7 +112,7 @@ func (p *Proxy) applyIngressMiddleware(rule *ingress.Rule, r *http.Request, w co
 		}
 
 		if result.ShouldFilterRequest {
-			w.WriteRespHeaders(result.StatusCode, nil)
+			w.WriteRespHeaders(result.StatusCode, result.Header)
 			return fmt.Errorf("request filtered by middleware handler (%s) due to: %s", handler.Name(), result.Reason), true
 		}
 	}
@@ -90,6 +129,36 @@ func (p *Proxy) ProxyHTTP(
 	incrementRequests()
 	defer decrementConcurrentRequests()
 
+	if p.shedder != nil && p.shedder.Overloaded() && !connection.IsLBProbeRequest(tr.Request) {
+		shedRequests.Inc()
+		return w.WriteRespHeaders(http.StatusServiceUnavailable, http.Header{"Retry-After": {"1"}})
+	}
+
+	kind := connection.InFlightHTTP
+	if isWebsocket {
+		kind = connection.InFlightWebsocket
+	}
+	done, ok := p.drainer.Track(kind)
+	if !ok {
+		// The edge stops sending requests to a connection once it's unregistered, this only affects the ones racing it
+		return w.WriteRespHeaders(http.StatusServiceUnavailable, nil)
+	}
+	defer done()
+
+	if p.recorder != nil && !isWebsocket {
+		recordingWriter := newRecordingResponseWriter(w, p.recorder.Start(tr.Request))
+		err := p.proxyHTTP(recordingWriter, tr, isWebsocket)
+		recordingWriter.finish(err, p.log)
+		return err
+	}
+	return p.proxyHTTP(w, tr, isWebsocket)
+}
+
+func (p *Proxy) proxyHTTP(
+	w connection.ResponseWriter,
+	tr *tracing.TracedHTTPRequest,
+	isWebsocket bool,
+) error {
 	req := tr.Request
 	cfRay := connection.FindCfRayHeader(req)
 	lbProbe := connection.IsLBProbeRequest(req)
@@ -97,7 +166,8 @@ func (p *Proxy) ProxyHTTP(
 
 	_, ruleSpan := tr.Tracer().Start(req.Context(), "ingress_match",
 		trace.WithAttributes(attribute.String("req-host", req.Host)))
-	rule, ruleNum := p.ingressRules.FindMatchingRule(req.Host, req.URL.Path)
+	rule, ruleNum := p.ingressRules.FindMatchingRequestRule(req)
+	requestsByRule.WithLabelValues(strconv.Itoa(ruleNum)).Inc()
 	logFields := logFields{
 		cfRay:     cfRay,
 		lbProbe:   lbProbe,
@@ -107,7 +177,12 @@ func (p *Proxy) ProxyHTTP(
 	p.logRequest(req, logFields)
 	ruleSpan.SetAttributes(attribute.Int("rule-num", ruleNum))
 	ruleSpan.End()
-	if err, applied := p.applyIngressMiddleware(rule, req, w); err != nil {
+	if rule.Config.ConnectorHeaders {
+		p.setConnectorHeaders(req, tr.ConnIndex)
+	}
+	// The responses that don't come from an origin go through the response middleware with generated
+	generated := newMiddlewareResponseWriter(w, req, responseHandlers(rule))
+	if err, applied := p.applyIngressMiddleware(rule, req, generated); err != nil {
 		if applied {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
 			p.logRequestError(err, cfRay, "", rule, srv)
@@ -116,18 +191,82 @@ func (p *Proxy) ProxyHTTP(
 		return err
 	}
 
-	switch originProxy := rule.Service.(type) {
+	service := rule.Service
+	if f, err := rule.MatchFilter(req); f != nil || err != nil {
+		if err != nil {
+			p.log.Warn().Err(err).Str(LogFieldCFRay, cfRay).Msg("Failed to evaluate ingress rule filter")
+		}
+		switch {
+		case f == nil:
+		case f.Action == ingress.FilterDeny:
+			generated.WriteRespHeaders(f.StatusCode, nil)
+			rule, srv := ruleField(p.ingressRules, ruleNum)
+			p.logRequestError(fmt.Errorf("request denied by filter %q", f.Expression), cfRay, "", rule, srv)
+			return nil
+		case f.Action == ingress.FilterRoute:
+			service = f.Service
+		}
+	}
+
+	if rule.RateLimit != nil {
+		if allowed, retryAfter := rule.RateLimit.Allow(req); !allowed {
+			ruleID, srv := ruleField(p.ingressRules, ruleNum)
+			p.logRequestError(errors.New("client exceeded the rate limit of the rule"), cfRay, "", ruleID, srv)
+			return serveRateLimited(generated, retryAfter)
+		}
+	}
+
+	switch originProxy := service.(type) {
 	case ingress.HTTPOriginProxy:
+		if rule.HealthCheck != nil && service == rule.Service && !rule.HealthCheck.Healthy() {
+			ruleID, srv := ruleField(p.ingressRules, ruleNum)
+			p.logRequestError(errors.New("no origin of the rule is healthy"), cfRay, "", ruleID, srv)
+			return serveUnhealthyOrigin(generated, rule.HealthCheck)
+		}
+		// Oversized uploads are turned away before the mirror or the cache see them
+		if !isWebsocket && announcesTooLargeBody(req, rule.Config.MaxRequestBodySize) {
+			tooLarge := newBodyTooLargeError(requestBodyKind, rule.Config.MaxRequestBodySize)
+			ruleID, srv := ruleField(p.ingressRules, ruleNum)
+			p.logRequestError(tooLarge, cfRay, "", ruleID, srv)
+			if connection.IsGRPC(req.Header) {
+				return writeGRPCError(generated, tooLarge)
+			}
+			generated.WriteRespHeaders(http.StatusRequestEntityTooLarge, nil)
+			return nil
+		}
+		passthrough := rule.Config.StreamingMode == ingress.StreamingModePassthrough
+		// Passthrough streaming is for long-lived bodies, which the mirror would cut at its body limit
+		if rule.Mirror != nil && !isWebsocket && !passthrough && rule.Mirror.Sample() {
+			p.mirrorRequest(rule.Mirror, req, cfRay, rule.Config.MaxRequestBodySize)
+		}
+		if rule.Cache != nil && !isWebsocket && service == rule.Service {
+			originProxy = rule.Cache.Wrap(originProxy)
+		}
 		if err := p.proxyHTTPRequest(
 			w,
 			tr,
 			originProxy,
 			isWebsocket,
-			rule.Config.DisableChunkedEncoding,
+			rule.Config,
+			responseHandlers(rule),
 			logFields,
 		); err != nil {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
 			p.logRequestError(err, cfRay, "", rule, srv)
+			var grpcErr *grpcStatusError
+			var tooLarge *bodyTooLargeError
+			var timedOut *requestTimeoutError
+			switch {
+			case errors.As(err, &grpcErr):
+				// The gRPC client was answered with the status of the call
+				return nil
+			case errors.As(err, &tooLarge) && tooLarge.body == requestBodyKind:
+				generated.WriteRespHeaders(http.StatusRequestEntityTooLarge, nil)
+				return nil
+			case errors.As(err, &timedOut):
+				generated.WriteRespHeaders(http.StatusGatewayTimeout, nil)
+				return nil
+			}
 			return err
 		}
 		return nil
@@ -137,15 +276,30 @@ func (p *Proxy) ProxyHTTP(
 			return err
 		}
 
-		rws := connection.NewHTTPResponseReadWriterAcker(w, req)
-		if err := p.proxyStream(tr.ToTracedContext(), rws, dest, originProxy); err != nil {
+		tracedCtx := tr.ToTracedContext()
+		if websocket.OffersCompression(req.Header) {
+			// The handshake response accepts the offer, see websocket.NewResponseHeader
+			tracedCtx.Context = context.WithValue(tracedCtx.Context, websocket.CompressionContextKey, true)
+		}
+		if pingInterval := rule.Config.WebsocketPingInterval.Duration; pingInterval > 0 {
+			tracedCtx.Context = context.WithValue(tracedCtx.Context, websocket.PingPeriodContextKey, pingInterval)
+		}
+		rws := &meteredReadWriteAcker{
+			ReadWriteAcker: connection.NewHTTPResponseReadWriterAcker(w, req),
+			rule:           strconv.Itoa(ruleNum),
+		}
+		err = p.proxyStream(tracedCtx, rws, dest, originProxy)
+		if rws.metrics != nil {
+			rws.metrics.Close(err)
+		}
+		if err != nil {
 			rule, srv := ruleField(p.ingressRules, ruleNum)
 			p.logRequestError(err, cfRay, "", rule, srv)
 			return err
 		}
 		return nil
 	case ingress.HTTPLocalProxy:
-		p.proxyLocalRequest(originProxy, w, req, isWebsocket)
+		p.proxyLocalRequest(originProxy, generated, req, isWebsocket)
 		return nil
 	default:
 		return fmt.Errorf("Unrecognized service: %s, %t", rule.Service, originProxy)
@@ -167,6 +321,17 @@ func (p *Proxy) ProxyTCP(
 		return err
 	}
 
+	if p.shedder != nil && p.shedder.Overloaded() {
+		shedRequests.Inc()
+		return errors.New("cloudflared is overloaded and rejects new TCP sessions")
+	}
+
+	done, ok := p.drainer.Track(connection.InFlightTCP)
+	if !ok {
+		return errors.New("cloudflared is shutting down and rejects new TCP sessions")
+	}
+	defer done()
+
 	serveCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -208,7 +373,8 @@ func (p *Proxy) proxyHTTPRequest(
 	tr *tracing.TracedHTTPRequest,
 	httpService ingress.HTTPOriginProxy,
 	isWebsocket bool,
-	disableChunkedEncoding bool,
+	cfg ingress.OriginRequestConfig,
+	responseHandlers []middleware.ResponseHandler,
 	fields logFields,
 ) error {
 	roundTripReq := tr.Request
@@ -221,40 +387,89 @@ func (p *Proxy) proxyHTTPRequest(
 		roundTripReq.Body = nil
 	} else {
 		// Support for WSGI Servers by switching transfer encoding from chunked to gzip/deflate
-		if disableChunkedEncoding {
+		if cfg.DisableChunkedEncoding {
 			roundTripReq.TransferEncoding = []string{"gzip", "deflate"}
 			cLength, err := strconv.Atoi(tr.Request.Header.Get("Content-Length"))
 			if err == nil {
 				roundTripReq.ContentLength = int64(cLength)
 			}
+		} else if len(tr.Request.Trailer) > 0 {
+			// Trailers can only follow a chunked body, so the origin must not be sent a Content-Length
+			roundTripReq.ContentLength = -1
 		}
 		// Request origin to keep connection alive to improve performance
 		roundTripReq.Header.Set("Connection", "keep-alive")
+		roundTripReq = p.forwardInterimResponses(roundTripReq, w)
+	}
+	if !isWebsocket && ingress.IsBodiless(roundTripReq) {
+		// The origin and the retries then know there's no body to send
+		roundTripReq.Body = http.NoBody
+	}
+	limitedReqBody, err := limitRequestBody(roundTripReq, cfg.MaxRequestBodySize)
+	if err != nil {
+		return err
+	}
+	var timer *requestTimer
+	if !isWebsocket {
+		roundTripReq, timer = withRequestTimeout(roundTripReq, cfg.RequestTimeout.Duration)
+		defer timer.done()
 	}
 
+	setOriginHeaders(roundTripReq, cfg.HTTPHeaders)
+
 	// Set the User-Agent as an empty string if not provided to avoid inserting golang default UA
 	if roundTripReq.Header.Get("User-Agent") == "" {
 		roundTripReq.Header.Set("User-Agent", "")
 	}
 
 	_, ttfbSpan := tr.Tracer().Start(tr.Context(), "ttfb_origin")
-	resp, err := httpService.RoundTrip(roundTripReq)
+	var resp *http.Response
+	if isWebsocket {
+		resp, err = httpService.RoundTrip(roundTripReq)
+	} else {
+		resp, err = p.roundTripWithRetries(httpService, roundTripReq, cfg)
+	}
 	if err != nil {
 		tracing.EndWithErrorStatus(ttfbSpan, err)
-		if err := roundTripReq.Context().Err(); err != nil {
-			return errors.Wrap(err, "Incoming request ended abruptly")
+		err = roundTripError(err, roundTripReq, limitedReqBody, timer, cfg)
+		if connection.IsGRPC(tr.Request.Header) {
+			if writeErr := writeGRPCError(newMiddlewareResponseWriter(w, tr.Request, responseHandlers), err); writeErr != nil {
+				return errors.Wrap(writeErr, "Error writing response header")
+			}
+			return &grpcStatusError{err: err}
 		}
-		return errors.Wrap(err, "Unable to reach the origin service. The service may be down or it may not be responding to traffic from cloudflared")
+		return err
 	}
 
 	tracing.EndWithStatusCode(ttfbSpan, resp.StatusCode)
 	defer resp.Body.Close()
+	// gRPC streams are as long-lived as server-sent events, and calls have their own deadline in grpc-timeout
+	if resp.StatusCode == http.StatusSwitchingProtocols || connection.IsServerSentEvent(resp.Header) || connection.IsGRPC(resp.Header) {
+		timer.exempt()
+	}
+	if !isWebsocket {
+		if err := limitResponseBody(resp, cfg.MaxResponseBodySize); err != nil {
+			return err
+		}
+	}
 
 	headers := make(http.Header, len(resp.Header))
 	// copy headers
 	for k, v := range resp.Header {
 		headers[k] = v
 	}
+	// The HTTP client moves the trailers announced by the origin out of the headers, announce them again
+	for trailerName := range resp.Trailer {
+		headers.Add(trailerHeaderName, trailerName)
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The response body of an upgraded connection is a stream, not something middleware can rewrite.
+		responseHandlers = nil
+	}
+	if err := applyResponseHeaderMiddleware(responseHandlers, tr.Request, resp.StatusCode, headers); err != nil {
+		return errors.Wrap(err, "Error processing response headers")
+	}
 
 	// Add spans to response header (if available)
 	tr.AddSpans(headers)
@@ -271,17 +486,42 @@ func (p *Proxy) proxyHTTPRequest(
 		}
 		defer rwc.Close()
 
-		eyeballStream := &bidirectionalStream{
+		wsMetrics := websocket.NewConnectionMetrics(strconv.Itoa(fields.rule))
+		eyeballStream := wsMetrics.Eyeball(&bidirectionalStream{
 			writer: w,
 			reader: tr.Request.Body,
+		})
+		originStream := wsMetrics.Origin(rwc)
+
+		var bridgeErr error
+		if cfg.WebsocketPingInterval.Duration > 0 || cfg.WebsocketIdleTimeout.Duration > 0 {
+			bridgeErr = websocket.Bridge(tr.Context(), eyeballStream, originStream, cfg.WebsocketPingInterval.Duration, cfg.WebsocketIdleTimeout.Duration, p.log)
+		} else {
+			stream.Pipe(eyeballStream, originStream, p.log)
 		}
-
-		stream.Pipe(eyeballStream, rwc, p.log)
+		wsMetrics.Close(bridgeErr)
 		return nil
 	}
 
-	if _, err = cfio.Copy(w, resp.Body); err != nil {
-		return err
+	passthrough := cfg.StreamingMode == ingress.StreamingModePassthrough
+	if passthrough {
+		// Response body middleware may hold on to chunks, so only header middleware applies to passthrough rules
+		responseHandlers = nil
+	}
+	bodyWriter := newResponseBodyMiddlewareWriter(w, tr.Request, responseHandlers)
+	switch {
+	case cfg.SSEHeartbeatInterval.Duration > 0 && connection.IsServerSentEvent(headers):
+		err = copySSEWithHeartbeats(w, bodyWriter, resp.Body, cfg.SSEHeartbeatInterval.Duration)
+	case passthrough:
+		err = copyPassthrough(tr.Request.Context(), w, resp.Body)
+	default:
+		_, err = cfio.Copy(bodyWriter, resp.Body)
+	}
+	if err != nil {
+		if connection.IsGRPC(headers) {
+			endGRPCStream(w, err)
+		}
+		return timer.wrapBodyError(err)
 	}
 
 	// copy trailers
@@ -291,6 +531,20 @@ func (p *Proxy) proxyHTTPRequest(
 	return nil
 }
 
+// roundTripError explains why a request to the origin failed.
+func roundTripError(err error, req *http.Request, limitedReqBody *limitedBody, timer *requestTimer, cfg ingress.OriginRequestConfig) error {
+	if limitedReqBody != nil && limitedReqBody.err != nil {
+		return limitedReqBody.err
+	}
+	if timer.expired() {
+		return &requestTimeoutError{timeout: cfg.RequestTimeout.Duration}
+	}
+	if err := req.Context().Err(); err != nil {
+		return errors.Wrap(err, "Incoming request ended abruptly")
+	}
+	return errors.Wrap(err, "Unable to reach the origin service. The service may be down or it may not be responding to traffic from cloudflared")
+}
+
 // proxyStream proxies type TCP and other underlying types if the connection is defined as a stream oriented
 // ingress rule.
 func (p *Proxy) proxyStream(
@@ -330,6 +584,32 @@ func (p *Proxy) proxyLocalRequest(proxy ingress.HTTPLocalProxy, w connection.Res
 	proxy.ServeHTTP(w, req)
 }
 
+// meteredReadWriteAcker reports the websocket of a stream to the metrics of its rule once it's acked.
+type meteredReadWriteAcker struct {
+	connection.ReadWriteAcker
+	rule    string
+	metrics *websocket.ConnectionMetrics
+	eyeball io.ReadWriter
+}
+
+func (m *meteredReadWriteAcker) AckConnection(tracePropagation string) error {
+	if err := m.ReadWriteAcker.AckConnection(tracePropagation); err != nil {
+		return err
+	}
+	m.metrics = websocket.NewConnectionMetrics(m.rule)
+	m.eyeball = m.metrics.Eyeball(m.ReadWriteAcker)
+	return nil
+}
+
+// Read and Write are only called once the stream is acked
+func (m *meteredReadWriteAcker) Read(p []byte) (int, error) {
+	return m.eyeball.Read(p)
+}
+
+func (m *meteredReadWriteAcker) Write(p []byte) (int, error) {
+	return m.eyeball.Write(p)
+}
+
 type bidirectionalStream struct {
 	reader io.Reader
 	writer io.Writer
@@ -349,6 +629,40 @@ func (p *Proxy) appendTagHeaders(r *http.Request) {
 	}
 }
 
+// setConnectorHeaders overwrites any value sent by the eyeball, so origins can trust them.
+func (p *Proxy) setConnectorHeaders(r *http.Request, connIndex uint8) {
+	for _, tag := range p.tags {
+		if tag.Name == connectorIDTagName {
+			r.Header.Set(ConnectorIDHeader, tag.Value)
+		}
+	}
+	r.Header.Set(ConnectorConnIndexHeader, strconv.Itoa(int(connIndex)))
+	r.Header.Del(ConnectorColoHeader)
+	r.Header.Del(ConnectorProtocolHeader)
+	if p.connections == nil {
+		return
+	}
+	if conn, ok := p.connections.GetConnection(connIndex); ok {
+		if conn.Location != "" {
+			r.Header.Set(ConnectorColoHeader, conn.Location)
+		}
+		r.Header.Set(ConnectorProtocolHeader, conn.Protocol.String())
+	}
+}
+
+// setOriginHeaders removes, then adds the request headers configured for the origin.
+func setOriginHeaders(r *http.Request, headers *config.HTTPHeadersConfig) {
+	if headers == nil {
+		return
+	}
+	for _, name := range headers.Remove {
+		r.Header.Del(name)
+	}
+	for name, value := range headers.Add {
+		r.Header.Set(name, value)
+	}
+}
+
 type logFields struct {
 	cfRay     string
 	lbProbe   bool
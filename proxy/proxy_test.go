@@ -31,6 +31,7 @@ import (
 	"github.com/cloudflare/cloudflared/logger"
 	"github.com/cloudflare/cloudflared/tracing"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
+	"github.com/cloudflare/cloudflared/tunnelstate"
 )
 
 var (
@@ -68,6 +69,10 @@ func (w *mockHTTPRespWriter) AddTrailer(trailerName, trailerValue string) {
 	// do nothing
 }
 
+func (w *mockHTTPRespWriter) WriteInterimResponse(status int, header http.Header) error {
+	return nil
+}
+
 func (w *mockHTTPRespWriter) Read(data []byte) (int, error) {
 	return 0, fmt.Errorf("mockHTTPRespWriter doesn't implement io.Reader")
 }
@@ -163,7 +168,7 @@ func TestProxySingleOrigin(t *testing.T) {
 
 	require.NoError(t, ingressRule.StartOrigins(&log, ctx.Done()))
 
-	proxy := NewOriginProxy(ingressRule, noWarpRouting, testTags, &log)
+	proxy := NewOriginProxy(ingressRule, noWarpRouting, testTags, &log, Options{})
 	t.Run("testProxyHTTP", testProxyHTTP(proxy))
 	t.Run("testProxyWebsocket", testProxyWebsocket(proxy))
 	t.Run("testProxySSE", testProxySSE(proxy))
@@ -367,7 +372,7 @@ func runIngressTestScenarios(t *testing.T, unvalidatedIngress []config.Unvalidat
 	ctx, cancel := context.WithCancel(context.Background())
 	require.NoError(t, ingress.StartOrigins(&log, ctx.Done()))
 
-	proxy := NewOriginProxy(ingress, noWarpRouting, testTags, &log)
+	proxy := NewOriginProxy(ingress, noWarpRouting, testTags, &log, Options{})
 
 	for _, test := range tests {
 		responseWriter := newMockHTTPRespWriter()
@@ -415,7 +420,7 @@ func TestProxyError(t *testing.T) {
 
 	log := zerolog.Nop()
 
-	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log)
+	proxy := NewOriginProxy(ing, noWarpRouting, testTags, &log, Options{})
 
 	responseWriter := newMockHTTPRespWriter()
 	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1", nil)
@@ -424,6 +429,148 @@ func TestProxyError(t *testing.T) {
 	assert.Error(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
 }
 
+type headerCapturingTransport struct {
+	header http.Header
+}
+
+func (t *headerCapturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.header = req.Header.Clone()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+func TestProxyConnectorHeaders(t *testing.T) {
+	transport := &headerCapturingTransport{}
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Hostname: "*",
+				Service:  ingress.MockOriginHTTPService{Transport: transport},
+				Config:   ingress.OriginRequestConfig{ConnectorHeaders: true},
+			},
+		},
+	}
+	tags := []tunnelpogs.Tag{{Name: "ID", Value: "connector-id"}}
+	connections := tunnelstate.MockedConnTracker(map[uint8]tunnelstate.ConnectionInfo{
+		2: {IsConnected: true, Protocol: connection.QUIC, Location: "LHR"},
+	})
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, tags, &log, Options{Connections: connections})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	// Values sent by the eyeball are replaced
+	req.Header.Set(ConnectorColoHeader, "spoofed")
+	require.NoError(t, proxy.ProxyHTTP(newMockHTTPRespWriter(), tracing.NewTracedHTTPRequest(req, 2, &log), false))
+	assert.Equal(t, "connector-id", transport.header.Get(ConnectorIDHeader))
+	assert.Equal(t, "2", transport.header.Get(ConnectorConnIndexHeader))
+	assert.Equal(t, "LHR", transport.header.Get(ConnectorColoHeader))
+	assert.Equal(t, "quic", transport.header.Get(ConnectorProtocolHeader))
+
+	// Disabled by default
+	ing.Rules[0].Config.ConnectorHeaders = false
+	proxy = NewOriginProxy(ing, noWarpRouting, tags, &log, Options{Connections: connections})
+	req, err = http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, proxy.ProxyHTTP(newMockHTTPRespWriter(), tracing.NewTracedHTTPRequest(req, 2, &log), false))
+	assert.Empty(t, transport.header.Get(ConnectorConnIndexHeader))
+}
+
+func TestProxyOriginHeaders(t *testing.T) {
+	transport := &headerCapturingTransport{}
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Hostname: "*",
+				Service:  ingress.MockOriginHTTPService{Transport: transport},
+				Config: ingress.OriginRequestConfig{
+					ConnectorHeaders: true,
+					HTTPHeaders: &config.HTTPHeadersConfig{
+						Add:    map[string]string{"X-Internal-Token": "secret"},
+						Remove: []string{"Cf-Connecting-Ip", ConnectorConnIndexHeader},
+					},
+				},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Cf-Connecting-Ip", "203.0.113.1")
+	// Values sent by the eyeball are replaced
+	req.Header.Set("X-Internal-Token", "spoofed")
+	require.NoError(t, proxy.ProxyHTTP(newMockHTTPRespWriter(), tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, []string{"secret"}, transport.header.Values("X-Internal-Token"))
+	assert.Empty(t, transport.header.Get("Cf-Connecting-Ip"))
+	assert.Empty(t, transport.header.Get(ConnectorConnIndexHeader))
+}
+
+func TestProxyUnhealthyOrigin(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	origin.Close()
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		Ingress: []config.UnvalidatedIngressRule{{
+			Service: origin.URL,
+			OriginRequest: config.OriginRequestConfig{
+				HealthCheck: &config.HealthCheckConfig{Interval: &config.CustomDuration{Duration: 10 * time.Millisecond}},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	shutdownC := make(chan struct{})
+	t.Cleanup(func() { close(shutdownC) })
+	require.NoError(t, ing.StartOrigins(&log, shutdownC))
+	require.Eventually(t, func() bool { return !ing.Rules[0].HealthCheck.Healthy() }, time.Second, 5*time.Millisecond)
+
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	respWriter := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(respWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusServiceUnavailable, respWriter.Code)
+	assert.Equal(t, "1", respWriter.Header().Get("Retry-After"))
+	assert.Equal(t, "The origin of this hostname is unhealthy\n", respWriter.Body.String())
+}
+
+type mockLoadShedder struct {
+	overloaded bool
+}
+
+func (s *mockLoadShedder) Overloaded() bool {
+	return s.overloaded
+}
+
+func TestProxyShedsLoad(t *testing.T) {
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Hostname: "*",
+				Service:  ingress.MockOriginHTTPService{Transport: bodyOriginTransport{body: "ok"}},
+			},
+		},
+	}
+	shedder := &mockLoadShedder{overloaded: true}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{Shedder: shedder})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	responseWriter := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusServiceUnavailable, responseWriter.Code)
+	assert.Equal(t, "1", responseWriter.Header().Get("Retry-After"))
+
+	shedder.overloaded = false
+	responseWriter = newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+}
+
 type replayer struct {
 	sync.RWMutex
 	writeDone chan struct{}
@@ -676,7 +823,7 @@ func TestConnections(t *testing.T) {
 
 			ingressRule := createSingleIngressConfig(t, test.args.ingressServiceScheme+ln.Addr().String())
 			ingressRule.StartOrigins(logger, ctx.Done())
-			proxy := NewOriginProxy(ingressRule, testWarpRouting, testTags, logger)
+			proxy := NewOriginProxy(ingressRule, testWarpRouting, testTags, logger, Options{})
 			proxy.warpRouting = test.args.warpRoutingService
 
 			dest := ln.Addr().String()
@@ -838,6 +985,10 @@ func (w *wsRespWriter) AddTrailer(trailerName, trailerValue string) {
 	// do nothing
 }
 
+func (w *wsRespWriter) WriteInterimResponse(status int, header http.Header) error {
+	return nil
+}
+
 // respHeaders is a test function to read respHeaders
 func (w *wsRespWriter) Header() http.Header {
 	// Removing indeterminstic header because it cannot be asserted.
@@ -877,6 +1028,10 @@ func (m *mockTCPRespWriter) AddTrailer(trailerName, trailerValue string) {
 	// do nothing
 }
 
+func (m *mockTCPRespWriter) WriteInterimResponse(status int, header http.Header) error {
+	return nil
+}
+
 func (m *mockTCPRespWriter) WriteRespHeaders(status int, header http.Header) error {
 	m.responseHeaders = header
 	m.code = status
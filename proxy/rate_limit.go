@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+// serveRateLimited answers a request whose client exceeded the rate limit of its rule with a 429, telling it when it
+// can make a request again.
+func serveRateLimited(w connection.ResponseWriter, retryAfter time.Duration) error {
+	rateLimitedRequests.Inc()
+	header := http.Header{}
+	header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return w.WriteRespHeaders(http.StatusTooManyRequests, header)
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+func TestProxyRateLimit(t *testing.T) {
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Service: "http_status:200",
+				OriginRequest: config.OriginRequestConfig{
+					RateLimit: &config.RateLimitConfig{RequestsPerSecond: 0.5, Burst: 1},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	proxyRequest := func(clientIP string) *mockHTTPRespWriter {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cf-Connecting-Ip", clientIP)
+		w := newMockHTTPRespWriter()
+		require.NoError(t, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, proxyRequest("198.51.100.1").Code)
+	w := proxyRequest("198.51.100.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, proxyRequest("198.51.100.2").Code)
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/recording"
+)
+
+// recordingResponseWriter records the response written to the eyeball for traffic replay.
+type recordingResponseWriter struct {
+	connection.ResponseWriter
+	recording     *recording.Recording
+	statusWritten bool
+}
+
+func newRecordingResponseWriter(w connection.ResponseWriter, recording *recording.Recording) *recordingResponseWriter {
+	return &recordingResponseWriter{
+		ResponseWriter: w,
+		recording:      recording,
+	}
+}
+
+func (w *recordingResponseWriter) WriteRespHeaders(status int, header http.Header) error {
+	w.recordHeaders(status, header)
+	return w.ResponseWriter.WriteRespHeaders(status, header)
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	w.recordHeaders(status, w.Header())
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(p []byte) (int, error) {
+	w.recordHeaders(http.StatusOK, w.Header())
+	n, err := w.ResponseWriter.Write(p)
+	w.recording.RecordResponseBody(p[:n])
+	return n, err
+}
+
+func (w *recordingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *recordingResponseWriter) recordHeaders(status int, header http.Header) {
+	if w.statusWritten {
+		return
+	}
+	w.statusWritten = true
+	w.recording.RecordResponseHeaders(status, header)
+}
+
+func (w *recordingResponseWriter) finish(err error, log *zerolog.Logger) {
+	if err != nil {
+		// The connection responds with a bad gateway if the proxy fails before writing a status.
+		w.recordHeaders(http.StatusBadGateway, nil)
+	}
+	if err := w.recording.Finish(err); err != nil {
+		log.Err(err).Msg("Failed to record HTTP exchange")
+	}
+}
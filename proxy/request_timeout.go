@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// requestTimeoutError is returned when the origin doesn't respond within the requestTimeout of the rule.
+type requestTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *requestTimeoutError) Error() string {
+	return fmt.Sprintf("origin didn't respond within the requestTimeout of %s", e.timeout)
+}
+
+// requestTimer cancels a request to the origin once the requestTimeout of its rule elapses. A nil requestTimer never
+// times out.
+type requestTimer struct {
+	timeout  time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut int32
+}
+
+// withRequestTimeout returns a copy of req that is canceled after timeout, unless the timer is stopped first.
+func withRequestTimeout(req *http.Request, timeout time.Duration) (*http.Request, *requestTimer) {
+	if timeout <= 0 {
+		return req, nil
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	t := &requestTimer{timeout: timeout, cancel: cancel}
+	t.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&t.timedOut, 1)
+		timedOutRequests.Inc()
+		cancel()
+	})
+	return req.WithContext(ctx), t
+}
+
+// exempt stops the timer of a request whose response is long-lived, such as server-sent events.
+func (t *requestTimer) exempt() {
+	if t != nil {
+		t.timer.Stop()
+	}
+}
+
+// expired returns true if the request was canceled because it timed out.
+func (t *requestTimer) expired() bool {
+	return t != nil && atomic.LoadInt32(&t.timedOut) == 1
+}
+
+// done releases the timer once the request is proxied.
+func (t *requestTimer) done() {
+	if t != nil {
+		t.timer.Stop()
+		t.cancel()
+	}
+}
+
+// wrapBodyError explains why the response body couldn't be copied when the request timed out.
+func (t *requestTimer) wrapBodyError(err error) error {
+	if err != nil && t.expired() {
+		return fmt.Errorf("origin didn't send its whole response within the requestTimeout of %s: %w", t.timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+func TestProxyRequestTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hung":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		w.(http.Flusher).Flush()
+		time.Sleep(2 * timeout)
+		_, _ = w.Write([]byte("data: late\n\n"))
+	}))
+	defer origin.Close()
+
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: http.DefaultTransport},
+				Config:  ingress.OriginRequestConfig{RequestTimeout: config.CustomDuration{Duration: timeout}},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+	proxyRequest := func(path string) (*mockHTTPRespWriter, error) {
+		req, err := http.NewRequest(http.MethodGet, origin.URL+path, nil)
+		require.NoError(t, err)
+		w := newMockHTTPRespWriter()
+		return w, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false)
+	}
+
+	// An origin that doesn't respond in time gets a 504
+	w, err := proxyRequest("/hung")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	// A response body that takes too long is cut
+	_, err = proxyRequest("/slow")
+	assert.ErrorContains(t, err, "origin didn't send its whole response within the requestTimeout of 50ms")
+
+	// Server-sent events are exempt
+	w, err = proxyRequest("/events")
+	require.NoError(t, err)
+	assert.Equal(t, "data: late\n\n", w.Body.String())
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/retry"
+)
+
+const (
+	defaultRetryTimeout = 10 * time.Second
+	// retryBaseTime is half of the longest wait before the first retry, the wait doubles with every retry
+	retryBaseTime   = 50 * time.Millisecond
+	retryMaxBackoff = 2 * time.Second
+	// retryMaxDrainBytes bounds how much of a failed response is read, so its connection is reused
+	retryMaxDrainBytes = 64 * 1024
+)
+
+// roundTripWithRetries sends req to the origin, and sends it again up to cfg.Retries times with a jittered exponential
+// backoff while it fails in a way listed in cfg.RetryOn. Only idempotent requests without a body are retried, since
+// the body has been consumed by the first attempt. No attempt is started once it couldn't end within
+// cfg.RetryTimeout of the first one, and the last failure is returned.
+func (p *Proxy) roundTripWithRetries(origin http.RoundTripper, req *http.Request, cfg ingress.OriginRequestConfig) (*http.Response, error) {
+	if cfg.Retries == 0 || !isRetryable(req) {
+		return origin.RoundTrip(req)
+	}
+	retryTimeout := cfg.RetryTimeout.Duration
+	if retryTimeout == 0 {
+		retryTimeout = defaultRetryTimeout
+	}
+	deadline := time.Now().Add(retryTimeout)
+	backoff := retry.BackoffHandler{
+		MaxRetries:      cfg.Retries,
+		BaseTime:        retryBaseTime,
+		MaxBackoff:      retryMaxBackoff,
+		MinBackoffRatio: 0.5,
+	}
+	// The origin services rewrite the requests they send
+	original := req.Clone(req.Context())
+	for {
+		resp, err := origin.RoundTrip(req)
+		reason, retry := retryReason(cfg.RetryOn, resp, err)
+		if !retry {
+			return resp, err
+		}
+		wait, ok := backoff.GetMaxBackoffDuration(req.Context())
+		if !ok || time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, retryMaxDrainBytes))
+			_ = resp.Body.Close()
+		}
+		p.log.Debug().Err(err).Str("reason", reason).Int("retry", backoff.Retries()+1).Msg("Retrying the request to the origin")
+		if !backoff.Backoff(req.Context()) {
+			return nil, errors.Wrap(req.Context().Err(), "Incoming request ended abruptly")
+		}
+		retriedRequests.WithLabelValues(reason).Inc()
+		req = original.Clone(original.Context())
+	}
+}
+
+// isRetryable tells whether a request can be sent to the origin again: its method has to be idempotent, and it
+// mustn't have a body, which proxyHTTPRequest has already replaced with http.NoBody then.
+func isRetryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return ingress.IsBodiless(req)
+}
+
+// retryReason returns the retryOn condition that the result of an attempt meets, if any.
+func retryReason(retryOn []string, resp *http.Response, err error) (string, bool) {
+	if len(retryOn) == 0 {
+		retryOn = []string{ingress.RetryOnConnectFailure}
+	}
+	for _, condition := range retryOn {
+		if condition == ingress.RetryOnConnectFailure {
+			if err != nil && isConnectFailure(err) {
+				return condition, true
+			}
+		} else if err == nil && strconv.Itoa(resp.StatusCode) == condition {
+			return condition, true
+		}
+	}
+	return "", false
+}
+
+// isConnectFailure tells whether the origin couldn't be connected to, in which case it didn't get the request.
+func isConnectFailure(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+// flakyTransport fails to connect to the origin the first failures times.
+type flakyTransport struct {
+	failures int32
+	attempts int32
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&t.attempts, 1) <= t.failures {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestProxyRetries(t *testing.T) {
+	var attempts int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every request fails twice before succeeding
+		if atomic.AddInt32(&attempts, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name             string
+		method           string
+		body             string
+		cfg              ingress.OriginRequestConfig
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		{
+			name:             "retried until success",
+			method:           http.MethodGet,
+			cfg:              ingress.OriginRequestConfig{Retries: 3, RetryOn: []string{"503"}},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "retries exhausted",
+			method:           http.MethodGet,
+			cfg:              ingress.OriginRequestConfig{Retries: 1, RetryOn: []string{"503"}},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "status not in retryOn",
+			method:           http.MethodGet,
+			cfg:              ingress.OriginRequestConfig{Retries: 3},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "not idempotent",
+			method:           http.MethodPost,
+			cfg:              ingress.OriginRequestConfig{Retries: 3, RetryOn: []string{"503"}},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "with a body",
+			method:           http.MethodPut,
+			body:             "body",
+			cfg:              ingress.OriginRequestConfig{Retries: 3, RetryOn: []string{"503"}},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retry timeout",
+			method:           http.MethodGet,
+			cfg:              ingress.OriginRequestConfig{Retries: 3, RetryOn: []string{"503"}, RetryTimeout: config.CustomDuration{Duration: time.Millisecond}},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+	}
+	log := zerolog.Nop()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			atomic.StoreInt32(&attempts, 0)
+			ing := ingress.Ingress{
+				Rules: []ingress.Rule{{Service: ingress.MockOriginHTTPService{Transport: http.DefaultTransport}, Config: test.cfg}},
+			}
+			proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+			req, err := http.NewRequest(test.method, origin.URL, nil)
+			if test.body != "" {
+				req, err = http.NewRequest(test.method, origin.URL, strings.NewReader(test.body))
+			}
+			require.NoError(t, err)
+			w := newMockHTTPRespWriter()
+			require.NoError(t, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+			assert.Equal(t, test.expectedStatus, w.Code)
+			assert.Equal(t, test.expectedAttempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestProxyRetriesConnectFailures(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer origin.Close()
+
+	transport := &flakyTransport{failures: 2}
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{{
+			Service: ingress.MockOriginHTTPService{Transport: transport},
+			Config:  ingress.OriginRequestConfig{Retries: 2},
+		}},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+	req, err := http.NewRequest(http.MethodGet, origin.URL, nil)
+	require.NoError(t, err)
+	w := newMockHTTPRespWriter()
+	require.NoError(t, proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&transport.attempts))
+}
+
+// roundTripperFunc sends requests to the origin with a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestProxyRetriesOverHTTP2(t *testing.T) {
+	var attempts int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer origin.Close()
+	originURL, err := url.Parse(origin.URL)
+	require.NoError(t, err)
+
+	toOrigin := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme = originURL.Scheme
+		req.URL.Host = originURL.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{{
+			Service: ingress.MockOriginHTTPService{Transport: toOrigin},
+			Config:  ingress.OriginRequestConfig{Retries: 3, RetryOn: []string{"503"}},
+		}},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	// The requests received over HTTP/2 have a Body even when the edge sent none
+	edgeConn, cfdConn := net.Pipe()
+	defer edgeConn.Close()
+	go (&http2.Server{}).ServeConn(cfdConn, &http2.ServeConnOpts{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			respWriter, err := connection.NewHTTP2RespWriter(r, w, connection.TypeHTTP, &log)
+			if err != nil {
+				return
+			}
+			_ = proxy.ProxyHTTP(respWriter, tracing.NewTracedHTTPRequest(r, 0, &log), false)
+		}),
+	})
+	edgeHTTP2Conn, err := (&http2.Transport{}).NewClientConn(edgeConn)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+	resp, err := edgeHTTP2Conn.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
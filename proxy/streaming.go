@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cloudflare/cloudflared/cfio"
+)
+
+// passthroughChunkSize bounds how much of a response is read from the origin before it's flushed to the eyeball.
+const passthroughChunkSize = 4 * 1024
+
+// copyPassthrough copies a response body to the eyeball in small chunks, flushing each one as soon as it's read from
+// the origin instead of leaving it in the connection's buffers.
+func copyPassthrough(ctx context.Context, w io.Writer, body io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	pooled, err := cfio.GetBuffer(ctx, passthroughChunkSize)
+	if err != nil {
+		return err
+	}
+	defer cfio.PutBuffer(pooled)
+	buf := *pooled
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return writeErr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sseHeartbeat is an SSE comment line, which clients ignore.
+var sseHeartbeat = []byte(":\n")
+
+type sseRead struct {
+	n   int
+	err error
+}
+
+// copySSEWithHeartbeats copies a server-sent events body to bodyWriter, flushing every chunk. When the origin stays
+// silent for interval, it writes a comment line straight to w so that the eyeball and intermediaries don't time the
+// stream out. Heartbeats are only sent between lines, so they never split an event field.
+func copySSEWithHeartbeats(w io.Writer, bodyWriter io.Writer, body io.Reader, interval time.Duration) error {
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// The body is read in its own goroutine so that its silence can be timed. The buffer isn't pooled because the
+	// goroutine may still be reading into it when this function returns on a write error.
+	buf := make([]byte, passthroughChunkSize)
+	reads := make(chan sseRead, 1)
+	next := make(chan struct{})
+	defer close(next)
+	go func() {
+		for {
+			n, err := body.Read(buf)
+			reads <- sseRead{n: n, err: err}
+			if err != nil {
+				return
+			}
+			if _, ok := <-next; !ok {
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	atLineStart := true
+	for {
+		select {
+		case <-timer.C:
+			if atLineStart {
+				if _, err := w.Write(sseHeartbeat); err != nil {
+					return err
+				}
+				flush()
+			}
+			timer.Reset(interval)
+		case read := <-reads:
+			if read.n > 0 {
+				if _, err := bodyWriter.Write(buf[:read.n]); err != nil {
+					return err
+				}
+				flush()
+				atLineStart = buf[read.n-1] == '\n'
+			}
+			if read.err == io.EOF {
+				return nil
+			}
+			if read.err != nil {
+				return read.err
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(interval)
+			next <- struct{}{}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type flushCountingRespWriter struct {
+	*mockHTTPRespWriter
+	flushes int
+}
+
+func (w *flushCountingRespWriter) Flush() {
+	w.flushes++
+	w.mockHTTPRespWriter.Flush()
+}
+
+type largeBodyTransport struct {
+	body string
+}
+
+func (t largeBodyTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(t.body))}, nil
+}
+
+func TestProxyPassthroughStreaming(t *testing.T) {
+	body := strings.Repeat("a", 3*passthroughChunkSize+1)
+	mirrored := make(chan mirroredRequest, 1)
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: largeBodyTransport{body: body}},
+				Mirror: &ingress.Mirror{
+					Service:      ingress.MockOriginHTTPService{Transport: recordingTransport{requests: mirrored}},
+					Percentage:   100,
+					Timeout:      time.Second,
+					MaxBodyBytes: 1024,
+				},
+				Config: ingress.OriginRequestConfig{StreamingMode: ingress.StreamingModePassthrough},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	responseWriter := &flushCountingRespWriter{mockHTTPRespWriter: newMockHTTPRespWriter()}
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/video.mp4", nil)
+	require.NoError(t, err)
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+
+	assert.Equal(t, http.StatusOK, responseWriter.Code)
+	assert.Equal(t, body, responseWriter.Body.String())
+	// Every chunk read from the origin is flushed
+	assert.Equal(t, 4, responseWriter.flushes)
+	select {
+	case <-mirrored:
+		t.Fatal("passthrough requests must not be mirrored")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCopySSEWithHeartbeats(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	originR, originW := io.Pipe()
+	go func() {
+		_, _ = originW.Write([]byte("data: a\n\n"))
+		time.Sleep(5 * interval)
+		// No heartbeat may be sent in the middle of a line
+		_, _ = originW.Write([]byte("data: b"))
+		time.Sleep(5 * interval)
+		_, _ = originW.Write([]byte("\n\n"))
+		originW.Close()
+	}()
+
+	w := &flushCountingRespWriter{mockHTTPRespWriter: newMockHTTPRespWriter()}
+	require.NoError(t, copySSEWithHeartbeats(w, w, originR, interval))
+	body := w.Body.String()
+	assert.Regexp(t, `^data: a\n\n(:\n)+data: b\n\n$`, body)
+	assert.Equal(t, 3+strings.Count(body, ":\n"), w.flushes)
+}
+
+func TestProxySSEHeartbeats(t *testing.T) {
+	originR, originW := io.Pipe()
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{
+				Service: ingress.MockOriginHTTPService{Transport: sseTransport{body: originR}},
+				Config:  ingress.OriginRequestConfig{SSEHeartbeatInterval: config.CustomDuration{Duration: 10 * time.Millisecond}},
+			},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = originW.Write([]byte("data: done\n\n"))
+		originW.Close()
+	}()
+
+	responseWriter := newMockHTTPRespWriter()
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/events", nil)
+	require.NoError(t, err)
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+	assert.Regexp(t, `^(:\n)+data: done\n\n$`, responseWriter.Body.String())
+}
+
+type sseTransport struct {
+	body io.ReadCloser
+}
+
+func (t sseTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	header := http.Header{"Content-Type": []string{"text/event-stream"}}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: t.body}, nil
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ingress"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type trailerRespWriter struct {
+	*mockHTTPRespWriter
+	trailers http.Header
+}
+
+func (w *trailerRespWriter) AddTrailer(trailerName, trailerValue string) {
+	w.trailers.Add(trailerName, trailerValue)
+}
+
+func TestProxyTrailers(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write(body)
+		w.Header().Set("Grpc-Status", r.Trailer.Get("Checksum"))
+	}))
+	defer origin.Close()
+
+	ing := ingress.Ingress{
+		Rules: []ingress.Rule{
+			{Service: ingress.MockOriginHTTPService{Transport: http.DefaultTransport}},
+		},
+	}
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	responseWriter := &trailerRespWriter{mockHTTPRespWriter: newMockHTTPRespWriter(), trailers: http.Header{}}
+	req, err := http.NewRequest(http.MethodPost, origin.URL, strings.NewReader("ping"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Length", "4")
+	req.Trailer = http.Header{"Checksum": []string{"abc"}}
+	require.NoError(t, proxy.ProxyHTTP(responseWriter, tracing.NewTracedHTTPRequest(req, 0, &log), false))
+
+	assert.Equal(t, "ping", responseWriter.Body.String())
+	// The trailers are announced in the response headers, and the request trailer reached the origin
+	assert.Equal(t, "Grpc-Status", responseWriter.Header().Get("Trailer"))
+	assert.Equal(t, "abc", responseWriter.trailers.Get("Grpc-Status"))
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+// DialUDP opens the socket of a new UDP session to the udp:// service of the ingress rule that matches hostname. It
+// also returns the udpIdleTimeout of the rule, which is 0 when the rule leaves the idle timeout to the edge.
+func (p *Proxy) DialUDP(hostname string) (ingress.UDPProxy, time.Duration, error) {
+	if p.shedder != nil && p.shedder.Overloaded() {
+		shedRequests.Inc()
+		return nil, 0, errors.New("cloudflared is overloaded and rejects new UDP sessions")
+	}
+
+	rule, ruleNum := p.ingressRules.FindMatchingRule(hostname, "")
+	service, ok := rule.Service.(ingress.UDPOriginProxy)
+	if !ok {
+		return nil, 0, fmt.Errorf("the ingress rule of %s has service %s, which doesn't take UDP sessions", hostname, rule.Service)
+	}
+	conn, err := service.DialUDP()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ruleLabel := strconv.Itoa(ruleNum)
+	activeUDPSessionsByRule.WithLabelValues(ruleLabel).Inc()
+	return &trackedUDPProxy{
+		UDPProxy: conn,
+		rule:     ruleLabel,
+	}, rule.Config.UDPIdleTimeout.Duration, nil
+}
+
+// trackedUDPProxy counts a session in the active sessions of its rule until it's closed.
+type trackedUDPProxy struct {
+	ingress.UDPProxy
+	rule      string
+	closeOnce sync.Once
+}
+
+func (t *trackedUDPProxy) Close() error {
+	t.closeOnce.Do(func() {
+		activeUDPSessionsByRule.WithLabelValues(t.rule).Dec()
+	})
+	return t.UDPProxy.Close()
+}
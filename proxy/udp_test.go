@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/config"
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/ingress"
+)
+
+var _ connection.UDPOriginProxy = (*Proxy)(nil)
+
+func activeUDPSessions(t *testing.T, rule string) float64 {
+	var m dto.Metric
+	require.NoError(t, activeUDPSessionsByRule.WithLabelValues(rule).Write(&m))
+	return m.Gauge.GetValue()
+}
+
+func TestProxyDialUDP(t *testing.T) {
+	origin, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer origin.Close()
+
+	ing, err := ingress.ParseIngress(&config.Configuration{
+		Ingress: []config.UnvalidatedIngressRule{
+			{
+				Hostname: "game.example.com",
+				Service:  "udp://" + origin.LocalAddr().String(),
+				OriginRequest: config.OriginRequestConfig{
+					UDPIdleTimeout: &config.CustomDuration{Duration: time.Minute},
+				},
+			},
+			{
+				Service: "http_status:404",
+			},
+		},
+	})
+	require.NoError(t, err)
+	log := zerolog.Nop()
+	proxy := NewOriginProxy(ing, noWarpRouting, nil, &log, Options{})
+
+	conn, idleTimeout, err := proxy.DialUDP("game.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, idleTimeout)
+	assert.Equal(t, 1.0, activeUDPSessions(t, "0"))
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, addr, err := origin.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf[:n]))
+	_, err = origin.WriteTo([]byte("pong"), addr)
+	require.NoError(t, err)
+	n, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf[:n]))
+
+	// Closing a session more than once only counts it once
+	require.NoError(t, conn.Close())
+	_ = conn.Close()
+	assert.Equal(t, 0.0, activeUDPSessions(t, "0"))
+
+	// Hostnames of other services don't take UDP sessions
+	_, _, err = proxy.DialUDP("www.example.com")
+	assert.Error(t, err)
+}
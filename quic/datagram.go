@@ -26,14 +26,17 @@ type BaseDatagramMuxer interface {
 
 type DatagramMuxer struct {
 	session   quic.Connection
+	pathMTU   *PathMTU
 	logger    *zerolog.Logger
 	demuxChan chan<- *packet.Session
 }
 
-func NewDatagramMuxer(quicSession quic.Connection, log *zerolog.Logger, demuxChan chan<- *packet.Session) *DatagramMuxer {
+// NewDatagramMuxer creates a DatagramMuxer. Payloads are sized after pathMTU, or a conservative default if it's nil.
+func NewDatagramMuxer(quicSession quic.Connection, pathMTU *PathMTU, log *zerolog.Logger, demuxChan chan<- *packet.Session) *DatagramMuxer {
 	logger := log.With().Uint8("datagramVersion", 1).Logger()
 	return &DatagramMuxer{
 		session:   quicSession,
+		pathMTU:   pathMTU,
 		logger:    &logger,
 		demuxChan: demuxChan,
 	}
@@ -41,7 +44,7 @@ func NewDatagramMuxer(quicSession quic.Connection, log *zerolog.Logger, demuxCha
 
 // Maximum application payload to send to / receive from QUIC datagram frame
 func (dm *DatagramMuxer) mtu() int {
-	return maxDatagramPayloadSize
+	return dm.pathMTU.DatagramSize() - sessionIDLen - typeIDLen
 }
 
 func (dm *DatagramMuxer) SendToSession(session *packet.Session) error {
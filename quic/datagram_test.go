@@ -145,10 +145,10 @@ func testDatagram(t *testing.T, version uint8, sessionToPayloads []*packet.Sessi
 
 		switch version {
 		case 1:
-			muxer := NewDatagramMuxer(quicSession, &logger, sessionDemuxChan)
+			muxer := NewDatagramMuxer(quicSession, nil, &logger, sessionDemuxChan)
 			muxer.ServeReceive(ctx)
 		case 2:
-			muxer := NewDatagramMuxerV2(quicSession, &logger, sessionDemuxChan)
+			muxer := NewDatagramMuxerV2(quicSession, nil, &logger, sessionDemuxChan)
 			muxer.ServeReceive(ctx)
 
 			for _, pk := range packets {
@@ -193,9 +193,9 @@ func testDatagram(t *testing.T, version uint8, sessionToPayloads []*packet.Sessi
 		var muxer BaseDatagramMuxer
 		switch version {
 		case 1:
-			muxer = NewDatagramMuxer(quicSession, &logger, nil)
+			muxer = NewDatagramMuxer(quicSession, nil, &logger, nil)
 		case 2:
-			muxerV2 := NewDatagramMuxerV2(quicSession, &logger, nil)
+			muxerV2 := NewDatagramMuxerV2(quicSession, nil, &logger, nil)
 			encoder := packet.NewEncoder()
 			for _, pk := range packets {
 				encodedPacket, err := encoder.Encode(&pk)
@@ -47,24 +47,34 @@ func SuffixType(b []byte, datagramType DatagramV2Type) ([]byte, error) {
 
 // Maximum application payload to send to / receive from QUIC datagram frame
 func (dm *DatagramMuxerV2) mtu() int {
-	return maxDatagramPayloadSize
+	return dm.pathMTU.DatagramSize() - sessionIDLen - typeIDLen
+}
+
+// MaxSessionPayloadSize returns the largest payload of the datagrams of a session that currently fits the path.
+func (dm *DatagramMuxerV2) MaxSessionPayloadSize() int {
+	return dm.mtu()
 }
 
 type DatagramMuxerV2 struct {
 	session          quic.Connection
+	pathMTU          *PathMTU
 	logger           *zerolog.Logger
 	sessionDemuxChan chan<- *packet.Session
 	packetDemuxChan  chan Packet
 }
 
+// NewDatagramMuxerV2 creates a DatagramMuxerV2. Datagrams are sized after pathMTU, or a conservative default if it's
+// nil.
 func NewDatagramMuxerV2(
 	quicSession quic.Connection,
+	pathMTU *PathMTU,
 	log *zerolog.Logger,
 	sessionDemuxChan chan<- *packet.Session,
 ) *DatagramMuxerV2 {
 	logger := log.With().Uint8("datagramVersion", 2).Logger()
 	return &DatagramMuxerV2{
 		session:          quicSession,
+		pathMTU:          pathMTU,
 		logger:           &logger,
 		sessionDemuxChan: sessionDemuxChan,
 		packetDemuxChan:  make(chan Packet, packetChanCapacity),
@@ -104,6 +114,10 @@ func (dm *DatagramMuxerV2) SendPacket(pk Packet) error {
 	if err != nil {
 		return errors.Wrap(err, "Failed to suffix datagram type, it will be dropped")
 	}
+	if len(payloadWithMetadataAndType) > dm.pathMTU.DatagramSize() {
+		packetTooBigDropped.Inc()
+		return fmt.Errorf("packet has %d bytes, which exceeds transport MTU %d", len(payloadWithMetadataAndType), dm.pathMTU.DatagramSize())
+	}
 	if err := dm.session.SendMessage(payloadWithMetadataAndType); err != nil {
 		return errors.Wrap(err, "Failed to send datagram back to edge")
 	}
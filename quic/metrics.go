@@ -26,6 +26,7 @@ var (
 		minRTT            *prometheus.GaugeVec
 		latestRTT         *prometheus.GaugeVec
 		smoothedRTT       *prometheus.GaugeVec
+		pathMTU           *prometheus.GaugeVec
 	}{
 		totalConnections: prometheus.NewCounter(
 			totalConnectionsOpts(logging.PerspectiveClient),
@@ -88,6 +89,15 @@ var (
 			},
 			clientConnLabels,
 		),
+		pathMTU: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: perspectiveString(logging.PerspectiveClient),
+				Name:      "path_mtu",
+				Help:      "Largest packet size in bytes acknowledged by the peer on a connection, as found by path MTU discovery",
+			},
+			clientConnLabels,
+		),
 	}
 	// The server has many QUIC connections. Adding per connection label incurs high memory cost
 	serverMetrics = struct {
@@ -270,6 +280,7 @@ func newClientCollector(index uint8) MetricsCollector {
 			clientMetrics.minRTT,
 			clientMetrics.latestRTT,
 			clientMetrics.smoothedRTT,
+			clientMetrics.pathMTU,
 			packetTooBigDropped,
 		)
 	})
@@ -0,0 +1,144 @@
+package quic
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+)
+
+const (
+	// A short header packet has a 1 byte flags field, the destination connection ID and a packet number of up to 4 bytes.
+	shortHeaderOverhead = 1 + 4
+	// All the cipher suites of QUIC have a 16 bytes AEAD tag.
+	aeadOverhead = 16
+	// A datagram frame has a 1 byte type and a length that is a varint of up to 2 bytes for the sizes we send.
+	datagramFrameOverhead = 3
+	// defaultDatagramSize is the largest datagram sent before the path MTU is known.
+	defaultDatagramSize = maxDatagramPayloadSize + sessionIDLen + typeIDLen
+)
+
+// PathMTU follows the path MTU that quic-go discovers with DPLPMTUD (RFC 8899) on a connection, so datagrams can be
+// as large as the path allows instead of a conservative size that fits any path.
+// quic-go sends probe packets larger than the current maximum packet size and only raises it once the peer
+// acknowledges one, so PathMTU does the same by tracing the packets of the connection.
+type PathMTU struct {
+	logging.NullConnectionTracer
+	index string
+
+	// packetSize is the largest packet acknowledged by the peer.
+	packetSize atomic.Int64
+	// peerMaxFrameSize is the max_datagram_frame_size transport parameter of the peer.
+	peerMaxFrameSize atomic.Int64
+	destConnIDLen    atomic.Int64
+
+	probesLock sync.Mutex
+	// probes are the packets in flight that are larger than packetSize.
+	probes map[logging.PacketNumber]logging.ByteCount
+}
+
+// NewPathMTU creates a PathMTU for the connection with the given index.
+func NewPathMTU(connIndex uint8) *PathMTU {
+	return &PathMTU{
+		index:  uint8ToString(connIndex),
+		probes: make(map[logging.PacketNumber]logging.ByteCount),
+	}
+}
+
+// TraceConnection returns a copy of config that also traces the connection to PathMTU.
+func (p *PathMTU) TraceConnection(config *quic.Config) *quic.Config {
+	return TraceConnection(config, p)
+}
+
+// DatagramSize returns the largest datagram, including the session ID and type suffixes, that fits in a single
+// packet on the path. It's never smaller than the default size used before the path MTU is discovered.
+func (p *PathMTU) DatagramSize() int {
+	if p == nil {
+		return defaultDatagramSize
+	}
+	packetSize := p.packetSize.Load()
+	peerMaxFrameSize := p.peerMaxFrameSize.Load()
+	if packetSize == 0 || peerMaxFrameSize <= 0 {
+		return defaultDatagramSize
+	}
+	size := packetSize - shortHeaderOverhead - p.destConnIDLen.Load() - aeadOverhead - datagramFrameOverhead
+	if maxSize := peerMaxFrameSize - datagramFrameOverhead; size > maxSize {
+		size = maxSize
+	}
+	if size > MaxDatagramFrameSize {
+		size = MaxDatagramFrameSize
+	}
+	if size < int64(defaultDatagramSize) {
+		return defaultDatagramSize
+	}
+	return int(size)
+}
+
+// PacketSize returns the largest packet acknowledged by the peer, or 0 if none was acknowledged yet.
+func (p *PathMTU) PacketSize() int {
+	return int(p.packetSize.Load())
+}
+
+func (p *PathMTU) ReceivedTransportParameters(parameters *logging.TransportParameters) {
+	p.peerMaxFrameSize.Store(int64(parameters.MaxDatagramFrameSize))
+}
+
+func (p *PathMTU) SentShortHeaderPacket(hdr *logging.ShortHeader, size logging.ByteCount, _ *logging.AckFrame, _ []logging.Frame) {
+	p.destConnIDLen.Store(int64(hdr.DestConnectionID.Len()))
+	if int64(size) <= p.packetSize.Load() {
+		return
+	}
+	p.probesLock.Lock()
+	defer p.probesLock.Unlock()
+	p.probes[hdr.PacketNumber] = size
+}
+
+func (p *PathMTU) AcknowledgedPacket(level logging.EncryptionLevel, number logging.PacketNumber) {
+	if level != logging.Encryption1RTT {
+		return
+	}
+	p.probesLock.Lock()
+	size, ok := p.probes[number]
+	if ok {
+		delete(p.probes, number)
+	}
+	p.probesLock.Unlock()
+	if ok {
+		p.increase(size)
+	}
+}
+
+func (p *PathMTU) LostPacket(level logging.EncryptionLevel, number logging.PacketNumber, _ logging.PacketLossReason) {
+	if level != logging.Encryption1RTT {
+		return
+	}
+	p.probesLock.Lock()
+	defer p.probesLock.Unlock()
+	delete(p.probes, number)
+}
+
+func (p *PathMTU) increase(size logging.ByteCount) {
+	for {
+		current := p.packetSize.Load()
+		if int64(size) <= current {
+			return
+		}
+		if p.packetSize.CompareAndSwap(current, int64(size)) {
+			clientMetrics.pathMTU.WithLabelValues(p.index).Set(float64(size))
+			p.dropSmallerProbes(size)
+			return
+		}
+	}
+}
+
+// dropSmallerProbes forgets the packets that aren't larger than the new packet size, they can't increase it.
+func (p *PathMTU) dropSmallerProbes(packetSize logging.ByteCount) {
+	p.probesLock.Lock()
+	defer p.probesLock.Unlock()
+	for number, size := range p.probes {
+		if size <= packetSize {
+			delete(p.probes, number)
+		}
+	}
+}
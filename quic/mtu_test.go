@@ -0,0 +1,47 @@
+//go:build !windows
+
+package quic
+
+import (
+	"testing"
+
+	"github.com/quic-go/quic-go/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathMTU(t *testing.T) {
+	var nilPathMTU *PathMTU
+	require.Equal(t, defaultDatagramSize, nilPathMTU.DatagramSize())
+
+	pathMTU := NewPathMTU(0)
+	require.Equal(t, defaultDatagramSize, pathMTU.DatagramSize())
+	pathMTU.ReceivedTransportParameters(&logging.TransportParameters{MaxDatagramFrameSize: 1500})
+
+	destConnID := logging.ConnectionID{}
+	sent := func(number logging.PacketNumber, size logging.ByteCount) {
+		pathMTU.SentShortHeaderPacket(&logging.ShortHeader{DestConnectionID: destConnID, PacketNumber: number}, size, nil, nil)
+	}
+	sent(1, 1252)
+	pathMTU.AcknowledgedPacket(logging.Encryption1RTT, 1)
+	require.Equal(t, 1252, pathMTU.PacketSize())
+	// The discovered size is smaller than the default one
+	require.Equal(t, defaultDatagramSize, pathMTU.DatagramSize())
+
+	// A lost probe doesn't change the size
+	sent(2, 1452)
+	pathMTU.LostPacket(logging.Encryption1RTT, 2, logging.PacketLossTimeThreshold)
+	pathMTU.AcknowledgedPacket(logging.Encryption1RTT, 2)
+	require.Equal(t, 1252, pathMTU.PacketSize())
+
+	sent(3, 1400)
+	sent(4, 1000)
+	pathMTU.AcknowledgedPacket(logging.Encryption1RTT, 4)
+	require.Equal(t, 1252, pathMTU.PacketSize())
+	pathMTU.AcknowledgedPacket(logging.Encryption1RTT, 3)
+	require.Equal(t, 1400, pathMTU.PacketSize())
+	require.Equal(t, 1400-shortHeaderOverhead-aeadOverhead-datagramFrameOverhead, pathMTU.DatagramSize())
+
+	// Datagrams are also bounded by the max_datagram_frame_size of the peer
+	pathMTU.ReceivedTransportParameters(&logging.TransportParameters{MaxDatagramFrameSize: 1350})
+	require.Equal(t, 1350-datagramFrameOverhead, pathMTU.DatagramSize())
+}
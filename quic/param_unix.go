@@ -3,7 +3,9 @@
 package quic
 
 const (
-	MaxDatagramFrameSize = 1350
-	// maxDatagramPayloadSize is the maximum packet size allowed by warp client
+	// MaxDatagramFrameSize is the largest QUIC packet quic-go can receive, so datagrams are only bounded by the path MTU
+	MaxDatagramFrameSize = 1452
+	// maxDatagramPayloadSize is the maximum packet size allowed by warp client. It's used until a larger path MTU is
+	// discovered
 	maxDatagramPayloadSize = 1280
 )
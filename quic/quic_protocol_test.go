@@ -153,7 +153,7 @@ func TestUnregisterUdpSession(t *testing.T) {
 			rpcClientStream, err := NewRPCClientStream(context.Background(), clientStream, test.timeout, &logger)
 			assert.NoError(t, err)
 
-			reg, err := rpcClientStream.RegisterUdpSession(context.Background(), test.sessionRPCServer.sessionID, test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext)
+			reg, err := rpcClientStream.RegisterUdpSession(context.Background(), test.sessionRPCServer.sessionID, test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext, test.sessionRPCServer.hostname, test.sessionRPCServer.maxDatagramSize)
 			assert.NoError(t, err)
 			assert.NoError(t, reg.Err)
 
@@ -195,6 +195,29 @@ func TestRegisterUdpSession(t *testing.T) {
 				traceContext:      "1241ce3ecdefc68854e8514e69ba42ca:b38f1bf5eae406f3:0:1",
 			},
 		},
+		{
+			name: "RegisterUdpSession (with hostname)",
+			sessionRPCServer: mockSessionRPCServer{
+				sessionID:         uuid.New(),
+				dstIP:             net.IP{172, 16, 0, 1},
+				dstPort:           8000,
+				closeIdleAfter:    testCloseIdleAfterHint,
+				unregisterMessage: unregisterMessage,
+				hostname:          "game.example.com",
+			},
+		},
+		{
+			name: "RegisterUdpSession (with max datagram size)",
+			sessionRPCServer: mockSessionRPCServer{
+				sessionID:         uuid.New(),
+				dstIP:             net.IP{172, 16, 0, 1},
+				dstPort:           8000,
+				closeIdleAfter:    testCloseIdleAfterHint,
+				unregisterMessage: unregisterMessage,
+				hostname:          "game.example.com",
+				maxDatagramSize:   1200,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -217,12 +240,13 @@ func TestRegisterUdpSession(t *testing.T) {
 			rpcClientStream, err := NewRPCClientStream(context.Background(), clientStream, 5*time.Second, &logger)
 			assert.NoError(t, err)
 
-			reg, err := rpcClientStream.RegisterUdpSession(context.Background(), test.sessionRPCServer.sessionID, test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext)
+			reg, err := rpcClientStream.RegisterUdpSession(context.Background(), test.sessionRPCServer.sessionID, test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext, test.sessionRPCServer.hostname, test.sessionRPCServer.maxDatagramSize)
 			assert.NoError(t, err)
 			assert.NoError(t, reg.Err)
+			assert.Equal(t, test.sessionRPCServer.maxDatagramSize, reg.MaxDatagramSize)
 
 			// Different sessionID, the RPC server should reject the registraion
-			reg, err = rpcClientStream.RegisterUdpSession(context.Background(), uuid.New(), test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext)
+			reg, err = rpcClientStream.RegisterUdpSession(context.Background(), uuid.New(), test.sessionRPCServer.dstIP, test.sessionRPCServer.dstPort, testCloseIdleAfterHint, test.sessionRPCServer.traceContext, test.sessionRPCServer.hostname, test.sessionRPCServer.maxDatagramSize)
 			assert.NoError(t, err)
 			assert.Error(t, reg.Err)
 
@@ -285,9 +309,11 @@ type mockSessionRPCServer struct {
 	closeIdleAfter    time.Duration
 	unregisterMessage string
 	traceContext      string
+	hostname          string
+	maxDatagramSize   uint16
 }
 
-func (s mockSessionRPCServer) RegisterUdpSession(_ context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeIdleAfter time.Duration, traceContext string) (*tunnelpogs.RegisterUdpSessionResponse, error) {
+func (s mockSessionRPCServer) RegisterUdpSession(_ context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeIdleAfter time.Duration, traceContext, hostname string, maxDatagramSize uint16) (*tunnelpogs.RegisterUdpSessionResponse, error) {
 	if s.sessionID != sessionID {
 		return nil, fmt.Errorf("expect session ID %s, got %s", s.sessionID, sessionID)
 	}
@@ -303,7 +329,14 @@ func (s mockSessionRPCServer) RegisterUdpSession(_ context.Context, sessionID uu
 	if s.traceContext != traceContext {
 		return nil, fmt.Errorf("expect traceContext %s, got %s", s.traceContext, traceContext)
 	}
-	return &tunnelpogs.RegisterUdpSessionResponse{}, nil
+	if s.hostname != hostname {
+		return nil, fmt.Errorf("expect hostname %s, got %s", s.hostname, hostname)
+	}
+	if s.maxDatagramSize != maxDatagramSize {
+		return nil, fmt.Errorf("expect maxDatagramSize %d, got %d", s.maxDatagramSize, maxDatagramSize)
+	}
+	// Accept the max datagram size the edge asks for
+	return &tunnelpogs.RegisterUdpSessionResponse{MaxDatagramSize: maxDatagramSize}, nil
 }
 
 func (s mockSessionRPCServer) UnregisterUdpSession(_ context.Context, sessionID uuid.UUID, message string) error {
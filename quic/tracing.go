@@ -5,10 +5,25 @@ import (
 	"net"
 	"time"
 
+	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/logging"
 	"github.com/rs/zerolog"
 )
 
+// TraceConnection returns a copy of config that also traces its connections to connTracer, along with the tracer of
+// config, if any.
+func TraceConnection(config *quic.Config, connTracer logging.ConnectionTracer) *quic.Config {
+	config = config.Clone()
+	tracer := config.Tracer
+	config.Tracer = func(ctx context.Context, perspective logging.Perspective, connID logging.ConnectionID) logging.ConnectionTracer {
+		if tracer == nil {
+			return connTracer
+		}
+		return logging.NewMultiplexedConnectionTracer(tracer(ctx, perspective, connID), connTracer)
+	}
+	return config
+}
+
 // QUICTracer is a wrapper to create new quicConnTracer
 type tracer struct {
 	logger *zerolog.Logger
@@ -0,0 +1,139 @@
+// Package recording captures HTTP exchanges proxied by cloudflared so they can later be replayed against a different
+// ingress configuration or origin version, and the responses compared.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RedactedValue replaces the value of sensitive headers and of query parameters in recordings.
+	RedactedValue = "REDACTED"
+
+	// maxLineSize bounds how large a single recorded exchange can be when read back.
+	maxLineSize = 64 * 1024 * 1024
+)
+
+// sensitiveHeaders are never written to a recording.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Cf-Access-Jwt-Assertion",
+	"Cf-Access-Token",
+	"Cf-Access-Client-Id",
+	"Cf-Access-Client-Secret",
+	"X-Api-Key",
+}
+
+// Exchange is a single recorded request and the response cloudflared returned for it.
+type Exchange struct {
+	Time                  time.Time   `json:"time"`
+	Method                string      `json:"method"`
+	Host                  string      `json:"host"`
+	URI                   string      `json:"uri"`
+	RequestHeaders        http.Header `json:"requestHeaders,omitempty"`
+	RequestBody           []byte      `json:"requestBody,omitempty"`
+	RequestBodyTruncated  bool        `json:"requestBodyTruncated,omitempty"`
+	Status                int         `json:"status"`
+	ResponseHeaders       http.Header `json:"responseHeaders,omitempty"`
+	ResponseBody          []byte      `json:"responseBody,omitempty"`
+	ResponseBodyTruncated bool        `json:"responseBodyTruncated,omitempty"`
+	Error                 string      `json:"error,omitempty"`
+}
+
+// NewRequest rebuilds the recorded request so it can be replayed.
+func (e *Exchange) NewRequest(ctx context.Context) (*http.Request, error) {
+	u, err := url.ParseRequestURI(e.URI)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recorded URI %s", e.URI)
+	}
+	u.Scheme = "http"
+	u.Host = e.Host
+
+	req, err := http.NewRequestWithContext(ctx, e.Method, u.String(), bytes.NewReader(e.RequestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = e.RequestHeaders.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	for _, h := range sensitiveHeaders {
+		if req.Header.Get(h) == RedactedValue {
+			req.Header.Del(h)
+		}
+	}
+	req.ContentLength = int64(len(e.RequestBody))
+	return req, nil
+}
+
+// sanitizeHeaders returns a copy of header with the values of sensitive headers redacted.
+func sanitizeHeaders(header http.Header) http.Header {
+	sanitized := header.Clone()
+	for _, h := range sensitiveHeaders {
+		if _, ok := sanitized[h]; ok {
+			sanitized[h] = []string{RedactedValue}
+		}
+	}
+	return sanitized
+}
+
+// sanitizeURI returns the request URI of u with the values of its query parameters redacted, since they commonly carry
+// tokens and signatures.
+func sanitizeURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		if name, _, ok := strings.Cut(param, "="); ok {
+			params[i] = name + "=" + RedactedValue
+		}
+	}
+	sanitized := *u
+	sanitized.RawQuery = strings.Join(params, "&")
+	return sanitized.RequestURI()
+}
+
+// ReadFile reads all exchanges from a recording file.
+func ReadFile(path string) ([]*Exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read decodes exchanges written by a Recorder, one per line.
+func Read(r io.Reader) ([]*Exchange, error) {
+	var exchanges []*Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, errors.Wrapf(err, "invalid exchange on line %d", line)
+		}
+		exchanges = append(exchanges, &exchange)
+	}
+	return exchanges, scanner.Err()
+}
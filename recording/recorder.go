@@ -0,0 +1,153 @@
+package recording
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxBodySize is how much of each request and response body is recorded by default.
+const DefaultMaxBodySize = 64 * 1024
+
+// Recorder writes sanitized exchanges to a file, one JSON object per line.
+type Recorder struct {
+	lock        sync.Mutex
+	out         io.WriteCloser
+	encoder     *json.Encoder
+	maxBodySize int
+}
+
+// NewFileRecorder creates a Recorder appending to the file at path. Bodies larger than maxBodySize are truncated.
+func NewFileRecorder(path string, maxBodySize int) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open traffic recording file")
+	}
+	return NewRecorder(f, maxBodySize), nil
+}
+
+// NewRecorder creates a Recorder writing to out.
+func NewRecorder(out io.WriteCloser, maxBodySize int) *Recorder {
+	if maxBodySize < 0 {
+		maxBodySize = 0
+	}
+	return &Recorder{
+		out:         out,
+		encoder:     json.NewEncoder(out),
+		maxBodySize: maxBodySize,
+	}
+}
+
+// Start begins recording the exchange for req. The request body is captured as it's read, so Start must be called
+// before the request is proxied. The returned Recording must be finished once the response has been written.
+func (r *Recorder) Start(req *http.Request) *Recording {
+	recording := &Recording{
+		recorder: r,
+		exchange: Exchange{
+			Time:           time.Now().UTC(),
+			Method:         req.Method,
+			Host:           req.Host,
+			URI:            sanitizeURI(req.URL),
+			RequestHeaders: sanitizeHeaders(req.Header),
+		},
+		requestBody:  limitedBuffer{limit: r.maxBodySize},
+		responseBody: limitedBuffer{limit: r.maxBodySize},
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &teeReadCloser{ReadCloser: req.Body, w: &recording.requestBody}
+	}
+	return recording
+}
+
+// Close closes the underlying output. Exchanges finished afterwards fail to be written.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.out.Close()
+}
+
+func (r *Recorder) write(exchange *Exchange) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.encoder.Encode(exchange)
+}
+
+// Recording is an exchange that is in progress.
+type Recording struct {
+	recorder     *Recorder
+	exchange     Exchange
+	requestBody  limitedBuffer
+	responseBody limitedBuffer
+	once         sync.Once
+}
+
+// RecordResponseHeaders records the status and headers sent to the eyeball.
+func (r *Recording) RecordResponseHeaders(status int, header http.Header) {
+	r.exchange.Status = status
+	r.exchange.ResponseHeaders = sanitizeHeaders(header)
+}
+
+// RecordResponseBody records a chunk of the response body sent to the eyeball.
+func (r *Recording) RecordResponseBody(p []byte) {
+	_, _ = r.responseBody.Write(p)
+}
+
+// Finish writes the exchange to the recording. err is the proxying error, if any.
+func (r *Recording) Finish(err error) error {
+	var writeErr error
+	r.once.Do(func() {
+		if err != nil {
+			r.exchange.Error = err.Error()
+		}
+		r.exchange.RequestBody, r.exchange.RequestBodyTruncated = r.requestBody.contents()
+		r.exchange.ResponseBody, r.exchange.ResponseBodyTruncated = r.responseBody.contents()
+		writeErr = r.recorder.write(&r.exchange)
+	})
+	return writeErr
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	lock      sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	remaining := b.limit - len(b.buf)
+	if len(p) > remaining {
+		b.buf = append(b.buf, p[:remaining]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
+
+// contents returns a copy of what was kept, and whether anything was discarded.
+func (b *limitedBuffer) contents() ([]byte, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]byte(nil), b.buf...), b.truncated
+}
+
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = t.w.Write(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,134 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (*bufferCloser) Close() error { return nil }
+
+func TestRecordSanitizesAndTruncates(t *testing.T) {
+	out := &bufferCloser{}
+	recorder := NewRecorder(out, 4)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api?q=1&token=secret&debug", strings.NewReader("hello world"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Custom", "value")
+	rec := recorder.Start(req)
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(body), "recording must not alter the proxied body")
+
+	rec.RecordResponseHeaders(http.StatusCreated, http.Header{"Set-Cookie": {"session=secret"}, "Content-Type": {"text/plain"}})
+	rec.RecordResponseBody([]byte("ok"))
+	require.NoError(t, rec.Finish(nil))
+	assert.NotContains(t, out.String(), "secret")
+
+	exchanges, err := Read(out)
+	require.NoError(t, err)
+	require.Len(t, exchanges, 1)
+	exchange := exchanges[0]
+	assert.Equal(t, http.MethodPost, exchange.Method)
+	assert.Equal(t, "example.com", exchange.Host)
+	assert.Equal(t, "/api?q=REDACTED&token=REDACTED&debug", exchange.URI)
+	assert.Equal(t, RedactedValue, exchange.RequestHeaders.Get("Authorization"))
+	assert.Equal(t, "value", exchange.RequestHeaders.Get("X-Custom"))
+	assert.Equal(t, "hell", string(exchange.RequestBody))
+	assert.True(t, exchange.RequestBodyTruncated)
+	assert.Equal(t, http.StatusCreated, exchange.Status)
+	assert.Equal(t, RedactedValue, exchange.ResponseHeaders.Get("Set-Cookie"))
+	assert.Equal(t, "ok", string(exchange.ResponseBody))
+	assert.False(t, exchange.ResponseBodyTruncated)
+
+	replayReq, err := exchange.NewRequest(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, replayReq.Header.Get("Authorization"), "redacted headers must not be replayed")
+	assert.Equal(t, "example.com", replayReq.Host)
+}
+
+func TestCompare(t *testing.T) {
+	recorded := &Exchange{
+		Status:          http.StatusOK,
+		ResponseHeaders: http.Header{"Content-Type": {"text/plain"}, "Date": {"yesterday"}, "X-Version": {"1"}},
+		ResponseBody:    []byte("abc"),
+	}
+	same := &Exchange{
+		Status:          http.StatusOK,
+		ResponseHeaders: http.Header{"Content-Type": {"text/plain"}, "Date": {"today"}, "X-Version": {"1"}},
+		ResponseBody:    []byte("abc"),
+	}
+	assert.Empty(t, Compare(recorded, same, nil))
+
+	changed := &Exchange{
+		Status:          http.StatusNotFound,
+		ResponseHeaders: http.Header{"Content-Type": {"text/html"}, "X-Version": {"2"}},
+		ResponseBody:    []byte("abd"),
+	}
+	assert.Len(t, Compare(recorded, changed, nil), 4)
+	assert.Len(t, Compare(recorded, changed, []string{"x-version"}), 3)
+
+	truncated := &Exchange{
+		Status:                http.StatusOK,
+		ResponseBody:          []byte("ab"),
+		ResponseBodyTruncated: true,
+	}
+	assert.Empty(t, Compare(truncated, &Exchange{Status: http.StatusOK, ResponseBody: []byte("abcdef")}, nil))
+	assert.Len(t, Compare(&Exchange{Status: http.StatusOK, ResponseBody: []byte("ab")}, &Exchange{Status: http.StatusOK, ResponseBody: []byte("abc")}, nil), 1)
+}
+
+type mockOriginProxy struct {
+	version string
+}
+
+func (p mockOriginProxy) ProxyHTTP(w connection.ResponseWriter, tr *tracing.TracedHTTPRequest, _ bool) error {
+	body, err := io.ReadAll(tr.Body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("X-Version", p.version)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(append([]byte(tr.Host+tr.URL.Path+":"), body...))
+	return err
+}
+
+func (mockOriginProxy) ProxyTCP(context.Context, connection.ReadWriteAcker, *connection.TCPRequest) error {
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	log := zerolog.Nop()
+	recorded := &Exchange{
+		Method:          http.MethodPut,
+		Host:            "example.com",
+		URI:             "/path",
+		RequestBody:     []byte("body"),
+		Status:          http.StatusOK,
+		ResponseHeaders: http.Header{"X-Version": {"1"}},
+		ResponseBody:    []byte("example.com/path:body"),
+	}
+
+	result, err := Replay(context.Background(), mockOriginProxy{version: "1"}, recorded, nil, &log)
+	require.NoError(t, err)
+	assert.Empty(t, result.Diffs)
+
+	result, err = Replay(context.Background(), mockOriginProxy{version: "2"}, recorded, nil, &log)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`header X-Version: recorded "1", replayed "2"`}, result.Diffs)
+}
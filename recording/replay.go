@@ -0,0 +1,163 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/tracing"
+)
+
+// volatileHeaders are expected to differ between a recording and its replay, so they are never compared.
+var volatileHeaders = []string{
+	"Date",
+	"Age",
+	"Expires",
+	"Cf-Ray",
+	"Traceparent",
+}
+
+// Result is the outcome of replaying a single exchange.
+type Result struct {
+	Recorded *Exchange
+	Replayed *Exchange
+	// Diffs describes how the replayed response differs from the recorded one. It's empty if they match.
+	Diffs []string
+}
+
+// Replay sends the recorded request through proxy and compares the response with the recorded one. Headers in
+// ignoreHeaders are not compared, in addition to those that are volatile or were redacted from the recording.
+func Replay(ctx context.Context, proxy connection.OriginProxy, recorded *Exchange, ignoreHeaders []string, log *zerolog.Logger) (*Result, error) {
+	req, err := recorded.NewRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	maxBodySize := len(recorded.ResponseBody)
+	if !recorded.ResponseBodyTruncated {
+		// Record one byte more than expected so a longer response is detected.
+		maxBodySize++
+	}
+	recorder := NewRecorder(nopWriteCloser{}, maxBodySize)
+	recording := recorder.Start(req)
+	w := newResponseWriter(recording)
+	proxyErr := proxy.ProxyHTTP(w, tracing.NewTracedHTTPRequest(req, 0, log), false)
+	if proxyErr != nil && !w.headersWritten {
+		// This is how the connection writers report a proxy error to the eyeball.
+		w.WriteRespHeaders(http.StatusBadGateway, nil)
+	}
+	recording.exchange.RequestBody, recording.exchange.RequestBodyTruncated = recording.requestBody.contents()
+	recording.exchange.ResponseBody, recording.exchange.ResponseBodyTruncated = recording.responseBody.contents()
+	if proxyErr != nil {
+		recording.exchange.Error = proxyErr.Error()
+	}
+
+	return &Result{
+		Recorded: recorded,
+		Replayed: &recording.exchange,
+		Diffs:    Compare(recorded, &recording.exchange, ignoreHeaders),
+	}, nil
+}
+
+// Compare describes every difference between the responses of two exchanges.
+func Compare(recorded, replayed *Exchange, ignoreHeaders []string) []string {
+	var diffs []string
+	if recorded.Status != replayed.Status {
+		diffs = append(diffs, fmt.Sprintf("status: recorded %d, replayed %d", recorded.Status, replayed.Status))
+	}
+
+	ignored := make(map[string]bool)
+	for _, h := range append(append(ignoreHeaders, volatileHeaders...), sensitiveHeaders...) {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+	names := make(map[string]bool)
+	for name := range recorded.ResponseHeaders {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	for name := range replayed.ResponseHeaders {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		if !ignored[name] {
+			sortedNames = append(sortedNames, name)
+		}
+	}
+	sort.Strings(sortedNames)
+	for _, name := range sortedNames {
+		recordedValue := strings.Join(recorded.ResponseHeaders.Values(name), ", ")
+		replayedValue := strings.Join(replayed.ResponseHeaders.Values(name), ", ")
+		if recordedValue != replayedValue {
+			diffs = append(diffs, fmt.Sprintf("header %s: recorded %q, replayed %q", name, recordedValue, replayedValue))
+		}
+	}
+
+	recordedBody, replayedBody := recorded.ResponseBody, replayed.ResponseBody
+	if recorded.ResponseBodyTruncated && len(replayedBody) > len(recordedBody) {
+		// Only the recorded prefix of the body is known, so only compare that much.
+		replayedBody = replayedBody[:len(recordedBody)]
+	}
+	if !bytes.Equal(recordedBody, replayedBody) {
+		diffs = append(diffs, fmt.Sprintf("body: recorded %d bytes, replayed %d bytes with different content", len(recordedBody), len(replayedBody)))
+	}
+	return diffs
+}
+
+// responseWriter is an in-memory connection.ResponseWriter that feeds a Recording.
+type responseWriter struct {
+	recording      *Recording
+	header         http.Header
+	headersWritten bool
+}
+
+func newResponseWriter(recording *Recording) *responseWriter {
+	return &responseWriter{
+		recording: recording,
+		header:    make(http.Header),
+	}
+}
+
+func (w *responseWriter) WriteRespHeaders(status int, header http.Header) error {
+	w.recording.RecordResponseHeaders(status, header)
+	w.headersWritten = true
+	return nil
+}
+
+func (w *responseWriter) WriteInterimResponse(status int, header http.Header) error {
+	return nil
+}
+
+func (w *responseWriter) AddTrailer(trailerName, trailerValue string) {}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	_ = w.WriteRespHeaders(status, w.header)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.headersWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.recording.RecordResponseBody(p)
+	return len(p), nil
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("replayed requests can't be hijacked")
+}
+
+type nopWriteCloser struct{}
+
+func (nopWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteCloser) Close() error                { return nil }
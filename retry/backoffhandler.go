@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"math"
 	"math/rand"
 	"time"
 )
@@ -31,6 +32,13 @@ type BackoffHandler struct {
 	RetryForever bool
 	// BaseTime sets the initial backoff period.
 	BaseTime time.Duration
+	// Multiplier is how much the backoff period grows with each retry. Defaults to 2.
+	Multiplier float64
+	// MaxBackoff caps the backoff period. 0 means it's only capped by MaxRetries.
+	MaxBackoff time.Duration
+	// MinBackoffRatio is the share of the backoff period that is always waited, the rest is random so that
+	// clients failing at once don't retry at once. 0 waits a random duration up to the period, 1 disables the jitter.
+	MinBackoffRatio float64
 
 	retries       uint
 	resetDeadline time.Time
@@ -51,7 +59,7 @@ func (b BackoffHandler) GetMaxBackoffDuration(ctx context.Context) (time.Duratio
 	if b.retries >= b.MaxRetries && !b.RetryForever {
 		return time.Duration(0), false
 	}
-	maxTimeToWait := b.GetBaseTime() * 1 << (b.retries + 1)
+	maxTimeToWait := b.backoffPeriod(b.retries + 1)
 	return maxTimeToWait, true
 }
 
@@ -69,8 +77,12 @@ func (b *BackoffHandler) BackoffTimer() <-chan time.Time {
 	} else {
 		b.retries++
 	}
-	maxTimeToWait := time.Duration(b.GetBaseTime() * 1 << (b.retries))
-	timeToWait := time.Duration(rand.Int63n(maxTimeToWait.Nanoseconds()))
+	maxTimeToWait := b.backoffPeriod(b.retries)
+	minTimeToWait := time.Duration(float64(maxTimeToWait) * b.minBackoffRatio())
+	timeToWait := minTimeToWait
+	if jitter := maxTimeToWait - minTimeToWait; jitter > 0 {
+		timeToWait += time.Duration(rand.Int63n(jitter.Nanoseconds()))
+	}
 	return Clock.After(timeToWait)
 }
 
@@ -97,6 +109,26 @@ func (b *BackoffHandler) SetGracePeriod() {
 	b.resetDeadline = Clock.Now().Add(timeToWait)
 }
 
+// backoffPeriod returns the longest time waited after the given number of retries.
+func (b BackoffHandler) backoffPeriod(retries uint) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	period := float64(b.GetBaseTime()) * math.Pow(multiplier, float64(retries))
+	if b.MaxBackoff > 0 && period > float64(b.MaxBackoff) {
+		return b.MaxBackoff
+	}
+	if period > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(period)
+}
+
+func (b BackoffHandler) minBackoffRatio() float64 {
+	return math.Max(0, math.Min(1, b.MinBackoffRatio))
+}
+
 func (b BackoffHandler) GetBaseTime() time.Duration {
 	if b.BaseTime == 0 {
 		return time.Second
@@ -145,3 +145,51 @@ func TestBackoffRetryForever(t *testing.T) {
 		t.Fatalf("backoff returned %v instead of 8 seconds on fifth retry", duration)
 	}
 }
+
+func TestBackoffPolicy(t *testing.T) {
+	var waited []time.Duration
+	Clock.After = func(d time.Duration) <-chan time.Time {
+		waited = append(waited, d)
+		return immediateTimeAfter(d)
+	}
+	ctx := context.Background()
+	backoff := BackoffHandler{
+		MaxRetries:      5,
+		BaseTime:        time.Second,
+		Multiplier:      3,
+		MaxBackoff:      20 * time.Second,
+		MinBackoffRatio: 1,
+	}
+	for backoff.Backoff(ctx) {
+	}
+	expected := []time.Duration{3 * time.Second, 9 * time.Second, 20 * time.Second, 20 * time.Second, 20 * time.Second}
+	if len(waited) != len(expected) {
+		t.Fatalf("backoff waited %d times, expected %d", len(waited), len(expected))
+	}
+	for i := range expected {
+		if waited[i] != expected[i] {
+			t.Errorf("retry %d waited %v, expected %v", i+1, waited[i], expected[i])
+		}
+	}
+	backoff.RetryForever = true
+	if duration, _ := backoff.GetMaxBackoffDuration(ctx); duration != 20*time.Second {
+		t.Errorf("max backoff duration is %v, expected it to be capped to 20s", duration)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	var waited time.Duration
+	Clock.After = func(d time.Duration) <-chan time.Time {
+		waited = d
+		return immediateTimeAfter(d)
+	}
+	ctx := context.Background()
+	backoff := BackoffHandler{MaxRetries: 1, BaseTime: time.Second, MinBackoffRatio: 0.75}
+	for i := 0; i < 100; i++ {
+		backoff.retries = 0
+		backoff.Backoff(ctx)
+		if waited < 1500*time.Millisecond || waited >= 2*time.Second {
+			t.Fatalf("backoff waited %v, expected between 1.5s and 2s", waited)
+		}
+	}
+}
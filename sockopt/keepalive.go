@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || netbsd || dragonfly
+
+package sockopt
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func setKeepAlive(fd uintptr, interval time.Duration, count int) error {
+	if interval > 0 {
+		// The interval is set in seconds, rounded up so a sub-second interval doesn't disable probes
+		secs := int((interval + time.Second - 1) / time.Second)
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, secs); err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPCNT, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
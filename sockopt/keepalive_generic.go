@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !dragonfly
+
+package sockopt
+
+import "time"
+
+// setKeepAlive is a no-op on the systems that don't have per-socket keepalive interval and probe count settings, such
+// as Windows and OpenBSD, where they are system-wide.
+func setKeepAlive(uintptr, time.Duration, int) error {
+	return nil
+}
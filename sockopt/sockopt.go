@@ -0,0 +1,101 @@
+// Package sockopt tunes TCP sockets beyond what net.Dialer exposes, so connections to origins and to the edge can be
+// adjusted for low latency or high throughput workloads.
+package sockopt
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Options are TCP socket settings. The zero value of each field keeps the default of the operating system, or Go's
+// default for NoDelay.
+type Options struct {
+	// NoDelay enables TCP_NODELAY, which sends small writes immediately instead of coalescing them. Go enables it by
+	// default.
+	NoDelay *bool
+	// KeepAliveInterval is the time between keepalive probes once a connection has been idle for the keepalive period
+	// of the dialer.
+	KeepAliveInterval time.Duration
+	// KeepAliveCount is the number of unanswered keepalive probes after which a connection is dropped.
+	KeepAliveCount int
+	// SendBuffer is the size of the socket's send buffer in bytes (SO_SNDBUF).
+	SendBuffer int
+	// ReceiveBuffer is the size of the socket's receive buffer in bytes (SO_RCVBUF).
+	ReceiveBuffer int
+}
+
+// IsZero checks if the options leave every setting to its default.
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// Control sets the buffer sizes of a socket before it connects, so the TCP window scale is negotiated for them. It
+// has the signature of net.Dialer.Control.
+func (o Options) Control(_, _ string, c syscall.RawConn) error {
+	if o.SendBuffer <= 0 && o.ReceiveBuffer <= 0 {
+		return nil
+	}
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = setBuffers(fd, o.SendBuffer, o.ReceiveBuffer)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Apply sets the options that Go would override if they were set by Control, on an established connection. Connections
+// that aren't TCP are left untouched.
+func (o Options) Apply(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if o.NoDelay != nil {
+		if err := tcpConn.SetNoDelay(*o.NoDelay); err != nil {
+			return err
+		}
+	}
+	if o.KeepAliveInterval <= 0 && o.KeepAliveCount <= 0 {
+		return nil
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = setKeepAlive(fd, o.KeepAliveInterval, o.KeepAliveCount)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// Dialer sets the options on the sockets dialed with d.
+func (o Options) Dialer(d *net.Dialer) {
+	if o.SendBuffer > 0 || o.ReceiveBuffer > 0 {
+		d.Control = o.Control
+	}
+}
+
+// WrapDial returns a dial function that applies the options to the connections dialed with dial. Dialers passed to
+// Dialer must be wrapped too, for the options that can only be set once connected.
+func (o Options) WrapDial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if o.NoDelay == nil && o.KeepAliveInterval <= 0 && o.KeepAliveCount <= 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if err := o.Apply(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
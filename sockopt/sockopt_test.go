@@ -0,0 +1,68 @@
+//go:build linux
+
+package sockopt
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func getsockopt(t *testing.T, conn net.Conn, level, opt int) int {
+	rawConn, err := conn.(syscall.Conn).SyscallConn()
+	require.NoError(t, err)
+	var value int
+	var sockErr error
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		value, sockErr = unix.GetsockoptInt(int(fd), level, opt)
+	}))
+	require.NoError(t, sockErr)
+	return value
+}
+
+func TestOptions(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	noDelay := false
+	opts := Options{
+		NoDelay:           &noDelay,
+		KeepAliveInterval: 1500 * time.Millisecond,
+		KeepAliveCount:    3,
+		ReceiveBuffer:     256 * 1024,
+	}
+	dialer := &net.Dialer{KeepAlive: 10 * time.Second}
+	opts.Dialer(dialer)
+	conn, err := opts.WrapDial(dialer.DialContext)(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, 0, getsockopt(t, conn, unix.IPPROTO_TCP, unix.TCP_NODELAY))
+	assert.Equal(t, 2, getsockopt(t, conn, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL))
+	assert.Equal(t, 3, getsockopt(t, conn, unix.IPPROTO_TCP, unix.TCP_KEEPCNT))
+	// The keepalive period of the dialer is kept
+	assert.Equal(t, 10, getsockopt(t, conn, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE))
+	// Linux doubles the requested size to account for its bookkeeping
+	assert.GreaterOrEqual(t, getsockopt(t, conn, unix.SOL_SOCKET, unix.SO_RCVBUF), 256*1024)
+}
+
+func TestIsZero(t *testing.T) {
+	assert.True(t, Options{}.IsZero())
+	assert.False(t, Options{KeepAliveCount: 1}.IsZero())
+}
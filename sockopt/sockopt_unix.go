@@ -0,0 +1,19 @@
+//go:build !windows
+
+package sockopt
+
+import "golang.org/x/sys/unix"
+
+func setBuffers(fd uintptr, sendBuffer, receiveBuffer int) error {
+	if sendBuffer > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, sendBuffer); err != nil {
+			return err
+		}
+	}
+	if receiveBuffer > 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, receiveBuffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
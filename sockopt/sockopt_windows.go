@@ -0,0 +1,19 @@
+//go:build windows
+
+package sockopt
+
+import "golang.org/x/sys/windows"
+
+func setBuffers(fd uintptr, sendBuffer, receiveBuffer int) error {
+	if sendBuffer > 0 {
+		if err := windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_SNDBUF, sendBuffer); err != nil {
+			return err
+		}
+	}
+	if receiveBuffer > 0 {
+		if err := windows.SetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, windows.SO_RCVBUF, receiveBuffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
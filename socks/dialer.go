@@ -11,6 +11,11 @@ type Dialer interface {
 	Dial(string) (io.ReadWriteCloser, *AddrSpec, error)
 }
 
+// PacketListener is implemented by the dialers that can relay the UDP datagrams of UDP ASSOCIATE requests
+type PacketListener interface {
+	ListenPacket() (net.PacketConn, *AddrSpec, error)
+}
+
 // NetDialer is a standard TCP dialer
 type NetDialer struct {
 }
@@ -33,6 +38,19 @@ func (d *NetDialer) Dial(address string) (io.ReadWriteCloser, *AddrSpec, error)
 	return c, &addr, nil
 }
 
+// ListenPacket opens a UDP socket on any address
+func (d *NetDialer) ListenPacket() (net.PacketConn, *AddrSpec, error) {
+	c, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local := c.LocalAddr().(*net.UDPAddr)
+	addr := AddrSpec{IP: local.IP, Port: local.Port}
+
+	return c, &addr, nil
+}
+
 // ConnDialer is like NetDialer but with an existing TCP dialer already created
 type ConnDialer struct {
 	conn net.Conn
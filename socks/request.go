@@ -99,6 +99,25 @@ func NewRequest(bufConn io.Reader) (*Request, error) {
 }
 
 func sendReply(w io.Writer, resp uint8, addr *AddrSpec) error {
+	addrSpec, err := encodeAddrSpec(addr)
+	if err != nil {
+		return err
+	}
+
+	// Format the message
+	msg := make([]byte, 3+len(addrSpec))
+	msg[0] = socks5Version
+	msg[1] = resp
+	msg[2] = 0 // Reserved
+	copy(msg[3:], addrSpec)
+
+	// Send the message
+	_, err = w.Write(msg)
+	return err
+}
+
+// encodeAddrSpec is the reverse of readAddrSpec, a nil address is encoded as 0.0.0.0:0
+func encodeAddrSpec(addr *AddrSpec) ([]byte, error) {
 	var addrType uint8
 	var addrBody []byte
 	var addrPort uint16
@@ -124,22 +143,15 @@ func sendReply(w io.Writer, resp uint8, addr *AddrSpec) error {
 		addrPort = uint16(addr.Port)
 
 	default:
-		return fmt.Errorf("Failed to format address: %v", addr)
+		return nil, fmt.Errorf("Failed to format address: %v", addr)
 	}
 
-	// Format the message
-	msg := make([]byte, 6+len(addrBody))
-	msg[0] = socks5Version
-	msg[1] = resp
-	msg[2] = 0 // Reserved
-	msg[3] = addrType
-	copy(msg[4:], addrBody)
-	msg[4+len(addrBody)] = byte(addrPort >> 8)
-	msg[4+len(addrBody)+1] = byte(addrPort & 0xff)
-
-	// Send the message
-	_, err := w.Write(msg)
-	return err
+	msg := make([]byte, 3+len(addrBody))
+	msg[0] = addrType
+	copy(msg[1:], addrBody)
+	msg[1+len(addrBody)] = byte(addrPort >> 8)
+	msg[1+len(addrBody)+1] = byte(addrPort & 0xff)
+	return msg, nil
 }
 
 // readAddrSpec is used to read AddrSpec.
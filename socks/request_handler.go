@@ -122,15 +122,6 @@ func (h *StandardRequestHandler) handleBind(conn io.ReadWriter, req *Request) er
 	return nil
 }
 
-// handleAssociate is used to handle a connect command
-// TODO: Support associate command
-func (h *StandardRequestHandler) handleAssociate(conn io.ReadWriter, req *Request) error {
-	if err := sendReply(conn, commandNotSupported, nil); err != nil {
-		return fmt.Errorf("Failed to send reply: %v", err)
-	}
-	return nil
-}
-
 func StreamHandler(tunnelConn io.ReadWriter, originConn net.Conn, log *zerolog.Logger) {
 	dialer := NewConnDialer(originConn)
 	requestHandler := NewRequestHandler(dialer, nil)
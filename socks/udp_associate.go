@@ -0,0 +1,174 @@
+package socks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/cloudflare/cloudflared/ipaccess"
+)
+
+// The clients of a SOCKS5 server behind a tunnel can't reach the UDP relay it opens for a UDP ASSOCIATE request, so
+// the datagrams are carried over the TCP connection of the request instead, once the server replied. Each one is
+// prefixed with its length as 2 bytes, and has the header of https://tools.ietf.org/html/rfc1928#section-7.
+
+// maxDatagramSize is the largest UDP datagram, and the largest datagram carried over the connection of a request
+const maxDatagramSize = 65535
+
+// writeDatagram writes a datagram with its length to the connection of a UDP ASSOCIATE request.
+func writeDatagram(w io.Writer, dgram []byte) error {
+	if len(dgram) > maxDatagramSize {
+		return fmt.Errorf("datagram of %d bytes is too large", len(dgram))
+	}
+	msg := make([]byte, 2+len(dgram))
+	binary.BigEndian.PutUint16(msg, uint16(len(dgram)))
+	copy(msg[2:], dgram)
+	_, err := w.Write(msg)
+	return err
+}
+
+// readDatagram reads the next datagram from the connection of a UDP ASSOCIATE request.
+func readDatagram(r io.Reader) ([]byte, error) {
+	length := []byte{0, 0}
+	if _, err := io.ReadFull(r, length); err != nil {
+		return nil, err
+	}
+	dgram := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(r, dgram); err != nil {
+		return nil, err
+	}
+	return dgram, nil
+}
+
+// parseDatagram returns the address and data of a datagram that has a SOCKS5 UDP request header.
+func parseDatagram(dgram []byte) (*AddrSpec, []byte, error) {
+	if len(dgram) < 4 {
+		return nil, nil, fmt.Errorf("datagram of %d bytes is too short", len(dgram))
+	}
+	// Fragments aren't supported, they're dropped
+	if dgram[2] != 0 {
+		return nil, nil, fmt.Errorf("fragmented datagrams aren't supported")
+	}
+	r := bytes.NewReader(dgram[3:])
+	addr, err := readAddrSpec(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, dgram[len(dgram)-r.Len():], nil
+}
+
+// newDatagram prefixes data with the SOCKS5 UDP request header of addr.
+func newDatagram(addr *AddrSpec, data []byte) ([]byte, error) {
+	addrSpec, err := encodeAddrSpec(addr)
+	if err != nil {
+		return nil, err
+	}
+	dgram := make([]byte, 3, 3+len(addrSpec)+len(data))
+	dgram = append(dgram, addrSpec...)
+	return append(dgram, data...), nil
+}
+
+// handleAssociate is used to handle a UDP associate command. The datagrams of the client are sent from a UDP socket to
+// their destination, and the datagrams that the destinations send back are relayed to the client, until the
+// connection of the request ends.
+func (h *StandardRequestHandler) handleAssociate(conn io.ReadWriter, req *Request) error {
+	listener, ok := h.dialer.(PacketListener)
+	if !ok {
+		if err := sendReply(conn, commandNotSupported, nil); err != nil {
+			return fmt.Errorf("Failed to send reply: %v", err)
+		}
+		return nil
+	}
+
+	relay, localAddr, err := listener.ListenPacket()
+	if err != nil {
+		_ = sendReply(conn, serverFailure, nil)
+		return fmt.Errorf("Failed to open UDP relay: %v", err)
+	}
+	defer relay.Close()
+
+	if err := sendReply(conn, successReply, localAddr); err != nil {
+		return fmt.Errorf("Failed to send reply: %v", err)
+	}
+
+	association := &udpAssociation{
+		relay:        relay,
+		accessPolicy: h.accessPolicy,
+		destinations: make(map[string]struct{}),
+	}
+	go func() {
+		_ = association.relayResponses(conn)
+	}()
+	err = association.relayRequests(req.bufConn)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// udpAssociation relays the datagrams of a UDP ASSOCIATE request between the client and their destinations.
+type udpAssociation struct {
+	relay        net.PacketConn
+	accessPolicy *ipaccess.Policy
+
+	lock sync.RWMutex
+	// destinations are the addresses that the client sent datagrams to, the only ones whose datagrams are relayed back
+	destinations map[string]struct{}
+}
+
+// relayRequests sends the datagrams read from the client to their destination, if the access policy allows it.
+func (a *udpAssociation) relayRequests(r io.Reader) error {
+	for {
+		dgram, err := readDatagram(r)
+		if err != nil {
+			return err
+		}
+		dest, data, err := parseDatagram(dgram)
+		if err != nil {
+			continue
+		}
+		addr, err := net.ResolveUDPAddr("udp", dest.Address())
+		if err != nil {
+			continue
+		}
+		if a.accessPolicy != nil {
+			if allowed, _ := a.accessPolicy.Allowed(addr.IP, addr.Port); !allowed {
+				continue
+			}
+		}
+		a.lock.Lock()
+		a.destinations[addr.String()] = struct{}{}
+		a.lock.Unlock()
+		// Like the datagrams themselves, failures to send them are dropped
+		_, _ = a.relay.WriteTo(data, addr)
+	}
+}
+
+// relayResponses sends the datagrams from the destinations of the client back to it, until the relay is closed.
+func (a *udpAssociation) relayResponses(w io.Writer) error {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := a.relay.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		a.lock.RLock()
+		_, known := a.destinations[from.String()]
+		a.lock.RUnlock()
+		udpAddr, ok := from.(*net.UDPAddr)
+		if !known || !ok {
+			continue
+		}
+		dgram, err := newDatagram(&AddrSpec{IP: udpAddr.IP, Port: udpAddr.Port}, buf[:n])
+		if err != nil || len(dgram) > maxDatagramSize {
+			continue
+		}
+		if err := writeDatagram(w, dgram); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package socks
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/ipaccess"
+)
+
+// startUDPEcho starts a UDP server that sends back every datagram it gets.
+func startUDPEcho(t *testing.T) *net.UDPAddr {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(buf[:n], from)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+// startUpstreamSOCKS starts a local SOCKS5 server that sends its requests to a SOCKS5 server with accessPolicy, like
+// `cloudflared access tcp` with a socks5:// listener does with a socks-proxy origin.
+func startUpstreamSOCKS(t *testing.T, accessPolicy *ipaccess.Policy) net.Addr {
+	dial := func() (io.ReadWriteCloser, error) {
+		local, remote := net.Pipe()
+		go func() {
+			defer remote.Close()
+			_ = NewConnectionHandler(NewRequestHandler(NewNetDialer(), accessPolicy)).Serve(remote)
+		}()
+		return local, nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = NewConnectionHandler(NewUpstreamRequestHandler(dial)).Serve(conn)
+			}()
+		}
+	}()
+	return listener.Addr()
+}
+
+// associate sends a UDP ASSOCIATE request to the SOCKS5 server at addr, and returns the connection of the request
+// with the address of the UDP relay.
+func associate(t *testing.T, addr net.Addr) (net.Conn, *net.UDPAddr) {
+	conn, err := net.Dial("tcp", addr.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_, err = conn.Write([]byte{socks5Version, 1, NoAuth})
+	require.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(conn, method)
+	require.NoError(t, err)
+	require.Equal(t, []byte{socks5Version, NoAuth}, method)
+
+	_, err = conn.Write(createRequestData(socks5Version, associateCommand, net.IPv4zero.To4(), 0))
+	require.NoError(t, err)
+	reply := make([]byte, 3)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	require.Equal(t, successReply, reply[1])
+	relay, err := readAddrSpec(conn)
+	require.NoError(t, err)
+	return conn, &net.UDPAddr{IP: relay.IP, Port: relay.Port}
+}
+
+func TestUDPAssociate(t *testing.T) {
+	echo := startUDPEcho(t)
+	_, relay := associate(t, startUpstreamSOCKS(t, nil))
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer client.Close()
+	dgram, err := newDatagram(&AddrSpec{IP: echo.IP, Port: echo.Port}, []byte("dns query"))
+	require.NoError(t, err)
+	_, err = client.WriteTo(dgram, relay)
+	require.NoError(t, err)
+
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, maxDatagramSize)
+	n, _, err := client.ReadFrom(buf)
+	require.NoError(t, err)
+	from, data, err := parseDatagram(buf[:n])
+	require.NoError(t, err)
+	assert.True(t, echo.IP.Equal(from.IP))
+	assert.Equal(t, echo.Port, from.Port)
+	assert.Equal(t, []byte("dns query"), data)
+}
+
+func TestUDPAssociateIPAccess(t *testing.T) {
+	echo := startUDPEcho(t)
+	accessPolicy, err := ipaccess.NewPolicy(false, nil)
+	require.NoError(t, err)
+	_, relay := associate(t, startUpstreamSOCKS(t, accessPolicy))
+
+	client, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer client.Close()
+	dgram, err := newDatagram(&AddrSpec{IP: echo.IP, Port: echo.Port}, []byte("dns query"))
+	require.NoError(t, err)
+	_, err = client.WriteTo(dgram, relay)
+	require.NoError(t, err)
+
+	// The datagram is dropped, since the policy doesn't allow its destination
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, _, err = client.ReadFrom(make([]byte, maxDatagramSize))
+	assert.Error(t, err)
+}
+
+func TestParseDatagram(t *testing.T) {
+	dgram, err := newDatagram(&AddrSpec{FQDN: "example.com", Port: 53}, []byte("query"))
+	require.NoError(t, err)
+	addr, data, err := parseDatagram(dgram)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", addr.FQDN)
+	assert.Equal(t, 53, addr.Port)
+	assert.Equal(t, []byte("query"), data)
+
+	// Fragments aren't supported
+	dgram[2] = 1
+	_, _, err = parseDatagram(dgram)
+	assert.Error(t, err)
+}
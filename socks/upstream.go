@@ -0,0 +1,210 @@
+package socks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// UpstreamRequestHandler serves the CONNECT and UDP ASSOCIATE requests of local clients by sending them to another
+// SOCKS5 server, like the socks-proxy origin of a tunnel reached through `cloudflared access tcp`. The UDP datagrams of
+// the clients are sent to a local UDP relay, which carries them to the upstream server over the connection of the
+// request.
+type UpstreamRequestHandler struct {
+	dial func() (io.ReadWriteCloser, error)
+}
+
+// NewUpstreamRequestHandler creates a request handler that sends every request to a new connection returned by dial.
+func NewUpstreamRequestHandler(dial func() (io.ReadWriteCloser, error)) RequestHandler {
+	return &UpstreamRequestHandler{
+		dial: dial,
+	}
+}
+
+// Handle sends the request upstream, and proxies its connection once the upstream server accepted it
+func (h *UpstreamRequestHandler) Handle(req *Request, conn io.ReadWriter) error {
+	if req.Command != connectCommand && req.Command != associateCommand {
+		if err := sendReply(conn, commandNotSupported, nil); err != nil {
+			return fmt.Errorf("Failed to send reply: %v", err)
+		}
+		return fmt.Errorf("Unsupported command: %v", req.Command)
+	}
+
+	upstream, err := h.dial()
+	if err != nil {
+		_ = sendReply(conn, serverFailure, nil)
+		return fmt.Errorf("Failed to connect to the upstream SOCKS server: %v", err)
+	}
+	defer upstream.Close()
+
+	upstreamReader := bufio.NewReader(upstream)
+	reply, boundAddr, err := sendUpstreamRequest(upstream, upstreamReader, req)
+	if err != nil {
+		_ = sendReply(conn, serverFailure, nil)
+		return fmt.Errorf("Failed to send the request to the upstream SOCKS server: %v", err)
+	}
+	if reply != successReply {
+		if err := sendReply(conn, reply, boundAddr); err != nil {
+			return fmt.Errorf("Failed to send reply: %v", err)
+		}
+		return fmt.Errorf("Upstream SOCKS server rejected the request to %v: %v", req.DestAddr, reply)
+	}
+
+	if req.Command == associateCommand {
+		return relayUpstreamDatagrams(conn, req, upstream, upstreamReader)
+	}
+
+	if err := sendReply(conn, successReply, boundAddr); err != nil {
+		return fmt.Errorf("Failed to send reply: %v", err)
+	}
+	proxyDone := make(chan error, 2)
+	go func() {
+		_, e := io.Copy(upstream, req.bufConn)
+		proxyDone <- e
+	}()
+	go func() {
+		_, e := io.Copy(conn, upstreamReader)
+		proxyDone <- e
+	}()
+	return <-proxyDone
+}
+
+// sendUpstreamRequest negotiates no authentication with the upstream server and sends it req. It returns the reply of
+// the server and the address it bound.
+func sendUpstreamRequest(upstream io.Writer, upstreamReader io.Reader, req *Request) (uint8, *AddrSpec, error) {
+	if _, err := upstream.Write([]byte{socks5Version, 1, NoAuth}); err != nil {
+		return 0, nil, err
+	}
+	method := []byte{0, 0}
+	if _, err := io.ReadFull(upstreamReader, method); err != nil {
+		return 0, nil, err
+	}
+	if method[0] != socks5Version || method[1] != NoAuth {
+		return 0, nil, fmt.Errorf("upstream SOCKS server requires authentication method %v", method[1])
+	}
+
+	addrSpec, err := encodeAddrSpec(req.DestAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := upstream.Write(append([]byte{socks5Version, req.Command, 0}, addrSpec...)); err != nil {
+		return 0, nil, err
+	}
+	header := []byte{0, 0, 0}
+	if _, err := io.ReadFull(upstreamReader, header); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != socks5Version {
+		return 0, nil, fmt.Errorf("Unsupported reply version: %v", header[0])
+	}
+	boundAddr, err := readAddrSpec(upstreamReader)
+	if err != nil {
+		return 0, nil, err
+	}
+	return header[1], boundAddr, nil
+}
+
+// relayUpstreamDatagrams opens a local UDP relay for a UDP ASSOCIATE request that the upstream server accepted, and
+// carries the datagrams between it and the upstream connection until either connection of the request ends.
+func relayUpstreamDatagrams(conn io.ReadWriter, req *Request, upstream io.Writer, upstreamReader io.Reader) error {
+	// The relay listens on the address the client connected to, so it's reachable by the client
+	host := "127.0.0.1"
+	if c, ok := conn.(net.Conn); ok {
+		if local, ok := c.LocalAddr().(*net.TCPAddr); ok {
+			host = local.IP.String()
+		}
+	}
+	relay, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		_ = sendReply(conn, serverFailure, nil)
+		return fmt.Errorf("Failed to open UDP relay: %v", err)
+	}
+	defer relay.Close()
+
+	local := relay.LocalAddr().(*net.UDPAddr)
+	if err := sendReply(conn, successReply, &AddrSpec{IP: local.IP, Port: local.Port}); err != nil {
+		return fmt.Errorf("Failed to send reply: %v", err)
+	}
+
+	client := newUDPClient(req.DestAddr)
+	relayDone := make(chan error, 3)
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, from, err := relay.ReadFrom(buf)
+			if err != nil {
+				relayDone <- err
+				return
+			}
+			if !client.accept(from) {
+				continue
+			}
+			if err := writeDatagram(upstream, buf[:n]); err != nil {
+				relayDone <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			dgram, err := readDatagram(upstreamReader)
+			if err != nil {
+				relayDone <- err
+				return
+			}
+			if addr := client.address(); addr != nil {
+				_, _ = relay.WriteTo(dgram, addr)
+			}
+		}
+	}()
+	go func() {
+		// The association ends with the connection of the request, which carries nothing else
+		_, err := io.Copy(io.Discard, req.bufConn)
+		relayDone <- err
+	}()
+	err = <-relayDone
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// udpClient is the address of the client of a UDP ASSOCIATE request, learned from its first datagram. Datagrams from
+// other addresses are dropped.
+type udpClient struct {
+	expected *AddrSpec
+	addr     atomic.Pointer[net.UDPAddr]
+}
+
+func newUDPClient(expected *AddrSpec) *udpClient {
+	return &udpClient{expected: expected}
+}
+
+// accept tells whether a datagram from addr comes from the client.
+func (c *udpClient) accept(from net.Addr) bool {
+	udpAddr, ok := from.(*net.UDPAddr)
+	if !ok {
+		return false
+	}
+	if known := c.addr.Load(); known != nil {
+		return known.IP.Equal(udpAddr.IP) && known.Port == udpAddr.Port
+	}
+	// The request may tell the address the client sends datagrams from, 0 meaning any
+	if c.expected != nil {
+		if len(c.expected.IP) != 0 && !c.expected.IP.IsUnspecified() && !c.expected.IP.Equal(udpAddr.IP) {
+			return false
+		}
+		if c.expected.Port != 0 && c.expected.Port != udpAddr.Port {
+			return false
+		}
+	}
+	c.addr.Store(udpAddr)
+	return true
+}
+
+func (c *udpClient) address() *net.UDPAddr {
+	return c.addr.Load()
+}
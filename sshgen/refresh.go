@@ -0,0 +1,175 @@
+package sshgen
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	// refreshRetryInterval is how long RefreshShortLivedCertificate waits to try again after it failed to renew a cert
+	refreshRetryInterval = 30 * time.Second
+	// agentCertificateMinLifetime is how long a cert in the SSH agent must still be valid for to be used rather than
+	// replaced, so that it doesn't expire while SSH authenticates with it
+	agentCertificateMinLifetime = 30 * time.Second
+)
+
+// CertificateInstaller installs a short lived cert signed by Access for the application at appURL, and returns when
+// the cert expires.
+type CertificateInstaller func(appURL *url.URL, token string) (time.Time, error)
+
+// InstallCertificateInAgent returns a CertificateInstaller that adds a new keypair and its short lived cert to
+// sshAgent. The agent forgets them when the cert expires, so no key is ever written to disk.
+func InstallCertificateInAgent(sshAgent agent.Agent) CertificateInstaller {
+	return func(appURL *url.URL, token string) (time.Time, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return time.Time{}, err
+		}
+		pub, err := gossh.NewPublicKey(&key.PublicKey)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		signRes, err := signCertificate(token, string(gossh.MarshalAuthorizedKey(pub)))
+		if err != nil {
+			return time.Time{}, err
+		}
+		parsed, _, _, _, err := gossh.ParseAuthorizedKey([]byte(signRes.Certificate))
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to parse the signed certificate")
+		}
+		cert, ok := parsed.(*gossh.Certificate)
+		if !ok {
+			return time.Time{}, errors.New("Access didn't sign a certificate")
+		}
+
+		expiresAt := signRes.expiry()
+		addedKey := agent.AddedKey{
+			PrivateKey:  key,
+			Certificate: cert,
+			Comment:     agentKeyComment(appURL),
+		}
+		if !expiresAt.IsZero() {
+			addedKey.LifetimeSecs = agentLifetimeSecs(time.Now(), expiresAt)
+		}
+		if err := sshAgent.Add(addedKey); err != nil {
+			return time.Time{}, errors.Wrap(err, "failed to add the certificate to the SSH agent")
+		}
+		return expiresAt, nil
+	}
+}
+
+// HasCertificateInAgent tells whether sshAgent holds a cert that InstallCertificateInAgent added for the application
+// at appURL, and that doesn't expire soon, in which case there is no need to generate another one.
+func HasCertificateInAgent(sshAgent agent.Agent, appURL *url.URL) (bool, error) {
+	keys, err := sshAgent.List()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list the keys of the SSH agent")
+	}
+	comment := agentKeyComment(appURL)
+	minValidBefore := time.Now().Add(agentCertificateMinLifetime)
+	for _, key := range keys {
+		if key.Comment != comment {
+			continue
+		}
+		pub, err := gossh.ParsePublicKey(key.Blob)
+		if err != nil {
+			continue
+		}
+		cert, ok := pub.(*gossh.Certificate)
+		if !ok {
+			continue
+		}
+		if cert.ValidBefore == gossh.CertTimeInfinity || time.Unix(int64(cert.ValidBefore), 0).After(minValidBefore) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// agentKeyComment is the comment of the keys InstallCertificateInAgent adds for the application at appURL
+func agentKeyComment(appURL *url.URL) string {
+	return "cloudflared access " + appURL.Host
+}
+
+// agentLifetimeSecs returns the lifetime in the SSH agent of a cert that expires at expiresAt. It is at least a
+// second, since the agent keeps a key with no lifetime forever.
+func agentLifetimeSecs(now, expiresAt time.Time) uint32 {
+	lifetime := expiresAt.Sub(now)
+	if lifetime < time.Second {
+		return 1
+	}
+	return uint32(lifetime.Seconds())
+}
+
+// RefreshShortLivedCertificate installs a short lived cert for the application at appURL, and a new one before each
+// of them expires, until ctx is done. fetchToken is called before each renewal, since the Access token may have
+// expired meanwhile. Only a failure to install the first cert is returned; failed renewals are logged and retried.
+func RefreshShortLivedCertificate(
+	ctx context.Context,
+	appURL *url.URL,
+	fetchToken func() (string, error),
+	install CertificateInstaller,
+	log *zerolog.Logger,
+) error {
+	expiresAt, err := installCertificate(appURL, fetchToken, install)
+	if err != nil {
+		return err
+	}
+	if expiresAt.IsZero() {
+		return errors.New("the certificate signed by Access doesn't expire, there is nothing to refresh")
+	}
+
+	for {
+		log.Info().Msgf("Installed a short lived certificate for %s, which expires at %s", appURL.Host, expiresAt.Format(time.RFC3339))
+		timer := time.NewTimer(refreshWait(time.Now(), expiresAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		renewedExpiresAt, err := installCertificate(appURL, fetchToken, install)
+		for err != nil || renewedExpiresAt.IsZero() {
+			if err == nil {
+				err = errors.New("the renewed certificate doesn't expire")
+			}
+			log.Error().Err(err).Msgf("Failed to renew the short lived certificate for %s, retrying in %s", appURL.Host, refreshRetryInterval)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(refreshRetryInterval):
+			}
+			renewedExpiresAt, err = installCertificate(appURL, fetchToken, install)
+		}
+		expiresAt = renewedExpiresAt
+	}
+}
+
+func installCertificate(appURL *url.URL, fetchToken func() (string, error), install CertificateInstaller) (time.Time, error) {
+	token, err := fetchToken()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to fetch an Access token")
+	}
+	return install(appURL, token)
+}
+
+// refreshWait returns how long to wait before renewing a cert that expires at expiresAt: once 4/5 of its remaining
+// lifetime went by, so that a renewal that fails has some time to be retried.
+func refreshWait(now, expiresAt time.Time) time.Duration {
+	wait := expiresAt.Sub(now) * 4 / 5
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
@@ -0,0 +1,151 @@
+//go:build !windows
+// +build !windows
+
+package sshgen
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// mockSignEndpoint makes the Sign endpoint sign the public keys it gets with a test CA, for certs valid for lifetime
+func mockSignEndpoint(t *testing.T, lifetime time.Duration) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ca, err := gossh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	mockRequest = func(url, contentType string, body io.Reader) (*http.Response, error) {
+		var payload signPayload
+		require.NoError(t, json.NewDecoder(body).Decode(&payload))
+		pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(payload.PublicKey))
+		require.NoError(t, err)
+
+		expiresAt := time.Now().Add(lifetime).Truncate(time.Second)
+		cert := &gossh.Certificate{
+			Key:             pub,
+			CertType:        gossh.UserCert,
+			ValidPrincipals: []string{"dalton"},
+			ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+			ValidBefore:     uint64(expiresAt.Unix()),
+		}
+		require.NoError(t, cert.SignCert(rand.Reader, ca))
+
+		w := httptest.NewRecorder()
+		require.NoError(t, json.NewEncoder(w).Encode(signResponse{
+			KeyID:       "test",
+			Certificate: string(gossh.MarshalAuthorizedKey(cert)),
+			ExpiresAt:   expiresAt,
+		}))
+		return w.Result(), nil
+	}
+	t.Cleanup(func() { mockRequest = nil })
+}
+
+func TestInstallCertificateInAgent(t *testing.T) {
+	mockSignEndpoint(t, 3*time.Minute)
+	appURL, _ := url.Parse("https://cf-test-access.com/testpath")
+	keyring := agent.NewKeyring()
+
+	expiresAt, err := InstallCertificateInAgent(keyring)(appURL, tokenGenerator())
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(3*time.Minute), expiresAt, 2*time.Second)
+
+	keys, err := keyring.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, gossh.CertAlgoECDSA256v01, keys[0].Type())
+	assert.Equal(t, "cloudflared access cf-test-access.com", keys[0].Comment)
+}
+
+func TestHasCertificateInAgent(t *testing.T) {
+	appURL, _ := url.Parse("https://cf-test-access.com/testpath")
+	otherURL, _ := url.Parse("https://other-access.com")
+	keyring := agent.NewKeyring()
+
+	ok, err := HasCertificateInAgent(keyring, appURL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// A cert that is about to expire is replaced
+	mockSignEndpoint(t, 10*time.Second)
+	_, err = InstallCertificateInAgent(keyring)(appURL, tokenGenerator())
+	require.NoError(t, err)
+	ok, err = HasCertificateInAgent(keyring, appURL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mockSignEndpoint(t, 3*time.Minute)
+	_, err = InstallCertificateInAgent(keyring)(appURL, tokenGenerator())
+	require.NoError(t, err)
+	ok, err = HasCertificateInAgent(keyring, appURL)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = HasCertificateInAgent(keyring, otherURL)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestAgentLifetimeSecs(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, uint32(300), agentLifetimeSecs(now, now.Add(5*time.Minute)))
+	assert.Equal(t, uint32(1), agentLifetimeSecs(now, now.Add(time.Millisecond)))
+	assert.Equal(t, uint32(1), agentLifetimeSecs(now, now.Add(-time.Minute)))
+}
+
+func TestRefreshShortLivedCertificate(t *testing.T) {
+	appURL, _ := url.Parse("https://cf-test-access.com/testpath")
+	log := zerolog.Nop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	installed := make(chan string, 3)
+	fetched := 0
+	fetchToken := func() (string, error) {
+		fetched++
+		return "token", nil
+	}
+	install := func(appURL *url.URL, token string) (time.Time, error) {
+		installed <- token
+		if len(installed) == cap(installed) {
+			cancel()
+		}
+		return time.Now().Add(time.Millisecond), nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- RefreshShortLivedCertificate(ctx, appURL, fetchToken, install, &log)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("certificate wasn't refreshed")
+	}
+	assert.Len(t, installed, 3)
+	assert.Equal(t, 3, fetched)
+}
+
+func TestRefreshWait(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, 4*time.Minute, refreshWait(now, now.Add(5*time.Minute)))
+	assert.Equal(t, time.Second, refreshWait(now, now.Add(time.Second)))
+	assert.Equal(t, time.Second, refreshWait(now, now.Add(-time.Minute)))
+}
@@ -43,6 +43,22 @@ type signResponse struct {
 	ExpiresAt   time.Time `json:"expires_at"`
 }
 
+// expiry returns when the certificate expires, from the certificate itself if the response doesn't tell
+func (r *signResponse) expiry() time.Time {
+	if !r.ExpiresAt.IsZero() {
+		return r.ExpiresAt
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey([]byte(r.Certificate))
+	if err != nil {
+		return time.Time{}
+	}
+	cert, ok := pub.(*gossh.Certificate)
+	if !ok || cert.ValidBefore == gossh.CertTimeInfinity {
+		return time.Time{}
+	}
+	return time.Unix(int64(cert.ValidBefore), 0)
+}
+
 // ErrorResponse struct stores error information after any error-prone function
 type errorResponse struct {
 	Status  int    `json:"status"`
@@ -53,49 +69,66 @@ var mockRequest func(url, contentType string, body io.Reader) (*http.Response, e
 
 // GenerateShortLivedCertificate generates and stores a keypair for short lived certs
 func GenerateShortLivedCertificate(appURL *url.URL, token string) error {
+	_, err := InstallCertificateFile(appURL, token)
+	return err
+}
+
+// InstallCertificateFile stores a keypair and its short lived cert signed by Access in the homedir, where the
+// IdentityFile and CertificateFile of `cloudflared access ssh-config --short-lived-cert` point.
+// It returns when the cert expires.
+func InstallCertificateFile(appURL *url.URL, token string) (time.Time, error) {
 	fullName, err := cfpath.GenerateSSHCertFilePathFromURL(appURL, keyName)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 
-	cert, err := handleCertificateGeneration(token, fullName)
+	signRes, err := handleCertificateGeneration(token, fullName)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 
 	name := fullName + "-cert.pub"
-	if err := writeKey(name, []byte(cert)); err != nil {
-		return err
+	if err := writeKey(name, []byte(signRes.Certificate)); err != nil {
+		return time.Time{}, err
 	}
 
-	return nil
+	return signRes.expiry(), nil
 }
 
 // handleCertificateGeneration takes a JWT and uses it build a signPayload
 // to send to the Sign endpoint with the public key from the keypair it generated
-func handleCertificateGeneration(token, fullName string) (string, error) {
+func handleCertificateGeneration(token, fullName string) (*signResponse, error) {
 	pub, err := generateKeyPair(fullName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return SignCert(token, string(pub))
+	return signCertificate(token, string(pub))
 }
 
 func SignCert(token, pubKey string) (string, error) {
+	signRes, err := signCertificate(token, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return signRes.Certificate, nil
+}
+
+// signCertificate sends pubKey to the Sign endpoint of the issuer of token
+func signCertificate(token, pubKey string) (*signResponse, error) {
 	if token == "" {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	parsedToken, err := jwt.ParseSigned(token)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to parse JWT")
+		return nil, errors.Wrap(err, "failed to parse JWT")
 	}
 
 	claims := jwt.Claims{}
 	err = parsedToken.UnsafeClaimsWithoutVerification(&claims)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to retrieve JWT claims")
+		return nil, errors.Wrap(err, "failed to retrieve JWT claims")
 	}
 
 	buf, err := json.Marshal(&signPayload{
@@ -104,7 +137,7 @@ func SignCert(token, pubKey string) (string, error) {
 		Issuer:    claims.Issuer,
 	})
 	if err != nil {
-		return "", errors.Wrap(err, "failed to marshal signPayload")
+		return nil, errors.Wrap(err, "failed to marshal signPayload")
 	}
 	var res *http.Response
 	if mockRequest != nil {
@@ -117,7 +150,7 @@ func SignCert(token, pubKey string) (string, error) {
 	}
 
 	if err != nil {
-		return "", errors.Wrap(err, "failed to send request")
+		return nil, errors.Wrap(err, "failed to send request")
 	}
 	defer res.Body.Close()
 
@@ -126,16 +159,16 @@ func SignCert(token, pubKey string) (string, error) {
 	if res.StatusCode != 200 {
 		var errResponse errorResponse
 		if err := decoder.Decode(&errResponse); err != nil {
-			return "", err
+			return nil, err
 		}
-		return "", fmt.Errorf("%d: %s", errResponse.Status, errResponse.Message)
+		return nil, fmt.Errorf("%d: %s", errResponse.Status, errResponse.Message)
 	}
 
 	var signRes signResponse
 	if err := decoder.Decode(&signRes); err != nil {
-		return "", errors.Wrap(err, "failed to decode HTTP response")
+		return nil, errors.Wrap(err, "failed to decode HTTP response")
 	}
-	return signRes.Certificate, nil
+	return &signRes, nil
 }
 
 // generateKeyPair creates a EC keypair (P256) and stores them in the homedir.
@@ -94,9 +94,12 @@ func copyData(dst io.Writer, src io.Reader, dir string) (written int64, err erro
 	if debugCopy {
 		// copyBuffer is based on stdio Copy implementation but shows copied data
 		copyBuffer := func(dst io.Writer, src io.Reader, dir string) (written int64, err error) {
-			var buf []byte
-			size := 32 * 1024
-			buf = make([]byte, size)
+			pooled, err := cfio.TryGetBuffer(cfio.StreamBufferSize)
+			if err != nil {
+				return 0, err
+			}
+			defer cfio.PutBuffer(pooled)
+			buf := *pooled
 			for {
 				t := time.Now()
 				nr, er := src.Read(buf)
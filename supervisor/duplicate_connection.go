@@ -0,0 +1,76 @@
+package supervisor
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+const (
+	// The edge may refuse a registration as a duplicate because a previous connection is being torn down, so only
+	// the connections of the previous run are cleaned up after a few consecutive failures.
+	staleConnectionsCleanupThreshold = 2
+)
+
+// duplicateConnections follows the connections that the edge refuses to register because it still has one for the
+// same connector, which happens after an unclean restart until the edge notices the previous connections are gone.
+// If cleanup is set, it removes the connections of the previous run so the connections can register right away.
+type duplicateConnections struct {
+	cleanup func() error
+
+	lock        sync.Mutex
+	consecutive map[uint8]int
+	cleanedUp   bool
+}
+
+func newDuplicateConnections(cleanup func() error) *duplicateConnections {
+	return &duplicateConnections{
+		cleanup:     cleanup,
+		consecutive: make(map[uint8]int),
+	}
+}
+
+// OnTunnelEvent resets the state of connections once they are registered.
+func (d *duplicateConnections) OnTunnelEvent(event connection.Event) {
+	if event.EventType != connection.Connected {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.consecutive[event.Index]; ok {
+		delete(d.consecutive, event.Index)
+		duplicateConnectionGauge.WithLabelValues(strconv.Itoa(int(event.Index))).Set(0)
+	}
+}
+
+// onDuplicate records that registering the connection failed because it's a duplicate, and cleans up the stale
+// connections if it keeps failing. The retry itself is left to the usual backoff, whose jitter spreads the
+// connections that failed at once.
+func (d *duplicateConnections) onDuplicate(connIndex uint8, log *zerolog.Logger) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.consecutive[connIndex]++
+	duplicateConnectionGauge.WithLabelValues(strconv.Itoa(int(connIndex))).Set(1)
+	if d.cleanup == nil {
+		if d.consecutive[connIndex] == staleConnectionsCleanupThreshold {
+			log.Warn().Msg("The edge still has connections of a previous run of this connector, which it will drop after they time out. Run cloudflared with --cleanup-stale-connections to remove them right away")
+		}
+		return
+	}
+	if d.cleanedUp || d.consecutive[connIndex] < staleConnectionsCleanupThreshold {
+		return
+	}
+
+	log.Info().Msg("Cleaning up the connections of the previous run of this connector")
+	if err := d.cleanup(); err != nil {
+		staleConnectionCleanups.WithLabelValues("failed").Inc()
+		log.Err(err).Msg("Failed to clean up stale connections")
+		return
+	}
+	staleConnectionCleanups.WithLabelValues("succeeded").Inc()
+	// Only connections of the previous run are removed, so there's nothing left to clean up
+	d.cleanedUp = true
+}
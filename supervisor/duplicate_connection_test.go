@@ -0,0 +1,63 @@
+package supervisor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+func TestDuplicateConnectionsCleanup(t *testing.T) {
+	log := zerolog.Nop()
+	cleanups := 0
+	var cleanupErr error
+	duplicates := newDuplicateConnections(func() error {
+		cleanups++
+		return cleanupErr
+	})
+
+	duplicates.onDuplicate(0, &log)
+	duplicates.onDuplicate(1, &log)
+	require.Equal(t, 0, cleanups, "stale connections must not be cleaned up after a single failure")
+
+	// Failed cleanups are retried on the next duplicate
+	cleanupErr = errors.New("API error")
+	duplicates.onDuplicate(0, &log)
+	require.Equal(t, 1, cleanups)
+	cleanupErr = nil
+	duplicates.onDuplicate(1, &log)
+	require.Equal(t, 2, cleanups)
+
+	// The previous run is only cleaned up once
+	duplicates.onDuplicate(1, &log)
+	duplicates.onDuplicate(2, &log)
+	duplicates.onDuplicate(2, &log)
+	require.Equal(t, 2, cleanups)
+}
+
+func TestDuplicateConnectionsReset(t *testing.T) {
+	log := zerolog.Nop()
+	cleanups := 0
+	duplicates := newDuplicateConnections(func() error {
+		cleanups++
+		return nil
+	})
+
+	duplicates.onDuplicate(0, &log)
+	duplicates.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected})
+	require.Empty(t, duplicates.consecutive)
+	duplicates.onDuplicate(0, &log)
+	require.Equal(t, 0, cleanups, "the failures before the connection registered must not count")
+}
+
+func TestDuplicateConnectionsWithoutCleanup(t *testing.T) {
+	log := zerolog.Nop()
+	duplicates := newDuplicateConnections(nil)
+	for i := 0; i < staleConnectionsCleanupThreshold+1; i++ {
+		duplicates.onDuplicate(0, &log)
+	}
+	require.Equal(t, staleConnectionsCleanupThreshold+1, duplicates.consecutive[0])
+}
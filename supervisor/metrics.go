@@ -18,10 +18,60 @@ var (
 			Help:      "Number of active ha connections",
 		},
 	)
+	connectionRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "connection_retries",
+			Help:      "Count of connection retries, by cause of the previous attempt's failure",
+		},
+		[]string{"cause"},
+	)
+	duplicateConnectionGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "duplicate_connection",
+			Help:      "1 if the edge refuses to register the connection because it still has one of this connector, 0 otherwise",
+		},
+		[]string{"conn_index"},
+	)
+	staleConnectionCleanups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "stale_connection_cleanups",
+			Help:      "Count of attempts to clean up the connections of a previous run of this connector",
+		},
+		[]string{"status"},
+	)
+	watchdogResets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "watchdog_resets",
+			Help:      "Count of connections torn down because nothing was received from the edge for the watchdog timeout, by protocol",
+		},
+		[]string{"protocol"},
+	)
+	protocolFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: connection.MetricsNamespace,
+			Subsystem: connection.TunnelSubsystem,
+			Name:      "protocol_fallbacks",
+			Help:      "Count of connections switching protocol, by the protocol they switched from and to, either falling back or trying the preferred protocol again after the cooldown",
+		},
+		[]string{"from", "to"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(
 		haConnections,
+		connectionRetries,
+		duplicateConnectionGauge,
+		staleConnectionCleanups,
+		watchdogResets,
+		protocolFallbacks,
 	)
 }
@@ -13,7 +13,6 @@ import (
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/orchestration"
-	"github.com/cloudflare/cloudflared/retry"
 	"github.com/cloudflare/cloudflared/signal"
 	"github.com/cloudflare/cloudflared/tunnelstate"
 )
@@ -81,6 +80,9 @@ func NewSupervisor(config *TunnelConfig, orchestrator *orchestration.Orchestrato
 	tracker := tunnelstate.NewConnTracker(config.Log)
 	log := NewConnAwareLogger(config.Log, tracker, config.Observer)
 
+	duplicates := newDuplicateConnections(config.CleanupStaleConnections)
+	config.Observer.RegisterSink(duplicates)
+
 	edgeAddrHandler := NewIPAddrFallback(config.MaxEdgeAddrRetries)
 	edgeBindAddr := config.EdgeBindAddr
 
@@ -92,6 +94,7 @@ func NewSupervisor(config *TunnelConfig, orchestrator *orchestration.Orchestrato
 		edgeAddrHandler:   edgeAddrHandler,
 		edgeBindAddr:      edgeBindAddr,
 		tracker:           tracker,
+		duplicates:        duplicates,
 		reconnectCh:       reconnectCh,
 		gracefulShutdownC: gracefulShutdownC,
 		connAwareLogger:   log,
@@ -138,7 +141,10 @@ func (s *Supervisor) Run(
 	var tunnelsWaiting []int
 	tunnelsActive := s.config.HAConnections
 
-	backoff := retry.BackoffHandler{MaxRetries: s.config.Retries, BaseTime: tunnelRetryDuration, RetryForever: true}
+	backoff := s.config.newBackoff()
+	if backoff.BaseTime < tunnelRetryDuration {
+		backoff.BaseTime = tunnelRetryDuration
+	}
 	var backoffTimer <-chan time.Time
 
 	shuttingDown := false
@@ -211,11 +217,7 @@ func (s *Supervisor) initialize(
 		s.log.Logger().Info().Msgf("You requested %d HA connections but I can give you at most %d.", s.config.HAConnections, availableAddrs)
 		s.config.HAConnections = availableAddrs
 	}
-	s.tunnelsProtocolFallback[0] = &protocolFallback{
-		retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
-		s.config.ProtocolSelector.Current(),
-		false,
-	}
+	s.tunnelsProtocolFallback[0] = s.newProtocolFallback(0, s.config.ProtocolSelector.Current())
 
 	go s.startFirstTunnel(ctx, connectedSignal)
 
@@ -233,12 +235,8 @@ func (s *Supervisor) initialize(
 
 	// At least one successful connection, so start the rest
 	for i := 1; i < s.config.HAConnections; i++ {
-		s.tunnelsProtocolFallback[i] = &protocolFallback{
-			retry.BackoffHandler{MaxRetries: s.config.Retries, RetryForever: true},
-			// Set the protocol we know the first tunnel connected with.
-			s.tunnelsProtocolFallback[0].protocol,
-			false,
-		}
+		// Set the protocol we know the first tunnel connected with, unless the HA protocol mix assigns another one.
+		s.tunnelsProtocolFallback[i] = s.newProtocolFallback(i, s.tunnelsProtocolFallback[0].protocol)
 		go s.startTunnel(ctx, i, s.newConnectedTunnelSignal(i))
 		time.Sleep(registrationInterval)
 	}
@@ -297,6 +295,21 @@ func (s *Supervisor) startFirstTunnel(
 	}
 }
 
+// newProtocolFallback creates the protocol fallback of the connection with the given index, which starts with
+// protocol unless the HA protocol mix assigns it another one.
+func (s *Supervisor) newProtocolFallback(index int, protocol connection.Protocol) *protocolFallback {
+	pf := &protocolFallback{
+		BackoffHandler:  s.config.newBackoff(),
+		protocol:        protocol,
+		fallbackRetries: s.config.ProtocolFallback.Retries,
+	}
+	if assigned, ok := s.config.HAProtocolMix.Protocol(index); ok {
+		pf.protocol = assigned
+		pf.selector = assignedProtocolSelector(assigned, s.config.ProtocolSelector)
+	}
+	return pf
+}
+
 // startTunnel starts a new tunnel connection. The resulting error will be sent on
 // s.tunnelError as this is expected to run in a goroutine.
 func (s *Supervisor) startTunnel(
@@ -27,6 +27,7 @@ import (
 	quicpogs "github.com/cloudflare/cloudflared/quic"
 	"github.com/cloudflare/cloudflared/retry"
 	"github.com/cloudflare/cloudflared/signal"
+	"github.com/cloudflare/cloudflared/sockopt"
 	"github.com/cloudflare/cloudflared/tunnelrpc"
 	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 	"github.com/cloudflare/cloudflared/tunnelstate"
@@ -46,6 +47,7 @@ type TunnelConfig struct {
 	Region             string
 	EdgeIPVersion      allregions.ConfigIPVersion
 	EdgeBindAddr       net.IP
+	EdgeSocketOptions  sockopt.Options
 	HAConnections      int
 	IncidentLookup     IncidentLookup
 	IsAutoupdated      bool
@@ -57,7 +59,13 @@ type TunnelConfig struct {
 	ReportedVersion    string
 	Retries            uint
 	MaxEdgeAddrRetries uint8
-	RunFromTerminal    bool
+	// RetryBaseTime, RetryMultiplier, RetryMaxBackoff and RetryMinBackoffRatio tune the backoff between connection
+	// retries, see retry.BackoffHandler. Zero values keep its defaults.
+	RetryBaseTime        time.Duration
+	RetryMultiplier      float64
+	RetryMaxBackoff      time.Duration
+	RetryMinBackoffRatio float64
+	RunFromTerminal      bool
 
 	NeedPQ bool
 
@@ -70,6 +78,49 @@ type TunnelConfig struct {
 	PacketConfig     *ingress.GlobalRouterConfig
 
 	UDPUnregisterSessionTimeout time.Duration
+
+	// Drainer tracks the UDP sessions in flight so a graceful shutdown can wait for them.
+	Drainer *connection.Drainer
+
+	// ConfigFingerprint, if set, returns the fingerprint of the current configuration, which is reported when
+	// registering a connection.
+	ConfigFingerprint func() string
+
+	// ClientFeatures, if set, returns the features reported when registering a connection, instead of the ones of
+	// NamedTunnel.
+	ClientFeatures func() []string
+
+	// CleanupStaleConnections, if set, removes the connections of a previous run of this connector when the edge
+	// keeps refusing to register connections as duplicates.
+	CleanupStaleConnections func() error
+
+	// WatchdogTimeout, if set, is how long a connection to the edge can go without receiving anything before it's
+	// torn down and re-established.
+	WatchdogTimeout time.Duration
+
+	// QUICTransport overrides the transport parameters of QUIC connections to the edge.
+	QUICTransport connection.QUICTransportConfig
+
+	// ProtocolFallback sets the retries before a connection falls back to another protocol, and when it tries the
+	// preferred protocol again. Its order is honored through ProtocolSelector.
+	ProtocolFallback connection.ProtocolFallbackPolicy
+
+	// HAProtocolMix, if set, assigns the protocols of the HA connections, which then keep to them rather than follow
+	// ProtocolSelector. Connections beyond the mix follow ProtocolSelector.
+	HAProtocolMix connection.ProtocolMix
+}
+
+// newBackoff creates the backoff between retries of a connection, up to Retries. The retries of the protocol fallback
+// policy only decide when a connection falls back to another protocol, see protocolFallback.
+func (c *TunnelConfig) newBackoff() retry.BackoffHandler {
+	return retry.BackoffHandler{
+		MaxRetries:      c.Retries,
+		RetryForever:    true,
+		BaseTime:        c.RetryBaseTime,
+		Multiplier:      c.RetryMultiplier,
+		MaxBackoff:      c.RetryMaxBackoff,
+		MinBackoffRatio: c.RetryMinBackoffRatio,
+	}
 }
 
 func (c *TunnelConfig) registrationOptions(connectionID uint8, OriginLocalIP string, uuid uuid.UUID) *tunnelpogs.RegistrationOptions {
@@ -99,8 +150,15 @@ func (c *TunnelConfig) connectionOptions(originLocalAddr string, numPreviousAtte
 	host, _, _ := net.SplitHostPort(originLocalAddr)
 	originIP := net.ParseIP(host)
 
+	client := c.NamedTunnel.Client
+	if c.ConfigFingerprint != nil {
+		client.ConfigFingerprint = c.ConfigFingerprint()
+	}
+	if c.ClientFeatures != nil {
+		client.Features = c.ClientFeatures()
+	}
 	return &tunnelpogs.ConnectionOptions{
-		Client:              c.NamedTunnel.Client,
+		Client:              client,
 		OriginLocalIP:       originIP,
 		ReplaceExisting:     c.ReplaceExisting,
 		CompressionQuality:  0,
@@ -209,6 +267,7 @@ type EdgeTunnelServer struct {
 	reconnectCh       chan ReconnectSignal
 	gracefulShutdownC <-chan struct{}
 	tracker           *tunnelstate.ConnTracker
+	duplicates        *duplicateConnections
 
 	connAwareLogger *ConnAwareLogger
 }
@@ -247,6 +306,15 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 		Logger()
 	connLog := e.connAwareLogger.ReplaceLogger(&logger)
 
+	selector := protocolFallback.protocolSelector(e.config.ProtocolSelector)
+	if protocolFallback.cooledDown(e.config.ProtocolFallback.Cooldown) {
+		preferred := selector.Current()
+		connLog.Logger().Info().Msgf("Trying protocol %s again after falling back to %s", preferred, protocolFallback.protocol)
+		protocolFallbacks.WithLabelValues(protocolFallback.protocol.String(), preferred.String()).Inc()
+		protocolFallback.reset()
+		protocolFallback.protocol = preferred
+	}
+
 	// Each connection to keep its own copy of protocol, because individual connections might fallback
 	// to another protocol when a particular metal doesn't support new protocol
 	// Each connection can also have it's own IP version because individual connections might fallback
@@ -283,6 +351,7 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 	if !ok {
 		return err
 	}
+	connectionRetries.WithLabelValues(retryCause(err)).Inc()
 	e.config.Observer.SendReconnect(connIndex)
 	connLog.Logger().Info().Msgf("Retrying connection in up to %s", duration)
 
@@ -299,14 +368,14 @@ func (e *EdgeTunnelServer) Serve(ctx context.Context, connIndex uint8, protocolF
 
 		// If a single connection has connected with the current protocol, we know we know we don't have to fallback
 		// to a different protocol.
-		if e.tracker.HasConnectedWith(e.config.ProtocolSelector.Current()) {
+		if e.tracker.HasConnectedWith(selector.Current()) {
 			return err
 		}
 
 		if !selectNextProtocol(
 			connLog.Logger(),
 			protocolFallback,
-			e.config.ProtocolSelector,
+			selector,
 			err,
 		) {
 			return err
@@ -322,6 +391,45 @@ type protocolFallback struct {
 	retry.BackoffHandler
 	protocol   connection.Protocol
 	inFallback bool
+	// fellBackAt is when the connection last fell back to another protocol
+	fellBackAt time.Time
+	// selector, if set, replaces the protocol selector of the tunnel for this connection
+	selector connection.ProtocolSelector
+	// fallbackRetries, if set, is how many retries a protocol gets before the connection falls back to the next one.
+	// The last protocol is retried up to the max retries of the backoff.
+	fallbackRetries uint
+}
+
+// protocolSelector returns the protocol selector of the connection, defaulting to the one of the tunnel.
+func (pf *protocolFallback) protocolSelector(tunnelSelector connection.ProtocolSelector) connection.ProtocolSelector {
+	if pf.selector != nil {
+		return pf.selector
+	}
+	return tunnelSelector
+}
+
+// assignedProtocolSelector is the protocol selector of a connection that the HA protocol mix assigns protocol to. It
+// starts with protocol, and falls back from there like selector does.
+func assignedProtocolSelector(protocol connection.Protocol, selector connection.ProtocolSelector) connection.ProtocolSelector {
+	order := []connection.Protocol{protocol}
+	for next, ok := selector.FallbackFrom(protocol); ok; next, ok = selector.FallbackFrom(next) {
+		for _, p := range order {
+			if p == next {
+				return connection.NewOrderedProtocolSelector(order)
+			}
+		}
+		order = append(order, next)
+	}
+	return connection.NewOrderedProtocolSelector(order)
+}
+
+// reachedRetries tells whether the connection is out of retries with its protocol, hasFallback telling whether there
+// is another protocol to fall back to.
+func (pf *protocolFallback) reachedRetries(hasFallback bool) bool {
+	if hasFallback && pf.fallbackRetries > 0 && uint(pf.Retries()) >= pf.fallbackRetries {
+		return true
+	}
+	return pf.ReachedMaxRetries()
 }
 
 func (pf *protocolFallback) reset() {
@@ -333,6 +441,12 @@ func (pf *protocolFallback) fallback(fallback connection.Protocol) {
 	pf.ResetNow()
 	pf.protocol = fallback
 	pf.inFallback = true
+	pf.fellBackAt = time.Now()
+}
+
+// cooledDown tells whether the connection has been on a fallback protocol for at least cooldown, 0 meaning never.
+func (pf *protocolFallback) cooledDown(cooldown time.Duration) bool {
+	return pf.inFallback && cooldown > 0 && time.Since(pf.fellBackAt) >= cooldown
 }
 
 // selectNextProtocol picks connection protocol for the next retry iteration,
@@ -344,9 +458,9 @@ func selectNextProtocol(
 	cause error,
 ) bool {
 	isQuicBroken := isQuicBroken(cause)
-	_, hasFallback := selector.Fallback()
+	_, hasFallback := selector.FallbackFrom(protocolBackoff.protocol)
 
-	if protocolBackoff.ReachedMaxRetries() || (hasFallback && isQuicBroken) {
+	if protocolBackoff.reachedRetries(hasFallback) || (hasFallback && isQuicBroken) {
 		if isQuicBroken {
 			connLog.Warn().Msg("If this log occurs persistently, and cloudflared is unable to connect to " +
 				"Cloudflare Network with `quic` protocol, then most likely your machine/network is getting its egress " +
@@ -356,15 +470,13 @@ func selectNextProtocol(
 				"unless your cloudflared can connect with Cloudflare Network with `quic`.")
 		}
 
-		fallback, hasFallback := selector.Fallback()
+		// Already using the last fallback protocol, no point to retry
+		fallback, hasFallback := selector.FallbackFrom(protocolBackoff.protocol)
 		if !hasFallback {
 			return false
 		}
-		// Already using fallback protocol, no point to retry
-		if protocolBackoff.protocol == fallback {
-			return false
-		}
 		connLog.Info().Msgf("Switching to fallback protocol %s", fallback)
+		protocolFallbacks.WithLabelValues(protocolBackoff.protocol.String(), fallback.String()).Inc()
 		protocolBackoff.fallback(fallback)
 	} else if !protocolBackoff.inFallback {
 		current := selector.Current()
@@ -376,6 +488,28 @@ func selectNextProtocol(
 	return true
 }
 
+// retryCause classifies why a connection is retried, for metrics.
+func retryCause(err error) string {
+	if err == nil {
+		return "closed"
+	}
+	switch err.(type) {
+	case connection.DupConnRegisterTunnelError:
+		return "duplicate_connection"
+	case edgediscovery.DialError, *connection.EdgeQuicDialError:
+		return "dial_error"
+	case ReconnectSignal:
+		return "reconnect_signal"
+	case staleConnectionError:
+		return "watchdog"
+	}
+	var idleTimeoutError *quic.IdleTimeoutError
+	if errors.As(err, &idleTimeoutError) {
+		return "idle_timeout"
+	}
+	return "other"
+}
+
 func isQuicBroken(cause error) bool {
 	var idleTimeoutError *quic.IdleTimeoutError
 	if errors.As(cause, &idleTimeoutError) {
@@ -401,6 +535,10 @@ func (e *EdgeTunnelServer) serveTunnel(
 	backoff *protocolFallback,
 	protocol connection.Protocol,
 ) (err error, recoverable bool) {
+	// Registered first so that it sees errors recovered from panics
+	defer func() {
+		e.config.Observer.SendDisconnect(connIndex, err)
+	}()
 	// Treat panics as recoverable errors
 	defer func() {
 		if r := recover(); r != nil {
@@ -414,7 +552,6 @@ func (e *EdgeTunnelServer) serveTunnel(
 		}
 	}()
 
-	defer e.config.Observer.SendDisconnect(connIndex)
 	err, recoverable = e.serveConnection(
 		ctx,
 		connLog,
@@ -429,16 +566,25 @@ func (e *EdgeTunnelServer) serveTunnel(
 		switch err := err.(type) {
 		case connection.DupConnRegisterTunnelError:
 			connLog.ConnAwareLogger().Err(err).Msg("Unable to establish connection.")
+			e.config.Observer.SendRegistrationError(connIndex, err)
+			if e.duplicates != nil {
+				e.duplicates.onDuplicate(connIndex, connLog.Logger())
+			}
 			// don't retry this connection anymore, let supervisor pick a new address
 			return err, false
 		case connection.ServerRegisterTunnelError:
 			connLog.ConnAwareLogger().Err(err).Msg("Register tunnel error from server side")
+			e.config.Observer.SendRegistrationError(connIndex, err)
 			// Don't send registration error return from server to Sentry. They are
 			// logged on server side
 			if incidents := e.config.IncidentLookup.ActiveIncidents(); len(incidents) > 0 {
 				connLog.ConnAwareLogger().Msg(activeIncidentsMsg(incidents))
 			}
-			return err.Cause, !err.Permanent
+			// A permanent error keeps its type, so that the reason the tunnel stopped is known
+			if err.Permanent {
+				return err, false
+			}
+			return err.Cause, true
 		case *connection.EdgeQuicDialError:
 			return err, false
 		case ReconnectSignal:
@@ -497,7 +643,7 @@ func (e *EdgeTunnelServer) serveConnection(
 			connIndex)
 
 	case connection.HTTP2:
-		edgeConn, err := edgediscovery.DialEdge(ctx, dialTimeout, e.config.EdgeTLSConfigs[protocol], addr.TCP, e.edgeBindAddr)
+		edgeConn, err := edgediscovery.DialEdge(ctx, dialTimeout, e.config.EdgeTLSConfigs[protocol], addr.TCP, e.edgeBindAddr, e.config.EdgeSocketOptions)
 		if err != nil {
 			connLog.ConnAwareLogger().Err(err).Msg("Unable to establish connection with Cloudflare edge")
 			return err, true
@@ -542,6 +688,11 @@ func (e *EdgeTunnelServer) serveHTTP2(
 	}
 
 	connLog.Logger().Debug().Msgf("Connecting via http2")
+	var watchdog *connWatchdog
+	if e.config.WatchdogTimeout > 0 {
+		watchdog = newConnWatchdog(e.config.WatchdogTimeout)
+		tlsServerConn = watchdog.wrapHTTP2Conn(tlsServerConn)
+	}
 	h2conn := connection.NewHTTP2Connection(
 		tlsServerConn,
 		e.orchestrator,
@@ -556,6 +707,11 @@ func (e *EdgeTunnelServer) serveHTTP2(
 	errGroup.Go(func() error {
 		return h2conn.Serve(serveCtx)
 	})
+	if watchdog != nil {
+		errGroup.Go(func() error {
+			return e.runWatchdog(serveCtx, watchdog, connection.HTTP2, connLog)
+		})
+	}
 
 	errGroup.Go(func() error {
 		err := listenReconnect(serveCtx, e.reconnectCh, e.gracefulShutdownC)
@@ -605,6 +761,12 @@ func (e *EdgeTunnelServer) serveQUIC(
 		MaxDatagramFrameSize:  quicpogs.MaxDatagramFrameSize,
 		Tracer:                quicpogs.NewClientTracer(connLogger.Logger(), connIndex),
 	}
+	e.config.QUICTransport.Apply(quicConfig)
+	var watchdog *connWatchdog
+	if e.config.WatchdogTimeout > 0 {
+		watchdog = newConnWatchdog(e.config.WatchdogTimeout)
+		quicConfig = quicpogs.TraceConnection(quicConfig, watchdog)
+	}
 
 	quicConn, err := connection.NewQUICConnection(
 		ctx,
@@ -619,6 +781,7 @@ func (e *EdgeTunnelServer) serveQUIC(
 		connLogger.Logger(),
 		e.config.PacketConfig,
 		e.config.UDPUnregisterSessionTimeout,
+		e.config.Drainer,
 	)
 	if err != nil {
 		if e.config.NeedPQ {
@@ -637,6 +800,11 @@ func (e *EdgeTunnelServer) serveQUIC(
 		}
 		return err
 	})
+	if watchdog != nil {
+		errGroup.Go(func() error {
+			return e.runWatchdog(serveCtx, watchdog, connection.QUIC, connLogger)
+		})
+	}
 
 	errGroup.Go(func() error {
 		err := listenReconnect(serveCtx, e.reconnectCh, e.gracefulShutdownC)
@@ -651,6 +819,16 @@ func (e *EdgeTunnelServer) serveQUIC(
 	return errGroup.Wait(), false
 }
 
+// runWatchdog runs the watchdog of a connection, counting the connections it tears down.
+func (e *EdgeTunnelServer) runWatchdog(ctx context.Context, watchdog *connWatchdog, protocol connection.Protocol, connLog *ConnAwareLogger) error {
+	err := watchdog.run(ctx)
+	if err != nil {
+		watchdogResets.WithLabelValues(protocol.String()).Inc()
+		connLog.ConnAwareLogger().Err(err).Msg("Tearing down connection to re-establish it")
+	}
+	return err
+}
+
 func listenReconnect(ctx context.Context, reconnectCh <-chan ReconnectSignal, gracefulShutdownCh <-chan struct{}) error {
 	select {
 	case reconnect := <-reconnectCh:
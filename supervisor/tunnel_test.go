@@ -11,6 +11,7 @@ import (
 	"github.com/cloudflare/cloudflared/connection"
 	"github.com/cloudflare/cloudflared/edgediscovery"
 	"github.com/cloudflare/cloudflared/retry"
+	tunnelpogs "github.com/cloudflare/cloudflared/tunnelrpc/pogs"
 )
 
 type dynamicMockFetcher struct {
@@ -50,9 +51,8 @@ func TestWaitForBackoffFallback(t *testing.T) {
 	assert.Equal(t, connection.QUIC, initProtocol)
 
 	protoFallback := &protocolFallback{
-		backoff,
-		initProtocol,
-		false,
+		BackoffHandler: backoff,
+		protocol:       initProtocol,
 	}
 
 	// Retry #0 and #1. At retry #2, we switch protocol, so the fallback loop has one more retry than this
@@ -106,7 +106,7 @@ func TestWaitForBackoffFallback(t *testing.T) {
 		&log,
 	)
 	assert.NoError(t, err)
-	protoFallback = &protocolFallback{backoff, protocolSelector.Current(), false}
+	protoFallback = &protocolFallback{BackoffHandler: backoff, protocol: protocolSelector.Current()}
 	for i := 0; i < int(maxRetries-1); i++ {
 		protoFallback.BackoffTimer() // simulate retry
 		ok := selectNextProtocol(&log, protoFallback, protocolSelector, &quic.IdleTimeoutError{})
@@ -118,3 +118,128 @@ func TestWaitForBackoffFallback(t *testing.T) {
 	ok = selectNextProtocol(&log, protoFallback, protocolSelector, &quic.IdleTimeoutError{})
 	assert.False(t, ok)
 }
+
+func TestSelectNextProtocolFallbackOrder(t *testing.T) {
+	log := zerolog.Nop()
+	selector := connection.NewOrderedProtocolSelector([]connection.Protocol{connection.HTTP2, connection.QUIC})
+	protoFallback := &protocolFallback{
+		BackoffHandler: retry.BackoffHandler{MaxRetries: 1, BaseTime: time.Millisecond},
+		protocol:       selector.Current(),
+	}
+
+	protoFallback.BackoffTimer()
+	assert.True(t, selectNextProtocol(&log, protoFallback, selector, nil))
+	assert.Equal(t, connection.QUIC, protoFallback.protocol)
+	assert.True(t, protoFallback.inFallback)
+
+	// quic is the last resort
+	protoFallback.BackoffTimer()
+	assert.False(t, selectNextProtocol(&log, protoFallback, selector, nil))
+}
+
+func TestProtocolFallbackCooldown(t *testing.T) {
+	protoFallback := &protocolFallback{protocol: connection.QUIC}
+	assert.False(t, protoFallback.cooledDown(time.Minute))
+
+	protoFallback.fallback(connection.HTTP2)
+	assert.False(t, protoFallback.cooledDown(0))
+	assert.False(t, protoFallback.cooledDown(time.Minute))
+
+	protoFallback.fellBackAt = time.Now().Add(-2 * time.Minute)
+	assert.True(t, protoFallback.cooledDown(time.Minute))
+
+	protoFallback.reset()
+	assert.False(t, protoFallback.cooledDown(time.Minute))
+}
+
+func TestAssignedProtocolSelector(t *testing.T) {
+	tunnelSelector := connection.NewOrderedProtocolSelector([]connection.Protocol{connection.QUIC, connection.HTTP2})
+
+	// A connection assigned http2 has nothing to fall back to after it
+	selector := assignedProtocolSelector(connection.HTTP2, tunnelSelector)
+	assert.Equal(t, connection.HTTP2, selector.Current())
+	_, ok := selector.Fallback()
+	assert.False(t, ok)
+
+	selector = assignedProtocolSelector(connection.QUIC, tunnelSelector)
+	assert.Equal(t, connection.QUIC, selector.Current())
+	fallback, ok := selector.Fallback()
+	assert.True(t, ok)
+	assert.Equal(t, connection.HTTP2, fallback)
+}
+
+func TestNewProtocolFallbackHAProtocolMix(t *testing.T) {
+	s := &Supervisor{config: &TunnelConfig{
+		ProtocolSelector: connection.NewOrderedProtocolSelector([]connection.Protocol{connection.QUIC, connection.HTTP2}),
+		HAProtocolMix:    connection.ProtocolMix{connection.QUIC, connection.HTTP2},
+	}}
+
+	pf := s.newProtocolFallback(1, connection.QUIC)
+	assert.Equal(t, connection.HTTP2, pf.protocol)
+	assert.Equal(t, connection.HTTP2, pf.protocolSelector(s.config.ProtocolSelector).Current())
+
+	// Connections beyond the mix follow the selector of the tunnel
+	pf = s.newProtocolFallback(2, connection.QUIC)
+	assert.Equal(t, connection.QUIC, pf.protocol)
+	assert.Equal(t, s.config.ProtocolSelector, pf.protocolSelector(s.config.ProtocolSelector))
+}
+
+func TestRetryCause(t *testing.T) {
+	assert.Equal(t, "closed", retryCause(nil))
+	assert.Equal(t, "idle_timeout", retryCause(&quic.IdleTimeoutError{}))
+	assert.Equal(t, "duplicate_connection", retryCause(connection.DupConnRegisterTunnelError{}))
+	assert.Equal(t, "dial_error", retryCause(&connection.EdgeQuicDialError{Cause: &quic.IdleTimeoutError{}}))
+	assert.Equal(t, "dial_error", retryCause(edgediscovery.DialError{}))
+	assert.Equal(t, "other", retryCause(assert.AnError))
+}
+
+func TestTunnelConfigBackoff(t *testing.T) {
+	config := TunnelConfig{Retries: 3, RetryBaseTime: time.Second, RetryMultiplier: 3, RetryMaxBackoff: time.Minute}
+	backoff := config.newBackoff()
+	assert.True(t, backoff.RetryForever)
+	assert.Equal(t, uint(3), backoff.MaxRetries)
+	assert.Equal(t, 3.0, backoff.Multiplier)
+	assert.Equal(t, time.Minute, backoff.MaxBackoff)
+
+	// The retries of the protocol fallback policy don't change the max retries
+	config.ProtocolFallback.Retries = 1
+	assert.Equal(t, uint(3), config.newBackoff().MaxRetries)
+}
+
+func TestSelectNextProtocolFallbackRetries(t *testing.T) {
+	log := zerolog.Nop()
+	selector := connection.NewOrderedProtocolSelector([]connection.Protocol{connection.QUIC, connection.HTTP2})
+	protoFallback := &protocolFallback{
+		BackoffHandler:  retry.BackoffHandler{MaxRetries: 3, BaseTime: time.Millisecond},
+		protocol:        selector.Current(),
+		fallbackRetries: 1,
+	}
+
+	protoFallback.BackoffTimer()
+	assert.True(t, selectNextProtocol(&log, protoFallback, selector, nil))
+	assert.Equal(t, connection.HTTP2, protoFallback.protocol)
+
+	// http2 is the last resort, it is retried up to the max retries rather than the fallback retries
+	for i := 0; i < 2; i++ {
+		protoFallback.BackoffTimer()
+		assert.True(t, selectNextProtocol(&log, protoFallback, selector, nil))
+		assert.Equal(t, connection.HTTP2, protoFallback.protocol)
+	}
+	protoFallback.BackoffTimer()
+	assert.False(t, selectNextProtocol(&log, protoFallback, selector, nil))
+}
+
+func TestConnectionOptionsClientFeatures(t *testing.T) {
+	config := TunnelConfig{
+		NamedTunnel: &connection.NamedTunnelProperties{
+			Client: tunnelpogs.ClientInfo{Features: []string{"default"}},
+		},
+	}
+	assert.Equal(t, []string{"default"}, config.connectionOptions("127.0.0.1:1234", 0).Client.Features)
+
+	features := []string{"default"}
+	config.ClientFeatures = func() []string { return features }
+	features = []string{"default", "flagged"}
+	assert.Equal(t, []string{"default", "flagged"}, config.connectionOptions("127.0.0.1:1234", 0).Client.Features)
+	assert.Equal(t, []string{"default"}, config.NamedTunnel.Client.Features)
+}
@@ -0,0 +1,175 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go/logging"
+)
+
+const (
+	// http2FrameHeaderLen is the length of the header of HTTP/2 frames, which starts with the 24-bit payload length.
+	http2FrameHeaderLen = 9
+	// http2FramePing is the type of PING frames.
+	http2FramePing = 0x6
+	// http2PingPayloadLen is the length of the opaque data of PING frames.
+	http2PingPayloadLen = 8
+)
+
+// staleConnectionError is returned when the connection watchdog tears down a connection.
+type staleConnectionError struct {
+	silence time.Duration
+}
+
+func (e staleConnectionError) Error() string {
+	return fmt.Sprintf("nothing was received from the edge for %s, the connection is likely dead", e.silence)
+}
+
+// connWatchdog follows when something was last received on a connection to the edge, so that a connection that looks
+// alive but no longer reaches the edge, e.g. because a NAT dropped its mapping, is torn down instead of lingering
+// until requests fail.
+// Over QUIC, the edge acknowledges the keepalive pings that quic-go sends, so received packets are heartbeats. Over
+// HTTP/2, the connection sends a PING frame once it's been quiet for half the timeout, whose ACK is the heartbeat, so
+// that an idle connection isn't torn down.
+type connWatchdog struct {
+	logging.NullConnectionTracer
+	timeout time.Duration
+
+	// lastReceived is the time, in Unix nanoseconds, at which something was last received from the edge.
+	lastReceived atomic.Int64
+	// ping, if set, asks the edge for something to receive.
+	ping func()
+}
+
+func newConnWatchdog(timeout time.Duration) *connWatchdog {
+	w := &connWatchdog{timeout: timeout}
+	w.received()
+	return w
+}
+
+func (w *connWatchdog) received() {
+	w.lastReceived.Store(time.Now().UnixNano())
+}
+
+// run returns a staleConnectionError once nothing was received for the timeout, or nil when ctx is done.
+func (w *connWatchdog) run(ctx context.Context) error {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			silence := time.Since(time.Unix(0, w.lastReceived.Load()))
+			if silence >= w.timeout {
+				return staleConnectionError{silence: silence.Truncate(time.Second)}
+			}
+			if silence >= w.timeout/2 && w.ping != nil {
+				w.ping()
+			}
+		}
+	}
+}
+
+// wrapHTTP2Conn returns a connection that reports the data it reads to the watchdog, and that the watchdog pings the
+// edge on. conn must be the plaintext of an HTTP/2 connection whose local end is the server.
+func (w *connWatchdog) wrapHTTP2Conn(conn net.Conn) net.Conn {
+	watched := &watchedConn{Conn: conn, watchdog: w}
+	w.ping = watched.ping
+	return watched
+}
+
+func (w *connWatchdog) ReceivedLongHeaderPacket(*logging.ExtendedHeader, logging.ByteCount, []logging.Frame) {
+	w.received()
+}
+
+func (w *connWatchdog) ReceivedShortHeaderPacket(*logging.ShortHeader, logging.ByteCount, []logging.Frame) {
+	w.received()
+}
+
+type watchedConn struct {
+	net.Conn
+	watchdog *connWatchdog
+
+	// writeLock serializes the writes of the HTTP/2 server and the PING frames of the watchdog, which are only written
+	// between two frames of the server.
+	writeLock sync.Mutex
+	// header is the part of the header of the frame being written that was written so far.
+	header    [http2FrameHeaderLen]byte
+	headerLen int
+	// payloadLeft is how much of the payload of the frame being written is left to write.
+	payloadLeft int
+	// wroteFrame tells whether the server wrote a frame, since its first frame must be its SETTINGS.
+	wroteFrame bool
+	pinging    atomic.Bool
+}
+
+func (c *watchedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.watchdog.received()
+	}
+	return n, err
+}
+
+func (c *watchedConn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	n, err := c.Conn.Write(b)
+	c.trackFrames(b[:n])
+	return n, err
+}
+
+// trackFrames follows where the frames written by the server start and end.
+func (c *watchedConn) trackFrames(b []byte) {
+	for len(b) > 0 {
+		if c.payloadLeft > 0 {
+			n := len(b)
+			if n > c.payloadLeft {
+				n = c.payloadLeft
+			}
+			c.payloadLeft -= n
+			b = b[n:]
+			continue
+		}
+		n := copy(c.header[c.headerLen:], b)
+		c.headerLen += n
+		b = b[n:]
+		if c.headerLen == http2FrameHeaderLen {
+			c.payloadLeft = int(c.header[0])<<16 | int(c.header[1])<<8 | int(c.header[2])
+			c.headerLen = 0
+			c.wroteFrame = true
+		}
+	}
+}
+
+// ping writes a PING frame, which the edge acknowledges, once the frame the server is writing is complete. It doesn't
+// wait for the write, so that the watchdog can still tear down a connection whose writes are stuck.
+func (c *watchedConn) ping() {
+	if !c.pinging.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.pinging.Store(false)
+		_ = c.writePing()
+	}()
+}
+
+// writePing writes a PING frame unless the server is in the middle of writing a frame, or hasn't written its SETTINGS
+// yet, in which case the watchdog pings again on its next check.
+func (c *watchedConn) writePing() error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if !c.wroteFrame || c.headerLen > 0 || c.payloadLeft > 0 {
+		return nil
+	}
+	frame := make([]byte, http2FrameHeaderLen+http2PingPayloadLen)
+	frame[2] = http2PingPayloadLen
+	frame[3] = http2FramePing
+	_, err := c.Conn.Write(frame)
+	return err
+}
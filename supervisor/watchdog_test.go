@@ -0,0 +1,131 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestConnWatchdogTearsDownSilentConnection(t *testing.T) {
+	watchdog := newConnWatchdog(200 * time.Millisecond)
+	start := time.Now()
+	err := watchdog.run(context.Background())
+	require.ErrorAs(t, err, &staleConnectionError{})
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	require.Equal(t, "watchdog", retryCause(err))
+}
+
+func TestConnWatchdogKeepsActiveConnection(t *testing.T) {
+	watchdog := newConnWatchdog(200 * time.Millisecond)
+	edge, local := net.Pipe()
+	defer edge.Close()
+	conn := watchdog.wrapHTTP2Conn(local)
+	defer conn.Close()
+
+	// The edge keeps sending data, which the connection reads
+	go func() {
+		for {
+			if _, err := edge.Write([]byte("ping")); err != nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	go func() {
+		buf := make([]byte, 4)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, watchdog.run(ctx))
+}
+
+func TestConnWatchdogPingsIdleHTTP2Connection(t *testing.T) {
+	watchdog := newConnWatchdog(200 * time.Millisecond)
+	edge, local := net.Pipe()
+	defer edge.Close()
+	conn := watchdog.wrapHTTP2Conn(local)
+	defer conn.Close()
+
+	// The edge only acknowledges the pings it receives
+	pings := make(chan struct{}, 100)
+	go func() {
+		framer := http2.NewFramer(edge, edge)
+		for {
+			frame, err := framer.ReadFrame()
+			if err != nil {
+				return
+			}
+			if ping, ok := frame.(*http2.PingFrame); ok && !ping.IsAck() {
+				pings <- struct{}{}
+				if err := framer.WritePing(true, ping.Data); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	require.NoError(t, http2.NewFramer(conn, nil).WriteSettings())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, watchdog.run(ctx))
+	assert.NotEmpty(t, pings)
+}
+
+type recordingConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	return c.written.Write(b)
+}
+
+func TestWatchedConnPingsBetweenFrames(t *testing.T) {
+	recorder := &recordingConn{}
+	conn := newConnWatchdog(time.Minute).wrapHTTP2Conn(recorder).(*watchedConn)
+
+	// Nothing is sent before the SETTINGS of the server
+	require.NoError(t, conn.writePing())
+	assert.Zero(t, recorder.written.Len())
+
+	var frames bytes.Buffer
+	require.NoError(t, http2.NewFramer(&frames, nil).WriteSettings(http2.Setting{ID: http2.SettingMaxFrameSize, Val: 1 << 20}))
+	require.NoError(t, http2.NewFramer(&frames, nil).WriteData(1, false, []byte("data")))
+	settings := frames.Len() - http2FrameHeaderLen - len("data")
+
+	// The server is in the middle of its DATA frame
+	_, err := conn.Write(frames.Bytes()[:settings+3])
+	require.NoError(t, err)
+	require.NoError(t, conn.writePing())
+	_, err = conn.Write(frames.Bytes()[settings+3:])
+	require.NoError(t, err)
+	assert.Equal(t, frames.Len(), recorder.written.Len())
+
+	require.NoError(t, conn.writePing())
+	framer := http2.NewFramer(nil, &recorder.written)
+	for _, want := range []http2.FrameType{http2.FrameSettings, http2.FrameData, http2.FramePing} {
+		frame, err := framer.ReadFrame()
+		require.NoError(t, err)
+		assert.Equal(t, want, frame.Header().Type)
+	}
+}
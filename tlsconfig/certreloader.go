@@ -133,6 +133,12 @@ func CreateTunnelConfig(c *cli.Context, serverName string) (*tls.Config, error)
 		rootCAs = append(rootCAs, c.String(CaCertFlag))
 	}
 
+	return NewTunnelConfig(serverName, rootCAs)
+}
+
+// NewTunnelConfig creates the TLS configuration to connect to the edge at serverName. The Cloudflare root CAs are
+// trusted along with the system ones, unless rootCAs are given.
+func NewTunnelConfig(serverName string, rootCAs []string) (*tls.Config, error) {
 	userConfig := &TLSParameters{RootCAs: rootCAs, ServerName: serverName}
 	tlsConfig, err := GetConfig(userConfig)
 	if err != nil {
@@ -0,0 +1,90 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// DefaultClockSkewTolerance is how much the local clock may be off for certificates to still be considered valid. Clock
+// skew is only tolerated when it's asked for, since it also accepts certificates that recently expired.
+const DefaultClockSkewTolerance time.Duration = 0
+
+// ClockSkewHandler is called when the server certificate isn't valid at the local time. skew is how much the local
+// clock is at least behind (positive) or ahead (negative), and tolerated tells if the certificate was accepted anyway.
+type ClockSkewHandler func(skew time.Duration, tolerated bool)
+
+// TolerateClockSkew makes config verify the server certificate itself, so that a certificate that is only invalid
+// because the local clock is off by up to tolerance is accepted. Devices whose real-time clock lost track of time
+// would otherwise fail with cryptic TLS errors, onSkew is called so they can be reported instead.
+// The server name and root CAs must be set before calling it. A tolerance of 0 leaves the verification to Go.
+func TolerateClockSkew(config *tls.Config, tolerance time.Duration, onSkew ClockSkewHandler) {
+	if config.InsecureSkipVerify || tolerance <= 0 {
+		return
+	}
+	roots, serverName := config.RootCAs, config.ServerName
+	// Go only verifies certificates at the local time, so verification is done by VerifyPeerCertificate instead
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verifyWithClockSkew(rawCerts, roots, serverName, time.Now(), tolerance, onSkew)
+	}
+}
+
+func verifyWithClockSkew(
+	rawCerts [][]byte,
+	roots *x509.CertPool,
+	serverName string,
+	now time.Time,
+	tolerance time.Duration,
+	onSkew ClockSkewHandler,
+) error {
+	if len(rawCerts) == 0 {
+		return errors.New("the server didn't present a certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+	var invalidErr x509.CertificateInvalidError
+	if !errors.As(err, &invalidErr) || invalidErr.Reason != x509.Expired {
+		return err
+	}
+	skew := clockSkew(invalidErr.Cert, now)
+	tolerated := skew <= tolerance && -skew <= tolerance
+	if onSkew != nil {
+		onSkew(skew, tolerated)
+	}
+	if !tolerated {
+		return err
+	}
+	opts.CurrentTime = now.Add(skew)
+	_, err = certs[0].Verify(opts)
+	return err
+}
+
+// clockSkew returns how much now must be shifted for cert to be valid.
+func clockSkew(cert *x509.Certificate, now time.Time) time.Duration {
+	if now.Before(cert.NotBefore) {
+		return cert.NotBefore.Sub(now)
+	}
+	if now.After(cert.NotAfter) {
+		return cert.NotAfter.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWithClockSkew(t *testing.T) {
+	notBefore := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(24 * time.Hour)
+	roots, leaf := newTestChain(t, notBefore, notAfter)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		expectErr    bool
+		expectSkew   time.Duration
+		expectCalled bool
+	}{
+		{name: "valid", now: notBefore.Add(time.Hour)},
+		{name: "clock behind", now: notBefore.Add(-time.Minute), expectSkew: time.Minute, expectCalled: true},
+		{name: "clock ahead", now: notAfter.Add(time.Minute), expectSkew: -time.Minute, expectCalled: true},
+		{name: "clock too far behind", now: notBefore.Add(-2 * time.Hour), expectErr: true, expectSkew: 2 * time.Hour, expectCalled: true},
+		{name: "clock too far ahead", now: notAfter.Add(2 * time.Hour), expectErr: true, expectSkew: -2 * time.Hour, expectCalled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			called := false
+			var skew time.Duration
+			var tolerated bool
+			err := verifyWithClockSkew([][]byte{leaf}, roots, "localhost", test.now, time.Hour, func(s time.Duration, ok bool) {
+				called, skew, tolerated = true, s, ok
+			})
+			assert.Equal(t, test.expectCalled, called)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if called {
+				assert.Equal(t, test.expectSkew, skew)
+				assert.Equal(t, !test.expectErr, tolerated)
+			}
+		})
+	}
+}
+
+func TestVerifyWithClockSkewWrongName(t *testing.T) {
+	notBefore := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	roots, leaf := newTestChain(t, notBefore, notBefore.Add(24*time.Hour))
+
+	err := verifyWithClockSkew([][]byte{leaf}, roots, "example.com", notBefore.Add(-time.Minute), time.Hour, nil)
+	assert.Error(t, err)
+}
+
+func TestTolerateClockSkewOptIn(t *testing.T) {
+	config := &tls.Config{ServerName: "localhost"}
+	TolerateClockSkew(config, DefaultClockSkewTolerance, nil)
+	assert.False(t, config.InsecureSkipVerify)
+	assert.Nil(t, config.VerifyPeerCertificate)
+
+	TolerateClockSkew(config, time.Hour, nil)
+	assert.True(t, config.InsecureSkipVerify)
+	assert.NotNil(t, config.VerifyPeerCertificate)
+}
+
+// newTestChain returns a pool with a self-signed CA and a leaf for localhost signed by it, valid between notBefore and
+// notAfter.
+func newTestChain(t *testing.T, notBefore, notAfter time.Time) (*x509.CertPool, []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             notBefore.Add(-time.Hour * 24 * 365),
+		NotAfter:              notAfter.Add(time.Hour * 24 * 365),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, leafDER
+}
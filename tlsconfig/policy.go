@@ -0,0 +1,120 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	curves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P256":   tls.CurveP256,
+		"P384":   tls.CurveP384,
+		"P521":   tls.CurveP521,
+	}
+)
+
+// Policy restricts the TLS versions, cipher suites and curves that a connection may negotiate, to comply with
+// organizational crypto policies. The zero Policy leaves the defaults alone.
+type Policy struct {
+	MinVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+// ParsePolicy parses a policy from a minimum version like "1.2", the IANA names of cipher suites like
+// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", and curve names among X25519, P256, P384 and P521. Empty values keep the
+// defaults.
+func ParsePolicy(minVersion string, cipherSuites, curvePreferences []string) (Policy, error) {
+	var policy Policy
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return Policy{}, fmt.Errorf("unknown TLS version %q, it must be one of 1.0, 1.1, 1.2 or 1.3", minVersion)
+		}
+		policy.MinVersion = version
+	}
+	for _, name := range cipherSuites {
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return Policy{}, err
+		}
+		policy.CipherSuites = append(policy.CipherSuites, id)
+	}
+	for _, name := range curvePreferences {
+		curve, ok := curves[name]
+		if !ok {
+			return Policy{}, fmt.Errorf("unknown curve %q, it must be one of X25519, P256, P384 or P521", name)
+		}
+		policy.CurvePreferences = append(policy.CurvePreferences, curve)
+	}
+	return policy, nil
+}
+
+func cipherSuiteID(name string) (uint16, error) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name != name {
+			continue
+		}
+		for _, version := range suite.SupportedVersions {
+			// Go doesn't let TLS 1.3 cipher suites be configured, they are all always enabled
+			if version == tls.VersionTLS13 {
+				return 0, fmt.Errorf("cipher suite %s is a TLS 1.3 one, which can't be restricted", name)
+			}
+		}
+		return suite.ID, nil
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// IsZero tells if the policy keeps the defaults.
+func (p Policy) IsZero() bool {
+	return p.MinVersion == 0 && len(p.CipherSuites) == 0 && len(p.CurvePreferences) == 0
+}
+
+// Apply restricts config to the policy.
+func (p Policy) Apply(config *tls.Config) {
+	if p.MinVersion != 0 {
+		config.MinVersion = p.MinVersion
+	}
+	if len(p.CipherSuites) > 0 {
+		config.CipherSuites = p.CipherSuites
+	}
+	if len(p.CurvePreferences) > 0 {
+		config.CurvePreferences = p.CurvePreferences
+	}
+}
+
+func (p Policy) String() string {
+	var parts []string
+	for name, version := range tlsVersions {
+		if version == p.MinVersion {
+			parts = append(parts, "min version "+name)
+		}
+	}
+	if len(p.CipherSuites) > 0 {
+		names := make([]string, len(p.CipherSuites))
+		for i, id := range p.CipherSuites {
+			names[i] = tls.CipherSuiteName(id)
+		}
+		parts = append(parts, "cipher suites "+strings.Join(names, ","))
+	}
+	if len(p.CurvePreferences) > 0 {
+		names := make([]string, len(p.CurvePreferences))
+		for i, curve := range p.CurvePreferences {
+			names[i] = curve.String()
+		}
+		parts = append(parts, "curves "+strings.Join(names, ","))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,40 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, policy.IsZero())
+	assert.Equal(t, "default", policy.String())
+
+	policy, err = ParsePolicy("1.2", []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}, []string{"P384", "X25519"})
+	require.NoError(t, err)
+	assert.Equal(t, Policy{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		CurvePreferences: []tls.CurveID{tls.CurveP384, tls.X25519},
+	}, policy)
+	assert.Equal(t, "min version 1.2, cipher suites TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, curves CurveP384,X25519", policy.String())
+
+	config := &tls.Config{CurvePreferences: []tls.CurveID{tls.CurveP256}}
+	policy.Apply(config)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, policy.CipherSuites, config.CipherSuites)
+	assert.Equal(t, policy.CurvePreferences, config.CurvePreferences)
+
+	_, err = ParsePolicy("1.4", nil, nil)
+	assert.Error(t, err)
+	_, err = ParsePolicy("", []string{"TLS_AES_128_GCM_SHA256"}, nil)
+	assert.Error(t, err, "TLS 1.3 cipher suites can't be configured")
+	_, err = ParsePolicy("", []string{"TLS_UNKNOWN"}, nil)
+	assert.Error(t, err)
+	_, err = ParsePolicy("", nil, []string{"P192"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	crlFetchTimeout = 10 * time.Second
+	// maxCRLSize bounds the memory a CRL may take
+	maxCRLSize = 32 * 1024 * 1024
+	// defaultCRLCacheTime is how long a CRL without a next update is cached
+	defaultCRLCacheTime = time.Hour
+	// crlRefreshAhead is how long before its next update a CRL is fetched again in the background, so that handshakes
+	// don't wait for it
+	crlRefreshAhead = 10 * time.Minute
+)
+
+// RevocationChecker rejects certificates revoked by their issuer, according to the CRLs at the distribution points
+// listed in the certificates. CRLs are cached per issuer until their next update, and refreshed in the background
+// shortly before. It fails closed: a certificate is rejected if its CRL can't be fetched. Certificates without
+// distribution points are accepted. OCSP isn't supported, certificates are only checked against CRLs.
+type RevocationChecker struct {
+	client *http.Client
+	now    func() time.Time
+
+	// lock only guards the map, each entry has its own lock so that fetching a CRL doesn't hold up the others
+	lock sync.Mutex
+	crls map[crlKey]*crlEntry
+}
+
+// crlKey identifies a CRL. A CRL is only trusted for the issuer that signed it, so the same distribution point has an
+// entry per issuer.
+type crlKey struct {
+	issuer string
+	url    string
+}
+
+type crlEntry struct {
+	lock      sync.Mutex
+	crl       *x509.RevocationList
+	expiry    time.Time
+	refreshAt time.Time
+	// err is the error of the last fetch
+	err error
+	// fetching is closed when the fetch in flight is done, nil if there is none
+	fetching chan struct{}
+}
+
+func NewRevocationChecker() *RevocationChecker {
+	return &RevocationChecker{
+		client: &http.Client{Timeout: crlFetchTimeout},
+		now:    time.Now,
+		crls:   make(map[crlKey]*crlEntry),
+	}
+}
+
+// VerifyConnection checks the verified chains of a connection. It's meant for tls.Config.VerifyConnection.
+func (rc *RevocationChecker) VerifyConnection(state tls.ConnectionState) error {
+	for _, chain := range state.VerifiedChains {
+		// The last certificate of the chain is the trusted root
+		for i := 0; i < len(chain)-1; i++ {
+			if err := rc.check(chain[i], chain[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rc *RevocationChecker) check(cert, issuer *x509.Certificate) error {
+	for _, url := range cert.CRLDistributionPoints {
+		crl, err := rc.crl(url, issuer)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if the certificate of %s is revoked", cert.Subject)
+		}
+		for _, revoked := range crl.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return fmt.Errorf("the certificate of %s was revoked on %s", cert.Subject, revoked.RevocationTime)
+			}
+		}
+	}
+	return nil
+}
+
+// crl returns the CRL of issuer at url. A cached CRL is returned right away, and only the first check of a CRL, or
+// one after it expired, waits for it to be fetched.
+func (rc *RevocationChecker) crl(url string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	entry := rc.entry(crlKey{issuer: string(issuer.RawSubjectPublicKeyInfo), url: url})
+
+	entry.lock.Lock()
+	if now := rc.now(); entry.crl != nil && now.Before(entry.expiry) {
+		crl := entry.crl
+		if !now.Before(entry.refreshAt) {
+			rc.fetch(entry, url, issuer)
+		}
+		entry.lock.Unlock()
+		return crl, nil
+	}
+	fetching := rc.fetch(entry, url, issuer)
+	entry.lock.Unlock()
+
+	<-fetching
+	entry.lock.Lock()
+	defer entry.lock.Unlock()
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry.crl, nil
+}
+
+func (rc *RevocationChecker) entry(key crlKey) *crlEntry {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	entry, ok := rc.crls[key]
+	if !ok {
+		entry = &crlEntry{}
+		rc.crls[key] = entry
+	}
+	return entry
+}
+
+// fetch starts fetching the CRL of entry in the background, unless it's already being fetched, and returns a channel
+// closed once it's done. The caller must hold the lock of entry.
+func (rc *RevocationChecker) fetch(entry *crlEntry, url string, issuer *x509.Certificate) <-chan struct{} {
+	if entry.fetching != nil {
+		return entry.fetching
+	}
+	fetching := make(chan struct{})
+	entry.fetching = fetching
+	go func() {
+		crl, err := rc.fetchCRL(url)
+		if err == nil {
+			if err = crl.CheckSignatureFrom(issuer); err != nil {
+				err = errors.Wrapf(err, "the CRL at %s isn't signed by the issuer", url)
+			}
+		}
+
+		entry.lock.Lock()
+		defer entry.lock.Unlock()
+		entry.err = err
+		if err == nil {
+			entry.crl = crl
+			entry.expiry = crl.NextUpdate
+			if entry.expiry.IsZero() {
+				entry.expiry = rc.now().Add(defaultCRLCacheTime)
+			}
+			entry.refreshAt = entry.expiry.Add(-crlRefreshAhead)
+		}
+		entry.fetching = nil
+		close(fetching)
+	}()
+	return fetching
+}
+
+func (rc *RevocationChecker) fetchCRL(url string) (*x509.RevocationList, error) {
+	resp, err := rc.client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the CRL")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the CRL: %s responded with %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the CRL")
+	}
+	if block, _ := pem.Decode(body); block != nil {
+		body = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the CRL at %s", url)
+	}
+	return crl, nil
+}
@@ -0,0 +1,106 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationChecker(t *testing.T) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          now.Add(-time.Minute),
+		NextUpdate:          now.Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{{SerialNumber: big.NewInt(3), RevocationTime: now.Add(-time.Minute)}},
+	}, ca, caKey)
+	require.NoError(t, err)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		_, _ = w.Write(crlDER)
+	}))
+	defer server.Close()
+
+	newLeaf := func(serial int64, crlURL string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "origin"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+		}
+		if crlURL != "" {
+			template.CRLDistributionPoints = []string{crlURL}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return leaf
+	}
+	state := func(leaf *x509.Certificate) tls.ConnectionState {
+		return tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf, ca}}}
+	}
+
+	checker := NewRevocationChecker()
+	assert.NoError(t, checker.VerifyConnection(state(newLeaf(2, server.URL))))
+	assert.Error(t, checker.VerifyConnection(state(newLeaf(3, server.URL))))
+	// The CRL is cached until its next update
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// Certificates without distribution points are accepted
+	assert.NoError(t, checker.VerifyConnection(state(newLeaf(3, ""))))
+	// The check fails closed
+	assert.Error(t, checker.VerifyConnection(state(newLeaf(2, server.URL+"/missing"))))
+
+	// The CRL is only trusted for the issuer that signed it
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &otherKey.PublicKey, otherKey)
+	require.NoError(t, err)
+	otherCA, err := x509.ParseCertificate(otherDER)
+	require.NoError(t, err)
+	assert.Error(t, checker.VerifyConnection(tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{newLeaf(2, server.URL), otherCA}}}))
+
+	// Shortly before its next update, the CRL is fetched again in the background while the cached one is used
+	fetched := atomic.LoadInt32(&fetches)
+	checker.now = func() time.Time { return now.Add(time.Hour - crlRefreshAhead/2) }
+	assert.Error(t, checker.VerifyConnection(state(newLeaf(3, server.URL))))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&fetches) == fetched+1
+	}, time.Second, 10*time.Millisecond)
+}
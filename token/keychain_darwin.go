@@ -0,0 +1,63 @@
+//go:build darwin
+// +build darwin
+
+package token
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityItemNotFound is the exit code of the security tool when the keychain has no such item
+const securityItemNotFound = 44
+
+// keychainStore keeps tokens in the macOS Keychain with the security tool.
+type keychainStore struct{}
+
+func newKeychainStore() (Store, error) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, fmt.Errorf("the macOS Keychain can't be reached: %w", err)
+	}
+	return keychainStore{}, nil
+}
+
+func (keychainStore) Get(path string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount(path), "-w").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return "", ErrTokenNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the token from the keychain: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (keychainStore) Set(path, token string) error {
+	// The command is written to the standard input of security, so that the token doesn't show in the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		keychainService, keychainAccount(path), hex.EncodeToString([]byte(token))))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to save the token in the keychain: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+func (keychainStore) Delete(path string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", keychainAccount(path)).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete the token from the keychain: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package token
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainStore keeps tokens in the Secret Service of the desktop session, like GNOME Keyring or KWallet, with the
+// secret-tool of libsecret.
+type keychainStore struct{}
+
+func newKeychainStore() (Store, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, fmt.Errorf("the keychain needs secret-tool, which comes with libsecret: %w", err)
+	}
+	return keychainStore{}, nil
+}
+
+func secretAttributes(path string) []string {
+	return []string{"service", keychainService, "account", keychainAccount(path)}
+}
+
+func (keychainStore) Get(path string) (string, error) {
+	cmd := exec.Command("secret-tool", append([]string{"lookup"}, secretAttributes(path)...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool fails without saying anything when there's no such secret
+		if stderr.Len() == 0 {
+			return "", ErrTokenNotFound
+		}
+		return "", fmt.Errorf("failed to read the token from the keychain: %w: %s", err, stderr.String())
+	}
+	if len(out) == 0 {
+		return "", ErrTokenNotFound
+	}
+	return string(out), nil
+}
+
+func (keychainStore) Set(path, token string) error {
+	args := append([]string{"store", "--label", "cloudflared Access token " + keychainAccount(path)}, secretAttributes(path)...)
+	cmd := exec.Command("secret-tool", args...)
+	// secret-tool reads the secret from its standard input, so that it doesn't show in the process list
+	cmd.Stdin = strings.NewReader(token)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to save the token in the keychain: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+func (keychainStore) Delete(path string) error {
+	cmd := exec.Command("secret-tool", append([]string{"clear"}, secretAttributes(path)...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// Like lookup, clear fails silently when there's no such secret
+	if err := cmd.Run(); err != nil && stderr.Len() != 0 {
+		return fmt.Errorf("failed to delete the token from the keychain: %w: %s", err, stderr.String())
+	}
+	return nil
+}
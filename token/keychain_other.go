@@ -0,0 +1,13 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package token
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newKeychainStore() (Store, error) {
+	return nil, fmt.Errorf("the %q token store isn't supported on %s", KeychainStoreName, runtime.GOOS)
+}
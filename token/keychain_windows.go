@@ -0,0 +1,175 @@
+//go:build windows
+// +build windows
+
+package token
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric          = 1
+	credPersistLocalMachine  = 2
+	credMaxCredentialBlobLen = 5 * 512
+)
+
+var (
+	advapi32        = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW struct of the Credential Manager API
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keychainStore keeps tokens as generic credentials of the Windows Credential Manager. A credential holds at most
+// credMaxCredentialBlobLen bytes, which Access tokens can exceed, so a token is split across a credential and as many
+// continuation credentials as needed, whose targets are suffixed with their index.
+type keychainStore struct{}
+
+func newKeychainStore() (Store, error) {
+	if err := procCredReadW.Find(); err != nil {
+		return nil, fmt.Errorf("the Windows Credential Manager can't be reached: %w", err)
+	}
+	return keychainStore{}, nil
+}
+
+func credentialTarget(path string, index int) string {
+	target := keychainService + ":" + keychainAccount(path)
+	if index > 0 {
+		target = fmt.Sprintf("%s:%d", target, index)
+	}
+	return target
+}
+
+func (keychainStore) Get(path string) (string, error) {
+	var token []byte
+	for i := 0; ; i++ {
+		blob, err := readCredential(credentialTarget(path, i))
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			if i == 0 {
+				return "", ErrTokenNotFound
+			}
+			return string(token), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token from the Credential Manager: %w", err)
+		}
+		token = append(token, blob...)
+	}
+}
+
+func (keychainStore) Set(path, token string) error {
+	blob := []byte(token)
+	i := 0
+	for {
+		chunk := blob
+		if len(chunk) > credMaxCredentialBlobLen {
+			chunk = chunk[:credMaxCredentialBlobLen]
+		}
+		if err := writeCredential(credentialTarget(path, i), keychainAccount(path), chunk); err != nil {
+			return fmt.Errorf("failed to save the token in the Credential Manager: %w", err)
+		}
+		blob = blob[len(chunk):]
+		i++
+		if len(blob) == 0 {
+			break
+		}
+	}
+	// Remove the continuation credentials of a longer token saved before
+	if err := deleteCredentials(path, i); err != nil {
+		return fmt.Errorf("failed to delete the previous token from the Credential Manager: %w", err)
+	}
+	return nil
+}
+
+func (keychainStore) Delete(path string) error {
+	if err := deleteCredentials(path, 0); err != nil {
+		return fmt.Errorf("failed to delete the token from the Credential Manager: %w", err)
+	}
+	return nil
+}
+
+// deleteCredentials deletes the credentials of the token at path from the one with the given index on.
+func deleteCredentials(path string, from int) error {
+	for i := from; ; i++ {
+		err := deleteCredential(credentialTarget(path, i))
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readCredential(target string) ([]byte, error) {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(targetPtr)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := make([]byte, cred.CredentialBlobSize)
+	copy(blob, unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize))
+	return blob, nil
+}
+
+func writeCredential(target, userName string, blob []byte) error {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	userNamePtr, err := windows.UTF16PtrFromString(userName)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetPtr,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userNamePtr,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func deleteCredential(target string) error {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(targetPtr)), credTypeGeneric, 0); r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package token
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FileStoreName keeps tokens in plaintext files in ~/.cloudflared, which is the default
+	FileStoreName = "file"
+	// KeychainStoreName keeps tokens in the keychain of the OS: the macOS Keychain, the Windows Credential Manager,
+	// or the Secret Service of libsecret on Linux
+	KeychainStoreName = "keychain"
+
+	keychainService = "cloudflared-access"
+)
+
+// ErrTokenNotFound is returned by a Store that has no token for a path
+var ErrTokenNotFound = errors.New("token not found")
+
+// Store keeps the Access tokens of cloudflared. Tokens are identified by the path of the file a FileStore keeps them in,
+// which other stores use as a key.
+type Store interface {
+	Get(path string) (string, error)
+	Set(path, token string) error
+	Delete(path string) error
+}
+
+// tokenStore is where tokens are fetched from and saved to
+var tokenStore Store = fileStore{}
+
+// NewStore returns the Store called name, one of FileStoreName and KeychainStoreName.
+func NewStore(name string) (Store, error) {
+	switch name {
+	case "", FileStoreName:
+		return fileStore{}, nil
+	case KeychainStoreName:
+		return newKeychainStore()
+	default:
+		return nil, fmt.Errorf("unknown token store %q, expected %q or %q", name, FileStoreName, KeychainStoreName)
+	}
+}
+
+// SetStore changes where tokens are fetched from and saved to.
+func SetStore(store Store) {
+	tokenStore = store
+}
+
+type fileStore struct{}
+
+func (fileStore) Get(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", ErrTokenNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (fileStore) Set(path, token string) error {
+	return ioutil.WriteFile(path, []byte(token), 0600)
+}
+
+func (fileStore) Delete(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// keychainAccount is the account the keychain keeps the token of path under, which is the name of its file
+func keychainAccount(path string) string {
+	return filepath.Base(path)
+}
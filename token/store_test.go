@@ -0,0 +1,42 @@
+package token
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	store, err := NewStore(FileStoreName)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "example.com-aud-token")
+
+	_, err = store.Get(path)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+
+	require.NoError(t, store.Set(path, "jwt"))
+	token, err := store.Get(path)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt", token)
+
+	require.NoError(t, store.Delete(path))
+	_, err = store.Get(path)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	// Deleting a token that isn't there isn't an error
+	assert.NoError(t, store.Delete(path))
+}
+
+func TestNewStoreUnknown(t *testing.T) {
+	_, err := NewStore("plaintext")
+	assert.Error(t, err)
+}
+
+func TestJWTPayloadExpiresWithin(t *testing.T) {
+	payload := jwtPayload{Exp: int(time.Now().Add(3 * time.Minute).Unix())}
+	assert.False(t, payload.isExpired())
+	assert.True(t, payload.expiresWithin(tokenRefreshWindow))
+	assert.False(t, payload.expiresWithin(time.Minute))
+}
@@ -27,6 +27,10 @@ const (
 	appDomainHeader       = "CF-Access-Domain"
 	appAUDHeader          = "CF-Access-Aud"
 	AccessLoginWorkerPath = "/cdn-cgi/access/login"
+
+	// tokenRefreshWindow is how long before it expires an app token is renewed with the org token, so that it doesn't
+	// expire in the middle of a session
+	tokenRefreshWindow = 5 * time.Minute
 )
 
 var (
@@ -70,6 +74,10 @@ func (p jwtPayload) isExpired() bool {
 	return int(time.Now().Unix()) > p.Exp
 }
 
+func (p jwtPayload) expiresWithin(d time.Duration) bool {
+	return int(time.Now().Add(d).Unix()) > p.Exp
+}
+
 func (s *signalHandler) register(handler func()) {
 	s.sigChannel = make(chan os.Signal, 1)
 	signal.Notify(s.sigChannel, s.signals...)
@@ -167,7 +175,7 @@ func FetchToken(appURL *url.URL, appInfo *AppInfo, log *zerolog.Logger) (string,
 // getToken will either load a stored token or generate a new one
 func getToken(appURL *url.URL, appInfo *AppInfo, useHostOnly bool, log *zerolog.Logger) (string, error) {
 	if token, err := GetAppTokenIfExists(appInfo); token != "" && err == nil {
-		return token, nil
+		return refreshAppToken(appURL, appInfo, token, log), nil
 	}
 
 	appTokenPath, err := GenerateAppTokenFilePathFromURL(appInfo.AppDomain, appInfo.AppAUD, keyName)
@@ -208,8 +216,8 @@ func getToken(appURL *url.URL, appInfo *AppInfo, useHostOnly bool, log *zerolog.
 			log.Debug().Msgf("failed to exchange org token for app token: %s", err)
 		} else {
 			// generate app path
-			if err := ioutil.WriteFile(appTokenPath, []byte(appToken), 0600); err != nil {
-				return "", errors.Wrap(err, "failed to write app token to disk")
+			if err := tokenStore.Set(appTokenPath, appToken); err != nil {
+				return "", errors.Wrap(err, "failed to save app token")
 			}
 			return appToken, nil
 		}
@@ -218,6 +226,44 @@ func getToken(appURL *url.URL, appInfo *AppInfo, useHostOnly bool, log *zerolog.
 
 }
 
+// refreshAppToken exchanges the org token for a new app token when token expires within tokenRefreshWindow. It returns
+// token as it is when it isn't about to expire, or when it can't be renewed without going through the login page.
+func refreshAppToken(appURL *url.URL, appInfo *AppInfo, token string, log *zerolog.Logger) string {
+	payload, err := parseTokenPayload(token)
+	if err != nil || !payload.expiresWithin(tokenRefreshWindow) {
+		return token
+	}
+	orgToken, err := GetOrgTokenIfExists(appInfo.AuthDomain)
+	if err != nil || orgToken == "" {
+		return token
+	}
+	appToken, err := exchangeOrgToken(appURL, orgToken)
+	if err != nil {
+		log.Debug().Msgf("failed to refresh app token with org token: %s", err)
+		return token
+	}
+	appTokenPath, err := GenerateAppTokenFilePathFromURL(appInfo.AppDomain, appInfo.AppAUD, keyName)
+	if err != nil {
+		return appToken
+	}
+	if err := tokenStore.Set(appTokenPath, appToken); err != nil {
+		log.Debug().Msgf("failed to save refreshed app token: %s", err)
+	}
+	return appToken
+}
+
+func parseTokenPayload(token string) (*jwtPayload, error) {
+	parsed, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	var payload jwtPayload
+	if err := json.Unmarshal(parsed.UnsafePayloadWithoutVerification(), &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
 // getTokensFromEdge will attempt to use the transfer service to retrieve an app and org token, save them to disk,
 // and return the app token.
 func getTokensFromEdge(appURL *url.URL, appAUD, appTokenPath, orgTokenPath string, useHostOnly bool, log *zerolog.Logger) (string, error) {
@@ -235,15 +281,15 @@ func getTokensFromEdge(appURL *url.URL, appAUD, appTokenPath, orgTokenPath strin
 		return "", errors.Wrap(err, "failed to marshal transfer service response")
 	}
 
-	// If we were able to get the auth domain and generate an org token path, lets write it to disk.
+	// If we were able to get the auth domain and generate an org token path, lets save it.
 	if orgTokenPath != "" {
-		if err := ioutil.WriteFile(orgTokenPath, []byte(resp.OrgToken), 0600); err != nil {
-			return "", errors.Wrap(err, "failed to write org token to disk")
+		if err := tokenStore.Set(orgTokenPath, resp.OrgToken); err != nil {
+			return "", errors.Wrap(err, "failed to save org token")
 		}
 	}
 
-	if err := ioutil.WriteFile(appTokenPath, []byte(resp.AppToken), 0600); err != nil {
-		return "", errors.Wrap(err, "failed to write app token to disk")
+	if err := tokenStore.Set(appTokenPath, resp.AppToken); err != nil {
+		return "", errors.Wrap(err, "failed to save app token")
 	}
 
 	return resp.AppToken, nil
@@ -358,7 +404,7 @@ func GetOrgTokenIfExists(authDomain string) (string, error) {
 	}
 
 	if payload.isExpired() {
-		err := os.Remove(path)
+		err := tokenStore.Delete(path)
 		return "", err
 	}
 	return token.CompactSerialize()
@@ -380,7 +426,7 @@ func GetAppTokenIfExists(appInfo *AppInfo) (string, error) {
 	}
 
 	if payload.isExpired() {
-		err := os.Remove(path)
+		err := tokenStore.Delete(path)
 		return "", err
 	}
 	return token.CompactSerialize()
@@ -389,11 +435,11 @@ func GetAppTokenIfExists(appInfo *AppInfo) (string, error) {
 
 // GetTokenIfExists will return the token from local storage if it exists and not expired
 func getTokenIfExists(path string) (*jose.JSONWebSignature, error) {
-	content, err := ioutil.ReadFile(path)
+	content, err := tokenStore.Get(path)
 	if err != nil {
 		return nil, err
 	}
-	token, err := jose.ParseSigned(string(content))
+	token, err := jose.ParseSigned(content)
 	if err != nil {
 		return nil, err
 	}
@@ -406,9 +452,5 @@ func RemoveTokenIfExists(appInfo *AppInfo) error {
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return err
-	}
-
-	return nil
+	return tokenStore.Delete(path)
 }
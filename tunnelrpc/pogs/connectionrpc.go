@@ -210,6 +210,8 @@ type ClientInfo struct {
 	Features []string
 	Version  string
 	Arch     string
+	// ConfigFingerprint is the fingerprint of the configuration the connector runs when it registers the connection
+	ConfigFingerprint string
 }
 
 type ConnectionOptions struct {
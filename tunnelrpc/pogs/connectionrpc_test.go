@@ -22,10 +22,11 @@ func TestMarshalConnectionOptions(t *testing.T) {
 	clientID := uuid.New()
 	orig := ConnectionOptions{
 		Client: ClientInfo{
-			ClientID: clientID[:],
-			Features: []string{"a", "b"},
-			Version:  "1.2.3",
-			Arch:     "macos",
+			ClientID:          clientID[:],
+			Features:          []string{"a", "b"},
+			Version:           "1.2.3",
+			Arch:              "macos",
+			ConfigFingerprint: "0123456789abcdef",
 		},
 		OriginLocalIP:      []byte{10, 2, 3, 4},
 		ReplaceExisting:    false,
@@ -15,8 +15,10 @@ import (
 
 type SessionManager interface {
 	// RegisterUdpSession is the call provided to cloudflared to handle an incoming
-	// capnproto RegisterUdpSession request from the edge.
-	RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext string) (*RegisterUdpSessionResponse, error)
+	// capnproto RegisterUdpSession request from the edge. hostname is empty unless the
+	// session should go to the UDP origin service of an ingress rule. maxDatagramSize is the
+	// largest payload the edge takes in the datagrams of the session, or 0 if it doesn't say.
+	RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext, hostname string, maxDatagramSize uint16) (*RegisterUdpSessionResponse, error)
 	// UnregisterUdpSession is the call provided to cloudflared to handle an incoming
 	// capnproto UnregisterUdpSession request from the edge.
 	UnregisterUdpSession(ctx context.Context, sessionID uuid.UUID, message string) error
@@ -59,7 +61,14 @@ func (i SessionManager_PogsImpl) RegisterUdpSession(p tunnelrpc.SessionManager_r
 		return err
 	}
 
-	resp, registrationErr := i.impl.RegisterUdpSession(p.Ctx, sessionID, dstIP, dstPort, closeIdleAfterHint, traceContext)
+	hostname, err := p.Params.Hostname()
+	if err != nil {
+		return err
+	}
+
+	maxDatagramSize := p.Params.MaxDatagramSize()
+
+	resp, registrationErr := i.impl.RegisterUdpSession(p.Ctx, sessionID, dstIP, dstPort, closeIdleAfterHint, traceContext, hostname, maxDatagramSize)
 	if registrationErr != nil {
 		// Make sure to assign a response even if one is not returned from register
 		if resp == nil {
@@ -99,6 +108,8 @@ func (i SessionManager_PogsImpl) UnregisterUdpSession(p tunnelrpc.SessionManager
 type RegisterUdpSessionResponse struct {
 	Err   error
 	Spans []byte // Spans in protobuf format
+	// MaxDatagramSize is the largest payload cloudflared sends in the datagrams of the session
+	MaxDatagramSize uint16
 }
 
 func (p *RegisterUdpSessionResponse) Marshal(s tunnelrpc.RegisterUdpSessionResponse) error {
@@ -108,6 +119,7 @@ func (p *RegisterUdpSessionResponse) Marshal(s tunnelrpc.RegisterUdpSessionRespo
 	if err := s.SetSpans(p.Spans); err != nil {
 		return err
 	}
+	s.SetMaxDatagramSize(p.MaxDatagramSize)
 	return nil
 }
 
@@ -123,6 +135,7 @@ func (p *RegisterUdpSessionResponse) Unmarshal(s tunnelrpc.RegisterUdpSessionRes
 	if err != nil {
 		return err
 	}
+	p.MaxDatagramSize = s.MaxDatagramSize()
 	return nil
 }
 
@@ -136,7 +149,7 @@ func (c SessionManager_PogsClient) Close() error {
 	return c.Conn.Close()
 }
 
-func (c SessionManager_PogsClient) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext string) (*RegisterUdpSessionResponse, error) {
+func (c SessionManager_PogsClient) RegisterUdpSession(ctx context.Context, sessionID uuid.UUID, dstIP net.IP, dstPort uint16, closeAfterIdleHint time.Duration, traceContext, hostname string, maxDatagramSize uint16) (*RegisterUdpSessionResponse, error) {
 	client := tunnelrpc.SessionManager{Client: c.Client}
 	promise := client.RegisterUdpSession(ctx, func(p tunnelrpc.SessionManager_registerUdpSession_Params) error {
 		if err := p.SetSessionId(sessionID[:]); err != nil {
@@ -148,6 +161,12 @@ func (c SessionManager_PogsClient) RegisterUdpSession(ctx context.Context, sessi
 		p.SetDstPort(dstPort)
 		p.SetCloseAfterIdleHint(int64(closeAfterIdleHint))
 		p.SetTraceContext(traceContext)
+		if hostname != "" {
+			if err := p.SetHostname(hostname); err != nil {
+				return err
+			}
+		}
+		p.SetMaxDatagramSize(maxDatagramSize)
 		return nil
 	})
 	result, err := promise.Result().Struct()
@@ -907,12 +907,12 @@ type ClientInfo struct{ capnp.Struct }
 const ClientInfo_TypeID = 0x83ced0145b2f114b
 
 func NewClientInfo(s *capnp.Segment) (ClientInfo, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 4})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
 	return ClientInfo{st}, err
 }
 
 func NewRootClientInfo(s *capnp.Segment) (ClientInfo, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 4})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5})
 	return ClientInfo{st}, err
 }
 
@@ -1003,12 +1003,31 @@ func (s ClientInfo) SetArch(v string) error {
 	return s.Struct.SetText(3, v)
 }
 
+func (s ClientInfo) ConfigFingerprint() (string, error) {
+	p, err := s.Struct.Ptr(4)
+	return p.Text(), err
+}
+
+func (s ClientInfo) HasConfigFingerprint() bool {
+	p, err := s.Struct.Ptr(4)
+	return p.IsValid() || err != nil
+}
+
+func (s ClientInfo) ConfigFingerprintBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(4)
+	return p.TextBytes(), err
+}
+
+func (s ClientInfo) SetConfigFingerprint(v string) error {
+	return s.Struct.SetText(4, v)
+}
+
 // ClientInfo_List is a list of ClientInfo.
 type ClientInfo_List struct{ capnp.List }
 
 // NewClientInfo creates a new list of ClientInfo.
 func NewClientInfo_List(s *capnp.Segment, sz int32) (ClientInfo_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 4}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 5}, sz)
 	return ClientInfo_List{l}, err
 }
 
@@ -3593,12 +3612,12 @@ type RegisterUdpSessionResponse struct{ capnp.Struct }
 const RegisterUdpSessionResponse_TypeID = 0xab6d5210c1f26687
 
 func NewRegisterUdpSessionResponse(s *capnp.Segment) (RegisterUdpSessionResponse, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
 	return RegisterUdpSessionResponse{st}, err
 }
 
 func NewRootRegisterUdpSessionResponse(s *capnp.Segment) (RegisterUdpSessionResponse, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2})
 	return RegisterUdpSessionResponse{st}, err
 }
 
@@ -3645,12 +3664,20 @@ func (s RegisterUdpSessionResponse) SetSpans(v []byte) error {
 	return s.Struct.SetData(1, v)
 }
 
+func (s RegisterUdpSessionResponse) MaxDatagramSize() uint16 {
+	return s.Struct.Uint16(0)
+}
+
+func (s RegisterUdpSessionResponse) SetMaxDatagramSize(v uint16) {
+	s.Struct.SetUint16(0, v)
+}
+
 // RegisterUdpSessionResponse_List is a list of RegisterUdpSessionResponse.
 type RegisterUdpSessionResponse_List struct{ capnp.List }
 
 // NewRegisterUdpSessionResponse creates a new list of RegisterUdpSessionResponse.
 func NewRegisterUdpSessionResponse_List(s *capnp.Segment, sz int32) (RegisterUdpSessionResponse_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 0, PointerCount: 2}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 8, PointerCount: 2}, sz)
 	return RegisterUdpSessionResponse_List{l}, err
 }
 
@@ -3695,7 +3722,7 @@ func (c SessionManager) RegisterUdpSession(ctx context.Context, params func(Sess
 		Options: capnp.NewCallOptions(opts),
 	}
 	if params != nil {
-		call.ParamsSize = capnp.ObjectSize{DataSize: 16, PointerCount: 3}
+		call.ParamsSize = capnp.ObjectSize{DataSize: 16, PointerCount: 4}
 		call.ParamsFunc = func(s capnp.Struct) error { return params(SessionManager_registerUdpSession_Params{Struct: s}) }
 	}
 	return SessionManager_registerUdpSession_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
@@ -3790,12 +3817,12 @@ type SessionManager_registerUdpSession_Params struct{ capnp.Struct }
 const SessionManager_registerUdpSession_Params_TypeID = 0x904e297b87fbecea
 
 func NewSessionManager_registerUdpSession_Params(s *capnp.Segment) (SessionManager_registerUdpSession_Params, error) {
-	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3})
+	st, err := capnp.NewStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
 	return SessionManager_registerUdpSession_Params{st}, err
 }
 
 func NewRootSessionManager_registerUdpSession_Params(s *capnp.Segment) (SessionManager_registerUdpSession_Params, error) {
-	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3})
+	st, err := capnp.NewRootStruct(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4})
 	return SessionManager_registerUdpSession_Params{st}, err
 }
 
@@ -3872,12 +3899,39 @@ func (s SessionManager_registerUdpSession_Params) SetTraceContext(v string) erro
 	return s.Struct.SetText(2, v)
 }
 
+func (s SessionManager_registerUdpSession_Params) Hostname() (string, error) {
+	p, err := s.Struct.Ptr(3)
+	return p.Text(), err
+}
+
+func (s SessionManager_registerUdpSession_Params) HasHostname() bool {
+	p, err := s.Struct.Ptr(3)
+	return p.IsValid() || err != nil
+}
+
+func (s SessionManager_registerUdpSession_Params) HostnameBytes() ([]byte, error) {
+	p, err := s.Struct.Ptr(3)
+	return p.TextBytes(), err
+}
+
+func (s SessionManager_registerUdpSession_Params) SetHostname(v string) error {
+	return s.Struct.SetText(3, v)
+}
+
+func (s SessionManager_registerUdpSession_Params) MaxDatagramSize() uint16 {
+	return s.Struct.Uint16(2)
+}
+
+func (s SessionManager_registerUdpSession_Params) SetMaxDatagramSize(v uint16) {
+	s.Struct.SetUint16(2, v)
+}
+
 // SessionManager_registerUdpSession_Params_List is a list of SessionManager_registerUdpSession_Params.
 type SessionManager_registerUdpSession_Params_List struct{ capnp.List }
 
 // NewSessionManager_registerUdpSession_Params creates a new list of SessionManager_registerUdpSession_Params.
 func NewSessionManager_registerUdpSession_Params_List(s *capnp.Segment, sz int32) (SessionManager_registerUdpSession_Params_List, error) {
-	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 3}, sz)
+	l, err := capnp.NewCompositeList(s, capnp.ObjectSize{DataSize: 16, PointerCount: 4}, sz)
 	return SessionManager_registerUdpSession_Params_List{l}, err
 }
 
@@ -4457,7 +4511,7 @@ func (c CloudflaredServer) RegisterUdpSession(ctx context.Context, params func(S
 		Options: capnp.NewCallOptions(opts),
 	}
 	if params != nil {
-		call.ParamsSize = capnp.ObjectSize{DataSize: 16, PointerCount: 3}
+		call.ParamsSize = capnp.ObjectSize{DataSize: 16, PointerCount: 4}
 		call.ParamsFunc = func(s capnp.Struct) error { return params(SessionManager_registerUdpSession_Params{Struct: s}) }
 	}
 	return SessionManager_registerUdpSession_Results_Promise{Pipeline: capnp.NewPipeline(c.Client.Call(call))}
@@ -4566,234 +4620,241 @@ func CloudflaredServer_Methods(methods []server.Method, s CloudflaredServer_Serv
 	return methods
 }
 
-const schema_db8274f9144abc7e = "x\xda\xccZ{t\x1c\xe5u\xbfwfW#\x19\xc9" +
-	"\xaba\x96H\x96#\xab\xd1\xb1Kqb@v\x9d\x82" +
-	"\x9bF\x92\x91\x1cV\xf8\xa1\xd9\xb5s\xa8\xb1s\x18\xed" +
-	"~\x92F\x9d\x9dYff\x85\xe5\xe0\xd886\x06\x0e" +
-	"\x10 6`'n\x8c\x1d\xda\x83\x09)\x0e\xa6)=" +
-	"\xa4\xc5i\x1c\x12H\x1c\x9c\x03\xa9\x89I\xd3\xc6q[" +
-	"\xfb\x98R\x0c4\xc7m\xcc\xf4\xdc\x99\x9d\x87v\x17=" +
-	" \x7f\xe4\xbf\xd5\x9d\xfb=\xee\xef\xfb\xdd\xc7w?]" +
-	"}\xa0\xae\x8b\xeb\x88oN\x00\xc8\x87\xe35\x0e\x9b\xff" +
-	"\xd3\x8d\xfb\xe6\xfd\xd3V\x90[\x10\x9d/<\xd7\x97\xbc" +
-	"`o=\x09q^\x00X\xf4\x98\xb0\x11\xa5g\x05\x01" +
-	"@zF\xf8\x0f@\xe7\x8e\x8f<\xf5\xb5\xc7zw~" +
-	"\x11\xc4\x16>T\x06\\\xb4\xbb\xb6\x0f\xa5'kI\xf3" +
-	"\xf1\xda\x1dR]\x9d\x00\xe0\xdc ^uS\xf2\xe5c" +
-	"\xa4\x1d\x9d:FS\xbfU;\x1f%$5\xe9b-" +
-	"M\xfd\xa9\xfcO\xf6\x7fr\xd7K\xdb@l\xe1\xc6M" +
-	"}\xa6n#J\x17]\xcd\x0bu\xab\x00\x9d\xb7w6" +
-	"?\xf1\xe8\xb1\x1fl\x07\xf1r\x84\xd2N\xc5\x19?G" +
-	"@i\xde\x8c\xbf\x01t~\xfc\xeeM\xef\x1c\xfe\xfe\xe2" +
-	";@\xbc\x82\x14\x90\x14\x8e\xceh\xe7\x00\xa5_\xcc\xe8" +
-	"\x04t\xce\x9e\xfb\xbf\x1d\x9f\xbfb\xe5\xfd _\x81\x9c" +
-	"?\xc5\xc5\x19-\x1c\xe0\xa2\xcb.iC@\xa7s\xd9" +
-	"\x8f\x9fmY\xf4\xd0\xce\xb2\xbds\xa4ym\xfd|\x94" +
-	"R\xf5\xb4\xa3\xde\xfa[\x01\x9dO\xff\xc9\xd3\xf7\xf5<" +
-	"\xb4e\x17\x88W\x05\x0b>^\xbf\x96\x16<ZO\x0b" +
-	"\xfe\xcf\xcc\xaf\x1c+^\xf7\xed\x87J;rg9]" +
-	"?\x9f\x14.\xba3\xb4\x8f\xce\xbb\xf9\xbbG\x9f~\x18" +
-	"\xe4\x05\x88\xce\xeb\x03\x1f\x7f\x95\xdf{\xf0$\xacA\x81" +
-	"6\xb8h}\xc3~2/\xdf@\xba?\xf9\xc4s\x7f" +
-	"\x7f\xff\xd3;\xbe\x02\xf2\xe5\x88\x00.\x9c\xc7\x1b\xfe\x97" +
-	"\x14N7\xd0j;O|ge\xfe\x81=\xfb=\x80" +
-	"\xdc\xef\x0d39\x0eb\xce\xb6\xd4o\xf2k\x0ed\x0e" +
-	"\x94\xa0\x8b\xd3'\x9cy\x1e\xc9\xee\x99\xae\xdd\x8b\x7f~" +
-	"z\xd5\x8ao\x0d\xfeudlGb#\x8d\xdd1x" +
-	"\xfeHc:\xffD5D\x16$\x0e\xa2\xd4\x9b D" +
-	"\xba\x13\xb4\xc7'\xe7\xdcP\xb7\xe1\xf4\xb2\xa7@\\\xe0" +
-	"O\xb37\x91\xa6iF_<\xf0\x87\xf3^\xbc\xf5\x10" +
-	"\xc8Wa\x00\xd6n\xfa\x86\xd23\xee\xd8\xd8\xc9y\x07" +
-	"\xbf\xf3\xcb\xfb\x0eW\x90Ll\xdc\x88\xd2\xbcFZ\xe5" +
-	"c\x8d\x9f\x91d\xfa\xe5\xc4\xd6\xf3\xef)\x8f\xfc\xe3\xe1" +
-	"r\x02{'\xd58\x80\xd2\x0a\xd2[\x94jt\xed\xbb" +
-	"\xfb\xc8\x9e\x8f\xd7~\xed\xedg\xaa\xaa\xdf\"\x0e\xa0\xb4" +
-	"]\xa4\x05n\x17\x89I\x97\xa5\xf0\xf5\xe7;b\xdf\x8e" +
-	"Rm\xde\xa5g\x09\xeak/%\x85\xd67\x966\xe8" +
-	"on}\xbe\x0c\x14W\xf1\xb5K\xfbPz\xe3R\x9a" +
-	"\xed\x8c\xab\x1c\xfb\xe4\xc8\x0e\xf1\xd4\xcf\x8ez\xa0x\x96" +
-	"o\x97F\xc8\xf2G%:\xb8\xbe\x9b\xbe\xfc`\xfc\xf4" +
-	"\x97_\xa0\xcdE\x9c N~\xb5\xe8\x88d\xa2\xf4\xaa" +
-	"\xe4\x9e\xb6\xd4\xc4\x03:-O\xfd\xe97\x97\xe6^{" +
-	"\xa9\xca\x89Hr\xd3yi}\x13\xfd\xfa\xf3&\x02\xf5" +
-	"\xd4\x82C\x9f?s\xef\xf1WJ\x96\xb8k?\xd3\xe4" +
-	"\x92\xe6\x87M\xb4\xf6\x85u\xfbnP\x9d\x1bO\x96\x03" +
-	"\xe3j\x9ei\xfa\x16J\xd8\xecz\xab;]\xc0\xd0j" +
-	"\xdaJ\xf3\x08JEW\xfb\x96f\x9a\x9b;\xad\xcc\xda" +
-	"\xf2\xb3O\xbf\x1e!U\xb1\xf9W\x081g\xe5go" +
-	"\x1a\xa9\xdbt\xeaTt[j\xb3\x0b\xf0&w\xe8?" +
-	"\xfc\xf3\xc3\xc3\xeb\xbfy\xect\x94H\xcd&\x11\xe9\xbf" +
-	"\xfe\xea\xec\x97\xce\xe5s\xff\xee\xba\x8c\x7f8\xbb\x9b\x97" +
-	"\x10\x9c\x87\x9a)\xa44\xb55\xf4\xb6\x9f\xe8?\x1b\xc5" +
-	"\xfb\xdeYKI\xe1\xb1Y4\xf9\xe2\x9b\xbb\xd9\xbak" +
-	"n<[\xc1\xb4\xa3\xb3\x96\xa0\xf4\xea,\x17\xebY;" +
-	"P:\xd3\xd2\x04\xe0\x8c\xfe\xed\x037>\xf1\xbd\x95\xe7" +
-	"=/v\xf7\xf2Z\xcbB\xda\xcb}_\xe8Yum" +
-	"\xfb\x91\xf3Q3\x8e\xb7\x9cw]\xb2\x85V\x1a\xbc\xe6" +
-	"\xdcg\xe6\xdd\xf7\xfd\xf3eG\xe5*\xc6g\xcfG\xe9" +
-	"\xb2\xd9\x04\x978\xbb\x13\xf0\xcde\x7f\xf9JK\xa2\xe5" +
-	"\x9d2hkHw\xf1\xec\x11\x94R\xa4\xbb\xa8w\xf6" +
-	"\x0bD\xe8G\xbf\xbe\xff_.\x1c\xbb\xfe\xdd\x0a\x1b\xae" +
-	"m%\xee\xb7\xd2\xb4\xa9VAJ\xb5^\x0e\xe0\xdcq" +
-	"\xf2s\x1b~\xfa\xc5\xb7\xdf-g\x98\xbb\x91\xee\xd64" +
-	"Jk\xdc\x11r+\x11\xf6\xe1\xd5\xff\xb9\xf9\xdc\xae\x8f" +
-	"\xfc\xa6b\xee\xb7ZGP\x8a\xcf!M\x9c\xf3\x82\xb4" +
-	"\x8b~9/\x0b\x07:z6\xbft!rT\x9b\xe6" +
-	"\xf4\x11<\x0f\x09_=\xb5\xe5\x97\x9f\xfbm\x14\x9e\xb1" +
-	"9\xbf\"x\xee\x9dC\xf0\xdc\xf6\xe6\xee\xeb\xbf\xb4\xee" +
-	"\x1b\xefE\x08\xf2\xe4\x9c\xad4\xd4.\xea:\xd3\xccB" +
-	",{\x95\xff3{eV)\xe8\x85%\xddE{\x98" +
-	"\xe9\xb6\x9aUl\x96f\x9dV\xc1\xd0-\xd6\x8f(7" +
-	"\xf21\x80\x18\x02\x88\xca\x08\x80|3\x8f\xb2\xc6\xa1\x88" +
-	"\x98$\xa2\x88*\x09\x87y\x94m\x0eE\x8eKR\x84" +
-	"\x15oi\x07\x905\x1e\xe5\x0d\x1c\"\x9fD\x1e@," +
-	">\x08 o\xe0Q\xde\xc6\xa1S`f^\xd1\x99\x0e" +
-	"\x09\xbb\xd74\xb1\x1e8\xac\x07tLf\x9bc\xca\x80" +
-	"\x06\x09\x16\x11\x0b#\xb7\xda\xd8\x00\x1c6\x00:\xc3F" +
-	"\xd1\xb4\xd6\xe86\xaaZ\x9a\x0d\x9a\xcc\xc2a\xac\x01\x0e" +
-	"k\x00'2/\xc3,K5\xf4\x15\x8a\xae\x0c1\x13" +
-	"\x80,\xab\xe5\xe3\x00A\xfaB?\xd1\x89\x1d{\x80\x13" +
-	"\x17\x08\x18f\x1a\xf4\xc9*~\xec pb\xab\xe0\x98" +
-	"lH\xb5lf\xe2\x9a\\\xc1\x9d\x9b7\xf4.t\x8a" +
-	"\xba\xf7\x01\x99\xe9}H\xd0\xaa]\xd8\x8f\xe1\xee\xf8\xca" +
-	"\xdd]\xa7\xa9L\xb7\x13)}\xd0(\x83\xbc\xaf\x1a\xe4" +
-	"}%\xc8\xb7E \xbf})\x80|\x1b\x8f\xf2\x9d\x1c" +
-	"\x8a|\x09\xf3\xed\xf3\x01\xe4-<\xca\xf7p\xe8d\xdd" +
-	"ER9\x00\x08\xd0\x1cd\x8a]4\x99E\xb2\x99\x80" +
-	"\xfd<\xba\xa0\xcf\x04\xdc<\xcaL\xda\xbb\x7f\x08\x09\xc5" +
-	"\xcc\x0e\x07\x075\x01\xd2\xbd\x1bT\xcbV\xf5\xa1\xd5\xae" +
-	"\xbc\xb3\xdf\xd0\xd4\xec\x18YU\xef\xee\xb3u\x09\x00\xa2" +
-	"x\xd9Z\x00\xe4Dq)@\xa7:\xa4\x1b&sr" +
-	"\xaa\x955t\x9d\x01\x9f\xb57\x0f(\x9a\xa2gY\xb0" +
-	"PM\xe5B\xde\x02\x19f\x8e2\xf3J%B\xdf\xb9" +
-	"\xfd\x8a\xa9\xf0yK\xae\x0fp\xec]\x0b \xf7\xf0(" +
-	"\xf7Gp\\A8.\xe7Q\xbe1\x82\xe3\x1a\xc2\xb1" +
-	"\x9fGy\x1d\x87\x8ea\xaaC\xaa~\x1d\x03\xde\x8c2" +
-	"\xd0\xb2u%\xcf\x08\xb3\x12\x1e\x9b\x8d\x82\xad\x1a\xba\x85" +
-	"\x8da\xd6\x01\xc4\xc6\x08R\xc2d\x9c\xbc\xd2\xa7\x94\xcf" +
-	"(C\x9f\x9bfVQ\xd0lK\x8e\x05\x964,\x01" +
-	"\x90ky\x94\x93\x1cv\x9a\xcc*j66\x86\xe5\xc4" +
-	"\xefbU\x1f\xbed\xb0\xe8\xa6t\x84\\>|\xdb\x17" +
-	"\x86\xe4\xc2\x12zw\x11z\xdbx\x94\xef'\x16\xa2\xc7" +
-	"\xc2{\xf7\x00\xc8\xf7\xf3(\x7f\x95C1\xc6%1\x86" +
-	"(\xee\xa6\xb8\xf1\x08\x8f\xf2\xd79t,o\xe5\x14`" +
-	"\xce\x87\xb9-g\xd9\xa9\x82\xff\xd7\xe6\x9ce\xf7\x1b\xa6" +
-	"\x8d\x02p(\x00\x91\xd9\xb0X\xf7 9Z*\xa7\xb1" +
-	"\xebU^\xb71\x0e\x1c\xc6\xc9zS\xc9\xb2\xeb\x0c\x8a" +
-	".l\x83]:$\x10q\x06\xc0D^\xe8\x11*A" +
-	"\x91\xd0\x0b\x0f\xbe\xf9W\x10{\xfe\x88G\xf9\x8f#\xe6" +
-	"w\x90\x01W\xf3(\x7f\x8aCG\xc9f\x8d\xa2n\xaf" +
-	"\x06^\x19*s\x92\x0c\x83D\xd6d!\x7f\xfcek" +
-	"\xab\xc4\x01C\x1fT\x87\x8a\xa6bGN\xa8X\xc8)" +
-	"6\x1b\xf7\xc9%\x86\xc6O\x81\x18A!2mb\xf8" +
-	"\xa1\xac\x8c\x1a\x09S\xc9[Ql\xd2\xd5\xb0!\x1a|" +
-	"\x82G\xf9\x9a\xea\x87\xbb9\xcf,K\x19b\x15\xf1$" +
-	"^\x15\x13\x9de\xc9\xea4\xf3\xb2\xd2\x95&\xb3\x84\xa2" +
-	"f\xd3.\xea\x1d\xc7\xdb\x06\x91q.\x8f\xf2\xd5\x1c6" +
-	"\xe0{\x8e\xb7\x8f\x05\x0f\x86g\xd4\xc6L\xd30\xb11" +
-	"\xcc\xda%H\xb2\xa5\x05\xd0\xd0{\x98\xad\xa8\x1a\x92\x1f" +
-	"\x07\xa5m\x19p\x93\x05\xa2\x106O<\xb7\x93\xdc)" +
-	"?\xee\xa4\xc8\x1f\x1ay\x94?\xca\xa13D\\\xedg" +
-	"&\xaaFn\xa5\xa2\x1b\x19\x9eeC\"\x97V\x9a9" +
-	"\xddE]~\xd8\x16\x04\xa3&\x1eo\xb2\x12\x08\xa5\xe1" +
-	"\xfdm\xde\x9e#\x11\xa0=\xcc\xde\xc11\xdf>\x10F" +
-	"\x80 \x80\xdeE\xcer'\x8f\xf2\xceH\"z\xa0/" +
-	"\x1a\x02bI\x8c\x01\x88\xbb\x89%;y\x94\xf7q\xe3" +
-	"s<\x1be\xba\xdd\xa3\x0e\x81\xc0\xacPJ[\xecQ" +
-	"\x87\x18\xf0\xd6\x87\x0d\xc6\xb5\x93\xe0a\x0cX\x86\xc6l" +
-	"\xd6\xc3\xb2\x9aB.7\xca\xbc\xef%2\xfa\x87:\x11" +
-	"o\xd3\x15\xdeC\xfcM\xf8eU\xc4\x83\xdaC\xea\x06" +
-	"\xd0.X\x18\xba\x95\xc0\xc2Z\xa8\xcd*(\xba5\x95" +
-	"X\xe2\xad\xef\xc5\x8b\x0a\x9a\x84NU\xa2\x0aZ\xbf\x93" +
-	"\xb8\xe4\xc2\x82\xe3\xe2\xc3\xd2\xd0\xba\xc0\xb8%\xa1qA" +
-	"\x9d\x11\x03\x0ec\x80\x9dYw\xc2\x0a\x0bc\x93\xed\xaa" +
-	"\xd3\xdb\x16a\x1bs\x0b;\xffn\x8c~CA\x14\xf7" +
-	"\x03'6\x08\x8e\xbfs\xf4\xc7\x0b\x15EZl\xa2@" +
-	"\xb4\xaa`\xab\x82\xa1[\xb4V\xc4E\x96Ts\x11\xb3" +
-	"J\x92\xdc\x1a\xf5\x90R\x92|`O\xe8\x0c^\x92\x04" +
-	"\x10\xf7\xee\x07\x90\xf7\xf1(\x7f\x83\xc3N\xaf~\xc3\xc6" +
-	"\xb0\x13Tb\xb5W\xa5,7\xa0-\xabha\xcet" +
-	"LV\xd0\x94,\xeb\xc5RE\x06\x88\xc0!\xba\xae\x94" +
-	"/\x98\xcc\xb2P5t\xb9\xa8h*o\x8f\x05U\xb4" +
-	"^\xcc\xf7\x9blTE\xa3hu\xdb6\xcb\x0b\x05\xdb" +
-	"\x9aJ\x8d\x1d\x02DqTP5\x17\xa0H\x11F\xd5" +
-	"h\x17\x8f\xf2\xf2\x08@)J\xa3\xd7\xf3(\xaf\x0e\x01" +
-	"\x92\xbf\x0b \xaf\xe6Q\xbe\x99\xc3D\xb1\xa8\x06y\xc3" +
-	"\xd1\x8c\xac{\xda\x90X\xa9\xe4\xcb\xd3G\xca\xe2\xd2," +
-	"o\xd8L\x1b\xf38\x9a\x0b-\x9ej\xf8.\x8b\xa3~" +
-	"\xbe\xfb}\xaa$'\xbe\xbc\x118P\x86{{5\xdc" +
-	"\x17F\xec\xf0\xb7\xbcb \xb4C\xf8\x0b6\x16D\x1d" +
-	"\x96\xa7\xbc\xe8\xc3]2\xa6\x1b\x84\x1bB\x9d\xe9\xc6\"" +
-	"\xd7\x07\x97\x1bYE+\x0f!\x89\xf2|\x19\xadl\xa6" +
-	"\x1e\x1e\xa2\x8b\xae*\xb4\xb9\xb0\x120\xd7\xf8\x13Kc" +
-	"\xd8\x07\x90\xd9\x80<f\xb6a\x88\x8dt;.\x05\xc8" +
-	"\xdcF\xf2;1\x84G\xda\x8e-\x00\x99-$\xbf\x07" +
-	"\x83\x9b\xadt\x17\x1e\x04\xc8\xdcC\xe2GH=\xc6\xbb" +
-	"\xde+\xedr\xa7\xdfI\xf2}$\x8f\xc7\x92\x18\x07\x90" +
-	"\xf6\xe2|\x80\xcc#$?L\xf2\x1a.\x895\x00\xd2" +
-	"!\x1c\x01\xc8<E\xf2\xe7H.\xc4\x93t\xb9\x97\x9e" +
-	"E\x13 \xf3w$\xff\x1e\xc9k\x9b\x93X\x0b \x1d" +
-	"q\xe5\xcf\x93\xfcG$\xaf\x9b\x95\xc4:\x00\xe9\x87\xb8" +
-	"\x15 \xf3\x03\x92\xbfB\xf2\x19\x98\xa4\xd2W:\x8e{" +
-	"\x002\xaf\x90\xfc_I~IM\x12/\x01\x90~\xe1" +
-	"\xee\xe7\x04\xc9\x7fM\xf2\xfaX\x92\xeae\xe9\xdfp?" +
-	"@\xe6\xd7$\xffo\x927\x08Il\x00\x90\xdep\xed" +
-	":G\xf2Z\xae\xecb\xe9\xd3\xb8\xec\xf6\xc8\x1bV\xc0" +
-	"\x13V\x0aG\xe8\xf9X\xbf\x91\xa0\x1b\"&\xc2\xa65" +
-	" &\x00\x9d\x82ah+\xc7\xbbG\xc2V\x86,\xff" +
-	"\xa6\xda\x18\xf6\xec\x00I\x18\x94r\x900\xf4T.\x88" +
-	"Y\xe5\x01\xd2\xdf\x89ju\x17m\xa3X\x806\xe2b" +
-	".\x08\x16fQ_f\x1a\xf9\xd5\xc8\xcc\xbc\xaa+\xda" +
-	"$\x81\xb3\x0e8\xac\x83R\xa4\xf2\xe7\x9e8\x8a\xbe\xff" +
-	"\xbd;`4W\xce\xe8\xb6\xc2\x92\xd5\xcaPY\xed0" +
-	"\x7f\x92\xda!\xa1G\xe2d\xdb\xa8\xa2\x15+\x8b\xee\x9a" +
-	"iV\x87\xe9N\xaf\xba\x9c\xec\xf2\xe1\xb7\xde\xca\xe2W" +
-	"\x95ZiMe)\x91fV[\xa1J\xb1t0\xbc" +
-	"Y\xf8\xf6.n\x8f\xdc\xc44\xc5f\x96\xdd]\xc0\x82" +
-	"\xa6\xb2\xdcg\x99\x99\x88V\x17\xd1Rjj\xa9l\\" +
-	"\xd1\xe6\x1a\x8c\x91\x07\x062\x9c+\x19<e<\x87\x98" +
-	"\xed\xfdJ\xe9\x83\x06\x95LB\xb4\x94\x9c\xde\xe84\xb3" +
-	"\x12S9\x8b\xb0g:y)<\x9dh\x9dfm." +
-	"\x17&\xbaXV\x99\xafJ\x1d\xea\xdf\x93\"\xcd/\"" +
-	"\xf7:\x1e\xe5\xe1\x08\xb9\x19\xa5\xda\x1c\x8fr!\xac\x17" +
-	"\xf2\xe9\xb0\xdd(\xf2\\\xa9\xdfH\xe9\xb7\xc0\xa3|\x1b" +
-	"\x87\x09\xa5h\x0fcc\xf8\x0e5\x0e\x84\xf1-1\xe2" +
-	"zJ\xcf1\xc0\x0d\xbe\xbbF\x92r\xf0@\xf2\x81`" +
-	"|\xdf\xf2\xdb\x82I\x0f0x$([\xf9}\x1b\x19" +
-	"\x9d\xde\xa2\xc4\xdbf\xb7 \xf6\xdf_\xd0o\xb0\x8b\x87" +
-	"6\x02'>.`\xf8\xa8\x80\xfe\x1b\x82\xb8\xd7\x04N" +
-	"\xdc% \x17<\x91\xa1\xff\x14&\xdeu7p\xe2v" +
-	"\x01\xf9\xe0\x85\x0b\xfd\xa6s\xc7\xd8\x0c\x04N\xdc$`" +
-	",x[D\xbfe-\xde2\x02\x9c\xa8\x0a\x18\x0f\x1e" +
-	"\xcf\xd0\x7fK\x11\xd7o\x05N\\\x13\xb6V\xa1\xd3\xb3" +
-	"\xa3\x0b\x1d\x9f\xf3\xd0\xe6\xb2~|\xa3\xd5\xd3\x02\xe8B" +
-	"\xc7\xbf\xb7\xf1\xefwqs\xb5\xfc^!$\xb2\x8a\xcd" +
-	"\xba\xa8P\xf6\x02\x1c\x96\"\x1ct\xa1\x1c\xc3H\xc7>" +
-	"\xd27\xfa\x80\x8d\x93\x0a?\x99f!\xea\x8f\xff\x801" +
-	"\x97\xaf\xb6kZ'\xe89G\xe6\xa5\x8a\xbc\x9eG\xb9" +
-	"\x99\x9b\xa4\xe0\xae\x1a:\xbd\x0d\xfb\xe4O\xd0`\x9a\xff" +
-	"\x0f\x82\xf9\x8fS\xb8\xfe\x11\x8f\xf2\x89\x88[\xbfJ\xc2" +
-	"\x97y\x94_\x8f\x94\xa3\xaf\x91\xaf\x9f\xe0Q~'|" +
-	"Fx\xebn\x00\xf9\x1d\x1e\xd3\x91JK\xbcH\x8a\xbf" +
-	"\xa5z\xc4\xad\xb3\xd0\xab\xb3\xe2\xf8 @\xa6\x96\xea\x94" +
-	"\xa4[g\xc5\xbc:K\xc4\x01\x80L#\xc9?\x1a\xad" +
-	"\xb3f\xe1Z\x80L3\xc9\xe7\xe2\xf8k\xb7P4\xc3" +
-	"\xf2W3\x86\x96\xabz\xd5\xe4\xed\xbfk\xa0\xbdLQ" +
-	"\xb5\xa2\xc9\xa0\xfc\x0a\x92\xea\x89\x943\xde\x83\x87\xd7\xc6" +
-	"\xcc\x10\x09sh\x05-\xceitA&\xcad\x9aQ" +
-	"\xcc\x0dj\x8a\xc9r\x19f\x0a^@\xe8\xe7\xe3r-" +
-	"F\xfe\x03\x01 |(\x8e\x90}\xc2\xcc\xd8k\x9a\x06" +
-	"\x9aeW\x8d\x85\xe1U#\xb8i\xac\x0dox\"\xd7" +
-	"U\xba\xe2\x0d\x84\x97\xa3\xb6\xacR\xb4X\x05&\xc03" +
-	"3\xe8\x84Y\xc3FQ\xcb\xa5\x19\x08\xb69Vq\xab" +
-	"\x8bM\x16}\x13~$\xacw#\xa1\xff\xda\x89\xfe\xa3" +
-	"\xa6(\xef\x01N\\A\x91\xd0\x7fxC\xff\xd5]\xec" +
-	">\x08\x9c\xf8g\x14\x09\xfd7g\xf4\x1fR\xc5\x8e\x17" +
-	"\x81\x13;\"\xefA>>\x15\xefA\xde\x07\xd7\x1f\xe8" +
-	"C)\xa1r\xe5\x19\x95\"T\xb4\x11\xf1!:;^" +
-	"B\x8d\x1c\xe7\xb4\x1eQ\xa6\xfc\xf6\x10\xfc\xd3KY\xcc" +
-	"\xa9\xfb\xb0M6?5\xfe\x7f\x00\x00\x00\xff\xff\xda\xbc" +
-	"\xea\xa1"
+const schema_db8274f9144abc7e = "x\xda\xccZ{t\x14\xe7u\xbfwfW#\x01\xab" +
+	"\xd5x6\xb1$c\xb6\xe1@]\x94\xc86\xa8\xa4." +
+	"\x8d#\x09\x0bba\x1e\x9a]\x94cc\x9c\xe3\xd1\xee" +
+	"'i\xd4\xdd\x99\xf5\xcc\xac,\x11\x13\xb0\x02\xc6\xf8\xf8" +
+	"\x1d\xf0\x83\x98\x06p\xdc\xc68\xa4v\x8c\x9b\xb8\xc7\xb4" +
+	"&\xb5c\xc7\x8e\x89\xc9\xc1)\x0e\xd0\xd4!\xb4\x0e\x07" +
+	"\xd7\x05\x9b\xe6\xd0&\x9e\x9e;\xb3\xf3\xd0j\x91P\x9c" +
+	"?\xf2\xdf\xea\xce\xf7\xb8\xdf\xef\xfb\xdd\xc7w\xaf\xae<" +
+	"Q\xd3\xc6\xcd\x8d\xae\x8b\x03\xc8{\xa3U6k\xfa\xe9" +
+	"\x9a\x1d\xb3\xffe\x04\xe4FD\xfb+/,I\x9c\xb3" +
+	"F\x8e@\x94\x17\x00Z\x9e\x10\xd6\xa0\xf4\xbc \x00H" +
+	"\xcf\x09\xff\x09h\xdf\xf1\xc9\xa7\xbf\xf1\xc4\xa2-_\x05" +
+	"\xb1\x91\x0f\x06\x03\xb6<Z\xbd\x04\xa5=\xd54\xf2\xc9" +
+	"\xeaMRM\x8d\x00`_'^qc\xe2\xcd\x034" +
+	":\xbct\x94\x96>]\xdd\x84\x12\xd2\xb0\x96\xdfU'" +
+	"\x11\xd0\xfe\\\xfe'\xbb>\xbb\xf5\xf5\x0d 6r\xa3" +
+	"\xd6n\x9e\xb2\x06\xa5\xf6)\xb4\xf6\xd5SV\x00\xda\x1f" +
+	"l\xa9\x7fj\xe7\x81W7\x82x\x19BI\xd5\xee)" +
+	"?G@I\x9d\xf2\xf7\x80\xf6\x1bgo\xfcp\xef\x0f" +
+	"\xe7\xdf\x01\xe2\x1c\x1a\x804\xa0f\xeaL\x0eP\xfa\xd4" +
+	"\xd4V@\xfb\xe4\xa9\xff\xdb\xf4\xe59\xcb\xef\x07y\x0e" +
+	"r\x00\xd1\x08\x8dh\x9f\xda\xc8\x01\xb6\xdc0\xf5>R" +
+	"\xa7u\xf1\x1b\xcf7\xb6<\xb4\xa5Ly\x8eFb\xac" +
+	"\x09%1F\x1a\xc5b\xb7\x02\xda\x9f\xff\x8bg\xef\xed" +
+	"xh\xfdV\x10\xaf\xf07\xbc%\xb6\x8a6\xbc'F" +
+	"\x1b\xfeO\xed\xd7\x0f\x14\xaf\xf9\xdeC%\x8d\x9cU\xf6" +
+	"\xc4\x9ah\xc0\xcb\xce\x0a3\x07g\xdf\xfc\x83\x97\x9f}" +
+	"\x18\xe4fD\xfbh\xcf\xa7\xdf\xe2\xb7\xef>\x02\xdd(" +
+	"\x90\x82-\xb3kw\xd1\xf1\xe6\xd7\xd2\xd8\x9f|\xe6\x85" +
+	"\x7f\xbc\xff\xd9M_\x07\xf92D\x00G\xf9Gk\xff" +
+	"\x97\x06\xec\xa9\xa5\xdd\xb6\x1c\xde\xb7<\xff\xc0\xb6]." +
+	"@\xce\xf7\xb7j9\x0e\"\xf6\x86\xce\xdf\xe4\xbb\x1fO" +
+	"?^\x82\xce\xb9\x8a\x1f\xd5\x9eA\xc0\x96c\xb5\xce5" +
+	"\xcc\xff\xf9\x89\x15\xcb\xbe\xdb\xfbw\xa1\xb9\xe7\xe2kh" +
+	"\xee\xa6\xde3\xfb\xebR\xf9\xa7\xca\xa9\xe2\x1c\xe6l|" +
+	"7J\xb1:\x82\xa4\xa6\x8e\xee`\xcf\x8c\xebj\x86N" +
+	",~\x1a\xc4fo\x9d'\xeaR\xb4\xce\xe0k\x8f\xff" +
+	"\xe9\xec\xd7n}\x06\xe4+\xd0Gk'}Ci_" +
+	"\x1d\x1d0rd\xf6\xee}\xbf\xb8w\xef\x18\x9a5\x88" +
+	"kPj\x16i\x979\xe2\x17\xa4\x1b\xe8\x97\x1d\xb9\x89" +
+	"\xffHy\xe4\x9f\xf7V\xd4\xab]\xecA\xa9\x9b\xc6\xb5" +
+	"\xc8\xa2s\xc0\xbb\xf6o\xfbt\xf57>x\xae\xe2\xf0" +
+	"\xe1\x8bzP\xba\xe7\"\xda`\xf3Et\x8cOt\xe2" +
+	"\xd1\x17\xe7F\xbe\x17\xe6Z\xb3t\x92\xb0n\x97h\xc0" +
+	"\xa5\xef-\x8ci\xef\x8f\xbcX\xc6\x13g\xe0;\xd2\x12" +
+	"\x94\xceJ\xb4\xdaigp\xe4\xb3\x03\x9b\xc4\xe3?{" +
+	"\xd9\x05\xc5=\xf9=\x89\x01:\xf9\x93\x09\xba\xb9%7" +
+	"~\xed\xc1\xe8\x89\xaf\xbdB\xca\x85\xac Z\xed\\T" +
+	"\xc2@\xe9X\x82~\xbe\x9d\xb8\x98\x07\xb4\x1b\x9f\xfe\xab" +
+	"\xef,\xcc\xbe\xfdz\x05\x92J7\xd4\x9f\x91X=\xfd" +
+	"R\xea\x09\xd4\xe3\xcd\xcf|\xf9\xd7\xf7\x1c<T:\x89" +
+	"\xb3\xf7\xbez\x875\x07\xebi\xefs\xabw\\\xa7\xda" +
+	"\xd7\x1f)\x07\xc6\x19y\xba\xfe\xbb(\xd54\xd0r\xd1" +
+	"\x06Z\xce\xa7h\xa5\xd1j\xc3\x00Jk\x9d\xd1\xc3\x0d" +
+	"\xb46wBiX\xff\xb3\xcf\x1f\x0d\xb1jm\xc3/" +
+	"\x11\"\xf6\xf2/\xde8P\xb3\xf6\xf8\xf1\xb0Z\xb74" +
+	"8\x00ot\xa6\xfe\xd3\xbf>\xdc\x7f\xd3w\x0e\x9c\x08" +
+	"\x13\xa9\xc1 \"\xfd\xd7\xdf\x9e\xbc\xefT>\xfb\x1f\x8e" +
+	"\xcdx\x97\xb3\xb3a\x01\xc1\xf9|\x03\xf9\xab\x8b\x93\xb1" +
+	"E3\x0fw\x9d\x0c\xe3\xbd\xb5q!\x0d\xd8\xd3H\x8b" +
+	"\xcf\xbf\xb9\x9d\xad\xbe\xea\xfa\x93c\x98\xf6F\xe3\x02\x94" +
+	"\x8e5:X7nB\xe9\xf4%\x17\x03\xd8\x83\xff\xf0" +
+	"\xc0\xf5O\xbd\xb4\xfc\x8ck\xc6\x8e.\xef\\2\x8ft" +
+	"\xb9\xf7+\x1d+\xfer\xe6\xfe3\xe1c\xbc}\x09\x19" +
+	"\x96\xf4\xde%\xb4S\xefU\xa7\xbe0\xfb\xde\x1f\x9e)" +
+	"\xbb*g`lz\x13J\x97N'\xb8\x1a\xa6\xb7\x02" +
+	"\xbe\xbf\xf8o\x0e5\xc6\x1b?,\x83\xb6\x8a\xc6^=" +
+	"}\x00%\x99\xc6\xb6,\x9b\xfe\x0a\x11z\xe77w\xfd" +
+	"\xdb\xb9\x03\xd7\x9e\x1ds\x86\xf6\x19\xc4\xfd\x19\xb4\xac<" +
+	"C\x90\xe4\x19\x97\x01\xd8w\x1c\xf9\xd2\xd0O\xbf\xfa\xc1" +
+	"\xd9r\x869\x8at\xceH\xa1t\x933\xe3\x86\x19D" +
+	"\xd8\x87W\xbe\xbb\xee\xd4\xd6O\xfef\xcc\xda\xe7f\x0c" +
+	"\xa0\x14K:\xf6\x9e|E\xdaN\xbf\xec7\x85\xc7\xe7" +
+	"v\xac{\xfd\\\xe8\xaa6&\x97\x10<\x0f\x09\x8f\x1d" +
+	"_\xff\x8b/\xfd6\x0c\xcf\xed\xc9_\x12<[\x93\x04" +
+	"\xcfm\xef?z\xed}\xab\xbf\xfdQ\x88 \xcf%G" +
+	"h\xaaU\xd44\x963\x0a\x91\xcc\x15\xde\xcf\xcc\xe5\x19" +
+	"\xa5\xa0\x15\x16\xb4\x17\xad~\xa6YjF\xb1X\x8a\xb5" +
+	"\x9a\x05]3Y\x17\xa2\\\xc7G\x00\"\x08 *\x03" +
+	"\x00\xf2\xcd<\xca9\x0eE\xc4\x04\x11ETI\xd8\xcf" +
+	"\xa3lq(r\\\x82\\\xacx\xcbL\x009\xc7\xa3" +
+	"<\xc4!\xf2\x09\xe4\x01\xc4\xe2\x83\x00\xf2\x10\x8f\xf2\x06" +
+	"\x0e\xed\x023\xf2\x8a\xc64\x88[\x8b\x0c\x03\xa7\x01\x87" +
+	"\xd3\x00m\x83Y\xc6\xb0\xd2\x93\x838\x0b\x89\x85\x81[" +
+	"-\x8c\x01\x871@\xbb_/\x1af\xb7f\xa1\x9aK" +
+	"\xb1^\x83\x99\xd8\x8fU\xc0a\x15\xe0x\xc7K3\xd3" +
+	"Tum\x99\xa2)}\xcc\x00\xa0\x93U\xf3Q\x00?" +
+	"~\xa1\x17\xe9\xc4\xb9\xdb\x80\x13\x9b\x05\x0cB\x0dzd" +
+	"\x15?\xb5\x1b8\xf1R\xc16X\x9fjZ\xcc\xc0\xee" +
+	"l\xc1Y\x9b\xd7\xb56\xb4\x8b\x9a\xfb\x01\x99\xe1~\x88" +
+	"\xd3\xaem\xd8\x85\x81v\xfcX\xed\xae\xc9\xa9L\xb3\xe2" +
+	"\x9dZ\xafN\x8a%|\xc8\xd7.\x01\x90o\xe3Q\xbe" +
+	"3\x04\xf9F\x12n\xe0Q\xde\x11\x82|\xfbB\x00\xf9" +
+	"\x11\x1e\xe5or(\xf2%\xccw6\x01\xc8\x8f\xf1(" +
+	"\x7f\x8bC1\x12I`\x04@|\x82.\xe2[<\xca" +
+	"{9\xb43\xce\xce\x9dY\x00\xf0!\xeee\x8aU4" +
+	"\x98I\xb2Z\xc0.\x1e\x9d\x9b\xa8\x05\\7\xc8\x0c:" +
+	"\x90w3q\xc5\xc8\xf4\xfb\xb7\x97\xd1\xb5^\xb5o\xb1" +
+	"\x8aZ\x1f3\x0a\x86\xaa\xa1\xe5\x7f\x1b\xe7j\x16\x0d\xa9" +
+	"\xa6\xa5j}+\x1dyk\x97\x9eS3\xc3\x04\xc34" +
+	"\xe7`\x97.\x00@\x14?\xb1\x0a\x009Q\\\x08\xd0" +
+	"\xaa\xf6i\xba\xc1\xec\xacjftMc\xc0g\xacu" +
+	"=JN\xd12\xcc\xdf\xa8j\xecF\xee\x06if\x0c" +
+	"2\xe3r%\xc4\xf7Y]\x8a\xa1\xf0yS\x9e\xe6\x03" +
+	"\xbfh\x15\x80\xdc\xc1\xa3\xdc\x15\x02~\x19\x01\xbf\x94G" +
+	"\xf9\xfa\x10\xf0\xdd\x04|\x17\x8f\xf2j\x0em\xddP\xfb" +
+	"T\xed\x1a\x06\xbc\x11\xa6\xaciiJ\x9e\x11\x9e%<" +
+	"\xd6\xe9\x05K\xd55\x13\xeb\x820\x05\x88u!\xa4\x84" +
+	"\x89H|\xb9\xc7A\x8f\x82\xba6+\xc5\xcc\xa2\x90\xb3" +
+	"L9\xe2\x9f$\xb6\x00@\xae\xe6QNp\xd8j0" +
+	"\xb3\x98\xb3\xb0.H@\xfe\x10\xbbz\xf0M\xf77}" +
+	".E91\x8f\xf2\x8b!\xf8\xf6\xcd\x03\x90\xbf\xcf\xa3" +
+	"\xfc\x12\x87XBo?\xa1\xf7\x02\x8f\xf2\xabD[t" +
+	"i\xfb\xf26\x00\xf9U\x1e\xe5CD[.\x81\x11D" +
+	"\xf1 9\x9a7y\x94\x8fr(F\xf9\x04F\x11\xc5" +
+	"ct#Gy\x94\xdf\xe5P\xac\xc2\x04V\x01\x88\xbf" +
+	"\x1e\x01\x90\xdf\xe5Q\xfe\x90C\xdbtu\xec\x04\xccz" +
+	"\x17\x92\xcc\x9aVg\xc1\xfbk]\xd6\xb4\xbat\xc3B" +
+	"\x018\x14\x88\xc69\xddd\xed\xbdd\xc3\x9d\xd9\x1c\xbb" +
+	"V\xe55\x0b\xa3\xc0a\x94p2\x94\x0c\xbbF'\xc7" +
+	"\xc5\x86<z\x83\x88S\x00*\xdcs\xe9C^\x19\xea" +
+	"P,\xa5\x0f\x0d%\x9fV\xd70\xf0\xf7\x1a\xc7'\xb8" +
+	"l\x8d\x93_v\x9d\x95\x87\xed\x1c\xa2\xe6\x9f\xf1(\xff" +
+	"y\x08\xdb\xb9\x84\xce\x95<\xca\x9f\xe3\xd0V2\x19\xbd" +
+	"\xa8Y+\x81W\xfa\xca,0\xcd \x9e1X@N" +
+	"o\xdb\xea\x0a^\xc9\xb1\xe7\xa2\xa1X\xa1\xeb/\x16\xb2" +
+	"\x8a\xc5F}rX\x97\xe3/\x80u~Z4i\xd6" +
+	"y\x8e\xb5\x8cwqC\xc9\x9balR\x95\xb0!\x8e" +
+	"}\x86G\xf9\xaa\xca|X\x97g\xa6\xa9\xf4\xb11\xce" +
+	"*Z\x11\x13\x8de\xe8\xd4)\xe6\xc6\xc8\xcb\x0df\x0a" +
+	"\xc5\x9cEZL\xb3mW\x0db\xfa,\x1e\xe5+9" +
+	"\x8c\xe1G\xb6\xabG\xf3\x83\xc1\x1d%\x99a\xe8\x06\xd6" +
+	"\x059D\x09\x92Li\x03\xd4\xb5\x0ef)j\x0e\xc9" +
+	"I\xf8\x89v\x19p\x13y\xb9\x006W<\xab\x95l" +
+	"5?\xea\xa6\xc8\xd8\xeax\x94\xa7sh\xf7\x11\xbd\xbb" +
+	"\x98\x81\xaa\x9e]\xaehz\x9ag\x99\x80\xfb\xa5\x9dj" +
+	"'\xbb\xa9\xc3\x0f\xcb\x04\x7f\xd6\xf8\xf3\x0dV\x02\xa14" +
+	"\xbd+\xe9\xea\x1c\x0a\x8b3\x83\\\xc2\xbf\xe6\xdb{\x00" +
+	"\xe4\xf5<\xcaw\x87\xbc\xf3f2\x96;y\x94\xb7\x84" +
+	"\xc2\xe2\x03\xe45\xee\xe7Q~,\x14\x16\x1f%\x96l" +
+	"q\xa3\xea\xa8\x8c\x83\x0d2\xcd\xeaP\xfb@`f " +
+	"%\x15;\xd4>\x06\xbc\xf9q=}\xf5\x04x\xe8=" +
+	"\xa6\x9ec\x16\xeb`\x99\x9cB&7\xc8\xdc\xef%2" +
+	"z\x97:\x1eoSc\xac\x87\xf8\x1b\xf7\x92\xbcP\xe0" +
+	"#h\xdbx\x94\x97\x86\xa0\xed\x9c\x17DC\xcfs/" +
+	"\x1b\x09\x82\xa1\xc0\x82l-i\x16\x14\xcd\xf4!\x99\xd8" +
+	"\xf7U\x9fO[\xd7\xbb\x8c!U`\x82%b\xa1\xf9" +
+	"\x07\xf1b\x0e\x888\xca\x9b,\x0c\xcc\xd8\x83\xa2yA" +
+	"\xe0a\xfct(\x02\x1cF\x00[\xdd\x0ch\x8co\x8d" +
+	"L\xa4U\xab\xab\x16\xddD\xc4IJ\xbdw=z\xd5" +
+	"\x10Q\xdc\x05\x9c\x18\x13lOs\xf4\xe6\x0bc\x12\xcc" +
+	"\xc8xnkE\xc1R\x05]3\xcb\xf2\xcc\x05\x95\x0c" +
+	"\xca\x08\x0c\xca\xbb\xf5\xcd#a{*\xc5\xeb\x07\xb6\x05" +
+	"\xa6\xe3\xc6kJHw\x01\xc8;x\x94\xbf\xcda\xab" +
+	"\x9bfb]P\xc7*\xd9\x80\x9b0-\xd5!\x99Q" +
+	"rAP\xb6\x0dV\xc8)\x19\xb6\x08K\xc9! \x02" +
+	"\x87\xe8\x18^\xbe`0\xd3DU\xd7\xe4\xa2\x92Sy" +
+	"k\xd8\x7f\x01h\xc5|\x97\xc1\x06U\xd4\x8bf\xbbe" +
+	"\xb1\xbcP\xb0\xcc\x0by\x1f\x04\x00\x91\xd7\x15\xd4\x9cY" +
+	"f\x16M\x95\xcc\x82\x82\xee\xb5<\xca+\x03\x80\xe4\x1f" +
+	"\x00\xc8+y\x94o\xe60^,\xaa~\x94\xb1sz" +
+	"\xc6\xb9m\x88/W\xf2\xe5\xc1\xa6\xd3\xe4R,\xaf[" +
+	",7\xecr4\x1b\x9c\xf8B\x9d}\x99\xd7\xf5\xa2\xe3" +
+	"\x1fSR;\xfe\xc3\x93\xc0\x81I\xba#_\xe5e=" +
+	"!\x7f\xf4\xd7l\xd8\xf7G,OQ\xd4\x83\xbbt\x98" +
+	"v\x10\xae\x0b\xc6L\xd6\x1796\xb8T\xcf(\xb9r" +
+	"\x17\x12/\x8f\xae\xe1<\xe8\xc2\xddCx\xd3\x15\x85\xa4" +
+	"\x03+\x01s\x95\xb7\xb04\x8cK\x00\xd2C\xc8cz" +
+	"\x03\x06\xd8H\xb7\xe3B\x80\xf4m$\xbf\x13\x03x\xa4" +
+	"\x8d\xd8\x08\x90^O\xf2\xbb\xd1\x7f\x95K\x9bq7@" +
+	"\xfan\x12?B\xc3#\xbcc\xbd\xd2Vg\xf9-$" +
+	"\xdfA\xf2h$\x81Q\x00i;6\x01\xa4\x1f!\xf9" +
+	"^\x92WqN\xce-=\x83\x03\x00\xe9\xa7I\xfe\x02" +
+	"\xc9\x85h\x02\x05\x00\xe9y4\x00\xd2\xdf'\xf9K$" +
+	"\xaf\xaeO`5\x80\xb4\xdf\x91\xbfH\xf2\x1f\x93\xbc\xa6" +
+	"!\x815\x00\xd2\x8fp\x04 \xfd*\xc9\x0f\x91|\x0a" +
+	"&(\x85\x96\x0e\xe26\x80\xf4!\x92\xff;\xc9\xa7V" +
+	"%p*\x80t\xcc\xd1\xe70\xc9\x7fE\xf2i\x91\x04" +
+	"\xe5\xdd\xd2;\xb8\x0b \xfd+\x92\xff7\xc9cB\x02" +
+	"c\x00\xd2{\xce\xb9N\x91\xbc\x9a+{\xffz4." +
+	"{\xe4\xf2\xba\xe9\xf3\x84\x95\xdc\x11\xba6\xd6\xa5\xc7\xe9" +
+	"\xb1\x8a\xf1\xa0\xe2\x0e\x88q@\xbb\xa0\xeb\xb9\xe5\xa3\xcd" +
+	"#n)}\xa6\xf7\xa0\xae\x0b\xea\x8d\x80$\xf4\x13?" +
+	"\x88\xebZg\xd6\xf7Y\xe5\x0e\xd2\xd3D5\xdb\x8b\x96" +
+	"^,@\x92\xb8\x98\xf5\x9d\x85Q\xd4\x16\x1bz~%" +
+	"2#\xafjJn\x02\xc7Y\x03\x1c\xd6@\xc9Sy" +
+	"k\x8f\xefE\xcf_\x1e\xf0\x19\xcd\x953:YX\xb0" +
+	"R\xe9+{\xc74\x05\xd1\xd57\xed\xe6yAx\x8d" +
+	"k!?\x99\x1cTr\xc5\xb1)z\xd5$s\xc9T" +
+	"\xab\x9b\x8bN\xf4T\xf1\xca\x86e\xfe\xabBf\xd5=" +
+	"6\x95H13\xe9\xd7\xcfB\x07\xde\x1d\xbcC\xbc\xf3" +
+	"\xce\x9f\x19z\xb7\xe5\x14\x8b\x99V{\x01\x0b9\x95e" +
+	"\xbf\xc8\x8cx8\xbb\x08'Y\x17\x16\xcaF\xa5x\xce" +
+	"\x811\xd4\x1d\xa1\x83s\xa5\x03_0\x9e}\xccr\x7f" +
+	"uj\xbd:\xa5LB8\xf1\x9c\xdc\xec\x143\xe3\x17" +
+	"r\x17A\xbdw\xe2\xc4y2\xde:\xc5\x92\x0e\x17\xc6" +
+	"{\x86VX\xafB\x1e\xea\xbd\xaaB\xb5R\"\xf7j" +
+	"\x1e\xe5\xfe\x10\xb9\x19\x85\xda,\x8fr!\xc8\x17\xf2\xa9" +
+	"\xa0T*\xf2\\\xa9VJ\xe1\xb7\xc0\xa3|\x1b\x87q" +
+	"\xa5h\xf5c]\xd0D\x1b\x05\xc2\xe8\xca\x1dq\xbdS" +
+	"\xcb2\xc0!\xcf\\CA\xd9o\xee\xfc^0\x9e7" +
+	"\xfd6a\xc2\x0b\xf4\x1b\x1ce;\x9f\xb7\xec\xd1\xean" +
+	"J\xbc\xadw\x12b\xafw\x84^s@|f\x0dp" +
+	"\xe2\x93\x02\x06\x0d\x11\xf4\xfa\x1f\xe2v\x038q\xab\x80" +
+	"\x9c\xdf\xdfC\xaf\x8f'n\xbe\x0b8q\xa3\x80\xbc\xdf" +
+	"\x9eC\xaf`>wx\x0a\x02'\xae\x150\xe27F" +
+	"\xd1+\xb7\x8b\xb7\x0c\x00'\xaa\x02F\xfd\xce\x1fz}" +
+	" \xf1\xa6\x11\xe0\xc4\xee\xa0,\x0c\xad\xee9\xda\xd0\xf6" +
+	"8\x0fI\x87\xf5\xa3\x8b\xc4\xee(\x806\xb4\xbdW\x1e" +
+	"\x7f\xbeg\x9e3\xca+[B<\xa3X\xac\x8d\x12e" +
+	"\xd7\xc1a\xc9\xc3A\x1b\xca\x11\x0cu\x1b\x00>n\x99" +
+	"e\x8c\x9dL2\x11\xf5\xe6\xff\x9e>\x97\xaf\xa45\xed" +
+	"\xe3\xd7\xcbC\xebRF>\x8dG\xb9\x9e\x9b \xe1\xae" +
+	"\xe8:]\x85=\xf2\xc7i2\xad\xff'\xfe\xfa\x07\xc9" +
+	"]\xff\x98G\xf9p\xc8\xac\xdf\x9a\x19\xaaLz\xe9\xe8" +
+	"\xdbd\xeb\x87\xdd\"\xa4\xd7\x029}\x17\x80\xfc!\x8f" +
+	"\xa9P\xa6%\xfe\x8e\x06\xfe\x96\xf2\x11'\xcfB7\xcf" +
+	"\x8a\xe2\x83\x00\xe9j\xcaS\x12N\x9e\x15q\xf3,\x11" +
+	"{\x00\xd2u$\x9f\x1e\xce\xb3\x1ap\x15@\xba\x9e\xe4" +
+	"\xb3p\xf4\x83\\(\x1aA\xfa\x9b\xd3\xfb\x96\xaaZ\xc5" +
+	"\xe0\xed\xf5d\xd0Z\xac\xa8\xb9\xa2\xc1\xa0\xfc\x09\xd2\xd9" +
+	"\x11Jg\xdcf\x8d['M\x13\x09\xb3h\xfao\xfb" +
+	"I\xd4L\xc6\x8bd9\xbd\x98\xed\xcd)\x06\xcb\xa6\x99" +
+	"!\xb8\x0e\xa1\x8b\x8f\xca\xd5\x18\xfa\xff\x09\x80\xa0\xc9\x1d" +
+	"\"\xfb\xb8\x91q\x91a\xe8h\x94=5\xe6\x05O\x0d" +
+	"\xff\xa5\xb1*x\xe1\x89\\[\xe9\x89\xd7\x13<\x8e\x92" +
+	"\x19\xa5h\xb21\x98\x00\xcf\x0c\xbfnf\xf6\xeb\xc5\\" +
+	"6\xc5@\xb0\x8c\xe11\xaf\xba\xc8D\xde7\xeey\xc2" +
+	"i\x8e'\xf4:\xb5\xe85dEy\x1bp\xe22\xf2" +
+	"\x84^\xd3\x10\xbd\xff\x18\x10\xdbw\x03'^M\x9e\xd0" +
+	"\xeb\x97\xa3\xd7\x04\x16\xe7\xbe\x06\x9c87\xd4\xcb\xf2\xf0" +
+	"\x19\xd3\xcbr?8\xf6@\x1fJ\x01\x95+\x8f\xa8\xe4" +
+	"\xa1\xc2\x85\x88\x8fQ\xd9q\x03j\xe8:'\xd5\xcf\xb9" +
+	"\xe06\x88\xff/;e>\xa7\xe6\xe3\x96\xe4\xbc\xd0\xf8" +
+	"\xff\x01\x00\x00\xff\xffeM\x184"
 
 func init() {
 	schemas.Register(schema_db8274f9144abc7e,
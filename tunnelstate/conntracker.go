@@ -18,6 +18,8 @@ type ConnTracker struct {
 type ConnectionInfo struct {
 	IsConnected bool
 	Protocol    connection.Protocol
+	// Location is the colo of the edge the connection was last registered with.
+	Location string
 }
 
 func NewConnTracker(log *zerolog.Logger) *ConnTracker {
@@ -40,6 +42,7 @@ func (ct *ConnTracker) OnTunnelEvent(c connection.Event) {
 		ci := ConnectionInfo{
 			IsConnected: true,
 			Protocol:    c.Protocol,
+			Location:    c.Location,
 		}
 		ct.connectionInfo[c.Index] = ci
 		ct.Unlock()
@@ -49,11 +52,21 @@ func (ct *ConnTracker) OnTunnelEvent(c connection.Event) {
 		ci.IsConnected = false
 		ct.connectionInfo[c.Index] = ci
 		ct.Unlock()
+	case connection.RegistrationError, connection.ConfigUpdated, connection.SetURL:
+		// These don't change whether a connection is active.
 	default:
 		ct.log.Error().Msgf("Unknown connection event case %v", c)
 	}
 }
 
+// GetConnection returns what's known about the connection at index.
+func (ct *ConnTracker) GetConnection(index uint8) (ConnectionInfo, bool) {
+	ct.RLock()
+	defer ct.RUnlock()
+	ci, ok := ct.connectionInfo[index]
+	return ci, ok
+}
+
 func (ct *ConnTracker) CountActiveConns() uint {
 	ct.RLock()
 	defer ct.RUnlock()
@@ -78,3 +91,14 @@ func (ct *ConnTracker) HasConnectedWith(protocol connection.Protocol) bool {
 	}
 	return false
 }
+
+// Connections returns a snapshot of what's known about every connection, by index.
+func (ct *ConnTracker) Connections() map[uint8]ConnectionInfo {
+	ct.RLock()
+	defer ct.RUnlock()
+	connections := make(map[uint8]ConnectionInfo, len(ct.connectionInfo))
+	for index, ci := range ct.connectionInfo {
+		connections[index] = ci
+	}
+	return connections
+}
@@ -0,0 +1,281 @@
+// Package webhook notifies HTTP endpoints about tunnel lifecycle events, so operators can route them to chat or paging
+// systems without scraping logs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/connection"
+	"github.com/cloudflare/cloudflared/retry"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body, keyed with the configured secret.
+	SignatureHeader = "Cf-Webhook-Signature"
+
+	defaultMaxRetries = 5
+	requestTimeout    = 10 * time.Second
+	queueSize         = 64
+)
+
+// EventType identifies what happened to the tunnel.
+type EventType string
+
+const (
+	// AllConnectionsLost is sent when the last active connection to the edge is lost.
+	AllConnectionsLost EventType = "all_connections_lost"
+	// ConnectionReestablished is sent when a connection to the edge is up again after all of them were lost.
+	ConnectionReestablished EventType = "connection_reestablished"
+	// ConfigReloaded is sent when a new remote configuration is applied.
+	ConfigReloaded EventType = "config_reloaded"
+	// RegistrationError is sent when the edge refuses to register a connection.
+	RegistrationError EventType = "registration_error"
+)
+
+// EventTypes are all the events that can be sent.
+var EventTypes = []EventType{AllConnectionsLost, ConnectionReestablished, ConfigReloaded, RegistrationError}
+
+// Config configures where and how notifications are sent.
+type Config struct {
+	// URLs receive a POST request with a JSON Payload for every event.
+	URLs []string
+	// Header is added to every request.
+	Header http.Header
+	// Secret, if set, is used to sign the body of every request.
+	Secret string
+	// Events limits which events are sent. All events are sent if it's empty.
+	Events []EventType
+	// MaxRetries bounds how many times a failed request is retried, with exponential backoff.
+	MaxRetries uint
+	// RetryBaseTime is the initial backoff period between retries. Defaults to 1 second.
+	RetryBaseTime time.Duration
+}
+
+// Payload is the JSON body sent for every event.
+type Payload struct {
+	Event             EventType `json:"event"`
+	Time              time.Time `json:"time"`
+	ConnectorID       uuid.UUID `json:"connectorId"`
+	ActiveConnections int       `json:"activeConnections"`
+	ConnIndex         *uint8    `json:"connIndex,omitempty"`
+	Location          string    `json:"location,omitempty"`
+	ConfigVersion     *int32    `json:"configVersion,omitempty"`
+	Message           string    `json:"message,omitempty"`
+}
+
+// Notifier is a connection.EventSink that sends webhooks for tunnel lifecycle events.
+type Notifier struct {
+	config      Config
+	events      map[EventType]bool
+	connectorID uuid.UUID
+	sender      *Sender
+	queue       chan Payload
+	log         *zerolog.Logger
+
+	// Only accessed from OnTunnelEvent, which the observer calls from a single goroutine.
+	connected    map[uint8]bool
+	lost         bool
+	shuttingDown bool
+}
+
+// NewNotifier creates a Notifier. Notifications are sent until ctx is done.
+func NewNotifier(ctx context.Context, config Config, connectorID uuid.UUID, log *zerolog.Logger) *Notifier {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	events := make(map[EventType]bool)
+	for _, event := range config.Events {
+		events[event] = true
+	}
+	n := &Notifier{
+		config:      config,
+		events:      events,
+		connectorID: connectorID,
+		sender:      NewSender(config.Header, config.Secret, config.MaxRetries, config.RetryBaseTime),
+		queue:       make(chan Payload, queueSize),
+		log:         log,
+		connected:   make(map[uint8]bool),
+	}
+	go n.run(ctx)
+	return n
+}
+
+// ParseEventTypes validates the names of events.
+func ParseEventTypes(names []string) ([]EventType, error) {
+	events := make([]EventType, 0, len(names))
+	for _, name := range names {
+		event := EventType(name)
+		if !isEventType(event) {
+			return nil, fmt.Errorf("unknown webhook event %s, valid events are %v", name, EventTypes)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func isEventType(event EventType) bool {
+	for _, e := range EventTypes {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) OnTunnelEvent(event connection.Event) {
+	switch event.EventType {
+	case connection.Connected:
+		n.connected[event.Index] = true
+		if n.lost {
+			n.lost = false
+			index := event.Index
+			n.notify(Payload{Event: ConnectionReestablished, ConnIndex: &index, Location: event.Location})
+		}
+	case connection.Unregistering, connection.Disconnected, connection.Reconnecting, connection.RegisteringTunnel:
+		// Connections also unregister when they are lost, only a shutdown isn't worth notifying about
+		if event.EventType == connection.Unregistering && event.ShuttingDown {
+			n.shuttingDown = true
+		}
+		wasActive := n.activeConnections() > 0
+		n.connected[event.Index] = false
+		if wasActive && n.activeConnections() == 0 && !n.shuttingDown {
+			n.lost = true
+			n.notify(Payload{Event: AllConnectionsLost, Message: "all connections to the edge were lost"})
+		}
+	case connection.RegistrationError:
+		index := event.Index
+		payload := Payload{Event: RegistrationError, ConnIndex: &index}
+		if event.Err != nil {
+			payload.Message = event.Err.Error()
+		}
+		n.notify(payload)
+	case connection.ConfigUpdated:
+		version := event.ConfigVersion
+		n.notify(Payload{Event: ConfigReloaded, ConfigVersion: &version})
+	}
+}
+
+func (n *Notifier) activeConnections() int {
+	active := 0
+	for _, connected := range n.connected {
+		if connected {
+			active++
+		}
+	}
+	return active
+}
+
+func (n *Notifier) notify(payload Payload) {
+	if len(n.events) > 0 && !n.events[payload.Event] {
+		return
+	}
+	payload.Time = time.Now().UTC()
+	payload.ConnectorID = n.connectorID
+	payload.ActiveConnections = n.activeConnections()
+	select {
+	case n.queue <- payload:
+	default:
+		n.log.Warn().Str("event", string(payload.Event)).Msg("Dropping webhook notification because too many are pending")
+	}
+}
+
+func (n *Notifier) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-n.queue:
+			body, err := json.Marshal(payload)
+			if err != nil {
+				n.log.Err(err).Msg("Failed to serialize webhook notification")
+				continue
+			}
+			for _, url := range n.config.URLs {
+				if err := n.sender.Send(ctx, url, body); err != nil {
+					n.log.Err(err).Str("url", url).Str("event", string(payload.Event)).Msg("Failed to send webhook notification")
+				}
+			}
+		}
+	}
+}
+
+// Sender POSTs JSON bodies to HTTP endpoints, retrying with backoff while the failure may be temporary.
+type Sender struct {
+	client        *http.Client
+	header        http.Header
+	secret        string
+	maxRetries    uint
+	retryBaseTime time.Duration
+}
+
+// NewSender creates a Sender that adds header to every request, and signs their body if secret is set. A failed
+// request is retried up to maxRetries times, starting after retryBaseTime, which defaults to 1 second.
+func NewSender(header http.Header, secret string, maxRetries uint, retryBaseTime time.Duration) *Sender {
+	return &Sender{
+		client:        &http.Client{Timeout: requestTimeout},
+		header:        header,
+		secret:        secret,
+		maxRetries:    maxRetries,
+		retryBaseTime: retryBaseTime,
+	}
+}
+
+// Send sends body to url, retrying with backoff until it's accepted or retries run out.
+func (s *Sender) Send(ctx context.Context, url string, body []byte) error {
+	backoff := retry.BackoffHandler{MaxRetries: s.maxRetries, BaseTime: s.retryBaseTime}
+	for {
+		retryable, err := s.send(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || !backoff.Backoff(ctx) {
+			return err
+		}
+	}
+}
+
+// send makes a single attempt at delivering body, and tells whether it's worth trying again if it failed.
+func (s *Sender) send(ctx context.Context, url string, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range s.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the value of SignatureHeader for body, so receivers can verify notifications.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
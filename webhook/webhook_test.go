@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/connection"
+)
+
+type receivedNotification struct {
+	payload Payload
+	header  string
+}
+
+func newReceiver(t *testing.T, failures int32) (*httptest.Server, chan receivedNotification) {
+	received := make(chan receivedNotification, 16)
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, Sign("secret", body), r.Header.Get(SignatureHeader))
+		received <- receivedNotification{
+			payload: payload,
+			header:  r.Header.Get("X-Team"),
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func waitForNotification(t *testing.T, received chan receivedNotification) receivedNotification {
+	select {
+	case notification := <-received:
+		return notification
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for webhook notification")
+		return receivedNotification{}
+	}
+}
+
+func TestNotifierLifecycle(t *testing.T) {
+	server, received := newReceiver(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := zerolog.Nop()
+	connectorID := uuid.New()
+	notifier := NewNotifier(ctx, Config{
+		URLs:   []string{server.URL},
+		Header: http.Header{"X-Team": {"sre"}},
+		Secret: "secret",
+	}, connectorID, &log)
+
+	notifier.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected, Location: "SFO"})
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected, Location: "LAX"})
+	notifier.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Disconnected})
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Reconnecting})
+
+	notification := waitForNotification(t, received)
+	assert.Equal(t, AllConnectionsLost, notification.payload.Event)
+	assert.Equal(t, connectorID, notification.payload.ConnectorID)
+	assert.Equal(t, 0, notification.payload.ActiveConnections)
+	assert.Equal(t, "sre", notification.header)
+
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.RegistrationError, Err: errors.New("bad token")})
+	notification = waitForNotification(t, received)
+	assert.Equal(t, RegistrationError, notification.payload.Event)
+	assert.Equal(t, "bad token", notification.payload.Message)
+	require.NotNil(t, notification.payload.ConnIndex)
+	assert.Equal(t, uint8(1), *notification.payload.ConnIndex)
+
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected, Location: "LAX"})
+	notification = waitForNotification(t, received)
+	assert.Equal(t, ConnectionReestablished, notification.payload.Event)
+	assert.Equal(t, "LAX", notification.payload.Location)
+	assert.Equal(t, 1, notification.payload.ActiveConnections)
+
+	notifier.OnTunnelEvent(connection.Event{EventType: connection.ConfigUpdated, ConfigVersion: 7})
+	notification = waitForNotification(t, received)
+	assert.Equal(t, ConfigReloaded, notification.payload.Event)
+	require.NotNil(t, notification.payload.ConfigVersion)
+	assert.Equal(t, int32(7), *notification.payload.ConfigVersion)
+
+	// A connection that unregisters because it was lost is a loss of connectivity
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Unregistering})
+	notification = waitForNotification(t, received)
+	assert.Equal(t, AllConnectionsLost, notification.payload.Event)
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Connected, Location: "LAX"})
+	notification = waitForNotification(t, received)
+	assert.Equal(t, ConnectionReestablished, notification.payload.Event)
+
+	// Shutting down isn't
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Unregistering, ShuttingDown: true})
+	notifier.OnTunnelEvent(connection.Event{Index: 1, EventType: connection.Disconnected})
+	select {
+	case notification := <-received:
+		assert.Failf(t, "unexpected notification", "%+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifierEventFilter(t *testing.T) {
+	server, received := newReceiver(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := zerolog.Nop()
+	notifier := NewNotifier(ctx, Config{
+		URLs:   []string{server.URL},
+		Secret: "secret",
+		Events: []EventType{ConfigReloaded},
+	}, uuid.New(), &log)
+
+	notifier.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Connected})
+	notifier.OnTunnelEvent(connection.Event{Index: 0, EventType: connection.Disconnected})
+	notifier.OnTunnelEvent(connection.Event{EventType: connection.ConfigUpdated, ConfigVersion: 1})
+	assert.Equal(t, ConfigReloaded, waitForNotification(t, received).payload.Event)
+}
+
+func TestNotifierRetries(t *testing.T) {
+	server, received := newReceiver(t, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := zerolog.Nop()
+	notifier := NewNotifier(ctx, Config{URLs: []string{server.URL}, Secret: "secret", RetryBaseTime: time.Millisecond}, uuid.New(), &log)
+	notifier.OnTunnelEvent(connection.Event{EventType: connection.ConfigUpdated, ConfigVersion: 1})
+	assert.Equal(t, ConfigReloaded, waitForNotification(t, received).payload.Event)
+}
+
+func TestParseEventTypes(t *testing.T) {
+	events, err := ParseEventTypes([]string{"all_connections_lost", "config_reloaded"})
+	require.NoError(t, err)
+	assert.Equal(t, []EventType{AllConnectionsLost, ConfigReloaded}, events)
+
+	_, err = ParseEventTypes([]string{"tunnel_deleted"})
+	assert.Error(t, err)
+}
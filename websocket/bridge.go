@@ -0,0 +1,243 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gobwas "github.com/gobwas/ws"
+	"github.com/rs/zerolog"
+
+	"github.com/cloudflare/cloudflared/cfio"
+)
+
+// ErrIdleTimeout is returned by Bridge when it closed a websocket that had no messages for its idle timeout.
+var ErrIdleTimeout = errors.New("websocket idle timeout")
+
+// bridgePingPayload tells the pongs that answer the pings of Bridge apart from the ones the eyeball and the origin
+// send each other.
+var bridgePingPayload = []byte("cloudflared")
+
+// Bridge copies the frames of a websocket between the eyeball and the origin until either side or ctx ends it. Every
+// pingInterval, it sends a ping to both sides and drops the pongs that answer them, so that intermediaries see traffic
+// on an idle websocket. Once no message went through in either direction for idleTimeout, both sides are sent a close
+// frame and Bridge returns ErrIdleTimeout. 0 disables pings, or the idle timeout. It returns nil when either side ended
+// the websocket.
+func Bridge(ctx context.Context, eyeball, origin io.ReadWriter, pingInterval, idleTimeout time.Duration, log *zerolog.Logger) error {
+	toEyeball := newFrameWriter(eyeball, false)
+	// Frames sent by clients are masked, and cloudflared is the client of the origin
+	toOrigin := newFrameWriter(origin, true)
+
+	var lastMessage atomic.Int64
+	lastMessage.Store(time.Now().UnixNano())
+	onMessage := func() {
+		lastMessage.Store(time.Now().UnixNano())
+	}
+	done := make(chan struct{}, 2)
+	go bridgeFrames(ctx, toOrigin, eyeball, "eyeball->origin", onMessage, done, log)
+	go bridgeFrames(ctx, toEyeball, origin, "origin->eyeball", onMessage, done, log)
+
+	var pings <-chan time.Time
+	if pingInterval > 0 {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if idleTimeout > 0 {
+		idleTimer = time.NewTimer(idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return nil
+		case <-pings:
+			if err := toEyeball.ping(); err != nil {
+				return fmt.Errorf("failed to ping the eyeball of the websocket: %w", err)
+			}
+			if err := toOrigin.ping(); err != nil {
+				return fmt.Errorf("failed to ping the origin of the websocket: %w", err)
+			}
+		case <-idle:
+			if silence := time.Since(time.Unix(0, lastMessage.Load())); silence < idleTimeout {
+				idleTimer.Reset(idleTimeout - silence)
+				continue
+			}
+			log.Debug().Msgf("closing the websocket after %s without messages", idleTimeout)
+			_ = toEyeball.close(gobwas.StatusGoingAway, "idle timeout")
+			_ = toOrigin.close(gobwas.StatusGoingAway, "idle timeout")
+			return ErrIdleTimeout
+		}
+	}
+}
+
+func bridgeFrames(ctx context.Context, dst *frameWriter, src io.Reader, dir string, onMessage func(), done chan<- struct{}, log *zerolog.Logger) {
+	defer func() {
+		// Like stream.Pipe, the other direction may write to a stream that was closed when Bridge returned
+		if r := recover(); r != nil {
+			log.Debug().Msgf("Gracefully handled error %v in websocket bridge for %s", r, dir)
+		}
+		done <- struct{}{}
+	}()
+	buf, err := cfio.GetBuffer(ctx, cfio.StreamBufferSize)
+	if err != nil {
+		log.Debug().Err(err).Msgf("websocket bridge for %s didn't get a buffer", dir)
+		return
+	}
+	defer cfio.PutBuffer(buf)
+	if err := copyFrames(dst, &frameReader{src: src, buf: *buf}, onMessage); err != nil {
+		log.Debug().Err(err).Msgf("websocket bridge for %s ended", dir)
+	}
+}
+
+// copyFrames copies the frames of r to dst as they are, except for the pongs to the pings of Bridge.
+func copyFrames(dst *frameWriter, r *frameReader, onMessage func()) error {
+	for {
+		hdr, err := gobwas.ReadHeader(r)
+		if err != nil {
+			return err
+		}
+		if hdr.OpCode == gobwas.OpPong && hdr.Length == int64(len(bridgePingPayload)) {
+			payload := make([]byte, hdr.Length)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return err
+			}
+			if isBridgePong(hdr, payload) {
+				continue
+			}
+			if err := dst.writeFrame(hdr, payload); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hdr.OpCode.IsControl() {
+			onMessage()
+		}
+		if err := dst.copyFrame(hdr, r); err != nil {
+			return err
+		}
+	}
+}
+
+// frameReader reads one side of a websocket through a buffer of the shared buffer manager, so that payloads are
+// copied from it without another buffer.
+type frameReader struct {
+	src        io.Reader
+	buf        []byte
+	start, end int
+}
+
+func (fr *frameReader) Read(p []byte) (int, error) {
+	if fr.start == fr.end {
+		if err := fr.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, fr.buf[fr.start:fr.end])
+	fr.start += n
+	return n, nil
+}
+
+// fill reads src into the buffer, which must have been read entirely.
+func (fr *frameReader) fill() error {
+	n, err := fr.src.Read(fr.buf)
+	fr.start, fr.end = 0, n
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
+
+// copyN writes the next n bytes of src to w.
+func (fr *frameReader) copyN(w io.Writer, n int64) error {
+	for n > 0 {
+		if fr.start == fr.end {
+			if err := fr.fill(); err != nil {
+				if err == io.EOF {
+					return io.ErrUnexpectedEOF
+				}
+				return err
+			}
+		}
+		chunk := fr.buf[fr.start:fr.end]
+		if int64(len(chunk)) > n {
+			chunk = chunk[:n]
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		fr.start += len(chunk)
+		n -= int64(len(chunk))
+	}
+	return nil
+}
+
+func isBridgePong(hdr gobwas.Header, payload []byte) bool {
+	unmasked := append([]byte(nil), payload...)
+	if hdr.Masked {
+		gobwas.Cipher(unmasked, hdr.Mask, 0)
+	}
+	return bytes.Equal(unmasked, bridgePingPayload)
+}
+
+// frameWriter writes whole frames to one side of a websocket, so that pings don't end up in the middle of a frame.
+type frameWriter struct {
+	lock   sync.Mutex
+	w      io.Writer
+	masked bool
+}
+
+func newFrameWriter(w io.Writer, masked bool) *frameWriter {
+	return &frameWriter{
+		w:      w,
+		masked: masked,
+	}
+}
+
+func (fw *frameWriter) writeFrame(hdr gobwas.Header, payload []byte) error {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	if err := gobwas.WriteHeader(fw.w, hdr); err != nil {
+		return err
+	}
+	_, err := fw.w.Write(payload)
+	return err
+}
+
+// copyFrame writes a frame whose payload is the next hdr.Length bytes of r.
+func (fw *frameWriter) copyFrame(hdr gobwas.Header, r *frameReader) error {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	if err := gobwas.WriteHeader(fw.w, hdr); err != nil {
+		return err
+	}
+	return r.copyN(fw.w, hdr.Length)
+}
+
+func (fw *frameWriter) ping() error {
+	return fw.writeControlFrame(gobwas.NewPingFrame(bridgePingPayload))
+}
+
+func (fw *frameWriter) close(code gobwas.StatusCode, reason string) error {
+	return fw.writeControlFrame(gobwas.NewCloseFrame(gobwas.NewCloseFrameBody(code, reason)))
+}
+
+func (fw *frameWriter) writeControlFrame(frame gobwas.Frame) error {
+	if fw.masked {
+		frame = gobwas.MaskFrame(frame)
+	}
+	return fw.writeFrame(frame.Header, frame.Payload)
+}
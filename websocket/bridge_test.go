@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	gobwas "github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cloudflare/cloudflared/cfio"
+)
+
+// readFrames sends the frames read from conn, unmasked, until it's closed.
+func readFrames(conn net.Conn) <-chan gobwas.Frame {
+	frames := make(chan gobwas.Frame, 16)
+	go func() {
+		defer close(frames)
+		for {
+			frame, err := gobwas.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			if frame.Header.Masked {
+				gobwas.Cipher(frame.Payload, frame.Header.Mask, 0)
+			}
+			frames <- frame
+		}
+	}()
+	return frames
+}
+
+// nextDataFrame returns the next data frame of frames, and the control frames that came before it.
+func nextDataFrame(t *testing.T, frames <-chan gobwas.Frame) (gobwas.Frame, []gobwas.Frame) {
+	var control []gobwas.Frame
+	for {
+		select {
+		case frame, ok := <-frames:
+			require.True(t, ok, "connection closed")
+			if !frame.Header.OpCode.IsControl() {
+				return frame, control
+			}
+			control = append(control, frame)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out waiting for a data frame")
+		}
+	}
+}
+
+func newBridge(t *testing.T, pingInterval, idleTimeout time.Duration) (eyeball, origin net.Conn, done <-chan error) {
+	log := zerolog.Nop()
+	eyeball, eyeballSide := net.Pipe()
+	originSide, origin := net.Pipe()
+	t.Cleanup(func() {
+		eyeball.Close()
+		origin.Close()
+	})
+	bridgeDone := make(chan error, 1)
+	go func() {
+		bridgeDone <- Bridge(context.Background(), eyeballSide, originSide, pingInterval, idleTimeout, &log)
+	}()
+	return eyeball, origin, bridgeDone
+}
+
+func TestBridgePings(t *testing.T) {
+	eyeball, origin, _ := newBridge(t, 10*time.Millisecond, 0)
+	eyeballFrames := readFrames(eyeball)
+	originFrames := readFrames(origin)
+
+	require.NoError(t, wsutil.WriteClientBinary(eyeball, []byte("hello")))
+	frame, control := nextDataFrame(t, originFrames)
+	assert.Equal(t, []byte("hello"), frame.Payload)
+	assert.True(t, frame.Header.Masked)
+
+	// Wait for a ping on both sides, and answer it
+	for len(control) == 0 {
+		select {
+		case frame := <-originFrames:
+			control = append(control, frame)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out waiting for a ping")
+		}
+	}
+	assert.Equal(t, gobwas.OpPing, control[0].Header.OpCode)
+	assert.Equal(t, bridgePingPayload, control[0].Payload)
+	require.NoError(t, wsutil.WriteServerMessage(origin, gobwas.OpPong, bridgePingPayload))
+
+	require.NoError(t, wsutil.WriteServerBinary(origin, []byte("world")))
+	frame, control = nextDataFrame(t, eyeballFrames)
+	assert.Equal(t, []byte("world"), frame.Payload)
+	assert.False(t, frame.Header.Masked)
+	require.NotEmpty(t, control)
+	for _, frame := range control {
+		assert.Equal(t, gobwas.OpPing, frame.Header.OpCode, "the pong of the origin must not reach the eyeball")
+	}
+}
+
+func TestBridgeLargeFrames(t *testing.T) {
+	eyeball, origin, _ := newBridge(t, 0, 0)
+	originFrames := readFrames(origin)
+
+	// The payloads are larger than the buffer frames are copied through
+	payload := bytes.Repeat([]byte("0123456789"), cfio.StreamBufferSize/5)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, wsutil.WriteClientBinary(eyeball, payload))
+		frame, _ := nextDataFrame(t, originFrames)
+		assert.Equal(t, payload, frame.Payload)
+	}
+}
+
+func TestBridgeIdleTimeout(t *testing.T) {
+	eyeball, origin, done := newBridge(t, 0, 50*time.Millisecond)
+	eyeballFrames := readFrames(eyeball)
+	originFrames := readFrames(origin)
+
+	require.NoError(t, wsutil.WriteClientBinary(eyeball, []byte("hello")))
+	frame, _ := nextDataFrame(t, originFrames)
+	assert.Equal(t, []byte("hello"), frame.Payload)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrIdleTimeout)
+	case <-time.After(time.Second):
+		require.FailNow(t, "the idle websocket wasn't closed")
+	}
+	for _, frames := range []<-chan gobwas.Frame{eyeballFrames, originFrames} {
+		frame := <-frames
+		assert.Equal(t, gobwas.OpClose, frame.Header.OpCode)
+		code, _ := gobwas.ParseCloseFrameData(frame.Payload)
+		assert.Equal(t, gobwas.StatusGoingAway, code)
+	}
+}
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	gobwas "github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	extensionsHeader  = "Sec-Websocket-Extensions"
+	compressionOffer  = "permessage-deflate"
+	compressionAccept = "permessage-deflate; server_no_context_takeover; client_no_context_takeover"
+)
+
+// deflateTail ends every message compressed with a sync flush, it's removed from the compressed messages that are
+// sent, and added back to the ones that are received (RFC 7692 section 7.2). The empty final block after it makes
+// the flate reader end without an error.
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+// OffersCompression tells whether the handshake request offers the permessage-deflate extension with parameters that
+// cloudflared can accept. Every message is compressed on its own, so the window of the compressor is never shared
+// between messages in either direction.
+func OffersCompression(header http.Header) bool {
+	for _, extensions := range header.Values(extensionsHeader) {
+		for _, offer := range strings.Split(extensions, ",") {
+			if acceptsCompressionOffer(offer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptsCompressionOffer(offer string) bool {
+	params := strings.Split(offer, ";")
+	if strings.TrimSpace(params[0]) != compressionOffer {
+		return false
+	}
+	for _, param := range params[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch strings.TrimSpace(name) {
+		case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+		case "server_max_window_bits":
+			// The compressor of compress/flate always uses a 32KB window
+			if strings.Trim(strings.TrimSpace(value), `"`) != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readCompressedMessage reads the next binary message from the client, inflating it if it's compressed.
+func readCompressedMessage(rw io.ReadWriter) ([]byte, error) {
+	controlHandler := wsutil.ControlFrameHandler(rw, gobwas.StateServerSide)
+	rd := wsutil.Reader{
+		Source:         rw,
+		State:          gobwas.StateServerSide | gobwas.StateExtended,
+		OnIntermediate: controlHandler,
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := controlHandler(hdr, &rd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hdr.OpCode != gobwas.OpBinary {
+			if err := rd.Discard(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		payload, err := io.ReadAll(&rd)
+		if err != nil || !hdr.Rsv1() {
+			return payload, err
+		}
+		return io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail))))
+	}
+}
+
+// writeCompressedMessage writes p to the client as a compressed binary message.
+func writeCompressedMessage(w io.Writer, p []byte) error {
+	var compressed bytes.Buffer
+	fw := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(fw)
+	fw.Reset(&compressed)
+	if _, err := fw.Write(p); err != nil {
+		return err
+	}
+	if err := fw.Flush(); err != nil {
+		return err
+	}
+	frame := gobwas.NewBinaryFrame(bytes.TrimSuffix(compressed.Bytes(), deflateTail[:4]))
+	frame.Header.Rsv = gobwas.Rsv(true, false, false)
+	return gobwas.WriteFrame(w, frame)
+}
@@ -23,10 +23,14 @@ const (
 	defaultPingPeriod = (defaultPongWait * 9) / 10
 
 	PingPeriodContextKey = PingPeriodContext("pingPeriod")
+	// CompressionContextKey is set to true when the handshake accepted the permessage-deflate extension
+	CompressionContextKey = CompressionContext("compression")
 )
 
 type PingPeriodContext string
 
+type CompressionContext string
+
 // GorillaConn is a wrapper around the standard gorilla websocket but implements a ReadWriter
 // This is still used by access carrier
 type GorillaConn struct {
@@ -86,12 +90,16 @@ type Conn struct {
 	// 2. Close only returns after in progress Write is finished, and no more Write will succeed after calling Close.
 	writeLock sync.Mutex
 	done      bool
+	compress  bool
+	// readBuf holds the bytes of the last message that didn't fit in the buffer of Read
+	readBuf bytes.Buffer
 }
 
 func NewConn(ctx context.Context, rw io.ReadWriter, log *zerolog.Logger) *Conn {
 	c := &Conn{
-		rw:  rw,
-		log: log,
+		rw:       rw,
+		log:      log,
+		compress: ctx.Value(CompressionContextKey) == true,
 	}
 	go c.pinger(ctx)
 	return c
@@ -99,11 +107,23 @@ func NewConn(ctx context.Context, rw io.ReadWriter, log *zerolog.Logger) *Conn {
 
 // Read will read messages from the websocket connection
 func (c *Conn) Read(reader []byte) (int, error) {
-	data, err := wsutil.ReadClientBinary(c.rw)
+	if c.readBuf.Len() > 0 {
+		return c.readBuf.Read(reader)
+	}
+
+	var data []byte
+	var err error
+	if c.compress {
+		data, err = readCompressedMessage(c.rw)
+	} else {
+		data, err = wsutil.ReadClientBinary(c.rw)
+	}
 	if err != nil {
 		return 0, err
 	}
-	return copy(reader, data), nil
+	copied := copy(reader, data)
+	c.readBuf.Write(data[copied:])
+	return copied, nil
 }
 
 // Write will write messages to the websocket connection.
@@ -114,7 +134,11 @@ func (c *Conn) Write(p []byte) (int, error) {
 	if c.done {
 		return 0, errors.New("write to closed websocket connection")
 	}
-	if err := wsutil.WriteServerBinary(c.rw, p); err != nil {
+	if c.compress {
+		if err := writeCompressedMessage(c.rw, p); err != nil {
+			return 0, err
+		}
+	} else if err := wsutil.WriteServerBinary(c.rw, p); err != nil {
 		return 0, err
 	}
 
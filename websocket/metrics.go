@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons why a websocket was closed, in the closed_connections metric
+const (
+	CloseReasonEyeball     = "eyeball"
+	CloseReasonOrigin      = "origin"
+	CloseReasonIdleTimeout = "idle_timeout"
+	CloseReasonError       = "error"
+)
+
+var (
+	activeConnections = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "cloudflared",
+			Subsystem: "websocket",
+			Name:      "active_connections",
+			Help:      "Concurrent count of websockets proxied to the origins of each ingress rule",
+		},
+		[]string{"rule"},
+	)
+	transferredBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "cloudflared",
+			Subsystem: "websocket",
+			Name:      "bytes",
+			Help:      "Count of websocket bytes received from (in) and sent to (out) the eyeballs of each ingress rule",
+		},
+		[]string{"rule", "direction"},
+	)
+	closedConnections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "cloudflared",
+			Subsystem: "websocket",
+			Name:      "closed_connections",
+			Help:      "Count of websockets proxied to the origins of each ingress rule that were closed, by the side that ended them or the reason cloudflared closed them",
+		},
+		[]string{"rule", "reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(activeConnections, transferredBytes, closedConnections)
+}
+
+// ConnectionMetrics reports a websocket to the metrics of its ingress rule, from NewConnectionMetrics until Close.
+type ConnectionMetrics struct {
+	rule     string
+	bytesIn  prometheus.Counter
+	bytesOut prometheus.Counter
+	// endedBy is the first side whose reads or writes failed
+	endedBy atomic.Value
+}
+
+// NewConnectionMetrics counts a new websocket of the ingress rule as active.
+func NewConnectionMetrics(rule string) *ConnectionMetrics {
+	activeConnections.WithLabelValues(rule).Inc()
+	return &ConnectionMetrics{
+		rule:     rule,
+		bytesIn:  transferredBytes.WithLabelValues(rule, "in"),
+		bytesOut: transferredBytes.WithLabelValues(rule, "out"),
+	}
+}
+
+// Eyeball wraps the eyeball side of the websocket to count the bytes that go through it.
+func (m *ConnectionMetrics) Eyeball(rw io.ReadWriter) io.ReadWriter {
+	return &meteredReadWriter{ReadWriter: rw, metrics: m, side: CloseReasonEyeball, read: m.bytesIn, written: m.bytesOut}
+}
+
+// Origin wraps the origin side of the websocket, to tell whether it ended the websocket.
+func (m *ConnectionMetrics) Origin(rw io.ReadWriter) io.ReadWriter {
+	return &meteredReadWriter{ReadWriter: rw, metrics: m, side: CloseReasonOrigin}
+}
+
+// Close counts the websocket as closed, given the error that ended its proxying. Without an error, it was ended by
+// the first side that failed, or by the origin when the eyeball side wasn't wrapped.
+func (m *ConnectionMetrics) Close(err error) {
+	activeConnections.WithLabelValues(m.rule).Dec()
+	reason := CloseReasonOrigin
+	switch endedBy, _ := m.endedBy.Load().(string); {
+	case errors.Is(err, ErrIdleTimeout):
+		reason = CloseReasonIdleTimeout
+	case err != nil:
+		reason = CloseReasonError
+	case endedBy != "":
+		reason = endedBy
+	}
+	closedConnections.WithLabelValues(m.rule, reason).Inc()
+}
+
+type meteredReadWriter struct {
+	io.ReadWriter
+	metrics *ConnectionMetrics
+	side    string
+	read    prometheus.Counter
+	written prometheus.Counter
+}
+
+func (rw *meteredReadWriter) Read(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Read(p)
+	rw.count(rw.read, n, err)
+	return n, err
+}
+
+func (rw *meteredReadWriter) Write(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Write(p)
+	rw.count(rw.written, n, err)
+	return n, err
+}
+
+func (rw *meteredReadWriter) count(counter prometheus.Counter, n int, err error) {
+	if counter != nil && n > 0 {
+		counter.Add(float64(n))
+	}
+	if err != nil {
+		rw.metrics.endedBy.CompareAndSwap(nil, rw.side)
+	}
+}
@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testReadWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func metricValue(t *testing.T, metric interface{ Write(*dto.Metric) error }) float64 {
+	var m dto.Metric
+	require.NoError(t, metric.Write(&m))
+	if m.Gauge != nil {
+		return m.Gauge.GetValue()
+	}
+	return m.Counter.GetValue()
+}
+
+func TestConnectionMetrics(t *testing.T) {
+	tests := []struct {
+		name   string
+		endBy  string
+		err    error
+		reason string
+	}{
+		{name: "origin", endBy: CloseReasonOrigin, reason: CloseReasonOrigin},
+		{name: "eyeball", endBy: CloseReasonEyeball, reason: CloseReasonEyeball},
+		{name: "idle", err: fmt.Errorf("bridge: %w", ErrIdleTimeout), reason: CloseReasonIdleTimeout},
+		{name: "error", err: fmt.Errorf("failed to ping"), reason: CloseReasonError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := "metrics-test-" + test.name
+			metrics := NewConnectionMetrics(rule)
+			assert.Equal(t, 1.0, metricValue(t, activeConnections.WithLabelValues(rule)))
+
+			var sent bytes.Buffer
+			eyeball := metrics.Eyeball(&testReadWriter{Reader: strings.NewReader("hello"), Writer: &sent})
+			origin := metrics.Origin(&testReadWriter{Reader: strings.NewReader(""), Writer: io.Discard})
+			_, err := eyeball.Write([]byte("hi"))
+			require.NoError(t, err)
+			_, err = io.ReadAll(io.LimitReader(eyeball, 5))
+			require.NoError(t, err)
+			switch test.endBy {
+			case CloseReasonEyeball:
+				_, err = eyeball.Read(make([]byte, 1))
+			case CloseReasonOrigin:
+				_, err = origin.Read(make([]byte, 1))
+			}
+			if test.endBy != "" {
+				assert.ErrorIs(t, err, io.EOF)
+			}
+			metrics.Close(test.err)
+
+			assert.Equal(t, 5.0, metricValue(t, transferredBytes.WithLabelValues(rule, "in")))
+			assert.Equal(t, 2.0, metricValue(t, transferredBytes.WithLabelValues(rule, "out")))
+			assert.Equal(t, 0.0, metricValue(t, activeConnections.WithLabelValues(rule)))
+			assert.Equal(t, 1.0, metricValue(t, closedConnections.WithLabelValues(rule, test.reason)))
+		})
+	}
+}
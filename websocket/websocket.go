@@ -19,6 +19,9 @@ func NewResponseHeader(req *http.Request) http.Header {
 	header.Add("Connection", "Upgrade")
 	header.Add("Sec-Websocket-Accept", generateAcceptKey(req.Header.Get("Sec-WebSocket-Key")))
 	header.Add("Upgrade", "websocket")
+	if OffersCompression(req.Header) {
+		header.Add(extensionsHeader, compressionAccept)
+	}
 	return header
 }
 
@@ -1,9 +1,21 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	gobwas "github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -16,3 +28,93 @@ const (
 func TestGenerateAcceptKey(t *testing.T) {
 	assert.Equal(t, testSecWebsocketAccept, generateAcceptKey(testSecWebsocketKey))
 }
+
+func TestOffersCompression(t *testing.T) {
+	tests := []struct {
+		extensions string
+		offers     bool
+	}{
+		{extensions: "", offers: false},
+		{extensions: "permessage-deflate", offers: true},
+		{extensions: "permessage-deflate; client_max_window_bits", offers: true},
+		{extensions: "permessage-deflate; server_no_context_takeover; client_no_context_takeover", offers: true},
+		{extensions: "permessage-deflate; server_max_window_bits=10", offers: false},
+		{extensions: `permessage-deflate; server_max_window_bits="15"`, offers: true},
+		{extensions: "permessage-deflate; server_max_window_bits=10, permessage-deflate", offers: true},
+		{extensions: "x-webkit-deflate-frame", offers: false},
+		{extensions: "permessage-deflate; unknown_param", offers: false},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.extensions != "" {
+			header.Set("Sec-Websocket-Extensions", test.extensions)
+		}
+		assert.Equal(t, test.offers, OffersCompression(header), test.extensions)
+	}
+}
+
+func TestCompressedConn(t *testing.T) {
+	log := zerolog.Nop()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		resp := &http.Response{
+			StatusCode: http.StatusSwitchingProtocols,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     NewResponseHeader(r),
+		}
+		require.NoError(t, resp.Write(rw))
+		require.NoError(t, rw.Flush())
+
+		ctx, cancel := context.WithCancel(context.WithValue(r.Context(), CompressionContextKey, true))
+		defer cancel()
+		wsConn := NewConn(ctx, conn, &log)
+		defer wsConn.Close()
+		buf := make([]byte, 1024)
+		n, err := wsConn.Read(buf)
+		require.NoError(t, err)
+		_, err = wsConn.Write(buf[:n])
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	client, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, "permessage-deflate; server_no_context_takeover; client_no_context_takeover", resp.Header.Get("Sec-Websocket-Extensions"))
+
+	message := []byte(strings.Repeat("terminal output ", 32))
+	require.NoError(t, client.WriteMessage(websocket.BinaryMessage, message))
+	_, echoed, err := client.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, message, echoed)
+}
+
+func TestWriteCompressedMessage(t *testing.T) {
+	message := []byte(strings.Repeat("terminal output ", 32))
+	var buf bytes.Buffer
+	require.NoError(t, writeCompressedMessage(&buf, message))
+
+	frame, err := gobwas.ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.True(t, frame.Header.Rsv1())
+	assert.Less(t, len(frame.Payload), len(message))
+}
+
+func TestConnReadLargeMessage(t *testing.T) {
+	log := zerolog.Nop()
+	var rw bytes.Buffer
+	require.NoError(t, wsutil.WriteClientBinary(&rw, []byte("0123456789")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := NewConn(ctx, &rw, &log)
+	defer conn.Close()
+
+	// The bytes of the message that don't fit in the buffer are returned by the next reads
+	data, err := io.ReadAll(io.LimitReader(iotest.OneByteReader(conn), 10))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0123456789"), data)
+}